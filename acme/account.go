@@ -110,7 +110,7 @@ func (a *Account) GetPrivateKey() crypto.PrivateKey {
 		return privateKey
 	}
 
-	log.Errorf("Cannot unmarshall private key %+v", a.PrivateKey)
+	log.Errorf("Cannot unmarshall the private key of the account %s", a.Email)
 	return nil
 }
 
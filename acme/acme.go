@@ -18,6 +18,7 @@ import (
 	"github.com/BurntSushi/ty/fun"
 	"github.com/cenk/backoff"
 	"github.com/containous/flaeg"
+	"github.com/containous/flaeg/parse"
 	"github.com/containous/mux"
 	"github.com/containous/staert"
 	"github.com/containous/traefik/cluster"
@@ -38,35 +39,80 @@ var (
 	OSCPMustStaple = false
 )
 
+// defaultClusterStorage is the key of the ACME account and certificates in the KV store when none is configured
+const defaultClusterStorage = "traefik/acme/account"
+
 // ACME allows to connect to lets encrypt and retrieve certs
 // Deprecated Please use provider/acme/Provider
 type ACME struct {
-	Email                 string                      `description:"Email address used for registration"`
-	Domains               []types.Domain              `description:"SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='main.net,san1.net,san2.net'"`
-	Storage               string                      `description:"File or key used for certificates storage."`
-	StorageFile           string                      // Deprecated
-	OnDemand              bool                        `description:"(Deprecated) Enable on demand certificate generation. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."` // Deprecated
-	OnHostRule            bool                        `description:"Enable certificate generation on frontends Host rules."`
-	CAServer              string                      `description:"CA server to use."`
-	EntryPoint            string                      `description:"Entrypoint to proxy acme challenge to."`
-	KeyType               string                      `description:"KeyType used for generating certificate private key. Allow value 'EC256', 'EC384', 'RSA2048', 'RSA4096', 'RSA8192'. Default to 'RSA4096'"`
-	DNSChallenge          *acmeprovider.DNSChallenge  `description:"Activate DNS-01 Challenge"`
-	HTTPChallenge         *acmeprovider.HTTPChallenge `description:"Activate HTTP-01 Challenge"`
-	TLSChallenge          *acmeprovider.TLSChallenge  `description:"Activate TLS-ALPN-01 Challenge"`
-	DNSProvider           string                      `description:"(Deprecated) Activate DNS-01 Challenge"`                                                                    // Deprecated
-	DelayDontCheckDNS     flaeg.Duration              `description:"(Deprecated) Assume DNS propagates after a delay in seconds rather than finding and querying nameservers."` // Deprecated
-	ACMELogging           bool                        `description:"Enable debug logging of ACME actions."`
-	OverrideCertificates  bool                        `description:"Enable to override certificates in key-value store when using storeconfig"`
-	client                *acme.Client
-	store                 cluster.Store
-	challengeHTTPProvider *challengeHTTPProvider
-	challengeTLSProvider  *challengeTLSProvider
-	checkOnDemandDomain   func(domain string) bool
-	jobs                  *channels.InfiniteChannel
-	TLSConfig             *tls.Config `description:"TLS config in case wildcard certs are used"`
-	dynamicCerts          *safe.Safe
-	resolvingDomains      map[string]struct{}
-	resolvingDomainsMutex sync.RWMutex
+	Email                      string                          `description:"Email address used for registration"`
+	Domains                    []types.Domain                  `description:"SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='main.net,san1.net,san2.net'"`
+	Storage                    string                          `description:"File or key used for certificates storage."`
+	StorageEncryption          *acmeprovider.StorageEncryption `description:"Encrypt the ACME storage at rest with AES-256-GCM"`
+	StorageSigning             *acmeprovider.StorageSigning    `description:"Sign the ACME storage with an Ed25519 key, and refuse to load a storage with a missing or an invalid signature"`
+	StorageReadOnlyFallback    bool                            `description:"Serve the certificates of a storage which can not be written in read-only mode, without saving the changes, instead of failing"`
+	ReadOnly                   bool                            `description:"Serve the stored certificates without writing the storage: no account is registered and no certificate is obtained nor renewed"`
+	AuditLog                   string                          `description:"File receiving the audit entries of the ACME storage mutations, in JSON. Default to the Traefik log"`
+	CertificateSecrets         *acmeprovider.TLSSecrets        `description:"Keep the certificates in per-domain kubernetes.io/tls Secrets, the storage holding the account only"`
+	KubernetesEvents           *acmeprovider.KubernetesEvents  `description:"Emit Kubernetes Events for the certificates issuance and renewal outcomes"`
+	StorageUnhealthyThreshold  parse.Duration                  `description:"Fail the ping health check when the storage is unhealthy for longer than this duration. Disabled when empty"`
+	ExpiryAlerts               *acmeprovider.ExpiryAlerts      `description:"Notify the stored certificates close to their expiry which are not renewed"`
+	DeployHooks                []acmeprovider.DeployHook       `description:"Commands to run once a certificate is issued or renewed and persisted"`
+	StorageDrift               *acmeprovider.StorageDrift      `description:"Periodically compare the storage with the data in memory, and reconcile their differences"`
+	StorageSaveQuietPeriod     parse.Duration                  `description:"Coalesce the saves of the storage until none happens for this duration, storageMaxSaveDelay at most. Default to 500ms, only the pending saves are coalesced when negative"`
+	StorageMaxSaveDelay        parse.Duration                  `description:"Write the coalesced saves of the storage at most this duration after the first one, up to 1m. Default to 5s"`
+	StoragePollInterval        parse.Duration                  `description:"Reload the storage when it is changed by others, checking it at this interval with a jitter of 10%. Disabled when empty"`
+	StorageCacheTTL            parse.Duration                  `description:"Cache the account and the certificates read from the storage for this duration. Disabled when empty"`
+	StorageRevisions           int                             `description:"Keep this number of revisions of the storage, written when its account or its certificates change, to roll back to. Disabled when empty"`
+	StorageMissing             string                          `description:"Behavior when the storage does not exist at start: createEmpty to start with an empty storage, waitFor to wait until it is created, or fail. Default to createEmpty"`
+	StorageLoadTimeout         parse.Duration                  `description:"Retry the first load of the storage with an exponential backoff and a jitter for this duration at most. Disabled when empty"`
+	StorageCircuitBreaker      *acmeprovider.StorageBreaker    `description:"Suspend the writes of the storage after consecutive failures, keeping the latest data in memory until a probe writes it"`
+	StorageLocalCache          string                          `description:"Mirror the account and the certificates to this local file after each save, to serve them in read-only mode when the storage can not be loaded at start. Disabled when empty"`
+	StorageConsistencyInterval parse.Duration                  `description:"Check at this interval that the storage holds the data in memory, writing it again when it does not. Disabled when empty"`
+	StorageFailMode            string                          `description:"Behavior when an obtained certificate can not be persisted: serveStale to serve it anyway, or strict to serve it once persisted only, retrying its issuance once the storage recovers. Default to serveStale"`
+	StorageCanary              *acmeprovider.StorageCanary     `description:"Check on start that the storage can be written, read back and decrypted with a canary entry, deleted once checked"`
+	StorageFile                string                          // Deprecated
+	OnDemand                   bool                            `description:"(Deprecated) Enable on demand certificate generation. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."` // Deprecated
+	OnDemandQueueTTL           parse.Duration                  `description:"Duration after which a queued on demand domain which has not been requested again is dropped. Default to 24h"`
+	OnHostRule                 bool                            `description:"Enable certificate generation on frontends Host rules."`
+	CAServer                   string                          `description:"CA server to use."`
+	CACertificates             []string                        `description:"Files of PEM encoded CA certificates trusted for the HTTPS calls to the CA server, in addition to the system ones"`
+	CACertificatesSecretRef    *acmeprovider.SecretRef         `description:"Kubernetes Secret holding PEM encoded CA certificates trusted for the HTTPS calls to the CA server, in addition to the system ones"`
+	TrustedRoots               []string                        `description:"Files of PEM encoded root certificates the issued certificates must chain to, instead of the system ones"`
+	AllowUnverifiedChains      bool                            `description:"Store and serve the issued certificates whose chain can not be verified, as the ones of private CAs"`
+	ValidateWithStaging        bool                            `description:"Validate the challenges of the domains without certificate against the Let's Encrypt staging CA before placing the production order"`
+	EntryPoint                 string                          `description:"Entrypoint to proxy acme challenge to."`
+	KeyType                    string                          `description:"KeyType used for generating certificate private key. Allow value 'EC256', 'EC384', 'RSA2048', 'RSA4096', 'RSA8192'. Default to 'RSA4096'"`
+	AccountKeyType             string                          `description:"KeyType used for generating the account private key. Allow value 'EC256', 'EC384', 'RSA2048', 'RSA4096'. Default to 'RSA4096'"`
+	DomainsKeyType             []acmeprovider.DomainKeyType    `description:"KeyType overrides used for generating the certificate private key of specific domains"`
+	DomainsChallenge           []acmeprovider.DomainChallenge  `description:"Challenge type overrides used for validating specific domains"`
+	RenewBefore                parse.Duration                  `description:"Renew the certificates when less than this duration is left before their expiry. Default to 720h (30 days)"`
+	DomainsRenewBefore         []acmeprovider.DomainRenewal    `description:"Renewal window overrides of the certificates of specific domains"`
+	StaggerRenewals            bool                            `description:"Spread the renewals of the certificates over the first half of their renewal window, with a random offset stored with each certificate"`
+	DomainsMustStaple          []string                        `description:"Domains (or wildcard domains) whose certificates are issued with the OCSP Must-Staple extension, and served with their OCSP response only"`
+	DomainsMetadata            []acmeprovider.DomainMetadata   `description:"Metadata of the certificates of specific domains"`
+	RenewalInfoRefreshInterval flaeg.Duration                  `description:"Interval between two refreshes of the ACME Renewal Information (ARI) of the certificates. Default to 6h"`
+	ChainRefreshInterval       flaeg.Duration                  `description:"Interval between two refreshes of the intermediate chains of the certificates from the CA. Default to 24h"`
+	AccountKeySecretRef        *acmeprovider.SecretRef         `description:"Kubernetes Secret holding the PEM encoded account private key to use instead of generating one"`
+	DNSChallenge               *acmeprovider.DNSChallenge      `description:"Activate DNS-01 Challenge"`
+	HTTPChallenge              *acmeprovider.HTTPChallenge     `description:"Activate HTTP-01 Challenge"`
+	TLSChallenge               *acmeprovider.TLSChallenge      `description:"Activate TLS-ALPN-01 Challenge"`
+	EphemeralChallenges        bool                            `description:"Keep the HTTP-01 and TLS-ALPN-01 challenges in memory only, without persisting them in the storage."`
+	EphemeralDefaultCert       bool                            `description:"Generate the default certificate of the entry points on each start, without persisting it in the storage."`
+	DNSProvider                string                          `description:"(Deprecated) Activate DNS-01 Challenge"`                                                                    // Deprecated
+	DelayDontCheckDNS          flaeg.Duration                  `description:"(Deprecated) Assume DNS propagates after a delay in seconds rather than finding and querying nameservers."` // Deprecated
+	ACMELogging                bool                            `description:"Enable debug logging of ACME actions."`
+	OverrideCertificates       bool                            `description:"Enable to override certificates in key-value store when using storeconfig"`
+	client                     *acme.Client
+	store                      cluster.Store
+	challengeHTTPProvider      *challengeHTTPProvider
+	challengeTLSProvider       *challengeTLSProvider
+	checkOnDemandDomain        func(domain string) bool
+	jobs                       *channels.InfiniteChannel
+	TLSConfig                  *tls.Config `description:"TLS config in case wildcard certs are used"`
+	dynamicCerts               *safe.Safe
+	resolvingDomains           map[string]struct{}
+	resolvingDomainsMutex      sync.RWMutex
 }
 
 func (a *ACME) init() error {
@@ -114,6 +160,20 @@ func (a *ACME) AddRoutes(router *mux.Router) {
 		}))
 }
 
+// GetClusterStorage returns the key of the ACME account and certificates in the KV store of the cluster mode, the prefix of their keys.
+// The installations sharing a KV store must use distinct keys.
+func (a *ACME) GetClusterStorage() (string, error) {
+	storage := a.Storage
+	if len(storage) == 0 {
+		storage = defaultClusterStorage
+	}
+
+	if err := cluster.CheckPrefix(storage); err != nil {
+		return "", fmt.Errorf("invalid ACME storage key: %v", err)
+	}
+	return storage, nil
+}
+
 // CreateClusterConfig creates a tls.config using ACME configuration in cluster mode
 func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tls.Config, certs *safe.Safe, checkOnDemandDomain func(domain string) bool) error {
 	err := a.init()
@@ -121,9 +181,11 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 		return err
 	}
 
-	if len(a.Storage) == 0 {
-		return errors.New("empty Store, please provide a key for certs storage")
+	storage, err := a.GetClusterStorage()
+	if err != nil {
+		return err
 	}
+	log.Infof("The ACME account and certificates are stored in the KV store under %s", storage)
 
 	a.checkOnDemandDomain = checkOnDemandDomain
 	a.dynamicCerts = certs
@@ -138,7 +200,7 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 		if !leadership.IsLeader() {
 			a.client, err = a.buildACMEClient(account)
 			if err != nil {
-				log.Errorf("Error building ACME client %+v: %s", object, err.Error())
+				log.Errorf("Error building ACME client for the account %s: %s", account.Email, err.Error())
 			}
 		}
 		return nil
@@ -148,7 +210,7 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 		leadership.Pool.Ctx(),
 		staert.KvSource{
 			Store:  leadership.Store,
-			Prefix: a.Storage,
+			Prefix: storage,
 		},
 		&Account{},
 		listener)
@@ -326,7 +388,7 @@ func (a *ACME) retrieveCertificates() {
 				}
 
 				if err = transaction.Commit(account); err != nil {
-					log.Errorf("Error Saving ACME account %+v: %s", account, err)
+					log.Errorf("Error Saving ACME account %s: %s", account.Email, err)
 					continue
 				}
 			}
@@ -402,7 +464,7 @@ func (a *ACME) storeRenewedCertificate(certificateResource *DomainsCertificate,
 
 	log.Infof("Commit certificate renewed in data store : %+v", certificateResource.Domains)
 	if err = transaction.Commit(account); err != nil {
-		return fmt.Errorf("error saving ACME account %+v: %v", account, err)
+		return fmt.Errorf("error saving ACME account %s: %v", account.Email, err)
 	}
 
 	oldAccount := a.store.Get().(*Account)
@@ -573,7 +635,7 @@ func (a *ACME) LoadCertificateForDomains(domains []string) {
 			return
 		}
 		if err = transaction.Commit(account); err != nil {
-			log.Errorf("Error Saving ACME account %+v: %v", account, err)
+			log.Errorf("Error Saving ACME account %s: %v", account.Email, err)
 			return
 		}
 	}
@@ -822,3 +822,16 @@ func TestRemoveEmptyCertificates(t *testing.T) {
 		})
 	}
 }
+
+func TestGetClusterStorage(t *testing.T) {
+	storage, err := (&ACME{}).GetClusterStorage()
+	assert.NoError(t, err)
+	assert.Equal(t, "traefik/acme/account", storage)
+
+	storage, err = (&ACME{Storage: "traefik/acme/staging"}).GetClusterStorage()
+	assert.NoError(t, err)
+	assert.Equal(t, "traefik/acme/staging", storage)
+
+	_, err = (&ACME{Storage: "traefik/acme/staging/"}).GetClusterStorage()
+	assert.EqualError(t, err, `invalid ACME storage key: the prefix "traefik/acme/staging/" of the keys in the KV store must not end with a slash`)
+}
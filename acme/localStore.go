@@ -1,6 +1,7 @@
 package acme
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -54,13 +55,13 @@ func (s *LocalStore) Get() (*Account, error) {
 func ConvertToNewFormat(fileName string) {
 	localStore := acme.NewLocalStore(fileName)
 
-	storeAccount, err := localStore.GetAccount()
+	storeAccount, err := localStore.GetAccount(context.Background())
 	if err != nil {
 		log.Errorf("Failed to read new account, ACME data conversion is not available : %v", err)
 		return
 	}
 
-	storeCertificates, err := localStore.GetCertificates()
+	storeCertificates, err := localStore.GetCertificates(context.Background())
 	if err != nil {
 		log.Errorf("Failed to read new certificates, ACME data conversion is not available : %v", err)
 		return
@@ -113,7 +114,7 @@ func ConvertToNewFormat(fileName string) {
 		// Store the data in new format into the file even if account is nil
 		// to delete Account in ACME v1 format and keeping the certificates
 		newLocalStore := acme.NewLocalStore(fileName)
-		newLocalStore.SaveDataChan <- &acme.StoredData{Account: newAccount, Certificates: storeCertificates}
+		newLocalStore.MigrateStoredData(&acme.StoredData{Account: newAccount, Certificates: storeCertificates})
 	}
 }
 
@@ -130,12 +131,12 @@ func backupACMEFile(originalFileName string, account interface{}) error {
 func FromNewToOldFormat(fileName string) (*Account, error) {
 	localStore := acme.NewLocalStore(fileName)
 
-	storeAccount, err := localStore.GetAccount()
+	storeAccount, err := localStore.GetAccount(context.Background())
 	if err != nil {
 		return nil, err
 	}
 
-	storeCertificates, err := localStore.GetCertificates()
+	storeCertificates, err := localStore.GetCertificates(context.Background())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,474 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/containous/mux"
+	"github.com/containous/traefik/log"
+	acmeprovider "github.com/containous/traefik/provider/acme"
+)
+
+// ACMEHandler expose ACME routes
+type ACMEHandler struct {
+	Provider *acmeprovider.Provider
+}
+
+// AddRoutes add ACME routes on a router
+func (h ACMEHandler) AddRoutes(router *mux.Router) {
+	router.Methods(http.MethodGet).Path("/api/acme").HandlerFunc(h.getModeHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/account").HandlerFunc(h.getAccountHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/storage").HandlerFunc(h.getStorageStatusHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/storage/status").HandlerFunc(h.getStorageStatusReportHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/storage/health").HandlerFunc(h.getStorageHealthHandler)
+	router.Methods(http.MethodPost).Path("/api/acme/storage/reload").HandlerFunc(h.reloadStorageHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/storage/revisions").HandlerFunc(h.getStorageRevisionsHandler)
+	router.Methods(http.MethodPost).Path("/api/acme/storage/revisions/{revision:[0-9]+}/rollback").HandlerFunc(h.rollbackStorageHandler)
+	router.Methods(http.MethodPost).Path("/api/acme/certificates").HandlerFunc(h.injectCertificateHandler)
+	router.Methods(http.MethodPost).Path("/api/acme/chains/refresh").HandlerFunc(h.refreshChainsHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/certificates/{domain}/chain").HandlerFunc(h.getCertificateChainHandler)
+	router.Methods(http.MethodPatch).Path("/api/acme/certificates/{domain}").HandlerFunc(h.patchCertificateHandler)
+	router.Methods(http.MethodPost).Path("/api/acme/certificates/{domain}/pause").HandlerFunc(h.pauseCertificateRenewalHandler)
+	router.Methods(http.MethodPost).Path("/api/acme/certificates/{domain}/resume").HandlerFunc(h.resumeCertificateRenewalHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/ondemand").HandlerFunc(h.getOnDemandQueueHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/orders").HandlerFunc(h.getOrderLimitsHandler)
+	h.AddDashboardRoutes(router)
+	router.Methods(http.MethodDelete).Path("/api/acme/challenges/{token}").HandlerFunc(h.deleteHTTPChallengeTokenHandler)
+	router.Methods(http.MethodDelete).Path("/api/acme/challenges/{type}/{domain}").HandlerFunc(h.deleteChallengeHandler)
+	router.Methods(http.MethodDelete).Path("/api/acme/challenges/{type}/{domain}/{token}").HandlerFunc(h.deleteChallengeHandler)
+}
+
+// AddDashboardRoutes add the read-only ACME routes used by the dashboard on a router
+func (h ACMEHandler) AddDashboardRoutes(router *mux.Router) {
+	router.Methods(http.MethodGet).Path("/api/acme/certificates").HandlerFunc(h.getCertificatesHandler)
+	router.Methods(http.MethodGet).Path("/api/acme/challenges").HandlerFunc(h.getChallengesHandler)
+}
+
+func (h ACMEHandler) getModeHandler(response http.ResponseWriter, request *http.Request) {
+	err := templatesRenderer.JSON(response, http.StatusOK, map[string]string{"mode": h.Provider.GetMode()})
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getAccountHandler(response http.ResponseWriter, request *http.Request) {
+	account, err := h.Provider.GetAccountInfo(request.Context())
+	switch {
+	case err == acmeprovider.ErrNoAccount:
+		http.Error(response, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		log.Errorf("Unable to get the ACME account: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, account)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getStorageStatusHandler(response http.ResponseWriter, request *http.Request) {
+	status, err := h.Provider.GetStorageStatus(request.Context())
+	if err != nil {
+		log.Errorf("Unable to get the ACME storage status: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if status == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, status)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getStorageStatusReportHandler(response http.ResponseWriter, request *http.Request) {
+	report, err := h.Provider.GetStorageStatusReport(request.Context())
+	if err != nil {
+		log.Errorf("Unable to get the ACME storage status: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if report == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, report)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getStorageHealthHandler(response http.ResponseWriter, request *http.Request) {
+	health := h.Provider.GetStorageHealth(request.Context())
+	if health == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err := templatesRenderer.JSON(response, http.StatusOK, health)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) reloadStorageHandler(response http.ResponseWriter, request *http.Request) {
+	reload, err := h.Provider.ReloadStorage()
+	if loadErr, ok := err.(*acmeprovider.StorageLoadError); ok {
+		http.Error(response, loadErr.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Errorf("Unable to reload the ACME storage: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if reload == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, reload)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getStorageRevisionsHandler(response http.ResponseWriter, request *http.Request) {
+	revisions, err := h.Provider.GetStorageRevisions()
+	if err != nil {
+		log.Errorf("Unable to get the ACME storage revisions: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if revisions == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, revisions)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) rollbackStorageHandler(response http.ResponseWriter, request *http.Request) {
+	revision, err := strconv.Atoi(mux.Vars(request)["revision"])
+	if err != nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	rolledBack, err := h.Provider.RollbackStorage(revision)
+	switch {
+	case err == acmeprovider.ErrReadOnly:
+		http.Error(response, err.Error(), http.StatusConflict)
+		return
+	case err == acmeprovider.ErrUnknownRevision:
+		http.NotFound(response, request)
+		return
+	case err != nil:
+		log.Errorf("Unable to roll back the ACME storage to the revision %d: %v", revision, err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if !rolledBack {
+		http.NotFound(response, request)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func (h ACMEHandler) getCertificatesHandler(response http.ResponseWriter, request *http.Request) {
+	certificates, err := h.Provider.GetCertificatesInfo(request.Context())
+	if err != nil {
+		log.Errorf("Unable to get the ACME certificates: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if certificates == nil {
+		certificates = []*acmeprovider.CertificateInfo{}
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, certificates)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getCertificateChainHandler(response http.ResponseWriter, request *http.Request) {
+	domain := mux.Vars(request)["domain"]
+
+	chain, err := h.Provider.GetCertificateChain(domain)
+	if err != nil {
+		log.Errorf("Unable to get the chain of the ACME certificate for %s: %v", domain, err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if chain == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/x-pem-file")
+	if _, err = response.Write(chain); err != nil {
+		log.Error(err)
+	}
+}
+
+// certificatePatch is the body of a certificate patch, a metadata key without value is removed
+type certificatePatch struct {
+	Metadata map[string]*string `json:"metadata"`
+}
+
+func (h ACMEHandler) patchCertificateHandler(response http.ResponseWriter, request *http.Request) {
+	domain := mux.Vars(request)["domain"]
+
+	patch := &certificatePatch{}
+	if err := json.NewDecoder(request.Body).Decode(patch); err != nil {
+		http.Error(response, fmt.Sprintf("invalid certificate patch: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	certificate, err := h.Provider.PatchCertificateMetadata(request.Context(), domain, patch.Metadata)
+	if metadataErr, ok := err.(*acmeprovider.InvalidMetadataError); ok {
+		http.Error(response, metadataErr.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == acmeprovider.ErrReadOnly:
+		http.Error(response, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Errorf("Unable to patch the metadata of the ACME certificate for %s: %v", domain, err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if certificate == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, certificate)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// certificateInjection is the body of the injection of an external certificate, PEM encoded
+type certificateInjection struct {
+	Certificate string `json:"certificate"`
+	Chain       string `json:"chain"`
+	Key         string `json:"key"`
+}
+
+func (h ACMEHandler) injectCertificateHandler(response http.ResponseWriter, request *http.Request) {
+	force := false
+	if value := request.URL.Query().Get("force"); len(value) > 0 {
+		var err error
+		force, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(response, fmt.Sprintf("invalid force parameter %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	injection := &certificateInjection{}
+	if err := json.NewDecoder(request.Body).Decode(injection); err != nil {
+		http.Error(response, fmt.Sprintf("invalid certificate injection: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	certificate, err := h.Provider.InjectCertificate(request.Context(), []byte(injection.Certificate), []byte(injection.Chain), []byte(injection.Key), force)
+	if certificateErr, ok := err.(*acmeprovider.InvalidCertificateError); ok {
+		http.Error(response, certificateErr.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case err == acmeprovider.ErrReadOnly, err == acmeprovider.ErrCertificateExists:
+		http.Error(response, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Errorf("Unable to inject the external certificate: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, certificate)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) pauseCertificateRenewalHandler(response http.ResponseWriter, request *http.Request) {
+	h.setCertificateRenewalPaused(response, request, true)
+}
+
+func (h ACMEHandler) resumeCertificateRenewalHandler(response http.ResponseWriter, request *http.Request) {
+	h.setCertificateRenewalPaused(response, request, false)
+}
+
+func (h ACMEHandler) setCertificateRenewalPaused(response http.ResponseWriter, request *http.Request, paused bool) {
+	domain := mux.Vars(request)["domain"]
+
+	certificate, err := h.Provider.SetRenewalPaused(request.Context(), domain, paused)
+	switch {
+	case err == acmeprovider.ErrReadOnly:
+		http.Error(response, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Errorf("Unable to set the renewal of the ACME certificate for %s paused to %t: %v", domain, paused, err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if certificate == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, certificate)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getOnDemandQueueHandler(response http.ResponseWriter, request *http.Request) {
+	queue, err := h.Provider.GetOnDemandQueue(request.Context())
+	if err != nil {
+		log.Errorf("Unable to get the ACME on demand queue: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if queue == nil {
+		queue = []*acmeprovider.QueuedDomain{}
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, queue)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) refreshChainsHandler(response http.ResponseWriter, request *http.Request) {
+	refresh, err := h.Provider.RefreshChains(request.Context())
+	switch {
+	case err == acmeprovider.ErrReadOnly || err == acmeprovider.ErrChainRefreshRunning:
+		http.Error(response, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Errorf("Unable to refresh the intermediate chains of the ACME certificates: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, refresh)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getOrderLimitsHandler(response http.ResponseWriter, request *http.Request) {
+	limits, err := h.Provider.GetOrderLimits(request.Context())
+	if err != nil {
+		log.Errorf("Unable to get the ACME order limits: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if limits == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, limits)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) getChallengesHandler(response http.ResponseWriter, request *http.Request) {
+	challenges, err := h.Provider.GetPendingChallenges(request.Context())
+	if err != nil {
+		log.Errorf("Unable to get the pending ACME challenges: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if challenges == nil {
+		challenges = []*acmeprovider.PendingChallenge{}
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, challenges)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) deleteChallengeHandler(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	deleted, err := h.Provider.DeletePendingChallenge(request.Context(), vars["type"], vars["token"], vars["domain"])
+	if err == acmeprovider.ErrReadOnly {
+		http.Error(response, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Errorf("Unable to delete the pending ACME challenge: %v", err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if deleted == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, deleted)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (h ACMEHandler) deleteHTTPChallengeTokenHandler(response http.ResponseWriter, request *http.Request) {
+	token := mux.Vars(request)["token"]
+
+	deleted, err := h.Provider.DeleteHTTPChallengeToken(request.Context(), token, request.URL.Query().Get("domain"))
+	if err == acmeprovider.ErrReadOnly {
+		http.Error(response, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Errorf("Unable to delete the pending ACME challenges of the token %s: %v", token, err)
+		http.Error(response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(deleted) == 0 {
+		http.NotFound(response, request)
+		return
+	}
+
+	err = templatesRenderer.JSON(response, http.StatusOK, deleted)
+	if err != nil {
+		log.Error(err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/containous/mux"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/middlewares"
+	acmeprovider "github.com/containous/traefik/provider/acme"
 	"github.com/containous/traefik/safe"
 	"github.com/containous/traefik/types"
 	"github.com/containous/traefik/version"
@@ -24,6 +25,7 @@ type Handler struct {
 	Stats                 *thoas_stats.Stats         `json:"-"`
 	StatsRecorder         *middlewares.StatsRecorder `json:"-"`
 	DashboardAssets       *assetfs.AssetFS
+	ACMEProvider          *acmeprovider.Provider `json:"-"`
 }
 
 var (
@@ -36,6 +38,13 @@ var (
 func (p Handler) AddRoutes(router *mux.Router) {
 	if p.Debug {
 		DebugHandler{}.AddRoutes(router)
+
+		if p.ACMEProvider != nil {
+			ACMEHandler{Provider: p.ACMEProvider}.AddRoutes(router)
+		}
+	} else if p.Dashboard && p.ACMEProvider != nil {
+		// The dashboard lists the ACME certificates and their pending challenges
+		ACMEHandler{Provider: p.ACMEProvider}.AddDashboardRoutes(router)
 	}
 
 	router.Methods(http.MethodGet).Path("/api").HandlerFunc(p.getConfigHandler)
@@ -234,10 +243,11 @@ func (p Handler) getRouteHandler(response http.ResponseWriter, request *http.Req
 }
 
 // healthResponse combines data returned by thoas/stats with statistics (if
-// they are enabled).
+// they are enabled), and the health of the ACME storage (if ACME is enabled).
 type healthResponse struct {
 	*thoas_stats.Data
 	*middlewares.Stats
+	ACMEStorage *acmeprovider.StoreHealth `json:"acme_storage,omitempty"`
 }
 
 func (p *Handler) getHealthHandler(response http.ResponseWriter, request *http.Request) {
@@ -245,6 +255,9 @@ func (p *Handler) getHealthHandler(response http.ResponseWriter, request *http.R
 	if p.StatsRecorder != nil {
 		health.Stats = p.StatsRecorder.Data()
 	}
+	if p.ACMEProvider != nil {
+		health.ACMEStorage = p.ACMEProvider.GetStorageHealth(request.Context())
+	}
 	err := templatesRenderer.JSON(response, http.StatusOK, health)
 	if err != nil {
 		log.Error(err)
@@ -57,8 +57,13 @@ type Datastore struct {
 	listener  Listener
 }
 
-// NewDataStore creates a Datastore
+// NewDataStore creates a Datastore whose keys are under the prefix of the KV source, its listings never read the keys of another prefix
 func NewDataStore(ctx context.Context, kvSource staert.KvSource, object Object, listener Listener) (*Datastore, error) {
+	if err := CheckPrefix(kvSource.Prefix); err != nil {
+		return nil, err
+	}
+	kvSource.Store = BoundStore(kvSource.Store)
+
 	datastore := Datastore{
 		kv:        kvSource,
 		ctx:       ctx,
@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abronan/valkeyrie/store"
+)
+
+// CheckPrefix checks the prefix of the keys of a datastore, which namespaces it in a KV store shared by several installations
+func CheckPrefix(prefix string) error {
+	if len(strings.Trim(prefix, "/")) == 0 {
+		return errors.New("empty prefix, please provide the prefix of the keys in the KV store")
+	}
+
+	if strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("the prefix %q of the keys in the KV store must not end with a slash", prefix)
+	}
+	return nil
+}
+
+// BoundStore bounds the listings of the KV store to the keys under the listed directory.
+// Some KV stores, like Consul, list every key starting with the directory: the keys of traefik/acme/account-staging
+// are listed with the ones of traefik/acme/account, and one installation would read the keys of another.
+func BoundStore(kvStore store.Store) store.Store {
+	if _, ok := kvStore.(*boundedStore); ok {
+		return kvStore
+	}
+	return &boundedStore{Store: kvStore}
+}
+
+type boundedStore struct {
+	store.Store
+}
+
+// List lists the keys under the directory, ErrKeyNotFound when there is none, as the KV stores do
+func (s *boundedStore) List(directory string, options *store.ReadOptions) ([]*store.KVPair, error) {
+	pairs, err := s.Store.List(directory, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var bounded []*store.KVPair
+	for _, pair := range pairs {
+		if isKeyUnder(pair.Key, directory) {
+			bounded = append(bounded, pair)
+		}
+	}
+
+	if len(bounded) == 0 {
+		return nil, store.ErrKeyNotFound
+	}
+	return bounded, nil
+}
+
+func isKeyUnder(key, directory string) bool {
+	key = strings.Trim(key, "/")
+	directory = strings.Trim(directory, "/")
+	return key == directory || strings.HasPrefix(key, directory+"/")
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/abronan/valkeyrie/store"
+	"github.com/containous/staert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixListStore lists the keys starting with the directory, as Consul does
+type prefixListStore struct {
+	store.Store
+	pairs []*store.KVPair
+}
+
+func (s *prefixListStore) List(directory string, options *store.ReadOptions) ([]*store.KVPair, error) {
+	var pairs []*store.KVPair
+	for _, pair := range s.pairs {
+		if strings.HasPrefix(pair.Key, directory) {
+			pairs = append(pairs, pair)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, store.ErrKeyNotFound
+	}
+	return pairs, nil
+}
+
+func TestCheckPrefix(t *testing.T) {
+	testCases := []struct {
+		prefix   string
+		expected string
+	}{
+		{prefix: "traefik/acme/account"},
+		{prefix: "", expected: "empty prefix, please provide the prefix of the keys in the KV store"},
+		{prefix: "/", expected: "empty prefix, please provide the prefix of the keys in the KV store"},
+		{prefix: "traefik/acme/account/", expected: `the prefix "traefik/acme/account/" of the keys in the KV store must not end with a slash`},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.prefix, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckPrefix(test.prefix)
+			if len(test.expected) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expected)
+			}
+		})
+	}
+}
+
+func TestBoundStoreList(t *testing.T) {
+	kvStore := BoundStore(&prefixListStore{pairs: []*store.KVPair{
+		{Key: "traefik/acme/account/Object", Value: []byte("production")},
+		{Key: "traefik/acme/account-staging/Object", Value: []byte("staging")},
+	}})
+
+	pairs, err := kvStore.List("traefik/acme/account", nil)
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+	assert.Equal(t, "traefik/acme/account/Object", pairs[0].Key)
+
+	_, err = kvStore.List("traefik/acme/acc", nil)
+	assert.Equal(t, store.ErrKeyNotFound, err)
+
+	assert.Equal(t, kvStore, BoundStore(kvStore))
+}
+
+func TestNewDataStoreInvalidPrefix(t *testing.T) {
+	_, err := NewDataStore(context.Background(), staert.KvSource{Store: &prefixListStore{}, Prefix: "traefik/acme/"}, &struct{}{}, nil)
+	assert.EqualError(t, err, `the prefix "traefik/acme/" of the keys in the KV store must not end with a slash`)
+}
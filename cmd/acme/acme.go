@@ -0,0 +1,94 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/containous/flaeg"
+	acmeprovider "github.com/containous/traefik/provider/acme"
+)
+
+// Configuration holds the configuration of the acme command
+type Configuration struct {
+	Storage  string `description:"Storage file of the ACME provider"`
+	Revision int    `description:"Revision of the storage to roll back to"`
+	Within   string `description:"Window of the report, the certificates expiring within it are reported: a number of days such as 45d, or a duration such as 72h. Default to 30d"`
+	JSON     bool   `description:"Print the report in JSON"`
+	API      string `description:"URL of the API of the running Traefik, such as http://localhost:8080, to report the renewal failures and whether the domains are still referenced, or to refresh the intermediate chains"`
+}
+
+// NewCmd builds a new ACME command, managing the revisions of the ACME storage file.
+// The args are the arguments of traefik, the action being the one following the command name.
+func NewCmd(args []string) *flaeg.Command {
+	config := &Configuration{Storage: "acme.json"}
+
+	return &flaeg.Command{
+		Name: "acme",
+		Description: `Manage the revisions of the ACME storage file, and report its certificates:
+	traefik acme revisions --storage=acme.json: list the revisions of the storage
+	traefik acme rollback --storage=acme.json --revision=n: restore a revision of the storage
+	traefik acme report --storage=acme.json --within=45d [--json] [--api=http://localhost:8080]: report the certificates expiring within the window
+	traefik acme refresh-chains --api=http://localhost:8080: refresh the intermediate chains of the certificates of the running Traefik`,
+		Config:                config,
+		DefaultPointersConfig: &Configuration{},
+		Run:                   runCmd(config, getAction(args)),
+	}
+}
+
+// getAction returns the argument following the command name, when it is not a flag
+func getAction(args []string) string {
+	if len(args) < 2 || len(args[1]) == 0 || args[1][0] == '-' {
+		return ""
+	}
+	return args[1]
+}
+
+func runCmd(config *Configuration, action string) func() error {
+	return func() error {
+		switch action {
+		case "revisions":
+			return printRevisions(config.Storage)
+		case "rollback":
+			return rollback(config.Storage, config.Revision)
+		case "report":
+			return report(config, os.Stdout)
+		case "refresh-chains":
+			return refreshChains(&http.Client{Timeout: chainRefreshTimeout}, config.API, os.Stdout)
+		default:
+			return fmt.Errorf("unknown action %q of the acme command, expected revisions, rollback, report or refresh-chains", action)
+		}
+	}
+}
+
+func printRevisions(storage string) error {
+	revisions, err := acmeprovider.ReadStorageRevisions(storage)
+	if err != nil {
+		return err
+	}
+
+	if len(revisions) == 0 {
+		fmt.Printf("No revision of the ACME storage %s\n", storage)
+		return nil
+	}
+
+	for _, revision := range revisions {
+		fmt.Printf("%d\t%s\n", revision.Revision, revision.CreatedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func rollback(storage string, revision int) error {
+	if revision <= 0 {
+		return errors.New("the revision to roll back to is missing, set it with --revision")
+	}
+
+	if err := acmeprovider.RestoreStorageRevision(storage, revision); err != nil {
+		return fmt.Errorf("unable to roll back the ACME storage %s to the revision %d: %v", storage, revision, err)
+	}
+
+	fmt.Printf("The revision %d of the ACME storage %s is restored, it is loaded by Traefik on its next reload of the storage\n", revision, storage)
+	return nil
+}
@@ -0,0 +1,59 @@
+package acme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	acmeprovider "github.com/containous/traefik/provider/acme"
+)
+
+// chainRefreshTimeout bounds the refresh, the chains being downloaded one per second by Traefik
+const chainRefreshTimeout = 10 * time.Minute
+
+// refreshChains triggers the refresh of the intermediate chains with the API of the running Traefik, and prints its summary
+func refreshChains(client *http.Client, api string, out io.Writer) error {
+	if len(api) == 0 {
+		return errors.New("the API of the running Traefik is missing, set it with --api")
+	}
+
+	resp, err := client.Post(strings.TrimSuffix(api, "/")+"/api/acme/chains/refresh", "application/json", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("the refresh of the intermediate chains answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var refresh acmeprovider.ChainRefresh
+	if err = json.NewDecoder(resp.Body).Decode(&refresh); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%d certificates checked, %d chains updated\n", refresh.Checked, len(refresh.Updated))
+	for _, domain := range refresh.Updated {
+		fmt.Fprintf(out, "updated\t%s\n", domain)
+	}
+	var failed []string
+	for domain := range refresh.Failed {
+		failed = append(failed, domain)
+	}
+	sort.Strings(failed)
+	for _, domain := range failed {
+		fmt.Fprintf(out, "failed\t%s\t%s\n", domain, refresh.Failed[domain])
+	}
+
+	if len(refresh.Failed) > 0 {
+		return fmt.Errorf("the intermediate chains of %d certificates can not be refreshed", len(refresh.Failed))
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package acme
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	acmeprovider "github.com/containous/traefik/provider/acme"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshChains(t *testing.T) {
+	refresh := acmeprovider.ChainRefresh{Checked: 3, Updated: []string{"traefik.wtf"}}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/api/acme/chains/refresh" {
+			http.NotFound(rw, req)
+			return
+		}
+		require.NoError(t, json.NewEncoder(rw).Encode(refresh))
+	}))
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	require.NoError(t, refreshChains(server.Client(), server.URL+"/", out))
+	assert.Equal(t, "3 certificates checked, 1 chains updated\nupdated\ttraefik.wtf\n", out.String())
+
+	// The failures of the refresh fail the command
+	refresh.Failed = map[string]string{"other.wtf": "the CA returned another certificate"}
+	out.Reset()
+	assert.Error(t, refreshChains(server.Client(), server.URL, out))
+	assert.Contains(t, out.String(), "failed\tother.wtf\tthe CA returned another certificate\n")
+
+	assert.Error(t, refreshChains(server.Client(), "", out))
+}
@@ -0,0 +1,192 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	acmeprovider "github.com/containous/traefik/provider/acme"
+	"github.com/containous/traefik/rules"
+	"github.com/containous/traefik/types"
+)
+
+const (
+	defaultReportWindow = "30d"
+	apiRequestTimeout   = 10 * time.Second
+)
+
+// report prints the stored certificates expiring within the window.
+// It fails when the renewal of one of them is paused or failing, for the report to gate a change freeze.
+func report(config *Configuration, out io.Writer) error {
+	window := config.Within
+	if len(window) == 0 {
+		window = defaultReportWindow
+	}
+	within, err := parseWindow(window)
+	if err != nil {
+		return err
+	}
+
+	certificates, err := acmeprovider.ReadStoredCertificates(config.Storage)
+	if err != nil {
+		return fmt.Errorf("unable to read the certificates of the ACME storage %s: %v", config.Storage, err)
+	}
+	expiring := acmeprovider.GetExpiringCertificates(certificates, time.Now(), within)
+
+	// The renewal failures and the configuration are only known by the running Traefik
+	if len(config.API) > 0 {
+		if err := setRuntimeState(&http.Client{Timeout: apiRequestTimeout}, config.API, expiring); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to query the Traefik API %s, the renewal failures and the references of the domains are unknown: %v\n", config.API, err)
+		}
+	}
+
+	if config.JSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(expiring); err != nil {
+			return err
+		}
+	} else {
+		printReport(out, expiring, window)
+	}
+
+	blocked := 0
+	for _, certificate := range expiring {
+		if certificate.IsRenewalBlocked() {
+			blocked++
+		}
+	}
+	if blocked > 0 {
+		return fmt.Errorf("the renewal of %d certificates expiring within %s is paused or failing", blocked, window)
+	}
+	return nil
+}
+
+func printReport(out io.Writer, expiring []*acmeprovider.ExpiringCertificate, window string) {
+	if len(expiring) == 0 {
+		fmt.Fprintf(out, "No certificate expires within %s\n", window)
+		return
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "DOMAINS\tRESOLVER\tEXPIRES\tRENEWAL\tRENEWAL WINDOW\tREFERENCED\tLAST ERROR")
+	for _, certificate := range expiring {
+		referenced := "unknown"
+		if certificate.Referenced != nil {
+			referenced = strconv.FormatBool(*certificate.Referenced)
+		}
+
+		domains := append([]string{certificate.Domain}, certificate.SANs...)
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			strings.Join(domains, ","),
+			certificate.Resolver,
+			certificate.NotAfter.Format(time.RFC3339),
+			certificate.RenewalState,
+			certificate.RenewalTime.Format(time.RFC3339),
+			referenced,
+			certificate.LastError)
+	}
+	writer.Flush()
+}
+
+// parseWindow parses the window of the report, a duration or a number of days such as 45d
+func parseWindow(window string) (time.Duration, error) {
+	if strings.HasSuffix(window, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(window, "d"))
+		if err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	} else if duration, err := time.ParseDuration(window); err == nil && duration > 0 {
+		return duration, nil
+	}
+
+	return 0, fmt.Errorf("invalid window %q of the report, expected a number of days such as 45d, or a duration such as 72h", window)
+}
+
+// setRuntimeState sets the renewal failures of the certificates, and whether their domains are referenced,
+// from the API of the running Traefik
+func setRuntimeState(client *http.Client, api string, expiring []*acmeprovider.ExpiringCertificate) error {
+	var certificates []*acmeprovider.CertificateInfo
+	if err := getAPIResource(client, api, "/api/acme/certificates", &certificates); err != nil {
+		return err
+	}
+
+	var configurations types.Configurations
+	if err := getAPIResource(client, api, "/api/providers", &configurations); err != nil {
+		return err
+	}
+
+	lastErrors := make(map[string]string)
+	for _, certificate := range certificates {
+		if certificate.CertificateTransparency != nil && certificate.LastRenewal != nil {
+			lastErrors[strings.ToLower(certificate.Domain)] = certificate.LastRenewal.Error
+		}
+	}
+
+	hosts := getConfigurationHosts(configurations)
+	for _, certificate := range expiring {
+		if lastError := lastErrors[strings.ToLower(certificate.Domain)]; len(lastError) > 0 {
+			certificate.LastError = lastError
+			if certificate.RenewalState != acmeprovider.RenewalStatePaused {
+				certificate.RenewalState = acmeprovider.RenewalStateFailing
+			}
+		}
+
+		referenced := isReferenced(append([]string{certificate.Domain}, certificate.SANs...), hosts)
+		certificate.Referenced = &referenced
+	}
+	return nil
+}
+
+func getAPIResource(client *http.Client, api, path string, resource interface{}) error {
+	resp, err := client.Get(strings.TrimSuffix(api, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(resource)
+}
+
+// getConfigurationHosts returns the domains of the Host rules of the frontends of the configurations
+func getConfigurationHosts(configurations types.Configurations) []string {
+	var hosts []string
+	for _, configuration := range configurations {
+		if configuration == nil {
+			continue
+		}
+
+		for _, frontend := range configuration.Frontends {
+			for _, route := range frontend.Routes {
+				domainRules := rules.Rules{}
+				domains, err := domainRules.ParseDomains(route.Rule)
+				if err != nil {
+					continue
+				}
+				hosts = append(hosts, domains...)
+			}
+		}
+	}
+	return hosts
+}
+
+// isReferenced returns whether a host is served by one of the domains of a certificate, wildcard domains included
+func isReferenced(domains []string, hosts []string) bool {
+	for _, host := range hosts {
+		for _, domain := range domains {
+			if types.MatchDomain(types.CanonicalDomain(host), types.CanonicalDomain(domain)) {
+				return true
+			}
+		}
+	}
+	return false
+}
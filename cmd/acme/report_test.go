@@ -0,0 +1,101 @@
+package acme
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	acmeprovider "github.com/containous/traefik/provider/acme"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	testCases := []struct {
+		window   string
+		expected time.Duration
+		invalid  bool
+	}{
+		{window: "45d", expected: 45 * 24 * time.Hour},
+		{window: "72h", expected: 72 * time.Hour},
+		{window: "0d", invalid: true},
+		{window: "-1h", invalid: true},
+		{window: "d", invalid: true},
+		{window: "45days", invalid: true},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.window, func(t *testing.T) {
+			t.Parallel()
+
+			within, err := parseWindow(test.window)
+			if test.invalid {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, within)
+			}
+		})
+	}
+}
+
+func TestSetRuntimeState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var resource interface{}
+		switch req.URL.Path {
+		case "/api/acme/certificates":
+			resource = []*acmeprovider.CertificateInfo{
+				{
+					CertificateTransparency: &acmeprovider.CertificateTransparency{Domain: "failing.wtf"},
+					LastRenewal:             &acmeprovider.RenewalResult{Error: "rate limited"},
+				},
+				{
+					CertificateTransparency: &acmeprovider.CertificateTransparency{Domain: "paused.wtf"},
+					LastRenewal:             &acmeprovider.RenewalResult{Error: "rate limited"},
+				},
+				{CertificateTransparency: &acmeprovider.CertificateTransparency{Domain: "scheduled.wtf"}},
+			}
+		case "/api/providers":
+			resource = types.Configurations{
+				"file": &types.Configuration{
+					Frontends: map[string]*types.Frontend{
+						"frontend": {Routes: map[string]types.Route{"route": {Rule: "Host:www.failing.wtf,paused.wtf"}}},
+					},
+				},
+			}
+		default:
+			http.NotFound(rw, req)
+			return
+		}
+		require.NoError(t, json.NewEncoder(rw).Encode(resource))
+	}))
+	defer server.Close()
+
+	expiring := []*acmeprovider.ExpiringCertificate{
+		{Domain: "failing.wtf", SANs: []string{"*.failing.wtf"}, RenewalState: acmeprovider.RenewalStateDue},
+		{Domain: "paused.wtf", RenewalState: acmeprovider.RenewalStatePaused},
+		{Domain: "scheduled.wtf", RenewalState: acmeprovider.RenewalStateScheduled},
+	}
+
+	err := setRuntimeState(server.Client(), server.URL+"/", expiring)
+	require.NoError(t, err)
+
+	assert.Equal(t, acmeprovider.RenewalStateFailing, expiring[0].RenewalState)
+	assert.Equal(t, "rate limited", expiring[0].LastError)
+	require.NotNil(t, expiring[0].Referenced)
+	assert.True(t, *expiring[0].Referenced)
+
+	// A paused renewal is reported as paused, with its last error
+	assert.Equal(t, acmeprovider.RenewalStatePaused, expiring[1].RenewalState)
+	assert.Equal(t, "rate limited", expiring[1].LastError)
+	require.NotNil(t, expiring[1].Referenced)
+	assert.True(t, *expiring[1].Referenced)
+
+	assert.Equal(t, acmeprovider.RenewalStateScheduled, expiring[2].RenewalState)
+	require.NotNil(t, expiring[2].Referenced)
+	assert.False(t, *expiring[2].Referenced)
+}
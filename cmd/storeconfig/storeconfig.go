@@ -85,7 +85,12 @@ func Run(kv *staert.KvSource, traefikConfiguration *cmd.TraefikConfiguration) fu
 				}
 			}
 
-			accountInitialized, err := keyExists(kv, traefikConfiguration.GlobalConfiguration.ACME.Storage)
+			storage, err := traefikConfiguration.GlobalConfiguration.ACME.GetClusterStorage()
+			if err != nil {
+				return err
+			}
+
+			accountInitialized, err := keyExists(kv, storage)
 			if err != nil {
 				return err
 			}
@@ -103,7 +108,7 @@ func Run(kv *staert.KvSource, traefikConfiguration *cmd.TraefikConfiguration) fu
 
 				source := staert.KvSource{
 					Store:  kv,
-					Prefix: traefikConfiguration.GlobalConfiguration.ACME.Storage,
+					Prefix: storage,
 				}
 
 				err = source.StoreConfig(meta)
@@ -120,7 +125,7 @@ func Run(kv *staert.KvSource, traefikConfiguration *cmd.TraefikConfiguration) fu
 }
 
 func keyExists(source *staert.KvSource, key string) (bool, error) {
-	list, err := source.List(key, nil)
+	list, err := cluster.BoundStore(source.Store).List(key, nil)
 	if err != nil {
 		return false, err
 	}
@@ -16,6 +16,7 @@ import (
 	"github.com/containous/staert"
 	"github.com/containous/traefik/autogen/genstatic"
 	"github.com/containous/traefik/cmd"
+	cmdACME "github.com/containous/traefik/cmd/acme"
 	"github.com/containous/traefik/cmd/bug"
 	"github.com/containous/traefik/cmd/healthcheck"
 	"github.com/containous/traefik/cmd/storeconfig"
@@ -81,6 +82,7 @@ Complete documentation is available at https://traefik.io`,
 	f.AddCommand(bug.NewCmd(traefikConfiguration, traefikPointersConfiguration))
 	f.AddCommand(storeConfigCmd)
 	f.AddCommand(healthcheck.NewCmd(traefikConfiguration, traefikPointersConfiguration))
+	f.AddCommand(cmdACME.NewCmd(os.Args[1:]))
 
 	usedCmd, err := f.GetCommand()
 	if err != nil {
@@ -184,6 +186,13 @@ func runCmd(globalConfiguration *configuration.GlobalConfiguration, configFile s
 
 	acmeprovider := globalConfiguration.InitACMEProvider()
 	if acmeprovider != nil {
+		// The tracing is set up before the ACME provider is initialized, to trace the load of the storage
+		if globalConfiguration.Tracing != nil && globalConfiguration.Tracing.Backend != "" {
+			globalConfiguration.Tracing.Setup()
+		}
+		if globalConfiguration.Tracing.IsEnabled() {
+			acmeprovider.SetTracer(globalConfiguration.Tracing)
+		}
 
 		if err := providerAggregator.AddProvider(acmeprovider); err != nil {
 			log.Errorf("Error initializing provider ACME: %v", err)
@@ -191,6 +200,10 @@ func runCmd(globalConfiguration *configuration.GlobalConfiguration, configFile s
 		}
 	}
 
+	if globalConfiguration.API != nil && acmeprovider != nil {
+		globalConfiguration.API.ACMEProvider = acmeprovider
+	}
+
 	entryPoints := map[string]server.EntryPoint{}
 	for entryPointName, config := range globalConfiguration.EntryPoints {
 
@@ -205,7 +218,7 @@ func runCmd(globalConfiguration *configuration.GlobalConfiguration, configFile s
 			}
 
 			// TLS ALPN 01
-			if acmeprovider.HTTPChallenge == nil && acmeprovider.DNSChallenge == nil && acmeprovider.TLSChallenge != nil {
+			if acmeprovider.TLSChallenge != nil {
 				entryPoint.TLSALPNGetter = acmeprovider.GetTLSALPNCertificate
 			}
 
@@ -216,6 +229,10 @@ func runCmd(globalConfiguration *configuration.GlobalConfiguration, configFile s
 			entryPoint.CertificateStore = traefiktls.NewCertificateStore()
 			acmeprovider.SetCertificateStore(entryPoint.CertificateStore)
 
+			if !acmeprovider.EphemeralDefaultCert {
+				entryPoint.DefaultCertificateGetter = acmeprovider.GetDefaultCertificate
+			}
+
 		}
 
 		entryPoint.InternalRouter = internalRouter
@@ -223,14 +240,25 @@ func runCmd(globalConfiguration *configuration.GlobalConfiguration, configFile s
 	}
 
 	svr := server.NewServer(*globalConfiguration, providerAggregator, entryPoints)
+	if acmeprovider != nil {
+		acmeprovider.SetMetricsRegistry(svr.GetMetricsRegistry())
+	}
+
 	if acmeprovider != nil && acmeprovider.OnHostRule {
-		acmeprovider.SetConfigListenerChan(make(chan types.Configuration))
-		svr.AddListener(acmeprovider.ListenConfiguration)
+		acmeprovider.SetConfigListenerChan(make(chan types.ConfigMessage))
+		svr.AddMessageListener(acmeprovider.ListenConfigMessage)
 	}
 	ctx := cmd.ContextWithSignal(context.Background())
 
 	if globalConfiguration.Ping != nil {
 		globalConfiguration.Ping.WithContext(ctx)
+
+		if acmeprovider != nil && acmeprovider.StorageUnhealthyThreshold > 0 {
+			globalConfiguration.Ping.AddCheck(acmeprovider.CheckStorageHealth)
+		}
+		if acmeprovider != nil && acmeprovider.StorageLoadTimeout > 0 {
+			globalConfiguration.Ping.AddCheck(acmeprovider.CheckStorageLoaded)
+		}
 	}
 
 	svr.StartWithContext(ctx)
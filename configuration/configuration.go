@@ -235,19 +235,21 @@ func (gc *GlobalConfiguration) initACMEProvider() {
 	if gc.ACME != nil {
 		gc.ACME.CAServer = getSafeACMECAServer(gc.ACME.CAServer)
 
-		if gc.ACME.DNSChallenge != nil && gc.ACME.HTTPChallenge != nil {
-			log.Warn("Unable to use DNS challenge and HTTP challenge at the same time. Fallback to DNS challenge.")
-			gc.ACME.HTTPChallenge = nil
-		}
+		if len(gc.ACME.DomainsChallenge) == 0 {
+			if gc.ACME.DNSChallenge != nil && gc.ACME.HTTPChallenge != nil {
+				log.Warn("Unable to use DNS challenge and HTTP challenge at the same time. Fallback to DNS challenge.")
+				gc.ACME.HTTPChallenge = nil
+			}
 
-		if gc.ACME.DNSChallenge != nil && gc.ACME.TLSChallenge != nil {
-			log.Warn("Unable to use DNS challenge and TLS challenge at the same time. Fallback to DNS challenge.")
-			gc.ACME.TLSChallenge = nil
-		}
+			if gc.ACME.DNSChallenge != nil && gc.ACME.TLSChallenge != nil {
+				log.Warn("Unable to use DNS challenge and TLS challenge at the same time. Fallback to DNS challenge.")
+				gc.ACME.TLSChallenge = nil
+			}
 
-		if gc.ACME.HTTPChallenge != nil && gc.ACME.TLSChallenge != nil {
-			log.Warn("Unable to use HTTP challenge and TLS challenge at the same time. Fallback to TLS challenge.")
-			gc.ACME.HTTPChallenge = nil
+			if gc.ACME.HTTPChallenge != nil && gc.ACME.TLSChallenge != nil {
+				log.Warn("Unable to use HTTP challenge and TLS challenge at the same time. Fallback to TLS challenge.")
+				gc.ACME.HTTPChallenge = nil
+			}
 		}
 
 		if len(gc.ACME.DNSProvider) > 0 {
@@ -269,23 +271,94 @@ func (gc *GlobalConfiguration) InitACMEProvider() *acmeprovider.Provider {
 		if gc.Cluster == nil {
 			provider := &acmeprovider.Provider{}
 			provider.Configuration = &acmeprovider.Configuration{
-				KeyType:       gc.ACME.KeyType,
-				OnHostRule:    gc.ACME.OnHostRule,
-				OnDemand:      gc.ACME.OnDemand,
-				Email:         gc.ACME.Email,
-				Storage:       gc.ACME.Storage,
-				HTTPChallenge: gc.ACME.HTTPChallenge,
-				DNSChallenge:  gc.ACME.DNSChallenge,
-				TLSChallenge:  gc.ACME.TLSChallenge,
-				Domains:       gc.ACME.Domains,
-				ACMELogging:   gc.ACME.ACMELogging,
-				CAServer:      gc.ACME.CAServer,
-				EntryPoint:    gc.ACME.EntryPoint,
+				KeyType:                    gc.ACME.KeyType,
+				AccountKeyType:             gc.ACME.AccountKeyType,
+				DomainsKeyType:             gc.ACME.DomainsKeyType,
+				DomainsChallenge:           gc.ACME.DomainsChallenge,
+				RenewBefore:                gc.ACME.RenewBefore,
+				DomainsRenewBefore:         gc.ACME.DomainsRenewBefore,
+				StaggerRenewals:            gc.ACME.StaggerRenewals,
+				DomainsMustStaple:          gc.ACME.DomainsMustStaple,
+				DomainsMetadata:            gc.ACME.DomainsMetadata,
+				RenewalInfoRefreshInterval: gc.ACME.RenewalInfoRefreshInterval,
+				ChainRefreshInterval:       gc.ACME.ChainRefreshInterval,
+				AccountKeySecretRef:        gc.ACME.AccountKeySecretRef,
+				OnHostRule:                 gc.ACME.OnHostRule,
+				OnDemand:                   gc.ACME.OnDemand,
+				OnDemandQueueTTL:           gc.ACME.OnDemandQueueTTL,
+				Email:                      gc.ACME.Email,
+				Storage:                    gc.ACME.Storage,
+				StorageEncryption:          gc.ACME.StorageEncryption,
+				StorageSigning:             gc.ACME.StorageSigning,
+				StorageReadOnlyFallback:    gc.ACME.StorageReadOnlyFallback,
+				ReadOnly:                   gc.ACME.ReadOnly,
+				AuditLog:                   gc.ACME.AuditLog,
+				CertificateSecrets:         gc.ACME.CertificateSecrets,
+				KubernetesEvents:           gc.ACME.KubernetesEvents,
+				StorageUnhealthyThreshold:  gc.ACME.StorageUnhealthyThreshold,
+				ExpiryAlerts:               gc.ACME.ExpiryAlerts,
+				DeployHooks:                gc.ACME.DeployHooks,
+				StorageDrift:               gc.ACME.StorageDrift,
+				StorageSaveQuietPeriod:     gc.ACME.StorageSaveQuietPeriod,
+				StorageMaxSaveDelay:        gc.ACME.StorageMaxSaveDelay,
+				StoragePollInterval:        gc.ACME.StoragePollInterval,
+				StorageCacheTTL:            gc.ACME.StorageCacheTTL,
+				StorageRevisions:           gc.ACME.StorageRevisions,
+				StorageMissing:             gc.ACME.StorageMissing,
+				StorageLoadTimeout:         gc.ACME.StorageLoadTimeout,
+				StorageCircuitBreaker:      gc.ACME.StorageCircuitBreaker,
+				StorageLocalCache:          gc.ACME.StorageLocalCache,
+				StorageConsistencyInterval: gc.ACME.StorageConsistencyInterval,
+				StorageFailMode:            gc.ACME.StorageFailMode,
+				StorageCanary:              gc.ACME.StorageCanary,
+				HTTPChallenge:              gc.ACME.HTTPChallenge,
+				DNSChallenge:               gc.ACME.DNSChallenge,
+				TLSChallenge:               gc.ACME.TLSChallenge,
+				EphemeralChallenges:        gc.ACME.EphemeralChallenges,
+				EphemeralDefaultCert:       gc.ACME.EphemeralDefaultCert,
+				Domains:                    gc.ACME.Domains,
+				ACMELogging:                gc.ACME.ACMELogging,
+				CAServer:                   gc.ACME.CAServer,
+				CACertificates:             gc.ACME.CACertificates,
+				CACertificatesSecretRef:    gc.ACME.CACertificatesSecretRef,
+				TrustedRoots:               gc.ACME.TrustedRoots,
+				AllowUnverifiedChains:      gc.ACME.AllowUnverifiedChains,
+				ValidateWithStaging:        gc.ACME.ValidateWithStaging,
+				EntryPoint:                 gc.ACME.EntryPoint,
+			}
+
+			// The Kubernetes Events are emitted by default when Traefik runs with Kubernetes
+			if provider.KubernetesEvents == nil && (provider.CertificateSecrets != nil || gc.Kubernetes != nil) {
+				provider.KubernetesEvents = &acmeprovider.KubernetesEvents{}
 			}
 
 			store := acmeprovider.NewLocalStore(provider.Storage)
-			provider.Store = store
-			acme.ConvertToNewFormat(provider.Storage)
+			store.EphemeralChallenges = provider.EphemeralChallenges
+			store.Encryption = provider.StorageEncryption
+			store.Signing = provider.StorageSigning
+			store.ReadOnlyFallback = provider.StorageReadOnlyFallback
+			store.AuditLog = provider.AuditLog
+			store.CertificateSecrets = provider.CertificateSecrets
+			store.SaveQuietPeriod = time.Duration(provider.StorageSaveQuietPeriod)
+			if store.SaveQuietPeriod == 0 {
+				store.SaveQuietPeriod = acmeprovider.DefaultSaveQuietPeriod
+			}
+			store.MaxSaveDelay = time.Duration(provider.StorageMaxSaveDelay)
+			store.Revisions = provider.StorageRevisions
+			store.MissingPolicy = provider.StorageMissing
+			store.CircuitBreaker = provider.StorageCircuitBreaker
+			store.LocalCacheFile = provider.StorageLocalCache
+			if provider.HTTPChallenge != nil {
+				store.HTTPChallengeTokenValidity = time.Duration(provider.HTTPChallenge.TokenValidity)
+				if store.HTTPChallengeTokenValidity <= 0 {
+					store.HTTPChallengeTokenValidity = time.Duration(provider.HTTPChallenge.TokenTTL)
+				}
+			}
+			provider.Store = acmeprovider.WrapStore(store, acmeprovider.StoreWrapOptions{CacheTTL: time.Duration(provider.StorageCacheTTL)})
+			// An encrypted or signed storage is always in the new format
+			if provider.StorageEncryption == nil && provider.StorageSigning == nil {
+				acme.ConvertToNewFormat(provider.Storage)
+			}
 			gc.ACME = nil
 			return provider
 		}
@@ -323,6 +396,12 @@ func (gc *GlobalConfiguration) ValidateConfiguration() {
 				log.Fatalf("Entrypoint %q has no TLS configuration for ACME configuration", gc.ACME.EntryPoint)
 			}
 		}
+
+		if gc.ACME.HTTPChallenge != nil {
+			if _, ok := gc.EntryPoints[gc.ACME.HTTPChallenge.EntryPoint]; !ok {
+				log.Fatalf("Unknown entrypoint %q for ACME HTTP challenge", gc.ACME.HTTPChallenge.EntryPoint)
+			}
+		}
 	}
 }
 
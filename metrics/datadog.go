@@ -31,6 +31,30 @@ const (
 	ddEntrypointOpenConnsName     = "entrypoint.connections.open"
 	ddOpenConnsName               = "backend.connections.open"
 	ddServerUpName                = "backend.server.up"
+	ddACMEChallengesName          = "acme.challenges.total"
+	ddACMEPendingChallengesName   = "acme.challenges.pending"
+	ddACMESignatureFailuresName   = "acme.storage.signature.failures.total"
+	ddACMEStoreOperationsName     = "acme.store.operations.total"
+	ddACMEStoreFailuresName       = "acme.store.operation.failures.total"
+	ddACMEStoreSaveDurationName   = "acme.store.save.duration"
+	ddACMEStoreLastSaveName       = "acme.store.seconds.since.last.save"
+	ddACMEIssuanceDurationName    = "acme.issuance.duration"
+	ddACMEIssuanceFailureName     = "acme.issuance.failure.duration"
+	ddACMEHookFailuresName        = "acme.deploy.hook.failures.total"
+	ddACMEStorePayloadSizeName    = "acme.store.payload.size"
+	ddACMEStoreRejectedName       = "acme.store.rejected.writes.total"
+	ddACMEStorePanicsName         = "acme.store.panics.total"
+	ddACMEStorageDriftName        = "acme.storage.drift.total"
+	ddACMEStoreCoalescedName      = "acme.store.coalesced.updates"
+	ddACMECTFailuresName          = "acme.certificate.transparency.failures.total"
+	ddACMEStoreK8sReadsName       = "acme.store.kubernetes.reads.total"
+	ddACMEStoreReplLagName        = "acme.store.replication.lag"
+	ddACMEStoreDegradedName       = "acme.store.degraded"
+	ddACMEStoreMismatchesName     = "acme.store.mismatches.total"
+	ddACMEStoreSkippedName        = "acme.store.skipped.writes.total"
+	ddACMECertExpiryName          = "acme.certificate.expiry"
+	ddACMECertVerificationName    = "acme.certificate.verification.failures.total"
+	ddACMEStoreCanaryName         = "acme.store.canary"
 )
 
 // RegisterDatadog registers the metrics pusher if this didn't happen yet and creates a datadog Registry instance.
@@ -53,6 +77,30 @@ func RegisterDatadog(config *types.Datadog) Registry {
 		backendRetriesCounter:          datadogClient.NewCounter(ddRetriesTotalName, 1.0),
 		backendOpenConnsGauge:          datadogClient.NewGauge(ddOpenConnsName),
 		backendServerUpGauge:           datadogClient.NewGauge(ddServerUpName),
+		acmeChallengesCounter:          datadogClient.NewCounter(ddACMEChallengesName, 1.0),
+		acmePendingChallengesGauge:     datadogClient.NewGauge(ddACMEPendingChallengesName),
+		acmeSignatureFailuresCounter:   datadogClient.NewCounter(ddACMESignatureFailuresName, 1.0),
+		acmeStoreOperationsCounter:     datadogClient.NewCounter(ddACMEStoreOperationsName, 1.0),
+		acmeStoreFailuresCounter:       datadogClient.NewCounter(ddACMEStoreFailuresName, 1.0),
+		acmeStoreSaveDurationHistogram: datadogClient.NewHistogram(ddACMEStoreSaveDurationName, 1.0),
+		acmeStoreLastSaveGauge:         datadogClient.NewGauge(ddACMEStoreLastSaveName),
+		acmeIssuanceDurationHistogram:  datadogClient.NewHistogram(ddACMEIssuanceDurationName, 1.0),
+		acmeIssuanceFailureHistogram:   datadogClient.NewHistogram(ddACMEIssuanceFailureName, 1.0),
+		acmeHookFailuresCounter:        datadogClient.NewCounter(ddACMEHookFailuresName, 1.0),
+		acmeStorePayloadSizeGauge:      datadogClient.NewGauge(ddACMEStorePayloadSizeName),
+		acmeStoreRejectedWritesCounter: datadogClient.NewCounter(ddACMEStoreRejectedName, 1.0),
+		acmeStorePanicsCounter:         datadogClient.NewCounter(ddACMEStorePanicsName, 1.0),
+		acmeStorageDriftCounter:        datadogClient.NewCounter(ddACMEStorageDriftName, 1.0),
+		acmeStoreCoalescedHistogram:    datadogClient.NewHistogram(ddACMEStoreCoalescedName, 1.0),
+		acmeCTFailuresCounter:          datadogClient.NewCounter(ddACMECTFailuresName, 1.0),
+		acmeStoreK8sReadsCounter:       datadogClient.NewCounter(ddACMEStoreK8sReadsName, 1.0),
+		acmeStoreReplLagGauge:          datadogClient.NewGauge(ddACMEStoreReplLagName),
+		acmeStoreDegradedGauge:         datadogClient.NewGauge(ddACMEStoreDegradedName),
+		acmeStoreMismatchesCounter:     datadogClient.NewCounter(ddACMEStoreMismatchesName, 1.0),
+		acmeStoreSkippedCounter:        datadogClient.NewCounter(ddACMEStoreSkippedName, 1.0),
+		acmeCertExpiryGauge:            datadogClient.NewGauge(ddACMECertExpiryName),
+		acmeCertVerificationCounter:    datadogClient.NewCounter(ddACMECertVerificationName, 1.0),
+		acmeStoreCanaryGauge:           datadogClient.NewGauge(ddACMEStoreCanaryName),
 	}
 
 	return registry
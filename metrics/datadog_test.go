@@ -34,6 +34,30 @@ func TestDatadog(t *testing.T) {
 		"traefik.entrypoint.request.duration:10000.000000|h|#entrypoint:test\n",
 		"traefik.entrypoint.connections.open:1.000000|g|#entrypoint:test\n",
 		"traefik.backend.server.up:1.000000|g|#backend:test,url:http://127.0.0.1,one:two\n",
+		"traefik.acme.challenges.total:1.000000|c|#type:http-01,outcome:created\n",
+		"traefik.acme.challenges.pending:1.000000|g|#type:http-01\n",
+		"traefik.acme.storage.signature.failures.total:1.000000|c|#reason:invalid\n",
+		"traefik.acme.store.operations.total:1.000000|c|#backend:file,operation:save\n",
+		"traefik.acme.store.operation.failures.total:1.000000|c|#backend:file,operation:save\n",
+		"traefik.acme.store.save.duration:10000.000000|h|#backend:file\n",
+		"traefik.acme.store.seconds.since.last.save:1.000000|g|#backend:file\n",
+		"traefik.acme.issuance.duration:10000.000000|h|#type:http-01,phase:total\n",
+		"traefik.acme.issuance.failure.duration:10000.000000|h|#type:http-01,phase:total\n",
+		"traefik.acme.deploy.hook.failures.total:1.000000|c\n",
+		"traefik.acme.store.payload.size:1024.000000|g|#backend:file,stage:serialized\n",
+		"traefik.acme.store.rejected.writes.total:1.000000|c|#backend:file\n",
+		"traefik.acme.store.panics.total:1.000000|c|#backend:file\n",
+		"traefik.acme.storage.drift.total:1.000000|c|#backend:file,kind:added\n",
+		"traefik.acme.store.coalesced.updates:3.000000|h|#backend:file\n",
+		"traefik.acme.certificate.transparency.failures.total:1.000000|c|#reason:missing\n",
+		"traefik.acme.store.kubernetes.reads.total:1.000000|c|#object:secrets,source:cache\n",
+		"traefik.acme.store.replication.lag:1.000000|g|#replica:passive\n",
+		"traefik.acme.store.degraded:1.000000|g|#backend:file\n",
+		"traefik.acme.store.mismatches.total:1.000000|c|#backend:file,section:account\n",
+		"traefik.acme.store.skipped.writes.total:1.000000|c|#backend:file\n",
+		"traefik.acme.certificate.expiry:1.000000|g|#domain:traefik.wtf,renewal_paused:false\n",
+		"traefik.acme.certificate.verification.failures.total:1.000000|c|#reason:chain\n",
+		"traefik.acme.store.canary:1.000000|g|#backend:file\n",
 	}
 
 	udp.ShouldReceiveAll(t, expected, func() {
@@ -48,5 +72,29 @@ func TestDatadog(t *testing.T) {
 		datadogRegistry.EntrypointReqDurationHistogram().With("entrypoint", "test").Observe(10000)
 		datadogRegistry.EntrypointOpenConnsGauge().With("entrypoint", "test").Set(1)
 		datadogRegistry.BackendServerUpGauge().With("backend", "test", "url", "http://127.0.0.1", "one", "two").Set(1)
+		datadogRegistry.ACMEChallengesCounter().With("type", "http-01", "outcome", "created").Add(1)
+		datadogRegistry.ACMEPendingChallengesGauge().With("type", "http-01").Set(1)
+		datadogRegistry.ACMESignatureFailuresCounter().With("reason", "invalid").Add(1)
+		datadogRegistry.ACMEStoreOperationsCounter().With("backend", "file", "operation", "save").Add(1)
+		datadogRegistry.ACMEStoreFailuresCounter().With("backend", "file", "operation", "save").Add(1)
+		datadogRegistry.ACMEStoreSaveDurationHistogram().With("backend", "file").Observe(10000)
+		datadogRegistry.ACMEStoreLastSaveGauge().With("backend", "file").Set(1)
+		datadogRegistry.ACMEIssuanceDurationHistogram().With("type", "http-01", "phase", "total").Observe(10000)
+		datadogRegistry.ACMEIssuanceFailureHistogram().With("type", "http-01", "phase", "total").Observe(10000)
+		datadogRegistry.ACMEHookFailuresCounter().Add(1)
+		datadogRegistry.ACMEStorePayloadSizeGauge().With("backend", "file", "stage", "serialized").Set(1024)
+		datadogRegistry.ACMEStoreRejectedWritesCounter().With("backend", "file").Add(1)
+		datadogRegistry.ACMEStorePanicsCounter().With("backend", "file").Add(1)
+		datadogRegistry.ACMEStorageDriftCounter().With("backend", "file", "kind", "added").Add(1)
+		datadogRegistry.ACMEStoreCoalescedUpdatesHistogram().With("backend", "file").Observe(3)
+		datadogRegistry.ACMECTFailuresCounter().With("reason", "missing").Add(1)
+		datadogRegistry.ACMEStoreKubernetesReadsCounter().With("object", "secrets", "source", "cache").Add(1)
+		datadogRegistry.ACMEStoreReplicationLagGauge().With("replica", "passive").Set(1)
+		datadogRegistry.ACMEStoreDegradedGauge().With("backend", "file").Set(1)
+		datadogRegistry.ACMEStoreMismatchesCounter().With("backend", "file", "section", "account").Add(1)
+		datadogRegistry.ACMEStoreSkippedWritesCounter().With("backend", "file").Add(1)
+		datadogRegistry.ACMECertificateExpiryGauge().With("domain", "traefik.wtf", "renewal_paused", "false").Set(1)
+		datadogRegistry.ACMECertificateVerificationFailuresCounter().With("reason", "chain").Add(1)
+		datadogRegistry.ACMEStoreCanaryGauge().With("backend", "file").Set(1)
 	})
 }
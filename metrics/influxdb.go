@@ -37,6 +37,30 @@ const (
 	influxDBEntrypointOpenConnsName     = "traefik.entrypoint.connections.open"
 	influxDBOpenConnsName               = "traefik.backend.connections.open"
 	influxDBServerUpName                = "traefik.backend.server.up"
+	influxDBACMEChallengesName          = "traefik.acme.challenges.total"
+	influxDBACMEPendingChallengesName   = "traefik.acme.challenges.pending"
+	influxDBACMESignatureFailuresName   = "traefik.acme.storage.signature.failures.total"
+	influxDBACMEStoreOperationsName     = "traefik.acme.store.operations.total"
+	influxDBACMEStoreFailuresName       = "traefik.acme.store.operation.failures.total"
+	influxDBACMEStoreSaveDurationName   = "traefik.acme.store.save.duration"
+	influxDBACMEStoreLastSaveName       = "traefik.acme.store.seconds.since.last.save"
+	influxDBACMEIssuanceDurationName    = "traefik.acme.issuance.duration"
+	influxDBACMEIssuanceFailureName     = "traefik.acme.issuance.failure.duration"
+	influxDBACMEHookFailuresName        = "traefik.acme.deploy.hook.failures.total"
+	influxDBACMEStorePayloadSizeName    = "traefik.acme.store.payload.size"
+	influxDBACMEStoreRejectedName       = "traefik.acme.store.rejected.writes.total"
+	influxDBACMEStorePanicsName         = "traefik.acme.store.panics.total"
+	influxDBACMEStorageDriftName        = "traefik.acme.storage.drift.total"
+	influxDBACMEStoreCoalescedName      = "traefik.acme.store.coalesced.updates"
+	influxDBACMECTFailuresName          = "traefik.acme.certificate.transparency.failures.total"
+	influxDBACMEStoreK8sReadsName       = "traefik.acme.store.kubernetes.reads.total"
+	influxDBACMEStoreReplLagName        = "traefik.acme.store.replication.lag"
+	influxDBACMEStoreDegradedName       = "traefik.acme.store.degraded"
+	influxDBACMEStoreMismatchesName     = "traefik.acme.store.mismatches.total"
+	influxDBACMEStoreSkippedName        = "traefik.acme.store.skipped.writes.total"
+	influxDBACMECertExpiryName          = "traefik.acme.certificate.expiry"
+	influxDBACMECertVerificationName    = "traefik.acme.certificate.verification.failures.total"
+	influxDBACMEStoreCanaryName         = "traefik.acme.store.canary"
 )
 
 // RegisterInfluxDB registers the metrics pusher if this didn't happen yet and creates a InfluxDB Registry instance.
@@ -62,6 +86,30 @@ func RegisterInfluxDB(config *types.InfluxDB) Registry {
 		backendRetriesCounter:          influxDBClient.NewCounter(influxDBRetriesTotalName),
 		backendOpenConnsGauge:          influxDBClient.NewGauge(influxDBOpenConnsName),
 		backendServerUpGauge:           influxDBClient.NewGauge(influxDBServerUpName),
+		acmeChallengesCounter:          influxDBClient.NewCounter(influxDBACMEChallengesName),
+		acmePendingChallengesGauge:     influxDBClient.NewGauge(influxDBACMEPendingChallengesName),
+		acmeSignatureFailuresCounter:   influxDBClient.NewCounter(influxDBACMESignatureFailuresName),
+		acmeStoreOperationsCounter:     influxDBClient.NewCounter(influxDBACMEStoreOperationsName),
+		acmeStoreFailuresCounter:       influxDBClient.NewCounter(influxDBACMEStoreFailuresName),
+		acmeStoreSaveDurationHistogram: influxDBClient.NewHistogram(influxDBACMEStoreSaveDurationName),
+		acmeStoreLastSaveGauge:         influxDBClient.NewGauge(influxDBACMEStoreLastSaveName),
+		acmeIssuanceDurationHistogram:  influxDBClient.NewHistogram(influxDBACMEIssuanceDurationName),
+		acmeIssuanceFailureHistogram:   influxDBClient.NewHistogram(influxDBACMEIssuanceFailureName),
+		acmeHookFailuresCounter:        influxDBClient.NewCounter(influxDBACMEHookFailuresName),
+		acmeStorePayloadSizeGauge:      influxDBClient.NewGauge(influxDBACMEStorePayloadSizeName),
+		acmeStoreRejectedWritesCounter: influxDBClient.NewCounter(influxDBACMEStoreRejectedName),
+		acmeStorePanicsCounter:         influxDBClient.NewCounter(influxDBACMEStorePanicsName),
+		acmeStorageDriftCounter:        influxDBClient.NewCounter(influxDBACMEStorageDriftName),
+		acmeStoreCoalescedHistogram:    influxDBClient.NewHistogram(influxDBACMEStoreCoalescedName),
+		acmeCTFailuresCounter:          influxDBClient.NewCounter(influxDBACMECTFailuresName),
+		acmeStoreK8sReadsCounter:       influxDBClient.NewCounter(influxDBACMEStoreK8sReadsName),
+		acmeStoreReplLagGauge:          influxDBClient.NewGauge(influxDBACMEStoreReplLagName),
+		acmeStoreDegradedGauge:         influxDBClient.NewGauge(influxDBACMEStoreDegradedName),
+		acmeStoreMismatchesCounter:     influxDBClient.NewCounter(influxDBACMEStoreMismatchesName),
+		acmeStoreSkippedCounter:        influxDBClient.NewCounter(influxDBACMEStoreSkippedName),
+		acmeCertExpiryGauge:            influxDBClient.NewGauge(influxDBACMECertExpiryName),
+		acmeCertVerificationCounter:    influxDBClient.NewCounter(influxDBACMECertVerificationName),
+		acmeStoreCanaryGauge:           influxDBClient.NewGauge(influxDBACMEStoreCanaryName),
 	}
 }
 
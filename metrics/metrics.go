@@ -27,6 +27,32 @@ type Registry interface {
 	BackendOpenConnsGauge() metrics.Gauge
 	BackendRetriesCounter() metrics.Counter
 	BackendServerUpGauge() metrics.Gauge
+
+	// acme metrics
+	ACMEChallengesCounter() metrics.Counter
+	ACMEPendingChallengesGauge() metrics.Gauge
+	ACMESignatureFailuresCounter() metrics.Counter
+	ACMEStoreOperationsCounter() metrics.Counter
+	ACMEStoreFailuresCounter() metrics.Counter
+	ACMEStoreSaveDurationHistogram() metrics.Histogram
+	ACMEStoreLastSaveGauge() metrics.Gauge
+	ACMEIssuanceDurationHistogram() metrics.Histogram
+	ACMEIssuanceFailureHistogram() metrics.Histogram
+	ACMEHookFailuresCounter() metrics.Counter
+	ACMEStorePayloadSizeGauge() metrics.Gauge
+	ACMEStoreRejectedWritesCounter() metrics.Counter
+	ACMEStorePanicsCounter() metrics.Counter
+	ACMEStorageDriftCounter() metrics.Counter
+	ACMEStoreCoalescedUpdatesHistogram() metrics.Histogram
+	ACMECTFailuresCounter() metrics.Counter
+	ACMEStoreKubernetesReadsCounter() metrics.Counter
+	ACMEStoreReplicationLagGauge() metrics.Gauge
+	ACMEStoreDegradedGauge() metrics.Gauge
+	ACMEStoreMismatchesCounter() metrics.Counter
+	ACMEStoreSkippedWritesCounter() metrics.Counter
+	ACMECertificateExpiryGauge() metrics.Gauge
+	ACMECertificateVerificationFailuresCounter() metrics.Counter
+	ACMEStoreCanaryGauge() metrics.Gauge
 }
 
 // NewVoidRegistry is a noop implementation of metrics.Registry.
@@ -51,6 +77,30 @@ func NewMultiRegistry(registries []Registry) Registry {
 	var backendOpenConnsGauge []metrics.Gauge
 	var backendRetriesCounter []metrics.Counter
 	var backendServerUpGauge []metrics.Gauge
+	var acmeChallengesCounter []metrics.Counter
+	var acmePendingChallengesGauge []metrics.Gauge
+	var acmeSignatureFailuresCounter []metrics.Counter
+	var acmeStoreOperationsCounter []metrics.Counter
+	var acmeStoreFailuresCounter []metrics.Counter
+	var acmeStoreSaveDurationHistogram []metrics.Histogram
+	var acmeStoreLastSaveGauge []metrics.Gauge
+	var acmeIssuanceDurationHistogram []metrics.Histogram
+	var acmeIssuanceFailureHistogram []metrics.Histogram
+	var acmeHookFailuresCounter []metrics.Counter
+	var acmeStorePayloadSizeGauge []metrics.Gauge
+	var acmeStoreRejectedWritesCounter []metrics.Counter
+	var acmeStorePanicsCounter []metrics.Counter
+	var acmeStorageDriftCounter []metrics.Counter
+	var acmeStoreCoalescedHistogram []metrics.Histogram
+	var acmeCTFailuresCounter []metrics.Counter
+	var acmeStoreK8sReadsCounter []metrics.Counter
+	var acmeStoreReplLagGauge []metrics.Gauge
+	var acmeStoreDegradedGauge []metrics.Gauge
+	var acmeStoreMismatchesCounter []metrics.Counter
+	var acmeStoreSkippedCounter []metrics.Counter
+	var acmeCertExpiryGauge []metrics.Gauge
+	var acmeCertVerificationCounter []metrics.Counter
+	var acmeStoreCanaryGauge []metrics.Gauge
 
 	for _, r := range registries {
 		if r.ConfigReloadsCounter() != nil {
@@ -89,6 +139,78 @@ func NewMultiRegistry(registries []Registry) Registry {
 		if r.BackendServerUpGauge() != nil {
 			backendServerUpGauge = append(backendServerUpGauge, r.BackendServerUpGauge())
 		}
+		if r.ACMEChallengesCounter() != nil {
+			acmeChallengesCounter = append(acmeChallengesCounter, r.ACMEChallengesCounter())
+		}
+		if r.ACMEPendingChallengesGauge() != nil {
+			acmePendingChallengesGauge = append(acmePendingChallengesGauge, r.ACMEPendingChallengesGauge())
+		}
+		if r.ACMESignatureFailuresCounter() != nil {
+			acmeSignatureFailuresCounter = append(acmeSignatureFailuresCounter, r.ACMESignatureFailuresCounter())
+		}
+		if r.ACMEStoreOperationsCounter() != nil {
+			acmeStoreOperationsCounter = append(acmeStoreOperationsCounter, r.ACMEStoreOperationsCounter())
+		}
+		if r.ACMEStoreFailuresCounter() != nil {
+			acmeStoreFailuresCounter = append(acmeStoreFailuresCounter, r.ACMEStoreFailuresCounter())
+		}
+		if r.ACMEStoreSaveDurationHistogram() != nil {
+			acmeStoreSaveDurationHistogram = append(acmeStoreSaveDurationHistogram, r.ACMEStoreSaveDurationHistogram())
+		}
+		if r.ACMEStoreLastSaveGauge() != nil {
+			acmeStoreLastSaveGauge = append(acmeStoreLastSaveGauge, r.ACMEStoreLastSaveGauge())
+		}
+		if r.ACMEIssuanceDurationHistogram() != nil {
+			acmeIssuanceDurationHistogram = append(acmeIssuanceDurationHistogram, r.ACMEIssuanceDurationHistogram())
+		}
+		if r.ACMEIssuanceFailureHistogram() != nil {
+			acmeIssuanceFailureHistogram = append(acmeIssuanceFailureHistogram, r.ACMEIssuanceFailureHistogram())
+		}
+		if r.ACMEHookFailuresCounter() != nil {
+			acmeHookFailuresCounter = append(acmeHookFailuresCounter, r.ACMEHookFailuresCounter())
+		}
+		if r.ACMEStorePayloadSizeGauge() != nil {
+			acmeStorePayloadSizeGauge = append(acmeStorePayloadSizeGauge, r.ACMEStorePayloadSizeGauge())
+		}
+		if r.ACMEStoreRejectedWritesCounter() != nil {
+			acmeStoreRejectedWritesCounter = append(acmeStoreRejectedWritesCounter, r.ACMEStoreRejectedWritesCounter())
+		}
+		if r.ACMEStorePanicsCounter() != nil {
+			acmeStorePanicsCounter = append(acmeStorePanicsCounter, r.ACMEStorePanicsCounter())
+		}
+		if r.ACMEStorageDriftCounter() != nil {
+			acmeStorageDriftCounter = append(acmeStorageDriftCounter, r.ACMEStorageDriftCounter())
+		}
+		if r.ACMEStoreCoalescedUpdatesHistogram() != nil {
+			acmeStoreCoalescedHistogram = append(acmeStoreCoalescedHistogram, r.ACMEStoreCoalescedUpdatesHistogram())
+		}
+		if r.ACMECTFailuresCounter() != nil {
+			acmeCTFailuresCounter = append(acmeCTFailuresCounter, r.ACMECTFailuresCounter())
+		}
+		if r.ACMEStoreKubernetesReadsCounter() != nil {
+			acmeStoreK8sReadsCounter = append(acmeStoreK8sReadsCounter, r.ACMEStoreKubernetesReadsCounter())
+		}
+		if r.ACMEStoreReplicationLagGauge() != nil {
+			acmeStoreReplLagGauge = append(acmeStoreReplLagGauge, r.ACMEStoreReplicationLagGauge())
+		}
+		if r.ACMEStoreDegradedGauge() != nil {
+			acmeStoreDegradedGauge = append(acmeStoreDegradedGauge, r.ACMEStoreDegradedGauge())
+		}
+		if r.ACMEStoreMismatchesCounter() != nil {
+			acmeStoreMismatchesCounter = append(acmeStoreMismatchesCounter, r.ACMEStoreMismatchesCounter())
+		}
+		if r.ACMEStoreSkippedWritesCounter() != nil {
+			acmeStoreSkippedCounter = append(acmeStoreSkippedCounter, r.ACMEStoreSkippedWritesCounter())
+		}
+		if r.ACMECertificateExpiryGauge() != nil {
+			acmeCertExpiryGauge = append(acmeCertExpiryGauge, r.ACMECertificateExpiryGauge())
+		}
+		if r.ACMECertificateVerificationFailuresCounter() != nil {
+			acmeCertVerificationCounter = append(acmeCertVerificationCounter, r.ACMECertificateVerificationFailuresCounter())
+		}
+		if r.ACMEStoreCanaryGauge() != nil {
+			acmeStoreCanaryGauge = append(acmeStoreCanaryGauge, r.ACMEStoreCanaryGauge())
+		}
 	}
 
 	return &standardRegistry{
@@ -105,6 +227,30 @@ func NewMultiRegistry(registries []Registry) Registry {
 		backendOpenConnsGauge:          multi.NewGauge(backendOpenConnsGauge...),
 		backendRetriesCounter:          multi.NewCounter(backendRetriesCounter...),
 		backendServerUpGauge:           multi.NewGauge(backendServerUpGauge...),
+		acmeChallengesCounter:          multi.NewCounter(acmeChallengesCounter...),
+		acmePendingChallengesGauge:     multi.NewGauge(acmePendingChallengesGauge...),
+		acmeSignatureFailuresCounter:   multi.NewCounter(acmeSignatureFailuresCounter...),
+		acmeStoreOperationsCounter:     multi.NewCounter(acmeStoreOperationsCounter...),
+		acmeStoreFailuresCounter:       multi.NewCounter(acmeStoreFailuresCounter...),
+		acmeStoreSaveDurationHistogram: multi.NewHistogram(acmeStoreSaveDurationHistogram...),
+		acmeStoreLastSaveGauge:         multi.NewGauge(acmeStoreLastSaveGauge...),
+		acmeIssuanceDurationHistogram:  multi.NewHistogram(acmeIssuanceDurationHistogram...),
+		acmeIssuanceFailureHistogram:   multi.NewHistogram(acmeIssuanceFailureHistogram...),
+		acmeHookFailuresCounter:        multi.NewCounter(acmeHookFailuresCounter...),
+		acmeStorePayloadSizeGauge:      multi.NewGauge(acmeStorePayloadSizeGauge...),
+		acmeStoreRejectedWritesCounter: multi.NewCounter(acmeStoreRejectedWritesCounter...),
+		acmeStorePanicsCounter:         multi.NewCounter(acmeStorePanicsCounter...),
+		acmeStorageDriftCounter:        multi.NewCounter(acmeStorageDriftCounter...),
+		acmeStoreCoalescedHistogram:    multi.NewHistogram(acmeStoreCoalescedHistogram...),
+		acmeCTFailuresCounter:          multi.NewCounter(acmeCTFailuresCounter...),
+		acmeStoreK8sReadsCounter:       multi.NewCounter(acmeStoreK8sReadsCounter...),
+		acmeStoreReplLagGauge:          multi.NewGauge(acmeStoreReplLagGauge...),
+		acmeStoreDegradedGauge:         multi.NewGauge(acmeStoreDegradedGauge...),
+		acmeStoreMismatchesCounter:     multi.NewCounter(acmeStoreMismatchesCounter...),
+		acmeStoreSkippedCounter:        multi.NewCounter(acmeStoreSkippedCounter...),
+		acmeCertExpiryGauge:            multi.NewGauge(acmeCertExpiryGauge...),
+		acmeCertVerificationCounter:    multi.NewCounter(acmeCertVerificationCounter...),
+		acmeStoreCanaryGauge:           multi.NewGauge(acmeStoreCanaryGauge...),
 	}
 }
 
@@ -122,6 +268,30 @@ type standardRegistry struct {
 	backendOpenConnsGauge          metrics.Gauge
 	backendRetriesCounter          metrics.Counter
 	backendServerUpGauge           metrics.Gauge
+	acmeChallengesCounter          metrics.Counter
+	acmePendingChallengesGauge     metrics.Gauge
+	acmeSignatureFailuresCounter   metrics.Counter
+	acmeStoreOperationsCounter     metrics.Counter
+	acmeStoreFailuresCounter       metrics.Counter
+	acmeStoreSaveDurationHistogram metrics.Histogram
+	acmeStoreLastSaveGauge         metrics.Gauge
+	acmeIssuanceDurationHistogram  metrics.Histogram
+	acmeIssuanceFailureHistogram   metrics.Histogram
+	acmeHookFailuresCounter        metrics.Counter
+	acmeStorePayloadSizeGauge      metrics.Gauge
+	acmeStoreRejectedWritesCounter metrics.Counter
+	acmeStorePanicsCounter         metrics.Counter
+	acmeStorageDriftCounter        metrics.Counter
+	acmeStoreCoalescedHistogram    metrics.Histogram
+	acmeCTFailuresCounter          metrics.Counter
+	acmeStoreK8sReadsCounter       metrics.Counter
+	acmeStoreReplLagGauge          metrics.Gauge
+	acmeStoreDegradedGauge         metrics.Gauge
+	acmeStoreMismatchesCounter     metrics.Counter
+	acmeStoreSkippedCounter        metrics.Counter
+	acmeCertExpiryGauge            metrics.Gauge
+	acmeCertVerificationCounter    metrics.Counter
+	acmeStoreCanaryGauge           metrics.Gauge
 }
 
 func (r *standardRegistry) IsEnabled() bool {
@@ -175,3 +345,99 @@ func (r *standardRegistry) BackendRetriesCounter() metrics.Counter {
 func (r *standardRegistry) BackendServerUpGauge() metrics.Gauge {
 	return r.backendServerUpGauge
 }
+
+func (r *standardRegistry) ACMEChallengesCounter() metrics.Counter {
+	return r.acmeChallengesCounter
+}
+
+func (r *standardRegistry) ACMEPendingChallengesGauge() metrics.Gauge {
+	return r.acmePendingChallengesGauge
+}
+
+func (r *standardRegistry) ACMESignatureFailuresCounter() metrics.Counter {
+	return r.acmeSignatureFailuresCounter
+}
+
+func (r *standardRegistry) ACMEStoreOperationsCounter() metrics.Counter {
+	return r.acmeStoreOperationsCounter
+}
+
+func (r *standardRegistry) ACMEStoreFailuresCounter() metrics.Counter {
+	return r.acmeStoreFailuresCounter
+}
+
+func (r *standardRegistry) ACMEStoreSaveDurationHistogram() metrics.Histogram {
+	return r.acmeStoreSaveDurationHistogram
+}
+
+func (r *standardRegistry) ACMEStoreLastSaveGauge() metrics.Gauge {
+	return r.acmeStoreLastSaveGauge
+}
+
+func (r *standardRegistry) ACMEIssuanceDurationHistogram() metrics.Histogram {
+	return r.acmeIssuanceDurationHistogram
+}
+
+func (r *standardRegistry) ACMEIssuanceFailureHistogram() metrics.Histogram {
+	return r.acmeIssuanceFailureHistogram
+}
+
+func (r *standardRegistry) ACMEHookFailuresCounter() metrics.Counter {
+	return r.acmeHookFailuresCounter
+}
+
+func (r *standardRegistry) ACMEStorePayloadSizeGauge() metrics.Gauge {
+	return r.acmeStorePayloadSizeGauge
+}
+
+func (r *standardRegistry) ACMEStoreRejectedWritesCounter() metrics.Counter {
+	return r.acmeStoreRejectedWritesCounter
+}
+
+func (r *standardRegistry) ACMEStorePanicsCounter() metrics.Counter {
+	return r.acmeStorePanicsCounter
+}
+
+func (r *standardRegistry) ACMEStorageDriftCounter() metrics.Counter {
+	return r.acmeStorageDriftCounter
+}
+
+func (r *standardRegistry) ACMEStoreCoalescedUpdatesHistogram() metrics.Histogram {
+	return r.acmeStoreCoalescedHistogram
+}
+
+func (r *standardRegistry) ACMECTFailuresCounter() metrics.Counter {
+	return r.acmeCTFailuresCounter
+}
+
+func (r *standardRegistry) ACMEStoreKubernetesReadsCounter() metrics.Counter {
+	return r.acmeStoreK8sReadsCounter
+}
+
+func (r *standardRegistry) ACMEStoreReplicationLagGauge() metrics.Gauge {
+	return r.acmeStoreReplLagGauge
+}
+
+func (r *standardRegistry) ACMEStoreDegradedGauge() metrics.Gauge {
+	return r.acmeStoreDegradedGauge
+}
+
+func (r *standardRegistry) ACMEStoreMismatchesCounter() metrics.Counter {
+	return r.acmeStoreMismatchesCounter
+}
+
+func (r *standardRegistry) ACMEStoreSkippedWritesCounter() metrics.Counter {
+	return r.acmeStoreSkippedCounter
+}
+
+func (r *standardRegistry) ACMECertificateExpiryGauge() metrics.Gauge {
+	return r.acmeCertExpiryGauge
+}
+
+func (r *standardRegistry) ACMECertificateVerificationFailuresCounter() metrics.Counter {
+	return r.acmeCertVerificationCounter
+}
+
+func (r *standardRegistry) ACMEStoreCanaryGauge() metrics.Gauge {
+	return r.acmeStoreCanaryGauge
+}
@@ -41,6 +41,33 @@ const (
 	backendOpenConnsName    = MetricBackendPrefix + "open_connections"
 	backendRetriesTotalName = MetricBackendPrefix + "retries_total"
 	backendServerUpName     = MetricBackendPrefix + "server_up"
+
+	// acme
+	metricACMEPrefix          = MetricNamePrefix + "acme_"
+	acmeChallengesTotalName   = metricACMEPrefix + "challenges_total"
+	acmePendingChallengesName = metricACMEPrefix + "pending_challenges"
+	acmeSignatureFailuresName = metricACMEPrefix + "storage_signature_failures_total"
+	acmeStoreOperationsName   = metricACMEPrefix + "store_operations_total"
+	acmeStoreFailuresName     = metricACMEPrefix + "store_operation_failures_total"
+	acmeStoreSaveDurationName = metricACMEPrefix + "store_save_duration_seconds"
+	acmeStoreLastSaveName     = metricACMEPrefix + "store_seconds_since_last_save"
+	acmeIssuanceDurationName  = metricACMEPrefix + "issuance_duration_seconds"
+	acmeIssuanceFailureName   = metricACMEPrefix + "issuance_failure_duration_seconds"
+	acmeHookFailuresName      = metricACMEPrefix + "deploy_hook_failures_total"
+	acmeStorePayloadSizeName  = metricACMEPrefix + "store_payload_size_bytes"
+	acmeStoreRejectedName     = metricACMEPrefix + "store_rejected_writes_total"
+	acmeStorePanicsName       = metricACMEPrefix + "store_panics_total"
+	acmeStorageDriftName      = metricACMEPrefix + "storage_drift_total"
+	acmeStoreCoalescedName    = metricACMEPrefix + "store_coalesced_updates"
+	acmeCTFailuresName        = metricACMEPrefix + "certificate_transparency_failures_total"
+	acmeStoreK8sReadsName     = metricACMEPrefix + "store_kubernetes_reads_total"
+	acmeStoreReplLagName      = metricACMEPrefix + "store_replication_lag_seconds"
+	acmeStoreDegradedName     = metricACMEPrefix + "store_degraded"
+	acmeStoreMismatchesName   = metricACMEPrefix + "store_mismatches_total"
+	acmeStoreSkippedName      = metricACMEPrefix + "store_skipped_writes_total"
+	acmeCertExpiryName        = metricACMEPrefix + "certificate_expiry_timestamp_seconds"
+	acmeCertVerificationName  = metricACMEPrefix + "certificate_verification_failures_total"
+	acmeStoreCanaryName       = metricACMEPrefix + "store_canary"
 )
 
 // promState holds all metric state internally and acts as the only Collector we register for Prometheus.
@@ -140,6 +167,109 @@ func initStandardRegistry(config *types.Prometheus) Registry {
 		Help: "Backend server is up, described by gauge value of 0 or 1.",
 	}, []string{"backend", "url"})
 
+	acmeChallenges := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeChallengesTotalName,
+		Help: "How many ACME challenges were created, solved, failed or expired, partitioned by challenge type and outcome.",
+	}, []string{"type", "outcome"})
+	acmePendingChallenges := newGaugeFrom(promState.collectors, stdprometheus.GaugeOpts{
+		Name: acmePendingChallengesName,
+		Help: "How many ACME challenges are pending in the storage, partitioned by challenge type.",
+	}, []string{"type"})
+	acmeSignatureFailures := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeSignatureFailuresName,
+		Help: "How many times the ACME storage was loaded with a missing or an invalid signature, partitioned by reason.",
+	}, []string{"reason"})
+	acmeStoreK8sReads := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeStoreK8sReadsName,
+		Help: "How many lists of the ACME Kubernetes objects were read, partitioned by object and by source (cache or direct).",
+	}, []string{"object", "source"})
+	acmeStoreReplLag := newGaugeFrom(promState.collectors, stdprometheus.GaugeOpts{
+		Name: acmeStoreReplLagName,
+		Help: "How many seconds the certificate Secrets of a replica are behind the last save of the ACME store, partitioned by replica.",
+	}, []string{"replica"})
+	acmeStoreDegraded := newGaugeFrom(promState.collectors, stdprometheus.GaugeOpts{
+		Name: acmeStoreDegradedName,
+		Help: "Whether the writes of the ACME store are suspended after consecutive failures (1) or not (0), partitioned by backend.",
+	}, []string{"backend"})
+	acmeStoreMismatches := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeStoreMismatchesName,
+		Help: "How many sections of the ACME store differed from the data in memory and were written again, partitioned by backend and section.",
+	}, []string{"backend", "section"})
+	acmeStoreSkipped := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeStoreSkippedName,
+		Help: "How many writes of the ACME store were skipped as the data was already written, partitioned by backend.",
+	}, []string{"backend"})
+	acmeCertExpiry := newGaugeFrom(promState.collectors, stdprometheus.GaugeOpts{
+		Name: acmeCertExpiryName,
+		Help: "When the ACME certificates expire, in Unix time, partitioned by main domain and by whether their renewal is paused.",
+	}, []string{"domain", "renewal_paused"})
+	acmeCertVerification := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeCertVerificationName,
+		Help: "How many issued ACME certificates failed their verification before being stored, partitioned by reason.",
+	}, []string{"reason"})
+	acmeStoreCanary := newGaugeFrom(promState.collectors, stdprometheus.GaugeOpts{
+		Name: acmeStoreCanaryName,
+		Help: "Whether the startup self-test of the ACME store passed (1) or failed (0), partitioned by backend.",
+	}, []string{"backend"})
+	acmeStoreOperations := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeStoreOperationsName,
+		Help: "How many ACME store loads and saves happened, partitioned by backend and operation.",
+	}, []string{"backend", "operation"})
+	acmeStoreFailures := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeStoreFailuresName,
+		Help: "How many ACME store loads and saves failed, partitioned by backend and operation.",
+	}, []string{"backend", "operation"})
+	acmeStoreSaveDurations := newHistogramFrom(promState.collectors, stdprometheus.HistogramOpts{
+		Name:    acmeStoreSaveDurationName,
+		Help:    "How long it took to save the ACME store, partitioned by backend.",
+		Buckets: stdprometheus.DefBuckets,
+	}, []string{"backend"})
+	acmeStoreLastSave := newGaugeFrom(promState.collectors, stdprometheus.GaugeOpts{
+		Name: acmeStoreLastSaveName,
+		Help: "How many seconds elapsed since the last successful save of the ACME store, partitioned by backend.",
+	}, []string{"backend"})
+	// The issuances take from seconds to minutes, longer than the default buckets
+	acmeIssuanceBuckets := []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+	acmeIssuanceDurations := newHistogramFrom(promState.collectors, stdprometheus.HistogramOpts{
+		Name:    acmeIssuanceDurationName,
+		Help:    "How long the successful ACME certificate issuances and renewals took, partitioned by challenge type and phase.",
+		Buckets: acmeIssuanceBuckets,
+	}, []string{"type", "phase"})
+	acmeIssuanceFailures := newHistogramFrom(promState.collectors, stdprometheus.HistogramOpts{
+		Name:    acmeIssuanceFailureName,
+		Help:    "How long the failed ACME certificate issuances and renewals took, partitioned by challenge type and phase.",
+		Buckets: acmeIssuanceBuckets,
+	}, []string{"type", "phase"})
+	acmeHookFailures := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeHookFailuresName,
+		Help: "How many ACME deploy hooks failed or timed out.",
+	}, []string{})
+	acmeStorePayloadSize := newGaugeFrom(promState.collectors, stdprometheus.GaugeOpts{
+		Name: acmeStorePayloadSizeName,
+		Help: "The size in bytes of the last successfully saved ACME store payload, partitioned by backend and stage.",
+	}, []string{"backend", "stage"})
+	acmeStoreRejected := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeStoreRejectedName,
+		Help: "How many ACME store writes were rejected by the backend for their size, partitioned by backend.",
+	}, []string{"backend"})
+	acmeStorePanics := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeStorePanicsName,
+		Help: "How many panics were recovered in the ACME store save loop, partitioned by backend.",
+	}, []string{"backend"})
+	acmeStorageDrift := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeStorageDriftName,
+		Help: "How many differences were found between the ACME storage and the memory, partitioned by backend and kind.",
+	}, []string{"backend", "kind"})
+	acmeStoreCoalesced := newHistogramFrom(promState.collectors, stdprometheus.HistogramOpts{
+		Name:    acmeStoreCoalescedName,
+		Help:    "How many ACME store updates were coalesced into a single write, partitioned by backend.",
+		Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200},
+	}, []string{"backend"})
+	acmeCTFailures := newCounterFrom(promState.collectors, stdprometheus.CounterOpts{
+		Name: acmeCTFailuresName,
+		Help: "How many issued ACME certificates have no valid Certificate Transparency SCT, partitioned by reason.",
+	}, []string{"reason"})
+
 	promState.describers = []func(chan<- *stdprometheus.Desc){
 		configReloads.cv.Describe,
 		configReloadsFailures.cv.Describe,
@@ -153,6 +283,30 @@ func initStandardRegistry(config *types.Prometheus) Registry {
 		backendOpenConns.gv.Describe,
 		backendRetries.cv.Describe,
 		backendServerUp.gv.Describe,
+		acmeChallenges.cv.Describe,
+		acmePendingChallenges.gv.Describe,
+		acmeSignatureFailures.cv.Describe,
+		acmeStoreOperations.cv.Describe,
+		acmeStoreFailures.cv.Describe,
+		acmeStoreSaveDurations.hv.Describe,
+		acmeStoreLastSave.gv.Describe,
+		acmeIssuanceDurations.hv.Describe,
+		acmeIssuanceFailures.hv.Describe,
+		acmeHookFailures.cv.Describe,
+		acmeStorePayloadSize.gv.Describe,
+		acmeStoreRejected.cv.Describe,
+		acmeStorePanics.cv.Describe,
+		acmeStorageDrift.cv.Describe,
+		acmeStoreCoalesced.hv.Describe,
+		acmeCTFailures.cv.Describe,
+		acmeStoreK8sReads.cv.Describe,
+		acmeStoreReplLag.gv.Describe,
+		acmeStoreDegraded.gv.Describe,
+		acmeStoreMismatches.cv.Describe,
+		acmeStoreSkipped.cv.Describe,
+		acmeCertExpiry.gv.Describe,
+		acmeCertVerification.cv.Describe,
+		acmeStoreCanary.gv.Describe,
 	}
 
 	return &standardRegistry{
@@ -169,6 +323,30 @@ func initStandardRegistry(config *types.Prometheus) Registry {
 		backendOpenConnsGauge:          backendOpenConns,
 		backendRetriesCounter:          backendRetries,
 		backendServerUpGauge:           backendServerUp,
+		acmeChallengesCounter:          acmeChallenges,
+		acmePendingChallengesGauge:     acmePendingChallenges,
+		acmeSignatureFailuresCounter:   acmeSignatureFailures,
+		acmeStoreOperationsCounter:     acmeStoreOperations,
+		acmeStoreFailuresCounter:       acmeStoreFailures,
+		acmeStoreSaveDurationHistogram: acmeStoreSaveDurations,
+		acmeStoreLastSaveGauge:         acmeStoreLastSave,
+		acmeIssuanceDurationHistogram:  acmeIssuanceDurations,
+		acmeIssuanceFailureHistogram:   acmeIssuanceFailures,
+		acmeHookFailuresCounter:        acmeHookFailures,
+		acmeStorePayloadSizeGauge:      acmeStorePayloadSize,
+		acmeStoreRejectedWritesCounter: acmeStoreRejected,
+		acmeStorePanicsCounter:         acmeStorePanics,
+		acmeStorageDriftCounter:        acmeStorageDrift,
+		acmeStoreCoalescedHistogram:    acmeStoreCoalesced,
+		acmeCTFailuresCounter:          acmeCTFailures,
+		acmeStoreK8sReadsCounter:       acmeStoreK8sReads,
+		acmeStoreReplLagGauge:          acmeStoreReplLag,
+		acmeStoreDegradedGauge:         acmeStoreDegraded,
+		acmeStoreMismatchesCounter:     acmeStoreMismatches,
+		acmeStoreSkippedCounter:        acmeStoreSkipped,
+		acmeCertExpiryGauge:            acmeCertExpiry,
+		acmeCertVerificationCounter:    acmeCertVerification,
+		acmeStoreCanaryGauge:           acmeStoreCanary,
 	}
 }
 
@@ -147,6 +147,102 @@ func TestPrometheus(t *testing.T) {
 		With("backend", "backend1", "url", "http://127.0.0.10:80").
 		Set(1)
 
+	prometheusRegistry.
+		ACMEChallengesCounter().
+		With("type", "http-01", "outcome", "created").
+		Add(1)
+	prometheusRegistry.
+		ACMEPendingChallengesGauge().
+		With("type", "http-01").
+		Set(1)
+	prometheusRegistry.
+		ACMESignatureFailuresCounter().
+		With("reason", "invalid").
+		Add(1)
+	prometheusRegistry.
+		ACMEStoreOperationsCounter().
+		With("backend", "file", "operation", "save").
+		Add(1)
+	prometheusRegistry.
+		ACMEStoreFailuresCounter().
+		With("backend", "file", "operation", "save").
+		Add(1)
+	prometheusRegistry.
+		ACMEStoreSaveDurationHistogram().
+		With("backend", "file").
+		Observe(1)
+	prometheusRegistry.
+		ACMEStoreLastSaveGauge().
+		With("backend", "file").
+		Set(1)
+	prometheusRegistry.
+		ACMEIssuanceDurationHistogram().
+		With("type", "http-01", "phase", "total").
+		Observe(1)
+	prometheusRegistry.
+		ACMEIssuanceFailureHistogram().
+		With("type", "http-01", "phase", "total").
+		Observe(1)
+	prometheusRegistry.
+		ACMEHookFailuresCounter().
+		Add(1)
+	prometheusRegistry.
+		ACMEStorePayloadSizeGauge().
+		With("backend", "file", "stage", "serialized").
+		Set(1024)
+	prometheusRegistry.
+		ACMEStoreRejectedWritesCounter().
+		With("backend", "file").
+		Add(1)
+	prometheusRegistry.
+		ACMEStorePanicsCounter().
+		With("backend", "file").
+		Add(1)
+	prometheusRegistry.
+		ACMEStorageDriftCounter().
+		With("backend", "file", "kind", "added").
+		Add(1)
+	prometheusRegistry.
+		ACMEStoreCoalescedUpdatesHistogram().
+		With("backend", "file").
+		Observe(3)
+	prometheusRegistry.
+		ACMECTFailuresCounter().
+		With("reason", "missing").
+		Add(1)
+	prometheusRegistry.
+		ACMEStoreKubernetesReadsCounter().
+		With("object", "secrets", "source", "cache").
+		Add(1)
+	prometheusRegistry.
+		ACMEStoreReplicationLagGauge().
+		With("replica", "passive").
+		Set(1)
+	prometheusRegistry.
+		ACMEStoreDegradedGauge().
+		With("backend", "file").
+		Set(1)
+	prometheusRegistry.
+		ACMEStoreMismatchesCounter().
+		With("backend", "file", "section", "account").
+		Add(1)
+	prometheusRegistry.
+		ACMEStoreSkippedWritesCounter().
+		With("backend", "file").
+		Add(1)
+	prometheusRegistry.
+		ACMECertificateExpiryGauge().
+		With("domain", "traefik.wtf", "renewal_paused", "false").
+		Set(1)
+	prometheusRegistry.
+		ACMECertificateVerificationFailuresCounter().
+		With("reason", "chain").
+		Add(1)
+	prometheusRegistry.
+		ACMEStoreCanaryGauge().
+		With("backend", "file").
+		Set(1)
+
 	delayForTrackingCompletion()
 
 	metricsFamilies := mustScrape()
@@ -245,6 +341,181 @@ func TestPrometheus(t *testing.T) {
 			},
 			assert: buildGaugeAssert(t, backendServerUpName, 1),
 		},
+		{
+			name: acmeChallengesTotalName,
+			labels: map[string]string{
+				"type":    "http-01",
+				"outcome": "created",
+			},
+			assert: buildCounterAssert(t, acmeChallengesTotalName, 1),
+		},
+		{
+			name: acmePendingChallengesName,
+			labels: map[string]string{
+				"type": "http-01",
+			},
+			assert: buildGaugeAssert(t, acmePendingChallengesName, 1),
+		},
+		{
+			name: acmeSignatureFailuresName,
+			labels: map[string]string{
+				"reason": "invalid",
+			},
+			assert: buildCounterAssert(t, acmeSignatureFailuresName, 1),
+		},
+		{
+			name: acmeStoreOperationsName,
+			labels: map[string]string{
+				"backend":   "file",
+				"operation": "save",
+			},
+			assert: buildCounterAssert(t, acmeStoreOperationsName, 1),
+		},
+		{
+			name: acmeStoreFailuresName,
+			labels: map[string]string{
+				"backend":   "file",
+				"operation": "save",
+			},
+			assert: buildCounterAssert(t, acmeStoreFailuresName, 1),
+		},
+		{
+			name: acmeStoreSaveDurationName,
+			labels: map[string]string{
+				"backend": "file",
+			},
+			assert: buildHistogramAssert(t, acmeStoreSaveDurationName, 1),
+		},
+		{
+			name: acmeStoreLastSaveName,
+			labels: map[string]string{
+				"backend": "file",
+			},
+			assert: buildGaugeAssert(t, acmeStoreLastSaveName, 1),
+		},
+		{
+			name: acmeIssuanceDurationName,
+			labels: map[string]string{
+				"type":  "http-01",
+				"phase": "total",
+			},
+			assert: buildHistogramAssert(t, acmeIssuanceDurationName, 1),
+		},
+		{
+			name: acmeIssuanceFailureName,
+			labels: map[string]string{
+				"type":  "http-01",
+				"phase": "total",
+			},
+			assert: buildHistogramAssert(t, acmeIssuanceFailureName, 1),
+		},
+		{
+			name:   acmeHookFailuresName,
+			assert: buildCounterAssert(t, acmeHookFailuresName, 1),
+		},
+		{
+			name: acmeStorePayloadSizeName,
+			labels: map[string]string{
+				"backend": "file",
+				"stage":   "serialized",
+			},
+			assert: buildGaugeAssert(t, acmeStorePayloadSizeName, 1024),
+		},
+		{
+			name: acmeStoreRejectedName,
+			labels: map[string]string{
+				"backend": "file",
+			},
+			assert: buildCounterAssert(t, acmeStoreRejectedName, 1),
+		},
+		{
+			name: acmeStorePanicsName,
+			labels: map[string]string{
+				"backend": "file",
+			},
+			assert: buildCounterAssert(t, acmeStorePanicsName, 1),
+		},
+		{
+			name: acmeStorageDriftName,
+			labels: map[string]string{
+				"backend": "file",
+				"kind":    "added",
+			},
+			assert: buildCounterAssert(t, acmeStorageDriftName, 1),
+		},
+		{
+			name: acmeStoreCoalescedName,
+			labels: map[string]string{
+				"backend": "file",
+			},
+			assert: buildHistogramAssert(t, acmeStoreCoalescedName, 1),
+		},
+		{
+			name: acmeCTFailuresName,
+			labels: map[string]string{
+				"reason": "missing",
+			},
+			assert: buildCounterAssert(t, acmeCTFailuresName, 1),
+		},
+		{
+			name: acmeStoreK8sReadsName,
+			labels: map[string]string{
+				"object": "secrets",
+				"source": "cache",
+			},
+			assert: buildCounterAssert(t, acmeStoreK8sReadsName, 1),
+		},
+		{
+			name: acmeStoreReplLagName,
+			labels: map[string]string{
+				"replica": "passive",
+			},
+			assert: buildGaugeAssert(t, acmeStoreReplLagName, 1),
+		},
+		{
+			name: acmeStoreDegradedName,
+			labels: map[string]string{
+				"backend": "file",
+			},
+			assert: buildGaugeAssert(t, acmeStoreDegradedName, 1),
+		},
+		{
+			name: acmeStoreMismatchesName,
+			labels: map[string]string{
+				"backend": "file",
+				"section": "account",
+			},
+			assert: buildCounterAssert(t, acmeStoreMismatchesName, 1),
+		},
+		{
+			name: acmeStoreSkippedName,
+			labels: map[string]string{
+				"backend": "file",
+			},
+			assert: buildCounterAssert(t, acmeStoreSkippedName, 1),
+		},
+		{
+			name: acmeCertExpiryName,
+			labels: map[string]string{
+				"domain":         "traefik.wtf",
+				"renewal_paused": "false",
+			},
+			assert: buildGaugeAssert(t, acmeCertExpiryName, 1),
+		},
+		{
+			name: acmeCertVerificationName,
+			labels: map[string]string{
+				"reason": "chain",
+			},
+			assert: buildCounterAssert(t, acmeCertVerificationName, 1),
+		},
+		{
+			name: acmeStoreCanaryName,
+			labels: map[string]string{
+				"backend": "file",
+			},
+			assert: buildGaugeAssert(t, acmeStoreCanaryName, 1),
+		},
 	}
 
 	for _, test := range tests {
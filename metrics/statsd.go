@@ -30,6 +30,30 @@ const (
 	statsdEntrypointOpenConnsName     = "entrypoint.connections.open"
 	statsdOpenConnsName               = "backend.connections.open"
 	statsdServerUpName                = "backend.server.up"
+	statsdACMEChallengesName          = "acme.challenges.total"
+	statsdACMEPendingChallengesName   = "acme.challenges.pending"
+	statsdACMESignatureFailuresName   = "acme.storage.signature.failures.total"
+	statsdACMEStoreOperationsName     = "acme.store.operations.total"
+	statsdACMEStoreFailuresName       = "acme.store.operation.failures.total"
+	statsdACMEStoreSaveDurationName   = "acme.store.save.duration"
+	statsdACMEStoreLastSaveName       = "acme.store.seconds.since.last.save"
+	statsdACMEIssuanceDurationName    = "acme.issuance.duration"
+	statsdACMEIssuanceFailureName     = "acme.issuance.failure.duration"
+	statsdACMEHookFailuresName        = "acme.deploy.hook.failures.total"
+	statsdACMEStorePayloadSizeName    = "acme.store.payload.size"
+	statsdACMEStoreRejectedName       = "acme.store.rejected.writes.total"
+	statsdACMEStorePanicsName         = "acme.store.panics.total"
+	statsdACMEStorageDriftName        = "acme.storage.drift.total"
+	statsdACMEStoreCoalescedName      = "acme.store.coalesced.updates"
+	statsdACMECTFailuresName          = "acme.certificate.transparency.failures.total"
+	statsdACMEStoreK8sReadsName       = "acme.store.kubernetes.reads.total"
+	statsdACMEStoreReplLagName        = "acme.store.replication.lag"
+	statsdACMEStoreDegradedName       = "acme.store.degraded"
+	statsdACMEStoreMismatchesName     = "acme.store.mismatches.total"
+	statsdACMEStoreSkippedName        = "acme.store.skipped.writes.total"
+	statsdACMECertExpiryName          = "acme.certificate.expiry"
+	statsdACMECertVerificationName    = "acme.certificate.verification.failures.total"
+	statsdACMEStoreCanaryName         = "acme.store.canary"
 )
 
 // RegisterStatsd registers the metrics pusher if this didn't happen yet and creates a statsd Registry instance.
@@ -52,6 +76,30 @@ func RegisterStatsd(config *types.Statsd) Registry {
 		backendRetriesCounter:          statsdClient.NewCounter(statsdRetriesTotalName, 1.0),
 		backendOpenConnsGauge:          statsdClient.NewGauge(statsdOpenConnsName),
 		backendServerUpGauge:           statsdClient.NewGauge(statsdServerUpName),
+		acmeChallengesCounter:          statsdClient.NewCounter(statsdACMEChallengesName, 1.0),
+		acmePendingChallengesGauge:     statsdClient.NewGauge(statsdACMEPendingChallengesName),
+		acmeSignatureFailuresCounter:   statsdClient.NewCounter(statsdACMESignatureFailuresName, 1.0),
+		acmeStoreOperationsCounter:     statsdClient.NewCounter(statsdACMEStoreOperationsName, 1.0),
+		acmeStoreFailuresCounter:       statsdClient.NewCounter(statsdACMEStoreFailuresName, 1.0),
+		acmeStoreSaveDurationHistogram: statsdClient.NewTiming(statsdACMEStoreSaveDurationName, 1.0),
+		acmeStoreLastSaveGauge:         statsdClient.NewGauge(statsdACMEStoreLastSaveName),
+		acmeIssuanceDurationHistogram:  statsdClient.NewTiming(statsdACMEIssuanceDurationName, 1.0),
+		acmeIssuanceFailureHistogram:   statsdClient.NewTiming(statsdACMEIssuanceFailureName, 1.0),
+		acmeHookFailuresCounter:        statsdClient.NewCounter(statsdACMEHookFailuresName, 1.0),
+		acmeStorePayloadSizeGauge:      statsdClient.NewGauge(statsdACMEStorePayloadSizeName),
+		acmeStoreRejectedWritesCounter: statsdClient.NewCounter(statsdACMEStoreRejectedName, 1.0),
+		acmeStorePanicsCounter:         statsdClient.NewCounter(statsdACMEStorePanicsName, 1.0),
+		acmeStorageDriftCounter:        statsdClient.NewCounter(statsdACMEStorageDriftName, 1.0),
+		acmeStoreCoalescedHistogram:    statsdClient.NewTiming(statsdACMEStoreCoalescedName, 1.0),
+		acmeCTFailuresCounter:          statsdClient.NewCounter(statsdACMECTFailuresName, 1.0),
+		acmeStoreK8sReadsCounter:       statsdClient.NewCounter(statsdACMEStoreK8sReadsName, 1.0),
+		acmeStoreReplLagGauge:          statsdClient.NewGauge(statsdACMEStoreReplLagName),
+		acmeStoreDegradedGauge:         statsdClient.NewGauge(statsdACMEStoreDegradedName),
+		acmeStoreMismatchesCounter:     statsdClient.NewCounter(statsdACMEStoreMismatchesName, 1.0),
+		acmeStoreSkippedCounter:        statsdClient.NewCounter(statsdACMEStoreSkippedName, 1.0),
+		acmeCertExpiryGauge:            statsdClient.NewGauge(statsdACMECertExpiryName),
+		acmeCertVerificationCounter:    statsdClient.NewCounter(statsdACMECertVerificationName, 1.0),
+		acmeStoreCanaryGauge:           statsdClient.NewGauge(statsdACMEStoreCanaryName),
 	}
 }
 
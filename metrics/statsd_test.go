@@ -32,6 +32,30 @@ func TestStatsD(t *testing.T) {
 		"traefik.entrypoint.request.duration:10000.000000|ms",
 		"traefik.entrypoint.connections.open:1.000000|g\n",
 		"traefik.backend.server.up:1.000000|g\n",
+		"traefik.acme.challenges.total:1.000000|c\n",
+		"traefik.acme.challenges.pending:1.000000|g\n",
+		"traefik.acme.storage.signature.failures.total:1.000000|c\n",
+		"traefik.acme.store.operations.total:1.000000|c\n",
+		"traefik.acme.store.operation.failures.total:1.000000|c\n",
+		"traefik.acme.store.save.duration:10000.000000|ms",
+		"traefik.acme.store.seconds.since.last.save:1.000000|g\n",
+		"traefik.acme.issuance.duration:10000.000000|ms",
+		"traefik.acme.issuance.failure.duration:10000.000000|ms",
+		"traefik.acme.deploy.hook.failures.total:1.000000|c\n",
+		"traefik.acme.store.payload.size:1024.000000|g\n",
+		"traefik.acme.store.rejected.writes.total:1.000000|c\n",
+		"traefik.acme.store.panics.total:1.000000|c\n",
+		"traefik.acme.storage.drift.total:1.000000|c\n",
+		"traefik.acme.store.coalesced.updates:3.000000|ms",
+		"traefik.acme.certificate.transparency.failures.total:1.000000|c\n",
+		"traefik.acme.store.kubernetes.reads.total:1.000000|c\n",
+		"traefik.acme.store.replication.lag:1.000000|g\n",
+		"traefik.acme.store.degraded:1.000000|g\n",
+		"traefik.acme.store.mismatches.total:1.000000|c\n",
+		"traefik.acme.store.skipped.writes.total:1.000000|c\n",
+		"traefik.acme.certificate.expiry:1.000000|g\n",
+		"traefik.acme.certificate.verification.failures.total:1.000000|c\n",
+		"traefik.acme.store.canary:1.000000|g\n",
 	}
 
 	udp.ShouldReceiveAll(t, expected, func() {
@@ -46,5 +70,29 @@ func TestStatsD(t *testing.T) {
 		statsdRegistry.EntrypointReqDurationHistogram().With("entrypoint", "test").Observe(10000)
 		statsdRegistry.EntrypointOpenConnsGauge().With("entrypoint", "test").Set(1)
 		statsdRegistry.BackendServerUpGauge().With("backend:test", "url", "http://127.0.0.1").Set(1)
+		statsdRegistry.ACMEChallengesCounter().With("type", "http-01", "outcome", "created").Add(1)
+		statsdRegistry.ACMEPendingChallengesGauge().With("type", "http-01").Set(1)
+		statsdRegistry.ACMESignatureFailuresCounter().With("reason", "invalid").Add(1)
+		statsdRegistry.ACMEStoreOperationsCounter().With("backend", "file", "operation", "save").Add(1)
+		statsdRegistry.ACMEStoreFailuresCounter().With("backend", "file", "operation", "save").Add(1)
+		statsdRegistry.ACMEStoreSaveDurationHistogram().With("backend", "file").Observe(10000)
+		statsdRegistry.ACMEStoreLastSaveGauge().With("backend", "file").Set(1)
+		statsdRegistry.ACMEIssuanceDurationHistogram().With("type", "http-01", "phase", "total").Observe(10000)
+		statsdRegistry.ACMEIssuanceFailureHistogram().With("type", "http-01", "phase", "total").Observe(10000)
+		statsdRegistry.ACMEHookFailuresCounter().Add(1)
+		statsdRegistry.ACMEStorePayloadSizeGauge().With("backend", "file", "stage", "serialized").Set(1024)
+		statsdRegistry.ACMEStoreRejectedWritesCounter().With("backend", "file").Add(1)
+		statsdRegistry.ACMEStorePanicsCounter().With("backend", "file").Add(1)
+		statsdRegistry.ACMEStorageDriftCounter().With("backend", "file", "kind", "added").Add(1)
+		statsdRegistry.ACMEStoreCoalescedUpdatesHistogram().With("backend", "file").Observe(3)
+		statsdRegistry.ACMECTFailuresCounter().With("reason", "missing").Add(1)
+		statsdRegistry.ACMEStoreKubernetesReadsCounter().With("object", "secrets", "source", "cache").Add(1)
+		statsdRegistry.ACMEStoreReplicationLagGauge().With("replica", "passive").Set(1)
+		statsdRegistry.ACMEStoreDegradedGauge().With("backend", "file").Set(1)
+		statsdRegistry.ACMEStoreMismatchesCounter().With("backend", "file", "section", "account").Add(1)
+		statsdRegistry.ACMEStoreSkippedWritesCounter().With("backend", "file").Add(1)
+		statsdRegistry.ACMECertificateExpiryGauge().With("domain", "traefik.wtf", "renewal_paused", "false").Set(1)
+		statsdRegistry.ACMECertificateVerificationFailuresCounter().With("reason", "chain").Add(1)
+		statsdRegistry.ACMEStoreCanaryGauge().With("backend", "file").Set(1)
 	})
 }
@@ -12,6 +12,7 @@ import (
 type Handler struct {
 	EntryPoint  string `description:"Ping entryPoint" export:"true"`
 	terminating bool
+	checks      []func() error
 }
 
 // WithContext causes the ping endpoint to serve non 200 responses.
@@ -22,6 +23,12 @@ func (h *Handler) WithContext(ctx context.Context) {
 	}()
 }
 
+// AddCheck adds a check causing the ping endpoint to serve non 200 responses when it fails.
+// The checks must be added before the routes are served.
+func (h *Handler) AddCheck(check func() error) {
+	h.checks = append(h.checks, check)
+}
+
 // AddRoutes add ping routes on a router
 func (h *Handler) AddRoutes(router *mux.Router) {
 	router.Methods(http.MethodGet, http.MethodHead).Path("/ping").
@@ -30,7 +37,16 @@ func (h *Handler) AddRoutes(router *mux.Router) {
 			if h.terminating {
 				statusCode = http.StatusServiceUnavailable
 			}
+
+			message := http.StatusText(statusCode)
+			for _, check := range h.checks {
+				if err := check(); err != nil && statusCode == http.StatusOK {
+					statusCode = http.StatusServiceUnavailable
+					message = fmt.Sprintf("%s: %v", http.StatusText(statusCode), err)
+				}
+			}
+
 			response.WriteHeader(statusCode)
-			fmt.Fprint(response, http.StatusText(statusCode))
+			fmt.Fprint(response, message)
 		})
 }
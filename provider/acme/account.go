@@ -2,20 +2,27 @@ package acme
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
 
-	"github.com/containous/traefik/log"
 	"github.com/xenolf/lego/acme"
 )
 
 // Account is used to store lets encrypt registration info
 type Account struct {
-	Email        string
-	Registration *acme.RegistrationResource
-	PrivateKey   []byte
-	KeyType      acme.KeyType
+	Email               string
+	Registration        *acme.RegistrationResource
+	PrivateKey          []byte
+	EncryptedPrivateKey *encryptedField `json:",omitempty"`
+	PrivateKeyType      acme.KeyType
+	KeyType             acme.KeyType
+	RegisteredAt        *time.Time `json:",omitempty"`
 }
 
 const (
@@ -24,19 +31,21 @@ const (
 )
 
 // NewAccount creates an account
-func NewAccount(email string, keyTypeValue string) (*Account, error) {
+func NewAccount(email string, keyTypeValue string, accountKeyTypeValue string) (*Account, error) {
 	keyType := GetKeyType(keyTypeValue)
+	accountKeyType := GetAccountKeyType(accountKeyTypeValue)
 
 	// Create a user. New accounts need an email and private key to start
-	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	privateKey, err := generateAccountPrivateKey(accountKeyType)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Account{
-		Email:      email,
-		PrivateKey: x509.MarshalPKCS1PrivateKey(privateKey),
-		KeyType:    keyType,
+		Email:          email,
+		PrivateKey:     privateKey,
+		PrivateKeyType: accountKeyType,
+		KeyType:        keyType,
 	}, nil
 }
 
@@ -52,14 +61,62 @@ func (a *Account) GetRegistration() *acme.RegistrationResource {
 
 // GetPrivateKey returns private key
 func (a *Account) GetPrivateKey() crypto.PrivateKey {
-	if privateKey, err := x509.ParsePKCS1PrivateKey(a.PrivateKey); err == nil {
-		return privateKey
+	switch a.PrivateKeyType {
+	case acme.EC256, acme.EC384:
+		if privateKey, err := x509.ParseECPrivateKey(a.PrivateKey); err == nil {
+			return privateKey
+		}
+	default:
+		if privateKey, err := x509.ParsePKCS1PrivateKey(a.PrivateKey); err == nil {
+			return privateKey
+		}
+		// The type of the EC keys on other curves is left empty
+		if privateKey, err := x509.ParseECPrivateKey(a.PrivateKey); err == nil && len(a.PrivateKeyType) == 0 {
+			return privateKey
+		}
 	}
 
-	log.Errorf("Cannot unmarshal private key %+v", a.PrivateKey)
+	logger().Errorf("Cannot unmarshal private key of type %q", a.PrivateKeyType)
 	return nil
 }
 
+// copyAccount returns a copy of the account, which does not share its registration nor its private key
+func copyAccount(account *Account) *Account {
+	if account == nil {
+		return nil
+	}
+
+	accountCopy := *account
+	accountCopy.PrivateKey = append([]byte(nil), account.PrivateKey...)
+
+	if account.Registration != nil {
+		registration := *account.Registration
+		registration.Body.Contact = append([]string(nil), account.Registration.Body.Contact...)
+		registration.Body.ExternalAccountBinding = append([]byte(nil), account.Registration.Body.ExternalAccountBinding...)
+		accountCopy.Registration = &registration
+	}
+
+	if account.RegisteredAt != nil {
+		registeredAt := *account.RegisteredAt
+		accountCopy.RegisteredAt = &registeredAt
+	}
+
+	return &accountCopy
+}
+
+// copyCertificates returns deep copies of the certificates
+func copyCertificates(certificates []*Certificate) []*Certificate {
+	if certificates == nil {
+		return nil
+	}
+
+	certificatesCopy := make([]*Certificate, 0, len(certificates))
+	for _, certificate := range certificates {
+		certificatesCopy = append(certificatesCopy, copyCertificate(certificate))
+	}
+	return certificatesCopy
+}
+
 // GetKeyType used to determine which algo to used
 func GetKeyType(value string) acme.KeyType {
 	switch value {
@@ -74,10 +131,89 @@ func GetKeyType(value string) acme.KeyType {
 	case "RSA8192":
 		return acme.RSA8192
 	case "":
-		log.Infof("The key type is empty. Use default key type %v.", acme.RSA4096)
+		logger().Infof("The key type is empty. Use default key type %v.", acme.RSA4096)
 		return acme.RSA4096
 	default:
-		log.Infof("Unable to determine key type value %q. Use default key type %v.", value, acme.RSA4096)
+		logger().Infof("Unable to determine key type value %q. Use default key type %v.", value, acme.RSA4096)
 		return acme.RSA4096
 	}
 }
+
+// GetAccountKeyType used to determine which algo to use for the account private key
+func GetAccountKeyType(value string) acme.KeyType {
+	switch value {
+	case "EC256":
+		return acme.EC256
+	case "EC384":
+		return acme.EC384
+	case "RSA2048":
+		return acme.RSA2048
+	case "RSA4096":
+		return acme.RSA4096
+	case "":
+		return acme.RSA4096
+	default:
+		logger().Infof("Unable to determine account key type value %q. Use default account key type %v.", value, acme.RSA4096)
+		return acme.RSA4096
+	}
+}
+
+// generateAccountPrivateKey generates a new account private key and returns its DER encoding
+// RSA keys are encoded in PKCS1 to stay compatible with existing storage
+func generateAccountPrivateKey(keyType acme.KeyType) ([]byte, error) {
+	switch keyType {
+	case acme.EC256, acme.EC384:
+		curve := elliptic.P256()
+		if keyType == acme.EC384 {
+			curve = elliptic.P384()
+		}
+
+		privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalECPrivateKey(privateKey)
+	case acme.RSA2048, acme.RSA4096:
+		bits := 4096
+		if keyType == acme.RSA2048 {
+			bits = 2048
+		}
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKCS1PrivateKey(privateKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported account key type %q", keyType)
+	}
+}
+
+// inferPrivateKeyType determines the key type of a DER encoded account private key
+func inferPrivateKeyType(der []byte) (acme.KeyType, error) {
+	if privateKey, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		switch privateKey.N.BitLen() {
+		case 2048:
+			return acme.RSA2048, nil
+		case 4096:
+			return acme.RSA4096, nil
+		case 8192:
+			return acme.RSA8192, nil
+		default:
+			return "", fmt.Errorf("unsupported RSA account key size %d", privateKey.N.BitLen())
+		}
+	}
+
+	if privateKey, err := x509.ParseECPrivateKey(der); err == nil {
+		switch privateKey.Curve {
+		case elliptic.P256():
+			return acme.EC256, nil
+		case elliptic.P384():
+			return acme.EC384, nil
+		default:
+			return "", fmt.Errorf("unsupported EC account key curve %s", privateKey.Curve.Params().Name)
+		}
+	}
+
+	return "", errors.New("unable to determine the account private key type")
+}
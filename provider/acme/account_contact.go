@@ -0,0 +1,128 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/xenolf/lego/acme"
+	"gopkg.in/square/go-jose.v2"
+)
+
+type staticNonce string
+
+// Nonce implements jose.NonceSource
+func (n staticNonce) Nonce() (string, error) {
+	return string(n), nil
+}
+
+// updateAccountContact updates the contact of the registered ACME account with the given email
+func updateAccountContact(caServer string, account *Account, email string) error {
+	if account.Registration == nil || len(account.Registration.URI) == 0 {
+		return errors.New("the ACME account is not registered")
+	}
+
+	resp, err := acme.HTTPClient.Get(caServer)
+	if err != nil {
+		return fmt.Errorf("unable to get the ACME directory: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var directory struct {
+		NewNonceURL string `json:"newNonce"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&directory); err != nil {
+		return fmt.Errorf("unable to decode the ACME directory: %v", err)
+	}
+
+	nonceResp, err := acme.HTTPClient.Head(directory.NewNonceURL)
+	if err != nil {
+		return fmt.Errorf("unable to get a nonce: %v", err)
+	}
+	nonceResp.Body.Close()
+
+	nonce := nonceResp.Header.Get("Replay-Nonce")
+	if len(nonce) == 0 {
+		return errors.New("the server did not respond with a nonce")
+	}
+
+	payload, err := json.Marshal(map[string][]string{"contact": {"mailto:" + email}})
+	if err != nil {
+		return err
+	}
+
+	signed, err := signAccountRequest(account, nonce, payload)
+	if err != nil {
+		return err
+	}
+
+	updateResp, err := acme.HTTPClient.Post(account.Registration.URI, "application/jose+json", bytes.NewBufferString(signed))
+	if err != nil {
+		return fmt.Errorf("unable to update the ACME account: %v", err)
+	}
+	defer updateResp.Body.Close()
+
+	body, err := ioutil.ReadAll(updateResp.Body)
+	if err != nil {
+		return err
+	}
+
+	if updateResp.StatusCode != http.StatusOK {
+		var problem struct {
+			Type   string `json:"type"`
+			Detail string `json:"detail"`
+		}
+		if json.Unmarshal(body, &problem) == nil && len(problem.Detail) > 0 {
+			return fmt.Errorf("the CA server rejected the account update (%d %s): %s", updateResp.StatusCode, problem.Type, problem.Detail)
+		}
+		return fmt.Errorf("the CA server rejected the account update with status code %d", updateResp.StatusCode)
+	}
+
+	if err = json.Unmarshal(body, &account.Registration.Body); err != nil {
+		return fmt.Errorf("unable to decode the updated ACME account: %v", err)
+	}
+
+	return nil
+}
+
+func signAccountRequest(account *Account, nonce string, payload []byte) (string, error) {
+	privateKey := account.GetPrivateKey()
+
+	var alg jose.SignatureAlgorithm
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		alg = jose.RS256
+	case *ecdsa.PrivateKey:
+		if key.Curve == elliptic.P384() {
+			alg = jose.ES384
+		} else {
+			alg = jose.ES256
+		}
+	default:
+		return "", errors.New("unsupported ACME account private key")
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: alg,
+		Key:       jose.JSONWebKey{Key: privateKey, KeyID: account.Registration.URI},
+	}, &jose.SignerOptions{
+		NonceSource:  staticNonce(nonce),
+		ExtraHeaders: map[jose.HeaderKey]interface{}{"url": account.Registration.URI},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to create the request signer: %v", err)
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign the request: %v", err)
+	}
+
+	return signed.FullSerialize(), nil
+}
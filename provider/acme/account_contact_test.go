@@ -0,0 +1,91 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestUpdateAccountContact(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		status          int
+		response        string
+		expectedError   bool
+		expectedContact []string
+	}{
+		{
+			desc:            "contact updated",
+			status:          http.StatusOK,
+			response:        `{"status":"valid","contact":["mailto:new@traefik.wtf"]}`,
+			expectedContact: []string{"mailto:new@traefik.wtf"},
+		},
+		{
+			desc:            "contact rejected",
+			status:          http.StatusBadRequest,
+			response:        `{"type":"urn:ietf:params:acme:error:invalidEmail","detail":"invalid email"}`,
+			expectedError:   true,
+			expectedContact: []string{"mailto:old@traefik.wtf"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			account, err := NewAccount("old@traefik.wtf", "EC256", "EC256")
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			account.Registration = &acme.RegistrationResource{URI: server.URL + "/acct/1"}
+			account.Registration.Body.Contact = []string{"mailto:old@traefik.wtf"}
+
+			mux.HandleFunc("/directory", func(rw http.ResponseWriter, req *http.Request) {
+				_, _ = rw.Write([]byte(`{"newNonce":"` + server.URL + `/nonce"}`))
+			})
+			mux.HandleFunc("/nonce", func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Replay-Nonce", "nonce")
+			})
+			mux.HandleFunc("/acct/1", func(rw http.ResponseWriter, req *http.Request) {
+				body, errRead := ioutil.ReadAll(req.Body)
+				require.NoError(t, errRead)
+
+				signed, errParse := jose.ParseSigned(string(body))
+				require.NoError(t, errParse)
+				require.Len(t, signed.Signatures, 1)
+				assert.Equal(t, server.URL+"/acct/1", signed.Signatures[0].Header.KeyID)
+				assert.Equal(t, "nonce", signed.Signatures[0].Header.Nonce)
+
+				payload, errVerify := signed.Verify(account.GetPrivateKey().(*ecdsa.PrivateKey).Public())
+				require.NoError(t, errVerify)
+
+				var update map[string][]string
+				require.NoError(t, json.Unmarshal(payload, &update))
+				assert.Equal(t, []string{"mailto:new@traefik.wtf"}, update["contact"])
+
+				rw.WriteHeader(test.status)
+				_, _ = rw.Write([]byte(test.response))
+			})
+
+			err = updateAccountContact(server.URL+"/directory", account, "new@traefik.wtf")
+			if test.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedContact, account.Registration.Body.Contact)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNoAccount is returned when no ACME account is registered yet
+var ErrNoAccount = errors.New("no ACME account is registered yet: the account is registered with the CA server when the first certificate is obtained")
+
+// AccountInfo describes the registered ACME account, without its private key
+type AccountInfo struct {
+	RegistrationURI string   `json:"registrationUri"`
+	Status          string   `json:"status,omitempty"`
+	Email           string   `json:"email,omitempty"`
+	Contact         []string `json:"contact,omitempty"`
+	KeyType         string   `json:"keyType,omitempty"`
+	// PublicKeyFingerprint is the SHA-256 fingerprint of the DER encoded public key of the account
+	PublicKeyFingerprint string     `json:"publicKeyFingerprint,omitempty"`
+	EABKeyID             string     `json:"eabKeyId,omitempty"`
+	CAServer             string     `json:"caServer"`
+	RegisteredAt         *time.Time `json:"registeredAt,omitempty"`
+}
+
+// GetAccountInfo returns the account registered in the store, ErrNoAccount when no account is registered yet
+func (p *Provider) GetAccountInfo(ctx context.Context) (*AccountInfo, error) {
+	account, err := p.Store.GetAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if account == nil || account.Registration == nil {
+		return nil, ErrNoAccount
+	}
+
+	info := &AccountInfo{
+		RegistrationURI:      account.Registration.URI,
+		Status:               account.Registration.Body.Status,
+		Email:                account.Email,
+		Contact:              account.Registration.Body.Contact,
+		KeyType:              string(account.PrivateKeyType),
+		PublicKeyFingerprint: getPublicKeyFingerprint(account),
+		EABKeyID:             getEABKeyID(account.Registration.Body.ExternalAccountBinding),
+		CAServer:             p.getCAServer(),
+		RegisteredAt:         account.RegisteredAt,
+	}
+
+	// The private key referenced by a Secret is not stored with the account
+	if len(info.PublicKeyFingerprint) == 0 && p.AccountKeySecretRef != nil && p.account != nil {
+		info.KeyType = string(p.account.PrivateKeyType)
+		info.PublicKeyFingerprint = getPublicKeyFingerprint(p.account)
+	}
+
+	return info, nil
+}
+
+// getPublicKeyFingerprint returns the fingerprint of the public key of the account, empty when its private key is unknown
+func getPublicKeyFingerprint(account *Account) string {
+	if len(account.PrivateKey) == 0 {
+		return ""
+	}
+
+	signer, ok := account.GetPrivateKey().(crypto.Signer)
+	if !ok {
+		return ""
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:])
+}
+
+// getEABKeyID returns the key ID of the External Account Binding of the registration, which is a JWS
+// signed with the MAC key of the binding and identified by its key ID
+func getEABKeyID(binding json.RawMessage) string {
+	if len(binding) == 0 {
+		return ""
+	}
+
+	jws := struct {
+		Protected string `json:"protected"`
+	}{}
+	if err := json.Unmarshal(binding, &jws); err != nil {
+		return ""
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if err != nil {
+		return ""
+	}
+
+	header := struct {
+		KeyID string `json:"kid"`
+	}{}
+	if err := json.Unmarshal(protected, &header); err != nil {
+		return ""
+	}
+	return header.KeyID
+}
@@ -0,0 +1,97 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+func TestGetAccountInfo(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	p := &Provider{Configuration: &Configuration{CAServer: "https://acme.wtf/directory"}, Store: store}
+
+	_, err := p.GetAccountInfo(context.Background())
+	assert.Equal(t, ErrNoAccount, err)
+
+	account, err := NewAccount("test@traefik.wtf", "RSA4096", "EC256")
+	require.NoError(t, err)
+	require.NoError(t, store.SaveAccount(context.Background(), account))
+
+	// The account is not registered yet
+	_, err = p.GetAccountInfo(context.Background())
+	assert.Equal(t, ErrNoAccount, err)
+
+	protected := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"eab-key","url":"https://acme.wtf/new-account"}`))
+	registeredAt := time.Date(2018, time.August, 1, 12, 0, 0, 0, time.UTC)
+	registration := &acme.RegistrationResource{URI: "https://acme.wtf/acme/acct/1"}
+	registration.Body.Status = "valid"
+	registration.Body.Contact = []string{"mailto:test@traefik.wtf"}
+	registration.Body.ExternalAccountBinding = json.RawMessage(`{"protected":"` + protected + `","payload":"","signature":""}`)
+
+	registered := *account
+	registered.Registration = registration
+	registered.RegisteredAt = &registeredAt
+	require.NoError(t, store.SaveAccount(context.Background(), &registered))
+
+	info, err := p.GetAccountInfo(context.Background())
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&account.GetPrivateKey().(*ecdsa.PrivateKey).PublicKey)
+	require.NoError(t, err)
+	fingerprint := sha256.Sum256(der)
+
+	assert.Equal(t, &AccountInfo{
+		RegistrationURI:      "https://acme.wtf/acme/acct/1",
+		Status:               "valid",
+		Email:                "test@traefik.wtf",
+		Contact:              []string{"mailto:test@traefik.wtf"},
+		KeyType:              "P256",
+		PublicKeyFingerprint: hex.EncodeToString(fingerprint[:]),
+		EABKeyID:             "eab-key",
+		CAServer:             "https://acme.wtf/directory",
+		RegisteredAt:         &registeredAt,
+	}, info)
+
+	content, err := json.Marshal(info)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), base64.StdEncoding.EncodeToString(account.PrivateKey))
+}
+
+func TestGetAccountInfoKeyFromSecret(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	account, err := NewAccount("test@traefik.wtf", "RSA4096", "EC256")
+	require.NoError(t, err)
+	account.Registration = &acme.RegistrationResource{URI: "https://acme.wtf/acme/acct/1"}
+
+	// The private key stays in the referenced Secret
+	storedAccount := *account
+	storedAccount.PrivateKey = nil
+	require.NoError(t, store.SaveAccount(context.Background(), &storedAccount))
+
+	p := &Provider{
+		Configuration: &Configuration{AccountKeySecretRef: &SecretRef{Name: "account"}},
+		Store:         store,
+		account:       account,
+	}
+
+	info, err := p.GetAccountInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "P256", info.KeyType)
+	assert.Equal(t, getPublicKeyFingerprint(account), info.PublicKeyFingerprint)
+	assert.NotEmpty(t, info.PublicKeyFingerprint)
+	assert.Empty(t, info.EABKeyID)
+}
@@ -0,0 +1,116 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/xenolf/lego/acme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// SecretRef references a key of a Kubernetes Secret
+type SecretRef struct {
+	Namespace string `description:"Namespace of the Secret"`
+	Name      string `description:"Name of the Secret"`
+	Key       string `description:"Key of the Secret data holding the value"`
+}
+
+// String returns the namespace/name:key representation of the reference
+func (r *SecretRef) String() string {
+	return fmt.Sprintf("%s/%s:%s", r.Namespace, r.Name, r.Key)
+}
+
+// secretDataGetter returns the data of a Kubernetes Secret
+type secretDataGetter func(namespace, name string) (map[string][]byte, error)
+
+func getInClusterSecretData(namespace, name string) (map[string][]byte, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create in-cluster configuration: %s", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Data, nil
+}
+
+// loadAccountKeyFromSecret reads the PEM encoded account private key from the referenced Secret
+// and returns it in the DER format used by the Account
+func loadAccountKeyFromSecret(ref *SecretRef, getSecretData secretDataGetter) ([]byte, acme.KeyType, error) {
+	if len(ref.Namespace) == 0 || len(ref.Name) == 0 || len(ref.Key) == 0 {
+		return nil, "", fmt.Errorf("invalid account key Secret reference %q: namespace, name and key are required", ref)
+	}
+
+	data, err := getSecretData(ref.Namespace, ref.Name)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read the account key Secret %s/%s: %v", ref.Namespace, ref.Name, err)
+	}
+
+	keyPEM, ok := data[ref.Key]
+	if !ok || len(keyPEM) == 0 {
+		return nil, "", fmt.Errorf("the account key Secret %s/%s has no key %q", ref.Namespace, ref.Name, ref.Key)
+	}
+
+	privateKey, err := parseAccountKeyPEM(keyPEM)
+	if err != nil {
+		return nil, "", fmt.Errorf("malformed account key in Secret %q: %v", ref, err)
+	}
+
+	keyType, err := inferPrivateKeyType(privateKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("malformed account key in Secret %q: %v", ref, err)
+	}
+
+	return privateKey, keyType, nil
+}
+
+// parseAccountKeyPEM decodes a PKCS1, SEC1 or PKCS8 PEM private key into PKCS1 (RSA) or SEC1 (EC) DER
+func parseAccountKeyPEM(keyPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, err
+		}
+		return block.Bytes, nil
+	case "EC PRIVATE KEY":
+		if _, err := x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return nil, err
+		}
+		return block.Bytes, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		switch privateKey := key.(type) {
+		case *rsa.PrivateKey:
+			return x509.MarshalPKCS1PrivateKey(privateKey), nil
+		case *ecdsa.PrivateKey:
+			return x509.MarshalECPrivateKey(privateKey)
+		default:
+			return nil, fmt.Errorf("unsupported PKCS8 private key type %T", key)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
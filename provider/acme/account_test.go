@@ -0,0 +1,257 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+func TestNewAccount(t *testing.T) {
+	testCases := []struct {
+		desc                   string
+		accountKeyType         string
+		expectedPrivateKeyType acme.KeyType
+		checkPrivateKey        func(t *testing.T, privateKey interface{})
+	}{
+		{
+			desc:                   "RSA2048 account key",
+			accountKeyType:         "RSA2048",
+			expectedPrivateKeyType: acme.RSA2048,
+			checkPrivateKey: func(t *testing.T, privateKey interface{}) {
+				require.IsType(t, &rsa.PrivateKey{}, privateKey)
+				assert.Equal(t, 2048, privateKey.(*rsa.PrivateKey).N.BitLen())
+			},
+		},
+		{
+			desc:                   "RSA4096 account key",
+			accountKeyType:         "RSA4096",
+			expectedPrivateKeyType: acme.RSA4096,
+			checkPrivateKey: func(t *testing.T, privateKey interface{}) {
+				require.IsType(t, &rsa.PrivateKey{}, privateKey)
+				assert.Equal(t, 4096, privateKey.(*rsa.PrivateKey).N.BitLen())
+			},
+		},
+		{
+			desc:                   "EC256 account key",
+			accountKeyType:         "EC256",
+			expectedPrivateKeyType: acme.EC256,
+			checkPrivateKey: func(t *testing.T, privateKey interface{}) {
+				require.IsType(t, &ecdsa.PrivateKey{}, privateKey)
+				assert.Equal(t, elliptic.P256(), privateKey.(*ecdsa.PrivateKey).Curve)
+			},
+		},
+		{
+			desc:                   "EC384 account key",
+			accountKeyType:         "EC384",
+			expectedPrivateKeyType: acme.EC384,
+			checkPrivateKey: func(t *testing.T, privateKey interface{}) {
+				require.IsType(t, &ecdsa.PrivateKey{}, privateKey)
+				assert.Equal(t, elliptic.P384(), privateKey.(*ecdsa.PrivateKey).Curve)
+			},
+		},
+		{
+			desc:                   "default account key",
+			expectedPrivateKeyType: acme.RSA4096,
+			checkPrivateKey: func(t *testing.T, privateKey interface{}) {
+				require.IsType(t, &rsa.PrivateKey{}, privateKey)
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			account, err := NewAccount("foo@foo.net", "EC256", test.accountKeyType)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedPrivateKeyType, account.PrivateKeyType)
+			assert.Equal(t, acme.EC256, account.KeyType)
+			test.checkPrivateKey(t, account.GetPrivateKey())
+
+			privateKeyType, err := inferPrivateKeyType(account.PrivateKey)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPrivateKeyType, privateKeyType)
+		})
+	}
+}
+
+func TestInferPrivateKeyTypeInvalidKey(t *testing.T) {
+	_, err := inferPrivateKeyType([]byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestLocalStoreUnknownAccountPrivateKeyType(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 3072)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc       string
+		privateKey []byte
+	}{
+		{
+			desc:       "RSA 3072",
+			privateKey: x509.MarshalPKCS1PrivateKey(rsaKey),
+		},
+		{
+			desc:       "EC P-521",
+			privateKey: ecDER,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			filename := filepath.Join(dir, "acme.json")
+			content, err := json.Marshal(&StoredData{Account: &Account{Email: "test@traefik.wtf", PrivateKey: test.privateKey}})
+			require.NoError(t, err)
+			require.NoError(t, ioutil.WriteFile(filename, content, 0600))
+
+			// The storage is loaded, the private key type being left empty
+			store := NewLocalStore(filename)
+			account, err := store.GetAccount(context.Background())
+			require.NoError(t, err)
+			require.NotNil(t, account)
+			assert.Empty(t, account.PrivateKeyType)
+			assert.NotNil(t, account.GetPrivateKey())
+
+			p := &Provider{Configuration: &Configuration{Email: "test@traefik.wtf"}, account: account}
+			_, err = p.initAccount()
+			require.NoError(t, err)
+			assert.Empty(t, p.account.PrivateKeyType)
+		})
+	}
+}
+
+func TestLoadAccountKeyFromSecret(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	ecPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})
+
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+	pkcs8PEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER})
+
+	secrets := map[string]map[string][]byte{
+		"traefik/account": {
+			"ec.pem":    ecPEM,
+			"pkcs8.pem": pkcs8PEM,
+			"bad.pem":   []byte("not a key"),
+		},
+	}
+	getSecretData := func(namespace, name string) (map[string][]byte, error) {
+		data, ok := secrets[namespace+"/"+name]
+		if !ok {
+			return nil, fmt.Errorf("secrets %q not found", name)
+		}
+		return data, nil
+	}
+
+	testCases := []struct {
+		desc          string
+		ref           *SecretRef
+		expectedError string
+	}{
+		{
+			desc: "EC private key",
+			ref:  &SecretRef{Namespace: "traefik", Name: "account", Key: "ec.pem"},
+		},
+		{
+			desc: "PKCS8 private key",
+			ref:  &SecretRef{Namespace: "traefik", Name: "account", Key: "pkcs8.pem"},
+		},
+		{
+			desc:          "incomplete reference",
+			ref:           &SecretRef{Name: "account", Key: "ec.pem"},
+			expectedError: `invalid account key Secret reference "/account:ec.pem": namespace, name and key are required`,
+		},
+		{
+			desc:          "missing Secret",
+			ref:           &SecretRef{Namespace: "traefik", Name: "missing", Key: "ec.pem"},
+			expectedError: `unable to read the account key Secret traefik/missing: secrets "missing" not found`,
+		},
+		{
+			desc:          "missing key",
+			ref:           &SecretRef{Namespace: "traefik", Name: "account", Key: "missing.pem"},
+			expectedError: `the account key Secret traefik/account has no key "missing.pem"`,
+		},
+		{
+			desc:          "malformed key",
+			ref:           &SecretRef{Namespace: "traefik", Name: "account", Key: "bad.pem"},
+			expectedError: `malformed account key in Secret "traefik/account:bad.pem": no PEM block found`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			privateKey, keyType, err := loadAccountKeyFromSecret(test.ref, getSecretData)
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, acme.EC256, keyType)
+			assert.Equal(t, ecDER, privateKey)
+		})
+	}
+}
+
+func TestInitAccountKeyFromSecret(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	getSecretData := func(namespace, name string) (map[string][]byte, error) {
+		return map[string][]byte{"key": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})}, nil
+	}
+
+	storedAccount := &Account{Email: "foo@foo.net", Registration: &acme.RegistrationResource{URI: "https://acme/acct/1"}}
+
+	acmeProvider := Provider{
+		account: storedAccount,
+		Configuration: &Configuration{
+			Email:               "foo@foo.net",
+			AccountKeySecretRef: &SecretRef{Namespace: "traefik", Name: "account", Key: "key"},
+		},
+	}
+
+	err = acmeProvider.initAccountKeyFromSecret(getSecretData)
+	require.NoError(t, err)
+
+	assert.Equal(t, ecDER, acmeProvider.account.PrivateKey)
+	assert.Equal(t, acme.EC384, acmeProvider.account.PrivateKeyType)
+	assert.Equal(t, storedAccount.Registration, acmeProvider.account.Registration)
+	assert.Empty(t, storedAccount.PrivateKey, "the private key must not be copied into the stored account")
+}
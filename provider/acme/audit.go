@@ -0,0 +1,229 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/containous/traefik/log"
+	"github.com/sirupsen/logrus"
+)
+
+// Triggers of the ACME store mutations
+const (
+	auditTriggerIssuance  = "issuance"
+	auditTriggerRenewal   = "renewal"
+	auditTriggerRemoval   = "removal"
+	auditTriggerMigration = "migration"
+)
+
+// Actions of the ACME store mutations
+const (
+	auditActionCertificateAdded   = "certificate.added"
+	auditActionCertificateUpdated = "certificate.updated"
+	auditActionCertificateRemoved = "certificate.removed"
+	auditActionAccountSaved       = "account.saved"
+	auditActionAccountRemoved     = "account.removed"
+	auditActionChallengeAdded     = "challenge.added"
+	auditActionChallengeRemoved   = "challenge.removed"
+)
+
+// storeAudit records the mutations of the ACME store on a dedicated logger.
+// It keeps the fingerprints of the stored data, never the private keys nor the key authorizations.
+type storeAudit struct {
+	logger   logrus.FieldLogger
+	instance string
+
+	lock         sync.Mutex
+	certificates map[string]string
+	account      *auditAccount
+}
+
+type auditAccount struct {
+	email           string
+	registrationURI string
+	keyHash         string
+}
+
+// newStoreAudit creates the audit logger: a JSON file opened in append mode when a path is given,
+// the Traefik logger otherwise
+func newStoreAudit(auditLog string) *storeAudit {
+	instance, err := os.Hostname()
+	if err != nil {
+		logger().Warnf("Unable to get the hostname for the ACME audit log: %v", err)
+	}
+
+	audit := &storeAudit{
+		logger:       log.WithField("logger", "acme.audit"),
+		instance:     instance,
+		certificates: make(map[string]string),
+	}
+
+	if len(auditLog) > 0 {
+		file, err := os.OpenFile(auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			logger().Errorf("Unable to open the ACME audit log %s, the audit entries are written to the Traefik log: %v", auditLog, err)
+		} else {
+			logger := logrus.New()
+			logger.Out = file
+			logger.Formatter = &logrus.JSONFormatter{}
+			audit.logger = logger
+		}
+	}
+
+	return audit
+}
+
+// snapshot records the state of the stored data, without emitting audit entries
+func (a *storeAudit) snapshot(storedData *StoredData) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.certificates = getCertificatesFingerprints(storedData.Certificates)
+	a.account = newAuditAccount(storedData.Account)
+}
+
+// saveCertificates audits the differences between the certificates and the last recorded ones.
+// Without trigger, the trigger is inferred from the change: issuance, renewal or removal.
+func (a *storeAudit) saveCertificates(certificates []*Certificate, trigger string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	fingerprints := getCertificatesFingerprints(certificates)
+
+	for domain, fingerprint := range fingerprints {
+		previous, ok := a.certificates[domain]
+		switch {
+		case !ok:
+			a.record(auditActionCertificateAdded, getAuditTrigger(trigger, auditTriggerIssuance), logrus.Fields{
+				"domain":            domain,
+				"fingerprintBefore": "",
+				"fingerprintAfter":  fingerprint,
+			})
+		case previous != fingerprint:
+			a.record(auditActionCertificateUpdated, getAuditTrigger(trigger, auditTriggerRenewal), logrus.Fields{
+				"domain":            domain,
+				"fingerprintBefore": previous,
+				"fingerprintAfter":  fingerprint,
+			})
+		}
+	}
+
+	for domain, previous := range a.certificates {
+		if _, ok := fingerprints[domain]; !ok {
+			a.record(auditActionCertificateRemoved, getAuditTrigger(trigger, auditTriggerRemoval), logrus.Fields{
+				"domain":            domain,
+				"fingerprintBefore": previous,
+				"fingerprintAfter":  "",
+			})
+		}
+	}
+
+	a.certificates = fingerprints
+}
+
+// saveAccount audits the change of the account, if any
+func (a *storeAudit) saveAccount(account *Account, trigger string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	current := newAuditAccount(account)
+
+	switch {
+	case current == nil && a.account != nil:
+		a.record(auditActionAccountRemoved, getAuditTrigger(trigger, auditTriggerRemoval), logrus.Fields{
+			"email":        a.account.email,
+			"registration": a.account.registrationURI,
+		})
+	case current != nil && (a.account == nil || *current != *a.account):
+		a.record(auditActionAccountSaved, getAuditTrigger(trigger, auditTriggerIssuance), logrus.Fields{
+			"email":             current.email,
+			"registration":      current.registrationURI,
+			"privateKeyChanged": a.account == nil || current.keyHash != a.account.keyHash,
+		})
+	}
+
+	a.account = current
+}
+
+// challenge audits the addition or the removal of a challenge, the key authorization is never logged
+func (a *storeAudit) challenge(action, challengeType, domain, token string) {
+	trigger := auditTriggerIssuance
+	if action == auditActionChallengeRemoved {
+		trigger = auditTriggerRemoval
+	}
+
+	fields := logrus.Fields{
+		"challenge": challengeType,
+		"domain":    domain,
+	}
+	if len(token) > 0 {
+		fields["token"] = token
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.record(action, trigger, fields)
+}
+
+func (a *storeAudit) record(action, trigger string, fields logrus.Fields) {
+	fields["action"] = action
+	fields["trigger"] = trigger
+	fields["instance"] = a.instance
+
+	a.logger.WithFields(fields).Info("ACME store mutation")
+}
+
+func getAuditTrigger(trigger, defaultTrigger string) string {
+	if len(trigger) > 0 {
+		return trigger
+	}
+	return defaultTrigger
+}
+
+func newAuditAccount(account *Account) *auditAccount {
+	if account == nil {
+		return nil
+	}
+
+	audit := &auditAccount{email: account.Email}
+	if account.Registration != nil {
+		audit.registrationURI = account.Registration.URI
+	}
+	if len(account.PrivateKey) > 0 {
+		hash := sha256.Sum256(account.PrivateKey)
+		audit.keyHash = hex.EncodeToString(hash[:])
+	}
+	return audit
+}
+
+func getCertificatesFingerprints(certificates []*Certificate) map[string]string {
+	fingerprints := make(map[string]string, len(certificates))
+	for _, certificate := range certificates {
+		if certificate == nil {
+			continue
+		}
+		fingerprints[strings.Join(certificate.Domain.ToStrArray(), ",")] = getCertificateFingerprint(certificate.Certificate)
+	}
+	return fingerprints
+}
+
+// getCertificateFingerprint returns the SHA-256 fingerprint of the leaf certificate
+func getCertificateFingerprint(certificate []byte) string {
+	if len(certificate) == 0 {
+		return ""
+	}
+
+	content := certificate
+	if block, _ := pem.Decode(bytes.TrimSpace(certificate)); block != nil {
+		content = block.Bytes
+	}
+
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
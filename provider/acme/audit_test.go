@@ -0,0 +1,147 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+func newTestStoreAudit() (*storeAudit, *bytes.Buffer) {
+	buffer := &bytes.Buffer{}
+
+	logger := logrus.New()
+	logger.Out = buffer
+	logger.Formatter = &logrus.JSONFormatter{}
+
+	return &storeAudit{logger: logger, instance: "traefik-1", certificates: make(map[string]string)}, buffer
+}
+
+func readAuditEntries(t *testing.T, buffer *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		entry := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestStoreAuditSaveCertificates(t *testing.T) {
+	certificate := func(domain, content string) *Certificate {
+		return &Certificate{Domain: types.Domain{Main: domain}, Certificate: []byte(content), Key: []byte("key-" + content)}
+	}
+
+	testCases := []struct {
+		desc     string
+		previous []*Certificate
+		current  []*Certificate
+		trigger  string
+		expected []map[string]interface{}
+	}{
+		{
+			desc:    "issuance",
+			current: []*Certificate{certificate("traefik.wtf", "cert")},
+			expected: []map[string]interface{}{
+				{"action": auditActionCertificateAdded, "trigger": auditTriggerIssuance, "domain": "traefik.wtf", "fingerprintBefore": "", "fingerprintAfter": getCertificateFingerprint([]byte("cert"))},
+			},
+		},
+		{
+			desc:     "renewal",
+			previous: []*Certificate{certificate("traefik.wtf", "cert")},
+			current:  []*Certificate{certificate("traefik.wtf", "renewed")},
+			expected: []map[string]interface{}{
+				{"action": auditActionCertificateUpdated, "trigger": auditTriggerRenewal, "domain": "traefik.wtf", "fingerprintBefore": getCertificateFingerprint([]byte("cert")), "fingerprintAfter": getCertificateFingerprint([]byte("renewed"))},
+			},
+		},
+		{
+			desc:     "removal",
+			previous: []*Certificate{certificate("traefik.wtf", "cert")},
+			expected: []map[string]interface{}{
+				{"action": auditActionCertificateRemoved, "trigger": auditTriggerRemoval, "domain": "traefik.wtf", "fingerprintBefore": getCertificateFingerprint([]byte("cert")), "fingerprintAfter": ""},
+			},
+		},
+		{
+			desc:     "migration",
+			previous: []*Certificate{certificate("traefik.wtf", "cert")},
+			trigger:  auditTriggerMigration,
+			expected: []map[string]interface{}{
+				{"action": auditActionCertificateRemoved, "trigger": auditTriggerMigration, "domain": "traefik.wtf", "fingerprintBefore": getCertificateFingerprint([]byte("cert")), "fingerprintAfter": ""},
+			},
+		},
+		{
+			desc:     "no change",
+			previous: []*Certificate{certificate("traefik.wtf", "cert")},
+			current:  []*Certificate{certificate("traefik.wtf", "cert")},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			audit, buffer := newTestStoreAudit()
+			audit.snapshot(&StoredData{Certificates: test.previous})
+
+			audit.saveCertificates(test.current, test.trigger)
+
+			entries := readAuditEntries(t, buffer)
+			require.Len(t, entries, len(test.expected))
+			for i, expected := range test.expected {
+				expected["instance"] = "traefik-1"
+				for field, value := range expected {
+					assert.Equal(t, value, entries[i][field], field)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreAuditNeverLogsSecrets(t *testing.T) {
+	audit, buffer := newTestStoreAudit()
+
+	store := &LocalStore{storedData: &StoredData{}, SaveDataChan: make(chan *StoredData, 10)}
+	store.auditOnce.Do(func() { store.audit = audit })
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{
+		Email:        "test@traefik.wtf",
+		PrivateKey:   []byte("account-private-key"),
+		Registration: &acme.RegistrationResource{URI: "https://acme.wtf/acct/1"},
+	}))
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{
+		{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("certificate-private-key")},
+	}))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("http-key-auth")))
+	require.NoError(t, store.RemoveHTTPChallengeToken(context.Background(), "token", "traefik.wtf"))
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "dns-token", &DNSChallengeState{Domain: "traefik.wtf", KeyAuth: "dns-key-auth"}))
+	require.NoError(t, store.RemoveDNSChallenge(context.Background(), "dns-token"))
+
+	entries := readAuditEntries(t, buffer)
+	var actions []interface{}
+	for _, entry := range entries {
+		actions = append(actions, entry["action"])
+	}
+	assert.Equal(t, []interface{}{
+		auditActionAccountSaved,
+		auditActionCertificateAdded,
+		auditActionChallengeAdded,
+		auditActionChallengeRemoved,
+		auditActionChallengeAdded,
+		auditActionChallengeRemoved,
+	}, actions)
+
+	for _, secret := range []string{"account-private-key", "certificate-private-key", "http-key-auth", "dns-key-auth"} {
+		assert.NotContains(t, buffer.String(), secret)
+	}
+}
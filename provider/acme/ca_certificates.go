@@ -0,0 +1,77 @@
+package acme
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/xenolf/lego/acme"
+)
+
+// initCACertificates makes the HTTPS calls to the CA server trust the configured CA certificates, in addition to the
+// system ones, and checks that the certificate of the CA server is trusted.
+func (p *Provider) initCACertificates(getSecretData secretDataGetter) error {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		logger().Warnf("Unable to load the system CA certificates, only the configured ones are trusted: %v", err)
+		pool = x509.NewCertPool()
+	}
+
+	for _, file := range p.CACertificates {
+		certificates, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("unable to read the CA certificates %s: %v", file, err)
+		}
+		if !pool.AppendCertsFromPEM(certificates) {
+			return fmt.Errorf("no PEM encoded CA certificate found in %s", file)
+		}
+	}
+
+	if ref := p.CACertificatesSecretRef; ref != nil {
+		if len(ref.Namespace) == 0 || len(ref.Name) == 0 || len(ref.Key) == 0 {
+			return fmt.Errorf("invalid CA certificates Secret reference %q: namespace, name and key are required", ref)
+		}
+
+		data, err := getSecretData(ref.Namespace, ref.Name)
+		if err != nil {
+			return fmt.Errorf("unable to read the CA certificates Secret %s/%s: %v", ref.Namespace, ref.Name, err)
+		}
+		if !pool.AppendCertsFromPEM(data[ref.Key]) {
+			return fmt.Errorf("no PEM encoded CA certificate found in the Secret %q", ref)
+		}
+	}
+
+	transport, ok := acme.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("unable to set the CA certificates of the HTTPS calls to the CA server")
+	}
+
+	tlsConfig := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	tlsConfig.RootCAs = pool
+	transport.TLSClientConfig = tlsConfig
+
+	return checkCAServerCertificate(p.getCAServer())
+}
+
+// checkCAServerCertificate fails when the certificate of the CA server is not trusted.
+// The CA server may be unavailable at the start, the other failures are only logged.
+func checkCAServerCertificate(caServer string) error {
+	resp, err := acme.HTTPClient.Get(caServer)
+	if err != nil {
+		if strings.Contains(err.Error(), "x509: ") {
+			return fmt.Errorf("unable to verify the certificate of the CA server %s: %v", caServer, err)
+		}
+
+		logger().Warnf("Unable to check the certificate of the CA server %s: %v", caServer, err)
+		return nil
+	}
+
+	return resp.Body.Close()
+}
@@ -0,0 +1,105 @@
+package acme
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+func TestInitCACertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, caPEM, 0600))
+	otherFile := filepath.Join(dir, "other.pem")
+	require.NoError(t, ioutil.WriteFile(otherFile, []byte("not a certificate"), 0600))
+
+	getSecretData := func(namespace, name string) (map[string][]byte, error) {
+		return map[string][]byte{"ca.crt": caPEM}, nil
+	}
+
+	testCases := []struct {
+		desc          string
+		files         []string
+		ref           *SecretRef
+		expectedError string
+	}{
+		{
+			desc:  "CA certificates file",
+			files: []string{caFile},
+		},
+		{
+			desc: "CA certificates Secret",
+			ref:  &SecretRef{Namespace: "traefik", Name: "ca", Key: "ca.crt"},
+		},
+		{
+			desc:          "missing Secret key",
+			ref:           &SecretRef{Namespace: "traefik", Name: "ca", Key: "other.crt"},
+			expectedError: `no PEM encoded CA certificate found in the Secret "traefik/ca:other.crt"`,
+		},
+		{
+			desc:          "missing file",
+			files:         []string{filepath.Join(dir, "missing.pem")},
+			expectedError: "unable to read the CA certificates",
+		},
+		{
+			desc:          "no certificate",
+			files:         []string{otherFile},
+			expectedError: "no PEM encoded CA certificate found in " + otherFile,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			defer func(transport http.RoundTripper) { acme.HTTPClient.Transport = transport }(acme.HTTPClient.Transport)
+			acme.HTTPClient.Transport = &http.Transport{}
+
+			provider := &Provider{Configuration: &Configuration{
+				CAServer:                server.URL,
+				CACertificates:          test.files,
+				CACertificatesSecretRef: test.ref,
+			}}
+
+			err := provider.initCACertificates(getSecretData)
+			if len(test.expectedError) > 0 {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckCAServerCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	defer func(transport http.RoundTripper) { acme.HTTPClient.Transport = transport }(acme.HTTPClient.Transport)
+	acme.HTTPClient.Transport = &http.Transport{}
+
+	err := checkCAServerCertificate(server.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "x509: ")
+
+	// An unavailable CA server does not prevent the start
+	server.Close()
+	assert.NoError(t, checkCAServerCertificate(server.URL))
+}
@@ -0,0 +1,272 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Annotations of the Secrets issued by cert-manager, read by the tools relying on its metadata
+const (
+	certManagerCertificateNameAnnotation = "cert-manager.io/certificate-name"
+	certManagerIssuerNameAnnotation      = "cert-manager.io/issuer-name"
+	certManagerIssuerKindAnnotation      = "cert-manager.io/issuer-kind"
+	certManagerIssuerGroupAnnotation     = "cert-manager.io/issuer-group"
+	certManagerCommonNameAnnotation      = "cert-manager.io/common-name"
+	certManagerAltNamesAnnotation        = "cert-manager.io/alt-names"
+)
+
+const (
+	certManagerAPIVersion = "cert-manager.io/v1"
+	certManagerKind       = "Certificate"
+	certManagerPlural     = "certificates"
+
+	// The issuer of the certificates is a placeholder, not an issuer of cert-manager: the certificates are issued and renewed by Traefik
+	certManagerIssuerKind = "ACMEProvider"
+
+	certManagerExternallyManagedAnnotation = "traefik.containous.io/acme-externally-managed"
+)
+
+// certManagerCertificate is a stub cert-manager Certificate, describing a certificate issued and renewed by Traefik
+type certManagerCertificate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec certManagerCertificateSpec `json:"spec"`
+}
+
+type certManagerCertificateSpec struct {
+	SecretName  string               `json:"secretName"`
+	CommonName  string               `json:"commonName,omitempty"`
+	DNSNames    []string             `json:"dnsNames"`
+	RenewBefore string               `json:"renewBefore,omitempty"`
+	IssuerRef   certManagerIssuerRef `json:"issuerRef"`
+}
+
+type certManagerIssuerRef struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Group string `json:"group"`
+}
+
+type certManagerCertificateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []certManagerCertificate `json:"items"`
+}
+
+// certManagerClient manages the stub cert-manager Certificates of a namespace
+type certManagerClient interface {
+	List(namespace string, selector string) ([]certManagerCertificate, error)
+	Create(certificate *certManagerCertificate) error
+	Update(certificate *certManagerCertificate) error
+	Delete(namespace, name string) error
+}
+
+// kubernetesCertManagerClient requests the API of the cert-manager Certificates, as kubernetesCertificateResourcesClient does
+type kubernetesCertManagerClient struct {
+	client rest.Interface
+}
+
+func newInClusterCertManagerClient() (certManagerClient, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create in-cluster configuration: %s", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kubernetesCertManagerClient{client: clientset.Discovery().RESTClient()}, nil
+}
+
+func getCertManagerCertificatesPath(namespace string, name ...string) string {
+	path := "/apis/" + certManagerAPIVersion + "/namespaces/" + namespace + "/" + certManagerPlural
+	for _, segment := range name {
+		path += "/" + segment
+	}
+	return path
+}
+
+func (c *kubernetesCertManagerClient) List(namespace string, selector string) ([]certManagerCertificate, error) {
+	raw, err := c.client.Get().AbsPath(getCertManagerCertificatesPath(namespace)).Param("labelSelector", selector).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &certManagerCertificateList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (c *kubernetesCertManagerClient) Create(certificate *certManagerCertificate) error {
+	return c.send(c.client.Post().AbsPath(getCertManagerCertificatesPath(certificate.Namespace)), certificate)
+}
+
+func (c *kubernetesCertManagerClient) Update(certificate *certManagerCertificate) error {
+	return c.send(c.client.Put().AbsPath(getCertManagerCertificatesPath(certificate.Namespace, certificate.Name)), certificate)
+}
+
+func (c *kubernetesCertManagerClient) Delete(namespace, name string) error {
+	return c.client.Delete().AbsPath(getCertManagerCertificatesPath(namespace, name)).Do().Error()
+}
+
+// send writes the Certificate, and sets its resource version to the one of the written Certificate
+func (c *kubernetesCertManagerClient) send(request *rest.Request, certificate *certManagerCertificate) error {
+	certificate.APIVersion = certManagerAPIVersion
+	certificate.Kind = certManagerKind
+
+	body, err := json.Marshal(certificate)
+	if err != nil {
+		return err
+	}
+
+	raw, err := request.SetHeader("Content-Type", "application/json").Body(body).Do().Raw()
+	if err != nil {
+		return err
+	}
+
+	written := &certManagerCertificate{}
+	if err := json.Unmarshal(raw, written); err != nil {
+		return err
+	}
+	certificate.ResourceVersion = written.ResourceVersion
+	return nil
+}
+
+func getCertManagerIssuerRef(owner string) certManagerIssuerRef {
+	return certManagerIssuerRef{Name: owner, Kind: certManagerIssuerKind, Group: certificateResourceGroup}
+}
+
+// setCertManagerAnnotations annotates the certificate Secret as cert-manager annotates the Secrets it issues
+func setCertManagerAnnotations(secret *corev1.Secret, owner string, certificate *Certificate) {
+	issuerRef := getCertManagerIssuerRef(owner)
+
+	secret.Annotations[certManagerCertificateNameAnnotation] = secret.Name
+	secret.Annotations[certManagerIssuerNameAnnotation] = issuerRef.Name
+	secret.Annotations[certManagerIssuerKindAnnotation] = issuerRef.Kind
+	secret.Annotations[certManagerIssuerGroupAnnotation] = issuerRef.Group
+	secret.Annotations[certManagerCommonNameAnnotation] = certificate.Domain.Main
+	secret.Annotations[certManagerAltNamesAnnotation] = strings.Join(certificate.Domain.ToStrArray(), ",")
+}
+
+func newCertManagerCertificate(namespace, owner string, certificate *Certificate) *certManagerCertificate {
+	name := getCertificateSecretName(certificate.Domain)
+
+	stub := &certManagerCertificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				certificateSecretLabel:      "true",
+				certificateSecretOwnerLabel: owner,
+			},
+			Annotations: map[string]string{
+				certManagerExternallyManagedAnnotation: "true",
+			},
+		},
+		Spec: certManagerCertificateSpec{
+			SecretName: name,
+			CommonName: certificate.Domain.Main,
+			DNSNames:   certificate.Domain.ToStrArray(),
+			IssuerRef:  getCertManagerIssuerRef(owner),
+		},
+	}
+	if certificate.RenewBefore > 0 {
+		stub.Spec.RenewBefore = certificate.RenewBefore.String()
+	}
+
+	return stub
+}
+
+func isCertManagerCertificateUpToDate(existing certManagerCertificate, stub *certManagerCertificate) bool {
+	existingSpec, err := json.Marshal(existing.Spec)
+	if err != nil {
+		return false
+	}
+	spec, err := json.Marshal(stub.Spec)
+	return err == nil && string(existingSpec) == string(spec) &&
+		existing.Annotations[certManagerExternallyManagedAnnotation] == stub.Annotations[certManagerExternallyManagedAnnotation]
+}
+
+func (s *LocalStore) getCertManagerClient() (certManagerClient, error) {
+	s.secretsLock.Lock()
+	defer s.secretsLock.Unlock()
+
+	if s.certManagerClient == nil {
+		client, err := newInClusterCertManagerClient()
+		if err != nil {
+			return nil, err
+		}
+		s.certManagerClient = client
+	}
+	return s.certManagerClient, nil
+}
+
+// saveCertManagerCertificates creates, updates and deletes the stub cert-manager Certificates of the owner to match the certificates
+func (s *LocalStore) saveCertManagerCertificates(certificates []*Certificate) error {
+	client, err := s.getCertManagerClient()
+	if err != nil {
+		return fmt.Errorf("unable to create the Kubernetes client of the cert-manager Certificates: %v", err)
+	}
+
+	namespace := s.CertificateSecrets.Namespace
+	owner := s.CertificateSecrets.getOwner()
+	stubs, err := client.List(namespace, certificateSecretOwnerLabel+"="+owner)
+	if err != nil {
+		return fmt.Errorf("unable to list the cert-manager Certificates of the namespace %q: %v", namespace, err)
+	}
+
+	existingStubs := make(map[string]certManagerCertificate)
+	for _, stub := range stubs {
+		existingStubs[stub.Name] = stub
+	}
+
+	savedStubs := make(map[string]struct{})
+	for _, certificate := range certificates {
+		stub := newCertManagerCertificate(namespace, owner, certificate)
+		savedStubs[stub.Name] = struct{}{}
+
+		existing, ok := existingStubs[stub.Name]
+		switch {
+		case !ok:
+			err = client.Create(stub)
+			if kerrors.IsAlreadyExists(err) {
+				// A Certificate of the same name which is not owned, possibly managed by cert-manager, is never changed
+				s.secretsLogger(storeOperationSave).WithField(logFieldSecret, stub.Name).Warnf("The cert-manager Certificate %s/%s is not owned by %q, it is not changed.", namespace, stub.Name, owner)
+				continue
+			}
+		case !isCertManagerCertificateUpToDate(existing, stub):
+			stub.ResourceVersion = existing.ResourceVersion
+			err = client.Update(stub)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to save the cert-manager Certificate %s/%s: %v", namespace, stub.Name, err)
+		}
+	}
+
+	for name := range existingStubs {
+		if _, ok := savedStubs[name]; ok {
+			continue
+		}
+		// The Certificate may already be deleted by another instance
+		if err := client.Delete(namespace, name); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete the cert-manager Certificate %s/%s: %v", namespace, name, err)
+		}
+	}
+
+	return nil
+}
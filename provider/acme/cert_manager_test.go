@@ -0,0 +1,128 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var certManagerGroupResource = schema.GroupResource{Group: "cert-manager.io", Resource: certManagerPlural}
+
+type fakeCertManagerClient struct {
+	lock         sync.Mutex
+	certificates map[string]certManagerCertificate
+}
+
+func newFakeCertManagerClient() *fakeCertManagerClient {
+	return &fakeCertManagerClient{certificates: make(map[string]certManagerCertificate)}
+}
+
+func (c *fakeCertManagerClient) List(namespace string, selector string) ([]certManagerCertificate, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	label := strings.SplitN(selector, "=", 2)
+
+	var certificates []certManagerCertificate
+	for _, certificate := range c.certificates {
+		if certificate.Namespace == namespace && certificate.Labels[label[0]] == label[1] {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return certificates, nil
+}
+
+func (c *fakeCertManagerClient) Create(certificate *certManagerCertificate) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := certificate.Namespace + "/" + certificate.Name
+	if _, ok := c.certificates[key]; ok {
+		return kerrors.NewAlreadyExists(certManagerGroupResource, certificate.Name)
+	}
+	c.certificates[key] = *certificate
+	return nil
+}
+
+func (c *fakeCertManagerClient) Update(certificate *certManagerCertificate) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := certificate.Namespace + "/" + certificate.Name
+	if _, ok := c.certificates[key]; !ok {
+		return kerrors.NewNotFound(certManagerGroupResource, certificate.Name)
+	}
+	c.certificates[key] = *certificate
+	return nil
+}
+
+func (c *fakeCertManagerClient) Delete(namespace, name string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := namespace + "/" + name
+	if _, ok := c.certificates[key]; !ok {
+		return kerrors.NewNotFound(certManagerGroupResource, name)
+	}
+	delete(c.certificates, key)
+	return nil
+}
+
+func TestSaveCertificateSecretsCertManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	secrets := newFakeSecretsClient()
+	stubs := newFakeCertManagerClient()
+
+	// A Certificate of the same name managed by cert-manager is not changed
+	managed := certManagerCertificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "acme-managed.traefik.wtf", Namespace: "traefik"},
+		Spec:       certManagerCertificateSpec{SecretName: "managed", IssuerRef: certManagerIssuerRef{Name: "letsencrypt", Kind: "ClusterIssuer"}},
+	}
+	stubs.certificates["traefik/"+managed.Name] = managed
+
+	store := newTestTLSSecretsStore(filepath.Join(dir, "acme.json"), secrets)
+	store.CertificateSecrets.CertManager = true
+	store.CertificateSecrets.CertManagerStubs = true
+	store.certManagerClient = stubs
+
+	certificates := []*Certificate{
+		{Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}, Certificate: []byte("cert"), Key: []byte("key"), RenewBefore: 720 * time.Hour},
+		{Domain: types.Domain{Main: "managed.traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")},
+	}
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+
+	secret := secrets.secrets["traefik/acme-traefik.wtf"]
+	assert.Equal(t, "acme-traefik.wtf", secret.Annotations[certManagerCertificateNameAnnotation])
+	assert.Equal(t, "traefik", secret.Annotations[certManagerIssuerNameAnnotation])
+	assert.Equal(t, certManagerIssuerKind, secret.Annotations[certManagerIssuerKindAnnotation])
+	assert.Equal(t, "traefik.wtf", secret.Annotations[certManagerCommonNameAnnotation])
+	assert.Equal(t, "traefik.wtf,www.traefik.wtf", secret.Annotations[certManagerAltNamesAnnotation])
+
+	require.Len(t, stubs.certificates, 2)
+	stub := stubs.certificates["traefik/acme-traefik.wtf"]
+	assert.Equal(t, "true", stub.Annotations[certManagerExternallyManagedAnnotation])
+	assert.Equal(t, []string{"traefik.wtf", "www.traefik.wtf"}, stub.Spec.DNSNames)
+	assert.Equal(t, "720h0m0s", stub.Spec.RenewBefore)
+	assert.Equal(t, getCertManagerIssuerRef("traefik"), stub.Spec.IssuerRef)
+	assert.Equal(t, managed, stubs.certificates["traefik/"+managed.Name])
+
+	// The stubs of the removed certificates are deleted, the Certificates which are not owned being kept
+	require.NoError(t, store.SaveCertificates(context.Background(), nil))
+	require.Len(t, stubs.certificates, 1)
+	assert.Equal(t, managed, stubs.certificates["traefik/"+managed.Name])
+}
@@ -0,0 +1,47 @@
+package acme
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+)
+
+// GetCertificateChain returns the PEM encoded chain of the certificate serving the server name, as found for the SNI: the leaf and the intermediates.
+// Only the certificates of the chain are returned, never a private key. It returns nil when no certificate serves the server name.
+// The certificate is read from the store, the certificates in memory being owned by the routine watching them.
+func (p *Provider) GetCertificateChain(serverName string) ([]byte, error) {
+	certificate, err := p.Store.GetCertificateByDomain(p.getContext(), serverName)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return getPEMCertificates(certificate.Certificate)
+}
+
+// getPEMCertificates returns the certificate blocks of the PEM content, the other blocks are dropped
+func getPEMCertificates(content []byte) ([]byte, error) {
+	var chain bytes.Buffer
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		if err := pem.Encode(&chain, &pem.Block{Type: block.Type, Bytes: block.Bytes}); err != nil {
+			return nil, err
+		}
+	}
+
+	if chain.Len() == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return chain.Bytes(), nil
+}
@@ -0,0 +1,39 @@
+package acme
+
+import (
+	"context"
+	"encoding/pem"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCertificateChain(t *testing.T) {
+	leaf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("leaf")})
+	intermediate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("intermediate")})
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("secret")})
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{
+		{Domain: types.Domain{Main: "*.traefik.wtf"}, Certificate: append(append(append([]byte{}, leaf...), privateKey...), intermediate...), Key: privateKey},
+		{Domain: types.Domain{Main: "broken.wtf"}, Certificate: []byte("not PEM"), Key: privateKey},
+	}))
+
+	p := &Provider{Configuration: &Configuration{}, Store: store}
+
+	chain, err := p.GetCertificateChain("WWW.traefik.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, string(leaf)+string(intermediate), string(chain))
+	assert.NotContains(t, string(chain), "PRIVATE KEY")
+
+	chain, err = p.GetCertificateChain("traefik.wtf")
+	require.NoError(t, err)
+	assert.Nil(t, chain)
+
+	_, err = p.GetCertificateChain("broken.wtf")
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package acme
+
+import (
+	"strings"
+	"sync"
+)
+
+// certificateIndex maps exact domains and wildcard base domains to the ACME certificates serving them
+type certificateIndex struct {
+	lock     sync.RWMutex
+	exact    map[string]*Certificate
+	wildcard map[string]*Certificate
+}
+
+func newCertificateIndex(certificates []*Certificate) *certificateIndex {
+	index := &certificateIndex{}
+	index.reset(certificates)
+	return index
+}
+
+// reset indexes the certificates in place of the ones indexed, for the callers holding the index
+func (i *certificateIndex) reset(certificates []*Certificate) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.exact = make(map[string]*Certificate)
+	i.wildcard = make(map[string]*Certificate)
+	for _, certificate := range certificates {
+		i.index(certificate)
+	}
+}
+
+// add indexes all the domains (Main and SANs) of the certificate
+func (i *certificateIndex) add(certificate *Certificate) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.index(certificate)
+}
+
+func (i *certificateIndex) index(certificate *Certificate) {
+	for _, domain := range certificate.Domain.ToStrArray() {
+		domain = normalizeDomain(domain)
+		if strings.HasPrefix(domain, "*.") {
+			i.wildcard[strings.TrimPrefix(domain, "*.")] = certificate
+		} else {
+			i.exact[domain] = certificate
+		}
+	}
+}
+
+// remove deletes the domains of the certificate from the index, if they still reference it
+func (i *certificateIndex) remove(certificate *Certificate) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, domain := range certificate.Domain.ToStrArray() {
+		domain = normalizeDomain(domain)
+		if strings.HasPrefix(domain, "*.") {
+			if i.wildcard[strings.TrimPrefix(domain, "*.")] == certificate {
+				delete(i.wildcard, strings.TrimPrefix(domain, "*."))
+			}
+		} else if i.exact[domain] == certificate {
+			delete(i.exact, domain)
+		}
+	}
+}
+
+// lookup returns the certificate serving the server name, an exact domain match takes precedence over a wildcard one
+func (i *certificateIndex) lookup(serverName string) *Certificate {
+	serverName = normalizeDomain(serverName)
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	if certificate, ok := i.exact[serverName]; ok {
+		return certificate
+	}
+
+	if idx := strings.Index(serverName, "."); idx > 0 {
+		if certificate, ok := i.wildcard[serverName[idx+1:]]; ok {
+			return certificate
+		}
+	}
+
+	return nil
+}
+
+// GetCertificateForDomain returns the ACME certificate serving the server name, or nil if there is none
+func (p *Provider) GetCertificateForDomain(serverName string) *Certificate {
+	if p.certificateIndex == nil {
+		return nil
+	}
+	return p.certificateIndex.lookup(serverName)
+}
@@ -0,0 +1,124 @@
+package acme
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateIndexLookup(t *testing.T) {
+	exact := &Certificate{Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}}
+	wildcard := &Certificate{Domain: types.Domain{Main: "*.traefik.wtf"}}
+	index := newCertificateIndex([]*Certificate{exact, wildcard})
+
+	testCases := []struct {
+		desc       string
+		serverName string
+		expected   *Certificate
+	}{
+		{
+			desc:       "exact match on main domain",
+			serverName: "traefik.wtf",
+			expected:   exact,
+		},
+		{
+			desc:       "exact match on SAN takes precedence over wildcard",
+			serverName: "www.traefik.wtf",
+			expected:   exact,
+		},
+		{
+			desc:       "case insensitive match with trailing dot",
+			serverName: "WWW.Traefik.WTF.",
+			expected:   exact,
+		},
+		{
+			desc:       "wildcard match",
+			serverName: "foo.traefik.wtf",
+			expected:   wildcard,
+		},
+		{
+			desc:       "wildcard does not match several labels",
+			serverName: "foo.bar.traefik.wtf",
+		},
+		{
+			desc:       "no match",
+			serverName: "traefik.io",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, index.lookup(test.serverName))
+		})
+	}
+}
+
+func TestCertificateIndexUpdate(t *testing.T) {
+	index := newCertificateIndex(nil)
+
+	oldCert := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}}
+	index.add(oldCert)
+	assert.Equal(t, oldCert, index.lookup("traefik.wtf"))
+
+	newCert := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}}
+	index.add(newCert)
+	index.remove(oldCert)
+	assert.Equal(t, newCert, index.lookup("traefik.wtf"), "removing a replaced certificate must not drop the new one")
+
+	index.remove(newCert)
+	assert.Nil(t, index.lookup("traefik.wtf"))
+}
+
+func TestCertificateIndexReset(t *testing.T) {
+	index := newCertificateIndex([]*Certificate{{Domain: types.Domain{Main: "*.traefik.wtf"}}})
+
+	reloaded := &Certificate{Domain: types.Domain{Main: "other.wtf"}}
+	index.reset([]*Certificate{reloaded})
+
+	assert.Nil(t, index.lookup("foo.traefik.wtf"))
+	assert.Equal(t, reloaded, index.lookup("other.wtf"))
+}
+
+func generateIndexCertificates(count int) []*Certificate {
+	var certificates []*Certificate
+	for i := 0; i < count; i++ {
+		certificates = append(certificates, &Certificate{Domain: types.Domain{Main: fmt.Sprintf("*.domain%d.traefik.wtf", i)}})
+	}
+	return certificates
+}
+
+// scanCertificates resolves the server name by iterating over all the certificates, as done before the index existed
+func scanCertificates(certificates []*Certificate, serverName string) *Certificate {
+	for _, certificate := range certificates {
+		for _, domain := range certificate.Domain.ToStrArray() {
+			if types.MatchDomain(serverName, domain) {
+				return certificate
+			}
+		}
+	}
+	return nil
+}
+
+func BenchmarkGetCertificateForDomain(b *testing.B) {
+	certificates := generateIndexCertificates(5000)
+	serverName := "www.domain4999.traefik.wtf"
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanCertificates(certificates, serverName)
+		}
+	})
+
+	b.Run("index", func(b *testing.B) {
+		provider := &Provider{certificateIndex: newCertificateIndex(certificates)}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			provider.GetCertificateForDomain(serverName)
+		}
+	})
+}
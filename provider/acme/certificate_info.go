@@ -0,0 +1,117 @@
+package acme
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/types"
+)
+
+// CertificateInfo describes a certificate of the store and its last renewal, without its private key
+type CertificateInfo struct {
+	*CertificateTransparency
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	// Issuer is the common name of the CA certificate which issued the certificate
+	Issuer        string            `json:"issuer,omitempty"`
+	ChallengeType string            `json:"challengeType,omitempty"`
+	LastRenewal   *RenewalResult    `json:"lastRenewal,omitempty"`
+	RenewalPaused bool              `json:"renewalPaused"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	External      bool              `json:"external"`
+	// RenewalTime is the time from which the certificate is renewed, delayed by its RenewalOffset when the renewals are staggered
+	RenewalTime   *time.Time `json:"renewalTime,omitempty"`
+	RenewalOffset string     `json:"renewalOffset,omitempty"`
+}
+
+// RenewalResult is the result of the last renewal of a certificate since the start
+type RenewalResult struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// renewalResults records the results of the renewals of the certificates, by main domain
+type renewalResults struct {
+	lock    sync.RWMutex
+	results map[string]*RenewalResult
+}
+
+func (r *renewalResults) record(domain types.Domain, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.results == nil {
+		r.results = make(map[string]*RenewalResult)
+	}
+
+	result := &RenewalResult{Time: time.Now()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	r.results[normalizeDomain(domain.Main)] = result
+}
+
+func (r *renewalResults) get(domain types.Domain) *RenewalResult {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.results[normalizeDomain(domain.Main)]
+}
+
+// renewalFailed records the failed renewal of the certificate of the domains
+func (p *Provider) renewalFailed(domain types.Domain, err error) {
+	p.renewals.record(domain, err)
+	p.expiry.renewalFailed(domain, err)
+}
+
+// renewed records the renewal of the certificate of the domains
+func (p *Provider) renewed(domain types.Domain) {
+	p.renewals.record(domain, nil)
+	p.expiry.renewed(domain)
+}
+
+// GetCertificatesInfo returns the certificates of the store, sorted by domain
+func (p *Provider) GetCertificatesInfo(ctx context.Context) ([]*CertificateInfo, error) {
+	certificates, err := p.Store.GetCertificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*CertificateInfo
+	for _, certificate := range certificates {
+		result = append(result, p.getCertificateInfo(certificate))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Domain < result[j].Domain
+	})
+
+	return result, nil
+}
+
+func (p *Provider) getCertificateInfo(certificate *Certificate) *CertificateInfo {
+	info := &CertificateInfo{
+		CertificateTransparency: newCertificateTransparency(certificate),
+		ChallengeType:           certificate.ChallengeType,
+		LastRenewal:             p.renewals.get(certificate.Domain),
+		RenewalPaused:           certificate.RenewalPaused,
+		Metadata:                certificate.Metadata,
+		External:                certificate.External,
+	}
+	if crt, err := parseCertificateLeaf(certificate.Certificate); err == nil {
+		notBefore := crt.NotBefore
+		info.NotBefore = &notBefore
+		info.Issuer = crt.Issuer.CommonName
+
+		// The external certificates are never renewed
+		if !certificate.External {
+			renewalTime := getRenewalTime(certificate, crt)
+			info.RenewalTime = &renewalTime
+		}
+	}
+	if offset := getStoredRenewBefore(certificate) - getScheduledRenewBefore(certificate); offset > 0 {
+		info.RenewalOffset = offset.String()
+	}
+	return info
+}
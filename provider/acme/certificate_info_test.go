@@ -0,0 +1,63 @@
+package acme
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewalResults(t *testing.T) {
+	results := renewalResults{}
+	assert.Nil(t, results.get(types.Domain{Main: "traefik.wtf"}))
+
+	results.record(types.Domain{Main: "traefik.wtf"}, errors.New("rate limited"))
+	result := results.get(types.Domain{Main: "TRAEFIK.wtf"})
+	require.NotNil(t, result)
+	assert.Equal(t, "rate limited", result.Error)
+	assert.False(t, result.Time.IsZero())
+
+	results.record(types.Domain{Main: "traefik.wtf"}, nil)
+	result = results.get(types.Domain{Main: "traefik.wtf"})
+	require.NotNil(t, result)
+	assert.Empty(t, result.Error)
+}
+
+func TestGetCertificatesInfo(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	crt := generateTestSCTCertificate(t, nil)
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{
+		{Domain: types.Domain{Main: "traefik.wtf"}, Key: []byte("key"), Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}), ChallengeType: "tls-alpn-01"},
+		{Domain: types.Domain{Main: "acme.wtf", SANs: []string{"www.acme.wtf"}}, Key: []byte("key")},
+	}))
+
+	p := &Provider{Store: store}
+	p.renewalFailed(types.Domain{Main: "acme.wtf"}, errors.New("rate limited"))
+
+	certificates, err := p.GetCertificatesInfo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 2)
+
+	assert.Equal(t, "acme.wtf", certificates[0].Domain)
+	assert.Equal(t, []string{"www.acme.wtf"}, certificates[0].SANs)
+	assert.Nil(t, certificates[0].NotBefore)
+	assert.Empty(t, certificates[0].Issuer)
+	require.NotNil(t, certificates[0].LastRenewal)
+	assert.Equal(t, "rate limited", certificates[0].LastRenewal.Error)
+
+	assert.Equal(t, "traefik.wtf", certificates[1].Domain)
+	require.NotNil(t, certificates[1].NotBefore)
+	assert.Equal(t, crt.NotBefore, *certificates[1].NotBefore)
+	assert.Equal(t, "traefik.wtf", certificates[1].Issuer)
+	assert.Equal(t, "tls-alpn-01", certificates[1].ChallengeType)
+	assert.Nil(t, certificates[1].LastRenewal)
+	require.NotNil(t, certificates[1].RenewalTime)
+	assert.Equal(t, crt.NotAfter.Add(-defaultRenewBefore), *certificates[1].RenewalTime)
+	assert.Empty(t, certificates[1].RenewalOffset)
+}
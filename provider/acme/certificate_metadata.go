@@ -0,0 +1,171 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/containous/traefik/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DomainMetadata holds the metadata of the certificates of a domain
+type DomainMetadata struct {
+	Domain   string            `description:"Domain (or wildcard domain) of the certificates"`
+	Metadata map[string]string `description:"Metadata of the certificates, by key"`
+}
+
+// InvalidMetadataError is returned when a metadata key is not a valid name of a Kubernetes label
+type InvalidMetadataError struct {
+	Key     string
+	Reasons []string
+}
+
+func (e *InvalidMetadataError) Error() string {
+	return fmt.Sprintf("invalid metadata key %q: %s", e.Key, strings.Join(e.Reasons, ", "))
+}
+
+// checkMetadataKey checks that the metadata key is a valid name of a Kubernetes label, without prefix:
+// at most 63 alphanumeric characters, '-', '_' or '.', beginning and ending with an alphanumeric character.
+// The metadata is exported to the annotations of the certificate Secrets under a prefix of Traefik.
+func checkMetadataKey(key string) error {
+	if strings.Contains(key, "/") {
+		return &InvalidMetadataError{Key: key, Reasons: []string{"a metadata key must not have a prefix"}}
+	}
+
+	if reasons := validation.IsQualifiedName(key); len(reasons) > 0 {
+		return &InvalidMetadataError{Key: key, Reasons: reasons}
+	}
+	return nil
+}
+
+func checkDomainsMetadata(domainsMetadata []DomainMetadata) error {
+	for _, domainMetadata := range domainsMetadata {
+		for key := range domainMetadata.Metadata {
+			if err := checkMetadataKey(key); err != nil {
+				return fmt.Errorf("invalid metadata of the domain %s: %v", domainMetadata.Domain, err)
+			}
+		}
+	}
+	return nil
+}
+
+// getDomainMetadata returns the configured metadata of the certificate of the given domain.
+// The metadata of the wildcard domains matching the domain are merged, an exact domain taking precedence.
+func (p *Provider) getDomainMetadata(domain types.Domain) map[string]string {
+	main := types.CanonicalDomain(domain.Main)
+
+	var metadata map[string]string
+	for _, override := range p.DomainsMetadata {
+		overrideDomain := types.CanonicalDomain(override.Domain)
+		if overrideDomain != main && types.MatchDomain(main, overrideDomain) {
+			metadata = mergeMetadata(metadata, override.Metadata)
+		}
+	}
+
+	for _, override := range p.DomainsMetadata {
+		if types.CanonicalDomain(override.Domain) == main {
+			metadata = mergeMetadata(metadata, override.Metadata)
+		}
+	}
+	return metadata
+}
+
+// refreshMetadata sets the configured metadata to the certificates, and returns whether one has changed.
+// The other metadata of the certificates, set with the API, is kept.
+func (p *Provider) refreshMetadata(certificates []*Certificate) bool {
+	changed := false
+	for _, certificate := range certificates {
+		metadata := mergeMetadata(certificate.Metadata, p.getDomainMetadata(certificate.Domain))
+		if !isMetadataEqual(certificate.Metadata, metadata) {
+			certificate.Metadata = metadata
+			changed = true
+		}
+	}
+	return changed
+}
+
+// mergeMetadata returns a new map of the metadata with the overrides, nil when both are empty
+func mergeMetadata(metadata, overrides map[string]string) map[string]string {
+	if len(metadata) == 0 && len(overrides) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(metadata)+len(overrides))
+	for key, value := range metadata {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+func isMetadataEqual(metadata, other map[string]string) bool {
+	if len(metadata) != len(other) {
+		return false
+	}
+	for key, value := range metadata {
+		if otherValue, ok := other[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// PatchCertificateMetadata patches the metadata of the certificate of the main domain, and returns the certificate.
+// The keys of the patch with a value are set, the keys without value are removed.
+// It returns nil when there is no certificate for the domain, and an InvalidMetadataError when a key is invalid.
+func (p *Provider) PatchCertificateMetadata(ctx context.Context, domain string, patch map[string]*string) (*CertificateInfo, error) {
+	if p.isPassive() {
+		return nil, ErrReadOnly
+	}
+
+	// The keys are checked in order, for the error to be the same for the same patch
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if patch[key] == nil {
+			continue
+		}
+		if err := checkMetadataKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	result := p.updateCertificate(ctx, domain, func(certificate *Certificate) bool {
+		metadata := mergeMetadata(certificate.Metadata, nil)
+		for key, value := range patch {
+			if value == nil {
+				delete(metadata, key)
+				continue
+			}
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[key] = *value
+		}
+		if len(metadata) == 0 {
+			metadata = nil
+		}
+
+		if isMetadataEqual(certificate.Metadata, metadata) {
+			return false
+		}
+		certificate.Metadata = metadata
+		return true
+	})
+	if result.err != nil || result.certificate == nil {
+		return nil, result.err
+	}
+
+	if result.updated {
+		domainsLogger(result.certificate.Domain.ToStrArray()).Infof("The metadata of the certificate for domains %v is updated.", result.certificate.Domain.ToStrArray())
+	}
+
+	return p.getCertificateInfo(result.certificate), nil
+}
@@ -0,0 +1,162 @@
+package acme
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckMetadataKey(t *testing.T) {
+	testCases := []struct {
+		key   string
+		valid bool
+	}{
+		{key: "team", valid: true},
+		{key: "cost-center_2.ticket", valid: true},
+		{key: "Team", valid: true},
+		{key: ""},
+		{key: "-team"},
+		{key: "team."},
+		{key: "team name"},
+		{key: "example.com/team"},
+		{key: strings.Repeat("a", 63), valid: true},
+		{key: strings.Repeat("a", 64)},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.key, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkMetadataKey(test.key)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, &InvalidMetadataError{}, err)
+			}
+		})
+	}
+}
+
+func TestCheckDomainsMetadata(t *testing.T) {
+	assert.NoError(t, checkDomainsMetadata([]DomainMetadata{{Domain: "traefik.wtf", Metadata: map[string]string{"team": "edge"}}}))
+
+	err := checkDomainsMetadata([]DomainMetadata{{Domain: "traefik.wtf", Metadata: map[string]string{"team/name": "edge"}}})
+	assert.EqualError(t, err, `invalid metadata of the domain traefik.wtf: invalid metadata key "team/name": a metadata key must not have a prefix`)
+}
+
+func TestGetDomainMetadata(t *testing.T) {
+	p := &Provider{Configuration: &Configuration{
+		DomainsMetadata: []DomainMetadata{
+			{Domain: "www.traefik.wtf", Metadata: map[string]string{"team": "web"}},
+			{Domain: "*.traefik.wtf", Metadata: map[string]string{"team": "edge", "ticket": "OPS-1"}},
+		},
+	}}
+
+	// An exact domain takes precedence over a wildcard one
+	assert.Equal(t, map[string]string{"team": "web", "ticket": "OPS-1"}, p.getDomainMetadata(types.Domain{Main: "WWW.traefik.wtf"}))
+	assert.Equal(t, map[string]string{"team": "edge", "ticket": "OPS-1"}, p.getDomainMetadata(types.Domain{Main: "api.traefik.wtf"}))
+	assert.Nil(t, p.getDomainMetadata(types.Domain{Main: "other.wtf"}))
+}
+
+func TestRefreshMetadata(t *testing.T) {
+	p := &Provider{Configuration: &Configuration{
+		DomainsMetadata: []DomainMetadata{{Domain: "traefik.wtf", Metadata: map[string]string{"team": "edge"}}},
+	}}
+
+	// The metadata set with the API is kept
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Metadata: map[string]string{"ticket": "OPS-1"}}
+	assert.True(t, p.refreshMetadata([]*Certificate{certificate}))
+	assert.Equal(t, map[string]string{"team": "edge", "ticket": "OPS-1"}, certificate.Metadata)
+
+	assert.False(t, p.refreshMetadata([]*Certificate{certificate}))
+}
+
+func TestProviderPatchCertificateMetadata(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate"), Key: []byte("key"), Metadata: map[string]string{"team": "edge"}}
+	configurationChan := make(chan types.ConfigMessage, 1)
+	p := &Provider{
+		Configuration:     &Configuration{},
+		Store:             store,
+		certificates:      []*Certificate{certificate},
+		configurationChan: configurationChan,
+	}
+
+	ticket := "OPS-1"
+	info, err := p.PatchCertificateMetadata(context.Background(), "traefik.wtf", map[string]*string{"ticket": &ticket, "team": nil})
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, map[string]string{"ticket": "OPS-1"}, info.Metadata)
+	assert.Equal(t, map[string]string{"ticket": "OPS-1"}, certificate.Metadata)
+
+	// The metadata is stored, and the served certificates are not refreshed
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1 && storedData.Certificates[0].Metadata["ticket"] == "OPS-1"
+	})
+	assert.Len(t, configurationChan, 0)
+
+	certificates, err := p.GetCertificatesInfo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, map[string]string{"ticket": "OPS-1"}, certificates[0].Metadata)
+
+	invalid := "value"
+	_, err = p.PatchCertificateMetadata(context.Background(), "traefik.wtf", map[string]*string{"not valid": &invalid})
+	assert.IsType(t, &InvalidMetadataError{}, err)
+
+	info, err = p.PatchCertificateMetadata(context.Background(), "unknown.wtf", map[string]*string{"ticket": &ticket})
+	require.NoError(t, err)
+	assert.Nil(t, info)
+}
+
+func TestCertificateSecretMetadata(t *testing.T) {
+	certificate := &Certificate{
+		Domain:      types.Domain{Main: "traefik.wtf"},
+		Certificate: []byte("cert"),
+		Key:         []byte("key"),
+		Metadata:    map[string]string{"team": "edge", "ticket": "OPS-1"},
+	}
+
+	secret := newCertificateSecret("traefik", "traefik", certificate)
+	assert.Equal(t, "edge", secret.Annotations[certificateSecretMetadataPrefix+"team"])
+	assert.Equal(t, "OPS-1", secret.Annotations[certificateSecretMetadataPrefix+"ticket"])
+
+	loaded, err := getSecretCertificate(*secret)
+	require.NoError(t, err)
+	assert.Equal(t, certificate.Metadata, loaded.Metadata)
+
+	// The Secret is outdated once a metadata key is removed
+	certificate.Metadata = map[string]string{"team": "edge"}
+	assert.False(t, isCertificateSecretUpToDate(*secret, newCertificateSecret("traefik", "traefik", certificate)))
+}
+
+func TestLocalStoreCertificateSecretsMetadata(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	client := newFakeSecretsClient()
+	secretsStore := newTestTLSSecretsStore(store.filename, client)
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key"), Metadata: map[string]string{"team": "edge", "ticket": "OPS-1"}}
+	require.NoError(t, secretsStore.SaveCertificates(context.Background(), []*Certificate{certificate}))
+
+	updated := copyCertificate(certificate)
+	delete(updated.Metadata, "ticket")
+	require.NoError(t, secretsStore.SaveCertificates(context.Background(), []*Certificate{updated}))
+
+	secret := client.secrets["traefik/acme-traefik.wtf"]
+	assert.Equal(t, "edge", secret.Annotations[certificateSecretMetadataPrefix+"team"])
+	assert.NotContains(t, secret.Annotations, certificateSecretMetadataPrefix+"ticket")
+
+	certificates, err := newTestTLSSecretsStore(store.filename, client).GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, map[string]string{"team": "edge"}, certificates[0].Metadata)
+}
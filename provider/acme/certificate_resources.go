@@ -0,0 +1,496 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/xenolf/lego/acme"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	certificateResourceGroup      = "acme.traefik.containous.io"
+	certificateResourceAPIVersion = certificateResourceGroup + "/v1alpha1"
+	certificateResourceKind       = "ACMECertificate"
+	certificateResourcePlural     = "acmecertificates"
+)
+
+// certificateResource is an ACMECertificate custom resource, describing a certificate held by a certificate Secret.
+// The spec is written with the certificate, the status is updated with its renewal state.
+type certificateResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   certificateResourceSpec   `json:"spec"`
+	Status certificateResourceStatus `json:"status,omitempty"`
+}
+
+type certificateResourceSpec struct {
+	Domain        types.Domain      `json:"domain"`
+	KeyType       acme.KeyType      `json:"keyType,omitempty"`
+	ChallengeType string            `json:"challengeType,omitempty"`
+	MustStaple    bool              `json:"mustStaple,omitempty"`
+	RenewBefore   string            `json:"renewBefore,omitempty"`
+	SecretName    string            `json:"secretName"`
+	RenewalPaused bool              `json:"renewalPaused,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	External      bool              `json:"external,omitempty"`
+}
+
+type certificateResourceStatus struct {
+	NotAfter      *metav1.Time                      `json:"notAfter,omitempty"`
+	RenewalInfo   *certificateResourceRenewal       `json:"renewalInfo,omitempty"`
+	RenewalOffset *certificateResourceRenewalOffset `json:"renewalOffset,omitempty"`
+}
+
+type certificateResourceRenewal struct {
+	SuggestedWindowStart metav1.Time `json:"suggestedWindowStart"`
+	SuggestedWindowEnd   metav1.Time `json:"suggestedWindowEnd"`
+	RetryAfter           metav1.Time `json:"retryAfter,omitempty"`
+	ExplanationURL       string      `json:"explanationURL,omitempty"`
+	FetchedAt            metav1.Time `json:"fetchedAt"`
+}
+
+type certificateResourceRenewalOffset struct {
+	Offset string `json:"offset"`
+	Window string `json:"window"`
+}
+
+type certificateResourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []certificateResource `json:"items"`
+}
+
+type certificateResourceEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// certificateResourcesClient manages the ACMECertificate resources of a namespace
+type certificateResourcesClient interface {
+	List(namespace string) ([]certificateResource, error)
+	Create(resource *certificateResource) error
+	Update(resource *certificateResource) error
+	UpdateStatus(resource *certificateResource) error
+	Delete(namespace, name string) error
+	// Watch calls changed on each change of the resources, until stop is closed or the watch ends
+	Watch(namespace string, stop <-chan struct{}, changed func()) error
+}
+
+// kubernetesCertificateResourcesClient requests the API of the resources, the client-go clientset having no client of custom resources
+type kubernetesCertificateResourcesClient struct {
+	client rest.Interface
+}
+
+func newInClusterCertificateResourcesClient() (certificateResourcesClient, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create in-cluster configuration: %s", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kubernetesCertificateResourcesClient{client: clientset.Discovery().RESTClient()}, nil
+}
+
+func getCertificateResourcesPath(namespace string, name ...string) string {
+	path := "/apis/" + certificateResourceAPIVersion + "/namespaces/" + namespace + "/" + certificateResourcePlural
+	for _, segment := range name {
+		path += "/" + segment
+	}
+	return path
+}
+
+func (c *kubernetesCertificateResourcesClient) list(namespace string) (*certificateResourceList, error) {
+	raw, err := c.client.Get().AbsPath(getCertificateResourcesPath(namespace)).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &certificateResourceList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *kubernetesCertificateResourcesClient) List(namespace string) ([]certificateResource, error) {
+	list, err := c.list(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (c *kubernetesCertificateResourcesClient) Create(resource *certificateResource) error {
+	return c.send(c.client.Post().AbsPath(getCertificateResourcesPath(resource.Namespace)), resource)
+}
+
+func (c *kubernetesCertificateResourcesClient) Update(resource *certificateResource) error {
+	return c.send(c.client.Put().AbsPath(getCertificateResourcesPath(resource.Namespace, resource.Name)), resource)
+}
+
+func (c *kubernetesCertificateResourcesClient) UpdateStatus(resource *certificateResource) error {
+	return c.send(c.client.Put().AbsPath(getCertificateResourcesPath(resource.Namespace, resource.Name, "status")), resource)
+}
+
+func (c *kubernetesCertificateResourcesClient) Delete(namespace, name string) error {
+	return c.client.Delete().AbsPath(getCertificateResourcesPath(namespace, name)).Do().Error()
+}
+
+// send writes the resource, and sets its resource version to the one of the written resource
+func (c *kubernetesCertificateResourcesClient) send(request *rest.Request, resource *certificateResource) error {
+	resource.APIVersion = certificateResourceAPIVersion
+	resource.Kind = certificateResourceKind
+
+	body, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
+	raw, err := request.SetHeader("Content-Type", "application/json").Body(body).Do().Raw()
+	if err != nil {
+		return err
+	}
+
+	written := &certificateResource{}
+	if err := json.Unmarshal(raw, written); err != nil {
+		return err
+	}
+	resource.ResourceVersion = written.ResourceVersion
+	return nil
+}
+
+func (c *kubernetesCertificateResourcesClient) Watch(namespace string, stop <-chan struct{}, changed func()) error {
+	// The watch starts from the listed resources, which are not notified as changes
+	list, err := c.list(namespace)
+	if err != nil {
+		return err
+	}
+
+	stream, err := c.client.Get().AbsPath(getCertificateResourcesPath(namespace)).
+		Param("watch", "true").
+		Param("resourceVersion", list.ResourceVersion).
+		Stream()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	safe.Go(func() {
+		select {
+		case <-stop:
+		case <-done:
+		}
+		stream.Close()
+	})
+
+	decoder := json.NewDecoder(stream)
+	for {
+		event := &certificateResourceEvent{}
+		if err := decoder.Decode(event); err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if event.Type == "ERROR" {
+			status := &metav1.Status{}
+			if err := json.Unmarshal(event.Object, status); err != nil {
+				return err
+			}
+			return &kerrors.StatusError{ErrStatus: *status}
+		}
+		changed()
+	}
+}
+
+func newCertificateResource(namespace string, certificate *Certificate) *certificateResource {
+	name := getCertificateSecretName(certificate.Domain)
+
+	resource := &certificateResource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: certificateResourceSpec{
+			Domain:        certificate.Domain,
+			KeyType:       certificate.KeyType,
+			ChallengeType: certificate.ChallengeType,
+			MustStaple:    certificate.MustStaple,
+			SecretName:    name,
+			RenewalPaused: certificate.RenewalPaused,
+			Metadata:      certificate.Metadata,
+			External:      certificate.External,
+		},
+	}
+	if certificate.RenewBefore > 0 {
+		resource.Spec.RenewBefore = certificate.RenewBefore.String()
+	}
+
+	if notAfter, err := getCertificateNotAfter(certificate.Certificate); err == nil {
+		resource.Status.NotAfter = &metav1.Time{Time: notAfter}
+	}
+	if info := certificate.RenewalInfo; info != nil {
+		resource.Status.RenewalInfo = &certificateResourceRenewal{
+			SuggestedWindowStart: metav1.Time{Time: info.SuggestedWindowStart},
+			SuggestedWindowEnd:   metav1.Time{Time: info.SuggestedWindowEnd},
+			RetryAfter:           metav1.Time{Time: info.RetryAfter},
+			ExplanationURL:       info.ExplanationURL,
+			FetchedAt:            metav1.Time{Time: info.FetchedAt},
+		}
+	}
+	if certificate.RenewalOffsetWindow > 0 {
+		resource.Status.RenewalOffset = &certificateResourceRenewalOffset{
+			Offset: certificate.RenewalOffset.String(),
+			Window: certificate.RenewalOffsetWindow.String(),
+		}
+	}
+
+	return resource
+}
+
+// setCertificateResource sets the state described by the resource to the certificate loaded from its Secret
+func setCertificateResource(certificate *Certificate, resource certificateResource) {
+	certificate.MustStaple = resource.Spec.MustStaple
+	certificate.RenewalPaused = resource.Spec.RenewalPaused
+	certificate.External = resource.Spec.External
+	certificate.Metadata = mergeMetadata(certificate.Metadata, resource.Spec.Metadata)
+	if renewBefore, err := time.ParseDuration(resource.Spec.RenewBefore); err == nil {
+		certificate.RenewBefore = renewBefore
+	}
+
+	if info := resource.Status.RenewalInfo; info != nil {
+		certificate.RenewalInfo = &RenewalInfo{
+			SuggestedWindowStart: info.SuggestedWindowStart.Time,
+			SuggestedWindowEnd:   info.SuggestedWindowEnd.Time,
+			RetryAfter:           info.RetryAfter.Time,
+			ExplanationURL:       info.ExplanationURL,
+			FetchedAt:            info.FetchedAt.Time,
+		}
+	}
+
+	if offset := resource.Status.RenewalOffset; offset != nil {
+		renewalOffset, offsetErr := time.ParseDuration(offset.Offset)
+		window, windowErr := time.ParseDuration(offset.Window)
+		if offsetErr == nil && windowErr == nil {
+			certificate.RenewalOffset = renewalOffset
+			certificate.RenewalOffsetWindow = window
+		}
+	}
+}
+
+func isCertificateResourceSpecUpToDate(existing certificateResource, resource *certificateResource) bool {
+	return existing.Spec.Domain.Main == resource.Spec.Domain.Main &&
+		fmt.Sprint(existing.Spec.Domain.SANs) == fmt.Sprint(resource.Spec.Domain.SANs) &&
+		existing.Spec.KeyType == resource.Spec.KeyType &&
+		existing.Spec.ChallengeType == resource.Spec.ChallengeType &&
+		existing.Spec.MustStaple == resource.Spec.MustStaple &&
+		existing.Spec.RenewBefore == resource.Spec.RenewBefore &&
+		existing.Spec.SecretName == resource.Spec.SecretName &&
+		existing.Spec.RenewalPaused == resource.Spec.RenewalPaused &&
+		existing.Spec.External == resource.Spec.External &&
+		isMetadataEqual(existing.Spec.Metadata, resource.Spec.Metadata)
+}
+
+func isCertificateResourceStatusUpToDate(existing certificateResource, resource *certificateResource) bool {
+	existingStatus, err := json.Marshal(existing.Status)
+	if err != nil {
+		return false
+	}
+	status, err := json.Marshal(resource.Status)
+	return err == nil && string(existingStatus) == string(status)
+}
+
+func (s *LocalStore) getCertificateResourcesClient() (certificateResourcesClient, error) {
+	s.secretsLock.Lock()
+	defer s.secretsLock.Unlock()
+
+	if s.certificateResourcesClient == nil {
+		client, err := newInClusterCertificateResourcesClient()
+		if err != nil {
+			return nil, err
+		}
+		s.certificateResourcesClient = newCachingCertificateResourcesClient(client, s.getCertificateSecretsCacheMaxAge(), s.countKubernetesRead)
+	}
+	return s.certificateResourcesClient, nil
+}
+
+// loadCertificateResources sets the state described by the ACMECertificate resources to the certificates loaded from the Secrets.
+// The resources of the certificates kept in the Secrets without resources are created, to migrate from the Secrets layout.
+func (s *LocalStore) loadCertificateResources(storedData *StoredData) error {
+	client, err := s.getCertificateResourcesClient()
+	if err != nil {
+		return fmt.Errorf("unable to create the Kubernetes client of the ACMECertificate resources: %v", err)
+	}
+
+	resources, err := client.List(s.CertificateSecrets.Namespace)
+	if err != nil {
+		return fmt.Errorf("unable to list the ACMECertificate resources of the namespace %q: %v", s.CertificateSecrets.Namespace, err)
+	}
+
+	resourcesBySecret := make(map[string]certificateResource)
+	for _, resource := range resources {
+		resourcesBySecret[resource.Spec.SecretName] = resource
+	}
+
+	var missing int
+	for _, certificate := range storedData.Certificates {
+		resource, ok := resourcesBySecret[getCertificateSecretName(certificate.Domain)]
+		if !ok {
+			missing++
+			continue
+		}
+		setCertificateResource(certificate, resource)
+	}
+
+	// The certificates still in the storage get their resources when they are moved to the Secrets
+	if missing == 0 || s.IsReadOnly() || atomic.LoadInt32(&s.certificatesInSecrets) == 0 {
+		return nil
+	}
+
+	if err := s.saveCertificateResources(storedData.Certificates); err != nil {
+		return err
+	}
+	s.secretsLogger(storeOperationMigrate).Infof("The ACMECertificate resources of %d certificates of the Secrets of the namespace %q are created.", missing, s.CertificateSecrets.Namespace)
+	return nil
+}
+
+// saveCertificateResources creates, updates and deletes the ACMECertificate resources to match the certificates
+func (s *LocalStore) saveCertificateResources(certificates []*Certificate) error {
+	client, err := s.getCertificateResourcesClient()
+	if err != nil {
+		return fmt.Errorf("unable to create the Kubernetes client of the ACMECertificate resources: %v", err)
+	}
+
+	namespace := s.CertificateSecrets.Namespace
+	resources, err := client.List(namespace)
+	if err != nil {
+		return fmt.Errorf("unable to list the ACMECertificate resources of the namespace %q: %v", namespace, err)
+	}
+
+	existingResources := make(map[string]certificateResource)
+	for _, resource := range resources {
+		existingResources[resource.Name] = resource
+	}
+
+	savedResources := make(map[string]struct{})
+	for _, certificate := range certificates {
+		resource := newCertificateResource(namespace, certificate)
+		savedResources[resource.Name] = struct{}{}
+
+		existing, ok := existingResources[resource.Name]
+		switch {
+		case !ok:
+			// The status is not written on creation, it is updated once the resource exists
+			err = client.Create(resource)
+			if kerrors.IsAlreadyExists(err) {
+				err = client.Update(resource)
+			}
+		case !isCertificateResourceSpecUpToDate(existing, resource):
+			resource.ResourceVersion = existing.ResourceVersion
+			err = client.Update(resource)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to save the ACMECertificate resource %s/%s: %v", namespace, resource.Name, err)
+		}
+
+		if ok && isCertificateResourceStatusUpToDate(existing, resource) {
+			continue
+		}
+		if len(resource.ResourceVersion) == 0 {
+			resource.ResourceVersion = existing.ResourceVersion
+		}
+		if err := client.UpdateStatus(resource); err != nil {
+			return fmt.Errorf("unable to update the status of the ACMECertificate resource %s/%s: %v", namespace, resource.Name, err)
+		}
+	}
+
+	for name := range existingResources {
+		if _, ok := savedResources[name]; ok {
+			continue
+		}
+		// The resource may already be deleted by another instance
+		if err := client.Delete(namespace, name); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete the ACMECertificate resource %s/%s: %v", namespace, name, err)
+		}
+	}
+
+	return nil
+}
+
+// watchCertificateResources reloads the storage when the ACMECertificate resources are changed, until stop is closed.
+// The watch is started again when it ends, the API server closing the watches after a timeout.
+func (s *LocalStore) watchCertificateResources(stop chan struct{}) {
+	client, err := s.getCertificateResourcesClient()
+	if err != nil {
+		s.secretsLogger(storeOperationLoad).Warnf("Unable to watch the ACMECertificate resources: %v", err)
+		return
+	}
+
+	delay := storageWatchDelay
+	changes := make(chan struct{}, 1)
+	changed := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+
+	safe.Go(func() {
+		for {
+			if err := client.Watch(s.CertificateSecrets.Namespace, stop, changed); err != nil {
+				s.secretsLogger(storeOperationLoad).Warnf("Error while watching the ACMECertificate resources: %v", err)
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
+			}
+		}
+	})
+
+	safe.Go(func() {
+		// The reload is delayed again by each change, as for the changes of the storage file
+		reload := time.NewTimer(delay)
+		reload.Stop()
+		defer reload.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+
+			case <-changes:
+				reload.Stop()
+				reload.Reset(delay)
+
+			case <-reload.C:
+				if err := s.Reload(); err != nil {
+					s.secretsLogger(storeOperationLoad).Errorf("Unable to reload the changed ACMECertificate resources: %v", err)
+				}
+			}
+		}
+	})
+}
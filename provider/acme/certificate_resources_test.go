@@ -0,0 +1,279 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var certificateResourcesGroupResource = schema.GroupResource{Group: certificateResourceGroup, Resource: certificateResourcePlural}
+
+type fakeCertificateResourcesClient struct {
+	lock      sync.Mutex
+	resources map[string]certificateResource
+	version   int
+	changes   chan struct{}
+}
+
+func newFakeCertificateResourcesClient() *fakeCertificateResourcesClient {
+	return &fakeCertificateResourcesClient{resources: make(map[string]certificateResource), changes: make(chan struct{}, 10)}
+}
+
+func (c *fakeCertificateResourcesClient) List(namespace string) ([]certificateResource, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var resources []certificateResource
+	for _, resource := range c.resources {
+		if resource.Namespace == namespace {
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
+
+func (c *fakeCertificateResourcesClient) Create(resource *certificateResource) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := resource.Namespace + "/" + resource.Name
+	if _, ok := c.resources[key]; ok {
+		return kerrors.NewAlreadyExists(certificateResourcesGroupResource, resource.Name)
+	}
+
+	// The status subresource is not written on creation
+	created := *resource
+	created.Status = certificateResourceStatus{}
+	c.write(key, &created)
+	resource.ResourceVersion = created.ResourceVersion
+	return nil
+}
+
+func (c *fakeCertificateResourcesClient) Update(resource *certificateResource) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := resource.Namespace + "/" + resource.Name
+	existing, ok := c.resources[key]
+	if !ok {
+		return kerrors.NewNotFound(certificateResourcesGroupResource, resource.Name)
+	}
+
+	updated := *resource
+	updated.Status = existing.Status
+	c.write(key, &updated)
+	resource.ResourceVersion = updated.ResourceVersion
+	return nil
+}
+
+func (c *fakeCertificateResourcesClient) UpdateStatus(resource *certificateResource) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := resource.Namespace + "/" + resource.Name
+	existing, ok := c.resources[key]
+	if !ok {
+		return kerrors.NewNotFound(certificateResourcesGroupResource, resource.Name)
+	}
+
+	existing.Status = resource.Status
+	c.write(key, &existing)
+	resource.ResourceVersion = existing.ResourceVersion
+	return nil
+}
+
+func (c *fakeCertificateResourcesClient) Delete(namespace, name string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := namespace + "/" + name
+	if _, ok := c.resources[key]; !ok {
+		return kerrors.NewNotFound(certificateResourcesGroupResource, name)
+	}
+	delete(c.resources, key)
+	c.notify()
+	return nil
+}
+
+func (c *fakeCertificateResourcesClient) Watch(namespace string, stop <-chan struct{}, changed func()) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-c.changes:
+			changed()
+		}
+	}
+}
+
+func (c *fakeCertificateResourcesClient) write(key string, resource *certificateResource) {
+	c.version++
+	resource.ResourceVersion = strconv.Itoa(c.version)
+	c.resources[key] = *resource
+	c.notify()
+}
+
+func (c *fakeCertificateResourcesClient) notify() {
+	select {
+	case c.changes <- struct{}{}:
+	default:
+	}
+}
+
+func newTestCertificateResourcesStore(filename string, secrets secretsClient, resources certificateResourcesClient) *LocalStore {
+	store := newTestTLSSecretsStore(filename, secrets)
+	store.CertificateSecrets.Resources = true
+	store.certificateResourcesClient = resources
+	return store
+}
+
+func TestLocalStoreCertificateResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	suggestedWindowStart := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	storedData := &StoredData{
+		Account: &Account{Email: "test@traefik.wtf", PrivateKeyType: "RSA4096"},
+		Certificates: []*Certificate{
+			{
+				Domain:      types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}},
+				Certificate: []byte("cert"), Key: []byte("key"), KeyType: "RSA4096", ChallengeType: "http-01",
+				MustStaple: true, RenewBefore: 720 * time.Hour, RenewalOffset: 100 * time.Hour, RenewalOffsetWindow: 720 * time.Hour,
+				RenewalInfo: &RenewalInfo{SuggestedWindowStart: suggestedWindowStart, SuggestedWindowEnd: suggestedWindowStart.Add(48 * time.Hour)},
+			},
+		},
+	}
+	content, err := json.Marshal(storedData)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filename, content, 0600))
+
+	secrets := newFakeSecretsClient()
+	resources := newFakeCertificateResourcesClient()
+	store := newTestCertificateResourcesStore(filename, secrets, resources)
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+	require.Len(t, secrets.secrets, 1)
+	require.Len(t, resources.resources, 1)
+
+	resource := resources.resources["traefik/acme-traefik.wtf"]
+	assert.Equal(t, "acme-traefik.wtf", resource.Spec.SecretName)
+	assert.Equal(t, "720h0m0s", resource.Spec.RenewBefore)
+	assert.True(t, resource.Spec.MustStaple)
+	require.NotNil(t, resource.Status.RenewalInfo)
+	assert.Equal(t, suggestedWindowStart, resource.Status.RenewalInfo.SuggestedWindowStart.UTC())
+	assert.Equal(t, &certificateResourceRenewalOffset{Offset: "100h0m0s", Window: "720h0m0s"}, resource.Status.RenewalOffset)
+
+	// The state described by the resources is loaded back with the certificates of the Secrets
+	certificates, err = newTestCertificateResourcesStore(filename, secrets, resources).GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, []byte("key"), certificates[0].Key)
+	assert.True(t, certificates[0].MustStaple)
+	assert.Equal(t, 720*time.Hour, certificates[0].RenewBefore)
+	assert.Equal(t, 100*time.Hour, certificates[0].RenewalOffset)
+	assert.Equal(t, 720*time.Hour, certificates[0].RenewalOffsetWindow)
+	require.NotNil(t, certificates[0].RenewalInfo)
+	assert.Equal(t, suggestedWindowStart, certificates[0].RenewalInfo.SuggestedWindowStart.UTC())
+
+	// The status is updated with the renewal state, the spec being unchanged
+	version := resources.resources["traefik/acme-traefik.wtf"].ResourceVersion
+	certificates[0].RenewalInfo.SuggestedWindowStart = suggestedWindowStart.Add(time.Hour)
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+	resource = resources.resources["traefik/acme-traefik.wtf"]
+	assert.NotEqual(t, version, resource.ResourceVersion)
+	assert.Equal(t, suggestedWindowStart.Add(time.Hour), resource.Status.RenewalInfo.SuggestedWindowStart.UTC())
+
+	// The resources of the removed certificates are deleted
+	require.NoError(t, store.SaveCertificates(context.Background(), nil))
+	assert.Empty(t, resources.resources)
+	assert.Empty(t, secrets.secrets)
+}
+
+func TestLocalStoreCertificateResourcesMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{}`), 0600))
+
+	// The certificates are already kept in the Secrets, without resources
+	secrets := newFakeSecretsClient()
+	secret := newCertificateSecret("traefik", "traefik", &Certificate{Domain: types.Domain{Main: "*.traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key"), ChallengeType: "dns-01"})
+	secrets.secrets["traefik/"+secret.Name] = *secret
+
+	testCases := []struct {
+		desc     string
+		readOnly bool
+		expected int
+	}{
+		{
+			desc:     "read-only store",
+			readOnly: true,
+		},
+		{
+			desc:     "store",
+			expected: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			resources := newFakeCertificateResourcesClient()
+			store := newTestCertificateResourcesStore(filename, secrets, resources)
+			store.SetReadOnly(test.readOnly)
+
+			certificates, err := store.GetCertificates(context.Background())
+			require.NoError(t, err)
+			require.Len(t, certificates, 1)
+
+			require.Len(t, resources.resources, test.expected)
+			if test.expected > 0 {
+				resource := resources.resources["traefik/acme-wildcard.traefik.wtf"]
+				assert.Equal(t, "*.traefik.wtf", resource.Spec.Domain.Main)
+				assert.Equal(t, "dns-01", resource.Spec.ChallengeType)
+			}
+		})
+	}
+}
+
+func TestLocalStoreCertificateResourcesWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{}`), 0600))
+
+	secrets := newFakeSecretsClient()
+	resources := newFakeCertificateResourcesClient()
+	store := newTestCertificateResourcesStore(filename, secrets, resources)
+
+	_, err = store.GetCertificates(context.Background())
+	require.NoError(t, err)
+
+	changes, unsubscribe := store.Subscribe(context.Background())
+	defer unsubscribe()
+
+	// Another instance saves a certificate
+	other := newTestCertificateResourcesStore(filename, secrets, resources)
+	require.NoError(t, other.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+
+	assert.Equal(t, StoreChange{Domains: []string{"traefik.wtf"}}, waitForStoreChange(t, changes))
+}
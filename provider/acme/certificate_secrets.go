@@ -0,0 +1,714 @@
+package acme
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/types"
+	"github.com/xenolf/lego/acme"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	certificateSecretLabel               = "traefik.containous.io/acme-certificate"
+	certificateSecretOwnerLabel          = "traefik.containous.io/acme-owner"
+	certificateSecretDomainLabel         = "traefik.containous.io/acme-domain"
+	certificateSecretDomainsAnnotation   = "traefik.containous.io/acme-domains"
+	certificateSecretKeyTypeAnnotation   = "traefik.containous.io/acme-key-type"
+	certificateSecretChallengeAnnotation = "traefik.containous.io/acme-challenge-type"
+	certificateSecretMigratedAnnotation  = "traefik.containous.io/acme-migrated-to"
+
+	// certificateSecretMetadataPrefix prefixes the metadata keys of the certificate in the annotations of its Secret
+	certificateSecretMetadataPrefix = "metadata.acme.traefik.containous.io/"
+
+	defaultCertificateSecretsOwner = "traefik"
+
+	// secretsFieldManager is the field manager of the fields of the certificate Secrets written by Traefik
+	secretsFieldManager = "traefik-acme"
+	applyPatchType      = ktypes.PatchType("application/apply-patch+yaml")
+)
+
+// TLSSecrets keeps the ACME certificates in per-domain kubernetes.io/tls Secrets, the storage holding the account only
+type TLSSecrets struct {
+	Namespace             string         `description:"Namespace of the certificate Secrets"`
+	Resources             bool           `description:"Describe the certificates and their renewal state with ACMECertificate resources, the Secrets holding the certificates and the private keys"`
+	ConfigMaps            bool           `description:"Keep the certificates and their private keys in ConfigMaps instead of Secrets, for non-sensitive certificates only"`
+	CacheMaxAge           parse.Duration `description:"Maximum age of the listed Secrets and resources reused by the store, listed again when older (default 30s)"`
+	Owner                 string         `description:"Owner label of the certificate Secrets, the Secrets of other owners are never changed (default traefik)"`
+	RetainOrphanedSecrets bool           `description:"Keep the Secrets of the removed certificates, released from their owner, instead of deleting them"`
+	CertManager           bool           `description:"Annotate the certificate Secrets with the annotations of cert-manager, for the tools relying on its metadata"`
+	CertManagerStubs      bool           `description:"Describe the certificates with stub cert-manager Certificates, marked as externally managed, the certificates being renewed by Traefik"`
+	Replicas              []TLSReplica   `description:"Clusters the certificate Secrets are replicated to after each save"`
+	FallbackNamespaces    []string       `description:"Namespaces searched for the certificate Secrets when the namespace has none, the Secrets found being copied to the namespace once"`
+	MirrorNamespaces      []string       `description:"Namespaces the certificate Secrets are copied to after each save, as mirrors never loaded back unless listed in the fallback namespaces"`
+}
+
+func (t *TLSSecrets) getOwner() string {
+	if len(t.Owner) > 0 {
+		return t.Owner
+	}
+	return defaultCertificateSecretsOwner
+}
+
+// secretsClient manages the certificate Secrets of a namespace.
+// The Secrets are the keyed data maps of the certificates, stored as Secrets or as ConfigMaps by the client.
+type secretsClient interface {
+	List(namespace string, selector string) ([]corev1.Secret, error)
+	Get(namespace, name string) (*corev1.Secret, error)
+	Create(secret *corev1.Secret) error
+	Update(secret *corev1.Secret) error
+	// Apply writes the fields of the Secret with a server-side apply of the field manager of Traefik, and sets the Secret to the applied one.
+	// The fields of the other managers are kept, the fields of Traefik missing from the Secret are removed.
+	Apply(secret *corev1.Secret, force bool) error
+	Delete(namespace, name string) error
+}
+
+type kubernetesSecretsClient struct {
+	clientset kubernetes.Interface
+}
+
+func newInClusterSecretsClient(configMaps bool) (secretsClient, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create in-cluster configuration: %s", err)
+	}
+
+	return newSecretsClientFromConfig(config, configMaps)
+}
+
+func newSecretsClientFromConfig(config *rest.Config, configMaps bool) (secretsClient, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if configMaps {
+		return &kubernetesConfigMapsClient{clientset: clientset}, nil
+	}
+	return &kubernetesSecretsClient{clientset: clientset}, nil
+}
+
+func (c *kubernetesSecretsClient) List(namespace string, selector string) ([]corev1.Secret, error) {
+	secrets, err := c.clientset.CoreV1().Secrets(namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return secrets.Items, nil
+}
+
+func (c *kubernetesSecretsClient) Get(namespace, name string) (*corev1.Secret, error) {
+	return c.clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c *kubernetesSecretsClient) Create(secret *corev1.Secret) error {
+	// The created fields are managed by the field manager of the applies
+	created := &corev1.Secret{}
+	err := c.clientset.CoreV1().RESTClient().Post().
+		Namespace(secret.Namespace).
+		Resource("secrets").
+		Param("fieldManager", secretsFieldManager).
+		Body(secret).
+		Do().
+		Into(created)
+	if err != nil {
+		return err
+	}
+	secret.ResourceVersion = created.ResourceVersion
+	return nil
+}
+
+func (c *kubernetesSecretsClient) Update(secret *corev1.Secret) error {
+	updated, err := c.clientset.CoreV1().Secrets(secret.Namespace).Update(secret)
+	if err != nil {
+		return err
+	}
+	secret.ResourceVersion = updated.ResourceVersion
+	return nil
+}
+
+func (c *kubernetesSecretsClient) Apply(secret *corev1.Secret, force bool) error {
+	body, err := json.Marshal(newSecretApplyConfiguration(secret))
+	if err != nil {
+		return err
+	}
+
+	applied := &corev1.Secret{}
+	err = c.clientset.CoreV1().RESTClient().Patch(applyPatchType).
+		Namespace(secret.Namespace).
+		Resource("secrets").
+		Name(secret.Name).
+		Param("fieldManager", secretsFieldManager).
+		Param("force", strconv.FormatBool(force)).
+		Body(body).
+		Do().
+		Into(applied)
+	if err != nil {
+		return err
+	}
+	*secret = *applied
+	return nil
+}
+
+func (c *kubernetesSecretsClient) Delete(namespace, name string) error {
+	return c.clientset.CoreV1().Secrets(namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
+// kubernetesConfigMapsClient stores the certificate Secrets as ConfigMaps, with the same names, labels, annotations and keys
+type kubernetesConfigMapsClient struct {
+	clientset kubernetes.Interface
+}
+
+func (c *kubernetesConfigMapsClient) List(namespace string, selector string) ([]corev1.Secret, error) {
+	configMaps, err := c.clientset.CoreV1().ConfigMaps(namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []corev1.Secret
+	for _, configMap := range configMaps.Items {
+		secrets = append(secrets, getConfigMapSecret(configMap))
+	}
+	return secrets, nil
+}
+
+func (c *kubernetesConfigMapsClient) Get(namespace, name string) (*corev1.Secret, error) {
+	configMap, err := c.clientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	secret := getConfigMapSecret(*configMap)
+	return &secret, nil
+}
+
+func (c *kubernetesConfigMapsClient) Create(secret *corev1.Secret) error {
+	created := &corev1.ConfigMap{}
+	err := c.clientset.CoreV1().RESTClient().Post().
+		Namespace(secret.Namespace).
+		Resource("configmaps").
+		Param("fieldManager", secretsFieldManager).
+		Body(newSecretConfigMap(secret)).
+		Do().
+		Into(created)
+	if err != nil {
+		return err
+	}
+	secret.ResourceVersion = created.ResourceVersion
+	return nil
+}
+
+func (c *kubernetesConfigMapsClient) Update(secret *corev1.Secret) error {
+	updated, err := c.clientset.CoreV1().ConfigMaps(secret.Namespace).Update(newSecretConfigMap(secret))
+	if err != nil {
+		return err
+	}
+	secret.ResourceVersion = updated.ResourceVersion
+	return nil
+}
+
+func (c *kubernetesConfigMapsClient) Apply(secret *corev1.Secret, force bool) error {
+	configMap := newSecretConfigMap(newSecretApplyConfiguration(secret))
+	configMap.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}
+
+	body, err := json.Marshal(configMap)
+	if err != nil {
+		return err
+	}
+
+	applied := &corev1.ConfigMap{}
+	err = c.clientset.CoreV1().RESTClient().Patch(applyPatchType).
+		Namespace(secret.Namespace).
+		Resource("configmaps").
+		Name(secret.Name).
+		Param("fieldManager", secretsFieldManager).
+		Param("force", strconv.FormatBool(force)).
+		Body(body).
+		Do().
+		Into(applied)
+	if err != nil {
+		return err
+	}
+	*secret = getConfigMapSecret(*applied)
+	return nil
+}
+
+func (c *kubernetesConfigMapsClient) Delete(namespace, name string) error {
+	return c.clientset.CoreV1().ConfigMaps(namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
+// newSecretApplyConfiguration returns the fields of the Secret written by Traefik, the fields of the other managers being absent
+func newSecretApplyConfiguration(secret *corev1.Secret) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secret.Name,
+			Namespace:   secret.Namespace,
+			Labels:      secret.Labels,
+			Annotations: secret.Annotations,
+		},
+		Type: secret.Type,
+		Data: secret.Data,
+	}
+}
+
+// newSecretConfigMap returns the ConfigMap holding the data of the Secret, the PEM blocks being text
+func newSecretConfigMap(secret *corev1.Secret) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: *secret.ObjectMeta.DeepCopy(),
+		Data:       make(map[string]string),
+	}
+	for key, value := range secret.Data {
+		configMap.Data[key] = string(value)
+	}
+	return configMap
+}
+
+// getConfigMapSecret returns the Secret of the data held by the ConfigMap
+func getConfigMapSecret(configMap corev1.ConfigMap) corev1.Secret {
+	secret := corev1.Secret{
+		ObjectMeta: configMap.ObjectMeta,
+		Type:       corev1.SecretTypeTLS,
+		Data:       make(map[string][]byte),
+	}
+	for key, value := range configMap.Data {
+		secret.Data[key] = []byte(value)
+	}
+	return secret
+}
+
+// createOrUpdateSecret creates the Secret, or updates it when another instance created it since it was listed.
+// A Secret of the same name which is not owned is never changed, it is not listed with the certificate Secrets.
+func createOrUpdateSecret(client secretsClient, secret *corev1.Secret, owner string) error {
+	err := client.Create(secret)
+	if !kerrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing, err := client.Get(secret.Namespace, secret.Name)
+	if err != nil {
+		return err
+	}
+	if !isOwnedSecret(*existing, owner) {
+		return fmt.Errorf("the Secret %s/%s already exists without the owner label %s=%s, it is not changed", secret.Namespace, secret.Name, certificateSecretOwnerLabel, owner)
+	}
+
+	return applySecret(client, secret)
+}
+
+// applySecret applies the fields of the certificate Secret, created when another instance deleted it since it was listed.
+// The apply is forced on a conflict with another manager: the conflicting fields are the ones written by Traefik, which are always its own.
+func applySecret(client secretsClient, secret *corev1.Secret) error {
+	err := client.Apply(secret, false)
+	if kerrors.IsConflict(err) {
+		return client.Apply(secret, true)
+	}
+	return err
+}
+
+// getCertificateSecretName returns the name of the Secret holding the certificate of the domain
+func getCertificateSecretName(domain types.Domain) string {
+	return "acme-" + strings.Replace(strings.ToLower(domain.Main), "*", "wildcard", -1)
+}
+
+// getCertificateSecretDomainLabel returns the value of the domain label of the Secret, empty when the domain is not a valid label value
+func getCertificateSecretDomainLabel(domain types.Domain) string {
+	value := strings.TrimPrefix(getCertificateSecretName(domain), "acme-")
+	if len(validation.IsValidLabelValue(value)) > 0 {
+		return ""
+	}
+	return value
+}
+
+// isOwnedSecret returns whether the certificate Secret belongs to the owner.
+// The Secrets labeled by the versions without owner label belong to any owner, they get the label on their next save.
+func isOwnedSecret(secret corev1.Secret, owner string) bool {
+	secretOwner, ok := secret.Labels[certificateSecretOwnerLabel]
+	if !ok {
+		return secret.Labels[certificateSecretLabel] == "true"
+	}
+	return secretOwner == owner
+}
+
+func newCertificateSecret(namespace, owner string, certificate *Certificate) *corev1.Secret {
+	labels := map[string]string{
+		certificateSecretLabel:      "true",
+		certificateSecretOwnerLabel: owner,
+	}
+	if domain := getCertificateSecretDomainLabel(certificate.Domain); len(domain) > 0 {
+		labels[certificateSecretDomainLabel] = domain
+	}
+
+	annotations := map[string]string{
+		certificateSecretDomainsAnnotation:   strings.Join(certificate.Domain.ToStrArray(), ","),
+		certificateSecretKeyTypeAnnotation:   string(certificate.KeyType),
+		certificateSecretChallengeAnnotation: certificate.ChallengeType,
+	}
+	for key, value := range certificate.Metadata {
+		annotations[certificateSecretMetadataPrefix+key] = value
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        getCertificateSecretName(certificate.Domain),
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificate.Certificate,
+			corev1.TLSPrivateKeyKey: certificate.Key,
+		},
+	}
+}
+
+// getSecretCertificate reconstructs the certificate held by a Secret
+func getSecretCertificate(secret corev1.Secret) (*Certificate, error) {
+	domains := strings.Split(secret.Annotations[certificateSecretDomainsAnnotation], ",")
+	if len(domains[0]) == 0 {
+		return nil, fmt.Errorf("the Secret %s/%s has no annotation %q", secret.Namespace, secret.Name, certificateSecretDomainsAnnotation)
+	}
+
+	certificate := &Certificate{
+		Domain:        types.Domain{Main: domains[0], SANs: domains[1:]},
+		Certificate:   secret.Data[corev1.TLSCertKey],
+		Key:           secret.Data[corev1.TLSPrivateKeyKey],
+		KeyType:       acme.KeyType(secret.Annotations[certificateSecretKeyTypeAnnotation]),
+		ChallengeType: secret.Annotations[certificateSecretChallengeAnnotation],
+	}
+	if len(certificate.Certificate) == 0 || len(certificate.Key) == 0 {
+		return nil, fmt.Errorf("the Secret %s/%s has no certificate or key", secret.Namespace, secret.Name)
+	}
+
+	for name, value := range secret.Annotations {
+		if strings.HasPrefix(name, certificateSecretMetadataPrefix) {
+			if certificate.Metadata == nil {
+				certificate.Metadata = make(map[string]string)
+			}
+			certificate.Metadata[strings.TrimPrefix(name, certificateSecretMetadataPrefix)] = value
+		}
+	}
+
+	return certificate, nil
+}
+
+func isCertificateSecretUpToDate(existing corev1.Secret, secret *corev1.Secret) bool {
+	for name, value := range secret.Labels {
+		if existing.Labels[name] != value {
+			return false
+		}
+	}
+	for name, value := range secret.Annotations {
+		if existing.Annotations[name] != value {
+			return false
+		}
+	}
+	// The removed metadata is removed from the annotations
+	for name := range existing.Annotations {
+		if _, ok := secret.Annotations[name]; !ok && strings.HasPrefix(name, certificateSecretMetadataPrefix) {
+			return false
+		}
+	}
+	return bytes.Equal(existing.Data[corev1.TLSCertKey], secret.Data[corev1.TLSCertKey]) &&
+		bytes.Equal(existing.Data[corev1.TLSPrivateKeyKey], secret.Data[corev1.TLSPrivateKeyKey])
+}
+
+func (s *LocalStore) getSecretsClient() (secretsClient, error) {
+	s.secretsLock.Lock()
+	defer s.secretsLock.Unlock()
+
+	if s.secretsClient == nil {
+		client, err := newInClusterSecretsClient(s.CertificateSecrets.ConfigMaps)
+		if err != nil {
+			return nil, err
+		}
+		// The lists are served from the last one, the saves of a burst of certificates listing the Secrets once
+		s.secretsClient = newCachingSecretsClient(client, s.getCertificateSecretsCacheMaxAge(), s.countKubernetesRead)
+
+		if s.CertificateSecrets.ConfigMaps {
+			s.secretsLogger(storeOperationLoad).Warnf("The private keys of the ACME certificates are stored in the ConfigMaps of the namespace %q, readable by everyone allowed to read its ConfigMaps: only use them for non-sensitive certificates, such as the ones of a staging CA.", s.CertificateSecrets.Namespace)
+		}
+	}
+	return s.secretsClient, nil
+}
+
+// loadCertificateSecrets sets the certificates of the stored data from the certificate Secrets.
+// The certificates still in the storage are kept, until they are moved to the Secrets on the next save.
+func (s *LocalStore) loadCertificateSecrets(storedData *StoredData) error {
+	client, err := s.getSecretsClient()
+	if err != nil {
+		return fmt.Errorf("unable to create the Kubernetes client of the certificate Secrets: %v", err)
+	}
+
+	secrets, err := client.List(s.CertificateSecrets.Namespace, certificateSecretLabel+"=true")
+	if err != nil {
+		return fmt.Errorf("unable to list the certificate Secrets of the namespace %q: %v", s.CertificateSecrets.Namespace, err)
+	}
+
+	// The mirrors copied to the namespace by another Traefik are never loaded
+	var ownedSecrets []corev1.Secret
+	for _, secret := range secrets {
+		if isOwnedSecret(secret, s.CertificateSecrets.getOwner()) && !isMirrorSecret(secret) {
+			ownedSecrets = append(ownedSecrets, secret)
+		}
+	}
+
+	// The Secrets of a namespace Traefik was moved from are copied, rather than starting without certificates
+	if len(ownedSecrets) == 0 && len(storedData.Certificates) == 0 && len(s.CertificateSecrets.FallbackNamespaces) > 0 {
+		ownedSecrets, err = s.migrateFallbackSecrets(client)
+		if err != nil {
+			return err
+		}
+	}
+
+	var certificates []*Certificate
+	secretNames := make(map[string]struct{})
+	for _, secret := range ownedSecrets {
+		certificate, err := getSecretCertificate(secret)
+		if err != nil {
+			s.secretsLogger(storeOperationLoad).WithField(logFieldSecret, secret.Name).Errorf("Unable to load the ACME certificate: %v", err)
+			continue
+		}
+		certificates = append(certificates, certificate)
+		secretNames[getCertificateSecretName(certificate.Domain)] = struct{}{}
+	}
+
+	if len(storedData.Certificates) == 0 {
+		atomic.StoreInt32(&s.certificatesInSecrets, 1)
+	} else {
+		s.secretsLogger(storeOperationLoad).Infof("The ACME certificates of the storage %s are moved to the Secrets of the namespace %q on the next save.", s.filename, s.CertificateSecrets.Namespace)
+	}
+
+	// The certificates of the Secrets take precedence over the ones of the storage
+	for _, certificate := range storedData.Certificates {
+		if _, ok := secretNames[getCertificateSecretName(certificate.Domain)]; !ok {
+			certificates = append(certificates, certificate)
+		}
+	}
+	storedData.Certificates = certificates
+
+	if s.CertificateSecrets.Resources {
+		return s.loadCertificateResources(storedData)
+	}
+	return nil
+}
+
+// migrateFallbackSecrets copies the certificate Secrets found in one of the fallback namespaces to the namespace, and annotates them as migrated.
+// The Secrets found in several fallback namespaces are not copied, the namespace to migrate from being unknown.
+// The mirrors of a fallback namespace are copied as the other Secrets, the namespace being explicitly listed.
+func (s *LocalStore) migrateFallbackSecrets(client secretsClient) ([]corev1.Secret, error) {
+	namespace := s.CertificateSecrets.Namespace
+	owner := s.CertificateSecrets.getOwner()
+
+	var found []string
+	fallbackSecrets := make(map[string][]corev1.Secret)
+	for _, fallback := range s.CertificateSecrets.FallbackNamespaces {
+		if fallback == namespace || len(fallbackSecrets[fallback]) > 0 {
+			continue
+		}
+
+		secrets, err := client.List(fallback, certificateSecretLabel+"=true")
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the certificate Secrets of the fallback namespace %q: %v", fallback, err)
+		}
+
+		for _, secret := range secrets {
+			if isOwnedSecret(secret, owner) && len(secret.Annotations[certificateSecretMigratedAnnotation]) == 0 {
+				fallbackSecrets[fallback] = append(fallbackSecrets[fallback], secret)
+			}
+		}
+		if len(fallbackSecrets[fallback]) > 0 {
+			found = append(found, fallback)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, nil
+	}
+	if len(found) > 1 {
+		return nil, fmt.Errorf("the certificate Secrets are found in the fallback namespaces %q, only the ones of a single namespace can be copied to the namespace %q", found, namespace)
+	}
+
+	fallback := found[0]
+	logger := s.secretsLogger(storeOperationMigrate)
+
+	// A read-only store loads the Secrets of the fallback namespace without copying them
+	if s.IsReadOnly() {
+		logger.Infof("The ACME certificates are loaded from the %d Secrets of the fallback namespace %q.", len(fallbackSecrets[fallback]), fallback)
+		return fallbackSecrets[fallback], nil
+	}
+
+	var secrets []corev1.Secret
+	for _, source := range fallbackSecrets[fallback] {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        source.Name,
+				Namespace:   namespace,
+				Labels:      source.Labels,
+				Annotations: getMigratedAnnotations(source),
+			},
+			Type: source.Type,
+			Data: source.Data,
+		}
+		if err := createOrUpdateSecret(client, secret, owner); err != nil {
+			return nil, fmt.Errorf("unable to copy the certificate Secret %s/%s to the namespace %q: %v", fallback, source.Name, namespace, err)
+		}
+		secrets = append(secrets, *secret)
+
+		migrated := source.DeepCopy()
+		if migrated.Annotations == nil {
+			migrated.Annotations = make(map[string]string)
+		}
+		migrated.Annotations[certificateSecretMigratedAnnotation] = namespace
+		if err := client.Update(migrated); err != nil {
+			return nil, fmt.Errorf("unable to annotate the migrated certificate Secret %s/%s: %v", fallback, source.Name, err)
+		}
+	}
+
+	logger.Infof("The %d certificate Secrets of the fallback namespace %q are copied to the namespace %q.", len(secrets), fallback, namespace)
+	return secrets, nil
+}
+
+// probeCertificateSecrets checks that the certificate Secrets of the namespace can be listed, from the API and not from the cache
+func (s *LocalStore) probeCertificateSecrets() error {
+	client, err := s.getSecretsClient()
+	if err != nil {
+		return fmt.Errorf("unable to create the Kubernetes client of the certificate Secrets: %v", err)
+	}
+	if cachingClient, ok := client.(*cachingSecretsClient); ok {
+		client = cachingClient.secretsClient
+	}
+
+	if _, err := client.List(s.CertificateSecrets.Namespace, certificateSecretLabel+"=true"); err != nil {
+		return fmt.Errorf("unable to list the certificate Secrets of the namespace %q: %v", s.CertificateSecrets.Namespace, err)
+	}
+
+	if s.CertificateSecrets.Resources {
+		resourcesClient, err := s.getCertificateResourcesClient()
+		if err != nil {
+			return fmt.Errorf("unable to create the Kubernetes client of the ACMECertificate resources: %v", err)
+		}
+		if cachingClient, ok := resourcesClient.(*cachingCertificateResourcesClient); ok {
+			resourcesClient = cachingClient.certificateResourcesClient
+		}
+		if _, err := resourcesClient.List(s.CertificateSecrets.Namespace); err != nil {
+			return fmt.Errorf("unable to list the ACMECertificate resources of the namespace %q: %v", s.CertificateSecrets.Namespace, err)
+		}
+	}
+	return nil
+}
+
+// saveCertificateSecrets creates, updates and deletes the certificate Secrets to match the certificates
+func (s *LocalStore) saveCertificateSecrets(certificates []*Certificate) error {
+	client, err := s.getSecretsClient()
+	if err != nil {
+		return fmt.Errorf("unable to create the Kubernetes client of the certificate Secrets: %v", err)
+	}
+
+	namespace := s.CertificateSecrets.Namespace
+	secrets, err := client.List(namespace, certificateSecretLabel+"=true")
+	if err != nil {
+		return fmt.Errorf("unable to list the certificate Secrets of the namespace %q: %v", namespace, err)
+	}
+
+	owner := s.CertificateSecrets.getOwner()
+	existingSecrets := make(map[string]corev1.Secret)
+	for _, secret := range secrets {
+		if isOwnedSecret(secret, owner) && !isMirrorSecret(secret) {
+			existingSecrets[secret.Name] = secret
+		}
+	}
+
+	savedSecrets := make(map[string]struct{})
+	for _, certificate := range certificates {
+		secret := newCertificateSecret(namespace, owner, certificate)
+		if s.CertificateSecrets.CertManager {
+			setCertManagerAnnotations(secret, owner, certificate)
+		}
+		savedSecrets[secret.Name] = struct{}{}
+
+		existing, ok := existingSecrets[secret.Name]
+		switch {
+		case !ok:
+			err = createOrUpdateSecret(client, secret, owner)
+		case !isCertificateSecretUpToDate(existing, secret):
+			err = applySecret(client, secret)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to save the certificate Secret %s/%s: %v", namespace, secret.Name, err)
+		}
+	}
+
+	var orphanedSecrets []corev1.Secret
+	for name, secret := range existingSecrets {
+		if _, ok := savedSecrets[name]; !ok {
+			orphanedSecrets = append(orphanedSecrets, secret)
+		}
+	}
+	if err := s.reconcileOrphanedSecrets(client, orphanedSecrets); err != nil {
+		return err
+	}
+
+	if s.CertificateSecrets.Resources {
+		if err := s.saveCertificateResources(certificates); err != nil {
+			return err
+		}
+	}
+
+	if s.CertificateSecrets.CertManagerStubs {
+		if err := s.saveCertManagerCertificates(certificates); err != nil {
+			return err
+		}
+	}
+
+	if atomic.CompareAndSwapInt32(&s.certificatesInSecrets, 0, 1) {
+		s.secretsLogger(storeOperationSave).Infof("The ACME certificates are moved from the storage %s to the Secrets of the namespace %q.", s.filename, namespace)
+	}
+
+	// The replicas are written in the background, their failures never fail the save
+	s.replicateCertificateSecrets(certificates)
+
+	return nil
+}
+
+// reconcileOrphanedSecrets deletes the owned Secrets whose domain is no longer the one of a stored certificate,
+// or releases them from their owner when they are retained: the released Secrets are no longer loaded nor changed.
+func (s *LocalStore) reconcileOrphanedSecrets(client secretsClient, secrets []corev1.Secret) error {
+	for _, secret := range secrets {
+		logger := s.secretsLogger(storeOperationSave).WithField(logFieldSecret, secret.Name)
+
+		if s.CertificateSecrets.RetainOrphanedSecrets {
+			released := secret.DeepCopy()
+			delete(released.Labels, certificateSecretLabel)
+			delete(released.Labels, certificateSecretOwnerLabel)
+			delete(released.Labels, certificateSecretDomainLabel)
+
+			// The Secret may already be deleted or released by another instance
+			if err := client.Update(released); err != nil && !kerrors.IsNotFound(err) {
+				return fmt.Errorf("unable to release the orphaned certificate Secret %s/%s: %v", secret.Namespace, secret.Name, err)
+			}
+			logger.Infof("The certificate Secret %s/%s no longer matches a stored certificate, it is retained without its owner label.", secret.Namespace, secret.Name)
+			continue
+		}
+
+		// The Secret may already be deleted by another instance
+		if err := client.Delete(secret.Namespace, secret.Name); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete the certificate Secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		}
+		logger.Infof("The certificate Secret %s/%s no longer matches a stored certificate, it is deleted.", secret.Namespace, secret.Name)
+	}
+	return nil
+}
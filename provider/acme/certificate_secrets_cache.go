@@ -0,0 +1,290 @@
+package acme
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultCertificateSecretsCacheMaxAge is the default maximum age of the listed certificate Secrets and ACMECertificate resources
+const defaultCertificateSecretsCacheMaxAge = 30 * time.Second
+
+// Sources of the lists of the Kubernetes objects
+const (
+	kubernetesReadSourceCache  = "cache"
+	kubernetesReadSourceDirect = "direct"
+)
+
+// objectsSnapshot tracks the freshness of the objects listed in a namespace, kept by the caching clients
+type objectsSnapshot struct {
+	maxAge    time.Duration
+	fetchedAt time.Time
+	namespace string
+	selector  string
+}
+
+func (s *objectsSnapshot) isFresh(namespace, selector string) bool {
+	return !s.fetchedAt.IsZero() && s.namespace == namespace && s.selector == selector && time.Since(s.fetchedAt) < s.maxAge
+}
+
+func (s *objectsSnapshot) set(namespace, selector string) {
+	s.fetchedAt = time.Now()
+	s.namespace = namespace
+	s.selector = selector
+}
+
+func (s *objectsSnapshot) invalidate() {
+	s.fetchedAt = time.Time{}
+}
+
+// cachingSecretsClient serves the lists of the certificate Secrets from the last list, kept up to date with the writes of the client,
+// until it is older than its maximum age or invalidated by a change of another instance
+type cachingSecretsClient struct {
+	secretsClient
+	countRead func(object, source string)
+
+	lock     sync.Mutex
+	snapshot objectsSnapshot
+	secrets  map[string]corev1.Secret
+}
+
+func newCachingSecretsClient(client secretsClient, maxAge time.Duration, countRead func(object, source string)) *cachingSecretsClient {
+	return &cachingSecretsClient{
+		secretsClient: client,
+		countRead:     countRead,
+		snapshot:      objectsSnapshot{maxAge: maxAge},
+	}
+}
+
+func (c *cachingSecretsClient) List(namespace string, selector string) ([]corev1.Secret, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.snapshot.isFresh(namespace, selector) {
+		c.countRead("secrets", kubernetesReadSourceCache)
+
+		var secrets []corev1.Secret
+		for _, secret := range c.secrets {
+			secrets = append(secrets, *secret.DeepCopy())
+		}
+		return secrets, nil
+	}
+
+	c.countRead("secrets", kubernetesReadSourceDirect)
+	secrets, err := c.secretsClient.List(namespace, selector)
+	if err != nil {
+		c.snapshot.invalidate()
+		return nil, err
+	}
+
+	c.secrets = make(map[string]corev1.Secret)
+	for _, secret := range secrets {
+		c.secrets[secret.Name] = *secret.DeepCopy()
+	}
+	c.snapshot.set(namespace, selector)
+	return secrets, nil
+}
+
+func (c *cachingSecretsClient) Create(secret *corev1.Secret) error {
+	return c.written(secret, c.secretsClient.Create(secret))
+}
+
+func (c *cachingSecretsClient) Update(secret *corev1.Secret) error {
+	err := c.secretsClient.Update(secret)
+	if kerrors.IsConflict(err) {
+		// The version of the listed Secret may be outdated, the certificate is the one to keep
+		secret.ResourceVersion = ""
+		err = c.secretsClient.Update(secret)
+	}
+	return c.written(secret, err)
+}
+
+func (c *cachingSecretsClient) Apply(secret *corev1.Secret, force bool) error {
+	return c.written(secret, c.secretsClient.Apply(secret, force))
+}
+
+func (c *cachingSecretsClient) Delete(namespace, name string) error {
+	err := c.secretsClient.Delete(namespace, name)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil && !kerrors.IsNotFound(err) {
+		c.snapshot.invalidate()
+		return err
+	}
+	delete(c.secrets, name)
+	return err
+}
+
+// written sets the written Secret in the list, which is listed again after a failed write
+func (c *cachingSecretsClient) written(secret *corev1.Secret, err error) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil || secret.Namespace != c.snapshot.namespace {
+		c.snapshot.invalidate()
+		return err
+	}
+	c.secrets[secret.Name] = *secret.DeepCopy()
+	return nil
+}
+
+func (c *cachingSecretsClient) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.snapshot.invalidate()
+}
+
+// cachingCertificateResourcesClient serves the lists of the ACMECertificate resources as cachingSecretsClient does for the Secrets
+type cachingCertificateResourcesClient struct {
+	certificateResourcesClient
+	countRead func(object, source string)
+
+	lock      sync.Mutex
+	snapshot  objectsSnapshot
+	resources map[string]certificateResource
+}
+
+func newCachingCertificateResourcesClient(client certificateResourcesClient, maxAge time.Duration, countRead func(object, source string)) *cachingCertificateResourcesClient {
+	return &cachingCertificateResourcesClient{
+		certificateResourcesClient: client,
+		countRead:                  countRead,
+		snapshot:                   objectsSnapshot{maxAge: maxAge},
+	}
+}
+
+func (c *cachingCertificateResourcesClient) List(namespace string) ([]certificateResource, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.snapshot.isFresh(namespace, "") {
+		c.countRead(certificateResourcePlural, kubernetesReadSourceCache)
+
+		var resources []certificateResource
+		for _, resource := range c.resources {
+			resources = append(resources, copyCertificateResource(resource))
+		}
+		return resources, nil
+	}
+
+	c.countRead(certificateResourcePlural, kubernetesReadSourceDirect)
+	resources, err := c.certificateResourcesClient.List(namespace)
+	if err != nil {
+		c.snapshot.invalidate()
+		return nil, err
+	}
+
+	c.resources = make(map[string]certificateResource)
+	for _, resource := range resources {
+		c.resources[resource.Name] = copyCertificateResource(resource)
+	}
+	c.snapshot.set(namespace, "")
+	return resources, nil
+}
+
+func (c *cachingCertificateResourcesClient) Create(resource *certificateResource) error {
+	return c.written(resource, c.certificateResourcesClient.Create(resource), false)
+}
+
+func (c *cachingCertificateResourcesClient) Update(resource *certificateResource) error {
+	return c.written(resource, c.certificateResourcesClient.Update(resource), false)
+}
+
+func (c *cachingCertificateResourcesClient) UpdateStatus(resource *certificateResource) error {
+	return c.written(resource, c.certificateResourcesClient.UpdateStatus(resource), true)
+}
+
+func (c *cachingCertificateResourcesClient) Delete(namespace, name string) error {
+	err := c.certificateResourcesClient.Delete(namespace, name)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil && !kerrors.IsNotFound(err) {
+		c.snapshot.invalidate()
+		return err
+	}
+	delete(c.resources, name)
+	return err
+}
+
+// written sets the written resource in the list, the status being only written by the updates of the status subresource
+func (c *cachingCertificateResourcesClient) written(resource *certificateResource, err error, status bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil || resource.Namespace != c.snapshot.namespace {
+		c.snapshot.invalidate()
+		return err
+	}
+
+	written := copyCertificateResource(*resource)
+	if !status {
+		written.Status = c.resources[resource.Name].Status
+	}
+	c.resources[resource.Name] = written
+	return nil
+}
+
+func (c *cachingCertificateResourcesClient) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.snapshot.invalidate()
+}
+
+func copyCertificateResource(resource certificateResource) certificateResource {
+	resourceCopy := resource
+	resource.ObjectMeta.DeepCopyInto(&resourceCopy.ObjectMeta)
+	resourceCopy.Spec.Domain.SANs = append([]string(nil), resource.Spec.Domain.SANs...)
+	if info := resource.Status.RenewalInfo; info != nil {
+		infoCopy := *info
+		resourceCopy.Status.RenewalInfo = &infoCopy
+	}
+	if offset := resource.Status.RenewalOffset; offset != nil {
+		offsetCopy := *offset
+		resourceCopy.Status.RenewalOffset = &offsetCopy
+	}
+	if resource.Status.NotAfter != nil {
+		notAfter := *resource.Status.NotAfter
+		resourceCopy.Status.NotAfter = &notAfter
+	}
+	return resourceCopy
+}
+
+// countKubernetesRead counts a list of the Kubernetes objects of the store, served by the cache or read from the API
+func (s *LocalStore) countKubernetesRead(object, source string) {
+	s.metricsLock.Lock()
+	registry := s.metricsRegistry
+	s.metricsLock.Unlock()
+
+	if registry == nil {
+		return
+	}
+
+	registry.ACMEStoreKubernetesReadsCounter().With("object", object, "source", source).Add(1)
+}
+
+// invalidateKubernetesCache drops the listed Secrets and resources, changed by another instance
+func (s *LocalStore) invalidateKubernetesCache() {
+	s.secretsLock.Lock()
+	defer s.secretsLock.Unlock()
+
+	if client, ok := s.secretsClient.(*cachingSecretsClient); ok {
+		client.invalidate()
+	}
+	if client, ok := s.certificateResourcesClient.(*cachingCertificateResourcesClient); ok {
+		client.invalidate()
+	}
+}
+
+func (s *LocalStore) getCertificateSecretsCacheMaxAge() time.Duration {
+	if s.CertificateSecrets.CacheMaxAge > 0 {
+		return time.Duration(s.CertificateSecrets.CacheMaxAge)
+	}
+	return defaultCertificateSecretsCacheMaxAge
+}
@@ -0,0 +1,140 @@
+package acme
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type readCounts map[string]int
+
+func (c readCounts) count(object, source string) {
+	c[object+"/"+source]++
+}
+
+// conflictingSecretsClient rejects the first update of each Secret, as when its listed version is outdated
+type conflictingSecretsClient struct {
+	*fakeSecretsClient
+	conflicts map[string]bool
+	err       error
+}
+
+func (c *conflictingSecretsClient) Update(secret *corev1.Secret) error {
+	if c.err != nil {
+		return c.err
+	}
+	if len(secret.ResourceVersion) > 0 && !c.conflicts[secret.Name] {
+		c.conflicts[secret.Name] = true
+		return kerrors.NewConflict(corev1.Resource("secrets"), secret.Name, errors.New("outdated version"))
+	}
+	return c.fakeSecretsClient.Update(secret)
+}
+
+func TestCachingSecretsClient(t *testing.T) {
+	inner := &conflictingSecretsClient{fakeSecretsClient: newFakeSecretsClient(), conflicts: make(map[string]bool)}
+	reads := readCounts{}
+	client := newCachingSecretsClient(inner, time.Hour, reads.count)
+
+	secret := newCertificateSecret("traefik", "traefik", &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")})
+	inner.secrets["traefik/"+secret.Name] = *secret
+
+	// The Secrets are listed from the API once, then served from the cache
+	for i := 0; i < 3; i++ {
+		secrets, err := client.List("traefik", certificateSecretLabel+"=true")
+		require.NoError(t, err)
+		assert.Len(t, secrets, 1)
+	}
+	assert.Equal(t, readCounts{"secrets/direct": 1, "secrets/cache": 2}, reads)
+
+	// The writes of the client are served without listing the Secrets again
+	other := newCertificateSecret("traefik", "traefik", &Certificate{Domain: types.Domain{Main: "*.traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")})
+	require.NoError(t, client.Create(other))
+	require.NoError(t, client.Delete("traefik", secret.Name))
+
+	secrets, err := client.List("traefik", certificateSecretLabel+"=true")
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, other.Name, secrets[0].Name)
+	assert.Equal(t, 1, reads["secrets/direct"])
+
+	// The Secret is written again when its listed version is outdated
+	other.ResourceVersion = "1"
+	other.Data[corev1.TLSCertKey] = []byte("renewed cert")
+	require.NoError(t, client.Update(other))
+	assert.Equal(t, []byte("renewed cert"), inner.secrets["traefik/"+other.Name].Data[corev1.TLSCertKey])
+
+	// The Secrets are listed again after a failed write
+	inner.err = errors.New("unavailable")
+	require.Error(t, client.Update(other))
+	_, err = client.List("traefik", certificateSecretLabel+"=true")
+	require.NoError(t, err)
+	assert.Equal(t, 2, reads["secrets/direct"])
+
+	// The Secrets are listed again once invalidated
+	client.invalidate()
+	_, err = client.List("traefik", certificateSecretLabel+"=true")
+	require.NoError(t, err)
+	assert.Equal(t, 3, reads["secrets/direct"])
+}
+
+func TestCachingSecretsClientMaxAge(t *testing.T) {
+	reads := readCounts{}
+	client := newCachingSecretsClient(newFakeSecretsClient(), time.Millisecond, reads.count)
+
+	_, err := client.List("traefik", certificateSecretLabel+"=true")
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = client.List("traefik", certificateSecretLabel+"=true")
+	require.NoError(t, err)
+
+	assert.Equal(t, readCounts{"secrets/direct": 2}, reads)
+}
+
+func TestCachingCertificateResourcesClient(t *testing.T) {
+	inner := newFakeCertificateResourcesClient()
+	reads := readCounts{}
+	client := newCachingCertificateResourcesClient(inner, time.Hour, reads.count)
+
+	_, err := client.List("traefik")
+	require.NoError(t, err)
+
+	resource := newCertificateResource("traefik", &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, RenewalInfo: &RenewalInfo{ExplanationURL: "https://traefik.wtf"}})
+	require.NoError(t, client.Create(resource))
+
+	// The status is only listed once written to the status subresource
+	resources, err := client.List("traefik")
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Nil(t, resources[0].Status.RenewalInfo)
+
+	require.NoError(t, client.UpdateStatus(resource))
+	resources, err = client.List("traefik")
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	require.NotNil(t, resources[0].Status.RenewalInfo)
+	assert.Equal(t, inner.resources["traefik/acme-traefik.wtf"].ResourceVersion, resources[0].ResourceVersion)
+
+	assert.Equal(t, readCounts{certificateResourcePlural + "/direct": 1, certificateResourcePlural + "/cache": 2}, reads)
+}
+
+func TestLocalStoreProbeCertificateSecrets(t *testing.T) {
+	reads := readCounts{}
+	store := &LocalStore{
+		CertificateSecrets: &TLSSecrets{Namespace: "traefik"},
+		secretsClient:      newCachingSecretsClient(newFakeSecretsClient(), time.Hour, reads.count),
+	}
+
+	// The probes check the API, they are not served from the cache
+	_, err := store.secretsClient.List("traefik", certificateSecretLabel+"=true")
+	require.NoError(t, err)
+	require.NoError(t, store.probeCertificateSecrets())
+	require.NoError(t, store.probeCertificateSecrets())
+
+	assert.Equal(t, readCounts{"secrets/direct": 1}, reads)
+}
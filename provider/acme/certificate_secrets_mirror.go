@@ -0,0 +1,147 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// certificateSecretMirrorAnnotation holds the namespace of the certificate Secrets a mirrored Secret is copied from
+const certificateSecretMirrorAnnotation = "traefik.containous.io/acme-mirror-of"
+
+// isMirrorSecret returns whether the certificate Secret is the mirror of the Secret of another namespace
+func isMirrorSecret(secret corev1.Secret) bool {
+	return len(secret.Annotations[certificateSecretMirrorAnnotation]) > 0
+}
+
+// getMigratedAnnotations returns the annotations of the Secret copied from a fallback namespace, without the ones of a mirror:
+// the copied Secret is a certificate Secret of the namespace.
+func getMigratedAnnotations(source corev1.Secret) map[string]string {
+	if !isMirrorSecret(source) {
+		return source.Annotations
+	}
+
+	annotations := make(map[string]string, len(source.Annotations))
+	for name, value := range source.Annotations {
+		if name != certificateSecretMirrorAnnotation && name != certificateSecretGenerationAnnotation {
+			annotations[name] = value
+		}
+	}
+	return annotations
+}
+
+// getMirrorNamespaces returns the namespaces the certificate Secrets are mirrored to, without their own namespace and the duplicates
+func (t *TLSSecrets) getMirrorNamespaces() []string {
+	var namespaces []string
+	seen := map[string]struct{}{t.Namespace: {}}
+	for _, namespace := range t.MirrorNamespaces {
+		if _, ok := seen[namespace]; ok || len(namespace) == 0 {
+			continue
+		}
+		seen[namespace] = struct{}{}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces
+}
+
+// getMirrorReplicators returns the replicators of the mirror namespaces, which write the mirrors with the client of the certificate Secrets.
+// It is called on the first save with the lock of the Secrets held, the mirrors of the namespaces no longer listed being pruned in the background.
+func (s *LocalStore) getMirrorReplicators() []*secretsReplicator {
+	// The mirrors are listed from the API, the cache holding the Secrets of the namespace only
+	client := s.secretsClient
+	if cachingClient, ok := client.(*cachingSecretsClient); ok {
+		client = cachingClient.secretsClient
+	}
+
+	namespaces := s.CertificateSecrets.getMirrorNamespaces()
+	safe.Go(func() { s.pruneMirrorNamespaces(client, namespaces) })
+
+	var replicators []*secretsReplicator
+	for _, namespace := range namespaces {
+		name := "mirror-" + namespace
+		replicator := &secretsReplicator{
+			name:        name,
+			namespace:   namespace,
+			owner:       s.CertificateSecrets.getOwner(),
+			certManager: s.CertificateSecrets.CertManager,
+			mirrorOf:    s.CertificateSecrets.Namespace,
+			client:      client,
+			logger:      s.secretsLogger(storeOperationSave).WithField("mirror", namespace),
+			setLag:      func(lag time.Duration) { s.setReplicationLag(name, lag) },
+			changes:     make(chan struct{}, 1),
+		}
+		replicators = append(replicators, replicator)
+		safe.Go(func() { replicator.run(s.closing) })
+	}
+	return replicators
+}
+
+// pruneMirrorNamespaces deletes the mirrors of the namespaces mirrored by a previous configuration, and stores the mirror namespaces.
+// A namespace whose mirrors can not be deleted stays in the storage, to be pruned again on the next start.
+func (s *LocalStore) pruneMirrorNamespaces(client secretsClient, namespaces []string) {
+	logger := s.secretsLogger(storeOperationSave)
+
+	storedData, err := s.get(context.Background())
+	if err != nil {
+		logger.Errorf("Unable to read the mirror namespaces of the certificate Secrets: %v", err)
+		return
+	}
+
+	s.lock.RLock()
+	mirrored := append([]string(nil), storedData.MirrorNamespaces...)
+	s.lock.RUnlock()
+
+	listed := make(map[string]struct{}, len(namespaces))
+	for _, namespace := range namespaces {
+		listed[namespace] = struct{}{}
+	}
+
+	retained := append([]string(nil), namespaces...)
+	for _, namespace := range mirrored {
+		if _, ok := listed[namespace]; ok {
+			continue
+		}
+
+		if err := s.pruneMirrorSecrets(client, namespace); err != nil {
+			logger.WithField("mirror", namespace).Errorf("Unable to prune the mirrors of the namespace %q, retrying on the next start: %v", namespace, err)
+			retained = append(retained, namespace)
+			continue
+		}
+		logger.WithField("mirror", namespace).Infof("The namespace %q is no longer a mirror namespace, its mirrors are deleted.", namespace)
+	}
+
+	if reflect.DeepEqual(mirrored, retained) {
+		return
+	}
+
+	err = s.Update(context.Background(), func(data *StoredData) error {
+		data.MirrorNamespaces = retained
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("Unable to store the mirror namespaces of the certificate Secrets: %v", err)
+	}
+}
+
+// pruneMirrorSecrets deletes the owned mirrors of the certificate Secrets in the namespace, the other Secrets are not changed
+func (s *LocalStore) pruneMirrorSecrets(client secretsClient, namespace string) error {
+	secrets, err := client.List(namespace, certificateSecretLabel+"=true")
+	if err != nil {
+		return fmt.Errorf("unable to list the certificate Secrets: %v", err)
+	}
+
+	for _, secret := range secrets {
+		if !isOwnedSecret(secret, s.CertificateSecrets.getOwner()) || secret.Annotations[certificateSecretMirrorAnnotation] != s.CertificateSecrets.Namespace {
+			continue
+		}
+		if err := client.Delete(namespace, secret.Name); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete the mirror %s: %v", secret.Name, err)
+		}
+	}
+	return nil
+}
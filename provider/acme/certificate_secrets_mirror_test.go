@@ -0,0 +1,119 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTLSSecretsGetMirrorNamespaces(t *testing.T) {
+	secrets := &TLSSecrets{Namespace: "traefik", MirrorNamespaces: []string{"backup", "traefik", "", "break-glass", "backup"}}
+	assert.Equal(t, []string{"backup", "break-glass"}, secrets.getMirrorNamespaces())
+}
+
+func TestLocalStoreMirrorCertificateSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	content, err := json.Marshal(&StoredData{MirrorNamespaces: []string{"backup", "removed"}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filename, content, 0600))
+
+	client := newFakeSecretsClient()
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}
+
+	// The mirror of the removed namespace is pruned, the Secrets of its primary are kept
+	mirror := newCertificateSecret("removed", "traefik", certificate)
+	mirror.Annotations[certificateSecretMirrorAnnotation] = "traefik"
+	client.secrets["removed/"+mirror.Name] = *mirror
+	primary := newCertificateSecret("removed", "traefik", &Certificate{Domain: types.Domain{Main: "removed.traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")})
+	client.secrets["removed/"+primary.Name] = *primary
+
+	// The Secret of the primary of a mirror namespace is not changed
+	other := newCertificateSecret("break-glass", "traefik", &Certificate{Domain: certificate.Domain, Certificate: []byte("other cert"), Key: []byte("other key")})
+	client.secrets["break-glass/"+other.Name] = *other
+
+	store := newTestTLSSecretsStore(filename, client)
+	store.CertificateSecrets.MirrorNamespaces = []string{"backup", "break-glass"}
+	defer store.Close(context.Background())
+
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{certificate}))
+
+	waitForSecret(t, client, "backup", "acme-traefik.wtf", func(secret *corev1.Secret) bool {
+		return secret != nil && secret.Annotations[certificateSecretMirrorAnnotation] == "traefik" && len(secret.Annotations[certificateSecretGenerationAnnotation]) > 0
+	})
+	waitForSecret(t, client, "removed", "acme-traefik.wtf", func(secret *corev1.Secret) bool {
+		return secret == nil
+	})
+	waitForStoredData(t, filename, func(storedData *StoredData) bool {
+		return len(storedData.MirrorNamespaces) == 2 && storedData.MirrorNamespaces[0] == "backup" && storedData.MirrorNamespaces[1] == "break-glass"
+	})
+
+	assert.Equal(t, *primary, client.secrets["removed/"+primary.Name])
+	assert.Equal(t, *other, client.secrets["break-glass/"+other.Name])
+
+	// The mirrors of the removed certificates are deleted
+	require.NoError(t, store.SaveCertificates(context.Background(), nil))
+	waitForSecret(t, client, "backup", "acme-traefik.wtf", func(secret *corev1.Secret) bool {
+		return secret == nil
+	})
+}
+
+func TestLoadCertificateSecretsMirrors(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		mirrorNamespace    string
+		fallbackNamespaces []string
+		expectedCertLoaded bool
+	}{
+		{
+			desc:            "mirrors never loaded",
+			mirrorNamespace: "traefik",
+		},
+		{
+			desc:               "mirror namespace in the fallback namespaces",
+			mirrorNamespace:    "backup",
+			fallbackNamespaces: []string{"backup"},
+			expectedCertLoaded: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := newFakeSecretsClient()
+			mirror := newCertificateSecret(test.mirrorNamespace, "traefik", &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")})
+			mirror.Annotations[certificateSecretMirrorAnnotation] = "production"
+			mirror.Annotations[certificateSecretGenerationAnnotation] = "1"
+			client.secrets[test.mirrorNamespace+"/"+mirror.Name] = *mirror
+
+			store := &LocalStore{
+				CertificateSecrets: &TLSSecrets{Namespace: "traefik", FallbackNamespaces: test.fallbackNamespaces},
+				secretsClient:      client,
+				storedData:         &StoredData{},
+			}
+
+			require.NoError(t, store.loadCertificateSecrets(store.storedData))
+			assert.Equal(t, test.expectedCertLoaded, len(store.storedData.Certificates) == 1)
+
+			// The mirror copied from the fallback namespace is a certificate Secret of the namespace
+			if test.expectedCertLoaded {
+				secret := client.secrets["traefik/acme-traefik.wtf"]
+				assert.Empty(t, secret.Annotations[certificateSecretMirrorAnnotation])
+				assert.Empty(t, secret.Annotations[certificateSecretGenerationAnnotation])
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+// certificateSecretGenerationAnnotation holds the generation of the replicated Secrets, the time of the save of the primary in nanoseconds
+const certificateSecretGenerationAnnotation = "traefik.containous.io/acme-generation"
+
+const (
+	replicationRetryDelay    = time.Second
+	replicationMaxRetryDelay = time.Minute
+)
+
+// TLSReplica is a cluster the certificate Secrets are replicated to, as the Kubernetes provider connects to an external cluster
+type TLSReplica struct {
+	Name             string `description:"Name of the replica, in the logs and the metrics"`
+	Endpoint         string `description:"Kubernetes server endpoint of the cluster of the replica"`
+	Token            string `description:"Kubernetes bearer token (not needed for in-cluster client)"`
+	CertAuthFilePath string `description:"Kubernetes certificate authority file path (not needed for in-cluster client)"`
+	Namespace        string `description:"Namespace of the replicated Secrets, default to the namespace of the certificate Secrets"`
+}
+
+func newReplicaSecretsClient(replica TLSReplica, configMaps bool) (secretsClient, error) {
+	if len(replica.Endpoint) == 0 {
+		return nil, errors.New("endpoint missing for the replica")
+	}
+
+	config := &rest.Config{
+		Host:        replica.Endpoint,
+		BearerToken: replica.Token,
+	}
+
+	if len(replica.CertAuthFilePath) > 0 {
+		caData, err := ioutil.ReadFile(replica.CertAuthFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %s", replica.CertAuthFilePath, err)
+		}
+
+		config.TLSClientConfig = rest.TLSClientConfig{CAData: caData}
+	}
+
+	return newSecretsClientFromConfig(config, configMaps)
+}
+
+// replicationPayload is the content of a save of the certificate Secrets, to replicate
+type replicationPayload struct {
+	generation   int64
+	certificates []*Certificate
+}
+
+// secretsReplicator writes the certificate Secrets of the last save to a replica in the background, until it succeeds.
+// Only the last payload is replicated, the payloads saved during a replication being coalesced.
+type secretsReplicator struct {
+	name        string
+	namespace   string
+	owner       string
+	certManager bool
+	client      secretsClient
+	logger      *logrus.Entry
+	setLag      func(lag time.Duration)
+	// mirrorOf is the namespace of the certificate Secrets mirrored by the replicator, empty for a replica
+	mirrorOf string
+
+	lock           sync.Mutex
+	pending        *replicationPayload
+	firstPendingAt time.Time
+	changes        chan struct{}
+}
+
+// queue sets the payload to replicate, which replaces the payload not yet replicated
+func (r *secretsReplicator) queue(payload *replicationPayload) {
+	r.lock.Lock()
+	r.pending = payload
+	if r.firstPendingAt.IsZero() {
+		r.firstPendingAt = time.Now()
+	}
+	r.lock.Unlock()
+
+	select {
+	case r.changes <- struct{}{}:
+	default:
+	}
+}
+
+func (r *secretsReplicator) run(stop <-chan struct{}) {
+	delay := replicationRetryDelay
+	for {
+		select {
+		case <-stop:
+			return
+		case <-r.changes:
+		}
+
+		for {
+			r.lock.Lock()
+			payload, firstPendingAt := r.pending, r.firstPendingAt
+			r.lock.Unlock()
+
+			if payload == nil {
+				break
+			}
+
+			err := r.replicate(payload)
+			if err == nil {
+				r.lock.Lock()
+				if r.pending == payload {
+					r.pending = nil
+					r.firstPendingAt = time.Time{}
+				}
+				r.lock.Unlock()
+
+				r.setLag(0)
+				delay = replicationRetryDelay
+				continue
+			}
+
+			r.setLag(time.Since(firstPendingAt))
+			r.logger.Warnf("Unable to replicate the certificate Secrets to the namespace %q of the replica %q, retrying in %s: %v", r.namespace, r.name, delay, err)
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > replicationMaxRetryDelay {
+				delay = replicationMaxRetryDelay
+			}
+		}
+	}
+}
+
+// replicate writes the certificate Secrets of the payload to the replica, and deletes its owned Secrets of the removed certificates.
+// The Secrets written by a later generation, from the save of another primary, are not changed.
+func (r *secretsReplicator) replicate(payload *replicationPayload) error {
+	secrets, err := r.client.List(r.namespace, certificateSecretLabel+"=true")
+	if err != nil {
+		return fmt.Errorf("unable to list the certificate Secrets: %v", err)
+	}
+
+	// The owned Secrets which are not the ones written by the replicator, as the Secrets of a primary in a mirror namespace, are not changed
+	existingSecrets := make(map[string]corev1.Secret)
+	otherSecrets := make(map[string]struct{})
+	for _, secret := range secrets {
+		if !isOwnedSecret(secret, r.owner) {
+			continue
+		}
+		if secret.Annotations[certificateSecretMirrorAnnotation] != r.mirrorOf {
+			otherSecrets[secret.Name] = struct{}{}
+			continue
+		}
+		existingSecrets[secret.Name] = secret
+	}
+
+	generation := strconv.FormatInt(payload.generation, 10)
+	savedSecrets := make(map[string]struct{})
+	for _, certificate := range payload.certificates {
+		secret := newCertificateSecret(r.namespace, r.owner, certificate)
+		if r.certManager {
+			setCertManagerAnnotations(secret, r.owner, certificate)
+		}
+		if len(r.mirrorOf) > 0 {
+			secret.Annotations[certificateSecretMirrorAnnotation] = r.mirrorOf
+		}
+		savedSecrets[secret.Name] = struct{}{}
+
+		if _, ok := otherSecrets[secret.Name]; ok {
+			r.logger.WithField(logFieldSecret, secret.Name).Warnf("The certificate Secret %s of the replica %q is not written by the replication, it is not changed.", secret.Name, r.name)
+			continue
+		}
+
+		existing, ok := existingSecrets[secret.Name]
+		if !ok {
+			secret.Annotations[certificateSecretGenerationAnnotation] = generation
+			if err := createOrUpdateSecret(r.client, secret, r.owner); err != nil {
+				return fmt.Errorf("unable to replicate the certificate Secret %s: %v", secret.Name, err)
+			}
+			continue
+		}
+
+		if r.isConflicting(existing, payload.generation) {
+			continue
+		}
+
+		// The Secrets only written again for a new generation are up to date
+		secret.Annotations[certificateSecretGenerationAnnotation] = existing.Annotations[certificateSecretGenerationAnnotation]
+		if isCertificateSecretUpToDate(existing, secret) {
+			continue
+		}
+
+		secret.Annotations[certificateSecretGenerationAnnotation] = generation
+		if err := applySecret(r.client, secret); err != nil {
+			return fmt.Errorf("unable to replicate the certificate Secret %s: %v", secret.Name, err)
+		}
+	}
+
+	for name, secret := range existingSecrets {
+		if _, ok := savedSecrets[name]; ok || r.isConflicting(secret, payload.generation) {
+			continue
+		}
+		if err := r.client.Delete(r.namespace, name); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete the replicated certificate Secret %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// isConflicting returns whether the Secret of the replica was written by a later generation than the replicated one
+func (r *secretsReplicator) isConflicting(secret corev1.Secret, generation int64) bool {
+	existing, err := strconv.ParseInt(secret.Annotations[certificateSecretGenerationAnnotation], 10, 64)
+	if err != nil || existing <= generation {
+		return false
+	}
+
+	r.logger.WithField(logFieldSecret, secret.Name).Warnf("The certificate Secret %s of the replica %q was written by a later save (generation %d, replicating %d), it is not changed.", secret.Name, r.name, existing, generation)
+	return true
+}
+
+// getReplicators returns the replicators of the certificate Secrets to the replicas and the mirror namespaces, started on the first save.
+// A replica whose client can not be created is skipped, it never affects the saves of the store.
+func (s *LocalStore) getReplicators() []*secretsReplicator {
+	s.secretsLock.Lock()
+	defer s.secretsLock.Unlock()
+
+	if s.replicators != nil {
+		return s.replicators
+	}
+
+	s.replicators = []*secretsReplicator{}
+	for i, replica := range s.CertificateSecrets.Replicas {
+		name := replica.Name
+		if len(name) == 0 {
+			name = strconv.Itoa(i)
+		}
+
+		namespace := replica.Namespace
+		if len(namespace) == 0 {
+			namespace = s.CertificateSecrets.Namespace
+		}
+
+		logger := s.logger(storeOperationSave).WithField(logFieldNamespace, namespace).WithField("replica", name)
+
+		client := s.replicaClients[name]
+		if client == nil {
+			var err error
+			client, err = newReplicaSecretsClient(replica, s.CertificateSecrets.ConfigMaps)
+			if err != nil {
+				logger.Errorf("Unable to create the Kubernetes client of the replica %q, its certificate Secrets are not replicated: %v", name, err)
+				continue
+			}
+		}
+
+		replicator := &secretsReplicator{
+			name:        name,
+			namespace:   namespace,
+			owner:       s.CertificateSecrets.getOwner(),
+			certManager: s.CertificateSecrets.CertManager,
+			client:      client,
+			logger:      logger,
+			setLag:      func(lag time.Duration) { s.setReplicationLag(name, lag) },
+			changes:     make(chan struct{}, 1),
+		}
+		s.replicators = append(s.replicators, replicator)
+		safe.Go(func() { replicator.run(s.closing) })
+	}
+
+	s.replicators = append(s.replicators, s.getMirrorReplicators()...)
+
+	return s.replicators
+}
+
+// replicateCertificateSecrets queues the certificates of a successful save for the replicas and the mirror namespaces, without waiting for them
+func (s *LocalStore) replicateCertificateSecrets(certificates []*Certificate) {
+	replicators := s.getReplicators()
+	if len(replicators) == 0 {
+		return
+	}
+
+	payload := &replicationPayload{generation: time.Now().UnixNano()}
+	for _, certificate := range certificates {
+		payload.certificates = append(payload.certificates, copyCertificate(certificate))
+	}
+
+	for _, replicator := range replicators {
+		replicator.queue(payload)
+	}
+}
+
+func (s *LocalStore) setReplicationLag(replica string, lag time.Duration) {
+	s.metricsLock.Lock()
+	registry := s.metricsRegistry
+	s.metricsLock.Unlock()
+
+	if registry == nil {
+		return
+	}
+
+	registry.ACMEStoreReplicationLagGauge().With("replica", replica).Set(lag.Seconds())
+}
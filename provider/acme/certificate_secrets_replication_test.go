@@ -0,0 +1,112 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type unavailableSecretsClient struct {
+	*fakeSecretsClient
+}
+
+func (c *unavailableSecretsClient) List(namespace string, selector string) ([]corev1.Secret, error) {
+	return nil, errors.New("unreachable cluster")
+}
+
+// waitForSecret waits for the Secret of the client to match the condition, a missing Secret being nil
+func waitForSecret(t *testing.T, client secretsClient, namespace, name string, condition func(*corev1.Secret) bool) {
+	matched := false
+	for i := 0; i < 500 && !matched; i++ {
+		secret, err := client.Get(namespace, name)
+		if err != nil {
+			secret = nil
+		}
+		matched = condition(secret)
+		if !matched {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	require.True(t, matched, "the Secret %s/%s does not match", namespace, name)
+}
+
+func TestSecretsReplicatorReplicate(t *testing.T) {
+	client := newFakeSecretsClient()
+	replicator := &secretsReplicator{
+		name:      "passive",
+		namespace: "passive",
+		owner:     "traefik",
+		client:    client,
+		logger:    logrus.NewEntry(logrus.StandardLogger()),
+	}
+
+	// A Secret written by a later save of another primary
+	conflicting := newCertificateSecret("passive", "traefik", &Certificate{Domain: types.Domain{Main: "conflict.traefik.wtf"}, Certificate: []byte("later cert"), Key: []byte("key")})
+	conflicting.Annotations[certificateSecretGenerationAnnotation] = strconv.FormatInt(math.MaxInt64, 10)
+	client.secrets["passive/"+conflicting.Name] = *conflicting
+
+	certificates := []*Certificate{
+		{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")},
+		{Domain: types.Domain{Main: "conflict.traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")},
+	}
+	require.NoError(t, replicator.replicate(&replicationPayload{generation: 1, certificates: certificates}))
+
+	secret := client.secrets["passive/acme-traefik.wtf"]
+	assert.Equal(t, []byte("cert"), secret.Data[corev1.TLSCertKey])
+	assert.Equal(t, "1", secret.Annotations[certificateSecretGenerationAnnotation])
+	assert.Equal(t, *conflicting, client.secrets["passive/"+conflicting.Name])
+
+	// The unchanged Secrets are not written again for a new generation
+	require.NoError(t, replicator.replicate(&replicationPayload{generation: 2, certificates: certificates}))
+	assert.Equal(t, "1", client.secrets["passive/acme-traefik.wtf"].Annotations[certificateSecretGenerationAnnotation])
+
+	certificates[0].Certificate = []byte("renewed cert")
+	require.NoError(t, replicator.replicate(&replicationPayload{generation: 3, certificates: certificates}))
+	secret = client.secrets["passive/acme-traefik.wtf"]
+	assert.Equal(t, []byte("renewed cert"), secret.Data[corev1.TLSCertKey])
+	assert.Equal(t, "3", secret.Annotations[certificateSecretGenerationAnnotation])
+
+	// The Secrets of the removed certificates are deleted, except the ones of a later save
+	require.NoError(t, replicator.replicate(&replicationPayload{generation: 4}))
+	require.Len(t, client.secrets, 1)
+	assert.Equal(t, *conflicting, client.secrets["passive/"+conflicting.Name])
+}
+
+func TestLocalStoreReplicateCertificateSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	replica := newFakeSecretsClient()
+	unavailable := &unavailableSecretsClient{fakeSecretsClient: newFakeSecretsClient()}
+
+	store := newTestTLSSecretsStore(filepath.Join(dir, "acme.json"), newFakeSecretsClient())
+	store.CertificateSecrets.Replicas = []TLSReplica{{Name: "passive", Namespace: "passive"}, {Name: "unavailable"}}
+	store.replicaClients = map[string]secretsClient{"passive": replica, "unavailable": unavailable}
+	defer store.Close(context.Background())
+
+	// The unreachable replica does not fail the saves
+	certificates := []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+
+	waitForSecret(t, replica, "passive", "acme-traefik.wtf", func(secret *corev1.Secret) bool {
+		return secret != nil && len(secret.Annotations[certificateSecretGenerationAnnotation]) > 0
+	})
+
+	require.NoError(t, store.SaveCertificates(context.Background(), nil))
+	waitForSecret(t, replica, "passive", "acme-traefik.wtf", func(secret *corev1.Secret) bool {
+		return secret == nil
+	})
+}
@@ -0,0 +1,621 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeSecretsClient struct {
+	lock    sync.Mutex
+	secrets map[string]corev1.Secret
+	// managers are the field managers of the fields of the Secrets, the fields of the Secrets set by the tests being unmanaged
+	managers map[string]map[string]string
+	// reactor is called before each create, update, apply and delete, to simulate the changes of another instance
+	reactor func(verb string, name string)
+}
+
+func newFakeSecretsClient() *fakeSecretsClient {
+	return &fakeSecretsClient{secrets: make(map[string]corev1.Secret), managers: make(map[string]map[string]string)}
+}
+
+func (c *fakeSecretsClient) List(namespace string, selector string) ([]corev1.Secret, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var secrets []corev1.Secret
+	for _, secret := range c.secrets {
+		if secret.Namespace == namespace && secret.Labels[certificateSecretLabel] == "true" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets, nil
+}
+
+func (c *fakeSecretsClient) Get(namespace, name string) (*corev1.Secret, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	secret, ok := c.secrets[namespace+"/"+name]
+	if !ok {
+		return nil, kerrors.NewNotFound(corev1.Resource("secrets"), name)
+	}
+	return &secret, nil
+}
+
+func (c *fakeSecretsClient) Create(secret *corev1.Secret) error {
+	c.react("create", secret.Name)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := secret.Namespace + "/" + secret.Name
+	if _, ok := c.secrets[key]; ok {
+		return kerrors.NewAlreadyExists(corev1.Resource("secrets"), secret.Name)
+	}
+	c.secrets[key] = *secret
+
+	c.managers[key] = make(map[string]string)
+	for path := range getSecretFields(secret) {
+		c.managers[key][path] = secretsFieldManager
+	}
+	return nil
+}
+
+func (c *fakeSecretsClient) Update(secret *corev1.Secret) error {
+	c.react("update", secret.Name)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := secret.Namespace + "/" + secret.Name
+	if _, ok := c.secrets[key]; !ok {
+		return kerrors.NewNotFound(corev1.Resource("secrets"), secret.Name)
+	}
+	c.secrets[key] = *secret
+	return nil
+}
+
+func (c *fakeSecretsClient) Delete(namespace, name string) error {
+	c.react("delete", name)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := namespace + "/" + name
+	if _, ok := c.secrets[key]; !ok {
+		return kerrors.NewNotFound(corev1.Resource("secrets"), name)
+	}
+	delete(c.secrets, key)
+	delete(c.managers, key)
+	return nil
+}
+
+func (c *fakeSecretsClient) Apply(secret *corev1.Secret, force bool) error {
+	return c.apply(secretsFieldManager, secret, force)
+}
+
+// apply simulates a server-side apply of the manager: the fields of the other managers are kept,
+// and the fields previously applied by the manager which are missing from the Secret are removed.
+func (c *fakeSecretsClient) apply(manager string, secret *corev1.Secret, force bool) error {
+	c.react("apply", secret.Name)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := secret.Namespace + "/" + secret.Name
+	existing, ok := c.secrets[key]
+	if !ok {
+		existing = corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secret.Name, Namespace: secret.Namespace}}
+	}
+	managers := c.managers[key]
+	if managers == nil {
+		managers = make(map[string]string)
+	}
+
+	existingFields := getSecretFields(&existing)
+	appliedFields := getSecretFields(secret)
+	for path, value := range appliedFields {
+		owner, ok := managers[path]
+		if !force && ok && owner != manager && existingFields[path] != value {
+			return kerrors.NewConflict(corev1.Resource("secrets"), secret.Name, fmt.Errorf("conflict with %q: %s", owner, path))
+		}
+	}
+
+	applied := existing.DeepCopy()
+	for path, owner := range managers {
+		if _, ok := appliedFields[path]; !ok && owner == manager {
+			setSecretField(applied, path, nil)
+			delete(managers, path)
+		}
+	}
+	for path, value := range appliedFields {
+		value := value
+		setSecretField(applied, path, &value)
+		managers[path] = manager
+	}
+
+	c.secrets[key] = *applied
+	c.managers[key] = managers
+	*secret = *applied.DeepCopy()
+	return nil
+}
+
+// getSecretFields returns the values of the fields of the Secret, keyed by their path
+func getSecretFields(secret *corev1.Secret) map[string]string {
+	fields := make(map[string]string)
+	for name, value := range secret.Labels {
+		fields["labels/"+name] = value
+	}
+	for name, value := range secret.Annotations {
+		fields["annotations/"+name] = value
+	}
+	for name, value := range secret.Data {
+		fields["data/"+name] = string(value)
+	}
+	if len(secret.Type) > 0 {
+		fields["type"] = string(secret.Type)
+	}
+	return fields
+}
+
+// setSecretField sets the field of the path to the value, a nil value removing it
+func setSecretField(secret *corev1.Secret, path string, value *string) {
+	if path == "type" {
+		secret.Type = ""
+		if value != nil {
+			secret.Type = corev1.SecretType(*value)
+		}
+		return
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	switch parts[0] {
+	case "labels":
+		if secret.Labels == nil {
+			secret.Labels = make(map[string]string)
+		}
+		setStringField(secret.Labels, parts[1], value)
+	case "annotations":
+		if secret.Annotations == nil {
+			secret.Annotations = make(map[string]string)
+		}
+		setStringField(secret.Annotations, parts[1], value)
+	case "data":
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		if value == nil {
+			delete(secret.Data, parts[1])
+		} else {
+			secret.Data[parts[1]] = []byte(*value)
+		}
+	}
+}
+
+func setStringField(fields map[string]string, name string, value *string) {
+	if value == nil {
+		delete(fields, name)
+	} else {
+		fields[name] = *value
+	}
+}
+
+func (c *fakeSecretsClient) react(verb string, name string) {
+	if c.reactor != nil {
+		c.reactor(verb, name)
+	}
+}
+
+func newTestTLSSecretsStore(filename string, client secretsClient) *LocalStore {
+	store := NewLocalStore(filename)
+	store.CertificateSecrets = &TLSSecrets{Namespace: "traefik"}
+	store.secretsClient = client
+	return store
+}
+
+func TestLocalStoreCertificateSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	storedData := &StoredData{
+		Account: &Account{Email: "test@traefik.wtf", PrivateKeyType: "RSA4096"},
+		Certificates: []*Certificate{
+			{Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}, Certificate: []byte("cert"), Key: []byte("key"), KeyType: "RSA4096", ChallengeType: "http-01"},
+			{Domain: types.Domain{Main: "*.traefik.wtf"}, Certificate: []byte("wildcard cert"), Key: []byte("wildcard key"), KeyType: "EC256", ChallengeType: "dns-01"},
+		},
+	}
+	content, err := json.Marshal(storedData)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filename, content, 0600))
+
+	client := newFakeSecretsClient()
+	store := newTestTLSSecretsStore(filename, client)
+
+	// The certificates of the storage are loaded until the first save
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, certificates, 2)
+	assert.Empty(t, client.secrets)
+
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+	require.Len(t, client.secrets, 2)
+
+	secret := client.secrets["traefik/acme-wildcard.traefik.wtf"]
+	assert.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	assert.Equal(t, []byte("wildcard key"), secret.Data[corev1.TLSPrivateKeyKey])
+	assert.Equal(t, "*.traefik.wtf", secret.Annotations[certificateSecretDomainsAnnotation])
+
+	waitForStoredData(t, filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil && len(storedData.Certificates) == 0
+	})
+
+	// The certificates are loaded back from the Secrets
+	certificates, err = newTestTLSSecretsStore(filename, client).GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 2)
+	for _, certificate := range certificates {
+		if certificate.Domain.Main == "traefik.wtf" {
+			assert.Equal(t, []string{"www.traefik.wtf"}, certificate.Domain.SANs)
+			assert.Equal(t, []byte("key"), certificate.Key)
+			assert.EqualValues(t, "RSA4096", certificate.KeyType)
+			assert.Equal(t, "http-01", certificate.ChallengeType)
+		}
+	}
+
+	// The Secrets of the removed certificates are deleted
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates[:1]))
+	assert.Len(t, client.secrets, 1)
+}
+
+func TestSaveCertificateSecretsConcurrentInstance(t *testing.T) {
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}
+	otherSecret := *newCertificateSecret("traefik", "traefik", &Certificate{Domain: certificate.Domain, Certificate: []byte("other cert"), Key: []byte("other key")})
+
+	testCases := []struct {
+		desc     string
+		existing bool
+		reactor  func(client *fakeSecretsClient) func(verb, name string)
+		expected int
+	}{
+		{
+			desc: "created by another instance",
+			reactor: func(client *fakeSecretsClient) func(verb, name string) {
+				return func(verb, name string) {
+					if verb == "create" && len(client.secrets) == 0 {
+						client.secrets["traefik/"+otherSecret.Name] = otherSecret
+					}
+				}
+			},
+			expected: 1,
+		},
+		{
+			desc:     "deleted by another instance",
+			existing: true,
+			reactor: func(client *fakeSecretsClient) func(verb, name string) {
+				return func(verb, name string) {
+					if verb == "apply" {
+						delete(client.secrets, "traefik/"+name)
+					}
+				}
+			},
+			expected: 1,
+		},
+		{
+			desc:     "removed certificate deleted by another instance",
+			existing: true,
+			reactor: func(client *fakeSecretsClient) func(verb, name string) {
+				return func(verb, name string) {
+					if verb == "delete" {
+						delete(client.secrets, "traefik/"+name)
+					}
+				}
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			client := newFakeSecretsClient()
+			if test.existing {
+				client.secrets["traefik/"+otherSecret.Name] = otherSecret
+			}
+			client.reactor = test.reactor(client)
+
+			store := &LocalStore{CertificateSecrets: &TLSSecrets{Namespace: "traefik"}, secretsClient: client}
+
+			var certificates []*Certificate
+			if test.expected > 0 {
+				certificates = []*Certificate{certificate}
+			}
+			require.NoError(t, store.saveCertificateSecrets(certificates))
+
+			require.Len(t, client.secrets, test.expected)
+			if test.expected > 0 {
+				assert.Equal(t, []byte("cert"), client.secrets["traefik/"+otherSecret.Name].Data[corev1.TLSCertKey])
+			}
+		})
+	}
+}
+
+func TestSaveCertificateSecretsOwnership(t *testing.T) {
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}
+	orphan := &Certificate{Domain: types.Domain{Main: "old.traefik.wtf"}, Certificate: []byte("old cert"), Key: []byte("old key")}
+
+	legacySecret := *newCertificateSecret("traefik", "traefik", orphan)
+	delete(legacySecret.Labels, certificateSecretOwnerLabel)
+
+	unlabeledSecret := *newCertificateSecret("traefik", "traefik", certificate)
+	unlabeledSecret.Labels = nil
+
+	testCases := []struct {
+		desc             string
+		existing         []corev1.Secret
+		retain           bool
+		expectedErr      bool
+		expectedSecrets  []string
+		expectedReleased []string
+	}{
+		{
+			desc:            "orphaned Secret",
+			existing:        []corev1.Secret{*newCertificateSecret("traefik", "traefik", orphan)},
+			expectedSecrets: []string{"acme-traefik.wtf"},
+		},
+		{
+			desc:            "orphaned Secret without owner label of a previous version",
+			existing:        []corev1.Secret{legacySecret},
+			expectedSecrets: []string{"acme-traefik.wtf"},
+		},
+		{
+			desc:            "Secret of another owner",
+			existing:        []corev1.Secret{*newCertificateSecret("traefik", "other", orphan)},
+			expectedSecrets: []string{"acme-old.traefik.wtf", "acme-traefik.wtf"},
+		},
+		{
+			desc:             "retained orphaned Secret",
+			existing:         []corev1.Secret{*newCertificateSecret("traefik", "traefik", orphan)},
+			retain:           true,
+			expectedSecrets:  []string{"acme-old.traefik.wtf", "acme-traefik.wtf"},
+			expectedReleased: []string{"acme-old.traefik.wtf"},
+		},
+		{
+			desc:            "unlabeled Secret of the same name",
+			existing:        []corev1.Secret{unlabeledSecret},
+			expectedErr:     true,
+			expectedSecrets: []string{"acme-traefik.wtf"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			client := newFakeSecretsClient()
+			for _, secret := range test.existing {
+				client.secrets["traefik/"+secret.Name] = *secret.DeepCopy()
+			}
+
+			store := &LocalStore{CertificateSecrets: &TLSSecrets{Namespace: "traefik", RetainOrphanedSecrets: test.retain}, secretsClient: client}
+			err := store.saveCertificateSecrets([]*Certificate{certificate})
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			var names, released []string
+			for _, secret := range client.secrets {
+				names = append(names, secret.Name)
+				if len(secret.Labels) == 0 && secret.Name != unlabeledSecret.Name {
+					released = append(released, secret.Name)
+				}
+			}
+			assert.ElementsMatch(t, test.expectedSecrets, names)
+			assert.ElementsMatch(t, test.expectedReleased, released)
+
+			if test.expectedErr {
+				// The Secret which is not owned is not changed
+				assert.Equal(t, unlabeledSecret, client.secrets["traefik/"+unlabeledSecret.Name])
+				return
+			}
+			assert.Equal(t, "traefik", client.secrets["traefik/acme-traefik.wtf"].Labels[certificateSecretOwnerLabel])
+			assert.Equal(t, "traefik.wtf", client.secrets["traefik/acme-traefik.wtf"].Labels[certificateSecretDomainLabel])
+		})
+	}
+}
+
+func TestSaveCertificateSecretsForeignFields(t *testing.T) {
+	client := newFakeSecretsClient()
+	store := &LocalStore{CertificateSecrets: &TLSSecrets{Namespace: "traefik"}, secretsClient: client}
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}
+	require.NoError(t, store.saveCertificateSecrets([]*Certificate{certificate}))
+
+	// Another controller adds its own fields, and forces a change of a field written by Traefik
+	foreign := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "acme-traefik.wtf",
+			Namespace: "traefik",
+			Labels:    map[string]string{"backup.example.com/include": "true"},
+		},
+		Data: map[string][]byte{"ca.crt": []byte("ca"), corev1.TLSCertKey: []byte("foreign cert")},
+	}
+	require.NoError(t, client.apply("backup-controller", foreign, true))
+
+	for _, renewed := range []string{"renewed cert", "renewed again cert"} {
+		certificate.Certificate = []byte(renewed)
+		require.NoError(t, store.saveCertificateSecrets([]*Certificate{certificate}))
+
+		secret := client.secrets["traefik/acme-traefik.wtf"]
+		assert.Equal(t, []byte(renewed), secret.Data[corev1.TLSCertKey])
+		assert.Equal(t, []byte("ca"), secret.Data["ca.crt"])
+		assert.Equal(t, "true", secret.Labels["backup.example.com/include"])
+		assert.Equal(t, "traefik", secret.Labels[certificateSecretOwnerLabel])
+	}
+
+	// The fields no longer written by Traefik are removed, the fields of the other managers being kept
+	store.CertificateSecrets.CertManager = true
+	require.NoError(t, store.saveCertificateSecrets([]*Certificate{certificate}))
+	require.Contains(t, client.secrets["traefik/acme-traefik.wtf"].Annotations, certManagerCertificateNameAnnotation)
+
+	store.CertificateSecrets.CertManager = false
+	certificate.Certificate = []byte("cert without cert-manager")
+	require.NoError(t, store.saveCertificateSecrets([]*Certificate{certificate}))
+	secret := client.secrets["traefik/acme-traefik.wtf"]
+	assert.NotContains(t, secret.Annotations, certManagerCertificateNameAnnotation)
+	assert.Equal(t, []byte("ca"), secret.Data["ca.crt"])
+	assert.Equal(t, "backup-controller", client.managers["traefik/acme-traefik.wtf"]["labels/backup.example.com/include"])
+	assert.Equal(t, secretsFieldManager, client.managers["traefik/acme-traefik.wtf"]["data/"+corev1.TLSCertKey])
+}
+
+func TestLoadCertificateSecretsOwnership(t *testing.T) {
+	client := newFakeSecretsClient()
+	for _, secret := range []*corev1.Secret{
+		newCertificateSecret("traefik", "traefik", &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}),
+		newCertificateSecret("traefik", "other", &Certificate{Domain: types.Domain{Main: "other.traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}),
+	} {
+		client.secrets["traefik/"+secret.Name] = *secret
+	}
+
+	store := &LocalStore{CertificateSecrets: &TLSSecrets{Namespace: "traefik"}, secretsClient: client, storedData: &StoredData{}}
+	require.NoError(t, store.loadCertificateSecrets(store.storedData))
+	require.Len(t, store.storedData.Certificates, 1)
+	assert.Equal(t, "traefik.wtf", store.storedData.Certificates[0].Domain.Main)
+}
+
+func TestLoadCertificateSecretsFallbackNamespaces(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		namespaces         []string
+		readOnly           bool
+		expectedError      bool
+		expectedCopied     bool
+		expectedMigrated   bool
+		expectedCertLoaded bool
+	}{
+		{
+			desc:               "Secrets in a fallback namespace",
+			namespaces:         []string{"kube-system"},
+			expectedCopied:     true,
+			expectedMigrated:   true,
+			expectedCertLoaded: true,
+		},
+		{
+			desc:               "read-only store",
+			namespaces:         []string{"kube-system"},
+			readOnly:           true,
+			expectedCertLoaded: true,
+		},
+		{
+			desc:          "Secrets in several fallback namespaces",
+			namespaces:    []string{"kube-system", "default"},
+			expectedError: true,
+		},
+		{
+			desc: "no Secrets in the fallback namespaces",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := newFakeSecretsClient()
+			for _, namespace := range test.namespaces {
+				secret := newCertificateSecret(namespace, "traefik", &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")})
+				client.secrets[namespace+"/"+secret.Name] = *secret
+			}
+
+			store := &LocalStore{
+				CertificateSecrets: &TLSSecrets{Namespace: "traefik-system", FallbackNamespaces: []string{"kube-system", "default"}},
+				secretsClient:      client,
+				storedData:         &StoredData{},
+			}
+			store.SetReadOnly(test.readOnly)
+
+			err := store.loadCertificateSecrets(store.storedData)
+			if test.expectedError {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "kube-system")
+				assert.Contains(t, err.Error(), "default")
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCertLoaded, len(store.storedData.Certificates) == 1)
+
+			_, copied := client.secrets["traefik-system/acme-traefik.wtf"]
+			assert.Equal(t, test.expectedCopied, copied)
+
+			if len(test.namespaces) > 0 {
+				source := client.secrets["kube-system/acme-traefik.wtf"]
+				assert.Equal(t, test.expectedMigrated, source.Annotations[certificateSecretMigratedAnnotation] == "traefik-system")
+			}
+		})
+	}
+}
+
+func TestGetCertificateSecretDomainLabel(t *testing.T) {
+	testCases := []struct {
+		domain   string
+		expected string
+	}{
+		{domain: "traefik.wtf", expected: "traefik.wtf"},
+		{domain: "*.Traefik.wtf", expected: "wildcard.traefik.wtf"},
+		{domain: strings.Repeat("a", 60) + ".traefik.wtf"},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.domain, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, getCertificateSecretDomainLabel(types.Domain{Main: test.domain}))
+		})
+	}
+}
+
+func TestSecretConfigMap(t *testing.T) {
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}, Certificate: []byte("cert"), Key: []byte("key"), ChallengeType: "http-01"}
+	secret := newCertificateSecret("traefik", "traefik", certificate)
+
+	configMap := newSecretConfigMap(secret)
+	assert.Equal(t, secret.Name, configMap.Name)
+	assert.Equal(t, "true", configMap.Labels[certificateSecretLabel])
+	assert.Equal(t, "key", configMap.Data[corev1.TLSPrivateKeyKey])
+
+	// The certificate is loaded back from the ConfigMap as from a Secret
+	loaded, err := getSecretCertificate(getConfigMapSecret(*configMap))
+	require.NoError(t, err)
+	assert.Equal(t, certificate.Domain, loaded.Domain)
+	assert.Equal(t, []byte("cert"), loaded.Certificate)
+	assert.Equal(t, []byte("key"), loaded.Key)
+	assert.True(t, isCertificateSecretUpToDate(getConfigMapSecret(*configMap), secret))
+}
+
+// waitForStoredData waits for the storage file to be written with data matching the condition
+func waitForStoredData(t *testing.T, filename string, condition func(*StoredData) bool) {
+	written := false
+	for i := 0; i < 500 && !written; i++ {
+		time.Sleep(10 * time.Millisecond)
+		content, err := ioutil.ReadFile(filename)
+		storedData := &StoredData{}
+		written = err == nil && json.Unmarshal(content, storedData) == nil && condition(storedData)
+	}
+	require.True(t, written, "the storage has not been written")
+}
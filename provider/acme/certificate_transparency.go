@@ -0,0 +1,182 @@
+package acme
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+)
+
+const (
+	ctFailureMissing   = "missing"
+	ctFailureMalformed = "malformed"
+
+	// sctLogIDLength is the length of the log ID of a SCT, the SHA-256 hash of the public key of the log
+	sctLogIDLength = 32
+)
+
+// oidSCTList is the certificate extension holding the embedded SCTs (RFC 6962, section 3.3)
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// SCT is a Signed Certificate Timestamp, the promise of a Certificate Transparency log to publish a certificate
+type SCT struct {
+	LogID     string
+	Timestamp time.Time
+}
+
+// parseSCTs returns the SCTs embedded in the certificate, none when the certificate has no SCT list
+func parseSCTs(crt *x509.Certificate) ([]SCT, error) {
+	for _, extension := range crt.Extensions {
+		if !extension.Id.Equal(oidSCTList) {
+			continue
+		}
+
+		var list []byte
+		if _, err := asn1.Unmarshal(extension.Value, &list); err != nil {
+			return nil, fmt.Errorf("malformed SCT list: %v", err)
+		}
+		return parseSCTList(list)
+	}
+	return nil, nil
+}
+
+// parseSCTList decodes the TLS encoded SignedCertificateTimestampList (RFC 6962, section 3.3)
+func parseSCTList(list []byte) ([]SCT, error) {
+	list, err := readOpaque16(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var scts []SCT
+	for len(list) > 0 {
+		var sct []byte
+		sct, list, err = readPrefixed16(list)
+		if err != nil {
+			return nil, err
+		}
+
+		// version (1 byte), log ID (32 bytes), timestamp (8 bytes), then the extensions and the signature
+		if len(sct) < 1+sctLogIDLength+8 {
+			return nil, errors.New("malformed SCT: too short")
+		}
+		if sct[0] != 0 {
+			return nil, fmt.Errorf("unsupported SCT version %d", sct[0])
+		}
+
+		milliseconds := int64(binary.BigEndian.Uint64(sct[1+sctLogIDLength:]))
+		scts = append(scts, SCT{
+			LogID:     base64.StdEncoding.EncodeToString(sct[1 : 1+sctLogIDLength]),
+			Timestamp: time.Unix(milliseconds/1000, (milliseconds%1000)*int64(time.Millisecond)).UTC(),
+		})
+	}
+	return scts, nil
+}
+
+// readOpaque16 returns the content of a 16 bits length prefixed vector, which must be the whole data
+func readOpaque16(data []byte) ([]byte, error) {
+	content, rest, err := readPrefixed16(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("malformed SCT list: trailing data")
+	}
+	return content, nil
+}
+
+func readPrefixed16(data []byte) ([]byte, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, errors.New("malformed SCT list: truncated length")
+	}
+	length := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+length {
+		return nil, nil, errors.New("malformed SCT list: truncated data")
+	}
+	return data[2 : 2+length], data[2+length:], nil
+}
+
+// recordSCTs records the SCTs embedded in an issued certificate. A certificate without valid SCT is counted
+// and reported, but it is served anyway.
+func (p *Provider) recordSCTs(certificate *Certificate, crt *x509.Certificate) {
+	domains := certificate.Domain.ToStrArray()
+
+	scts, err := parseSCTs(crt)
+	if err != nil {
+		domainsLogger(domains).Warnf("Unable to read the Certificate Transparency SCTs of the certificate for domains %v: %v", domains, err)
+		countCTFailure(p.metricsRegistry, ctFailureMalformed)
+		p.events.certificateTransparencyFailed(domains, err)
+		return
+	}
+
+	if len(scts) == 0 {
+		err = errors.New("no SCT embedded in the certificate")
+		domainsLogger(domains).Warnf("The certificate for domains %v has no Certificate Transparency SCT.", domains)
+		countCTFailure(p.metricsRegistry, ctFailureMissing)
+		p.events.certificateTransparencyFailed(domains, err)
+		return
+	}
+
+	certificate.SCTs = scts
+}
+
+func countCTFailure(registry metrics.Registry, reason string) {
+	if registry == nil || registry.ACMECTFailuresCounter() == nil {
+		return
+	}
+	registry.ACMECTFailuresCounter().With("reason", reason).Add(1)
+}
+
+// CertificateTransparency describes the Certificate Transparency of a certificate, without its private key
+type CertificateTransparency struct {
+	Domain   string                       `json:"domain"`
+	SANs     []string                     `json:"sans,omitempty"`
+	NotAfter *time.Time                   `json:"notAfter,omitempty"`
+	SCTs     []CertificateTransparencySCT `json:"scts"`
+}
+
+// CertificateTransparencySCT describes a SCT embedded in a certificate
+type CertificateTransparencySCT struct {
+	LogID     string    `json:"logId"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GetCertificatesTransparency returns the SCTs recorded for the certificates of the store, sorted by domain
+func (p *Provider) GetCertificatesTransparency(ctx context.Context) ([]*CertificateTransparency, error) {
+	certificates, err := p.Store.GetCertificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*CertificateTransparency
+	for _, certificate := range certificates {
+		result = append(result, newCertificateTransparency(certificate))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Domain < result[j].Domain
+	})
+
+	return result, nil
+}
+
+func newCertificateTransparency(certificate *Certificate) *CertificateTransparency {
+	info := &CertificateTransparency{
+		Domain: certificate.Domain.Main,
+		SANs:   certificate.Domain.SANs,
+		SCTs:   []CertificateTransparencySCT{},
+	}
+	if notAfter, err := getCertificateNotAfter(certificate.Certificate); err == nil {
+		info.NotAfter = &notAfter
+	}
+	for _, sct := range certificate.SCTs {
+		info.SCTs = append(info.SCTs, CertificateTransparencySCT{LogID: sct.LogID, Timestamp: sct.Timestamp})
+	}
+	return info
+}
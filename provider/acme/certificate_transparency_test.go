@@ -0,0 +1,165 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSCTLogID(b byte) []byte {
+	logID := make([]byte, sctLogIDLength)
+	for i := range logID {
+		logID[i] = b
+	}
+	return logID
+}
+
+func encodeTestSCT(logID byte, timestamp time.Time) []byte {
+	sct := append([]byte{0}, testSCTLogID(logID)...)
+	milliseconds := make([]byte, 8)
+	binary.BigEndian.PutUint64(milliseconds, uint64(timestamp.UnixNano()/int64(time.Millisecond)))
+	sct = append(sct, milliseconds...)
+	// No extension, then the hash and signature algorithms and an empty signature
+	sct = append(sct, 0, 0, 4, 3, 0, 0)
+	return sct
+}
+
+func encodeTestSCTList(scts ...[]byte) []byte {
+	var content []byte
+	for _, sct := range scts {
+		content = append(content, byte(len(sct)>>8), byte(len(sct)))
+		content = append(content, sct...)
+	}
+	return append([]byte{byte(len(content) >> 8), byte(len(content))}, content...)
+}
+
+func generateTestSCTCertificate(t *testing.T, list []byte) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "traefik.wtf"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	if list != nil {
+		value, err := asn1.Marshal(list)
+		require.NoError(t, err)
+		template.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return crt
+}
+
+func TestParseSCTs(t *testing.T) {
+	timestamp := time.Date(2018, time.August, 1, 12, 0, 0, int(250*time.Millisecond), time.UTC)
+
+	testCases := []struct {
+		desc          string
+		list          []byte
+		expected      []SCT
+		expectedError string
+	}{
+		{
+			desc: "no SCT list",
+		},
+		{
+			desc: "two SCTs",
+			list: encodeTestSCTList(encodeTestSCT(1, timestamp), encodeTestSCT(2, timestamp.Add(time.Second))),
+			expected: []SCT{
+				{LogID: base64.StdEncoding.EncodeToString(testSCTLogID(1)), Timestamp: timestamp},
+				{LogID: base64.StdEncoding.EncodeToString(testSCTLogID(2)), Timestamp: timestamp.Add(time.Second)},
+			},
+		},
+		{
+			desc:          "truncated SCT",
+			list:          encodeTestSCTList(encodeTestSCT(1, timestamp)[:20]),
+			expectedError: "malformed SCT: too short",
+		},
+		{
+			desc:          "truncated list",
+			list:          encodeTestSCTList(encodeTestSCT(1, timestamp))[:30],
+			expectedError: "malformed SCT list: truncated data",
+		},
+		{
+			desc:          "unsupported version",
+			list:          encodeTestSCTList(append([]byte{1}, encodeTestSCT(1, timestamp)[1:]...)),
+			expectedError: "unsupported SCT version 1",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			scts, err := parseSCTs(generateTestSCTCertificate(t, test.list))
+			if len(test.expectedError) > 0 {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, scts)
+		})
+	}
+}
+
+func TestRecordSCTs(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+	provider := &Provider{metricsRegistry: registry}
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}}
+	provider.recordSCTs(certificate, generateTestSCTCertificate(t, encodeTestSCTList(encodeTestSCT(1, time.Now()))))
+	assert.Len(t, certificate.SCTs, 1)
+	assert.Equal(t, float64(0), registry.ctFailures.CounterValue)
+
+	// A certificate without SCT is counted, and recorded without SCT
+	certificate = &Certificate{Domain: types.Domain{Main: "traefik.wtf"}}
+	provider.recordSCTs(certificate, generateTestSCTCertificate(t, nil))
+	assert.Empty(t, certificate.SCTs)
+	assert.Equal(t, float64(1), registry.ctFailures.CounterValue)
+	assert.Equal(t, []string{"reason", ctFailureMissing}, registry.ctFailures.LastLabelValues)
+
+	provider.recordSCTs(certificate, generateTestSCTCertificate(t, []byte{0, 5}))
+	assert.Equal(t, float64(2), registry.ctFailures.CounterValue)
+	assert.Equal(t, []string{"reason", ctFailureMalformed}, registry.ctFailures.LastLabelValues)
+}
+
+func TestGetCertificatesTransparency(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	timestamp := time.Date(2018, time.August, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{
+		{Domain: types.Domain{Main: "traefik.wtf"}, Key: []byte("key"), SCTs: []SCT{{LogID: "log", Timestamp: timestamp}}},
+		{Domain: types.Domain{Main: "acme.wtf", SANs: []string{"www.acme.wtf"}}, Key: []byte("key")},
+	}))
+
+	p := &Provider{Store: store}
+
+	certificates, err := p.GetCertificatesTransparency(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 2)
+
+	assert.Equal(t, &CertificateTransparency{Domain: "acme.wtf", SANs: []string{"www.acme.wtf"}, SCTs: []CertificateTransparencySCT{}}, certificates[0])
+	assert.Equal(t, &CertificateTransparency{Domain: "traefik.wtf", SCTs: []CertificateTransparencySCT{{LogID: "log", Timestamp: timestamp}}}, certificates[1])
+}
@@ -0,0 +1,67 @@
+package acme
+
+import (
+	"context"
+)
+
+// certificateUpdate is a request to update the certificate of a main domain, in memory and in the store
+type certificateUpdate struct {
+	domain string
+	// update changes the certificate, and returns whether it changed
+	update func(certificate *Certificate) bool
+	done   chan certificateUpdateResult
+}
+
+type certificateUpdateResult struct {
+	// certificate is a copy of the updated certificate, nil when there is no certificate for the domain
+	certificate *Certificate
+	updated     bool
+	err         error
+}
+
+// updateCertificate updates the certificate of the main domain, and stores it when the update changed it
+func (p *Provider) updateCertificate(ctx context.Context, domain string, update func(certificate *Certificate) bool) certificateUpdateResult {
+	if p.certificateUpdates == nil {
+		// Before the start of the provider, no routine owns the certificates in memory
+		return p.applyCertificateUpdate(domain, update)
+	}
+
+	// The certificates in memory are updated in the routine watching the certificates, which owns them
+	done := make(chan certificateUpdateResult, 1)
+	select {
+	case p.certificateUpdates <- &certificateUpdate{domain: domain, update: update, done: done}:
+	case <-ctx.Done():
+		return certificateUpdateResult{err: ctx.Err()}
+	}
+	return <-done
+}
+
+// applyCertificateUpdate updates the certificate of the main domain and stores it.
+// The served certificates are not refreshed, the updates do not change them.
+func (p *Provider) applyCertificateUpdate(domain string, update func(certificate *Certificate) bool) certificateUpdateResult {
+	for i, certificate := range p.certificates {
+		if normalizeDomain(certificate.Domain.Main) != normalizeDomain(domain) {
+			continue
+		}
+
+		// The entry is updated in memory once stored, it is replaced by a copy in the store
+		updated := copyCertificate(certificate)
+		if !update(updated) {
+			return certificateUpdateResult{certificate: updated}
+		}
+		certificates := append([]*Certificate(nil), p.certificates...)
+		certificates[i] = updated
+
+		err := p.tracing.traceStore(p.Store, storeOperationSave, "", func() error {
+			return p.Store.SaveCertificates(p.getContext(), certificates)
+		})
+		if err != nil {
+			return certificateUpdateResult{err: err}
+		}
+		*certificate = *copyCertificate(updated)
+
+		return certificateUpdateResult{certificate: updated, updated: true}
+	}
+
+	return certificateUpdateResult{}
+}
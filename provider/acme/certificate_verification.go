@@ -0,0 +1,158 @@
+package acme
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+)
+
+// Reasons of the verification failures of the issued certificates
+const (
+	verificationFailureInvalid  = "invalid"
+	verificationFailureDomains  = "domains"
+	verificationFailureValidity = "validity"
+	verificationFailureChain    = "chain"
+)
+
+// maxCertificateLifetime is the longest validity of a publicly trusted certificate
+const maxCertificateLifetime = 398 * 24 * time.Hour
+
+// initTrustedRoots loads the root certificates the issued certificates must chain to, the system ones being used when none is configured
+func (p *Provider) initTrustedRoots() error {
+	if len(p.TrustedRoots) == 0 {
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	for _, file := range p.TrustedRoots {
+		certificates, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("unable to read the trusted root certificates %s: %v", file, err)
+		}
+		if !pool.AppendCertsFromPEM(certificates) {
+			return fmt.Errorf("no PEM encoded root certificate found in %s", file)
+		}
+	}
+
+	p.trustedRoots = pool
+	return nil
+}
+
+// verifyObtainedCertificate verifies the certificate issued for the domains before it is stored and served.
+// A failure is logged with the fingerprints of the chain, and counted.
+// The chain which does not verify is accepted with allowUnverifiedChains, the other checks still apply.
+func (p *Provider) verifyObtainedCertificate(domains []string, certificate []byte) error {
+	chain, reason, err := verifyIssuedCertificate(certificate, domains, p.trustedRoots, time.Now())
+	if err == nil {
+		return nil
+	}
+
+	logger := domainsLogger(domains)
+	if reason == verificationFailureChain && p.AllowUnverifiedChains {
+		logger.Warnf("The chain of the certificate issued for the domains %v is not verified, it is used as unverified chains are allowed: %v, chain SHA-256 fingerprints: %s",
+			domains, err, strings.Join(getChainFingerprints(chain), ", "))
+		return nil
+	}
+
+	logger.Errorf("The certificate issued for the domains %v is not stored nor served, its verification failed: %v, chain SHA-256 fingerprints: %s",
+		domains, err, strings.Join(getChainFingerprints(chain), ", "))
+	countCertificateVerificationFailure(p.metricsRegistry, reason)
+	return fmt.Errorf("the certificate issued for the domains %v failed its verification: %v", domains, err)
+}
+
+// verifyIssuedCertificate checks that the PEM encoded certificate, with its chain, covers exactly the requested domains,
+// has a sane validity, and chains to the roots, the system ones when nil. It returns the parsed chain,
+// and the reason of the failure with its error.
+func verifyIssuedCertificate(certificate []byte, domains []string, roots *x509.CertPool, now time.Time) ([]*x509.Certificate, string, error) {
+	var chain []*x509.Certificate
+	for rest := certificate; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return chain, verificationFailureInvalid, err
+		}
+		chain = append(chain, crt)
+	}
+	if len(chain) == 0 {
+		return nil, verificationFailureInvalid, errors.New("no PEM encoded certificate found")
+	}
+	leaf := chain[0]
+
+	if !isSameDomains(leaf.DNSNames, domains) {
+		return chain, verificationFailureDomains, fmt.Errorf("the certificate names %v are not the requested domains %v", leaf.DNSNames, domains)
+	}
+
+	if now.After(leaf.NotAfter) || leaf.NotAfter.Sub(leaf.NotBefore) > maxCertificateLifetime {
+		return chain, verificationFailureValidity, fmt.Errorf("invalid validity of the certificate, from %s to %s",
+			leaf.NotBefore.UTC().Format(time.RFC3339), leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, crt := range chain[1:] {
+		intermediates.AddCert(crt)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return chain, verificationFailureChain, err
+	}
+
+	return chain, "", nil
+}
+
+// isSameDomains returns whether the names are the domains, in any order and case
+func isSameDomains(names, domains []string) bool {
+	normalize := func(values []string) []string {
+		var normalized []string
+		seen := make(map[string]struct{})
+		for _, value := range values {
+			value = strings.ToLower(value)
+			if _, ok := seen[value]; ok {
+				continue
+			}
+			seen[value] = struct{}{}
+			normalized = append(normalized, value)
+		}
+		sort.Strings(normalized)
+		return normalized
+	}
+
+	return strings.Join(normalize(names), ",") == strings.Join(normalize(domains), ",")
+}
+
+func getChainFingerprints(chain []*x509.Certificate) []string {
+	var fingerprints []string
+	for _, crt := range chain {
+		hash := sha256.Sum256(crt.Raw)
+		fingerprints = append(fingerprints, hex.EncodeToString(hash[:]))
+	}
+	return fingerprints
+}
+
+func countCertificateVerificationFailure(registry metrics.Registry, reason string) {
+	if registry == nil || registry.ACMECertificateVerificationFailuresCounter() == nil {
+		return
+	}
+	registry.ACMECertificateVerificationFailuresCounter().With("reason", reason).Add(1)
+}
@@ -0,0 +1,188 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{certificate: certificate, key: key}
+}
+
+func (c *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.certificate)
+	return pool
+}
+
+// issue returns the PEM encoded certificate of the domains issued by the CA
+func (c *testCA) issue(t *testing.T, domains []string, lifetime time.Duration) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(lifetime),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.certificate, &key.PublicKey, c.key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestVerifyIssuedCertificate(t *testing.T) {
+	ca := newTestCA(t, "Traefik Test CA")
+	other := newTestCA(t, "Other CA")
+	domains := []string{"traefik.wtf", "*.traefik.wtf"}
+
+	testCases := []struct {
+		desc           string
+		certificate    []byte
+		roots          *x509.CertPool
+		expectedReason string
+	}{
+		{
+			desc:        "trusted chain",
+			certificate: ca.issue(t, []string{"*.TRAEFIK.wtf", "traefik.wtf"}, 90*24*time.Hour),
+			roots:       ca.pool(),
+		},
+		{
+			desc:           "untrusted root",
+			certificate:    ca.issue(t, domains, 90*24*time.Hour),
+			roots:          other.pool(),
+			expectedReason: verificationFailureChain,
+		},
+		{
+			desc:           "missing domain",
+			certificate:    ca.issue(t, []string{"traefik.wtf"}, 90*24*time.Hour),
+			roots:          ca.pool(),
+			expectedReason: verificationFailureDomains,
+		},
+		{
+			desc:           "additional domain",
+			certificate:    ca.issue(t, append([]string{"other.wtf"}, domains...), 90*24*time.Hour),
+			roots:          ca.pool(),
+			expectedReason: verificationFailureDomains,
+		},
+		{
+			desc:           "too long validity",
+			certificate:    ca.issue(t, domains, 5*365*24*time.Hour),
+			roots:          ca.pool(),
+			expectedReason: verificationFailureValidity,
+		},
+		{
+			desc:           "invalid certificate",
+			certificate:    []byte("certificate"),
+			roots:          ca.pool(),
+			expectedReason: verificationFailureInvalid,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, reason, err := verifyIssuedCertificate(test.certificate, domains, test.roots, time.Now())
+			assert.Equal(t, test.expectedReason, reason)
+			if len(test.expectedReason) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestProviderVerifyObtainedCertificate(t *testing.T) {
+	ca := newTestCA(t, "Traefik Test CA")
+	domains := []string{"traefik.wtf"}
+	certificate := ca.issue(t, domains, 90*24*time.Hour)
+
+	registry := newCollectingACMEMetrics()
+	p := &Provider{Configuration: &Configuration{}, metricsRegistry: registry}
+
+	// The test CA is not one of the system roots
+	err := p.verifyObtainedCertificate(domains, certificate)
+	assert.Error(t, err)
+	assert.Equal(t, float64(1), registry.verifies.CounterValue)
+	assert.Equal(t, []string{"reason", verificationFailureChain}, registry.verifies.LastLabelValues)
+
+	p.trustedRoots = ca.pool()
+	assert.NoError(t, p.verifyObtainedCertificate(domains, certificate))
+
+	// The unverified chains are allowed, not the other failures
+	p.trustedRoots = nil
+	p.AllowUnverifiedChains = true
+	assert.NoError(t, p.verifyObtainedCertificate(domains, certificate))
+	assert.Equal(t, float64(1), registry.verifies.CounterValue)
+
+	err = p.verifyObtainedCertificate([]string{"other.wtf"}, certificate)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"reason", verificationFailureDomains}, registry.verifies.LastLabelValues)
+}
+
+func TestInitTrustedRoots(t *testing.T) {
+	ca := newTestCA(t, "Traefik Test CA")
+
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rootFile := filepath.Join(dir, "root.pem")
+	require.NoError(t, ioutil.WriteFile(rootFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}), 0600))
+	otherFile := filepath.Join(dir, "other.pem")
+	require.NoError(t, ioutil.WriteFile(otherFile, []byte("not a certificate"), 0600))
+
+	p := &Provider{Configuration: &Configuration{}}
+	require.NoError(t, p.initTrustedRoots())
+	assert.Nil(t, p.trustedRoots)
+
+	p.TrustedRoots = []string{rootFile}
+	require.NoError(t, p.initTrustedRoots())
+	_, reason, err := verifyIssuedCertificate(ca.issue(t, []string{"traefik.wtf"}, 90*24*time.Hour), []string{"traefik.wtf"}, p.trustedRoots, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+	p.TrustedRoots = []string{otherFile}
+	assert.EqualError(t, p.initTrustedRoots(), "no PEM encoded root certificate found in "+otherFile)
+}
@@ -0,0 +1,285 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/xenolf/lego/acme"
+)
+
+const (
+	// defaultChainRefreshInterval is the default interval between two refreshes of the intermediate chains
+	defaultChainRefreshInterval = 24 * time.Hour
+	// chainRefreshRequestDelay spaces the downloads of the chains from the CA
+	chainRefreshRequestDelay = time.Second
+	// maxChainSize bounds the size of a downloaded chain
+	maxChainSize = 1 << 20
+)
+
+// ErrChainRefreshRunning is returned when the intermediate chains are refreshed while a refresh is already running
+var ErrChainRefreshRunning = errors.New("the intermediate chains are already being refreshed")
+
+// upLink matches the link of the issuer certificate in the Link header of a certificate download
+var upLink = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?up"?`)
+
+// ChainRefresh is the summary of a refresh of the intermediate chains, the certificates being identified by their main domain
+type ChainRefresh struct {
+	Checked int               `json:"checked"`
+	Updated []string          `json:"updated"`
+	Failed  map[string]string `json:"failed,omitempty"`
+}
+
+func (p *Provider) getChainRefreshInterval() time.Duration {
+	if p.ChainRefreshInterval > 0 {
+		return time.Duration(p.ChainRefreshInterval)
+	}
+	return defaultChainRefreshInterval
+}
+
+// watchChains refreshes the intermediate chains of the certificates at the chain refresh interval
+func (p *Provider) watchChains() {
+	ticker := time.NewTicker(p.getChainRefreshInterval())
+	p.pool.Go(func(stop chan bool) {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := p.RefreshChains(p.getContext()); err != nil && err != ErrReadOnly && err != ErrChainRefreshRunning {
+					logger().Errorf("Unable to refresh the intermediate chains of the ACME certificates: %v", err)
+				}
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	})
+}
+
+// RefreshChains downloads again the chain of each stored certificate from its URL at the CA, one download at a time,
+// and replaces the intermediates of the certificates whose chain changed. The leaves and the private keys are kept.
+func (p *Provider) RefreshChains(ctx context.Context) (*ChainRefresh, error) {
+	if p.isPassive() {
+		return nil, ErrReadOnly
+	}
+
+	if !atomic.CompareAndSwapInt32(&p.refreshingChains, 0, 1) {
+		return nil, ErrChainRefreshRunning
+	}
+	defer atomic.StoreInt32(&p.refreshingChains, 0)
+
+	certificates, err := p.Store.GetCertificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := &ChainRefresh{Updated: []string{}}
+	for _, certificate := range certificates {
+		// The external certificates are not issued by the CA, the ones issued before the URL was stored have none
+		if certificate.External || len(certificate.CertURL) == 0 {
+			continue
+		}
+
+		if refresh.Checked > 0 {
+			select {
+			case <-time.After(chainRefreshRequestDelay):
+			case <-ctx.Done():
+				return refresh, ctx.Err()
+			}
+		}
+		refresh.Checked++
+
+		logger := domainsLogger(certificate.Domain.ToStrArray())
+		updated, err := p.refreshChain(ctx, certificate)
+		if err != nil {
+			logger.Warnf("Unable to refresh the intermediate chain of the certificate for domains %v: %v", certificate.Domain.ToStrArray(), err)
+			if refresh.Failed == nil {
+				refresh.Failed = make(map[string]string)
+			}
+			refresh.Failed[certificate.Domain.Main] = err.Error()
+			continue
+		}
+		if updated != nil {
+			logger.Infof("The intermediate chain of the certificate for domains %v changed at the CA, it is updated.", certificate.Domain.ToStrArray())
+			refresh.Updated = append(refresh.Updated, certificate.Domain.Main)
+			p.runDeployHooks(updated)
+		}
+	}
+
+	if len(refresh.Updated) > 0 {
+		p.refreshCertificates()
+	}
+
+	return refresh, nil
+}
+
+// refreshChain downloads the chain of the certificate, and stores it in place of the stored one when its intermediates changed.
+// It returns the updated certificate, nil when the chain did not change.
+func (p *Provider) refreshChain(ctx context.Context, certificate *Certificate) (*Certificate, error) {
+	stored, err := parseCertificateChain(certificate.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	downloaded, err := fetchCertificateChain(ctx, certificate.CertURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(downloaded[0].Raw, stored[0].Raw) {
+		return nil, errors.New("the CA returned another certificate")
+	}
+	if isSameChain(stored[1:], downloaded[1:]) {
+		return nil, nil
+	}
+
+	bundle := encodeCertificateChain(append([]*x509.Certificate{stored[0]}, downloaded[1:]...))
+	if err = p.verifyRefreshedChain(certificate.Domain.ToStrArray(), bundle); err != nil {
+		return nil, err
+	}
+
+	result := p.updateCertificate(ctx, certificate.Domain.Main, func(cert *Certificate) bool {
+		// The certificate renewed meanwhile keeps its chain
+		current, err := parseCertificateLeaf(cert.Certificate)
+		if err != nil || !bytes.Equal(current.Raw, stored[0].Raw) {
+			return false
+		}
+		cert.Certificate = bundle
+		return true
+	})
+	if result.err != nil {
+		return nil, result.err
+	}
+	if !result.updated {
+		return nil, nil
+	}
+	return result.certificate, nil
+}
+
+// verifyRefreshedChain verifies the certificate with its refreshed chain as the issued ones, without counting the failures
+func (p *Provider) verifyRefreshedChain(domains []string, bundle []byte) error {
+	_, reason, err := verifyIssuedCertificate(bundle, domains, p.trustedRoots, time.Now())
+	if err == nil || (reason == verificationFailureChain && p.AllowUnverifiedChains) {
+		return nil
+	}
+	return fmt.Errorf("the refreshed chain failed its verification: %v", err)
+}
+
+// fetchCertificateChain downloads the PEM encoded certificate and its chain from its URL at the CA,
+// the issuer being downloaded from the up link of the response when the chain is not bundled
+func fetchCertificateChain(ctx context.Context, certURL string) ([]*x509.Certificate, error) {
+	body, header, err := fetchCertificateResource(ctx, certURL)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := parseCertificateChain(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) > 1 {
+		return chain, nil
+	}
+
+	for _, link := range header["Link"] {
+		match := upLink.FindStringSubmatch(link)
+		if match == nil {
+			continue
+		}
+
+		issuer, _, err := fetchCertificateResource(ctx, match[1])
+		if err != nil {
+			return nil, err
+		}
+		// The issuer is DER encoded by the CAs which link it
+		crt, err := x509.ParseCertificate(issuer)
+		if err != nil {
+			issuers, pemErr := parseCertificateChain(issuer)
+			if pemErr != nil {
+				return nil, err
+			}
+			crt = issuers[0]
+		}
+		return append(chain, crt), nil
+	}
+
+	return chain, nil
+}
+
+func fetchCertificateResource(ctx context.Context, resourceURL string) ([]byte, http.Header, error) {
+	req, err := http.NewRequest(http.MethodGet, resourceURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := acme.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, resourceURL)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxChainSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Header, nil
+}
+
+// parseCertificateChain parses the PEM encoded certificates, the leaf first
+func parseCertificateChain(data []byte) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, crt)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return chain, nil
+}
+
+func encodeCertificateChain(chain []*x509.Certificate) []byte {
+	var bundle []byte
+	for _, crt := range chain {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})...)
+	}
+	return bundle
+}
+
+// isSameChain returns whether the intermediates are the same, in the same order
+func isSameChain(chain, other []*x509.Certificate) bool {
+	if len(chain) != len(other) {
+		return false
+	}
+	for i := range chain {
+		if !bytes.Equal(chain[i].Raw, other[i].Raw) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestIntermediate returns an intermediate certificate of the CA with the key, a new one being issued for each serial
+func newTestIntermediate(t *testing.T, ca *testCA, key *ecdsa.PrivateKey, serial int64) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "Traefik Test Intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(5 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	intermediate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return intermediate
+}
+
+func TestProviderRefreshChains(t *testing.T) {
+	ca := newTestCA(t, "Traefik Test CA")
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	previous := newTestIntermediate(t, ca, intermediateKey, 10)
+	rotated := newTestIntermediate(t, ca, intermediateKey, 11)
+
+	leaf := (&testCA{certificate: previous, key: intermediateKey}).issue(t, []string{"traefik.wtf"}, 90*24*time.Hour)
+	previousIntermediate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: previous.Raw})
+	rotatedIntermediate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rotated.Raw})
+
+	other := (&testCA{certificate: previous, key: intermediateKey}).issue(t, []string{"other.wtf"}, 90*24*time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bundled", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write(append(append([]byte(nil), leaf...), rotatedIntermediate...))
+	})
+	mux.HandleFunc("/linked", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Link", `<http://`+req.Host+`/issuer>;rel="up"`)
+		_, _ = rw.Write(leaf)
+	})
+	mux.HandleFunc("/issuer", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write(rotated.Raw)
+	})
+	mux.HandleFunc("/other", func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write(other)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	testCases := []struct {
+		desc            string
+		path            string
+		expectedUpdated []string
+		expectedFailed  bool
+	}{
+		{
+			desc:            "bundled chain",
+			path:            "/bundled",
+			expectedUpdated: []string{"traefik.wtf"},
+		},
+		{
+			desc:            "issuer linked",
+			path:            "/linked",
+			expectedUpdated: []string{"traefik.wtf"},
+		},
+		{
+			desc:            "another certificate",
+			path:            "/other",
+			expectedUpdated: []string{},
+			expectedFailed:  true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			store, clean := newTestLocalStore(t)
+			defer clean()
+
+			certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: append(append([]byte(nil), leaf...), previousIntermediate...), Key: []byte("key"), CertURL: server.URL + test.path}
+			require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{certificate}))
+			waitForStoredData(t, store.filename, func(storedData *StoredData) bool { return len(storedData.Certificates) == 1 })
+
+			configurationChan := make(chan types.ConfigMessage, 1)
+			p := &Provider{
+				Configuration:     &Configuration{},
+				Store:             store,
+				certificates:      []*Certificate{copyCertificate(certificate)},
+				configurationChan: configurationChan,
+				trustedRoots:      ca.pool(),
+			}
+
+			refresh, err := p.RefreshChains(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 1, refresh.Checked)
+			assert.Equal(t, test.expectedUpdated, refresh.Updated)
+			assert.Equal(t, test.expectedFailed, len(refresh.Failed) == 1)
+
+			if len(test.expectedUpdated) == 0 {
+				assert.Equal(t, certificate.Certificate, p.certificates[0].Certificate)
+				assert.Len(t, configurationChan, 0)
+				return
+			}
+
+			// Only the chain is replaced, the leaf and the private key are kept
+			expected := append(append([]byte(nil), leaf...), rotatedIntermediate...)
+			assert.Equal(t, expected, p.certificates[0].Certificate)
+			assert.Equal(t, []byte("key"), p.certificates[0].Key)
+			assert.Len(t, configurationChan, 1)
+			waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+				return len(storedData.Certificates) == 1 && string(storedData.Certificates[0].Certificate) == string(expected)
+			})
+
+			// The chain does not change again
+			refresh, err = p.RefreshChains(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, refresh.Updated)
+		})
+	}
+}
+
+func TestProviderRefreshChainsSkipped(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	certificates := []*Certificate{
+		{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, time.Now().Add(24*time.Hour)), Key: []byte("key")},
+		{Domain: types.Domain{Main: "external.wtf"}, Certificate: generateTestCertificate(t, time.Now().Add(24*time.Hour)), Key: []byte("key"), External: true, CertURL: "http://127.0.0.1:1/cert"},
+	}
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool { return len(storedData.Certificates) == 2 })
+
+	p := &Provider{Configuration: &Configuration{}, Store: store}
+
+	// The certificates without URL and the external ones are not refreshed
+	refresh, err := p.RefreshChains(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, refresh.Checked)
+
+	p.refreshingChains = 1
+	_, err = p.RefreshChains(context.Background())
+	assert.Equal(t, ErrChainRefreshRunning, err)
+
+	p.refreshingChains = 0
+	p.SetReadOnly(true)
+	_, err = p.RefreshChains(context.Background())
+	assert.Equal(t, ErrReadOnly, err)
+}
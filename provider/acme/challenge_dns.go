@@ -0,0 +1,170 @@
+package acme
+
+import (
+	"context"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+	"github.com/xenolf/lego/acme"
+)
+
+var _ acme.ChallengeProviderTimeout = (*challengeDNS)(nil)
+
+// dnsChallengeValidationDelay is the time left to the CA to validate a DNS challenge once the record is propagated
+const dnsChallengeValidationDelay = 5 * time.Minute
+
+type dnsProviderGetter func(name string) (acme.ChallengeProvider, error)
+
+// DNSChallengeState holds what was published by a DNS provider for a DNS-01 challenge
+type DNSChallengeState struct {
+	Provider  string
+	Domain    string
+	Token     string
+	KeyAuth   string
+	FQDN      string
+	Value     string
+	CreatedAt time.Time
+}
+
+// challengeDNS persists the state of the DNS-01 challenges handled by a DNS provider
+type challengeDNS struct {
+	provider        acme.ChallengeProvider
+	providerName    string
+	Store           Store
+	metricsRegistry metrics.Registry
+	tracing         *issuanceTracer
+	timings         *issuanceTimings
+
+	// credentials are read again, to build the provider again, when it fails
+	credentials *dnsCredentials
+	newProvider dnsProviderGetter
+}
+
+// Present presents a challenge to obtain new ACME certificate
+func (c *challengeDNS) Present(domain, token, keyAuth string) error {
+	c.timings.challengePresented(domain)
+
+	fqdn, value, _ := acme.DNS01Record(domain, keyAuth)
+
+	state := &DNSChallengeState{
+		Provider:  c.providerName,
+		Domain:    domain,
+		Token:     token,
+		KeyAuth:   keyAuth,
+		FQDN:      fqdn,
+		Value:     value,
+		CreatedAt: time.Now(),
+	}
+
+	// The state is stored before creating the record, to be able to clean it up even when Traefik stops in between
+	err := c.tracing.traceChallenge(c.Store, challengeTypeDNS01, challengeOperationPresent, domain, func() error {
+		return c.Store.AddDNSChallenge(context.Background(), token, state)
+	})
+	if err != nil {
+		challengeLogger(challengeTypeDNS01, domain).Errorf("Unable to store the DNS challenge state for domain %s: %v", domain, err)
+	}
+
+	err = c.provider.Present(domain, token, keyAuth)
+	if err != nil && c.renewProvider(domain) {
+		err = c.provider.Present(domain, token, keyAuth)
+	}
+	if err != nil {
+		countChallenges(c.metricsRegistry, challengeTypeDNS01, challengeOutcomeFailed, 1)
+		return err
+	}
+
+	countChallenges(c.metricsRegistry, challengeTypeDNS01, challengeOutcomeCreated, 1)
+	updatePendingChallenges(context.Background(), c.metricsRegistry, c.Store)
+	return nil
+}
+
+// CleanUp cleans the challenges when certificate is obtained
+func (c *challengeDNS) CleanUp(domain, token, keyAuth string) error {
+	c.timings.challengeCleanedUp(domain)
+
+	err := c.provider.CleanUp(domain, token, keyAuth)
+	if err != nil && c.renewProvider(domain) {
+		err = c.provider.CleanUp(domain, token, keyAuth)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = c.tracing.traceChallenge(c.Store, challengeTypeDNS01, challengeOperationCleanUp, domain, func() error {
+		return c.Store.RemoveDNSChallenge(context.Background(), token)
+	})
+	updatePendingChallenges(context.Background(), c.metricsRegistry, c.Store)
+	return err
+}
+
+// Timeout calculates the maximum of time allowed to resolved an ACME challenge
+func (c *challengeDNS) Timeout() (timeout, interval time.Duration) {
+	if challengeProviderTimeout, ok := c.provider.(acme.ChallengeProviderTimeout); ok {
+		return challengeProviderTimeout.Timeout()
+	}
+
+	// Same default values than LEGO
+	return 60 * time.Second, 2 * time.Second
+}
+
+// reconcileDNSChallenges cleans up the records of the DNS challenges stored by a previous run:
+// the dead ones are removed immediately, the ones still within their validation window once it elapses
+func (p *Provider) reconcileDNSChallenges(newProvider dnsProviderGetter) {
+	if p.isPassive() {
+		return
+	}
+
+	states, err := p.Store.GetDNSChallenges(p.getContext())
+	if err != nil {
+		logger().WithField(logFieldChallengeType, challengeTypeDNS01).Errorf("Unable to get the stored DNS challenges: %v", err)
+		return
+	}
+
+	providers := make(map[string]*challengeDNS)
+	for token, state := range states {
+		challenge, ok := providers[state.Provider]
+		if !ok {
+			provider, err := newProvider(state.Provider)
+			if err != nil {
+				challengeLogger(challengeTypeDNS01, state.Domain).Errorf("Unable to clean up the DNS challenge record %s for domain %s with provider %s: %v", state.FQDN, state.Domain, state.Provider, err)
+				continue
+			}
+
+			challenge = &challengeDNS{provider: provider, providerName: state.Provider, Store: p.Store, metricsRegistry: p.metricsRegistry}
+			providers[state.Provider] = challenge
+		}
+
+		timeout, _ := challenge.Timeout()
+		remaining := time.Until(state.CreatedAt.Add(timeout + dnsChallengeValidationDelay))
+		if remaining <= 0 {
+			cleanUpDNSChallenge(challenge, token, state)
+			countChallenges(p.metricsRegistry, challengeTypeDNS01, challengeOutcomeExpired, 1)
+			continue
+		}
+
+		challengeLogger(challengeTypeDNS01, state.Domain).Debugf("The DNS challenge record %s for domain %s will be cleaned up in %s.", state.FQDN, state.Domain, remaining)
+		p.scheduleDNSChallengeCleanUp(challenge, token, state, remaining)
+	}
+}
+
+func (p *Provider) scheduleDNSChallengeCleanUp(challenge *challengeDNS, token string, state *DNSChallengeState, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	p.pool.Go(func(stop chan bool) {
+		select {
+		case <-timer.C:
+			cleanUpDNSChallenge(challenge, token, state)
+			countChallenges(p.metricsRegistry, challengeTypeDNS01, challengeOutcomeExpired, 1)
+		case <-stop:
+			timer.Stop()
+		}
+	})
+}
+
+func cleanUpDNSChallenge(challenge *challengeDNS, token string, state *DNSChallengeState) {
+	logger := challengeLogger(challengeTypeDNS01, state.Domain)
+	logger.Infof("Cleaning up the DNS challenge record %s for domain %s created at %s.", state.FQDN, state.Domain, state.CreatedAt)
+
+	if err := challenge.CleanUp(state.Domain, token, state.KeyAuth); err != nil {
+		logger.Errorf("Unable to clean up the DNS challenge record %s for domain %s: %v", state.FQDN, state.Domain, err)
+	}
+}
@@ -0,0 +1,87 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+type fakeDNSProvider struct {
+	presented []string
+	cleaned   []string
+}
+
+func (f *fakeDNSProvider) Present(domain, token, keyAuth string) error {
+	f.presented = append(f.presented, domain+":"+token)
+	return nil
+}
+
+func (f *fakeDNSProvider) CleanUp(domain, token, keyAuth string) error {
+	f.cleaned = append(f.cleaned, domain+":"+token)
+	return nil
+}
+
+func TestChallengeDNSPersistsState(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	provider := &fakeDNSProvider{}
+	challenge := &challengeDNS{provider: provider, providerName: "fake", Store: store}
+
+	require.NoError(t, challenge.Present("traefik.wtf", "token", "keyAuth"))
+
+	states, err := store.GetDNSChallenges(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, states, "token")
+
+	fqdn, value, _ := acme.DNS01Record("traefik.wtf", "keyAuth")
+	assert.Equal(t, "fake", states["token"].Provider)
+	assert.Equal(t, "traefik.wtf", states["token"].Domain)
+	assert.Equal(t, "keyAuth", states["token"].KeyAuth)
+	assert.Equal(t, fqdn, states["token"].FQDN)
+	assert.Equal(t, value, states["token"].Value)
+
+	require.NoError(t, challenge.CleanUp("traefik.wtf", "token", "keyAuth"))
+
+	states, err = store.GetDNSChallenges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, states)
+	assert.Equal(t, []string{"traefik.wtf:token"}, provider.cleaned)
+}
+
+func TestReconcileDNSChallenges(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "dead", &DNSChallengeState{Provider: "fake", Domain: "dead.traefik.wtf", KeyAuth: "dead", CreatedAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "alive", &DNSChallengeState{Provider: "fake", Domain: "alive.traefik.wtf", KeyAuth: "alive", CreatedAt: time.Now()}))
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "unknown", &DNSChallengeState{Provider: "unknown", Domain: "unknown.traefik.wtf", KeyAuth: "unknown", CreatedAt: time.Now().Add(-time.Hour)}))
+
+	provider := &fakeDNSProvider{}
+	newProvider := func(name string) (acme.ChallengeProvider, error) {
+		if name == "fake" {
+			return provider, nil
+		}
+		return nil, errors.New("unrecognized DNS provider")
+	}
+
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+
+	p := &Provider{Store: store, pool: pool}
+	p.reconcileDNSChallenges(newProvider)
+
+	assert.Equal(t, []string{"dead.traefik.wtf:dead"}, provider.cleaned)
+
+	states, err := store.GetDNSChallenges(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, states, "dead")
+	assert.Contains(t, states, "alive", "a challenge within its validation window must not be cleaned up yet")
+	assert.Contains(t, states, "unknown", "a challenge without available provider must be kept")
+}
@@ -1,13 +1,14 @@
 package acme
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"time"
 
 	"github.com/cenk/backoff"
 	"github.com/containous/mux"
-	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/metrics"
 	"github.com/containous/traefik/safe"
 	"github.com/xenolf/lego/acme"
 )
@@ -15,17 +16,38 @@ import (
 var _ acme.ChallengeProviderTimeout = (*challengeHTTP)(nil)
 
 type challengeHTTP struct {
-	Store Store
+	Store           Store
+	metricsRegistry metrics.Registry
+	tracing         *issuanceTracer
+	timings         *issuanceTimings
 }
 
 // Present presents a challenge to obtain new ACME certificate
 func (c *challengeHTTP) Present(domain, token, keyAuth string) error {
-	return c.Store.SetHTTPChallengeToken(token, domain, []byte(keyAuth))
+	c.timings.challengePresented(domain)
+
+	err := c.tracing.traceChallenge(c.Store, challengeTypeHTTP01, challengeOperationPresent, domain, func() error {
+		return c.Store.SetHTTPChallengeToken(context.Background(), token, domain, []byte(keyAuth))
+	})
+	if err != nil {
+		countChallenges(c.metricsRegistry, challengeTypeHTTP01, challengeOutcomeFailed, 1)
+		return err
+	}
+
+	countChallenges(c.metricsRegistry, challengeTypeHTTP01, challengeOutcomeCreated, 1)
+	updatePendingChallenges(context.Background(), c.metricsRegistry, c.Store)
+	return nil
 }
 
 // CleanUp cleans the challenges when certificate is obtained
 func (c *challengeHTTP) CleanUp(domain, token, keyAuth string) error {
-	return c.Store.RemoveHTTPChallengeToken(token, domain)
+	c.timings.challengeCleanedUp(domain)
+
+	err := c.tracing.traceChallenge(c.Store, challengeTypeHTTP01, challengeOperationCleanUp, domain, func() error {
+		return c.Store.RemoveHTTPChallengeToken(context.Background(), token, domain)
+	})
+	updatePendingChallenges(context.Background(), c.metricsRegistry, c.Store)
+	return err
 }
 
 // Timeout calculates the maximum of time allowed to resolved an ACME challenge
@@ -33,32 +55,98 @@ func (c *challengeHTTP) Timeout() (timeout, interval time.Duration) {
 	return 60 * time.Second, 5 * time.Second
 }
 
-func getTokenValue(token, domain string, store Store) []byte {
-	log.Debugf("Looking for an existing ACME challenge for token %v...", token)
+// defaultHTTPChallengeTokenTTL is the duration after which a pending HTTP challenge token is removed
+const defaultHTTPChallengeTokenTTL = 1 * time.Hour
+
+func (p *Provider) getHTTPChallengeTokenTTL() time.Duration {
+	if p.HTTPChallenge != nil && p.HTTPChallenge.TokenTTL > 0 {
+		return time.Duration(p.HTTPChallenge.TokenTTL)
+	}
+	return defaultHTTPChallengeTokenTTL
+}
+
+// watchHTTPChallengeTokens periodically removes the HTTP challenge tokens of failed or abandoned validations
+func (p *Provider) watchHTTPChallengeTokens() {
+	ttl := p.getHTTPChallengeTokenTTL()
+
+	p.removeExpiredHTTPChallengeTokens(ttl)
+
+	ticker := time.NewTicker(ttl)
+	p.pool.Go(func(stop chan bool) {
+		for {
+			select {
+			case <-ticker.C:
+				p.removeExpiredHTTPChallengeTokens(ttl)
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	})
+}
+
+func (p *Provider) removeExpiredHTTPChallengeTokens(ttl time.Duration) {
+	if p.isPassive() {
+		return
+	}
+
+	removed, err := p.Store.RemoveExpiredHTTPChallengeTokens(p.getContext(), ttl)
+	if err != nil {
+		logger().WithField(logFieldChallengeType, challengeTypeHTTP01).Errorf("Unable to remove the expired HTTP challenge tokens: %v", err)
+		return
+	}
+
+	if removed > 0 {
+		logger().WithField(logFieldChallengeType, challengeTypeHTTP01).Infof("Removed %d HTTP challenge tokens older than %s.", removed, ttl)
+		countChallenges(p.metricsRegistry, challengeTypeHTTP01, challengeOutcomeExpired, removed)
+		updatePendingChallenges(p.getContext(), p.metricsRegistry, p.Store)
+	}
+}
+
+// httpChallengeTokenRetryTimeout is the maximum duration of the retries of a failing store, looking for a token
+var httpChallengeTokenRetryTimeout = 60 * time.Second
+
+// getTokenValue returns the key authorization of a pending HTTP challenge token from the store,
+// or ErrNotFound when the token is unknown or expired
+func getTokenValue(ctx context.Context, token, domain string, store Store, tracing *issuanceTracer) ([]byte, error) {
+	logger := challengeLogger(challengeTypeHTTP01, domain).WithField(logFieldToken, token)
+	logger.Debugf("Looking for an existing ACME challenge for token %v...", token)
 	var result []byte
 
 	operation := func() error {
-		var err error
-		result, err = store.GetHTTPChallengeToken(token, domain)
+		err := tracing.traceChallenge(store, challengeTypeHTTP01, challengeOperationGet, domain, func() error {
+			var err error
+			result, err = store.GetHTTPChallengeToken(ctx, token, domain)
+			return err
+		})
+		if err != nil && !IsRetriable(err) {
+			// The token is unknown or expired, or the store fails for good: it will not show up by retrying
+			return backoff.Permanent(err)
+		}
 		return err
 	}
 
 	notify := func(err error, time time.Duration) {
-		log.Errorf("Error getting challenge for token retrying in %s", time)
+		logger.Errorf("Error getting challenge for token retrying in %s", time)
 	}
 
 	ebo := backoff.NewExponentialBackOff()
-	ebo.MaxElapsedTime = 60 * time.Second
-	err := backoff.RetryNotify(safe.OperationWithRecover(operation), ebo, notify)
+	ebo.MaxElapsedTime = httpChallengeTokenRetryTimeout
+	err := backoff.RetryNotify(safe.OperationWithRecover(operation), backoff.WithContext(ebo, ctx), notify)
+	if err == ErrNotFound {
+		logger.Debugf("No pending ACME challenge for token %v.", token)
+		return nil, err
+	}
 	if err != nil {
-		log.Errorf("Error getting challenge for token: %v", err)
-		return []byte{}
+		logger.Errorf("Error getting challenge for token: %v", err)
+		return nil, err
 	}
 
-	return result
+	return result, nil
 }
 
 // AddRoutes add routes on internal router
+// An unknown or expired token is answered with a 404, a failure of the store with a 503
 func (p *Provider) AddRoutes(router *mux.Router) {
 	router.Methods(http.MethodGet).
 		Path(acme.HTTP01ChallengePath("{token}")).
@@ -67,16 +155,21 @@ func (p *Provider) AddRoutes(router *mux.Router) {
 			if token, ok := vars["token"]; ok {
 				domain, _, err := net.SplitHostPort(req.Host)
 				if err != nil {
-					log.Debugf("Unable to split host and port: %v. Fallback to request host.", err)
+					logger().WithField(logFieldChallengeType, challengeTypeHTTP01).Debugf("Unable to split host and port: %v. Fallback to request host.", err)
 					domain = req.Host
 				}
 
-				tokenValue := getTokenValue(token, domain, p.Store)
+				tokenValue, err := getTokenValue(req.Context(), token, domain, p.Store, p.tracing)
+				if err != nil && err != ErrNotFound {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
 				if len(tokenValue) > 0 {
 					rw.WriteHeader(http.StatusOK)
 					_, err = rw.Write(tokenValue)
 					if err != nil {
-						log.Errorf("Unable to write token : %v", err)
+						challengeLogger(challengeTypeHTTP01, domain).WithField(logFieldToken, token).Errorf("Unable to write token : %v", err)
 					}
 					return
 				}
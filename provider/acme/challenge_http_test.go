@@ -0,0 +1,73 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containous/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unavailableStore struct {
+	*LocalStore
+}
+
+func (s *unavailableStore) GetHTTPChallengeToken(ctx context.Context, token, domain string) ([]byte, error) {
+	return nil, errors.New("storage unavailable")
+}
+
+func TestHTTPChallengeRoutes(t *testing.T) {
+	defer func(timeout time.Duration) { httpChallengeTokenRetryTimeout = timeout }(httpChallengeTokenRetryTimeout)
+	httpChallengeTokenRetryTimeout = 10 * time.Millisecond
+
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+
+	testCases := []struct {
+		desc           string
+		store          Store
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			desc:           "pending token",
+			store:          store,
+			path:           "/.well-known/acme-challenge/token",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "keyAuth",
+		},
+		{
+			desc:           "unknown token",
+			store:          store,
+			path:           "/.well-known/acme-challenge/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:           "unavailable store",
+			store:          &unavailableStore{LocalStore: store},
+			path:           "/.well-known/acme-challenge/token",
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			router := mux.NewRouter()
+			provider := &Provider{Store: test.store}
+			provider.AddRoutes(router)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://traefik.wtf:8089"+test.path, nil))
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, test.expectedBody, recorder.Body.String())
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package acme
 
 import (
+	"context"
 	"crypto/tls"
 
-	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/metrics"
 	"github.com/containous/traefik/types"
 	"github.com/xenolf/lego/acme"
 )
@@ -11,11 +12,15 @@ import (
 var _ acme.ChallengeProvider = (*challengeTLSALPN)(nil)
 
 type challengeTLSALPN struct {
-	Store Store
+	Store           Store
+	metricsRegistry metrics.Registry
+	tracing         *issuanceTracer
+	timings         *issuanceTimings
 }
 
 func (c *challengeTLSALPN) Present(domain, token, keyAuth string) error {
-	log.Debugf("TLS Challenge Present temp certificate for %s", domain)
+	challengeLogger(challengeTypeTLSALPN01, domain).Debugf("TLS Challenge Present temp certificate for %s", domain)
+	c.timings.challengePresented(domain)
 
 	certPEMBlock, keyPEMBlock, err := acme.TLSALPNChallengeBlocks(domain, keyAuth)
 	if err != nil {
@@ -23,18 +28,42 @@ func (c *challengeTLSALPN) Present(domain, token, keyAuth string) error {
 	}
 
 	cert := &Certificate{Certificate: certPEMBlock, Key: keyPEMBlock, Domain: types.Domain{Main: "TEMP-" + domain}}
-	return c.Store.AddTLSChallenge(domain, cert)
+	err = c.tracing.traceChallenge(c.Store, challengeTypeTLSALPN01, challengeOperationPresent, domain, func() error {
+		return c.Store.AddTLSChallenge(context.Background(), domain, cert)
+	})
+	if err != nil {
+		countChallenges(c.metricsRegistry, challengeTypeTLSALPN01, challengeOutcomeFailed, 1)
+		return err
+	}
+
+	countChallenges(c.metricsRegistry, challengeTypeTLSALPN01, challengeOutcomeCreated, 1)
+	updatePendingChallenges(context.Background(), c.metricsRegistry, c.Store)
+	return nil
 }
 
 func (c *challengeTLSALPN) CleanUp(domain, token, keyAuth string) error {
-	log.Debugf("TLS Challenge CleanUp temp certificate for %s", domain)
+	challengeLogger(challengeTypeTLSALPN01, domain).Debugf("TLS Challenge CleanUp temp certificate for %s", domain)
+	c.timings.challengeCleanedUp(domain)
 
-	return c.Store.RemoveTLSChallenge(domain)
+	err := c.tracing.traceChallenge(c.Store, challengeTypeTLSALPN01, challengeOperationCleanUp, domain, func() error {
+		return c.Store.RemoveTLSChallenge(context.Background(), domain)
+	})
+	updatePendingChallenges(context.Background(), c.metricsRegistry, c.Store)
+	return err
 }
 
 // GetTLSALPNCertificate Get the temp certificate for ACME TLS-ALPN-O1 challenge.
 func (p *Provider) GetTLSALPNCertificate(domain string) (*tls.Certificate, error) {
-	cert, err := p.Store.GetTLSChallenge(domain)
+	var cert *Certificate
+	err := p.tracing.traceChallenge(p.Store, challengeTypeTLSALPN01, challengeOperationGet, domain, func() error {
+		var err error
+		cert, err = p.Store.GetTLSChallenge(context.Background(), domain)
+		if err == ErrNotFound {
+			// The domain has no pending challenge, it is not a failure
+			return nil
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,98 @@
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+// alpnValidationHandshake performs the TLS handshake done by the CA to validate a TLS-ALPN-01 challenge
+func alpnValidationHandshake(provider *Provider, serverName string) (*x509.Certificate, error) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	server := tls.Server(serverConn, &tls.Config{
+		NextProtos: []string{acme.ACMETLS1Protocol},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			certificate, err := provider.GetTLSALPNCertificate(hello.ServerName)
+			if err == nil && certificate == nil {
+				err = fmt.Errorf("no challenge certificate for %s", hello.ServerName)
+			}
+			return certificate, err
+		},
+	})
+	go func() {
+		_ = server.Handshake()
+		serverConn.Close()
+	}()
+
+	client := tls.Client(clientConn, &tls.Config{
+		ServerName:         serverName,
+		NextProtos:         []string{acme.ACMETLS1Protocol},
+		InsecureSkipVerify: true,
+	})
+	if err := client.Handshake(); err != nil {
+		return nil, err
+	}
+
+	return client.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestGetTLSALPNCertificateWildcard(t *testing.T) {
+	certPEMBlock, keyPEMBlock, err := acme.TLSALPNChallengeBlocks("*.traefik.wtf", "keyAuth")
+	require.NoError(t, err)
+
+	store := &LocalStore{storedData: &StoredData{}}
+	challenge := &challengeTLSALPN{Store: store}
+	require.NoError(t, store.AddTLSChallenge(context.Background(), "*.Traefik.wtf", &Certificate{Certificate: certPEMBlock, Key: keyPEMBlock, Domain: types.Domain{Main: "TEMP-*.traefik.wtf"}}))
+
+	provider := &Provider{Store: store}
+
+	testCases := []struct {
+		desc       string
+		serverName string
+		expected   bool
+	}{
+		{
+			desc:       "subdomain covered by the wildcard challenge",
+			serverName: "api.traefik.wtf",
+			expected:   true,
+		},
+		{
+			desc:       "apex not covered by the wildcard challenge",
+			serverName: "traefik.wtf",
+		},
+		{
+			desc:       "sub-subdomain not covered by the wildcard challenge",
+			serverName: "foo.api.traefik.wtf",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			leaf, err := alpnValidationHandshake(provider, test.serverName)
+			if !test.expected {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []string{"*.traefik.wtf"}, leaf.DNSNames)
+		})
+	}
+
+	require.NoError(t, challenge.CleanUp("*.traefik.wtf", "token", "keyAuth"))
+	certificate, err := provider.GetTLSALPNCertificate("api.traefik.wtf")
+	require.NoError(t, err)
+	assert.Nil(t, certificate)
+}
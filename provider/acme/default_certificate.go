@@ -0,0 +1,173 @@
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/containous/traefik/tls/generate"
+)
+
+const (
+	// defaultCertificateRenewBefore is the duration left before the expiry of the stored default certificate from which a new one is generated
+	defaultCertificateRenewBefore = 30 * 24 * time.Hour
+	// defaultCertificateTimeout bounds the read and the save of the stored default certificate
+	defaultCertificateTimeout = 10 * time.Second
+)
+
+// DefaultCertificate is the self-signed certificate served when no certificate matches the SNI,
+// stored apart from the ACME certificates: it is neither renewed with the CA nor alerted on.
+type DefaultCertificate struct {
+	Certificate  []byte
+	Key          []byte          `json:",omitempty"`
+	EncryptedKey *encryptedField `json:",omitempty"`
+	GeneratedAt  time.Time
+}
+
+// defaultCertificateStore is implemented by the stores keeping the default certificate of the entry points
+type defaultCertificateStore interface {
+	GetDefaultCertificate(ctx context.Context) (*DefaultCertificate, error)
+}
+
+// copyDefaultCertificate returns a copy of the default certificate, which does not share its private key
+func copyDefaultCertificate(certificate *DefaultCertificate) *DefaultCertificate {
+	if certificate == nil {
+		return nil
+	}
+
+	certificateCopy := *certificate
+	certificateCopy.Certificate = append([]byte(nil), certificate.Certificate...)
+	certificateCopy.Key = append([]byte(nil), certificate.Key...)
+
+	if certificate.EncryptedKey != nil {
+		certificateCopy.EncryptedKey = &encryptedField{
+			Nonce: append([]byte(nil), certificate.EncryptedKey.Nonce...),
+			Data:  append([]byte(nil), certificate.EncryptedKey.Data...),
+		}
+	}
+
+	return &certificateCopy
+}
+
+// sealKey returns a copy of the default certificate with an encrypted private key
+func (c *DefaultCertificate) sealKey(k *storageKey) (*DefaultCertificate, error) {
+	certificate := *c
+	if len(c.Key) == 0 {
+		return &certificate, nil
+	}
+
+	field, err := k.seal(c.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate.Key = nil
+	certificate.EncryptedKey = field
+	return &certificate, nil
+}
+
+// openKey decrypts the private key of the default certificate, a plaintext private key is kept as is
+func (c *DefaultCertificate) openKey(k *storageKey) error {
+	if c.EncryptedKey == nil {
+		return nil
+	}
+
+	key, err := k.open(c.EncryptedKey)
+	if err != nil {
+		return err
+	}
+
+	c.Key = key
+	c.EncryptedKey = nil
+	return nil
+}
+
+// parseDefaultCertificate returns the TLS certificate of the stored default certificate, and an error when it can not be served until the date
+func parseDefaultCertificate(stored *DefaultCertificate, until time.Time) (*tls.Certificate, error) {
+	certificate, err := tls.X509KeyPair(stored.Certificate, stored.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default certificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid default certificate: %v", err)
+	}
+	if until.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("the default certificate expires on %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	certificate.Leaf = leaf
+	return &certificate, nil
+}
+
+// GetDefaultCertificate returns the default certificate of the entry points, reused across the restarts by the stores keeping it.
+// The stored default certificate is served while it is valid for defaultCertificateRenewBefore at least,
+// a new one being generated and stored otherwise. A default certificate which can not be stored is served anyway until the next start.
+func (p *Provider) GetDefaultCertificate() (*tls.Certificate, error) {
+	p.defaultCertificateLock.Lock()
+	defer p.defaultCertificateLock.Unlock()
+
+	renewAt := time.Now().Add(defaultCertificateRenewBefore)
+	if p.defaultCertificate != nil && renewAt.Before(p.defaultCertificate.Leaf.NotAfter) {
+		return p.defaultCertificate, nil
+	}
+
+	ctx, cancel := context.WithTimeout(p.getContext(), defaultCertificateTimeout)
+	defer cancel()
+
+	store, ok := unwrapStore(p.Store).(defaultCertificateStore)
+
+	var stored *DefaultCertificate
+	if ok {
+		var err error
+		stored, err = store.GetDefaultCertificate(ctx)
+		if err != nil {
+			logger().Errorf("Unable to read the stored default certificate: %v", err)
+		}
+	}
+
+	if stored != nil {
+		certificate, errParse := parseDefaultCertificate(stored, renewAt)
+		if errParse == nil {
+			p.defaultCertificate = certificate
+			return certificate, nil
+		}
+		logger().Infof("Generating a new default certificate: %v", errParse)
+	}
+
+	certPEM, keyPEM, err := generate.DefaultKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	stored = &DefaultCertificate{Certificate: certPEM, Key: keyPEM, GeneratedAt: time.Now()}
+	certificate, err := parseDefaultCertificate(stored, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	// The generated default certificate is served until its renewal, whether it is stored or not
+	p.defaultCertificate = certificate
+
+	if !ok {
+		logger().Debug("The ACME store does not keep the default certificate, it is generated again on the next start.")
+		return certificate, nil
+	}
+
+	if p.isReadOnly() {
+		logger().Warn("The ACME storage is read-only, the generated default certificate is not stored.")
+		return certificate, nil
+	}
+
+	err = p.Store.Update(ctx, func(data *StoredData) error {
+		data.DefaultCertificate = stored
+		return nil
+	})
+	if err != nil {
+		logger().Errorf("Unable to store the generated default certificate, it is generated again on the next start: %v", err)
+	}
+	return certificate, nil
+}
@@ -0,0 +1,149 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/tls/generate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderGetDefaultCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	store := NewLocalStore(filename)
+	p := &Provider{Configuration: &Configuration{}, Store: store}
+
+	certificate, err := p.GetDefaultCertificate()
+	require.NoError(t, err)
+
+	cached, err := p.GetDefaultCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, certificate, cached)
+
+	require.NoError(t, store.Close(context.Background()))
+
+	// The stored default certificate is reused on the next start, apart from the ACME certificates
+	reloaded := NewLocalStore(filename)
+	defer reloaded.Close(context.Background())
+
+	certificates, err := reloaded.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, certificates)
+
+	restarted := &Provider{Configuration: &Configuration{}, Store: reloaded}
+	reused, err := restarted.GetDefaultCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, certificate.Certificate, reused.Certificate)
+
+	// The returned default certificate is not the stored one
+	stored, err := reloaded.GetDefaultCertificate(context.Background())
+	require.NoError(t, err)
+	stored.Key[0]++
+
+	stored, err = reloaded.GetDefaultCertificate(context.Background())
+	require.NoError(t, err)
+	_, err = parseDefaultCertificate(stored, time.Time{})
+	assert.NoError(t, err)
+}
+
+// notKeepingDefaultCertificateStore is a store which does not keep the default certificate
+type notKeepingDefaultCertificateStore struct {
+	Store
+}
+
+func TestProviderGetDefaultCertificateNotStored(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewLocalStore(filepath.Join(dir, "acme.json"))
+	defer store.Close(context.Background())
+
+	// A store which does not keep the default certificate only serves a generated one
+	p := &Provider{Configuration: &Configuration{}, Store: &notKeepingDefaultCertificateStore{Store: store}}
+	certificate, err := p.GetDefaultCertificate()
+	require.NoError(t, err)
+	assert.NotNil(t, certificate)
+
+	stored, err := store.GetDefaultCertificate(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestProviderGetDefaultCertificateRenewal(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		expiration       time.Time
+		expectedReused   bool
+		expectedReplaced bool
+	}{
+		{
+			desc:           "valid",
+			expiration:     time.Now().Add(2 * defaultCertificateRenewBefore),
+			expectedReused: true,
+		},
+		{
+			desc:             "close to its expiry",
+			expiration:       time.Now().Add(defaultCertificateRenewBefore / 2),
+			expectedReplaced: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			certPEM, keyPEM, err := generate.KeyPair("default.traefik.wtf", test.expiration)
+			require.NoError(t, err)
+
+			store := NewLocalStore(filepath.Join(dir, "acme.json"))
+			defer store.Close(context.Background())
+			require.NoError(t, store.Update(context.Background(), func(data *StoredData) error {
+				data.DefaultCertificate = &DefaultCertificate{Certificate: certPEM, Key: keyPEM}
+				return nil
+			}))
+
+			p := &Provider{Configuration: &Configuration{}, Store: store}
+			certificate, err := p.GetDefaultCertificate()
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedReused, certificate.Leaf.DNSNames[0] == "default.traefik.wtf")
+
+			stored, err := store.GetDefaultCertificate(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedReplaced, string(stored.Certificate) != string(certPEM))
+		})
+	}
+}
+
+func TestProviderGetDefaultCertificateReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewLocalStore(filepath.Join(dir, "acme.json"))
+	defer store.Close(context.Background())
+	store.SetReadOnly(true)
+
+	p := &Provider{Configuration: &Configuration{ReadOnly: true}, Store: store}
+	certificate, err := p.GetDefaultCertificate()
+	require.NoError(t, err)
+	assert.NotNil(t, certificate)
+
+	stored, err := store.GetDefaultCertificate(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
@@ -0,0 +1,122 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/metrics"
+	"github.com/containous/traefik/safe"
+)
+
+const defaultDeployHookTimeout = 1 * time.Minute
+
+// DeployHook runs a command once a certificate is issued or renewed and persisted
+type DeployHook struct {
+	Command          []string       `description:"Command to run and its arguments, it is not run in a shell"`
+	Timeout          parse.Duration `description:"Duration after which the command is killed. Default to 1m"`
+	CertificateFiles bool           `description:"Write the certificate and its private key to temporary files, passed in the TRAEFIK_ACME_CERT_FILE and TRAEFIK_ACME_KEY_FILE environment variables"`
+}
+
+// runDeployHooks runs the deploy hooks of the certificate in the background, one after the other.
+// The failures of the hooks are logged and counted, they never affect the certificate.
+func (p *Provider) runDeployHooks(certificate *Certificate) {
+	if len(p.DeployHooks) == 0 {
+		return
+	}
+
+	hooks := p.DeployHooks
+	registry := p.metricsRegistry
+	safe.Go(func() {
+		runDeployHookList(hooks, certificate, registry)
+	})
+}
+
+func runDeployHookList(hooks []DeployHook, certificate *Certificate, registry metrics.Registry) {
+	logger := domainsLogger(certificate.Domain.ToStrArray())
+	for _, hook := range hooks {
+		if err := runDeployHook(hook, certificate); err != nil {
+			logger.Errorf("Deploy hook %q failed for the certificate of the domains %v: %v", strings.Join(hook.Command, " "), certificate.Domain.ToStrArray(), err)
+			countHookFailures(registry)
+			continue
+		}
+		logger.Debugf("Deploy hook %q succeeded for the certificate of the domains %v", strings.Join(hook.Command, " "), certificate.Domain.ToStrArray())
+	}
+}
+
+func runDeployHook(hook DeployHook, certificate *Certificate) error {
+	if len(hook.Command) == 0 {
+		return errors.New("no command")
+	}
+
+	timeout := time.Duration(hook.Timeout)
+	if timeout <= 0 {
+		timeout = defaultDeployHookTimeout
+	}
+
+	env, err := getDeployHookEnv(certificate)
+	if err != nil {
+		return err
+	}
+
+	if hook.CertificateFiles {
+		dir, err := ioutil.TempDir("", "traefik-acme-hook")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		certFile := filepath.Join(dir, "cert.pem")
+		if err := ioutil.WriteFile(certFile, certificate.Certificate, 0600); err != nil {
+			return err
+		}
+		keyFile := filepath.Join(dir, "key.pem")
+		if err := ioutil.WriteFile(keyFile, certificate.Key, 0600); err != nil {
+			return err
+		}
+		env = append(env, "TRAEFIK_ACME_CERT_FILE="+certFile, "TRAEFIK_ACME_KEY_FILE="+keyFile)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func getDeployHookEnv(certificate *Certificate) ([]string, error) {
+	notAfter, err := getCertificateNotAfter(certificate.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the certificate: %v", err)
+	}
+
+	return []string{
+		"TRAEFIK_ACME_DOMAIN=" + certificate.Domain.Main,
+		"TRAEFIK_ACME_SANS=" + strings.Join(certificate.Domain.SANs, ","),
+		"TRAEFIK_ACME_NOT_AFTER=" + notAfter.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+func countHookFailures(registry metrics.Registry) {
+	if registry == nil {
+		return
+	}
+
+	registry.ACMEHookFailuresCounter().Add(1)
+}
@@ -0,0 +1,90 @@
+package acme
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDeployHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks are tested with a shell")
+	}
+
+	notAfter := time.Date(2030, time.March, 15, 10, 0, 0, 0, time.UTC)
+	certificate := &Certificate{
+		Domain:      types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf", "api.traefik.wtf"}},
+		Certificate: generateTestCertificate(t, notAfter),
+		Key:         []byte("key"),
+	}
+
+	dir, err := ioutil.TempDir("", "acme-hook")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	testCases := []struct {
+		desc     string
+		hook     DeployHook
+		expected string
+		err      string
+	}{
+		{
+			desc:     "environment",
+			hook:     DeployHook{Command: []string{"sh", "-c", `echo "$TRAEFIK_ACME_DOMAIN $TRAEFIK_ACME_SANS $TRAEFIK_ACME_NOT_AFTER $TRAEFIK_ACME_CERT_FILE" > ` + output}},
+			expected: "traefik.wtf www.traefik.wtf,api.traefik.wtf 2030-03-15T10:00:00Z",
+		},
+		{
+			desc:     "certificate files",
+			hook:     DeployHook{Command: []string{"sh", "-c", `cat "$TRAEFIK_ACME_KEY_FILE" > ` + output}, CertificateFiles: true},
+			expected: "key",
+		},
+		{
+			desc: "failure",
+			hook: DeployHook{Command: []string{"sh", "-c", "echo unreachable; exit 3"}},
+			err:  "exit status 3: unreachable",
+		},
+		{
+			desc: "timeout",
+			hook: DeployHook{Command: []string{"sleep", "10"}, Timeout: parse.Duration(100 * time.Millisecond)},
+			err:  "timed out after 100ms",
+		},
+		{
+			desc: "no command",
+			hook: DeployHook{},
+			err:  "no command",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			err := runDeployHook(test.hook, certificate)
+			if len(test.err) > 0 {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			content, err := ioutil.ReadFile(output)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, strings.TrimSpace(string(content)))
+		})
+	}
+}
+
+func TestRunDeployHookListFailures(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+
+	runDeployHookList([]DeployHook{{}, {}}, &Certificate{Domain: types.Domain{Main: "traefik.wtf"}}, registry)
+	assert.Equal(t, float64(2), registry.hooks.CounterValue)
+}
@@ -0,0 +1,153 @@
+package acme
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+)
+
+// DesiredDomain is a domain discovered in the configuration of the providers, kept in the store to provide its certificate across restarts
+type DesiredDomain struct {
+	Domain            types.Domain
+	Providers         []string
+	FirstSeenAt       time.Time
+	UnreferencedSince *time.Time `json:",omitempty"`
+}
+
+// desiredDomainsStore is implemented by the stores keeping the desired domains
+type desiredDomainsStore interface {
+	GetDesiredDomains(ctx context.Context) (map[string]*DesiredDomain, error)
+}
+
+func copyDesiredDomain(desired *DesiredDomain) *DesiredDomain {
+	desiredCopy := *desired
+	desiredCopy.Domain.SANs = append([]string(nil), desired.Domain.SANs...)
+	desiredCopy.Providers = append([]string(nil), desired.Providers...)
+	return &desiredCopy
+}
+
+func getDesiredDomainKey(domain types.Domain) string {
+	return strings.Join(domain.ToStrArray(), ",")
+}
+
+// setDesiredDomains returns the desired domains once the domains of the provider are replaced by the given ones, and whether they changed.
+// The domains no longer referenced by any provider are kept, and marked as unreferenced.
+func setDesiredDomains(desiredDomains map[string]*DesiredDomain, providerName string, domains []types.Domain, now time.Time) (map[string]*DesiredDomain, bool) {
+	referenced := make(map[string]types.Domain)
+	for _, domain := range domains {
+		referenced[getDesiredDomainKey(domain)] = domain
+	}
+
+	updated := make(map[string]*DesiredDomain, len(desiredDomains)+len(referenced))
+	for key, desired := range desiredDomains {
+		updated[key] = desired
+	}
+	for key, domain := range referenced {
+		if _, ok := updated[key]; !ok {
+			updated[key] = &DesiredDomain{Domain: domain, FirstSeenAt: now}
+		}
+	}
+
+	var changed bool
+	for key, desired := range updated {
+		_, isReferenced := referenced[key]
+
+		var providers []string
+		for _, name := range desired.Providers {
+			if name != providerName {
+				providers = append(providers, name)
+			}
+		}
+		if isReferenced {
+			providers = append(providers, providerName)
+			sort.Strings(providers)
+		}
+
+		unreferencedSince := desired.UnreferencedSince
+		if len(providers) > 0 {
+			unreferencedSince = nil
+		} else if unreferencedSince == nil {
+			unreferencedSince = &now
+		}
+
+		if strings.Join(providers, ",") == strings.Join(desired.Providers, ",") && (unreferencedSince == nil) == (desired.UnreferencedSince == nil) {
+			continue
+		}
+
+		// The entries are replaced, the callers may still hold the previous ones
+		desiredCopy := copyDesiredDomain(desired)
+		desiredCopy.Providers = providers
+		desiredCopy.UnreferencedSince = unreferencedSince
+		updated[key] = desiredCopy
+		changed = true
+	}
+
+	return updated, changed
+}
+
+// updateDesiredDomains replaces the desired domains discovered in the configuration of a provider, in the stores keeping them
+func (p *Provider) updateDesiredDomains(providerName string, domains []types.Domain) {
+	store, ok := unwrapStore(p.Store).(desiredDomainsStore)
+	if !ok || p.isPassive() {
+		return
+	}
+
+	desiredDomains, err := store.GetDesiredDomains(p.getContext())
+	if err != nil {
+		logger().Errorf("Unable to get the desired domains: %v", err)
+		return
+	}
+
+	// The store is only written when the domains of the provider changed
+	if _, changed := setDesiredDomains(desiredDomains, providerName, domains, time.Now()); !changed {
+		return
+	}
+
+	err = p.Store.Update(p.getContext(), func(data *StoredData) error {
+		data.DesiredDomains, _ = setDesiredDomains(data.DesiredDomains, providerName, domains, time.Now())
+		return nil
+	})
+	if err != nil && err != ErrReadOnly {
+		logger().Errorf("Unable to save the desired domains of the provider %q: %v", providerName, err)
+	}
+}
+
+// resumeDesiredDomains obtains the missing certificates of the domains still referenced by the providers, discovered before a restart
+func (p *Provider) resumeDesiredDomains() {
+	store, ok := unwrapStore(p.Store).(desiredDomainsStore)
+	if !ok || !p.OnHostRule || p.isPassive() {
+		return
+	}
+
+	desiredDomains, err := store.GetDesiredDomains(p.getContext())
+	if err != nil {
+		logger().Errorf("Unable to get the desired domains: %v", err)
+		return
+	}
+
+	var domains []types.Domain
+	for _, desired := range desiredDomains {
+		if desired.UnreferencedSince == nil {
+			domains = append(domains, desired.Domain)
+		}
+	}
+	if len(domains) == 0 {
+		return
+	}
+
+	sort.Slice(domains, func(i, j int) bool {
+		return getDesiredDomainKey(domains[i]) < getDesiredDomainKey(domains[j])
+	})
+
+	safe.Go(func() {
+		for _, domain := range domains {
+			if _, err := p.resolveCertificate(domain, false); err != nil {
+				domainsLogger(domain.ToStrArray()).Errorf("Unable to obtain ACME certificate for the desired domains %q: %v", getDesiredDomainKey(domain), err)
+			}
+		}
+	})
+}
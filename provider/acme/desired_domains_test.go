@@ -0,0 +1,137 @@
+package acme
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetDesiredDomains(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+
+	testCases := []struct {
+		desc            string
+		desiredDomains  map[string]*DesiredDomain
+		providerName    string
+		domains         []types.Domain
+		expected        map[string]*DesiredDomain
+		expectedChanged bool
+	}{
+		{
+			desc:         "new domains",
+			providerName: "kubernetes",
+			domains:      []types.Domain{{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}},
+			expected: map[string]*DesiredDomain{
+				"traefik.wtf,www.traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}, Providers: []string{"kubernetes"}, FirstSeenAt: now},
+			},
+			expectedChanged: true,
+		},
+		{
+			desc: "unchanged domains",
+			desiredDomains: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"kubernetes"}, FirstSeenAt: before},
+			},
+			providerName: "kubernetes",
+			domains:      []types.Domain{{Main: "traefik.wtf"}},
+			expected: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"kubernetes"}, FirstSeenAt: before},
+			},
+		},
+		{
+			desc: "domain referenced by another provider",
+			desiredDomains: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"kubernetes"}, FirstSeenAt: before},
+			},
+			providerName: "file",
+			domains:      []types.Domain{{Main: "traefik.wtf"}},
+			expected: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"file", "kubernetes"}, FirstSeenAt: before},
+			},
+			expectedChanged: true,
+		},
+		{
+			desc: "domain still referenced by another provider",
+			desiredDomains: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"file", "kubernetes"}, FirstSeenAt: before},
+			},
+			providerName: "kubernetes",
+			expected: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"file"}, FirstSeenAt: before},
+			},
+			expectedChanged: true,
+		},
+		{
+			desc: "removed domain",
+			desiredDomains: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"kubernetes"}, FirstSeenAt: before},
+			},
+			providerName: "kubernetes",
+			expected: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, FirstSeenAt: before, UnreferencedSince: &now},
+			},
+			expectedChanged: true,
+		},
+		{
+			desc: "domain unreferenced before",
+			desiredDomains: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, FirstSeenAt: before, UnreferencedSince: &before},
+			},
+			providerName: "kubernetes",
+			expected: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, FirstSeenAt: before, UnreferencedSince: &before},
+			},
+		},
+		{
+			desc: "domain referenced again",
+			desiredDomains: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, FirstSeenAt: before, UnreferencedSince: &before},
+			},
+			providerName: "kubernetes",
+			domains:      []types.Domain{{Main: "traefik.wtf"}},
+			expected: map[string]*DesiredDomain{
+				"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"kubernetes"}, FirstSeenAt: before},
+			},
+			expectedChanged: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			desiredDomains, changed := setDesiredDomains(test.desiredDomains, test.providerName, test.domains, now)
+			assert.Equal(t, test.expectedChanged, changed)
+			assert.Equal(t, test.expected, desiredDomains)
+		})
+	}
+}
+
+func TestUpdateDesiredDomains(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	p := &Provider{Configuration: &Configuration{OnHostRule: true}, Store: store}
+
+	p.updateDesiredDomains("kubernetes", []types.Domain{{Main: "traefik.wtf"}, {Main: "acme.wtf"}})
+	p.updateDesiredDomains("kubernetes", []types.Domain{{Main: "traefik.wtf"}})
+
+	// The desired domains are kept across restarts
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		desired, ok := storedData.DesiredDomains["acme.wtf"]
+		return ok && desired.UnreferencedSince != nil
+	})
+	desiredDomains, err := NewLocalStore(store.filename).GetDesiredDomains(context.Background())
+	require.NoError(t, err)
+	require.Len(t, desiredDomains, 2)
+
+	assert.Equal(t, []string{"kubernetes"}, desiredDomains["traefik.wtf"].Providers)
+	assert.Nil(t, desiredDomains["traefik.wtf"].UnreferencedSince)
+	assert.Empty(t, desiredDomains["acme.wtf"].Providers)
+	assert.NotNil(t, desiredDomains["acme.wtf"].UnreferencedSince)
+}
@@ -0,0 +1,141 @@
+package acme
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/xenolf/lego/acme"
+)
+
+// CredentialsSecretRef references the Kubernetes Secret holding the credentials of the DNS provider.
+// Each key of the Secret is the name of an environment variable read by the DNS provider, such as CF_API_KEY.
+type CredentialsSecretRef struct {
+	Namespace string `description:"Namespace of the Secret. Default to the namespace of the certificate Secrets"`
+	Name      string `description:"Name of the Secret"`
+}
+
+// String returns the namespace/name representation of the reference
+func (r *CredentialsSecretRef) String() string {
+	return fmt.Sprintf("%s/%s", r.Namespace, r.Name)
+}
+
+// dnsCredentials sets the credentials of the DNS provider from the referenced Secret. The DNS providers read their
+// credentials from the environment when they are built, the keys of the Secret are then set as environment variables.
+type dnsCredentials struct {
+	ref           *CredentialsSecretRef
+	getSecretData secretDataGetter
+
+	lock sync.Mutex
+	data map[string][]byte
+}
+
+func newDNSCredentials(ref *CredentialsSecretRef, getSecretData secretDataGetter) (*dnsCredentials, error) {
+	if len(ref.Namespace) == 0 || len(ref.Name) == 0 {
+		return nil, fmt.Errorf("invalid DNS credentials Secret reference %q: namespace and name are required", ref)
+	}
+
+	return &dnsCredentials{ref: ref, getSecretData: getSecretData}, nil
+}
+
+// load reads the Secret and sets its keys in the environment, it returns whether the credentials changed.
+// The keys removed from the Secret are removed from the environment.
+func (c *dnsCredentials) load() (bool, error) {
+	data, err := c.getSecretData(c.ref.Namespace, c.ref.Name)
+	if err != nil {
+		return false, fmt.Errorf("unable to read the DNS credentials Secret %s: %v", c.ref, err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.data != nil && isSecretDataEqual(c.data, data) {
+		return false, nil
+	}
+
+	for key := range c.data {
+		if _, ok := data[key]; !ok {
+			if err := os.Unsetenv(key); err != nil {
+				return false, err
+			}
+		}
+	}
+	for key, value := range data {
+		if err := os.Setenv(key, string(value)); err != nil {
+			return false, fmt.Errorf("invalid key %q in the DNS credentials Secret %s: %v", key, c.ref, err)
+		}
+	}
+
+	c.data = data
+	return true, nil
+}
+
+func isSecretDataEqual(data, other map[string][]byte) bool {
+	if len(data) != len(other) {
+		return false
+	}
+	for key, value := range data {
+		otherValue, ok := other[key]
+		if !ok || string(value) != string(otherValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// initDNSCredentials sets the credentials of the DNS provider from the referenced Secret, and checks that the DNS
+// provider finds all the credentials it requires: the DNS providers fail to build when one of them is missing.
+func (p *Provider) initDNSCredentials(getSecretData secretDataGetter, newProvider dnsProviderGetter) error {
+	ref := *p.DNSChallenge.CredentialsSecretRef
+	if len(ref.Namespace) == 0 && p.CertificateSecrets != nil {
+		ref.Namespace = p.CertificateSecrets.Namespace
+	}
+
+	credentials, err := newDNSCredentials(&ref, getSecretData)
+	if err != nil {
+		return err
+	}
+
+	if _, err = credentials.load(); err != nil {
+		return err
+	}
+
+	if _, err = newProvider(p.DNSChallenge.Provider); err != nil {
+		return fmt.Errorf("the DNS credentials Secret %s misses credentials of the DNS provider %s: %v", &ref, p.DNSChallenge.Provider, err)
+	}
+
+	logger().WithField(logFieldChallengeType, challengeTypeDNS01).Infof("Using the credentials of the DNS provider %s from the Secret %s.", p.DNSChallenge.Provider, &ref)
+	p.dnsCredentials = credentials
+	return nil
+}
+
+// renewProvider reads the credentials Secret again after a failure of the DNS provider, and builds the provider again
+// when the credentials changed: they may have been rotated, the provider then failing to authenticate.
+// It returns whether the provider is renewed.
+func (c *challengeDNS) renewProvider(domain string) bool {
+	if c.credentials == nil || c.newProvider == nil {
+		return false
+	}
+
+	logger := challengeLogger(challengeTypeDNS01, domain)
+
+	changed, err := c.credentials.load()
+	if err != nil {
+		logger.Errorf("Unable to reload the credentials of the DNS provider %s: %v", c.providerName, err)
+		return false
+	}
+	if !changed {
+		return false
+	}
+
+	var provider acme.ChallengeProvider
+	provider, err = c.newProvider(c.providerName)
+	if err != nil {
+		logger.Errorf("Unable to build the DNS provider %s with the reloaded credentials: %v", c.providerName, err)
+		return false
+	}
+
+	logger.Infof("The credentials of the DNS provider %s have changed, retrying with them.", c.providerName)
+	c.provider = provider
+	return true
+}
@@ -0,0 +1,138 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+const testDNSCredentialsKey = "TRAEFIK_TEST_DNS_AUTH_TOKEN"
+
+// credentialsDNSProvider fails to present the challenges without the expected token in the environment
+type credentialsDNSProvider struct {
+	fakeDNSProvider
+	token string
+}
+
+func (f *credentialsDNSProvider) Present(domain, token, keyAuth string) error {
+	if os.Getenv(testDNSCredentialsKey) != f.token {
+		return errors.New("401 Unauthorized")
+	}
+	return f.fakeDNSProvider.Present(domain, token, keyAuth)
+}
+
+func newCredentialsDNSProvider(token string) dnsProviderGetter {
+	return func(name string) (acme.ChallengeProvider, error) {
+		if len(os.Getenv(testDNSCredentialsKey)) == 0 {
+			return nil, fmt.Errorf("%s: some credentials information are missing: %s", name, testDNSCredentialsKey)
+		}
+		return &credentialsDNSProvider{token: token}, nil
+	}
+}
+
+func TestInitDNSCredentials(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		ref                *CredentialsSecretRef
+		certificateSecrets *TLSSecrets
+		secrets            map[string]map[string][]byte
+		expectedError      string
+	}{
+		{
+			desc:    "credentials in the Secret",
+			ref:     &CredentialsSecretRef{Namespace: "traefik", Name: "dns"},
+			secrets: map[string]map[string][]byte{"traefik/dns": {testDNSCredentialsKey: []byte("token")}},
+		},
+		{
+			desc:               "namespace of the certificate Secrets",
+			ref:                &CredentialsSecretRef{Name: "dns"},
+			certificateSecrets: &TLSSecrets{Namespace: "traefik"},
+			secrets:            map[string]map[string][]byte{"traefik/dns": {testDNSCredentialsKey: []byte("token")}},
+		},
+		{
+			desc:          "no namespace",
+			ref:           &CredentialsSecretRef{Name: "dns"},
+			expectedError: `invalid DNS credentials Secret reference "/dns": namespace and name are required`,
+		},
+		{
+			desc:          "missing Secret",
+			ref:           &CredentialsSecretRef{Namespace: "traefik", Name: "dns"},
+			expectedError: `unable to read the DNS credentials Secret traefik/dns: secrets "dns" not found`,
+		},
+		{
+			desc:          "missing credentials",
+			ref:           &CredentialsSecretRef{Namespace: "traefik", Name: "dns"},
+			secrets:       map[string]map[string][]byte{"traefik/dns": {"OTHER": []byte("token")}},
+			expectedError: "the DNS credentials Secret traefik/dns misses credentials of the DNS provider fake: fake: some credentials information are missing: " + testDNSCredentialsKey,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			defer os.Unsetenv(testDNSCredentialsKey)
+			defer os.Unsetenv("OTHER")
+
+			getSecretData := func(namespace, name string) (map[string][]byte, error) {
+				data, ok := test.secrets[namespace+"/"+name]
+				if !ok {
+					return nil, fmt.Errorf("secrets %q not found", name)
+				}
+				return data, nil
+			}
+
+			provider := &Provider{Configuration: &Configuration{
+				DNSChallenge:       &DNSChallenge{Provider: "fake", CredentialsSecretRef: test.ref},
+				CertificateSecrets: test.certificateSecrets,
+			}}
+
+			err := provider.initDNSCredentials(getSecretData, newCredentialsDNSProvider("token"))
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				assert.Nil(t, provider.dnsCredentials)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotNil(t, provider.dnsCredentials)
+			assert.Equal(t, "token", os.Getenv(testDNSCredentialsKey))
+		})
+	}
+}
+
+func TestChallengeDNSRenewsRotatedCredentials(t *testing.T) {
+	defer os.Unsetenv(testDNSCredentialsKey)
+	defer os.Unsetenv("OTHER")
+
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	data := map[string][]byte{testDNSCredentialsKey: []byte("old"), "OTHER": []byte("other")}
+	credentials, err := newDNSCredentials(&CredentialsSecretRef{Namespace: "traefik", Name: "dns"}, func(namespace, name string) (map[string][]byte, error) {
+		return data, nil
+	})
+	require.NoError(t, err)
+	changed, err := credentials.load()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	newProvider := newCredentialsDNSProvider("new")
+	provider, err := newProvider("fake")
+	require.NoError(t, err)
+	challenge := &challengeDNS{provider: provider, providerName: "fake", Store: store, credentials: credentials, newProvider: newProvider}
+
+	// The credentials are not rotated yet
+	assert.EqualError(t, challenge.Present("traefik.wtf", "token", "keyAuth"), "401 Unauthorized")
+
+	data = map[string][]byte{testDNSCredentialsKey: []byte("new")}
+	require.NoError(t, challenge.Present("traefik.wtf", "token", "keyAuth"))
+	assert.Equal(t, []string{"traefik.wtf:token"}, challenge.provider.(*credentialsDNSProvider).presented)
+
+	// The keys removed from the Secret are removed from the environment
+	_, ok := os.LookupEnv("OTHER")
+	assert.False(t, ok)
+}
@@ -0,0 +1,81 @@
+package acme
+
+import (
+	"strings"
+
+	"github.com/containous/traefik/types"
+)
+
+// DomainChallenge holds the challenge type used to validate a domain
+type DomainChallenge struct {
+	Domain    string `description:"Domain using the challenge type. A domain starting with '*.' or '.' matches all its subdomains"`
+	Challenge string `description:"Challenge type used to validate the domain. Allow value 'http-01', 'tls-alpn-01', 'dns-01'"`
+}
+
+// getChallengeType returns the default challenge type of the provider:
+// DNS-01 takes precedence over TLS-ALPN-01, which takes precedence over HTTP-01
+func (p *Provider) getChallengeType() string {
+	if p.isChallengeConfigured(challengeTypeDNS01) {
+		return challengeTypeDNS01
+	}
+	if p.isChallengeConfigured(challengeTypeTLSALPN01) {
+		return challengeTypeTLSALPN01
+	}
+	if p.isChallengeConfigured(challengeTypeHTTP01) {
+		return challengeTypeHTTP01
+	}
+	return ""
+}
+
+func (p *Provider) isChallengeConfigured(challengeType string) bool {
+	switch challengeType {
+	case challengeTypeDNS01:
+		return p.DNSChallenge != nil && len(p.DNSChallenge.Provider) > 0
+	case challengeTypeHTTP01:
+		return p.HTTPChallenge != nil && len(p.HTTPChallenge.EntryPoint) > 0
+	case challengeTypeTLSALPN01:
+		return p.TLSChallenge != nil
+	default:
+		return false
+	}
+}
+
+// getDomainChallengeType returns the challenge type to use for the certificate of the given domain:
+// wildcard domains always use DNS-01, then an exact domain override takes precedence over a suffix one,
+// then the challenge type which issued the stored certificate, then the default challenge type
+func (p *Provider) getDomainChallengeType(domain types.Domain, certificate *Certificate) string {
+	main := normalizeDomain(domain.Main)
+
+	if strings.HasPrefix(main, "*.") && p.isChallengeConfigured(challengeTypeDNS01) {
+		return challengeTypeDNS01
+	}
+
+	for _, override := range p.DomainsChallenge {
+		if normalizeDomain(override.Domain) == main {
+			return p.getConfiguredChallengeType(override)
+		}
+	}
+
+	for _, override := range p.DomainsChallenge {
+		suffix := normalizeDomain(override.Domain)
+		suffix = strings.TrimPrefix(suffix, "*")
+		if strings.HasPrefix(suffix, ".") && strings.HasSuffix(main, suffix) {
+			return p.getConfiguredChallengeType(override)
+		}
+	}
+
+	if certificate != nil && p.isChallengeConfigured(certificate.ChallengeType) {
+		return certificate.ChallengeType
+	}
+
+	return p.getChallengeType()
+}
+
+func (p *Provider) getConfiguredChallengeType(override DomainChallenge) string {
+	if p.isChallengeConfigured(override.Challenge) {
+		return override.Challenge
+	}
+
+	challengeLogger(override.Challenge, override.Domain).Warnf("The challenge %q configured for domain %q is not available, using the default challenge.", override.Challenge, override.Domain)
+	return p.getChallengeType()
+}
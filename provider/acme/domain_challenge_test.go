@@ -0,0 +1,155 @@
+package acme
+
+import (
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDomainChallengeType(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		conf        *Configuration
+		domain      types.Domain
+		certificate *Certificate
+		expected    string
+	}{
+		{
+			desc: "no override",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+			},
+			domain:   types.Domain{Main: "example.com"},
+			expected: challengeTypeHTTP01,
+		},
+		{
+			desc: "exact override",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+				DomainsChallenge: []DomainChallenge{
+					{Domain: "example.com", Challenge: challengeTypeHTTP01},
+				},
+			},
+			domain:   types.Domain{Main: "Example.com."},
+			expected: challengeTypeHTTP01,
+		},
+		{
+			desc: "suffix override",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+				DomainsChallenge: []DomainChallenge{
+					{Domain: ".example.com", Challenge: challengeTypeHTTP01},
+				},
+			},
+			domain:   types.Domain{Main: "www.example.com"},
+			expected: challengeTypeHTTP01,
+		},
+		{
+			desc: "wildcard suffix override",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+				DomainsChallenge: []DomainChallenge{
+					{Domain: "*.example.com", Challenge: challengeTypeHTTP01},
+				},
+			},
+			domain:   types.Domain{Main: "a.b.example.com"},
+			expected: challengeTypeHTTP01,
+		},
+		{
+			desc: "suffix override does not match the apex domain",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+				DomainsChallenge: []DomainChallenge{
+					{Domain: ".example.com", Challenge: challengeTypeHTTP01},
+				},
+			},
+			domain:   types.Domain{Main: "example.com"},
+			expected: challengeTypeDNS01,
+		},
+		{
+			desc: "exact override takes precedence over suffix override",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				TLSChallenge:  &TLSChallenge{},
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+				DomainsChallenge: []DomainChallenge{
+					{Domain: ".example.com", Challenge: challengeTypeHTTP01},
+					{Domain: "www.example.com", Challenge: challengeTypeTLSALPN01},
+				},
+			},
+			domain:   types.Domain{Main: "www.example.com"},
+			expected: challengeTypeTLSALPN01,
+		},
+		{
+			desc: "wildcard domain always uses DNS challenge",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+				DomainsChallenge: []DomainChallenge{
+					{Domain: ".example.com", Challenge: challengeTypeHTTP01},
+				},
+			},
+			domain:   types.Domain{Main: "*.example.com"},
+			expected: challengeTypeDNS01,
+		},
+		{
+			desc: "unavailable override challenge",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				DomainsChallenge: []DomainChallenge{
+					{Domain: "example.com", Challenge: challengeTypeDNS01},
+				},
+			},
+			domain:   types.Domain{Main: "example.com"},
+			expected: challengeTypeHTTP01,
+		},
+		{
+			desc: "certificate challenge is preferred on renewal",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+			},
+			domain:      types.Domain{Main: "example.com"},
+			certificate: &Certificate{ChallengeType: challengeTypeHTTP01},
+			expected:    challengeTypeHTTP01,
+		},
+		{
+			desc: "override takes precedence over certificate challenge",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+				DomainsChallenge: []DomainChallenge{
+					{Domain: "example.com", Challenge: challengeTypeDNS01},
+				},
+			},
+			domain:      types.Domain{Main: "example.com"},
+			certificate: &Certificate{ChallengeType: challengeTypeHTTP01},
+			expected:    challengeTypeDNS01,
+		},
+		{
+			desc: "unavailable certificate challenge",
+			conf: &Configuration{
+				DNSChallenge: &DNSChallenge{Provider: "manual"},
+			},
+			domain:      types.Domain{Main: "example.com"},
+			certificate: &Certificate{ChallengeType: challengeTypeHTTP01},
+			expected:    challengeTypeDNS01,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			p := &Provider{Configuration: test.conf}
+
+			assert.Equal(t, test.expected, p.getDomainChallengeType(test.domain, test.certificate))
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package acme
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// normalizeDomain returns the lower case ASCII (punycode) form of the domain, without trailing dot.
+// It must be used for every domain key of the Store, and before comparing domains.
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+
+	asciiDomain, err := idna.ToASCII(domain)
+	if err != nil {
+		return domain
+	}
+	return asciiDomain
+}
+
+// matchDomain checks if the domain is served by the certificate domain, according to RFC 6125:
+// a wildcard matches exactly one label, never across dots, and never the bare apex domain
+func matchDomain(domain, certDomain string) bool {
+	domain = normalizeDomain(domain)
+	certDomain = normalizeDomain(certDomain)
+
+	if len(domain) == 0 || len(certDomain) == 0 {
+		return false
+	}
+
+	if domain == certDomain {
+		return true
+	}
+
+	if !strings.HasPrefix(certDomain, "*.") {
+		return false
+	}
+
+	idx := strings.Index(domain, ".")
+	if idx <= 0 {
+		return false
+	}
+
+	return domain[idx+1:] == strings.TrimPrefix(certDomain, "*.")
+}
+
+// findCertificateByDomain returns the certificate serving the domain, an exact match takes precedence over a wildcard one
+func findCertificateByDomain(certificates []*Certificate, domain string) *Certificate {
+	domain = normalizeDomain(domain)
+
+	var wildcardCertificate *Certificate
+	for _, certificate := range certificates {
+		for _, certDomain := range certificate.Domain.ToStrArray() {
+			if !matchDomain(domain, certDomain) {
+				continue
+			}
+
+			if !strings.HasPrefix(certDomain, "*.") {
+				return certificate
+			}
+
+			if wildcardCertificate == nil {
+				wildcardCertificate = certificate
+			}
+		}
+	}
+
+	return wildcardCertificate
+}
@@ -0,0 +1,207 @@
+package acme
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		domain   string
+		expected string
+	}{
+		{
+			desc:     "already normalized",
+			domain:   "traefik.wtf",
+			expected: "traefik.wtf",
+		},
+		{
+			desc:     "mixed case",
+			domain:   "WWW.Traefik.wtf",
+			expected: "www.traefik.wtf",
+		},
+		{
+			desc:     "trailing dot",
+			domain:   "traefik.wtf.",
+			expected: "traefik.wtf",
+		},
+		{
+			desc:     "unicode",
+			domain:   "Bücher.example.",
+			expected: "xn--bcher-kva.example",
+		},
+		{
+			desc:     "punycode",
+			domain:   "XN--BCHER-KVA.example",
+			expected: "xn--bcher-kva.example",
+		},
+		{
+			desc:     "wildcard",
+			domain:   "*.Bücher.example",
+			expected: "*.xn--bcher-kva.example",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, normalizeDomain(test.domain))
+		})
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		domain     string
+		certDomain string
+		expected   bool
+	}{
+		{
+			desc:       "exact match",
+			domain:     "traefik.wtf",
+			certDomain: "traefik.wtf",
+			expected:   true,
+		},
+		{
+			desc:       "exact match is case insensitive",
+			domain:     "Traefik.WTF",
+			certDomain: "traefik.wtf",
+			expected:   true,
+		},
+		{
+			desc:       "exact match with trailing dot",
+			domain:     "traefik.wtf.",
+			certDomain: "traefik.wtf",
+			expected:   true,
+		},
+		{
+			desc:       "exact match with trailing dot on certificate domain",
+			domain:     "traefik.wtf",
+			certDomain: "traefik.wtf.",
+			expected:   true,
+		},
+		{
+			desc:       "different domain",
+			domain:     "traefik.io",
+			certDomain: "traefik.wtf",
+		},
+		{
+			desc:       "wildcard matches one label",
+			domain:     "api.traefik.wtf",
+			certDomain: "*.traefik.wtf",
+			expected:   true,
+		},
+		{
+			desc:       "wildcard with trailing dot",
+			domain:     "api.traefik.wtf.",
+			certDomain: "*.traefik.wtf",
+			expected:   true,
+		},
+		{
+			desc:       "wildcard does not match the apex",
+			domain:     "traefik.wtf",
+			certDomain: "*.traefik.wtf",
+		},
+		{
+			desc:       "wildcard does not match across dots",
+			domain:     "foo.api.traefik.wtf",
+			certDomain: "*.traefik.wtf",
+		},
+		{
+			desc:       "multi-level wildcard matches one label",
+			domain:     "foo.api.traefik.wtf",
+			certDomain: "*.api.traefik.wtf",
+			expected:   true,
+		},
+		{
+			desc:       "wildcard does not match an empty label",
+			domain:     ".traefik.wtf",
+			certDomain: "*.traefik.wtf",
+		},
+		{
+			desc:       "exact match does not match subdomains",
+			domain:     "api.traefik.wtf",
+			certDomain: "traefik.wtf",
+		},
+		{
+			desc:       "unicode domain matches punycode certificate domain",
+			domain:     "bücher.example",
+			certDomain: "xn--bcher-kva.example",
+			expected:   true,
+		},
+		{
+			desc:       "punycode domain matches unicode wildcard certificate domain",
+			domain:     "www.xn--bcher-kva.example",
+			certDomain: "*.bücher.example",
+			expected:   true,
+		},
+		{
+			desc:       "empty domain",
+			domain:     "",
+			certDomain: "*.traefik.wtf",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, matchDomain(test.domain, test.certDomain))
+		})
+	}
+}
+
+func TestLocalStoreGetCertificateByDomain(t *testing.T) {
+	exact := &Certificate{Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"api.traefik.wtf"}}}
+	wildcard := &Certificate{Domain: types.Domain{Main: "*.traefik.wtf"}}
+
+	store := &LocalStore{storedData: &StoredData{Certificates: []*Certificate{wildcard, exact}}}
+
+	testCases := []struct {
+		desc        string
+		domain      string
+		expected    *Certificate
+		expectedErr error
+	}{
+		{
+			desc:     "exact match on SAN takes precedence over wildcard",
+			domain:   "api.traefik.wtf",
+			expected: exact,
+		},
+		{
+			desc:     "wildcard match",
+			domain:   "www.traefik.wtf",
+			expected: wildcard,
+		},
+		{
+			desc:        "not found",
+			domain:      "foo.www.traefik.wtf",
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			certificate, err := store.GetCertificateByDomain(context.Background(), test.domain)
+			if test.expectedErr != nil {
+				require.Equal(t, test.expectedErr, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, certificate)
+		})
+	}
+}
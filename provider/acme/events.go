@@ -0,0 +1,247 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/safe"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	eventReasonCertificateIssued  = "CertificateIssued"
+	eventReasonCertificateRenewed = "CertificateRenewed"
+	eventReasonIssuanceFailed     = "CertificateIssuanceFailed"
+	eventReasonRenewalFailed      = "CertificateRenewalFailed"
+	eventReasonStorageFailed      = "StorageWriteFailed"
+	eventReasonTransparencyFailed = "CertificateTransparencyFailed"
+
+	// eventStorageKey is the rate limiting key of the storage events, which are not related to a domain
+	eventStorageKey = "storage"
+
+	maxEventErrorLength      = 256
+	defaultEventsMinInterval = 10 * time.Minute
+
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// KubernetesEvents emits Kubernetes Events for the ACME certificates issuance and renewal outcomes
+type KubernetesEvents struct {
+	Kind        string         `description:"Kind of the object receiving the Events. Default to Pod"`
+	Namespace   string         `description:"Namespace of the object receiving the Events. Default to the POD_NAMESPACE environment variable, or the namespace of the service account"`
+	Name        string         `description:"Name of the object receiving the Events. Default to the POD_NAME environment variable, or the host name"`
+	MinInterval parse.Duration `description:"Minimum interval between two Events with the same reason for a domain. Default to 10m"`
+}
+
+// eventsClient creates the Kubernetes Events
+type eventsClient interface {
+	Create(event *corev1.Event) error
+}
+
+type kubernetesEventsClient struct {
+	clientset kubernetes.Interface
+}
+
+func (c *kubernetesEventsClient) Create(event *corev1.Event) error {
+	_, err := c.clientset.CoreV1().Events(event.Namespace).Create(event)
+	return err
+}
+
+// eventRecorder emits the Events of the ACME provider, at most once per interval for a domain and a reason.
+// A nil eventRecorder emits nothing.
+type eventRecorder struct {
+	client      eventsClient
+	object      corev1.ObjectReference
+	minInterval time.Duration
+
+	lock     sync.Mutex
+	lastSent map[string]time.Time
+}
+
+func newInClusterEventRecorder(config *KubernetesEvents) (*eventRecorder, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create in-cluster configuration: %s", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEventRecorder(config, &kubernetesEventsClient{clientset: clientset})
+}
+
+func newEventRecorder(config *KubernetesEvents, client eventsClient) (*eventRecorder, error) {
+	object := corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       config.Kind,
+		Namespace:  config.Namespace,
+		Name:       config.Name,
+	}
+
+	if len(object.Kind) == 0 {
+		object.Kind = "Pod"
+	}
+	if len(object.Namespace) == 0 {
+		object.Namespace = getPodNamespace()
+	}
+	if len(object.Name) == 0 {
+		object.Name = getPodName()
+	}
+	if len(object.Namespace) == 0 || len(object.Name) == 0 {
+		return nil, fmt.Errorf("unable to find the object receiving the Events: kind %s, namespace %q, name %q", object.Kind, object.Namespace, object.Name)
+	}
+
+	minInterval := time.Duration(config.MinInterval)
+	if minInterval <= 0 {
+		minInterval = defaultEventsMinInterval
+	}
+
+	return &eventRecorder{
+		client:      client,
+		object:      object,
+		minInterval: minInterval,
+		lastSent:    make(map[string]time.Time),
+	}, nil
+}
+
+func getPodNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); len(namespace) > 0 {
+		return namespace
+	}
+
+	namespace, err := ioutil.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(namespace))
+}
+
+func getPodName() string {
+	if name := os.Getenv("POD_NAME"); len(name) > 0 {
+		return name
+	}
+
+	// The host name of a container is the name of its Pod
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+func (r *eventRecorder) certificateObtained(domains []string, certificate []byte, renewal bool) {
+	reason := eventReasonCertificateIssued
+	action := "issued"
+	if renewal {
+		reason = eventReasonCertificateRenewed
+		action = "renewed"
+	}
+
+	message := fmt.Sprintf("Certificate %s for the domains %s", action, strings.Join(domains, ","))
+	if notAfter, err := getCertificateNotAfter(certificate); err == nil {
+		message += fmt.Sprintf(", valid until %s", notAfter.Format(time.RFC3339))
+	}
+
+	r.record(domains[0], corev1.EventTypeNormal, reason, message)
+}
+
+func (r *eventRecorder) certificateFailed(domains []string, renewal bool, err error) {
+	reason := eventReasonIssuanceFailed
+	action := "issue"
+	if renewal {
+		reason = eventReasonRenewalFailed
+		action = "renew"
+	}
+
+	message := fmt.Sprintf("Unable to %s the certificate for the domains %s: %s", action, strings.Join(domains, ","), truncateEventError(err))
+	r.record(domains[0], corev1.EventTypeWarning, reason, message)
+}
+
+func (r *eventRecorder) certificateTransparencyFailed(domains []string, err error) {
+	message := fmt.Sprintf("Unable to verify the Certificate Transparency of the certificate for the domains %s: %s", strings.Join(domains, ","), truncateEventError(err))
+	r.record(domains[0], corev1.EventTypeWarning, eventReasonTransparencyFailed, message)
+}
+
+func (r *eventRecorder) storageFailed(err error) {
+	r.record(eventStorageKey, corev1.EventTypeWarning, eventReasonStorageFailed, fmt.Sprintf("Unable to write the ACME storage: %s", truncateEventError(err)))
+}
+
+func (r *eventRecorder) record(key, eventType, reason, message string) {
+	if r == nil {
+		return
+	}
+
+	now := time.Now()
+
+	r.lock.Lock()
+	rateKey := key + "/" + reason
+	if lastSent, ok := r.lastSent[rateKey]; ok && now.Sub(lastSent) < r.minInterval {
+		r.lock.Unlock()
+		logger().WithField(logFieldEvent, rateKey).Debugf("Skip the Kubernetes Event %s for %s, sent less than %s ago.", reason, key, r.minInterval)
+		return
+	}
+	r.lastSent[rateKey] = now
+	r.lock.Unlock()
+
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: r.object.Name + ".",
+			Namespace:    r.object.Namespace,
+		},
+		InvolvedObject: r.object,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: "traefik"},
+		FirstTimestamp: metav1.NewTime(now),
+		LastTimestamp:  metav1.NewTime(now),
+		Count:          1,
+	}
+
+	safe.Go(func() {
+		if err := r.client.Create(event); err != nil {
+			logger().WithFields(logrus.Fields{logFieldNamespace: event.Namespace, logFieldEvent: rateKey}).Errorf("Unable to create the Kubernetes Event %s: %v", reason, err)
+		}
+	})
+}
+
+// truncateEventError keeps the Events short, the full error is logged
+func truncateEventError(err error) string {
+	message := err.Error()
+	if len(message) > maxEventErrorLength {
+		message = message[:maxEventErrorLength] + "..."
+	}
+	return message
+}
+
+func getCertificateNotAfter(certificate []byte) (time.Time, error) {
+	crt, err := parseCertificateLeaf(certificate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return crt.NotAfter, nil
+}
+
+// parseCertificateLeaf parses the first certificate of the PEM encoded chain
+func parseCertificateLeaf(certificate []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certificate)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,119 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type fakeEventsClient struct {
+	lock   sync.Mutex
+	events []*corev1.Event
+}
+
+func (c *fakeEventsClient) Create(event *corev1.Event) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.events = append(c.events, event)
+	return nil
+}
+
+func (c *fakeEventsClient) getReasons() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var reasons []string
+	for _, event := range c.events {
+		reasons = append(reasons, event.Reason)
+	}
+	return reasons
+}
+
+func generateTestCertificate(t *testing.T, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// waitForEvents waits for the given number of events to be created
+func waitForEvents(client *fakeEventsClient, count int) []string {
+	var reasons []string
+	for i := 0; i < 500; i++ {
+		reasons = client.getReasons()
+		if len(reasons) >= count {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return reasons
+}
+
+func TestEventRecorder(t *testing.T) {
+	client := &fakeEventsClient{}
+	recorder, err := newEventRecorder(&KubernetesEvents{Namespace: "traefik", Name: "traefik-0"}, client)
+	require.NoError(t, err)
+	assert.Equal(t, "Pod", recorder.object.Kind)
+
+	renewalErr := errors.New(strings.Repeat("rate limited ", 100))
+
+	recorder.certificateObtained([]string{"traefik.wtf", "www.traefik.wtf"}, generateTestCertificate(t, time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)), false)
+	recorder.certificateFailed([]string{"traefik.wtf"}, true, renewalErr)
+	// Rate limited per domain and reason
+	recorder.certificateFailed([]string{"traefik.wtf"}, true, renewalErr)
+	recorder.certificateFailed([]string{"other.wtf"}, true, renewalErr)
+	recorder.storageFailed(errors.New("permission denied"))
+
+	reasons := waitForEvents(client, 4)
+	assert.ElementsMatch(t, []string{eventReasonCertificateIssued, eventReasonRenewalFailed, eventReasonRenewalFailed, eventReasonStorageFailed}, reasons)
+
+	time.Sleep(50 * time.Millisecond)
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	require.Len(t, client.events, 4)
+
+	for _, event := range client.events {
+		assert.Equal(t, "traefik", event.Namespace)
+		assert.Equal(t, "traefik-0", event.InvolvedObject.Name)
+
+		switch event.Reason {
+		case eventReasonCertificateIssued:
+			assert.Equal(t, corev1.EventTypeNormal, event.Type)
+			assert.Contains(t, event.Message, "traefik.wtf,www.traefik.wtf")
+			assert.Contains(t, event.Message, "valid until 2030-01-01T00:00:00Z")
+		case eventReasonRenewalFailed:
+			assert.Equal(t, corev1.EventTypeWarning, event.Type)
+			assert.True(t, len(event.Message) < maxEventErrorLength+100, event.Message)
+		}
+	}
+}
+
+func TestEventRecorderDisabled(t *testing.T) {
+	var recorder *eventRecorder
+
+	// A nil recorder emits nothing
+	recorder.certificateObtained([]string{"traefik.wtf"}, nil, false)
+	recorder.storageFailed(errors.New("permission denied"))
+
+	_, err := newEventRecorder(&KubernetesEvents{Kind: "Deployment", Name: "traefik"}, &fakeEventsClient{})
+	if len(getPodNamespace()) == 0 {
+		assert.Error(t, err)
+	}
+}
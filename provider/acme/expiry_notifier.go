@@ -0,0 +1,290 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/types"
+)
+
+const (
+	defaultExpiryThreshold    = 14 * 24 * time.Hour
+	defaultExpiryScanInterval = 1 * time.Hour
+	defaultExpiryMinInterval  = 24 * time.Hour
+	defaultWebhookTimeout     = 10 * time.Second
+
+	// ExpiryWebhookSignatureHeader is the header of the HMAC-SHA256 signature of the webhook notifications body
+	ExpiryWebhookSignatureHeader = "X-Traefik-Signature"
+)
+
+// ExpiryAlerts notifies the stored certificates close to their expiry which are not renewed
+type ExpiryAlerts struct {
+	Threshold    parse.Duration `description:"Notify the certificates expiring within this duration, when their renewal is failing or did not happen. Default to 336h (14 days)"`
+	ScanInterval parse.Duration `description:"Interval between two scans of the certificates of the storage. Default to 1h"`
+	MinInterval  parse.Duration `description:"Minimum interval between two notifications for the same certificate. Default to 24h"`
+	Webhook      *ExpiryWebhook `description:"Post the notifications in JSON to a webhook"`
+}
+
+// ExpiryWebhook posts the notifications in JSON to an URL
+type ExpiryWebhook struct {
+	URL     string         `description:"URL receiving the notifications"`
+	Secret  string         `description:"Secret signing the body of the notifications with HMAC-SHA256, in the X-Traefik-Signature header"`
+	Timeout parse.Duration `description:"Timeout of the webhook requests. Default to 10s"`
+}
+
+// ExpiryNotification is the notification of a certificate close to its expiry
+type ExpiryNotification struct {
+	Domain        string    `json:"domain"`
+	SANs          []string  `json:"sans,omitempty"`
+	NotAfter      time.Time `json:"notAfter"`
+	LastError     string    `json:"lastError,omitempty"`
+	RenewalPaused bool      `json:"renewalPaused,omitempty"`
+	External      bool      `json:"external,omitempty"`
+}
+
+// ExpiryNotifier is notified of the certificates close to their expiry which are not renewed
+type ExpiryNotifier interface {
+	Notify(notification *ExpiryNotification) error
+}
+
+// webhookNotifier posts the notifications to a webhook
+type webhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+func newWebhookNotifier(config *ExpiryWebhook) (*webhookNotifier, error) {
+	if len(config.URL) == 0 {
+		return nil, errors.New("the URL of the expiry notifications webhook is missing")
+	}
+
+	timeout := time.Duration(config.Timeout)
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+
+	return &webhookNotifier{
+		url:    config.URL,
+		secret: []byte(config.Secret),
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Notify posts the notification, signing its body when a secret is set
+func (n *webhookNotifier) Notify(notification *ExpiryNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		req.Header.Set(ExpiryWebhookSignatureHeader, "sha256="+signWebhookBody(n.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("the webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+func signWebhookBody(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// expiryWatcher scans the certificates of the store for the ones close to their expiry.
+// A certificate is notified at most once per interval, and again as soon as its renewal error changes.
+// A nil expiryWatcher notifies nothing.
+type expiryWatcher struct {
+	notifier     ExpiryNotifier
+	threshold    time.Duration
+	scanInterval time.Duration
+	minInterval  time.Duration
+
+	lock          sync.Mutex
+	renewalErrors map[string]string
+	notified      map[string]expiryNotified
+}
+
+type expiryNotified struct {
+	time      time.Time
+	lastError string
+}
+
+func newExpiryWatcher(config *ExpiryAlerts, notifier ExpiryNotifier) *expiryWatcher {
+	watcher := &expiryWatcher{
+		notifier:      notifier,
+		threshold:     defaultExpiryThreshold,
+		scanInterval:  defaultExpiryScanInterval,
+		minInterval:   defaultExpiryMinInterval,
+		renewalErrors: make(map[string]string),
+		notified:      make(map[string]expiryNotified),
+	}
+
+	if config != nil {
+		if config.Threshold > 0 {
+			watcher.threshold = time.Duration(config.Threshold)
+		}
+		if config.ScanInterval > 0 {
+			watcher.scanInterval = time.Duration(config.ScanInterval)
+		}
+		if config.MinInterval > 0 {
+			watcher.minInterval = time.Duration(config.MinInterval)
+		}
+	}
+
+	return watcher
+}
+
+// SetExpiryNotifier sets the notifier of the stored certificates close to their expiry which are not renewed,
+// in place of the webhook of the configuration
+func (p *Provider) SetExpiryNotifier(notifier ExpiryNotifier) {
+	if notifier == nil {
+		p.expiry = nil
+		return
+	}
+	p.expiry = newExpiryWatcher(p.ExpiryAlerts, notifier)
+}
+
+// watchExpiry scans the store for the certificates close to their expiry, once at the start then at each interval
+func (p *Provider) watchExpiry() {
+	if p.expiry == nil {
+		return
+	}
+
+	p.expiry.scan(p.getContext(), p.Store, time.Now())
+
+	ticker := time.NewTicker(p.expiry.scanInterval)
+	p.pool.Go(func(stop chan bool) {
+		for {
+			select {
+			case <-ticker.C:
+				p.expiry.scan(p.getContext(), p.Store, time.Now())
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	})
+}
+
+// renewalFailed records the last renewal error of the certificate of the domain
+func (w *expiryWatcher) renewalFailed(domain types.Domain, err error) {
+	if w == nil {
+		return
+	}
+
+	w.lock.Lock()
+	w.renewalErrors[normalizeDomain(domain.Main)] = err.Error()
+	w.lock.Unlock()
+}
+
+// renewed forgets the renewal error of the certificate of the domain
+func (w *expiryWatcher) renewed(domain types.Domain) {
+	if w == nil {
+		return
+	}
+
+	w.lock.Lock()
+	delete(w.renewalErrors, normalizeDomain(domain.Main))
+	w.lock.Unlock()
+}
+
+// scan notifies the certificates of the store expiring within the threshold whose renewal is due,
+// whether it failed or did not happen
+func (w *expiryWatcher) scan(ctx context.Context, store Store, now time.Time) {
+	if w == nil {
+		return
+	}
+
+	certificates, err := store.GetCertificates(ctx)
+	if err != nil {
+		logger().WithField(logFieldOperation, storeOperationLoad).Errorf("Unable to get the ACME certificates to check their expiry: %v", err)
+		return
+	}
+
+	seen := make(map[string]struct{})
+	for _, certificate := range certificates {
+		crt, err := parseCertificateLeaf(certificate.Certificate)
+		if err != nil {
+			domainsLogger(certificate.Domain.ToStrArray()).Debugf("Unable to parse the certificate of the domains %v to check its expiry: %v", certificate.Domain.ToStrArray(), err)
+			continue
+		}
+
+		if crt.NotAfter.Sub(now) > w.threshold || !isRenewalNeeded(certificate, crt, now) {
+			continue
+		}
+
+		notification := &ExpiryNotification{
+			Domain:        certificate.Domain.Main,
+			SANs:          certificate.Domain.SANs,
+			NotAfter:      crt.NotAfter,
+			RenewalPaused: certificate.RenewalPaused,
+			External:      certificate.External,
+		}
+		seen[getExpiryKey(notification)] = struct{}{}
+		w.notify(notification, now)
+	}
+
+	// Forget the renewed and the removed certificates
+	w.lock.Lock()
+	for key := range w.notified {
+		if _, ok := seen[key]; !ok {
+			delete(w.notified, key)
+		}
+	}
+	w.lock.Unlock()
+}
+
+// getExpiryKey is the deduplication key of the notifications, a renewed certificate has another expiry
+func getExpiryKey(notification *ExpiryNotification) string {
+	return normalizeDomain(notification.Domain) + "/" + notification.NotAfter.UTC().Format(time.RFC3339)
+}
+
+func (w *expiryWatcher) notify(notification *ExpiryNotification, now time.Time) {
+	logger := domainsLogger(append([]string{notification.Domain}, notification.SANs...))
+	key := getExpiryKey(notification)
+
+	w.lock.Lock()
+	notification.LastError = w.renewalErrors[normalizeDomain(notification.Domain)]
+	notified, ok := w.notified[key]
+	if ok && now.Sub(notified.time) < w.minInterval && notified.lastError == notification.LastError {
+		w.lock.Unlock()
+		logger.Debugf("Skip the expiry notification of the certificate for %s, sent less than %s ago.", notification.Domain, w.minInterval)
+		return
+	}
+	w.lock.Unlock()
+
+	logger.Warnf("The certificate for %s expires at %s and is not renewed, notifying.", notification.Domain, notification.NotAfter.Format(time.RFC3339))
+	if err := w.notifier.Notify(notification); err != nil {
+		logger.Errorf("Unable to notify the expiry of the certificate for %s: %v", notification.Domain, err)
+		return
+	}
+
+	w.lock.Lock()
+	w.notified[key] = expiryNotified{time: now, lastError: notification.LastError}
+	w.lock.Unlock()
+}
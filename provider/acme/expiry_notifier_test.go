@@ -0,0 +1,128 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collectingNotifier struct {
+	notifications []*ExpiryNotification
+	err           error
+}
+
+func (n *collectingNotifier) Notify(notification *ExpiryNotification) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func TestExpiryWatcherScan(t *testing.T) {
+	now := time.Now()
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	expiring := &Certificate{Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}, Certificate: generateTestCertificate(t, now.Add(5*24*time.Hour))}
+	valid := &Certificate{Domain: types.Domain{Main: "other.wtf"}, Certificate: generateTestCertificate(t, now.Add(60*24*time.Hour))}
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{expiring, valid}))
+
+	notifier := &collectingNotifier{}
+	watcher := newExpiryWatcher(&ExpiryAlerts{}, notifier)
+
+	watcher.scan(context.Background(), store, now)
+	require.Len(t, notifier.notifications, 1)
+	assert.Equal(t, "traefik.wtf", notifier.notifications[0].Domain)
+	assert.Equal(t, []string{"www.traefik.wtf"}, notifier.notifications[0].SANs)
+	assert.Empty(t, notifier.notifications[0].LastError)
+
+	// Not notified again within the interval
+	watcher.scan(context.Background(), store, now.Add(time.Hour))
+	assert.Len(t, notifier.notifications, 1)
+
+	// Notified again when the renewal error changes
+	watcher.renewalFailed(expiring.Domain, errors.New("rate limited"))
+	watcher.scan(context.Background(), store, now.Add(2*time.Hour))
+	require.Len(t, notifier.notifications, 2)
+	assert.Equal(t, "rate limited", notifier.notifications[1].LastError)
+
+	// Notified again after the interval
+	watcher.scan(context.Background(), store, now.Add(defaultExpiryMinInterval+3*time.Hour))
+	assert.Len(t, notifier.notifications, 3)
+}
+
+func TestExpiryWatcherNotifierFailure(t *testing.T) {
+	now := time.Now()
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	expiring := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, now.Add(24*time.Hour))}
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{expiring}))
+
+	notifier := &collectingNotifier{err: errors.New("unavailable")}
+	watcher := newExpiryWatcher(nil, notifier)
+
+	watcher.scan(context.Background(), store, now)
+	assert.Empty(t, notifier.notifications)
+
+	// The failed notification is retried at the next scan
+	notifier.err = nil
+	watcher.scan(context.Background(), store, now.Add(time.Hour))
+	assert.Len(t, notifier.notifications, 1)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		signature = req.Header.Get(ExpiryWebhookSignatureHeader)
+
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	notifier, err := newWebhookNotifier(&ExpiryWebhook{URL: server.URL, Secret: "secret"})
+	require.NoError(t, err)
+
+	notAfter := time.Date(2019, time.March, 15, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, notifier.Notify(&ExpiryNotification{Domain: "traefik.wtf", NotAfter: notAfter, LastError: "rate limited"}))
+
+	notification := &ExpiryNotification{}
+	require.NoError(t, json.Unmarshal(body, notification))
+	assert.Equal(t, "traefik.wtf", notification.Domain)
+	assert.Equal(t, notAfter, notification.NotAfter)
+	assert.Equal(t, "rate limited", notification.LastError)
+	assert.Equal(t, "sha256="+signWebhookBody([]byte("secret"), body), signature)
+}
+
+func TestWebhookNotifierFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier, err := newWebhookNotifier(&ExpiryWebhook{URL: server.URL})
+	require.NoError(t, err)
+
+	err = notifier.Notify(&ExpiryNotification{Domain: "traefik.wtf"})
+	assert.EqualError(t, err, "the webhook answered 500 Internal Server Error")
+
+	_, err = newWebhookNotifier(&ExpiryWebhook{})
+	assert.Error(t, err)
+}
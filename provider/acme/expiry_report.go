@@ -0,0 +1,103 @@
+package acme
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"sort"
+	"time"
+)
+
+// Renewal states of the expiring certificates
+const (
+	RenewalStatePaused    = "paused"
+	RenewalStateFailing   = "failing"
+	RenewalStateDue       = "due"
+	RenewalStateScheduled = "scheduled"
+	RenewalStateExternal  = "external"
+)
+
+// ExpiringCertificate is a stored certificate expiring within the window of a report, with its renewal state
+type ExpiringCertificate struct {
+	Domain   string    `json:"domain"`
+	SANs     []string  `json:"sans,omitempty"`
+	Resolver string    `json:"resolver"`
+	NotAfter time.Time `json:"notAfter"`
+	// RenewalState is paused, external when it is renewed outside of ACME, failing when its last renewal failed,
+	// due when its renewal window has started, scheduled otherwise
+	RenewalState string `json:"renewalState"`
+	// RenewalTime is the start of the renewal window of the certificate
+	RenewalTime time.Time `json:"renewalTime"`
+	LastError   string    `json:"lastError,omitempty"`
+	// Referenced is whether a domain of the certificate is referenced by the current configuration, nil when unknown
+	Referenced *bool `json:"referenced,omitempty"`
+}
+
+// IsRenewalBlocked returns whether the renewal of the certificate is paused or failing
+func (c *ExpiringCertificate) IsRenewalBlocked() bool {
+	return c.RenewalState == RenewalStatePaused || c.RenewalState == RenewalStateFailing
+}
+
+// ReadStoredCertificates returns the certificates of the storage file, which is not changed
+func ReadStoredCertificates(filename string) ([]*Certificate, error) {
+	// A missing storage file is not created
+	if _, err := os.Stat(filename); err != nil {
+		return nil, err
+	}
+
+	store := NewLocalStore(filename)
+	store.SetReadOnly(true)
+	defer store.Close(context.Background())
+
+	return store.GetCertificates(context.Background())
+}
+
+// GetExpiringCertificates returns the certificates expiring within the window, the first expiring first.
+// The certificates which can not be parsed are skipped.
+func GetExpiringCertificates(certificates []*Certificate, now time.Time, within time.Duration) []*ExpiringCertificate {
+	var expiring []*ExpiringCertificate
+	for _, certificate := range certificates {
+		crt, err := parseCertificateLeaf(certificate.Certificate)
+		if err != nil {
+			domainsLogger(certificate.Domain.ToStrArray()).Debugf("Unable to parse the certificate of the domains %v to check its expiry: %v", certificate.Domain.ToStrArray(), err)
+			continue
+		}
+
+		if crt.NotAfter.Sub(now) > within {
+			continue
+		}
+
+		expiringCertificate := &ExpiringCertificate{
+			Domain:       certificate.Domain.Main,
+			SANs:         certificate.Domain.SANs,
+			Resolver:     defaultResolverName,
+			NotAfter:     crt.NotAfter,
+			RenewalState: RenewalStateScheduled,
+			RenewalTime:  getRenewalTime(certificate, crt),
+		}
+		switch {
+		case certificate.RenewalPaused:
+			expiringCertificate.RenewalState = RenewalStatePaused
+		case certificate.External:
+			expiringCertificate.RenewalState = RenewalStateExternal
+		case isRenewalNeeded(certificate, crt, now):
+			expiringCertificate.RenewalState = RenewalStateDue
+		}
+		expiring = append(expiring, expiringCertificate)
+	}
+
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].NotAfter.Before(expiring[j].NotAfter)
+	})
+
+	return expiring
+}
+
+// getRenewalTime returns the time from which the certificate is renewed, as checked by isRenewalNeeded
+func getRenewalTime(certificate *Certificate, crt *x509.Certificate) time.Time {
+	if certificate.RenewalInfo != nil && !certificate.RenewalInfo.SuggestedWindowStart.IsZero() {
+		return certificate.RenewalInfo.SuggestedWindowStart
+	}
+
+	return crt.NotAfter.Add(-getScheduledRenewBefore(certificate))
+}
@@ -0,0 +1,61 @@
+package acme
+
+import (
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetExpiringCertificates(t *testing.T) {
+	now := time.Now()
+	certificates := []*Certificate{
+		{Domain: types.Domain{Main: "later.wtf"}, Certificate: generateTestCertificate(t, now.Add(90*24*time.Hour))},
+		{Domain: types.Domain{Main: "scheduled.wtf"}, Certificate: generateTestCertificate(t, now.Add(40*24*time.Hour))},
+		{Domain: types.Domain{Main: "due.wtf", SANs: []string{"www.due.wtf"}}, Certificate: generateTestCertificate(t, now.Add(10*24*time.Hour))},
+		{Domain: types.Domain{Main: "paused.wtf"}, Certificate: generateTestCertificate(t, now.Add(5*24*time.Hour)), RenewalPaused: true},
+		{Domain: types.Domain{Main: "external.wtf"}, Certificate: generateTestCertificate(t, now.Add(20*24*time.Hour)), External: true},
+		{Domain: types.Domain{Main: "invalid.wtf"}, Certificate: []byte("certificate")},
+	}
+
+	expiring := GetExpiringCertificates(certificates, now, 45*24*time.Hour)
+	require.Len(t, expiring, 4)
+
+	assert.Equal(t, "paused.wtf", expiring[0].Domain)
+	assert.Equal(t, RenewalStatePaused, expiring[0].RenewalState)
+	assert.True(t, expiring[0].IsRenewalBlocked())
+
+	assert.Equal(t, "due.wtf", expiring[1].Domain)
+	assert.Equal(t, []string{"www.due.wtf"}, expiring[1].SANs)
+	assert.Equal(t, RenewalStateDue, expiring[1].RenewalState)
+	assert.False(t, expiring[1].IsRenewalBlocked())
+
+	// The external certificates are renewed outside of ACME
+	assert.Equal(t, "external.wtf", expiring[2].Domain)
+	assert.Equal(t, RenewalStateExternal, expiring[2].RenewalState)
+	assert.False(t, expiring[2].IsRenewalBlocked())
+
+	assert.Equal(t, "scheduled.wtf", expiring[3].Domain)
+	assert.Equal(t, RenewalStateScheduled, expiring[3].RenewalState)
+	assert.Equal(t, defaultResolverName, expiring[3].Resolver)
+	assert.Equal(t, expiring[3].NotAfter.Add(-defaultRenewBefore), expiring[3].RenewalTime)
+	assert.Nil(t, expiring[3].Referenced)
+}
+
+func TestGetExpiringCertificatesRenewalInfo(t *testing.T) {
+	now := time.Now()
+	windowStart := now.Add(20 * 24 * time.Hour)
+	certificates := []*Certificate{{
+		Domain:      types.Domain{Main: "traefik.wtf"},
+		Certificate: generateTestCertificate(t, now.Add(25*24*time.Hour)),
+		RenewalInfo: &RenewalInfo{SuggestedWindowStart: windowStart, SuggestedWindowEnd: windowStart.Add(24 * time.Hour)},
+	}}
+
+	// The renewal window suggested by the CA takes precedence over renewBefore
+	expiring := GetExpiringCertificates(certificates, now, 30*24*time.Hour)
+	require.Len(t, expiring, 1)
+	assert.Equal(t, RenewalStateScheduled, expiring[0].RenewalState)
+	assert.Equal(t, windowStart, expiring[0].RenewalTime)
+}
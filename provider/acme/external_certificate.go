@@ -0,0 +1,201 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/types"
+)
+
+// ErrCertificateExists is returned when an external certificate is injected for a domain which already has a certificate, without forcing it
+var ErrCertificateExists = errors.New("a certificate already exists for the domain, force the injection to replace it")
+
+// InvalidCertificateError is returned when an external certificate or its private key can not be used
+type InvalidCertificateError struct {
+	Reason string
+}
+
+func (e *InvalidCertificateError) Error() string {
+	return "invalid external certificate: " + e.Reason
+}
+
+// certificateInjection is a request to add an external certificate, in memory and in the store
+type certificateInjection struct {
+	certificate *Certificate
+	force       bool
+	done        chan certificateUpdateResult
+}
+
+// InjectCertificate stores and serves an externally obtained certificate, with its PEM encoded chain and private key, and returns it.
+// The domains of the certificate are the ones of its leaf. The certificate is flagged as external: it is never renewed.
+// A certificate of the same main domain is replaced only when forced, ErrCertificateExists is returned otherwise.
+func (p *Provider) InjectCertificate(ctx context.Context, certificate, chain, key []byte, force bool) (*CertificateInfo, error) {
+	if p.isPassive() {
+		return nil, ErrReadOnly
+	}
+
+	cert, crt, err := newExternalCertificate(certificate, chain, key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	cert.RenewBefore = p.getCertificateRenewBefore(cert.Domain, crt)
+	cert.Metadata = p.getDomainMetadata(cert.Domain)
+	p.recordSCTs(cert, crt)
+
+	result := p.injectCertificate(ctx, cert, force)
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	logger := domainsLogger(cert.Domain.ToStrArray())
+	if result.updated {
+		logger.Infof("The certificate for domains %v is replaced by an external certificate.", cert.Domain.ToStrArray())
+	} else {
+		logger.Infof("The external certificate for domains %v is added.", cert.Domain.ToStrArray())
+	}
+	setCertificateExpiry(p.metricsRegistry, result.certificate, false)
+	p.runDeployHooks(result.certificate)
+
+	return p.getCertificateInfo(result.certificate), nil
+}
+
+// injectCertificate adds the certificate, the updated flag of the result telling whether it replaced another one
+func (p *Provider) injectCertificate(ctx context.Context, certificate *Certificate, force bool) certificateUpdateResult {
+	if p.certificateInjections == nil {
+		// Before the start of the provider, no routine owns the certificates in memory
+		return p.applyCertificateInjection(certificate, force)
+	}
+
+	// The certificates in memory are updated in the routine watching the certificates, which owns them
+	done := make(chan certificateUpdateResult, 1)
+	select {
+	case p.certificateInjections <- &certificateInjection{certificate: certificate, force: force, done: done}:
+	case <-ctx.Done():
+		return certificateUpdateResult{err: ctx.Err()}
+	}
+	return <-done
+}
+
+// applyCertificateInjection stores the certificate, replacing the one of the same main domain, and serves it
+func (p *Provider) applyCertificateInjection(certificate *Certificate, force bool) certificateUpdateResult {
+	var existing *Certificate
+	certificates := make([]*Certificate, 0, len(p.certificates)+1)
+	for _, cert := range p.certificates {
+		if normalizeDomain(cert.Domain.Main) != normalizeDomain(certificate.Domain.Main) {
+			certificates = append(certificates, cert)
+			continue
+		}
+
+		if !force {
+			return certificateUpdateResult{err: ErrCertificateExists}
+		}
+		existing = cert
+		// The metadata set with the API is carried to the replacing certificate
+		certificate.Metadata = mergeMetadata(cert.Metadata, certificate.Metadata)
+		certificates = append(certificates, certificate)
+	}
+	if existing == nil {
+		certificates = append(certificates, certificate)
+	}
+
+	err := p.tracing.traceStore(p.Store, storeOperationSave, "", func() error {
+		return p.Store.SaveCertificates(p.getContext(), certificates)
+	})
+	if err != nil {
+		return certificateUpdateResult{err: err}
+	}
+
+	// The entry in memory is a copy, the store may keep the saved one
+	injected := copyCertificate(certificate)
+	if existing != nil {
+		p.certificateIndex.remove(existing)
+		*existing = *injected
+		p.certificateIndex.add(existing)
+	} else {
+		p.certificates = append(p.certificates, injected)
+		p.certificateIndex.add(injected)
+	}
+	p.refreshCertificates()
+
+	return certificateUpdateResult{certificate: copyCertificate(certificate), updated: existing != nil}
+}
+
+// newExternalCertificate returns the external certificate of the PEM encoded leaf, chain and private key, with its parsed leaf.
+// The private key must match the leaf, which must not be expired. The domains are the DNS names of the leaf, its common name first.
+func newExternalCertificate(certificate, chain, key []byte, now time.Time) (*Certificate, *x509.Certificate, error) {
+	// The certificate is stored with its chain, as the obtained ones
+	bundle := bytes.Join([][]byte{bytes.TrimSpace(certificate), bytes.TrimSpace(chain)}, []byte("\n"))
+	bundle = append(bytes.TrimSpace(bundle), '\n')
+
+	pair, err := tls.X509KeyPair(bundle, key)
+	if err != nil {
+		return nil, nil, &InvalidCertificateError{Reason: err.Error()}
+	}
+
+	var leaf *x509.Certificate
+	for _, der := range pair.Certificate {
+		crt, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, nil, &InvalidCertificateError{Reason: err.Error()}
+		}
+		if leaf == nil {
+			leaf = crt
+		}
+	}
+
+	if now.After(leaf.NotAfter) {
+		return nil, nil, &InvalidCertificateError{Reason: fmt.Sprintf("the certificate expired on %s", leaf.NotAfter.UTC().Format(time.RFC3339))}
+	}
+
+	domain, err := getExternalCertificateDomain(leaf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert := &Certificate{
+		Domain:      domain,
+		Certificate: bundle,
+		Key:         key,
+		External:    true,
+	}
+	if keyType, err := getCertificateKeyType(cert); err == nil {
+		cert.KeyType = keyType
+	}
+
+	return cert, leaf, nil
+}
+
+// getExternalCertificateDomain returns the domains of the leaf, its DNS names, the common name being the main domain when it is one of them
+func getExternalCertificateDomain(leaf *x509.Certificate) (types.Domain, error) {
+	commonName := strings.ToLower(leaf.Subject.CommonName)
+
+	var domain types.Domain
+	for _, name := range leaf.DNSNames {
+		name = strings.ToLower(name)
+		if name == commonName && len(domain.Main) == 0 {
+			domain.Main = name
+			continue
+		}
+		domain.SANs = append(domain.SANs, name)
+	}
+	if len(leaf.DNSNames) == 0 {
+		domain.Main = commonName
+	}
+	if len(domain.Main) == 0 && len(domain.SANs) > 0 {
+		domain.Main, domain.SANs = domain.SANs[0], domain.SANs[1:]
+	}
+
+	if len(domain.Main) == 0 {
+		return types.Domain{}, &InvalidCertificateError{Reason: "the certificate has no DNS name"}
+	}
+	if len(domain.SANs) == 0 {
+		domain.SANs = nil
+	}
+	return domain, nil
+}
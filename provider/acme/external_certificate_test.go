@@ -0,0 +1,198 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateTestExternalCertificate returns a PEM encoded self-signed certificate of the names, with its private key
+func generateTestExternalCertificate(t *testing.T, commonName string, dnsNames []string, notAfter time.Time) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestNewExternalCertificate(t *testing.T) {
+	now := time.Now()
+	certificate, key := generateTestExternalCertificate(t, "www.traefik.wtf", []string{"traefik.wtf", "WWW.traefik.wtf"}, now.Add(365*24*time.Hour))
+	chain, _ := generateTestExternalCertificate(t, "Traefik CA", nil, now.Add(365*24*time.Hour))
+
+	cert, crt, err := newExternalCertificate(certificate, chain, key, now)
+	require.NoError(t, err)
+	assert.Equal(t, types.Domain{Main: "www.traefik.wtf", SANs: []string{"traefik.wtf"}}, cert.Domain)
+	assert.True(t, cert.External)
+	assert.Equal(t, "www.traefik.wtf", crt.Subject.CommonName)
+
+	// The certificate is stored with its chain
+	leaf, err := parseCertificateLeaf(cert.Certificate)
+	require.NoError(t, err)
+	assert.Equal(t, crt, leaf)
+	block, rest := pem.Decode(cert.Certificate)
+	require.NotNil(t, block)
+	block, _ = pem.Decode(rest)
+	require.NotNil(t, block)
+
+	_, otherKey := generateTestExternalCertificate(t, "traefik.wtf", nil, now.Add(365*24*time.Hour))
+	_, _, err = newExternalCertificate(certificate, chain, otherKey, now)
+	assert.IsType(t, &InvalidCertificateError{}, err)
+
+	expired, expiredKey := generateTestExternalCertificate(t, "traefik.wtf", nil, now.Add(-time.Hour))
+	_, _, err = newExternalCertificate(expired, nil, expiredKey, now)
+	assert.IsType(t, &InvalidCertificateError{}, err)
+
+	_, _, err = newExternalCertificate([]byte("certificate"), nil, key, now)
+	assert.IsType(t, &InvalidCertificateError{}, err)
+}
+
+func TestGetExternalCertificateDomain(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		commonName string
+		dnsNames   []string
+		expected   types.Domain
+		invalid    bool
+	}{
+		{
+			desc:       "common name only",
+			commonName: "traefik.wtf",
+			expected:   types.Domain{Main: "traefik.wtf"},
+		},
+		{
+			desc:       "common name not in the DNS names",
+			commonName: "Traefik Labs",
+			dnsNames:   []string{"traefik.wtf", "*.traefik.wtf"},
+			expected:   types.Domain{Main: "traefik.wtf", SANs: []string{"*.traefik.wtf"}},
+		},
+		{
+			desc:     "no common name",
+			dnsNames: []string{"traefik.wtf"},
+			expected: types.Domain{Main: "traefik.wtf"},
+		},
+		{
+			desc:    "no name",
+			invalid: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			domain, err := getExternalCertificateDomain(&x509.Certificate{Subject: pkix.Name{CommonName: test.commonName}, DNSNames: test.dnsNames})
+			if test.invalid {
+				assert.IsType(t, &InvalidCertificateError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, domain)
+		})
+	}
+}
+
+func TestProviderInjectCertificate(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	acmeCertificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, time.Now().Add(24*time.Hour)), Key: []byte("key"), Metadata: map[string]string{"team": "edge"}}
+	configurationChan := make(chan types.ConfigMessage, 1)
+	p := &Provider{
+		Configuration:     &Configuration{},
+		Store:             store,
+		certificates:      []*Certificate{acmeCertificate},
+		certificateIndex:  newCertificateIndex([]*Certificate{acmeCertificate}),
+		configurationChan: configurationChan,
+	}
+
+	certificate, key := generateTestExternalCertificate(t, "traefik.wtf", []string{"traefik.wtf", "www.traefik.wtf"}, time.Now().Add(365*24*time.Hour))
+
+	// The ACME certificate of the main domain is replaced only when forced
+	_, err := p.InjectCertificate(context.Background(), certificate, nil, key, false)
+	assert.Equal(t, ErrCertificateExists, err)
+	assert.False(t, acmeCertificate.External)
+	assert.Len(t, configurationChan, 0)
+
+	info, err := p.InjectCertificate(context.Background(), certificate, nil, key, true)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.True(t, info.External)
+	assert.Equal(t, []string{"www.traefik.wtf"}, info.SANs)
+	assert.Equal(t, map[string]string{"team": "edge"}, info.Metadata)
+
+	require.Len(t, p.certificates, 1)
+	assert.True(t, p.certificates[0].External)
+	assert.Equal(t, key, p.certificates[0].Key)
+
+	// The external certificate is served at once, and stored
+	config := <-configurationChan
+	assert.Len(t, config.Configuration.TLS, 1)
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1 && storedData.Certificates[0].External
+	})
+
+	other, otherKey := generateTestExternalCertificate(t, "other.wtf", nil, time.Now().Add(365*24*time.Hour))
+	info, err = p.InjectCertificate(context.Background(), other, nil, otherKey, false)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, "other.wtf", info.Domain)
+	assert.Len(t, p.certificates, 2)
+	<-configurationChan
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 2
+	})
+}
+
+func TestProviderInjectCertificatePassive(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	p := &Provider{Configuration: &Configuration{}, Store: store}
+	p.SetReadOnly(true)
+
+	certificate, key := generateTestExternalCertificate(t, "traefik.wtf", nil, time.Now().Add(365*24*time.Hour))
+	_, err := p.InjectCertificate(context.Background(), certificate, nil, key, false)
+	assert.Equal(t, ErrReadOnly, err)
+}
+
+func TestProviderRenewCertificatesExternal(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, time.Now().Add(24*time.Hour)), Key: []byte("key"), External: true}
+	p := &Provider{
+		Configuration: &Configuration{},
+		Store:         store,
+		certificates:  []*Certificate{certificate},
+	}
+	p.renewalInfoOnce.Do(func() {})
+
+	// The external certificate close to its expiry is not renewed, no ACME client is created
+	p.renewCertificates()
+	assert.Nil(t, p.renewals.get(certificate.Domain))
+}
@@ -0,0 +1,148 @@
+package acme
+
+import (
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+	kitmetrics "github.com/go-kit/kit/metrics"
+)
+
+const (
+	issuancePhaseOrder     = "order"
+	issuancePhaseChallenge = "challenge"
+	issuancePhaseStorage   = "storage"
+	issuancePhaseTotal     = "total"
+)
+
+// issuanceTimings measures the phases of the issuances and renewals of certificates, from the decision to (re)issue
+// to the persistence of the certificate. A nil issuanceTimings measures nothing.
+type issuanceTimings struct {
+	registry metrics.Registry
+
+	lock      sync.Mutex
+	issuances map[string]*issuanceTiming
+}
+
+// issuanceTiming is the timing of the issuance of the certificate of a set of domains
+type issuanceTiming struct {
+	challengeType  string
+	start          time.Time
+	phases         map[string]time.Duration
+	challengeStart time.Time
+	challengeEnd   time.Time
+}
+
+func newIssuanceTimings(registry metrics.Registry) *issuanceTimings {
+	if registry == nil || !registry.IsEnabled() {
+		return nil
+	}
+	return &issuanceTimings{registry: registry, issuances: make(map[string]*issuanceTiming)}
+}
+
+// start starts the timing of the issuance of the certificate of the domains
+func (t *issuanceTimings) start(domains []string, challengeType string) *issuanceTiming {
+	if t == nil {
+		return nil
+	}
+
+	timing := &issuanceTiming{challengeType: challengeType, start: time.Now(), phases: make(map[string]time.Duration)}
+
+	t.lock.Lock()
+	for _, domain := range domains {
+		t.issuances[normalizeDomain(domain)] = timing
+	}
+	t.lock.Unlock()
+
+	return timing
+}
+
+// phase records the duration of a phase of the issuance, started at start
+func (t *issuanceTimings) phase(timing *issuanceTiming, phase string, start time.Time) {
+	if t == nil || timing == nil {
+		return
+	}
+
+	t.lock.Lock()
+	timing.phases[phase] += time.Since(start)
+	t.lock.Unlock()
+}
+
+// challengePresented records the presentation of a challenge for the domain, the challenge phase of its issuance
+// lasts from the first presented challenge to the last cleaned up one
+func (t *issuanceTimings) challengePresented(domain string) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if timing, ok := t.issuances[normalizeDomain(domain)]; ok && timing.challengeStart.IsZero() {
+		timing.challengeStart = time.Now()
+	}
+}
+
+// challengeCleanedUp records the cleanup of the challenge of the domain, once it has been validated or has failed
+func (t *issuanceTimings) challengeCleanedUp(domain string) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if timing, ok := t.issuances[normalizeDomain(domain)]; ok && !timing.challengeStart.IsZero() {
+		timing.challengeEnd = time.Now()
+	}
+}
+
+// failed records the phases of the failed issuance of the certificate of the domains
+func (t *issuanceTimings) failed(domains []string, timing *issuanceTiming) {
+	if t == nil || timing == nil {
+		return
+	}
+
+	t.end(domains, timing, t.registry.ACMEIssuanceFailureHistogram())
+}
+
+// persisted records the phases of the issuance of the certificate of the domains, once the storage saved at
+// storageStart has ended. An issuance whose certificate failed to be persisted is recorded as failed.
+func (t *issuanceTimings) persisted(domains []string, storageStart time.Time, err error) {
+	if t == nil || len(domains) == 0 {
+		return
+	}
+
+	t.lock.Lock()
+	timing := t.issuances[normalizeDomain(domains[0])]
+	t.lock.Unlock()
+	if timing == nil {
+		return
+	}
+
+	t.phase(timing, issuancePhaseStorage, storageStart)
+	if err != nil {
+		t.end(domains, timing, t.registry.ACMEIssuanceFailureHistogram())
+		return
+	}
+	t.end(domains, timing, t.registry.ACMEIssuanceDurationHistogram())
+}
+
+func (t *issuanceTimings) end(domains []string, timing *issuanceTiming, histogram kitmetrics.Histogram) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, domain := range domains {
+		if t.issuances[normalizeDomain(domain)] == timing {
+			delete(t.issuances, normalizeDomain(domain))
+		}
+	}
+
+	if !timing.challengeStart.IsZero() && timing.challengeEnd.After(timing.challengeStart) {
+		timing.phases[issuancePhaseChallenge] = timing.challengeEnd.Sub(timing.challengeStart)
+	}
+	for phase, duration := range timing.phases {
+		histogram.With("type", timing.challengeType, "phase", phase).Observe(duration.Seconds())
+	}
+	histogram.With("type", timing.challengeType, "phase", issuancePhaseTotal).Observe(time.Since(timing.start).Seconds())
+}
@@ -0,0 +1,71 @@
+package acme
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuanceTimings(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+	timings := newIssuanceTimings(registry)
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	challenge := &challengeHTTP{Store: store, timings: timings}
+
+	domains := []string{"traefik.wtf", "www.traefik.wtf"}
+	timing := timings.start(domains, challengeTypeHTTP01)
+
+	orderStart := time.Now()
+	require.NoError(t, challenge.Present("www.traefik.wtf", "token", "keyAuth"))
+	require.NoError(t, challenge.CleanUp("www.traefik.wtf", "token", "keyAuth"))
+	timings.phase(timing, issuancePhaseOrder, orderStart)
+
+	timings.persisted(domains, time.Now(), nil)
+
+	// order, challenge, storage and total
+	assert.Equal(t, 4, registry.issuances.ObservationsCount)
+	assert.Equal(t, []string{"type", challengeTypeHTTP01, "phase", issuancePhaseTotal}, registry.issuances.LastLabelValues)
+	assert.Equal(t, 0, registry.notIssued.ObservationsCount)
+
+	// The issuance is recorded once
+	timings.persisted(domains, time.Now(), nil)
+	assert.Equal(t, 4, registry.issuances.ObservationsCount)
+}
+
+func TestIssuanceTimingsFailure(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+	timings := newIssuanceTimings(registry)
+
+	domains := []string{"traefik.wtf"}
+	timing := timings.start(domains, challengeTypeDNS01)
+	timings.phase(timing, issuancePhaseOrder, time.Now())
+	timings.failed(domains, timing)
+
+	assert.Equal(t, 2, registry.notIssued.ObservationsCount)
+	assert.Equal(t, []string{"type", challengeTypeDNS01, "phase", issuancePhaseTotal}, registry.notIssued.LastLabelValues)
+
+	timing = timings.start(domains, challengeTypeDNS01)
+	timings.persisted(domains, time.Now(), errors.New("storage failed"))
+
+	assert.Equal(t, 4, registry.notIssued.ObservationsCount)
+	assert.Equal(t, 0, registry.issuances.ObservationsCount)
+}
+
+func TestIssuanceTimingsDisabled(t *testing.T) {
+	timings := newIssuanceTimings(metrics.NewVoidRegistry())
+	assert.Nil(t, timings)
+
+	timing := timings.start([]string{"traefik.wtf"}, challengeTypeHTTP01)
+	assert.Nil(t, timing)
+	timings.challengePresented("traefik.wtf")
+	timings.phase(timing, issuancePhaseOrder, time.Now())
+	timings.failed([]string{"traefik.wtf"}, timing)
+	timings.persisted([]string{"traefik.wtf"}, time.Now(), nil)
+}
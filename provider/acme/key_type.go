@@ -0,0 +1,76 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/containous/traefik/types"
+	"github.com/xenolf/lego/acme"
+)
+
+// DomainKeyType holds the KeyType used for the certificates of a domain
+type DomainKeyType struct {
+	Domain  string `description:"Domain (or wildcard domain) using the key type"`
+	KeyType string `description:"KeyType used for generating certificate private key. Allow value 'EC256', 'EC384', 'RSA2048', 'RSA4096', 'RSA8192'"`
+}
+
+// getKeyType returns the KeyType to use for the certificate of the given domain
+// An exact domain override takes precedence over a wildcard one
+func (p *Provider) getKeyType(domain types.Domain) acme.KeyType {
+	main := types.CanonicalDomain(domain.Main)
+
+	for _, override := range p.DomainsKeyType {
+		if types.CanonicalDomain(override.Domain) == main {
+			return GetKeyType(override.KeyType)
+		}
+	}
+
+	for _, override := range p.DomainsKeyType {
+		if types.MatchDomain(main, types.CanonicalDomain(override.Domain)) {
+			return GetKeyType(override.KeyType)
+		}
+	}
+
+	if p.account != nil && len(p.account.KeyType) > 0 {
+		return p.account.KeyType
+	}
+
+	return GetKeyType(p.KeyType)
+}
+
+// getCertificateKeyType returns the KeyType of a stored certificate, inferring it from the private key for legacy entries
+func getCertificateKeyType(certificate *Certificate) (acme.KeyType, error) {
+	if len(certificate.KeyType) > 0 {
+		return certificate.KeyType, nil
+	}
+
+	block, _ := pem.Decode(certificate.Key)
+	if block == nil {
+		return "", fmt.Errorf("unable to decode the private key of the certificate for domain %q", certificate.Domain.Main)
+	}
+
+	return inferPrivateKeyType(block.Bytes)
+}
+
+// generateCertificatePrivateKey generates a certificate private key matching the given KeyType
+func generateCertificatePrivateKey(keyType acme.KeyType) (crypto.PrivateKey, error) {
+	switch keyType {
+	case acme.EC256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case acme.EC384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case acme.RSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case acme.RSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case acme.RSA8192:
+		return rsa.GenerateKey(rand.Reader, 8192)
+	default:
+		return nil, fmt.Errorf("invalid KeyType: %s", keyType)
+	}
+}
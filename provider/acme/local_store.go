@@ -1,251 +1,1599 @@
 package acme
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/metrics"
 	"github.com/containous/traefik/safe"
+	"golang.org/x/crypto/ed25519"
 )
 
 var _ Store = (*LocalStore)(nil)
 
+const (
+	// DefaultSaveQuietPeriod is the default duration without any save after which the coalesced saves are written
+	DefaultSaveQuietPeriod = 500 * time.Millisecond
+
+	// defaultMaxSaveDelay bounds the delay of the coalesced saves, when the saves keep arriving
+	defaultMaxSaveDelay = 5 * time.Second
+	maxSaveDelayLimit   = 1 * time.Minute
+)
+
+// storageBuffers are the buffers encoding the storage, reused from a save to the next one
+var storageBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
 // LocalStore Store implementation for local file
 type LocalStore struct {
-	filename     string
-	storedData   *StoredData
-	SaveDataChan chan *StoredData `json:"-"`
-	lock         sync.RWMutex
+	filename                   string
+	storedData                 *StoredData
+	SaveDataChan               chan *StoredData   `json:"-"`
+	EphemeralChallenges        bool               `json:"-"`
+	HTTPChallengeTokenValidity time.Duration      `json:"-"`
+	SaveQuietPeriod            time.Duration      `json:"-"`
+	MaxSaveDelay               time.Duration      `json:"-"`
+	Encryption                 *StorageEncryption `json:"-"`
+	Signing                    *StorageSigning    `json:"-"`
+	ReadOnlyFallback           bool               `json:"-"`
+	AuditLog                   string             `json:"-"`
+	CertificateSecrets         *TLSSecrets        `json:"-"`
+	Revisions                  int                `json:"-"`
+	MissingPolicy              string             `json:"-"`
+	CircuitBreaker             *StorageBreaker    `json:"-"`
+	LocalCacheFile             string             `json:"-"`
+	lock                       sync.RWMutex
+	loadLock                   sync.Mutex
+
+	storageKeyLock sync.Mutex
+	storageKey     *storageKey
+	kmsProviders   map[string]KMSProvider
+	signingKey     ed25519.PrivateKey
+
+	metricsLock       sync.Mutex
+	metricsRegistry   metrics.Registry
+	signatureFailures map[string]int
+
+	// canary is the result of the canary check of the storage, reported to the metrics registry once set
+	canaryLock sync.RWMutex
+	canary     *StorageCanaryResult
+
+	auditOnce sync.Once
+	audit     *storeAudit
+
+	readOnly int32
+
+	secretsLock                sync.Mutex
+	secretsClient              secretsClient
+	certificateResourcesClient certificateResourcesClient
+	certManagerClient          certManagerClient
+	replicaClients             map[string]secretsClient
+	replicators                []*secretsReplicator
+	certificatesInSecrets      int32
+
+	health  storeHealthTracker
+	hash    contentHash
+	payload payloadHash
+	written writtenSnapshot
+	breaker storeBreaker
+
+	// locker locks the keys among the stores of the storage file, set on the first lock
+	lockerOnce sync.Once
+	locker     *leaseLocker
+
+	// cacheLock serializes the reads and the writes of the local cache, servingCache is set while its data is served
+	cacheLock    sync.Mutex
+	servingCache int32
+
+	// flushes ends the coalescing of the saves, for the saves which can not wait
+	flushes chan struct{}
+
+	// persists receives the waits for the write of the saves, lastWrite is the result of the last write
+	persists  chan chan error
+	lastWrite error
+
+	// closing stops the save loop, which closes closed once the last save is written
+	closeOnce sync.Once
+	closing   chan struct{}
+	closed    chan struct{}
+
+	// watchStop stops the watch of the storage file, which runs while there are subscribers
+	subscriptions storeSubscriptions
+	watchLock     sync.Mutex
+	watchStop     chan struct{}
+
+	// writeFile writes the storage file, ioutil.WriteFile when nil: the tests replace it before the saves
+	writeFile func(filename string, data []byte, perm os.FileMode) error
+}
+
+// writeStorageFile writes the storage file with the writer of the store
+func (s *LocalStore) writeStorageFile(filename string, data []byte, perm os.FileMode) error {
+	if s.writeFile != nil {
+		return s.writeFile(filename, data, perm)
+	}
+	return ioutil.WriteFile(filename, data, perm)
 }
 
 // NewLocalStore initializes a new LocalStore with a file name
 func NewLocalStore(filename string) *LocalStore {
-	store := &LocalStore{filename: filename, SaveDataChan: make(chan *StoredData)}
+	store := &LocalStore{
+		filename:     filename,
+		SaveDataChan: make(chan *StoredData),
+		flushes:      make(chan struct{}, 1),
+		persists:     make(chan chan error),
+		closing:      make(chan struct{}),
+		closed:       make(chan struct{}),
+	}
 	store.listenSaveAction()
 	return store
 }
 
-func (s *LocalStore) get() (*StoredData, error) {
+// get returns the data of the storage, loaded on the first call unless the context is done
+func (s *LocalStore) get(ctx context.Context) (*StoredData, error) {
+	s.loadLock.Lock()
+	defer s.loadLock.Unlock()
+
+	// The reads of the storage cannot be interrupted, they are not started with a done context
+	if s.storedData == nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return s.load()
+}
+
+func (s *LocalStore) load() (*StoredData, error) {
 	if s.storedData == nil {
-		s.storedData = &StoredData{
-			HTTPChallenges: make(map[string]map[string][]byte),
-			TLSChallenges:  make(map[string]*Certificate),
+		storedData, err := s.loadStorage()
+		if err != nil {
+			return nil, err
+		}
+		s.storedData = storedData
+	}
+
+	return s.storedData, nil
+}
+
+// loadStorage reads the storage into new data, leaving the data of the store unchanged.
+// The changes made to the data while it is loaded are saved.
+func (s *LocalStore) loadStorage() (data *StoredData, err error) {
+	defer func() { s.health.loaded(err) }()
+
+	storedData := &StoredData{
+		HTTPChallenges:          make(map[string]map[string][]byte),
+		HTTPChallengesCreatedAt: make(map[string]map[string]time.Time),
+		TLSChallenges:           make(map[string]*Certificate),
+		TLSChallengesCreatedAt:  make(map[string]time.Time),
+	}
+	s.hash.set(nil)
+	s.payload.reset()
+
+	exists, err := s.checkMissingStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	// A read-only store never creates the storage
+	if !exists && s.IsReadOnly() {
+		if s.CertificateSecrets != nil {
+			if err := s.loadCertificateSecrets(storedData); err != nil {
+				return nil, err
+			}
 		}
+		return storedData, nil
+	}
+
+	// A storage which can not be read is never loaded as an empty one, it is read again on the next load
+	hasData, err := CheckFile(s.filename)
+	if err != nil {
+		return nil, err
+	}
 
-		hasData, err := CheckFile(s.filename)
+	if hasData {
+		// The file is read in a buffer of its size
+		file, err := ioutil.ReadFile(s.filename)
 		if err != nil {
 			return nil, err
 		}
+		s.health.setPayloadSize(len(file))
+		s.hash.set(file)
 
-		if hasData {
-			f, err := os.Open(s.filename)
+		signatureFailure := ""
+		if len(file) > 0 && s.Signing != nil {
+			signatureFailure, err = s.verifySignature(file)
 			if err != nil {
 				return nil, err
 			}
-			defer f.Close()
 
-			file, err := ioutil.ReadAll(f)
+			if len(signatureFailure) > 0 && !s.Signing.AllowInvalidSignature {
+				s.logger(storeOperationLoad).Errorf("The signature of the ACME storage %s is %s, its account and certificates are not loaded. The storage is moved to %s.", s.filename, signatureFailure, s.filename+".rejected")
+				if s.IsReadOnly() {
+					s.logger(storeOperationLoad).Warn("The ACME storage is read-only, the rejected storage is not kept.")
+				} else if err := ioutil.WriteFile(s.filename+".rejected", file, 0600); err != nil {
+					s.logger(storeOperationLoad).Errorf("Unable to keep the rejected ACME storage: %v", err)
+				}
+				file = nil
+			}
+		}
+
+		if len(file) > 0 {
+			if err := s.unmarshalStoredData(file, storedData); err != nil {
+				return nil, err
+			}
+			s.getAudit().snapshot(storedData)
+			s.written.set(newStoredDataSnapshot(storedData))
+
+			if len(signatureFailure) > 0 {
+				s.logger(storeOperationLoad).Warnf("The signature of the ACME storage %s is %s, the storage is loaded anyway and signed again.", s.filename, signatureFailure)
+				s.save(storedData)
+			}
+		}
+
+		// Check if ACME Account is in ACME V1 format
+		if storedData.Account != nil && storedData.Account.Registration != nil {
+			isOldRegistration, err := regexp.MatchString(RegistrationURLPathV1Regexp, storedData.Account.Registration.URI)
 			if err != nil {
 				return nil, err
 			}
+			if isOldRegistration {
+				s.logger(storeOperationLoad).Debug("Reset ACME account.")
+				storedData.Account = nil
+				s.save(storedData)
+			}
+		}
 
-			if len(file) > 0 {
-				if err := json.Unmarshal(file, s.storedData); err != nil {
-					return nil, err
-				}
+		// Infer the private key type of accounts stored before it was recorded, an unknown type being left empty
+		if storedData.Account != nil && len(storedData.Account.PrivateKeyType) == 0 && len(storedData.Account.PrivateKey) > 0 {
+			privateKeyType, err := inferPrivateKeyType(storedData.Account.PrivateKey)
+			if err != nil {
+				s.logger(storeOperationLoad).Warnf("The ACME account private key type is left empty: %v", err)
+			} else {
+				s.logger(storeOperationLoad).Debugf("Set ACME account private key type to %s.", privateKeyType)
+				storedData.Account.PrivateKeyType = privateKeyType
+				s.save(storedData)
 			}
+		}
 
-			// Check if ACME Account is in ACME V1 format
-			if s.storedData.Account != nil && s.storedData.Account.Registration != nil {
-				isOldRegistration, err := regexp.MatchString(RegistrationURLPathV1Regexp, s.storedData.Account.Registration.URI)
-				if err != nil {
-					return nil, err
-				}
-				if isOldRegistration {
-					log.Debug("Reset ACME account.")
-					s.storedData.Account = nil
-					s.SaveDataChan <- s.storedData
+		// Drop the challenges persisted before they were kept in memory only
+		if s.EphemeralChallenges && (len(storedData.HTTPChallenges) > 0 || len(storedData.TLSChallenges) > 0) {
+			s.logger(storeOperationLoad).Debug("Delete the persisted HTTP and TLS challenges.")
+			storedData.HTTPChallenges = make(map[string]map[string][]byte)
+			storedData.HTTPChallengesCreatedAt = make(map[string]map[string]time.Time)
+			storedData.TLSChallenges = make(map[string]*Certificate)
+			storedData.TLSChallengesCreatedAt = make(map[string]time.Time)
+			s.save(storedData)
+		}
+
+		// Consider the HTTP challenge tokens stored without creation date as created now, to let them expire
+		if storedData.HTTPChallengesCreatedAt == nil {
+			storedData.HTTPChallengesCreatedAt = make(map[string]map[string]time.Time)
+		}
+		for token, domains := range storedData.HTTPChallenges {
+			for domain := range domains {
+				if _, ok := storedData.HTTPChallengesCreatedAt[token][domain]; !ok {
+					setHTTPChallengeCreatedAt(storedData, token, domain, time.Now())
 				}
 			}
+		}
 
-			// Delete all certificates with no value
-			var certificates []*Certificate
-			for _, certificate := range s.storedData.Certificates {
-				if len(certificate.Certificate) == 0 || len(certificate.Key) == 0 {
-					log.Debugf("Delete certificate %v for domains %v which have no value.", certificate, certificate.Domain.ToStrArray())
-					continue
-				}
-				certificates = append(certificates, certificate)
+		if storedData.TLSChallengesCreatedAt == nil {
+			storedData.TLSChallengesCreatedAt = make(map[string]time.Time)
+		}
+		for domain := range storedData.TLSChallenges {
+			if _, ok := storedData.TLSChallengesCreatedAt[domain]; !ok {
+				storedData.TLSChallengesCreatedAt[domain] = time.Now()
 			}
+		}
 
-			if len(certificates) < len(s.storedData.Certificates) {
-				s.storedData.Certificates = certificates
-				s.SaveDataChan <- s.storedData
+		// Delete all certificates with no value
+		var certificates []*Certificate
+		for _, certificate := range storedData.Certificates {
+			if len(certificate.Certificate) == 0 || len(certificate.Key) == 0 {
+				s.logger(storeOperationLoad).WithField(logFieldDomains, strings.Join(certificate.Domain.ToStrArray(), ",")).Debugf("Delete certificate %v for domains %v which have no value.", certificate, certificate.Domain.ToStrArray())
+				continue
 			}
+			certificates = append(certificates, certificate)
+		}
+
+		if len(certificates) < len(storedData.Certificates) {
+			storedData.Certificates = certificates
+			s.save(storedData)
 		}
+
+		audit := s.getAudit()
+		audit.saveAccount(storedData.Account, auditTriggerMigration)
+		audit.saveCertificates(storedData.Certificates, auditTriggerMigration)
 	}
 
-	return s.storedData, nil
+	if s.CertificateSecrets != nil {
+		if err := s.loadCertificateSecrets(storedData); err != nil {
+			return nil, err
+		}
+	}
+
+	s.writeCache(storedData)
+	return storedData, nil
+}
+
+// unmarshalStoredData decrypts and unmarshals the storage content into the StoredData,
+// then re-encrypts the storage when it is not encrypted with the configured key and mode
+func (s *LocalStore) unmarshalStoredData(file []byte, storedData *StoredData) error {
+	storedMode, previousKeyID, err := s.decodeStoredData(file, storedData)
+	if err != nil {
+		return err
+	}
+
+	if len(previousKeyID) > 0 {
+		reportStorageRewrap(storedData, previousKeyID)
+		s.save(storedData)
+	} else if s.Encryption != nil && storedMode != s.Encryption.getMode() {
+		s.logger(storeOperationLoad).Infof("Encrypt the ACME storage with the %q encryption mode.", s.Encryption.getMode())
+		s.save(storedData)
+	}
+
+	return nil
+}
+
+// decodeStoredData decrypts and unmarshals the storage file into storedData. It returns the encryption mode of the file,
+// and the key ID of the data encrypted with a previous key, if any.
+func (s *LocalStore) decodeStoredData(file []byte, storedData *StoredData) (string, string, error) {
+	data := file
+	previousKeyID := ""
+
+	envelope := parseEncryptedStoredData(file)
+	if envelope != nil {
+		key, primary, err := s.getStorageDecryptionKey(envelope.KeyID, envelope.WrappedKey)
+		if err != nil {
+			return "", "", fmt.Errorf("decryption failed: %v", err)
+		}
+		if key != nil && !primary {
+			previousKeyID = envelope.KeyID
+		}
+
+		data, err = key.decrypt(envelope)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if err := json.Unmarshal(data, storedData); err != nil {
+		s.logger(storeOperationLoad).Debugf("Unable to unmarshal the ACME storage %s: %s", s.filename, sanitizePayload(data))
+		return "", "", err
+	}
+
+	storedMode := ""
+	switch {
+	case envelope != nil:
+		storedMode = storageEncryptionModeFull
+	case storedData.KeysEncryption != nil:
+		storedMode = storageEncryptionModeKeys
+
+		header := storedData.KeysEncryption
+		key, primary, err := s.getStorageDecryptionKey(header.KeyID, header.WrappedKey)
+		if err != nil {
+			return "", "", fmt.Errorf("decryption failed: %v", err)
+		}
+		if key != nil && !primary {
+			previousKeyID = header.KeyID
+		}
+
+		plaintext, err := key.openStoredDataKeys(storedData)
+		if err != nil {
+			return "", "", err
+		}
+
+		if plaintext {
+			// Seal the private keys stored in plaintext
+			storedMode = ""
+		}
+	}
+
+	return storedMode, previousKeyID, nil
 }
 
-// listenSaveAction listens to a chan to store ACME data in json format into LocalStore.filename
+// listenSaveAction listens to a chan to store ACME data in json format into LocalStore.filename,
+// the bursts of saves are coalesced into a single write and the loop is restarted after a panic
 func (s *LocalStore) listenSaveAction() {
-	safe.Go(func() {
-		for object := range s.SaveDataChan {
-			data, err := json.MarshalIndent(object, "", "  ")
-			if err != nil {
-				log.Error(err)
+	safe.GoSupervised(func() {
+		for {
+			var object *StoredData
+			select {
+			case next, ok := <-s.SaveDataChan:
+				if !ok {
+					s.stopBreaker()
+					close(s.closed)
+					return
+				}
+				object = next
+			case <-s.breaker.probes():
+				object = s.probeWrite()
+			case result := <-s.persists:
+				result <- s.lastWrite
+				continue
+			case <-s.closing:
+				s.stopBreaker()
+				close(s.closed)
+				return
 			}
 
-			err = ioutil.WriteFile(s.filename, data, 0600)
-			if err != nil {
-				log.Error(err)
+			if s.IsReadOnly() {
+				s.logger(storeOperationSave).Warn("The ACME storage is in read-only mode, the data is not saved.")
+				s.lastWrite = ErrReadOnly
+				continue
+			}
+			s.health.changed()
+
+			object, updates := s.coalesceSaves(object)
+			s.reportCoalescedSaves(updates)
+			if s.suspendWrite(object) {
+				s.lastWrite = errWriteSuspended
+				continue
+			}
+			err := s.write(object)
+			s.lastWrite = err
+			if err == nil {
+				s.writeCache(object)
 			}
+			s.recordWrite(object, err)
 		}
-	})
+	}, s.recoverSaveLoop)
 }
 
-// GetAccount returns ACME Account
-func (s *LocalStore) GetAccount() (*Account, error) {
-	storedData, err := s.get()
-	if err != nil {
-		return nil, err
+// coalesceSaves collects the saves following the first one, and returns the last saved data with the number of saves.
+// Every save holds the whole data, only the last one needs to be written. Without a quiet period, only the pending saves
+// are collected; otherwise the saves are collected until none arrives for the quiet period, for MaxSaveDelay at most,
+// or until a save is flushed.
+func (s *LocalStore) coalesceSaves(object *StoredData) (*StoredData, int) {
+	updates := 1
+
+	if s.SaveQuietPeriod <= 0 {
+		for {
+			select {
+			case next, ok := <-s.SaveDataChan:
+				if !ok {
+					return object, updates
+				}
+				object = next
+				updates++
+			default:
+				return object, updates
+			}
+		}
+	}
+
+	quiet := time.NewTimer(s.SaveQuietPeriod)
+	defer quiet.Stop()
+	maxDelay := s.MaxSaveDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxSaveDelay
+	}
+	deadline := time.NewTimer(maxDelay)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case next, ok := <-s.SaveDataChan:
+			if !ok {
+				return object, updates
+			}
+			object = next
+			updates++
+
+			if !quiet.Stop() {
+				<-quiet.C
+			}
+			quiet.Reset(s.SaveQuietPeriod)
+		case <-quiet.C:
+			return object, updates
+		case <-deadline.C:
+			return object, updates
+		case <-s.flushes:
+			return object, updates
+		case <-s.closing:
+			return object, updates
+		}
+	}
+}
+
+// save hands over a copy of the data to the save loop, the data being changed while it is written.
+// The data is not saved once the store is closed.
+func (s *LocalStore) save(object *StoredData) {
+	s.lock.RLock()
+	snapshot := copyStoredData(object)
+	s.lock.RUnlock()
+
+	select {
+	case s.SaveDataChan <- snapshot:
+	case <-s.closing:
+		s.logger(storeOperationSave).Warn("The ACME storage is closed, the data is not saved.")
+	}
+}
+
+// Close stops the save loop once the saves handed over to it are written, and syncs the storage file
+func (s *LocalStore) Close(ctx context.Context) error {
+	if s.closing == nil {
+		return nil
 	}
 
-	return storedData.Account, nil
+	s.closeOnce.Do(func() { close(s.closing) })
+	s.closeSubscriptions()
+
+	select {
+	case <-s.closed:
+	case <-ctx.Done():
+		return fmt.Errorf("unable to close the ACME storage %s: %v", s.filename, ctx.Err())
+	}
+
+	return syncStorageFile(s.filename)
 }
 
-// SaveAccount stores ACME Account
-func (s *LocalStore) SaveAccount(account *Account) error {
-	storedData, err := s.get()
+// syncStorageFile commits the storage file to the disk, a missing file having nothing to sync
+func syncStorageFile(filename string) error {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
-	storedData.Account = account
-	s.SaveDataChan <- storedData
+	return file.Sync()
+}
 
-	return nil
+// flush writes the coalesced saves without waiting for the quiet period, once the data is sent to the save loop
+func (s *LocalStore) flush() {
+	select {
+	case s.flushes <- struct{}{}:
+	default:
+	}
 }
 
-// GetCertificates returns ACME Certificates list
-func (s *LocalStore) GetCertificates() ([]*Certificate, error) {
-	storedData, err := s.get()
-	if err != nil {
-		return nil, err
+// checkSaveDelays checks the quiet period and the max delay of the coalesced saves
+func checkSaveDelays(quietPeriod, maxDelay time.Duration) error {
+	if maxDelay == 0 {
+		maxDelay = defaultMaxSaveDelay
 	}
 
-	return storedData.Certificates, nil
+	switch {
+	case maxDelay < 0 || maxDelay > maxSaveDelayLimit:
+		return fmt.Errorf("the max save delay of the ACME storage %s is not between 0 and %s", maxDelay, maxSaveDelayLimit)
+	case quietPeriod > maxDelay:
+		return fmt.Errorf("the save quiet period of the ACME storage %s is longer than its max save delay %s", quietPeriod, maxDelay)
+	default:
+		return nil
+	}
 }
 
-// SaveCertificates stores ACME Certificates list
-func (s *LocalStore) SaveCertificates(certificates []*Certificate) error {
-	storedData, err := s.get()
+// write writes the data in the storage file, with its signature, and returns the error of the write
+func (s *LocalStore) write(object *StoredData) error {
+	if s.EphemeralChallenges {
+		persistedData := *object
+		persistedData.HTTPChallenges = nil
+		persistedData.HTTPChallengesCreatedAt = nil
+		persistedData.TLSChallenges = nil
+		persistedData.TLSChallengesCreatedAt = nil
+		object = &persistedData
+	}
+
+	// The certificates are only kept in the storage until they are moved to the Secrets
+	if s.CertificateSecrets != nil && atomic.LoadInt32(&s.certificatesInSecrets) == 1 {
+		persistedData := *object
+		persistedData.Certificates = nil
+		object = &persistedData
+	}
+
+	// The data is encoded in a pooled buffer, compact not to grow it with the indentation
+	buffer := storageBuffers.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer storageBuffers.Put(buffer)
+
+	err := json.NewEncoder(buffer).Encode(object)
 	if err != nil {
+		s.saveErrorf("Unable to marshal the ACME storage, the data is not saved: %v", err)
+		s.health.saved(fmt.Errorf("unable to marshal: %v", err))
 		return err
 	}
 
-	storedData.Certificates = certificates
-	s.SaveDataChan <- storedData
+	// The payload is compared with the one last written before its encryption
+	payloadSum := sha256.Sum256(buffer.Bytes())
+	if s.isPayloadWritten(payloadSum) {
+		s.skipWrite()
+		return nil
+	}
+	snapshot := newStoredDataSnapshot(object)
 
-	return nil
-}
+	var revisionDigest string
+	if s.Revisions > 0 {
+		var err error
+		revisionDigest, err = getRevisionDigest(buffer.Bytes())
+		if err != nil {
+			s.saveErrorf("Unable to hash the ACME storage, no revision is written: %v", err)
+		}
+	}
 
-// GetHTTPChallengeToken Get the http challenge token from the store
-func (s *LocalStore) GetHTTPChallengeToken(token, domain string) ([]byte, error) {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	var key *storageKey
+	if s.Encryption != nil {
+		var err error
+		key, err = s.getStorageKey()
+		if err != nil {
+			s.saveErrorf("Unable to encrypt the ACME storage, the data is not saved: %v", err)
+			s.health.saved(fmt.Errorf("unable to encrypt: %v", err))
+			return err
+		}
+	}
 
-	if s.storedData.HTTPChallenges == nil {
-		s.storedData.HTTPChallenges = map[string]map[string][]byte{}
+	// The data is encoded again with its private keys encrypted
+	if key != nil && s.Encryption.getMode() == storageEncryptionModeKeys {
+		sealedData, err := key.sealStoredDataKeys(object)
+		if err != nil {
+			s.saveErrorf("Unable to encrypt the ACME storage private keys, the data is not saved: %v", err)
+			s.health.saved(fmt.Errorf("unable to encrypt the private keys: %v", err))
+			return err
+		}
+
+		buffer.Reset()
+		if err := json.NewEncoder(buffer).Encode(sealedData); err != nil {
+			s.saveErrorf("Unable to marshal the ACME storage, the data is not saved: %v", err)
+			s.health.saved(fmt.Errorf("unable to marshal: %v", err))
+			return err
+		}
 	}
+	data := buffer.Bytes()
 
-	if _, ok := s.storedData.HTTPChallenges[token]; !ok {
-		return nil, fmt.Errorf("cannot find challenge for token %v", token)
+	if key != nil && s.Encryption.getMode() == storageEncryptionModeFull {
+		data, err = key.encrypt(data)
+		if err != nil {
+			s.saveErrorf("Unable to encrypt the ACME storage, the data is not saved: %v", err)
+			s.health.saved(fmt.Errorf("unable to encrypt: %v", err))
+			return err
+		}
 	}
 
-	result, ok := s.storedData.HTTPChallenges[token][domain]
-	if !ok {
-		return nil, fmt.Errorf("cannot find challenge for token %v", token)
+	var signature []byte
+	if s.Signing != nil {
+		signingKey, err := s.getSigningKey()
+		if err != nil {
+			s.saveErrorf("Unable to sign the ACME storage, the data is not saved: %v", err)
+			s.health.saved(fmt.Errorf("unable to sign: %v", err))
+			return err
+		}
+		signature = signStorage(signingKey, data)
 	}
-	return result, nil
+
+	s.logWriteDiff(snapshot)
+	err = s.writeStorageFile(s.filename, data, 0600)
+	if err != nil {
+		s.saveErrorf("Unable to write the ACME storage: %v", err)
+	} else {
+		s.health.setPayloadSize(len(data))
+		s.hash.set(data)
+	}
+	s.reportWrite(len(data), err)
+
+	if signature != nil {
+		if signatureErr := ioutil.WriteFile(getSignatureFilename(s.filename), signature, 0600); signatureErr != nil {
+			s.saveErrorf("Unable to write the signature of the ACME storage: %v", signatureErr)
+			if err == nil {
+				err = signatureErr
+			}
+		}
+	}
+	if err == nil {
+		s.payload.set(payloadSum)
+		s.written.set(snapshot)
+	}
+	s.health.saved(err)
+
+	if err == nil && len(revisionDigest) > 0 {
+		s.writeRevision(data, signature, revisionDigest)
+	}
+	return err
 }
 
-// SetHTTPChallengeToken Set the http challenge token in the store
-func (s *LocalStore) SetHTTPChallengeToken(token, domain string, keyAuth []byte) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// saveErrorf logs a failure of the save, at the debug level while the writes are suspended by the circuit breaker
+// not to repeat it on every probe
+func (s *LocalStore) saveErrorf(format string, args ...interface{}) {
+	if s.isDegraded() {
+		s.logger(storeOperationSave).Debugf(format, args...)
+		return
+	}
+	s.logger(storeOperationSave).Errorf(format, args...)
+}
 
-	if s.storedData.HTTPChallenges == nil {
-		s.storedData.HTTPChallenges = map[string]map[string][]byte{}
+// recoverSaveLoop reports a panic of the save loop, the data being saved is lost until the next save.
+// The panic is counted before it is recorded in the health, which orders the counter before the readers of the health.
+func (s *LocalStore) recoverSaveLoop(err interface{}, stack []byte) {
+	s.logger(storeOperationSave).Errorf("Panic while saving the ACME storage, the data is not saved: %v\n%s", err, stack)
+
+	s.metricsLock.Lock()
+	registry := s.metricsRegistry
+	s.metricsLock.Unlock()
+
+	if registry != nil {
+		registry.ACMEStorePanicsCounter().With("backend", getStoreBackend(s)).Add(1)
 	}
 
-	if _, ok := s.storedData.HTTPChallenges[token]; !ok {
-		s.storedData.HTTPChallenges[token] = map[string][]byte{}
+	s.health.saved(fmt.Errorf("panic: %v", err))
+}
+
+// verifySignature checks the signature of the storage content, and returns the reason of the signature failure, if any
+func (s *LocalStore) verifySignature(file []byte) (string, error) {
+	signingKey, err := s.getSigningKey()
+	if err != nil {
+		return "", err
 	}
 
-	s.storedData.HTTPChallenges[token][domain] = keyAuth
-	return nil
+	failure := ""
+	signature, err := ioutil.ReadFile(getSignatureFilename(s.filename))
+	switch {
+	case os.IsNotExist(err):
+		failure = storageSignatureMissing
+	case err != nil:
+		return "", err
+	case !verifyStorage(signingKey, file, signature):
+		failure = storageSignatureInvalid
+	default:
+		return "", nil
+	}
+
+	s.countSignatureFailure(failure)
+	return failure, nil
 }
 
-// RemoveHTTPChallengeToken Remove the http challenge token in the store
-func (s *LocalStore) RemoveHTTPChallengeToken(token, domain string) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+func (s *LocalStore) getSigningKey() (ed25519.PrivateKey, error) {
+	s.storageKeyLock.Lock()
+	defer s.storageKeyLock.Unlock()
 
-	if s.storedData.HTTPChallenges == nil {
-		return nil
+	if s.signingKey != nil {
+		return s.signingKey, nil
 	}
 
-	if _, ok := s.storedData.HTTPChallenges[token]; ok {
-		if _, domainOk := s.storedData.HTTPChallenges[token][domain]; domainOk {
-			delete(s.storedData.HTTPChallenges[token], domain)
-		}
-		if len(s.storedData.HTTPChallenges[token]) == 0 {
-			delete(s.storedData.HTTPChallenges, token)
-		}
+	key, err := loadSigningKey(s.Signing)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	s.signingKey = key
+	return key, nil
 }
 
-// AddTLSChallenge Add a certificate to the ACME TLS-ALPN-01 certificates storage
-func (s *LocalStore) AddTLSChallenge(domain string, cert *Certificate) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// SetMetricsRegistry sets the registry used to report the storage metrics,
+// the signature failures detected before are reported right away
+func (s *LocalStore) SetMetricsRegistry(registry metrics.Registry) {
+	s.metricsLock.Lock()
+	defer s.metricsLock.Unlock()
 
-	if s.storedData.TLSChallenges == nil {
-		s.storedData.TLSChallenges = make(map[string]*Certificate)
+	s.metricsRegistry = registry
+	for reason, count := range s.signatureFailures {
+		countStorageSignatureFailures(registry, reason, count)
 	}
+	s.signatureFailures = nil
+	reportStorageCanary(registry, getStoreBackend(s), s.getCanary())
+}
 
-	s.storedData.TLSChallenges[domain] = cert
-	return nil
+func (s *LocalStore) countSignatureFailure(reason string) {
+	s.metricsLock.Lock()
+	defer s.metricsLock.Unlock()
+
+	if s.metricsRegistry != nil {
+		countStorageSignatureFailures(s.metricsRegistry, reason, 1)
+		return
+	}
+
+	if s.signatureFailures == nil {
+		s.signatureFailures = make(map[string]int)
+	}
+	s.signatureFailures[reason]++
 }
 
-// GetTLSChallenge Get a certificate from the ACME TLS-ALPN-01 certificates storage
-func (s *LocalStore) GetTLSChallenge(domain string) (*Certificate, error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// reportCoalescedSaves reports the number of saves coalesced into a write
+func (s *LocalStore) reportCoalescedSaves(updates int) {
+	s.metricsLock.Lock()
+	registry := s.metricsRegistry
+	s.metricsLock.Unlock()
 
-	if s.storedData.TLSChallenges == nil {
-		s.storedData.TLSChallenges = make(map[string]*Certificate)
+	if registry == nil {
+		return
 	}
 
-	return s.storedData.TLSChallenges[domain], nil
+	registry.ACMEStoreCoalescedUpdatesHistogram().With("backend", getStoreBackend(s)).Observe(float64(updates))
 }
 
-// RemoveTLSChallenge Remove a certificate from the ACME TLS-ALPN-01 certificates storage
-func (s *LocalStore) RemoveTLSChallenge(domain string) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// reportWrite reports the size of the storage file after a successful write, or the write rejected for its size
+func (s *LocalStore) reportWrite(size int, err error) {
+	s.metricsLock.Lock()
+	registry := s.metricsRegistry
+	s.metricsLock.Unlock()
 
-	if s.storedData.TLSChallenges == nil {
-		return nil
+	if registry == nil {
+		return
 	}
 
-	delete(s.storedData.TLSChallenges, domain)
-	return nil
+	if err != nil {
+		if isFileTooLarge(err) {
+			registry.ACMEStoreRejectedWritesCounter().With("backend", getStoreBackend(s)).Add(1)
+		}
+		return
+	}
+	registry.ACMEStorePayloadSizeGauge().With("backend", getStoreBackend(s), "stage", storePayloadStageSerialized).Set(float64(size))
+}
+
+func isFileTooLarge(err error) bool {
+	pathErr, ok := err.(*os.PathError)
+	return ok && pathErr.Err == syscall.EFBIG
+}
+
+// getAudit returns the audit of the store mutations
+func (s *LocalStore) getAudit() *storeAudit {
+	s.auditOnce.Do(func() {
+		s.audit = newStoreAudit(s.AuditLog)
+	})
+	return s.audit
+}
+
+// getStorageKey returns the primary key used to encrypt the storage, or nil when the storage is not encrypted.
+// With a KMS, a new data key is generated unless the storage has been decrypted with the primary key.
+func (s *LocalStore) getStorageKey() (*storageKey, error) {
+	if s.Encryption == nil {
+		return nil, nil
+	}
+
+	if err := s.Encryption.checkMode(); err != nil {
+		return nil, err
+	}
+
+	s.storageKeyLock.Lock()
+	defer s.storageKeyLock.Unlock()
+
+	if s.storageKey != nil {
+		return s.storageKey, nil
+	}
+
+	key, err := s.loadStorageKey(s.Encryption.primaryKey(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.storageKey = key
+	return key, nil
+}
+
+// getStorageDecryptionKey returns the configured key matching the key ID of the encrypted data,
+// and whether this key is the primary key.
+func (s *LocalStore) getStorageDecryptionKey(keyID string, wrappedKey []byte) (*storageKey, bool, error) {
+	if s.Encryption == nil {
+		return nil, false, nil
+	}
+
+	if err := s.Encryption.checkMode(); err != nil {
+		return nil, false, err
+	}
+
+	s.storageKeyLock.Lock()
+	defer s.storageKeyLock.Unlock()
+
+	for i, config := range s.Encryption.getKeys() {
+		if (config.KMS != nil && config.KMS.Key != keyID) || (config.KMS == nil && len(config.KeyID) > 0 && config.KeyID != keyID) {
+			continue
+		}
+
+		key, err := s.loadStorageKey(config, wrappedKey)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if key.id != keyID {
+			continue
+		}
+
+		if i == 0 {
+			s.storageKey = key
+		}
+		return key, i == 0, nil
+	}
+
+	return nil, false, fmt.Errorf("the storage is encrypted with the key %q, which is not configured anymore: keep it in the previous keys until the storage is re-encrypted", keyID)
+}
+
+func (s *LocalStore) loadStorageKey(config *StorageEncryptionKey, wrappedKey []byte) (*storageKey, error) {
+	if config.KMS == nil {
+		return loadStorageKey(config)
+	}
+
+	provider, ok := s.kmsProviders[config.KMS.Provider]
+	if !ok {
+		var err error
+		provider, err = newKMSProvider(config.KMS)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.kmsProviders == nil {
+			s.kmsProviders = make(map[string]KMSProvider)
+		}
+		s.kmsProviders[config.KMS.Provider] = provider
+	}
+
+	return newKMSStorageKey(config.KMS, provider, wrappedKey)
+}
+
+// GetAccount returns a copy of the ACME Account
+func (s *LocalStore) GetAccount(ctx context.Context) (*Account, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return copyAccount(storedData.Account), nil
+}
+
+// SaveAccount stores a copy of the ACME Account, the caller keeping its account
+func (s *LocalStore) SaveAccount(ctx context.Context, account *Account) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.getAudit().saveAccount(account, "")
+
+	s.lock.Lock()
+	storedData.Account = copyAccount(account)
+	s.lock.Unlock()
+
+	s.save(storedData)
+	// The registration of an account can not be lost, it is written without waiting for the next saves
+	s.flush()
+
+	return nil
+}
+
+// GetCertificates returns copies of the ACME Certificates
+func (s *LocalStore) GetCertificates(ctx context.Context) ([]*Certificate, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return copyCertificates(storedData.Certificates), nil
+}
+
+// SaveCertificates stores copies of the ACME Certificates, the caller keeping its certificates
+func (s *LocalStore) SaveCertificates(ctx context.Context, certificates []*Certificate) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if s.CertificateSecrets != nil {
+		if err := s.saveCertificateSecrets(certificates); err != nil {
+			return err
+		}
+	}
+
+	s.getAudit().saveCertificates(certificates, "")
+
+	s.lock.Lock()
+	changedDomains := changedCertificateDomains(storedData.Certificates, certificates)
+	storedData.Certificates = copyCertificates(certificates)
+	s.lock.Unlock()
+
+	s.save(storedData)
+	s.subscriptions.notify(changedDomains)
+	// The obtained certificates can not be lost, they are written without waiting for the next saves
+	s.flush()
+
+	return nil
+}
+
+// MigrateStoredData stores the ACME data converted from a previous storage format
+func (s *LocalStore) MigrateStoredData(storedData *StoredData) {
+	if s.IsReadOnly() {
+		s.logger(storeOperationMigrate).Warn("The ACME storage is read-only, the converted data is not saved.")
+		return
+	}
+
+	audit := s.getAudit()
+	audit.saveAccount(storedData.Account, auditTriggerMigration)
+	audit.saveCertificates(storedData.Certificates, auditTriggerMigration)
+
+	s.save(storedData)
+}
+
+// GetCertificateByDomain returns a copy of the ACME Certificate serving the domain, or ErrNotFound
+func (s *LocalStore) GetCertificateByDomain(ctx context.Context, domain string) (*Certificate, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	certificate := copyCertificate(findCertificateByDomain(storedData.Certificates, domain))
+	s.lock.RUnlock()
+
+	if certificate == nil {
+		return nil, ErrNotFound
+	}
+
+	return certificate, nil
+}
+
+// GetHTTPChallengeToken Get the http challenge token from the store
+func (s *LocalStore) GetHTTPChallengeToken(ctx context.Context, token, domain string) ([]byte, error) {
+	domain = normalizeDomain(domain)
+
+	if _, err := s.get(ctx); err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.storedData.HTTPChallenges == nil {
+		s.storedData.HTTPChallenges = map[string]map[string][]byte{}
+	}
+
+	if _, ok := s.storedData.HTTPChallenges[token]; !ok {
+		return nil, ErrNotFound
+	}
+
+	result, ok := s.storedData.HTTPChallenges[token][domain]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// Never serve a stale key authorization, even before the expired tokens are removed
+	createdAt, ok := s.storedData.HTTPChallengesCreatedAt[token][domain]
+	if ok && time.Since(createdAt) > s.getHTTPChallengeTokenValidity() {
+		return nil, ErrNotFound
+	}
+
+	return result, nil
+}
+
+func (s *LocalStore) getHTTPChallengeTokenValidity() time.Duration {
+	if s.HTTPChallengeTokenValidity > 0 {
+		return s.HTTPChallengeTokenValidity
+	}
+	return defaultHTTPChallengeTokenTTL
+}
+
+// GetHTTPChallenges Get a copy of all the http challenge tokens from the store
+func (s *LocalStore) GetHTTPChallenges(ctx context.Context) ([]*PendingHTTPChallenge, error) {
+	if _, err := s.get(ctx); err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var challenges []*PendingHTTPChallenge
+	for token, domains := range s.storedData.HTTPChallenges {
+		for domain, keyAuth := range domains {
+			challenges = append(challenges, &PendingHTTPChallenge{
+				Token:     token,
+				Domain:    domain,
+				KeyAuth:   append([]byte(nil), keyAuth...),
+				CreatedAt: s.storedData.HTTPChallengesCreatedAt[token][domain],
+			})
+		}
+	}
+
+	return challenges, nil
+}
+
+// SetHTTPChallengeToken Set the http challenge token in the store
+func (s *LocalStore) SetHTTPChallengeToken(ctx context.Context, token, domain string, keyAuth []byte) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	domain = normalizeDomain(domain)
+
+	if _, err := s.get(ctx); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.storedData.HTTPChallenges == nil {
+		s.storedData.HTTPChallenges = map[string]map[string][]byte{}
+	}
+
+	if _, ok := s.storedData.HTTPChallenges[token]; !ok {
+		s.storedData.HTTPChallenges[token] = map[string][]byte{}
+	}
+
+	s.storedData.HTTPChallenges[token][domain] = keyAuth
+	setHTTPChallengeCreatedAt(s.storedData, token, domain, time.Now())
+	s.getAudit().challenge(auditActionChallengeAdded, challengeTypeHTTP01, domain, token)
+	return nil
+}
+
+// RemoveHTTPChallengeToken Remove the http challenge token in the store
+func (s *LocalStore) RemoveHTTPChallengeToken(ctx context.Context, token, domain string) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	domain = normalizeDomain(domain)
+
+	if _, err := s.get(ctx); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.storedData.HTTPChallenges[token][domain]; !ok {
+		return nil
+	}
+
+	removeHTTPChallenge(s.storedData, token, domain)
+	s.getAudit().challenge(auditActionChallengeRemoved, challengeTypeHTTP01, domain, token)
+	return nil
+}
+
+// RemoveExpiredHTTPChallengeTokens Remove the http challenge tokens created for longer than the TTL and returns how many were removed
+func (s *LocalStore) RemoveExpiredHTTPChallengeTokens(ctx context.Context, ttl time.Duration) (int, error) {
+	if s.IsReadOnly() {
+		return 0, ErrReadOnly
+	}
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.lock.Lock()
+	removed := 0
+	now := time.Now()
+	for token, domains := range storedData.HTTPChallenges {
+		for domain := range domains {
+			createdAt, ok := storedData.HTTPChallengesCreatedAt[token][domain]
+			if ok && now.Sub(createdAt) < ttl {
+				continue
+			}
+			removeHTTPChallenge(storedData, token, domain)
+			s.getAudit().challenge(auditActionChallengeRemoved, challengeTypeHTTP01, domain, token)
+			removed++
+		}
+	}
+	s.lock.Unlock()
+
+	if removed > 0 {
+		s.save(storedData)
+	}
+	return removed, nil
+}
+
+// RemoveHTTPChallengeTokensForDomain Remove all the http challenge tokens of the domain and returns how many were removed
+func (s *LocalStore) RemoveHTTPChallengeTokensForDomain(ctx context.Context, domain string) (int, error) {
+	if s.IsReadOnly() {
+		return 0, ErrReadOnly
+	}
+
+	domain = normalizeDomain(domain)
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.lock.Lock()
+	removed := 0
+	for token, domains := range storedData.HTTPChallenges {
+		if _, ok := domains[domain]; ok {
+			removeHTTPChallenge(storedData, token, domain)
+			s.getAudit().challenge(auditActionChallengeRemoved, challengeTypeHTTP01, domain, token)
+			removed++
+		}
+	}
+	s.lock.Unlock()
+
+	if removed > 0 {
+		s.save(storedData)
+	}
+	return removed, nil
+}
+
+func setHTTPChallengeCreatedAt(storedData *StoredData, token, domain string, createdAt time.Time) {
+	if storedData.HTTPChallengesCreatedAt == nil {
+		storedData.HTTPChallengesCreatedAt = make(map[string]map[string]time.Time)
+	}
+
+	if _, ok := storedData.HTTPChallengesCreatedAt[token]; !ok {
+		storedData.HTTPChallengesCreatedAt[token] = make(map[string]time.Time)
+	}
+
+	storedData.HTTPChallengesCreatedAt[token][domain] = createdAt
+}
+
+func removeHTTPChallenge(storedData *StoredData, token, domain string) {
+	if _, ok := storedData.HTTPChallenges[token]; ok {
+		delete(storedData.HTTPChallenges[token], domain)
+		if len(storedData.HTTPChallenges[token]) == 0 {
+			delete(storedData.HTTPChallenges, token)
+		}
+	}
+
+	if _, ok := storedData.HTTPChallengesCreatedAt[token]; ok {
+		delete(storedData.HTTPChallengesCreatedAt[token], domain)
+		if len(storedData.HTTPChallengesCreatedAt[token]) == 0 {
+			delete(storedData.HTTPChallengesCreatedAt, token)
+		}
+	}
+}
+
+// AddTLSChallenge Add a certificate to the ACME TLS-ALPN-01 certificates storage
+func (s *LocalStore) AddTLSChallenge(ctx context.Context, domain string, cert *Certificate) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	domain = normalizeDomain(domain)
+
+	if _, err := s.get(ctx); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.storedData.TLSChallenges == nil {
+		s.storedData.TLSChallenges = make(map[string]*Certificate)
+	}
+
+	s.storedData.TLSChallenges[domain] = cert
+
+	if s.storedData.TLSChallengesCreatedAt == nil {
+		s.storedData.TLSChallengesCreatedAt = make(map[string]time.Time)
+	}
+	s.storedData.TLSChallengesCreatedAt[domain] = time.Now()
+	s.getAudit().challenge(auditActionChallengeAdded, challengeTypeTLSALPN01, domain, "")
+
+	return nil
+}
+
+// GetTLSChallenge Get a certificate from the ACME TLS-ALPN-01 certificates storage, falling back to a wildcard challenge
+func (s *LocalStore) GetTLSChallenge(ctx context.Context, domain string) (*Certificate, error) {
+	domain = normalizeDomain(domain)
+
+	if _, err := s.get(ctx); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.storedData.TLSChallenges == nil {
+		s.storedData.TLSChallenges = make(map[string]*Certificate)
+	}
+
+	if cert, ok := s.storedData.TLSChallenges[domain]; ok {
+		return cert, nil
+	}
+
+	// The challenges of a wildcard order are keyed by the wildcard domain, but validated with a concrete subdomain
+	for challengeDomain, cert := range s.storedData.TLSChallenges {
+		if strings.HasPrefix(challengeDomain, "*.") && matchDomain(domain, challengeDomain) {
+			return cert, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// GetTLSChallenges Get a copy of all the certificates from the ACME TLS-ALPN-01 certificates storage, by domain
+func (s *LocalStore) GetTLSChallenges(ctx context.Context) (map[string]*Certificate, error) {
+	if _, err := s.get(ctx); err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	certificates := make(map[string]*Certificate, len(s.storedData.TLSChallenges))
+	for domain, cert := range s.storedData.TLSChallenges {
+		certificates[domain] = copyCertificate(cert)
+	}
+
+	return certificates, nil
+}
+
+// GetTLSChallengesCreatedAt Get the creation dates of the certificates from the ACME TLS-ALPN-01 certificates storage, by domain
+func (s *LocalStore) GetTLSChallengesCreatedAt(ctx context.Context) (map[string]time.Time, error) {
+	if _, err := s.get(ctx); err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	createdAt := make(map[string]time.Time, len(s.storedData.TLSChallengesCreatedAt))
+	for domain, date := range s.storedData.TLSChallengesCreatedAt {
+		createdAt[domain] = date
+	}
+
+	return createdAt, nil
+}
+
+// RemoveTLSChallenge Remove a certificate from the ACME TLS-ALPN-01 certificates storage
+func (s *LocalStore) RemoveTLSChallenge(ctx context.Context, domain string) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	domain = normalizeDomain(domain)
+
+	if _, err := s.get(ctx); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.storedData.TLSChallenges[domain]; !ok {
+		return nil
+	}
+
+	delete(s.storedData.TLSChallenges, domain)
+	delete(s.storedData.TLSChallengesCreatedAt, domain)
+	s.getAudit().challenge(auditActionChallengeRemoved, challengeTypeTLSALPN01, domain, "")
+	return nil
+}
+
+// AddDNSChallenge stores the state of a DNS-01 challenge
+func (s *LocalStore) AddDNSChallenge(ctx context.Context, token string, state *DNSChallengeState) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	if storedData.DNSChallenges == nil {
+		storedData.DNSChallenges = make(map[string]*DNSChallengeState)
+	}
+	storedData.DNSChallenges[token] = state
+	s.lock.Unlock()
+
+	s.getAudit().challenge(auditActionChallengeAdded, challengeTypeDNS01, state.Domain, token)
+
+	s.save(storedData)
+	return nil
+}
+
+// GetDNSChallenges returns a copy of the states of the DNS-01 challenges, by token
+func (s *LocalStore) GetDNSChallenges(ctx context.Context) (map[string]*DNSChallengeState, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	states := make(map[string]*DNSChallengeState, len(storedData.DNSChallenges))
+	for token, state := range storedData.DNSChallenges {
+		stateCopy := *state
+		states[token] = &stateCopy
+	}
+
+	return states, nil
+}
+
+// RemoveDNSChallenge removes the state of a DNS-01 challenge
+func (s *LocalStore) RemoveDNSChallenge(ctx context.Context, token string) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	state, ok := storedData.DNSChallenges[token]
+	delete(storedData.DNSChallenges, token)
+	s.lock.Unlock()
+
+	if ok {
+		s.getAudit().challenge(auditActionChallengeRemoved, challengeTypeDNS01, state.Domain, token)
+		s.save(storedData)
+	}
+	return nil
+}
+
+// AddOnDemandRequest queues a domain requested on demand, or counts a new request of a queued domain
+func (s *LocalStore) AddOnDemandRequest(ctx context.Context, domain string, requestedAt time.Time) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	domain = normalizeDomain(domain)
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	if storedData.OnDemandQueue == nil {
+		storedData.OnDemandQueue = make(map[string]*OnDemandRequest)
+	}
+	// The request is replaced rather than changed, the saved snapshots sharing it
+	request := &OnDemandRequest{Domain: domain, FirstRequestedAt: requestedAt}
+	if queued, ok := storedData.OnDemandQueue[domain]; ok {
+		*request = *queued
+	}
+	request.LastRequestedAt = requestedAt
+	request.Requests++
+	storedData.OnDemandQueue[domain] = request
+	s.lock.Unlock()
+
+	s.save(storedData)
+	return nil
+}
+
+// GetOnDemandQueue returns a copy of the domains queued on demand, by domain
+func (s *LocalStore) GetOnDemandQueue(ctx context.Context) (map[string]*OnDemandRequest, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	queue := make(map[string]*OnDemandRequest, len(storedData.OnDemandQueue))
+	for domain, request := range storedData.OnDemandQueue {
+		requestCopy := *request
+		queue[domain] = &requestCopy
+	}
+
+	return queue, nil
+}
+
+// GetDesiredDomains returns a copy of the domains to provide certificates for, by domain key
+func (s *LocalStore) GetDesiredDomains(ctx context.Context) (map[string]*DesiredDomain, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	desired := make(map[string]*DesiredDomain, len(storedData.DesiredDomains))
+	for key, domain := range storedData.DesiredDomains {
+		desired[key] = copyDesiredDomain(domain)
+	}
+
+	return desired, nil
+}
+
+// GetOrderHistory returns a copy of the history of the orders placed with the CA
+func (s *LocalStore) GetOrderHistory(ctx context.Context) (*OrderHistory, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return copyOrderHistory(storedData.OrderHistory), nil
+}
+
+// GetStagingAccount returns a copy of the account registered with the staging CA, nil when none is
+func (s *LocalStore) GetStagingAccount(ctx context.Context) (*Account, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return copyAccount(storedData.StagingAccount), nil
+}
+
+// GetDefaultCertificate returns a copy of the stored default certificate of the entry points, nil when none is
+func (s *LocalStore) GetDefaultCertificate(ctx context.Context) (*DefaultCertificate, error) {
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return copyDefaultCertificate(storedData.DefaultCertificate), nil
+}
+
+// RemoveOnDemandRequest removes a domain from the on demand queue
+func (s *LocalStore) RemoveOnDemandRequest(ctx context.Context, domain string) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	domain = normalizeDomain(domain)
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	_, ok := storedData.OnDemandQueue[domain]
+	delete(storedData.OnDemandQueue, domain)
+	s.lock.Unlock()
+
+	if ok {
+		s.save(storedData)
+	}
+	return nil
+}
+
+// RemoveExpiredOnDemandRequests removes the domains of the on demand queue not requested again for the ttl,
+// and returns how many were removed
+func (s *LocalStore) RemoveExpiredOnDemandRequests(ctx context.Context, ttl time.Duration) (int, error) {
+	if s.IsReadOnly() {
+		return 0, ErrReadOnly
+	}
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.lock.Lock()
+	removed := 0
+	now := time.Now()
+	for domain, request := range storedData.OnDemandQueue {
+		if now.Sub(request.LastRequestedAt) < ttl {
+			continue
+		}
+		delete(storedData.OnDemandQueue, domain)
+		removed++
+	}
+	s.lock.Unlock()
+
+	if removed > 0 {
+		s.save(storedData)
+	}
+	return removed, nil
 }
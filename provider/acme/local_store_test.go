@@ -0,0 +1,616 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLocalStore(t *testing.T) (*LocalStore, func()) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+
+	store := NewLocalStore(filepath.Join(dir, "acme.json"))
+	_, err = store.get(context.Background())
+	require.NoError(t, err)
+
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestLocalStoreRemoveHTTPChallengeToken(t *testing.T) {
+	store := &LocalStore{storedData: &StoredData{}}
+
+	require.NoError(t, store.RemoveHTTPChallengeToken(context.Background(), "unknown", "traefik.wtf"))
+	assert.Nil(t, store.storedData.HTTPChallenges)
+
+	for i := 0; i < 100; i++ {
+		token := fmt.Sprintf("token%d", i)
+		require.NoError(t, store.SetHTTPChallengeToken(context.Background(), token, "traefik.wtf", []byte(token)))
+		require.NoError(t, store.SetHTTPChallengeToken(context.Background(), token, "www.traefik.wtf", []byte(token)))
+		require.NoError(t, store.RemoveHTTPChallengeToken(context.Background(), token, "traefik.wtf"))
+		require.NoError(t, store.RemoveHTTPChallengeToken(context.Background(), token, "www.traefik.wtf"))
+	}
+
+	data, err := json.Marshal(store.storedData)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "token")
+	assert.Empty(t, store.storedData.HTTPChallenges)
+	assert.Empty(t, store.storedData.HTTPChallengesCreatedAt)
+}
+
+func TestLocalStoreNormalizeChallengeDomains(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		setDomain    string
+		lookupDomain string
+	}{
+		{
+			desc:         "mixed case",
+			setDomain:    "WWW.Traefik.wtf",
+			lookupDomain: "www.traefik.WTF",
+		},
+		{
+			desc:         "trailing dot",
+			setDomain:    "traefik.wtf.",
+			lookupDomain: "traefik.wtf",
+		},
+		{
+			desc:         "unicode stored, punycode requested",
+			setDomain:    "bücher.example",
+			lookupDomain: "xn--bcher-kva.example",
+		},
+		{
+			desc:         "punycode stored, unicode requested",
+			setDomain:    "xn--bcher-kva.example",
+			lookupDomain: "Bücher.example.",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			store := &LocalStore{storedData: &StoredData{}}
+
+			require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", test.setDomain, []byte("keyAuth")))
+			value, err := store.GetHTTPChallengeToken(context.Background(), "token", test.lookupDomain)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("keyAuth"), value)
+
+			require.NoError(t, store.RemoveHTTPChallengeToken(context.Background(), "token", test.lookupDomain))
+			assert.Empty(t, store.storedData.HTTPChallenges)
+
+			cert := &Certificate{Domain: types.Domain{Main: "TEMP-" + test.setDomain}}
+			require.NoError(t, store.AddTLSChallenge(context.Background(), test.setDomain, cert))
+			tlsCert, err := store.GetTLSChallenge(context.Background(), test.lookupDomain)
+			require.NoError(t, err)
+			assert.Equal(t, cert, tlsCert)
+
+			require.NoError(t, store.RemoveTLSChallenge(context.Background(), test.lookupDomain))
+			assert.Empty(t, store.storedData.TLSChallenges)
+		})
+	}
+}
+
+func TestLocalStoreGetTLSChallenges(t *testing.T) {
+	store := &LocalStore{storedData: &StoredData{}}
+
+	certificates, err := store.GetTLSChallenges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, certificates)
+
+	cert := &Certificate{Domain: types.Domain{Main: "TEMP-traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}
+	require.NoError(t, store.AddTLSChallenge(context.Background(), "traefik.wtf", cert))
+
+	certificates, err = store.GetTLSChallenges(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, certificates, "traefik.wtf")
+	assert.Equal(t, cert, certificates["traefik.wtf"])
+
+	certificates["traefik.wtf"].Key[0] = 'K'
+	delete(certificates, "traefik.wtf")
+	assert.Equal(t, []byte("key"), store.storedData.TLSChallenges["traefik.wtf"].Key, "the returned certificates must be copies")
+	assert.Contains(t, store.storedData.TLSChallenges, "traefik.wtf")
+}
+
+func TestLocalStoreRemoveExpiredHTTPChallengeTokens(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "fresh", "traefik.wtf", []byte("fresh")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "stale", "traefik.wtf", []byte("stale")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "stale", "www.traefik.wtf", []byte("stale")))
+	setHTTPChallengeCreatedAt(store.storedData, "stale", "traefik.wtf", time.Now().Add(-2*time.Hour))
+	setHTTPChallengeCreatedAt(store.storedData, "stale", "www.traefik.wtf", time.Now().Add(-2*time.Hour))
+
+	removed, err := store.RemoveExpiredHTTPChallengeTokens(context.Background(), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	_, err = store.GetHTTPChallengeToken(context.Background(), "stale", "traefik.wtf")
+	assert.Error(t, err)
+	assert.NotContains(t, store.storedData.HTTPChallengesCreatedAt, "stale")
+
+	value, err := store.GetHTTPChallengeToken(context.Background(), "fresh", "traefik.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("fresh"), value)
+}
+
+func TestLocalStoreHTTPChallengeTokenValidity(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		validity    time.Duration
+		age         time.Duration
+		expectedErr error
+	}{
+		{
+			desc: "fresh token",
+			age:  time.Minute,
+		},
+		{
+			desc:        "token older than the default validity",
+			age:         2 * time.Hour,
+			expectedErr: ErrNotFound,
+		},
+		{
+			desc:     "token within the configured validity",
+			validity: 3 * time.Hour,
+			age:      2 * time.Hour,
+		},
+		{
+			desc:        "token older than the configured validity",
+			validity:    5 * time.Minute,
+			age:         10 * time.Minute,
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			store := &LocalStore{storedData: &StoredData{}, HTTPChallengeTokenValidity: test.validity}
+			require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+			setHTTPChallengeCreatedAt(store.storedData, "token", "traefik.wtf", time.Now().Add(-test.age))
+
+			value, err := store.GetHTTPChallengeToken(context.Background(), "token", "traefik.wtf")
+			if test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr, err)
+				assert.Nil(t, value)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []byte("keyAuth"), value)
+		})
+	}
+}
+
+func TestLocalStoreTLSChallengesCreatedAt(t *testing.T) {
+	store := &LocalStore{storedData: &StoredData{}}
+
+	before := time.Now()
+	require.NoError(t, store.AddTLSChallenge(context.Background(), "Traefik.wtf", &Certificate{}))
+
+	createdAt, err := store.GetTLSChallengesCreatedAt(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, createdAt, "traefik.wtf")
+	assert.False(t, createdAt["traefik.wtf"].Before(before))
+
+	require.NoError(t, store.RemoveTLSChallenge(context.Background(), "traefik.wtf"))
+
+	createdAt, err = store.GetTLSChallengesCreatedAt(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, createdAt)
+}
+
+func TestLocalStoreRemoveHTTPChallengeTokensForDomain(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "foo", "traefik.wtf", []byte("foo")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "bar", "traefik.wtf", []byte("bar")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "bar", "traefik.io", []byte("bar")))
+
+	removed, err := store.RemoveHTTPChallengeTokensForDomain(context.Background(), "traefik.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	assert.Equal(t, map[string]map[string][]byte{"bar": {"traefik.io": []byte("bar")}}, store.storedData.HTTPChallenges)
+}
+
+func TestLocalStoreEphemeralChallenges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	err = ioutil.WriteFile(filename, []byte(`{"HTTPChallenges":{"foo":{"traefik.wtf":"Zm9v"}},"TLSChallenges":{"traefik.wtf":{"Domain":{"Main":"TEMP-traefik.wtf"}}}}`), 0600)
+	require.NoError(t, err)
+
+	store := &LocalStore{filename: filename, SaveDataChan: make(chan *StoredData), EphemeralChallenges: true}
+	store.listenSaveAction()
+
+	storedData, err := store.get(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, storedData.HTTPChallenges, "the persisted challenges must be dropped on load")
+	assert.Empty(t, storedData.TLSChallenges, "the persisted challenges must be dropped on load")
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "bar", "traefik.wtf", []byte("bar")))
+	require.NoError(t, store.AddTLSChallenge(context.Background(), "traefik.wtf", &Certificate{Domain: types.Domain{Main: "TEMP-traefik.wtf"}}))
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}}}))
+
+	var persistedData StoredData
+	for i := 0; i < 500 && len(persistedData.Certificates) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if data, err := ioutil.ReadFile(filename); err == nil {
+			_ = json.Unmarshal(data, &persistedData)
+		}
+	}
+	require.Len(t, persistedData.Certificates, 1)
+
+	assert.Empty(t, persistedData.HTTPChallenges)
+	assert.Empty(t, persistedData.HTTPChallengesCreatedAt)
+	assert.Empty(t, persistedData.TLSChallenges)
+
+	value, err := store.GetHTTPChallengeToken(context.Background(), "bar", "traefik.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bar"), value, "the challenges must be kept in memory")
+}
+
+func TestLocalStoreGetSetsHTTPChallengeCreatedAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	err = ioutil.WriteFile(filename, []byte(`{"HTTPChallenges":{"foo":{"traefik.wtf":"Zm9v"}}}`), 0600)
+	require.NoError(t, err)
+
+	store := NewLocalStore(filename)
+	storedData, err := store.get(context.Background())
+	require.NoError(t, err)
+
+	require.Contains(t, storedData.HTTPChallengesCreatedAt, "foo")
+	assert.WithinDuration(t, time.Now(), storedData.HTTPChallengesCreatedAt["foo"]["traefik.wtf"], time.Minute)
+}
+
+func TestLocalStoreCopiesAccountAndCertificates(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	account := &Account{Email: "test@traefik.wtf", PrivateKey: []byte("key"), PrivateKeyType: "RSA4096"}
+	require.NoError(t, store.SaveAccount(context.Background(), account))
+	certificates := []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+
+	// The saved values are not changed by their caller
+	account.Email = "other@traefik.wtf"
+	certificates[0].Certificate = []byte("other cert")
+
+	saved, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test@traefik.wtf", saved.Email)
+
+	savedCertificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, savedCertificates, 1)
+	assert.Equal(t, []byte("cert"), savedCertificates[0].Certificate)
+
+	// The returned values are not the stored ones
+	saved.Email = "other@traefik.wtf"
+	savedCertificates[0].Certificate = []byte("other cert")
+
+	saved, err = store.GetAccount(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test@traefik.wtf", saved.Email)
+
+	certificate, err := store.GetCertificateByDomain(context.Background(), "traefik.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cert"), certificate.Certificate)
+
+	require.NoError(t, store.Update(context.Background(), func(data *StoredData) error {
+		data.StagingAccount = &Account{Email: "staging@traefik.wtf"}
+		return nil
+	}))
+	stagingAccount, err := store.GetStagingAccount(context.Background())
+	require.NoError(t, err)
+	stagingAccount.Email = "other@traefik.wtf"
+
+	stagingAccount, err = store.GetStagingAccount(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "staging@traefik.wtf", stagingAccount.Email)
+}
+
+func TestLocalStoreDoneContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	err = ioutil.WriteFile(filename, []byte(`{"Account":{"Email":"test@traefik.wtf"}}`), 0600)
+	require.NoError(t, err)
+
+	store := NewLocalStore(filename)
+	defer store.Close(context.Background())
+
+	// The storage is not loaded with a done context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = store.GetAccount(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, store.Update(ctx, func(data *StoredData) error { return nil }))
+
+	account, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test@traefik.wtf", account.Email)
+
+	// Once loaded, the data in memory is read with any context
+	account, err = store.GetAccount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "test@traefik.wtf", account.Email)
+}
+
+func TestLocalStoreSaveLoopPanic(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+	store.SetMetricsRegistry(registry)
+
+	var writes int32
+	store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		if atomic.AddInt32(&writes, 1) == 1 {
+			panic("BOOM")
+		}
+		return ioutil.WriteFile(filename, data, perm)
+	}
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "panic@traefik.wtf"}))
+	health := waitForStoreHealth(t, store, func(health *StoreHealth) bool { return health.LastSave != nil })
+	assert.Equal(t, "panic: BOOM", health.LastSave.Error)
+	assert.Equal(t, float64(1), registry.panics.CounterValue)
+	assert.Equal(t, []string{"backend", "file"}, registry.panics.LastLabelValues)
+
+	// The save loop is restarted
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoreHealth(t, store, func(health *StoreHealth) bool { return health.Healthy })
+
+	var persistedData StoredData
+	data, err := ioutil.ReadFile(store.filename)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &persistedData))
+	require.NotNil(t, persistedData.Account)
+	assert.Equal(t, "test@traefik.wtf", persistedData.Account.Email)
+}
+
+func TestLocalStoreCoalescePendingSaves(t *testing.T) {
+	store := &LocalStore{SaveDataChan: make(chan *StoredData, 3)}
+
+	for i := 1; i <= 3; i++ {
+		store.SaveDataChan <- &StoredData{Account: &Account{Email: fmt.Sprintf("test%d@traefik.wtf", i)}}
+	}
+
+	object, updates := store.coalesceSaves(&StoredData{})
+	assert.Equal(t, 4, updates)
+	assert.Equal(t, "test3@traefik.wtf", object.Account.Email)
+}
+
+func TestLocalStoreCoalesceSavesQuietPeriod(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewLocalStore(filepath.Join(dir, "acme.json"))
+	store.SaveQuietPeriod = 200 * time.Millisecond
+	store.SetMetricsRegistry(registry)
+	_, err = store.get(context.Background())
+	require.NoError(t, err)
+
+	var writes int32
+	store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		atomic.AddInt32(&writes, 1)
+		return ioutil.WriteFile(filename, data, perm)
+	}
+
+	for i := 0; i < 10; i++ {
+		domain := fmt.Sprintf("%d.traefik.wtf", i)
+		require.NoError(t, store.AddDNSChallenge(context.Background(), domain, &DNSChallengeState{Domain: domain, Token: domain}))
+	}
+
+	waitForStoreHealth(t, store, func(health *StoreHealth) bool { return health.LastSave != nil })
+	assert.Equal(t, int32(1), atomic.LoadInt32(&writes))
+	assert.Equal(t, float64(10), registry.coalesced.LastValue)
+	assert.Equal(t, []string{"backend", "file"}, registry.coalesced.LastLabelValues)
+}
+
+func TestLocalStoreFlushSaves(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	// The challenges wait for the quiet period, the account and the certificates are written right away
+	store.SaveQuietPeriod = time.Minute
+	store.MaxSaveDelay = 2 * time.Minute
+
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "traefik.wtf", &DNSChallengeState{Domain: "traefik.wtf", Token: "traefik.wtf"}))
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil
+	})
+
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate")}}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1
+	})
+}
+
+func TestLocalStoreCloseFlushesSaves(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	// The pending challenge would wait for the quiet period
+	store.SaveQuietPeriod = time.Minute
+	store.MaxSaveDelay = 2 * time.Minute
+
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "traefik.wtf", &DNSChallengeState{Domain: "traefik.wtf", Token: "traefik.wtf"}))
+	require.NoError(t, store.Close(context.Background()))
+
+	data, err := ioutil.ReadFile(store.filename)
+	require.NoError(t, err)
+	storedData := &StoredData{}
+	require.NoError(t, json.Unmarshal(data, storedData))
+	assert.Len(t, storedData.DNSChallenges, 1)
+
+	// A save after the close does not block, and a second close does nothing
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "acme.wtf", &DNSChallengeState{Domain: "acme.wtf", Token: "acme.wtf"}))
+	require.NoError(t, store.Close(context.Background()))
+}
+
+func TestLocalStoreCloseGoroutines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	goroutines := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		store := NewLocalStore(filepath.Join(dir, fmt.Sprintf("acme%d.json", i)))
+		require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+		require.NoError(t, store.Close(context.Background()))
+	}
+
+	// The save loops may still be returning from the supervision
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= goroutines, "%d goroutines left running, %d before", runtime.NumGoroutine(), goroutines)
+}
+
+func TestLocalStoreCloseTimeout(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	// A write in progress is waited for until the context is done
+	written := make(chan struct{})
+	store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		<-written
+		return nil
+	}
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, store.Close(ctx))
+
+	// The close completes once the write is done
+	close(written)
+	assert.NoError(t, store.Close(context.Background()))
+}
+
+func TestCheckSaveDelays(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		quietPeriod time.Duration
+		maxDelay    time.Duration
+		expectError bool
+	}{
+		{desc: "defaults", quietPeriod: DefaultSaveQuietPeriod},
+		{desc: "no quiet period", quietPeriod: -1},
+		{desc: "quiet period shorter than the max delay", quietPeriod: time.Second, maxDelay: 10 * time.Second},
+		{desc: "quiet period equal to the max delay", quietPeriod: time.Minute, maxDelay: time.Minute},
+		{desc: "quiet period longer than the default max delay", quietPeriod: 10 * time.Second, expectError: true},
+		{desc: "quiet period longer than the max delay", quietPeriod: 2 * time.Second, maxDelay: time.Second, expectError: true},
+		{desc: "negative max delay", maxDelay: -1, expectError: true},
+		{desc: "max delay too long", maxDelay: 2 * time.Minute, expectError: true},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkSaveDelays(test.quietPeriod, test.maxDelay)
+			if test.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// generateStorageCertificates generates certificates with the size of real ones, about 2 kB of PEM certificate and key
+func generateStorageCertificates(count int) []*Certificate {
+	var certificates []*Certificate
+	for i := 0; i < count; i++ {
+		certificates = append(certificates, &Certificate{
+			Domain:      types.Domain{Main: fmt.Sprintf("domain%d.traefik.wtf", i), SANs: []string{fmt.Sprintf("www.domain%d.traefik.wtf", i)}},
+			Certificate: bytes.Repeat([]byte{byte(i)}, 2048),
+			Key:         bytes.Repeat([]byte{byte(i)}, 1700),
+		})
+	}
+	return certificates
+}
+
+func BenchmarkStoreSave(b *testing.B) {
+	for _, count := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("%d certificates", count), func(b *testing.B) {
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(b, err)
+			defer os.RemoveAll(dir)
+
+			store := &LocalStore{filename: filepath.Join(dir, "acme.json")}
+			account := &Account{}
+			storedData := &StoredData{Account: account, Certificates: generateStorageCertificates(count)}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Each save writes different data, the unchanged data is not written again
+				account.Email = strconv.Itoa(i)
+				store.write(storedData)
+			}
+		})
+	}
+}
+
+func BenchmarkStoreLoad(b *testing.B) {
+	for _, count := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("%d certificates", count), func(b *testing.B) {
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(b, err)
+			defer os.RemoveAll(dir)
+
+			filename := filepath.Join(dir, "acme.json")
+			(&LocalStore{filename: filename}).write(&StoredData{Certificates: generateStorageCertificates(count)})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store := &LocalStore{filename: filename}
+				if _, err := store.get(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
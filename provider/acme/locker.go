@@ -0,0 +1,297 @@
+package acme
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/safe"
+)
+
+const (
+	// defaultLockTTL is the duration a lock is held without being renewed, it is renewed every third of it
+	defaultLockTTL = time.Minute
+
+	lockKeyAccount        = "account"
+	lockKeyStagingAccount = "stagingAccount"
+)
+
+// ErrLockLost is returned by the operations aborted because the lock protecting them was lost
+var ErrLockLost = errors.New("the ACME storage lock is lost")
+
+// Locker is implemented by the stores able to lock a key among the users of the storage.
+// Acquire waits for the lock of the key, held for the ttl and renewed while it is held, until the context is done.
+// The returned context is canceled when the lock is lost or released, release returns the lock.
+type Locker interface {
+	Acquire(ctx context.Context, key string, ttl time.Duration) (held context.Context, release func(), err error)
+}
+
+// lease is the record of a lock, held by its owner until its expiry. The record is kept once released,
+// for its fencing token to increase on every acquisition.
+type lease struct {
+	Owner  string
+	Token  uint64
+	Expiry time.Time
+}
+
+func (l *lease) isHeld(now time.Time) bool {
+	return l != nil && now.Before(l.Expiry)
+}
+
+func (l *lease) equal(other *lease) bool {
+	if l == nil || other == nil {
+		return l == other
+	}
+	return l.Owner == other.Owner && l.Token == other.Token && l.Expiry.Equal(other.Expiry)
+}
+
+// leaseBackend holds the lease records of a backend, the lock primitive of a backend is its compare-and-swap
+type leaseBackend interface {
+	getLease(key string) (*lease, error)
+	// compareAndSwapLease replaces the lease of the key by the next one, if it is still the current one
+	compareAndSwapLease(key string, current, next *lease) (bool, error)
+}
+
+// leaseLocker implements the TTL-based locks with the lease records of a backend
+type leaseLocker struct {
+	backend leaseBackend
+	owner   string
+	// retryInterval is the interval between two attempts to acquire a held lock, the third of the ttl at most
+	retryInterval time.Duration
+}
+
+func newLeaseLocker(backend leaseBackend) *leaseLocker {
+	return &leaseLocker{backend: backend, owner: newLockOwner(), retryInterval: time.Second}
+}
+
+func newLockOwner() string {
+	owner := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, owner); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(owner)
+}
+
+// Acquire waits for the lock of the key and renews it until it is released
+func (l *leaseLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (context.Context, func(), error) {
+	acquired, err := l.acquire(ctx, key, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	held, cancel := context.WithCancel(ctx)
+	released := make(chan struct{})
+	done := make(chan struct{})
+	safe.Go(func() {
+		defer close(done)
+		defer cancel()
+		l.renew(key, acquired, ttl, released)
+	})
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			close(released)
+			<-done
+		})
+	}
+	return held, release, nil
+}
+
+// acquire takes the lease of the key once it is not held, until the context is done
+func (l *leaseLocker) acquire(ctx context.Context, key string, ttl time.Duration) (*lease, error) {
+	retryInterval := l.retryInterval
+	if retryInterval > ttl/3 {
+		retryInterval = ttl / 3
+	}
+
+	for {
+		current, err := l.backend.getLease(key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the lock %s: %v", key, err)
+		}
+
+		now := time.Now()
+		if !current.isHeld(now) {
+			next := &lease{Owner: l.owner, Expiry: now.Add(ttl)}
+			if current != nil {
+				next.Token = current.Token + 1
+			}
+
+			swapped, err := l.backend.compareAndSwapLease(key, current, next)
+			if err != nil {
+				return nil, fmt.Errorf("unable to acquire the lock %s: %v", key, err)
+			}
+			if swapped {
+				return next, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// renew extends the lease every third of the ttl until it is lost, or released and returned
+func (l *leaseLocker) renew(key string, held *lease, ttl time.Duration, released <-chan struct{}) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-released:
+			l.release(key, held)
+			return
+		case <-ticker.C:
+			next, err := l.extend(key, held, ttl)
+			switch {
+			case err == ErrLockLost:
+				logger().Warnf("The ACME storage lock %s is lost, the operation it protects is aborted.", key)
+				return
+			case err != nil:
+				// The lease is renewed again on the next tick, until it expires
+				if !time.Now().Before(held.Expiry) {
+					logger().Warnf("The ACME storage lock %s expired, the operation it protects is aborted: %v", key, err)
+					return
+				}
+				logger().Debugf("Unable to renew the ACME storage lock %s: %v", key, err)
+			default:
+				held = next
+			}
+		}
+	}
+}
+
+func (l *leaseLocker) extend(key string, held *lease, ttl time.Duration) (*lease, error) {
+	current, err := l.backend.getLease(key)
+	if err != nil {
+		return nil, err
+	}
+	if !current.equal(held) || !current.isHeld(time.Now()) {
+		return nil, ErrLockLost
+	}
+
+	next := &lease{Owner: held.Owner, Token: held.Token, Expiry: time.Now().Add(ttl)}
+	swapped, err := l.backend.compareAndSwapLease(key, current, next)
+	if err != nil {
+		return nil, err
+	}
+	if !swapped {
+		return nil, ErrLockLost
+	}
+	return next, nil
+}
+
+func (l *leaseLocker) release(key string, held *lease) {
+	current, err := l.backend.getLease(key)
+	if err != nil || !current.equal(held) {
+		return
+	}
+
+	next := &lease{Owner: held.Owner, Token: held.Token}
+	if _, err := l.backend.compareAndSwapLease(key, current, next); err != nil {
+		logger().Debugf("Unable to release the ACME storage lock %s, it expires: %v", key, err)
+	}
+}
+
+// memoryLeases holds the lease records in memory, for the stores of this instance
+type memoryLeases struct {
+	lock   sync.Mutex
+	leases map[string]lease
+}
+
+func (m *memoryLeases) getLease(key string) (*lease, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	current, ok := m.leases[key]
+	if !ok {
+		return nil, nil
+	}
+	return &current, nil
+}
+
+func (m *memoryLeases) compareAndSwapLease(key string, current, next *lease) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stored, ok := m.leases[key]
+	if ok && !current.equal(&stored) || !ok && current != nil {
+		return false, nil
+	}
+
+	if m.leases == nil {
+		m.leases = make(map[string]lease)
+	}
+	m.leases[key] = *next
+	return true, nil
+}
+
+// fileLeases are the lease records of the storage files, shared by the stores of the same file in this instance.
+// A storage file is not shared with other instances, its locks do not need to be.
+var fileLeases = struct {
+	lock   sync.Mutex
+	leases map[string]*memoryLeases
+}{leases: make(map[string]*memoryLeases)}
+
+func getFileLeases(filename string) *memoryLeases {
+	if absolute, err := filepath.Abs(filename); err == nil {
+		filename = absolute
+	}
+
+	fileLeases.lock.Lock()
+	defer fileLeases.lock.Unlock()
+
+	leases, ok := fileLeases.leases[filename]
+	if !ok {
+		leases = &memoryLeases{}
+		fileLeases.leases[filename] = leases
+	}
+	return leases
+}
+
+// Acquire locks the key among the stores of the storage file in this instance
+func (s *LocalStore) Acquire(ctx context.Context, key string, ttl time.Duration) (context.Context, func(), error) {
+	s.lockerOnce.Do(func() {
+		s.locker = newLeaseLocker(getFileLeases(s.filename))
+	})
+	return s.locker.Acquire(ctx, key, ttl)
+}
+
+// getCertificateLockKey returns the key of the lock of the issuance of the domains
+func getCertificateLockKey(domains []string) string {
+	return "certificates/" + strings.Join(domains, ",")
+}
+
+// lock acquires the lock of the key when the store is able to lock, the lock is not needed otherwise.
+// The returned context is canceled once the lock is lost.
+func (p *Provider) lock(key string) (context.Context, func(), error) {
+	locker, ok := unwrapStore(p.Store).(Locker)
+	if !ok {
+		return p.getContext(), func() {}, nil
+	}
+
+	held, release, err := locker.Acquire(p.getContext(), key, defaultLockTTL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to lock the ACME storage for %s: %v", key, err)
+	}
+	return held, release, nil
+}
+
+// checkLockHeld returns ErrLockLost once the lock protecting the operation is lost
+func checkLockHeld(held context.Context) error {
+	if held.Err() != nil {
+		return ErrLockLost
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package acme
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreLockMutualExclusion(t *testing.T) {
+	first, clean := newTestLocalStore(t)
+	defer clean()
+
+	// Two stores of the same storage file compete for the same lock
+	second := NewLocalStore(first.filename)
+	defer second.Close(context.Background())
+
+	var holders, violations, acquisitions int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		store := first
+		if i%2 == 1 {
+			store = second
+		}
+
+		wg.Add(1)
+		go func(store *LocalStore) {
+			defer wg.Done()
+
+			for j := 0; j < 5; j++ {
+				held, release, err := store.Acquire(context.Background(), "certificates/traefik.wtf", 90*time.Millisecond)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				if atomic.AddInt32(&holders, 1) > 1 {
+					atomic.AddInt32(&violations, 1)
+				}
+				atomic.AddInt32(&acquisitions, 1)
+				time.Sleep(time.Millisecond)
+				assert.NoError(t, held.Err())
+				atomic.AddInt32(&holders, -1)
+
+				release()
+			}
+		}(store)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(0), violations)
+	assert.Equal(t, int32(100), acquisitions)
+
+	current, err := getFileLeases(first.filename).getLease("certificates/traefik.wtf")
+	require.NoError(t, err)
+	require.NotNil(t, current)
+	// The fencing token increases on every acquisition
+	assert.Equal(t, uint64(99), current.Token)
+	assert.False(t, current.isHeld(time.Now()))
+}
+
+func TestLeaseLockerRenewal(t *testing.T) {
+	backend := &memoryLeases{}
+	locker := newLeaseLocker(backend)
+
+	held, release, err := locker.Acquire(context.Background(), "account", 30*time.Millisecond)
+	require.NoError(t, err)
+
+	// The lock is held beyond its ttl while it is renewed
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, held.Err())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = newLeaseLocker(backend).Acquire(ctx, "account", 30*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	release()
+	assert.Error(t, held.Err())
+
+	_, releaseAgain, err := newLeaseLocker(backend).Acquire(context.Background(), "account", 30*time.Millisecond)
+	require.NoError(t, err)
+	releaseAgain()
+}
+
+func TestLeaseLockerLockLost(t *testing.T) {
+	backend := &memoryLeases{}
+	locker := newLeaseLocker(backend)
+
+	held, release, err := locker.Acquire(context.Background(), "account", 30*time.Millisecond)
+	require.NoError(t, err)
+	defer release()
+
+	// The lease is taken by another owner, as when the lease expired while this instance was paused
+	current, err := backend.getLease("account")
+	require.NoError(t, err)
+	swapped, err := backend.compareAndSwapLease("account", current, &lease{Owner: "other", Token: current.Token + 1, Expiry: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	require.True(t, swapped)
+
+	select {
+	case <-held.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the loss of the lock is not detected")
+	}
+	assert.Equal(t, ErrLockLost, checkLockHeld(held))
+
+	// The lease of the other owner is not released
+	release()
+	current, err = backend.getLease("account")
+	require.NoError(t, err)
+	assert.Equal(t, "other", current.Owner)
+	assert.True(t, current.isHeld(time.Now()))
+}
+
+// notLockingStore is a store unable to lock
+type notLockingStore struct {
+	Store
+}
+
+func TestProviderLockWithoutLocker(t *testing.T) {
+	p := &Provider{Store: &notLockingStore{}}
+
+	held, release, err := p.lock(lockKeyAccount)
+	require.NoError(t, err)
+	defer release()
+	assert.NoError(t, checkLockHeld(held))
+}
@@ -0,0 +1,57 @@
+package acme
+
+import (
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/sirupsen/logrus"
+)
+
+// Fields of the ACME logs, to correlate the entries of a domain or of a storage
+const (
+	logFieldProviderName  = "providerName"
+	logFieldDomains       = "domains"
+	logFieldChallengeType = "challengeType"
+	logFieldToken         = "token"
+	logFieldStoreBackend  = "storeBackend"
+	logFieldStoreMethod   = "storeMethod"
+	logFieldStorage       = "storage"
+	logFieldOperation     = "operation"
+	logFieldNamespace     = "namespace"
+	logFieldSecret        = "secret"
+	logFieldEvent         = "event"
+
+	providerName = "acme"
+)
+
+// logger returns the logger of the ACME provider
+func logger() *logrus.Entry {
+	return log.WithField(logFieldProviderName, providerName)
+}
+
+// domainsLogger returns the logger of the ACME operations on the domains
+func domainsLogger(domains []string) *logrus.Entry {
+	return logger().WithField(logFieldDomains, strings.Join(domains, ","))
+}
+
+// challengeLogger returns the logger of the challenge of the domain
+func challengeLogger(challengeType, domain string) *logrus.Entry {
+	return logger().WithFields(logrus.Fields{
+		logFieldChallengeType: challengeType,
+		logFieldDomains:       domain,
+	})
+}
+
+// logger returns the logger of the operation on the storage file
+func (s *LocalStore) logger(operation string) *logrus.Entry {
+	return logger().WithFields(logrus.Fields{
+		logFieldStoreBackend: getStoreBackend(s),
+		logFieldStorage:      s.filename,
+		logFieldOperation:    operation,
+	})
+}
+
+// secretsLogger returns the logger of the operation on the certificate Secrets
+func (s *LocalStore) secretsLogger(operation string) *logrus.Entry {
+	return s.logger(operation).WithField(logFieldNamespace, s.CertificateSecrets.Namespace)
+}
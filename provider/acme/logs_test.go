@@ -0,0 +1,87 @@
+package acme
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/containous/traefik/log"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collectingHook struct {
+	lock    sync.Mutex
+	entries []*logrus.Entry
+}
+
+func (h *collectingHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *collectingHook) Fire(entry *logrus.Entry) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.entries = append(h.entries, entry)
+	return nil
+}
+
+// findEntry returns the fields of the first entry with the message
+func (h *collectingHook) findEntry(message string) logrus.Fields {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, entry := range h.entries {
+		if entry.Message == message {
+			return entry.Data
+		}
+	}
+	return nil
+}
+
+// collectLogs collects the entries of the Traefik logger, at the debug level, until the returned function is called
+func collectLogs() (*collectingHook, func()) {
+	hook := &collectingHook{}
+
+	standardLogger := logrus.StandardLogger()
+	hooks, level := standardLogger.Hooks, log.GetLevel()
+
+	standardLogger.Hooks = make(logrus.LevelHooks)
+	standardLogger.Hooks.Add(hook)
+	log.SetLevel(logrus.DebugLevel)
+
+	return hook, func() {
+		standardLogger.Hooks = hooks
+		log.SetLevel(level)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	hook, restore := collectLogs()
+	defer restore()
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.CheckPermissions())
+	fields := hook.findEntry("ACME storage permissions:")
+	require.NotNil(t, fields)
+	assert.Equal(t, providerName, fields[logFieldProviderName])
+	assert.Equal(t, "file", fields[logFieldStoreBackend])
+	assert.Equal(t, store.filename, fields[logFieldStorage])
+	assert.Equal(t, storeOperationCheckPermissions, fields[logFieldOperation])
+
+	challenge := &challengeTLSALPN{Store: store}
+	require.NoError(t, challenge.CleanUp("traefik.wtf", "token", "keyAuth"))
+	fields = hook.findEntry("TLS Challenge CleanUp temp certificate for traefik.wtf")
+	require.NotNil(t, fields)
+	assert.Equal(t, providerName, fields[logFieldProviderName])
+	assert.Equal(t, challengeTypeTLSALPN01, fields[logFieldChallengeType])
+	assert.Equal(t, "traefik.wtf", fields[logFieldDomains])
+
+	searchUncheckedDomains([]string{"traefik.wtf", "www.traefik.wtf"}, []string{"traefik.wtf"})
+	fields = hook.findEntry(`Domains ["traefik.wtf" "www.traefik.wtf"] need ACME certificates generation for domains "www.traefik.wtf".`)
+	require.NotNil(t, fields)
+	assert.Equal(t, "traefik.wtf,www.traefik.wtf", fields[logFieldDomains])
+}
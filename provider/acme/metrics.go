@@ -0,0 +1,82 @@
+package acme
+
+import (
+	"context"
+	"github.com/containous/traefik/metrics"
+)
+
+const (
+	challengeTypeHTTP01    = "http-01"
+	challengeTypeTLSALPN01 = "tls-alpn-01"
+	challengeTypeDNS01     = "dns-01"
+
+	challengeOutcomeCreated = "created"
+	challengeOutcomeSolved  = "solved"
+	challengeOutcomeFailed  = "failed"
+	challengeOutcomeExpired = "expired"
+)
+
+// metricsStore is implemented by the stores reporting their own metrics
+type metricsStore interface {
+	SetMetricsRegistry(registry metrics.Registry)
+}
+
+// SetMetricsRegistry sets the registry used to report the ACME challenges, issuances and storage metrics,
+// and wraps the store again to report its operations
+func (p *Provider) SetMetricsRegistry(registry metrics.Registry) {
+	p.metricsRegistry = registry
+	p.timings = newIssuanceTimings(registry)
+
+	store := unwrapStore(p.Store)
+	if s, ok := store.(metricsStore); ok {
+		s.SetMetricsRegistry(registry)
+	}
+
+	if store != nil {
+		p.Store = WrapStore(store, p.getStoreWrapOptions(registry))
+	}
+}
+
+func countChallenges(registry metrics.Registry, challengeType, outcome string, count int) {
+	if registry == nil || count <= 0 {
+		return
+	}
+
+	registry.ACMEChallengesCounter().With("type", challengeType, "outcome", outcome).Add(float64(count))
+}
+
+func countStorageSignatureFailures(registry metrics.Registry, reason string, count int) {
+	if registry == nil || count <= 0 {
+		return
+	}
+
+	registry.ACMESignatureFailuresCounter().With("reason", reason).Add(float64(count))
+}
+
+// updatePendingChallenges sets the pending challenges gauge from the challenges in the store
+func updatePendingChallenges(ctx context.Context, registry metrics.Registry, store Store) {
+	if registry == nil || !registry.IsEnabled() {
+		return
+	}
+
+	httpChallenges, err := store.GetHTTPChallenges(ctx)
+	if err != nil {
+		logger().WithField(logFieldChallengeType, challengeTypeHTTP01).Errorf("Unable to get the pending HTTP challenges: %v", err)
+	} else {
+		registry.ACMEPendingChallengesGauge().With("type", challengeTypeHTTP01).Set(float64(len(httpChallenges)))
+	}
+
+	tlsChallenges, err := store.GetTLSChallenges(ctx)
+	if err != nil {
+		logger().WithField(logFieldChallengeType, challengeTypeTLSALPN01).Errorf("Unable to get the pending TLS challenges: %v", err)
+	} else {
+		registry.ACMEPendingChallengesGauge().With("type", challengeTypeTLSALPN01).Set(float64(len(tlsChallenges)))
+	}
+
+	dnsChallenges, err := store.GetDNSChallenges(ctx)
+	if err != nil {
+		logger().WithField(logFieldChallengeType, challengeTypeDNS01).Errorf("Unable to get the pending DNS challenges: %v", err)
+	} else {
+		registry.ACMEPendingChallengesGauge().With("type", challengeTypeDNS01).Set(float64(len(dnsChallenges)))
+	}
+}
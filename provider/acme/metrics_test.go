@@ -0,0 +1,243 @@
+package acme
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containous/traefik/metrics"
+	"github.com/containous/traefik/testhelpers"
+	kitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collectingACMEMetrics struct {
+	metrics.Registry
+	challenges *testhelpers.CollectingCounter
+	pending    *testhelpers.CollectingGauge
+	signatures *testhelpers.CollectingCounter
+	operations *testhelpers.CollectingCounter
+	failures   *testhelpers.CollectingCounter
+	durations  *testhelpers.CollectingHistogram
+	lastSave   *testhelpers.CollectingGauge
+	issuances  *testhelpers.CollectingHistogram
+	notIssued  *testhelpers.CollectingHistogram
+	hooks      *testhelpers.CollectingCounter
+	payload    *testhelpers.CollectingGauge
+	rejected   *testhelpers.CollectingCounter
+	panics     *testhelpers.CollectingCounter
+	drifts     *testhelpers.CollectingCounter
+	coalesced  *testhelpers.CollectingHistogram
+	ctFailures *testhelpers.CollectingCounter
+	k8sReads   *testhelpers.CollectingCounter
+	replLag    *testhelpers.CollectingGauge
+	degraded   *testhelpers.CollectingGauge
+	mismatches *testhelpers.CollectingCounter
+	skipped    *testhelpers.CollectingCounter
+	expiry     *testhelpers.CollectingGauge
+	verifies   *testhelpers.CollectingCounter
+	canary     *testhelpers.CollectingGauge
+}
+
+func newCollectingACMEMetrics() *collectingACMEMetrics {
+	return &collectingACMEMetrics{
+		Registry:   metrics.NewVoidRegistry(),
+		challenges: &testhelpers.CollectingCounter{},
+		pending:    &testhelpers.CollectingGauge{},
+		signatures: &testhelpers.CollectingCounter{},
+		operations: &testhelpers.CollectingCounter{},
+		failures:   &testhelpers.CollectingCounter{},
+		durations:  &testhelpers.CollectingHistogram{},
+		lastSave:   &testhelpers.CollectingGauge{},
+		issuances:  &testhelpers.CollectingHistogram{},
+		notIssued:  &testhelpers.CollectingHistogram{},
+		hooks:      &testhelpers.CollectingCounter{},
+		payload:    &testhelpers.CollectingGauge{},
+		rejected:   &testhelpers.CollectingCounter{},
+		panics:     &testhelpers.CollectingCounter{},
+		drifts:     &testhelpers.CollectingCounter{},
+		coalesced:  &testhelpers.CollectingHistogram{},
+		ctFailures: &testhelpers.CollectingCounter{},
+		k8sReads:   &testhelpers.CollectingCounter{},
+		replLag:    &testhelpers.CollectingGauge{},
+		degraded:   &testhelpers.CollectingGauge{},
+		mismatches: &testhelpers.CollectingCounter{},
+		skipped:    &testhelpers.CollectingCounter{},
+		expiry:     &testhelpers.CollectingGauge{},
+		verifies:   &testhelpers.CollectingCounter{},
+		canary:     &testhelpers.CollectingGauge{},
+	}
+}
+
+func (m *collectingACMEMetrics) IsEnabled() bool {
+	return true
+}
+
+func (m *collectingACMEMetrics) ACMEChallengesCounter() kitmetrics.Counter {
+	return m.challenges
+}
+
+func (m *collectingACMEMetrics) ACMEPendingChallengesGauge() kitmetrics.Gauge {
+	return m.pending
+}
+
+func (m *collectingACMEMetrics) ACMESignatureFailuresCounter() kitmetrics.Counter {
+	return m.signatures
+}
+
+func (m *collectingACMEMetrics) ACMEStoreOperationsCounter() kitmetrics.Counter {
+	return m.operations
+}
+
+func (m *collectingACMEMetrics) ACMEStoreFailuresCounter() kitmetrics.Counter {
+	return m.failures
+}
+
+func (m *collectingACMEMetrics) ACMEStoreSaveDurationHistogram() kitmetrics.Histogram {
+	return m.durations
+}
+
+func (m *collectingACMEMetrics) ACMEStoreLastSaveGauge() kitmetrics.Gauge {
+	return m.lastSave
+}
+
+func (m *collectingACMEMetrics) ACMEIssuanceDurationHistogram() kitmetrics.Histogram {
+	return m.issuances
+}
+
+func (m *collectingACMEMetrics) ACMEIssuanceFailureHistogram() kitmetrics.Histogram {
+	return m.notIssued
+}
+
+func (m *collectingACMEMetrics) ACMEHookFailuresCounter() kitmetrics.Counter {
+	return m.hooks
+}
+
+func (m *collectingACMEMetrics) ACMEStorePayloadSizeGauge() kitmetrics.Gauge {
+	return m.payload
+}
+
+func (m *collectingACMEMetrics) ACMEStoreRejectedWritesCounter() kitmetrics.Counter {
+	return m.rejected
+}
+
+func (m *collectingACMEMetrics) ACMEStorePanicsCounter() kitmetrics.Counter {
+	return m.panics
+}
+
+func (m *collectingACMEMetrics) ACMEStorageDriftCounter() kitmetrics.Counter {
+	return m.drifts
+}
+
+func (m *collectingACMEMetrics) ACMEStoreCoalescedUpdatesHistogram() kitmetrics.Histogram {
+	return m.coalesced
+}
+
+func (m *collectingACMEMetrics) ACMECTFailuresCounter() kitmetrics.Counter {
+	return m.ctFailures
+}
+
+func (m *collectingACMEMetrics) ACMEStoreKubernetesReadsCounter() kitmetrics.Counter {
+	return m.k8sReads
+}
+
+func (m *collectingACMEMetrics) ACMEStoreReplicationLagGauge() kitmetrics.Gauge {
+	return m.replLag
+}
+
+func (m *collectingACMEMetrics) ACMEStoreDegradedGauge() kitmetrics.Gauge {
+	return m.degraded
+}
+
+func (m *collectingACMEMetrics) ACMEStoreMismatchesCounter() kitmetrics.Counter {
+	return m.mismatches
+}
+
+func (m *collectingACMEMetrics) ACMEStoreSkippedWritesCounter() kitmetrics.Counter {
+	return m.skipped
+}
+
+func (m *collectingACMEMetrics) ACMECertificateExpiryGauge() kitmetrics.Gauge {
+	return m.expiry
+}
+
+func (m *collectingACMEMetrics) ACMECertificateVerificationFailuresCounter() kitmetrics.Counter {
+	return m.verifies
+}
+
+func (m *collectingACMEMetrics) ACMEStoreCanaryGauge() kitmetrics.Gauge {
+	return m.canary
+}
+
+func TestChallengeHTTPMetrics(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+	store := &LocalStore{storedData: &StoredData{}}
+	challenge := &challengeHTTP{Store: store, metricsRegistry: registry}
+
+	require.NoError(t, challenge.Present("traefik.wtf", "token", "keyAuth"))
+	assert.Equal(t, float64(1), registry.challenges.CounterValue)
+	assert.Equal(t, []string{"type", challengeTypeHTTP01, "outcome", challengeOutcomeCreated}, registry.challenges.LastLabelValues)
+
+	httpChallenges, err := store.GetHTTPChallenges(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, httpChallenges, 1)
+
+	require.NoError(t, challenge.CleanUp("traefik.wtf", "token", "keyAuth"))
+	assert.Equal(t, float64(1), registry.challenges.CounterValue)
+	assert.Equal(t, float64(0), registry.pending.GaugeValue)
+}
+
+func TestGetChallengeType(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		conf     *Configuration
+		expected string
+	}{
+		{
+			desc: "DNS challenge takes precedence",
+			conf: &Configuration{
+				DNSChallenge:  &DNSChallenge{Provider: "manual"},
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				TLSChallenge:  &TLSChallenge{},
+			},
+			expected: challengeTypeDNS01,
+		},
+		{
+			desc: "TLS challenge takes precedence over HTTP challenge",
+			conf: &Configuration{
+				HTTPChallenge: &HTTPChallenge{EntryPoint: "http"},
+				TLSChallenge:  &TLSChallenge{},
+			},
+			expected: challengeTypeTLSALPN01,
+		},
+		{
+			desc: "no challenge",
+			conf: &Configuration{},
+		},
+		{
+			desc:     "DNS challenge",
+			conf:     &Configuration{DNSChallenge: &DNSChallenge{Provider: "manual"}},
+			expected: challengeTypeDNS01,
+		},
+		{
+			desc:     "HTTP challenge",
+			conf:     &Configuration{HTTPChallenge: &HTTPChallenge{EntryPoint: "http"}},
+			expected: challengeTypeHTTP01,
+		},
+		{
+			desc:     "TLS challenge",
+			conf:     &Configuration{TLSChallenge: &TLSChallenge{}},
+			expected: challengeTypeTLSALPN01,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			p := &Provider{Configuration: test.conf}
+			assert.Equal(t, test.expected, p.getChallengeType())
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package acme
+
+import (
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/xenolf/lego/acme"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspStapleGetter returns the DER encoded OCSP response of a PEM encoded certificate bundle
+type ocspStapleGetter func(bundle []byte) ([]byte, *ocsp.Response, error)
+
+var getOCSPStaple ocspStapleGetter = acme.GetOCSPForCert
+
+// isMustStaple returns whether the certificate of the given domain is issued with the OCSP Must-Staple extension
+// An exact domain or a wildcard one matches
+func (p *Provider) isMustStaple(domain types.Domain) bool {
+	if OSCPMustStaple {
+		return true
+	}
+
+	main := types.CanonicalDomain(domain.Main)
+	for _, mustStapleDomain := range p.DomainsMustStaple {
+		mustStapleDomain = types.CanonicalDomain(mustStapleDomain)
+		if mustStapleDomain == main || types.MatchDomain(main, mustStapleDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOCSPStapleValid checks that the OCSP response stapled to a certificate reports it as good, and is not outdated
+func isOCSPStapleValid(staple []byte, now time.Time) bool {
+	if len(staple) == 0 {
+		return false
+	}
+
+	response, err := ocsp.ParseResponse(staple, nil)
+	if err != nil {
+		return false
+	}
+
+	return response.Status == ocsp.Good && (response.NextUpdate.IsZero() || now.Before(response.NextUpdate))
+}
+
+// isOCSPStapleRefreshDue checks whether the OCSP response is invalid, or in the second half of its validity
+func isOCSPStapleRefreshDue(staple []byte, now time.Time) bool {
+	if !isOCSPStapleValid(staple, now) {
+		return true
+	}
+
+	response, err := ocsp.ParseResponse(staple, nil)
+	if err != nil || response.NextUpdate.IsZero() {
+		return true
+	}
+
+	return now.After(response.ThisUpdate.Add(response.NextUpdate.Sub(response.ThisUpdate) / 2))
+}
+
+// refreshOCSPStaple fetches the OCSP response of a Must-Staple certificate when its refresh is due,
+// and returns whether it has changed. A failure keeps the previous response, which may still be valid.
+func refreshOCSPStaple(certificate *Certificate, now time.Time) bool {
+	if !certificate.MustStaple || !isOCSPStapleRefreshDue(certificate.OCSPStaple, now) {
+		return false
+	}
+
+	staple, response, err := getOCSPStaple(certificate.Certificate)
+	if err != nil {
+		domainsLogger(certificate.Domain.ToStrArray()).Errorf("Unable to get the OCSP response of the certificate for domains %v: %v", certificate.Domain.ToStrArray(), err)
+		return false
+	}
+	if response == nil || response.Status != ocsp.Good {
+		domainsLogger(certificate.Domain.ToStrArray()).Errorf("The OCSP response of the certificate for domains %v does not report it as good.", certificate.Domain.ToStrArray())
+		return false
+	}
+
+	certificate.OCSPStaple = staple
+	return true
+}
+
+// refreshOCSPStaples fetches the OCSP responses of the Must-Staple certificates when their refresh is due,
+// and returns whether one has changed
+func refreshOCSPStaples(certificates []*Certificate, now time.Time) bool {
+	changed := false
+	for _, certificate := range certificates {
+		if refreshOCSPStaple(certificate, now) {
+			changed = true
+		}
+	}
+	return changed
+}
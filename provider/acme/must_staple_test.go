@@ -0,0 +1,155 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/tls/generate"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+func generateTestOCSPStaple(t *testing.T, status int, thisUpdate, nextUpdate time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: thisUpdate, NotAfter: nextUpdate}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	issuer, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	staple, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{Status: status, SerialNumber: big.NewInt(2), ThisUpdate: thisUpdate, NextUpdate: nextUpdate}, key)
+	require.NoError(t, err)
+	return staple
+}
+
+func TestIsMustStaple(t *testing.T) {
+	provider := &Provider{Configuration: &Configuration{DomainsMustStaple: []string{"secure.traefik.wtf", "*.payments.traefik.wtf"}}}
+
+	assert.True(t, provider.isMustStaple(types.Domain{Main: "secure.traefik.wtf"}))
+	assert.True(t, provider.isMustStaple(types.Domain{Main: "eu.payments.traefik.wtf"}))
+	assert.False(t, provider.isMustStaple(types.Domain{Main: "traefik.wtf"}))
+}
+
+func TestIsOCSPStapleValid(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		desc          string
+		staple        []byte
+		expectedValid bool
+		expectedDue   bool
+	}{
+		{
+			desc:        "no staple",
+			expectedDue: true,
+		},
+		{
+			desc:        "malformed staple",
+			staple:      []byte("staple"),
+			expectedDue: true,
+		},
+		{
+			desc:          "good staple",
+			staple:        generateTestOCSPStaple(t, ocsp.Good, now.Add(-time.Hour), now.Add(7*24*time.Hour)),
+			expectedValid: true,
+		},
+		{
+			desc:          "good staple in the second half of its validity",
+			staple:        generateTestOCSPStaple(t, ocsp.Good, now.Add(-5*24*time.Hour), now.Add(2*24*time.Hour)),
+			expectedValid: true,
+			expectedDue:   true,
+		},
+		{
+			desc:        "outdated staple",
+			staple:      generateTestOCSPStaple(t, ocsp.Good, now.Add(-8*24*time.Hour), now.Add(-time.Hour)),
+			expectedDue: true,
+		},
+		{
+			desc:        "revoked certificate",
+			staple:      generateTestOCSPStaple(t, ocsp.Revoked, now.Add(-time.Hour), now.Add(7*24*time.Hour)),
+			expectedDue: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expectedValid, isOCSPStapleValid(test.staple, now))
+			assert.Equal(t, test.expectedDue, isOCSPStapleRefreshDue(test.staple, now))
+		})
+	}
+}
+
+func TestRefreshOCSPStaple(t *testing.T) {
+	now := time.Now()
+	staple := generateTestOCSPStaple(t, ocsp.Good, now.Add(-time.Hour), now.Add(7*24*time.Hour))
+
+	defer func(get ocspStapleGetter) { getOCSPStaple = get }(getOCSPStaple)
+	var err error
+	getOCSPStaple = func(bundle []byte) ([]byte, *ocsp.Response, error) {
+		if err != nil {
+			return nil, nil, err
+		}
+		return staple, &ocsp.Response{Status: ocsp.Good}, nil
+	}
+
+	// The OCSP responses are only fetched for the Must-Staple certificates
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}}
+	assert.False(t, refreshOCSPStaple(certificate, now))
+	assert.Empty(t, certificate.OCSPStaple)
+
+	certificate.MustStaple = true
+	assert.True(t, refreshOCSPStaple(certificate, now))
+	assert.Equal(t, staple, certificate.OCSPStaple)
+
+	// The response is not fetched again while it is fresh
+	assert.False(t, refreshOCSPStaple(certificate, now))
+
+	// A failure keeps the previous response
+	err = errors.New("OCSP responder unavailable")
+	assert.False(t, refreshOCSPStaple(certificate, now.Add(5*24*time.Hour)))
+	assert.Equal(t, staple, certificate.OCSPStaple)
+}
+
+func TestRefreshCertificatesMustStaple(t *testing.T) {
+	now := time.Now()
+	certPEM, keyPEM, err := generate.KeyPair("traefik.wtf", now.Add(90*24*time.Hour))
+	require.NoError(t, err)
+
+	configurationChan := make(chan types.ConfigMessage, 1)
+	provider := &Provider{
+		Configuration:     &Configuration{EntryPoint: "https"},
+		configurationChan: configurationChan,
+		certificates: []*Certificate{
+			{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: certPEM, Key: keyPEM},
+			{Domain: types.Domain{Main: "secure.traefik.wtf"}, Certificate: certPEM, Key: keyPEM, MustStaple: true},
+			{
+				Domain:      types.Domain{Main: "stapled.traefik.wtf"},
+				Certificate: certPEM,
+				Key:         keyPEM,
+				MustStaple:  true,
+				OCSPStaple:  generateTestOCSPStaple(t, ocsp.Good, now.Add(-time.Hour), now.Add(7*24*time.Hour)),
+			},
+		},
+	}
+
+	provider.refreshCertificates()
+
+	// The Must-Staple certificate without OCSP response is not served
+	config := <-configurationChan
+	require.Len(t, config.Configuration.TLS, 2)
+	assert.Empty(t, config.Configuration.TLS[0].Certificate.OCSPStaple)
+	assert.NotEmpty(t, config.Configuration.TLS[1].Certificate.OCSPStaple)
+}
@@ -0,0 +1,136 @@
+package acme
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+)
+
+// defaultOnDemandQueueTTL is the duration after which a queued domain which has not been requested again is dropped
+const defaultOnDemandQueueTTL = 24 * time.Hour
+
+// OnDemandRequest is a domain requested on demand, queued in the store until its certificate is obtained
+type OnDemandRequest struct {
+	Domain           string
+	FirstRequestedAt time.Time
+	LastRequestedAt  time.Time
+	Requests         int
+}
+
+// QueuedDomain describes a domain of the on demand queue
+type QueuedDomain struct {
+	Domain           string    `json:"domain"`
+	FirstRequestedAt time.Time `json:"firstRequestedAt"`
+	LastRequestedAt  time.Time `json:"lastRequestedAt"`
+	Requests         int       `json:"requests"`
+}
+
+func (p *Provider) getOnDemandQueueTTL() time.Duration {
+	if p.OnDemandQueueTTL > 0 {
+		return time.Duration(p.OnDemandQueueTTL)
+	}
+	return defaultOnDemandQueueTTL
+}
+
+// GetOnDemandQueue returns the domains queued on demand, the most requested first
+func (p *Provider) GetOnDemandQueue(ctx context.Context) ([]*QueuedDomain, error) {
+	queue, err := p.Store.GetOnDemandQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []*QueuedDomain
+	for _, request := range sortOnDemandQueue(queue) {
+		domains = append(domains, &QueuedDomain{
+			Domain:           request.Domain,
+			FirstRequestedAt: request.FirstRequestedAt,
+			LastRequestedAt:  request.LastRequestedAt,
+			Requests:         request.Requests,
+		})
+	}
+	return domains, nil
+}
+
+// sortOnDemandQueue returns the requests of the queue, the most requested first, then the oldest
+func sortOnDemandQueue(queue map[string]*OnDemandRequest) []*OnDemandRequest {
+	var requests []*OnDemandRequest
+	for _, request := range queue {
+		requests = append(requests, request)
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].Requests != requests[j].Requests {
+			return requests[i].Requests > requests[j].Requests
+		}
+		if !requests[i].FirstRequestedAt.Equal(requests[j].FirstRequestedAt) {
+			return requests[i].FirstRequestedAt.Before(requests[j].FirstRequestedAt)
+		}
+		return requests[i].Domain < requests[j].Domain
+	})
+
+	return requests
+}
+
+// queueOnDemandDomain records the request of a domain in the on demand queue, until its certificate is obtained
+func (p *Provider) queueOnDemandDomain(domain string) {
+	if err := p.Store.AddOnDemandRequest(p.getContext(), domain, time.Now()); err != nil && err != ErrReadOnly {
+		domainsLogger([]string{domain}).Errorf("Unable to queue the on demand domain %s: %v", domain, err)
+	}
+}
+
+func (p *Provider) dequeueOnDemandDomain(domain string) {
+	if err := p.Store.RemoveOnDemandRequest(p.getContext(), domain); err != nil && err != ErrReadOnly {
+		domainsLogger([]string{domain}).Errorf("Unable to remove the on demand domain %s from the queue: %v", domain, err)
+	}
+}
+
+// resumeOnDemandQueue obtains the certificates of the domains queued on demand before a restart, the most requested first
+func (p *Provider) resumeOnDemandQueue() {
+	if !p.OnDemand || p.isPassive() {
+		return
+	}
+
+	p.removeExpiredOnDemandRequests()
+
+	queue, err := p.Store.GetOnDemandQueue(p.getContext())
+	if err != nil {
+		logger().Errorf("Unable to get the on demand queue: %v", err)
+		return
+	}
+	if len(queue) == 0 {
+		return
+	}
+
+	requests := sortOnDemandQueue(queue)
+	logger().Infof("Resuming the on demand issuance of %d queued domains.", len(requests))
+
+	safe.Go(func() {
+		for _, request := range requests {
+			if _, err := p.resolveCertificate(types.Domain{Main: request.Domain}, false); err != nil {
+				domainsLogger([]string{request.Domain}).Errorf("Unable to obtain ACME certificate for the queued on demand domain %s: %v", request.Domain, err)
+				continue
+			}
+			p.dequeueOnDemandDomain(request.Domain)
+		}
+	})
+}
+
+func (p *Provider) removeExpiredOnDemandRequests() {
+	if !p.OnDemand || p.isPassive() {
+		return
+	}
+
+	ttl := p.getOnDemandQueueTTL()
+	removed, err := p.Store.RemoveExpiredOnDemandRequests(p.getContext(), ttl)
+	if err != nil {
+		logger().Errorf("Unable to remove the expired domains of the on demand queue: %v", err)
+		return
+	}
+
+	if removed > 0 {
+		logger().Infof("Removed %d domains of the on demand queue not requested for %s.", removed, ttl)
+	}
+}
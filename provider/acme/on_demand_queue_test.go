@@ -0,0 +1,62 @@
+package acme
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreOnDemandQueue(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	now := time.Now()
+	require.NoError(t, store.AddOnDemandRequest(context.Background(), "Traefik.wtf", now.Add(-2*time.Hour)))
+	require.NoError(t, store.AddOnDemandRequest(context.Background(), "traefik.wtf", now.Add(-time.Hour)))
+	require.NoError(t, store.AddOnDemandRequest(context.Background(), "acme.wtf", now.Add(-48*time.Hour)))
+
+	queue, err := store.GetOnDemandQueue(context.Background())
+	require.NoError(t, err)
+	require.Len(t, queue, 2)
+	assert.Equal(t, &OnDemandRequest{Domain: "traefik.wtf", FirstRequestedAt: now.Add(-2 * time.Hour), LastRequestedAt: now.Add(-time.Hour), Requests: 2}, queue["traefik.wtf"])
+
+	removed, err := store.RemoveExpiredOnDemandRequests(context.Background(), 24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	require.NoError(t, store.RemoveOnDemandRequest(context.Background(), "traefik.wtf"))
+	queue, err = store.GetOnDemandQueue(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, queue)
+}
+
+func TestGetOnDemandQueue(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	now := time.Now()
+	require.NoError(t, store.AddOnDemandRequest(context.Background(), "old.traefik.wtf", now.Add(-2*time.Hour)))
+	require.NoError(t, store.AddOnDemandRequest(context.Background(), "new.traefik.wtf", now.Add(-time.Hour)))
+	require.NoError(t, store.AddOnDemandRequest(context.Background(), "popular.traefik.wtf", now))
+	require.NoError(t, store.AddOnDemandRequest(context.Background(), "popular.traefik.wtf", now))
+
+	p := &Provider{Configuration: &Configuration{OnDemand: true, OnDemandQueueTTL: parse.Duration(time.Hour)}, Store: store}
+
+	queue, err := p.GetOnDemandQueue(context.Background())
+	require.NoError(t, err)
+	require.Len(t, queue, 3)
+	assert.Equal(t, "popular.traefik.wtf", queue[0].Domain)
+	assert.Equal(t, 2, queue[0].Requests)
+	assert.Equal(t, "old.traefik.wtf", queue[1].Domain)
+	assert.Equal(t, "new.traefik.wtf", queue[2].Domain)
+
+	p.removeExpiredOnDemandRequests()
+	queue, err = p.GetOnDemandQueue(context.Background())
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+	assert.Equal(t, "popular.traefik.wtf", queue[0].Domain)
+}
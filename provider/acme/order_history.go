@@ -0,0 +1,456 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xenolf/lego/acme"
+)
+
+const (
+	// orderHistoryWindow is the period of the orders kept in the history, the longest window of the known limits
+	orderHistoryWindow = 7 * 24 * time.Hour
+	// defaultRateLimitRetryAfter is the delay before the next order once rate limited, when the CA does not tell it
+	defaultRateLimitRetryAfter = time.Hour
+
+	rateLimitedErrorType      = "rateLimited"
+	letsEncryptProductionHost = "acme-v02.api.letsencrypt.org"
+)
+
+// Names of the known limits of the Let's Encrypt production CA
+const (
+	orderLimitNewOrders             = "newOrders"
+	orderLimitDuplicateCertificates = "duplicateCertificates"
+	orderLimitFailedValidations     = "failedValidations"
+)
+
+// rateLimitRetryAfter matches the time the CA tells to retry after in the detail of its rate-limit responses
+var rateLimitRetryAfter = regexp.MustCompile(`retry after (\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} UTC)`)
+
+// OrderHistory is the history of the orders placed with the CA and of its rate-limit responses,
+// stored to respect the limits of the CA across restarts
+type OrderHistory struct {
+	Account *OrderCounters `json:",omitempty"`
+	// Domains are the counters of each set of domains, by their sorted domains joined with a comma
+	Domains map[string]*OrderCounters `json:",omitempty"`
+}
+
+// OrderCounters are the orders placed, the certificates issued and the orders failed within the history window,
+// and the last rate-limit response of the CA
+type OrderCounters struct {
+	Orders    []time.Time `json:",omitempty"`
+	Issued    []time.Time `json:",omitempty"`
+	Failures  []time.Time `json:",omitempty"`
+	RateLimit *RateLimit  `json:",omitempty"`
+}
+
+// RateLimit is a rate-limit response of the CA
+type RateLimit struct {
+	Detail     string
+	LimitedAt  time.Time
+	RetryAfter time.Time
+}
+
+// orderLimit is a known limit of the CA: no more than limit times counted within the window
+type orderLimit struct {
+	name   string
+	limit  int
+	window time.Duration
+	times  func(counters *OrderCounters) []time.Time
+}
+
+// accountOrderLimits are the limits of the Let's Encrypt production CA applying to the account
+var accountOrderLimits = []orderLimit{
+	{name: orderLimitNewOrders, limit: 300, window: 3 * time.Hour, times: func(counters *OrderCounters) []time.Time { return counters.Orders }},
+}
+
+// domainsOrderLimits are the limits of the Let's Encrypt production CA applying to a set of domains
+var domainsOrderLimits = []orderLimit{
+	{name: orderLimitDuplicateCertificates, limit: 5, window: 7 * 24 * time.Hour, times: func(counters *OrderCounters) []time.Time { return counters.Issued }},
+	{name: orderLimitFailedValidations, limit: 5, window: time.Hour, times: func(counters *OrderCounters) []time.Time { return counters.Failures }},
+}
+
+// OrderDeferredError is returned when an order is not placed as it would exceed a known limit of the CA
+type OrderDeferredError struct {
+	Domains    []string
+	Reason     string
+	RetryAfter time.Time
+}
+
+func (e *OrderDeferredError) Error() string {
+	return fmt.Sprintf("the order of the domains %v is deferred until %s: %s", e.Domains, e.RetryAfter.UTC().Format(time.RFC3339), e.Reason)
+}
+
+// OrderLimitsInfo describes the orders of the account and of each set of domains, and how close they are to the limits of the CA
+type OrderLimitsInfo struct {
+	Account *OrderCountersInfo   `json:"account"`
+	Domains []*OrderCountersInfo `json:"domains"`
+}
+
+// OrderCountersInfo describes the orders within the history window, of the account or of a set of domains
+type OrderCountersInfo struct {
+	Domains       []string          `json:"domains,omitempty"`
+	Orders        int               `json:"orders"`
+	Issued        int               `json:"issued"`
+	Failures      int               `json:"failures"`
+	RateLimit     *RateLimitInfo    `json:"rateLimit,omitempty"`
+	Limits        []*OrderLimitInfo `json:"limits,omitempty"`
+	DeferredUntil *time.Time        `json:"deferredUntil,omitempty"`
+}
+
+// RateLimitInfo describes the last rate-limit response of the CA
+type RateLimitInfo struct {
+	Detail     string    `json:"detail"`
+	LimitedAt  time.Time `json:"limitedAt"`
+	RetryAfter time.Time `json:"retryAfter"`
+}
+
+// OrderLimitInfo describes the use of a known limit of the CA
+type OrderLimitInfo struct {
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+}
+
+// orderHistoryStore is implemented by the stores keeping the history of the orders
+type orderHistoryStore interface {
+	GetOrderHistory(ctx context.Context) (*OrderHistory, error)
+}
+
+// checkOrderLimits returns an OrderDeferredError when an order of the domains would exceed a known limit of the CA,
+// the history failing to be read does not prevent the order. The limits are not checked with the stores not keeping the history.
+func (p *Provider) checkOrderLimits(domains []string) error {
+	store, ok := unwrapStore(p.Store).(orderHistoryStore)
+	if !ok {
+		return nil
+	}
+
+	history, err := store.GetOrderHistory(p.getContext())
+	if err != nil {
+		domainsLogger(domains).Errorf("Unable to get the order history, the limits of the CA are not checked: %v", err)
+		return nil
+	}
+
+	err = checkOrderLimits(history, domains, p.isLetsEncryptProduction(), time.Now())
+	if deferred, ok := err.(*OrderDeferredError); ok {
+		domainsLogger(domains).Warnf("The order of the certificate for the domains %v is deferred until %s: %s",
+			domains, deferred.RetryAfter.UTC().Format(time.RFC3339), deferred.Reason)
+	}
+	return err
+}
+
+// recordOrder records an order of the domains placed with the CA, and its outcome
+func (p *Provider) recordOrder(domains []string, err error) {
+	now := time.Now()
+	updateErr := p.Store.Update(p.getContext(), func(data *StoredData) error {
+		data.OrderHistory = recordOrder(data.OrderHistory, domains, err, now)
+		return nil
+	})
+	if updateErr != nil && updateErr != ErrReadOnly {
+		domainsLogger(domains).Errorf("Unable to save the order history: %v", updateErr)
+	}
+}
+
+// isLetsEncryptProduction returns whether the CA is the Let's Encrypt production one, whose limits are known
+func (p *Provider) isLetsEncryptProduction() bool {
+	caURL, err := url.Parse(p.getCAServer())
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(caURL.Hostname(), letsEncryptProductionHost)
+}
+
+// GetOrderLimits returns the orders of the account and of each set of domains within the history window, with the known limits of the CA.
+// It returns nil when the store does not keep the history of the orders.
+func (p *Provider) GetOrderLimits(ctx context.Context) (*OrderLimitsInfo, error) {
+	store, ok := unwrapStore(p.Store).(orderHistoryStore)
+	if !ok {
+		return nil, nil
+	}
+
+	history, err := store.GetOrderHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return getOrderLimits(history, p.isLetsEncryptProduction(), time.Now()), nil
+}
+
+// checkOrderLimits returns an OrderDeferredError when the CA rate limited the account or the domains until later,
+// or when an order would exceed one of the limits of Let's Encrypt
+func checkOrderLimits(history *OrderHistory, domains []string, letsEncrypt bool, now time.Time) error {
+	scopes := []struct {
+		counters *OrderCounters
+		limits   []orderLimit
+	}{
+		{counters: history.Account, limits: accountOrderLimits},
+		{counters: history.Domains[getOrderDomainsKey(domains)], limits: domainsOrderLimits},
+	}
+
+	for _, scope := range scopes {
+		if scope.counters == nil {
+			continue
+		}
+
+		if rateLimit := scope.counters.RateLimit; rateLimit != nil && now.Before(rateLimit.RetryAfter) {
+			return &OrderDeferredError{Domains: domains, Reason: "rate limited by the CA: " + rateLimit.Detail, RetryAfter: rateLimit.RetryAfter}
+		}
+
+		if !letsEncrypt {
+			continue
+		}
+		for _, limit := range scope.limits {
+			if count, until := limit.check(scope.counters, now); count >= limit.limit {
+				reason := fmt.Sprintf("the %s limit of %d per %s is reached", limit.name, limit.limit, limit.window)
+				return &OrderDeferredError{Domains: domains, Reason: reason, RetryAfter: until}
+			}
+		}
+	}
+
+	return nil
+}
+
+// check returns the times counted by the limit within its window, and when the count goes below the limit
+func (l orderLimit) check(counters *OrderCounters, now time.Time) (int, time.Time) {
+	var times []time.Time
+	for _, t := range l.times(counters) {
+		if now.Sub(t) < l.window {
+			times = append(times, t)
+		}
+	}
+	if len(times) < l.limit {
+		return len(times), time.Time{}
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return len(times), times[len(times)-l.limit].Add(l.window)
+}
+
+// recordOrder returns the history with the order of the domains and its outcome, the times out of the history window being pruned.
+// The history is not modified, it may be shared with a copy of the stored data.
+func recordOrder(history *OrderHistory, domains []string, err error, now time.Time) *OrderHistory {
+	recorded := copyOrderHistory(history)
+	if recorded.Account == nil {
+		recorded.Account = &OrderCounters{}
+	}
+	if recorded.Domains == nil {
+		recorded.Domains = make(map[string]*OrderCounters)
+	}
+
+	key := getOrderDomainsKey(domains)
+	counters := recorded.Domains[key]
+	if counters == nil {
+		counters = &OrderCounters{}
+		recorded.Domains[key] = counters
+	}
+
+	recorded.Account.Orders = append(recorded.Account.Orders, now)
+	counters.Orders = append(counters.Orders, now)
+	if err == nil {
+		counters.Issued = append(counters.Issued, now)
+	} else {
+		counters.Failures = append(counters.Failures, now)
+	}
+
+	if rateLimit := getRateLimit(err, now); rateLimit != nil {
+		counters.RateLimit = rateLimit
+		// The new orders limit applies to the whole account
+		if strings.Contains(strings.ToLower(rateLimit.Detail), "new orders") {
+			recorded.Account.RateLimit = rateLimit
+		}
+	}
+
+	pruneOrderCounters(recorded.Account, now)
+	for key, counters := range recorded.Domains {
+		if pruneOrderCounters(counters, now) {
+			delete(recorded.Domains, key)
+		}
+	}
+
+	return recorded
+}
+
+// pruneOrderCounters removes the times and the rate limit out of the history window, and returns whether the counters are empty
+func pruneOrderCounters(counters *OrderCounters, now time.Time) bool {
+	prune := func(times []time.Time) []time.Time {
+		var kept []time.Time
+		for _, t := range times {
+			if now.Sub(t) < orderHistoryWindow {
+				kept = append(kept, t)
+			}
+		}
+		return kept
+	}
+
+	counters.Orders = prune(counters.Orders)
+	counters.Issued = prune(counters.Issued)
+	counters.Failures = prune(counters.Failures)
+	if counters.RateLimit != nil && now.Sub(counters.RateLimit.RetryAfter) >= orderHistoryWindow {
+		counters.RateLimit = nil
+	}
+
+	return len(counters.Orders) == 0 && len(counters.Issued) == 0 && len(counters.Failures) == 0 && counters.RateLimit == nil
+}
+
+// getRateLimit returns the rate limit of the error of an order, nil when the CA did not rate limit it
+func getRateLimit(err error, now time.Time) *RateLimit {
+	if err == nil {
+		return nil
+	}
+
+	detail, ok := getRateLimitDetail(err)
+	if !ok {
+		return nil
+	}
+
+	retryAfter := now.Add(defaultRateLimitRetryAfter)
+	if match := rateLimitRetryAfter.FindStringSubmatch(detail); match != nil {
+		if t, parseErr := time.Parse("2006-01-02 15:04:05 MST", match[1]); parseErr == nil {
+			retryAfter = t
+		}
+	}
+
+	return &RateLimit{Detail: detail, LimitedAt: now, RetryAfter: retryAfter}
+}
+
+// getRateLimitDetail returns the detail of the rate-limit response of the CA in the error, the 429 responses and the rateLimited errors
+func getRateLimitDetail(err error) (string, bool) {
+	switch e := err.(type) {
+	case acme.RemoteError:
+		if e.StatusCode == http.StatusTooManyRequests || strings.HasSuffix(e.Type, rateLimitedErrorType) {
+			return e.Detail, true
+		}
+		return "", false
+	case acme.ObtainError:
+		for _, domainErr := range e {
+			if detail, ok := getRateLimitDetail(domainErr); ok {
+				return detail, true
+			}
+		}
+		return "", false
+	}
+
+	// The errors of the ACME client may be wrapped in others
+	if strings.Contains(err.Error(), rateLimitedErrorType) {
+		return err.Error(), true
+	}
+	return "", false
+}
+
+// getOrderLimits describes the orders of the history, at the time
+func getOrderLimits(history *OrderHistory, letsEncrypt bool, now time.Time) *OrderLimitsInfo {
+	info := &OrderLimitsInfo{
+		Account: getOrderCountersInfo(history.Account, nil, accountOrderLimits, letsEncrypt, now),
+		Domains: []*OrderCountersInfo{},
+	}
+
+	for key, counters := range history.Domains {
+		info.Domains = append(info.Domains, getOrderCountersInfo(counters, strings.Split(key, ","), domainsOrderLimits, letsEncrypt, now))
+	}
+	sort.Slice(info.Domains, func(i, j int) bool {
+		return strings.Join(info.Domains[i].Domains, ",") < strings.Join(info.Domains[j].Domains, ",")
+	})
+
+	return info
+}
+
+func getOrderCountersInfo(counters *OrderCounters, domains []string, limits []orderLimit, letsEncrypt bool, now time.Time) *OrderCountersInfo {
+	if counters == nil {
+		counters = &OrderCounters{}
+	}
+
+	within := func(times []time.Time) int {
+		var count int
+		for _, t := range times {
+			if now.Sub(t) < orderHistoryWindow {
+				count++
+			}
+		}
+		return count
+	}
+
+	info := &OrderCountersInfo{
+		Domains:  domains,
+		Orders:   within(counters.Orders),
+		Issued:   within(counters.Issued),
+		Failures: within(counters.Failures),
+	}
+
+	if rateLimit := counters.RateLimit; rateLimit != nil {
+		info.RateLimit = &RateLimitInfo{Detail: rateLimit.Detail, LimitedAt: rateLimit.LimitedAt, RetryAfter: rateLimit.RetryAfter}
+		if now.Before(rateLimit.RetryAfter) {
+			retryAfter := rateLimit.RetryAfter
+			info.DeferredUntil = &retryAfter
+		}
+	}
+
+	if !letsEncrypt {
+		return info
+	}
+	for _, limit := range limits {
+		count, until := limit.check(counters, now)
+		info.Limits = append(info.Limits, &OrderLimitInfo{Name: limit.name, Count: count, Limit: limit.limit, Window: limit.window.String()})
+		if count >= limit.limit && (info.DeferredUntil == nil || until.After(*info.DeferredUntil)) {
+			info.DeferredUntil = &until
+		}
+	}
+
+	return info
+}
+
+// getOrderDomainsKey returns the key of the set of domains in the history, as the CA counts the duplicate certificates in any order and case
+func getOrderDomainsKey(domains []string) string {
+	var normalized []string
+	seen := make(map[string]struct{})
+	for _, domain := range domains {
+		domain = normalizeDomain(domain)
+		if _, ok := seen[domain]; ok {
+			continue
+		}
+		seen[domain] = struct{}{}
+		normalized = append(normalized, domain)
+	}
+	sort.Strings(normalized)
+	return strings.Join(normalized, ",")
+}
+
+// copyOrderHistory returns a deep copy of the history, an empty one when nil
+func copyOrderHistory(history *OrderHistory) *OrderHistory {
+	copied := &OrderHistory{}
+	if history == nil {
+		return copied
+	}
+
+	copied.Account = copyOrderCounters(history.Account)
+	if history.Domains != nil {
+		copied.Domains = make(map[string]*OrderCounters, len(history.Domains))
+		for key, counters := range history.Domains {
+			copied.Domains[key] = copyOrderCounters(counters)
+		}
+	}
+	return copied
+}
+
+func copyOrderCounters(counters *OrderCounters) *OrderCounters {
+	if counters == nil {
+		return nil
+	}
+
+	copied := &OrderCounters{
+		Orders:   append([]time.Time(nil), counters.Orders...),
+		Issued:   append([]time.Time(nil), counters.Issued...),
+		Failures: append([]time.Time(nil), counters.Failures...),
+	}
+	if counters.RateLimit != nil {
+		rateLimit := *counters.RateLimit
+		copied.RateLimit = &rateLimit
+	}
+	return copied
+}
@@ -0,0 +1,227 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+func TestGetRateLimit(t *testing.T) {
+	now := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		desc               string
+		err                error
+		expectedDetail     string
+		expectedRetryAfter time.Time
+	}{
+		{
+			desc:               "rateLimited error with retry after",
+			err:                acme.RemoteError{Type: "urn:ietf:params:acme:error:rateLimited", Detail: "too many certificates already issued for exact set of domains: traefik.wtf: see https://letsencrypt.org/docs/rate-limits/, retry after 2026-10-16 08:30:00 UTC"},
+			expectedDetail:     "too many certificates already issued for exact set of domains: traefik.wtf: see https://letsencrypt.org/docs/rate-limits/, retry after 2026-10-16 08:30:00 UTC",
+			expectedRetryAfter: time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			desc:               "429 response",
+			err:                acme.RemoteError{StatusCode: http.StatusTooManyRequests, Detail: "slow down"},
+			expectedDetail:     "slow down",
+			expectedRetryAfter: now.Add(defaultRateLimitRetryAfter),
+		},
+		{
+			desc:               "obtain error",
+			err:                acme.ObtainError{"traefik.wtf": acme.RemoteError{StatusCode: http.StatusTooManyRequests, Detail: "too many failed authorizations recently"}},
+			expectedDetail:     "too many failed authorizations recently",
+			expectedRetryAfter: now.Add(defaultRateLimitRetryAfter),
+		},
+		{
+			desc:               "wrapped error",
+			err:                fmt.Errorf("unable to obtain: %v", acme.RemoteError{StatusCode: http.StatusTooManyRequests, Type: "urn:ietf:params:acme:error:rateLimited", Detail: "limited"}),
+			expectedDetail:     "unable to obtain: acme: Error 429 - urn:ietf:params:acme:error:rateLimited - limited",
+			expectedRetryAfter: now.Add(defaultRateLimitRetryAfter),
+		},
+		{
+			desc: "other error",
+			err:  acme.RemoteError{StatusCode: http.StatusForbidden, Type: "urn:ietf:params:acme:error:unauthorized"},
+		},
+		{
+			desc: "no error",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rateLimit := getRateLimit(test.err, now)
+			if len(test.expectedDetail) == 0 {
+				assert.Nil(t, rateLimit)
+				return
+			}
+			require.NotNil(t, rateLimit)
+			assert.Equal(t, test.expectedDetail, rateLimit.Detail)
+			assert.Equal(t, now, rateLimit.LimitedAt)
+			assert.Equal(t, test.expectedRetryAfter, rateLimit.RetryAfter)
+		})
+	}
+}
+
+func TestRecordOrder(t *testing.T) {
+	now := time.Now()
+	domains := []string{"www.traefik.wtf", "TRAEFIK.wtf"}
+
+	old := recordOrder(nil, []string{"old.wtf"}, nil, now.Add(-8*24*time.Hour))
+	history := recordOrder(old, domains, nil, now.Add(-time.Hour))
+	history = recordOrder(history, domains, errors.New("unauthorized"), now)
+
+	// The history is not modified in place, it may be shared with a copy of the stored data
+	assert.Len(t, old.Domains, 1)
+
+	// The orders out of the history window are pruned
+	require.Len(t, history.Domains, 1)
+	counters := history.Domains["traefik.wtf,www.traefik.wtf"]
+	require.NotNil(t, counters)
+	assert.Len(t, counters.Orders, 2)
+	assert.Len(t, counters.Issued, 1)
+	assert.Len(t, counters.Failures, 1)
+	assert.Nil(t, counters.RateLimit)
+	assert.Len(t, history.Account.Orders, 2)
+
+	// The new orders limit applies to the account
+	history = recordOrder(history, []string{"other.wtf"}, acme.RemoteError{StatusCode: http.StatusTooManyRequests, Detail: "too many new orders recently"}, now)
+	require.NotNil(t, history.Domains["other.wtf"].RateLimit)
+	require.NotNil(t, history.Account.RateLimit)
+	assert.Nil(t, history.Domains["traefik.wtf,www.traefik.wtf"].RateLimit)
+}
+
+func TestCheckOrderLimits(t *testing.T) {
+	now := time.Now()
+	domains := []string{"traefik.wtf"}
+
+	issued := &OrderHistory{}
+	for i := 0; i < 5; i++ {
+		issued = recordOrder(issued, domains, nil, now.Add(-time.Duration(5-i)*24*time.Hour))
+	}
+
+	failed := &OrderHistory{}
+	for i := 0; i < 5; i++ {
+		failed = recordOrder(failed, domains, errors.New("unauthorized"), now.Add(-time.Duration(50-i)*time.Minute))
+	}
+
+	rateLimited := recordOrder(nil, domains, acme.RemoteError{StatusCode: http.StatusTooManyRequests, Detail: "slow down"}, now)
+
+	accountOrders := &OrderHistory{Account: &OrderCounters{}}
+	for i := 0; i < 300; i++ {
+		accountOrders.Account.Orders = append(accountOrders.Account.Orders, now.Add(-time.Hour))
+	}
+
+	testCases := []struct {
+		desc               string
+		history            *OrderHistory
+		domains            []string
+		letsEncrypt        bool
+		expectedRetryAfter time.Time
+	}{
+		{
+			desc:        "empty history",
+			history:     &OrderHistory{},
+			domains:     domains,
+			letsEncrypt: true,
+		},
+		{
+			desc:               "duplicate certificates",
+			history:            issued,
+			domains:            domains,
+			letsEncrypt:        true,
+			expectedRetryAfter: now.Add(2 * 24 * time.Hour),
+		},
+		{
+			desc:        "duplicate certificates of another CA",
+			history:     issued,
+			domains:     domains,
+			letsEncrypt: false,
+		},
+		{
+			desc:        "duplicate certificates of other domains",
+			history:     issued,
+			domains:     []string{"traefik.wtf", "www.traefik.wtf"},
+			letsEncrypt: true,
+		},
+		{
+			desc:               "failed validations",
+			history:            failed,
+			domains:            domains,
+			letsEncrypt:        true,
+			expectedRetryAfter: now.Add(10 * time.Minute),
+		},
+		{
+			desc:               "rate limited by any CA",
+			history:            rateLimited,
+			domains:            domains,
+			expectedRetryAfter: now.Add(defaultRateLimitRetryAfter),
+		},
+		{
+			desc:               "new orders of the account",
+			history:            accountOrders,
+			domains:            []string{"other.wtf"},
+			letsEncrypt:        true,
+			expectedRetryAfter: now.Add(2 * time.Hour),
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkOrderLimits(test.history, test.domains, test.letsEncrypt, now)
+			if test.expectedRetryAfter.IsZero() {
+				assert.NoError(t, err)
+				return
+			}
+			require.IsType(t, &OrderDeferredError{}, err)
+			assert.Equal(t, test.expectedRetryAfter, err.(*OrderDeferredError).RetryAfter)
+		})
+	}
+}
+
+func TestProviderOrderLimits(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	p := &Provider{Configuration: &Configuration{}, Store: store}
+	domains := []string{"traefik.wtf"}
+
+	require.NoError(t, p.checkOrderLimits(domains))
+
+	p.recordOrder(domains, acme.RemoteError{Type: "urn:ietf:params:acme:error:rateLimited", Detail: "too many certificates already issued"})
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.OrderHistory != nil && len(storedData.OrderHistory.Domains) == 1
+	})
+
+	// The rate limit is respected from the stored history, as after a restart
+	err := p.checkOrderLimits(domains)
+	assert.IsType(t, &OrderDeferredError{}, err)
+	assert.NoError(t, p.checkOrderLimits([]string{"other.wtf"}))
+
+	limits, err := p.GetOrderLimits(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, limits.Account.Orders)
+	require.Len(t, limits.Domains, 1)
+	assert.Equal(t, domains, limits.Domains[0].Domains)
+	assert.Equal(t, 1, limits.Domains[0].Failures)
+	require.NotNil(t, limits.Domains[0].RateLimit)
+	assert.Equal(t, "too many certificates already issued", limits.Domains[0].RateLimit.Detail)
+	assert.NotNil(t, limits.Domains[0].DeferredUntil)
+
+	// The known limits are reported for Let's Encrypt
+	require.Len(t, limits.Domains[0].Limits, 2)
+	assert.Equal(t, &OrderLimitInfo{Name: orderLimitDuplicateCertificates, Count: 0, Limit: 5, Window: "168h0m0s"}, limits.Domains[0].Limits[0])
+}
@@ -0,0 +1,195 @@
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/xenolf/lego/providers/dns"
+)
+
+// PendingChallenge describes a pending ACME challenge, without its key authorization
+type PendingChallenge struct {
+	Type          string     `json:"type"`
+	Domain        string     `json:"domain"`
+	Token         string     `json:"token,omitempty"`
+	KeyAuthSHA256 string     `json:"keyAuthSha256,omitempty"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	Age           string     `json:"age,omitempty"`
+}
+
+// GetPendingChallenges returns the challenges pending in the store, sorted by type, domain and token
+func (p *Provider) GetPendingChallenges(ctx context.Context) ([]*PendingChallenge, error) {
+	var challenges []*PendingChallenge
+
+	httpChallenges, err := p.Store.GetHTTPChallenges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, challenge := range httpChallenges {
+		challenges = append(challenges, newPendingChallenge(challengeTypeHTTP01, challenge.Domain, challenge.Token, challenge.KeyAuth, challenge.CreatedAt))
+	}
+
+	tlsChallenges, err := p.Store.GetTLSChallenges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tlsChallengesCreatedAt, err := p.Store.GetTLSChallengesCreatedAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for domain := range tlsChallenges {
+		challenges = append(challenges, newPendingChallenge(challengeTypeTLSALPN01, domain, "", nil, tlsChallengesCreatedAt[domain]))
+	}
+
+	dnsChallenges, err := p.Store.GetDNSChallenges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for token, state := range dnsChallenges {
+		challenges = append(challenges, newPendingChallenge(challengeTypeDNS01, state.Domain, token, []byte(state.KeyAuth), state.CreatedAt))
+	}
+
+	sort.Slice(challenges, func(i, j int) bool {
+		if challenges[i].Type != challenges[j].Type {
+			return challenges[i].Type < challenges[j].Type
+		}
+		if challenges[i].Domain != challenges[j].Domain {
+			return challenges[i].Domain < challenges[j].Domain
+		}
+		return challenges[i].Token < challenges[j].Token
+	})
+
+	return challenges, nil
+}
+
+// DeletePendingChallenge removes a pending challenge from the store once its removal is persisted, and returns it, nil if it does not exist.
+// The token is only needed for HTTP-01 and DNS-01 challenges, the record of a DNS-01 challenge is also cleaned up.
+func (p *Provider) DeletePendingChallenge(ctx context.Context, challengeType, token, domain string) (*PendingChallenge, error) {
+	challenge, err := p.getPendingChallenge(ctx, challengeType, token, domain)
+	if err != nil || challenge == nil {
+		return nil, err
+	}
+
+	switch challengeType {
+	case challengeTypeHTTP01:
+		if err := p.Store.RemoveHTTPChallengeToken(ctx, challenge.Token, challenge.Domain); err != nil {
+			return nil, err
+		}
+
+	case challengeTypeTLSALPN01:
+		if err := p.Store.RemoveTLSChallenge(ctx, challenge.Domain); err != nil {
+			return nil, err
+		}
+
+	case challengeTypeDNS01:
+		states, err := p.Store.GetDNSChallenges(ctx)
+		if err != nil {
+			return nil, err
+		}
+		state, ok := states[token]
+		if !ok {
+			return nil, nil
+		}
+
+		provider, err := dns.NewDNSChallengeProviderByName(state.Provider)
+		if err != nil {
+			challengeLogger(challengeTypeDNS01, state.Domain).Errorf("Unable to clean up the DNS challenge record %s for domain %s with provider %s: %v", state.FQDN, state.Domain, state.Provider, err)
+			if err = p.Store.RemoveDNSChallenge(ctx, token); err != nil {
+				return nil, err
+			}
+		} else {
+			cleanUpDNSChallenge(&challengeDNS{provider: provider, providerName: state.Provider, Store: p.Store}, token, state)
+		}
+	}
+
+	challengeLogger(challengeType, domain).Infof("Deleted the pending %s challenge for domain %s.", challengeType, domain)
+	updatePendingChallenges(ctx, p.metricsRegistry, p.Store)
+
+	if err := p.persistStorage(ctx); err != nil {
+		return nil, fmt.Errorf("the deletion of the pending %s challenge for domain %s is not persisted: %v", challengeType, domain, err)
+	}
+	return challenge, nil
+}
+
+// DeleteHTTPChallengeToken removes the pending HTTP-01 challenges of the token from the store, only the one of the domain when set,
+// and returns them once their removal is persisted. The challenges are removed even when the token is no longer served.
+func (p *Provider) DeleteHTTPChallengeToken(ctx context.Context, token, domain string) ([]*PendingChallenge, error) {
+	httpChallenges, err := p.Store.GetHTTPChallenges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []*PendingChallenge
+	for _, challenge := range httpChallenges {
+		if challenge.Token != token || (len(domain) > 0 && normalizeDomain(challenge.Domain) != normalizeDomain(domain)) {
+			continue
+		}
+
+		if err := p.Store.RemoveHTTPChallengeToken(ctx, challenge.Token, challenge.Domain); err != nil {
+			return nil, err
+		}
+
+		challengeLogger(challengeTypeHTTP01, challenge.Domain).Infof("Deleted the pending %s challenge for domain %s.", challengeTypeHTTP01, challenge.Domain)
+		deleted = append(deleted, newPendingChallenge(challengeTypeHTTP01, challenge.Domain, challenge.Token, challenge.KeyAuth, challenge.CreatedAt))
+	}
+
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	updatePendingChallenges(ctx, p.metricsRegistry, p.Store)
+
+	if err := p.persistStorage(ctx); err != nil {
+		return nil, fmt.Errorf("the deletion of the pending %s challenges of the token %s is not persisted: %v", challengeTypeHTTP01, token, err)
+	}
+	return deleted, nil
+}
+
+// getPendingChallenge returns the pending challenge of the type matching the token and the domain, nil if none
+func (p *Provider) getPendingChallenge(ctx context.Context, challengeType, token, domain string) (*PendingChallenge, error) {
+	switch challengeType {
+	case challengeTypeHTTP01, challengeTypeTLSALPN01, challengeTypeDNS01:
+	default:
+		return nil, nil
+	}
+
+	challenges, err := p.GetPendingChallenges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, challenge := range challenges {
+		if challenge.Type != challengeType || normalizeDomain(challenge.Domain) != normalizeDomain(domain) {
+			continue
+		}
+		if challengeType != challengeTypeTLSALPN01 && challenge.Token != token {
+			continue
+		}
+		return challenge, nil
+	}
+	return nil, nil
+}
+
+func newPendingChallenge(challengeType, domain, token string, keyAuth []byte, createdAt time.Time) *PendingChallenge {
+	challenge := &PendingChallenge{
+		Type:   challengeType,
+		Domain: domain,
+		Token:  token,
+	}
+
+	if len(keyAuth) > 0 {
+		hash := sha256.Sum256(keyAuth)
+		challenge.KeyAuthSHA256 = hex.EncodeToString(hash[:])
+	}
+
+	if !createdAt.IsZero() {
+		challenge.CreatedAt = &createdAt
+		challenge.Age = time.Since(createdAt).Truncate(time.Second).String()
+	}
+
+	return challenge
+}
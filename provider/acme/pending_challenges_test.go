@@ -0,0 +1,157 @@
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPendingChallenges(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+	require.NoError(t, store.AddTLSChallenge(context.Background(), "traefik.wtf", &Certificate{Domain: types.Domain{Main: "TEMP-traefik.wtf"}}))
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "dnsToken", &DNSChallengeState{Provider: "manual", Domain: "traefik.wtf", KeyAuth: "dnsKeyAuth", CreatedAt: time.Now().Add(-time.Minute)}))
+
+	p := &Provider{Store: store}
+
+	challenges, err := p.GetPendingChallenges(context.Background())
+	require.NoError(t, err)
+	require.Len(t, challenges, 3)
+
+	keyAuthHash := sha256.Sum256([]byte("dnsKeyAuth"))
+	assert.Equal(t, challengeTypeDNS01, challenges[0].Type)
+	assert.Equal(t, "dnsToken", challenges[0].Token)
+	assert.Equal(t, hex.EncodeToString(keyAuthHash[:]), challenges[0].KeyAuthSHA256)
+	assert.Equal(t, "1m0s", challenges[0].Age)
+
+	keyAuthHash = sha256.Sum256([]byte("keyAuth"))
+	assert.Equal(t, challengeTypeHTTP01, challenges[1].Type)
+	assert.Equal(t, "traefik.wtf", challenges[1].Domain)
+	assert.Equal(t, "token", challenges[1].Token)
+	assert.Equal(t, hex.EncodeToString(keyAuthHash[:]), challenges[1].KeyAuthSHA256)
+	assert.NotNil(t, challenges[1].CreatedAt)
+
+	assert.Equal(t, challengeTypeTLSALPN01, challenges[2].Type)
+	assert.Equal(t, "traefik.wtf", challenges[2].Domain)
+	assert.Empty(t, challenges[2].KeyAuthSHA256)
+	assert.NotNil(t, challenges[2].CreatedAt)
+	assert.NotEmpty(t, challenges[2].Age)
+}
+
+func TestDeletePendingChallenge(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+	require.NoError(t, store.AddTLSChallenge(context.Background(), "traefik.wtf", &Certificate{Domain: types.Domain{Main: "TEMP-traefik.wtf"}}))
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "dnsToken", &DNSChallengeState{Provider: "unknown", Domain: "traefik.wtf", CreatedAt: time.Now()}))
+
+	p := &Provider{Store: store}
+
+	testCases := []struct {
+		desc          string
+		challengeType string
+		token         string
+		domain        string
+		expected      bool
+	}{
+		{
+			desc:          "unknown HTTP challenge",
+			challengeType: challengeTypeHTTP01,
+			token:         "unknown",
+			domain:        "traefik.wtf",
+		},
+		{
+			desc:          "HTTP challenge",
+			challengeType: challengeTypeHTTP01,
+			token:         "token",
+			domain:        "traefik.wtf",
+			expected:      true,
+		},
+		{
+			desc:          "TLS challenge",
+			challengeType: challengeTypeTLSALPN01,
+			domain:        "traefik.wtf",
+			expected:      true,
+		},
+		{
+			desc:          "DNS challenge for another domain",
+			challengeType: challengeTypeDNS01,
+			token:         "dnsToken",
+			domain:        "traefik.io",
+		},
+		{
+			desc:          "DNS challenge",
+			challengeType: challengeTypeDNS01,
+			token:         "dnsToken",
+			domain:        "traefik.wtf",
+			expected:      true,
+		},
+		{
+			desc:          "unknown challenge type",
+			challengeType: "foo",
+			domain:        "traefik.wtf",
+		},
+	}
+
+	for _, test := range testCases {
+		deleted, err := p.DeletePendingChallenge(context.Background(), test.challengeType, test.token, test.domain)
+		require.NoError(t, err, test.desc)
+		assert.Equal(t, test.expected, deleted != nil, test.desc)
+		if deleted != nil {
+			assert.Equal(t, test.challengeType, deleted.Type, test.desc)
+			assert.Equal(t, test.domain, deleted.Domain, test.desc)
+		}
+	}
+
+	challenges, err := p.GetPendingChallenges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, challenges)
+}
+
+func TestDeleteHTTPChallengeToken(t *testing.T) {
+	store, cleanUp := newTestLocalStore(t)
+	defer cleanUp()
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "www.traefik.wtf", []byte("keyAuth")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "other", "traefik.wtf", []byte("keyAuth")))
+
+	p := &Provider{Store: store}
+
+	deleted, err := p.DeleteHTTPChallengeToken(context.Background(), "unknown", "")
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+
+	// Scoped by domain
+	deleted, err = p.DeleteHTTPChallengeToken(context.Background(), "token", "WWW.traefik.wtf")
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, "www.traefik.wtf", deleted[0].Domain)
+	assert.Equal(t, "token", deleted[0].Token)
+	assert.NotEmpty(t, deleted[0].KeyAuthSHA256)
+
+	deleted, err = p.DeleteHTTPChallengeToken(context.Background(), "token", "")
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, "traefik.wtf", deleted[0].Domain)
+
+	// The removal is persisted
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		_, ok := storedData.HTTPChallenges["token"]
+		return !ok && len(storedData.HTTPChallenges["other"]) == 1
+	})
+
+	challenges, err := p.GetPendingChallenges(context.Background())
+	require.NoError(t, err)
+	require.Len(t, challenges, 1)
+	assert.Equal(t, "other", challenges[0].Token)
+}
@@ -1,6 +1,8 @@
 package acme
 
 import (
+	"context"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -16,6 +18,7 @@ import (
 	"github.com/cenk/backoff"
 	"github.com/containous/flaeg/parse"
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/metrics"
 	"github.com/containous/traefik/rules"
 	"github.com/containous/traefik/safe"
 	traefiktls "github.com/containous/traefik/tls"
@@ -33,20 +36,65 @@ var (
 	OSCPMustStaple = false
 )
 
+// storeCloseTimeout bounds the wait for the pending saves of the store when the provider is stopped
+const storeCloseTimeout = 10 * time.Second
+
 // Configuration holds ACME configuration provided by users
 type Configuration struct {
-	Email         string         `description:"Email address used for registration"`
-	ACMELogging   bool           `description:"Enable debug logging of ACME actions."`
-	CAServer      string         `description:"CA server to use."`
-	Storage       string         `description:"Storage to use."`
-	EntryPoint    string         `description:"EntryPoint to use."`
-	KeyType       string         `description:"KeyType used for generating certificate private key. Allow value 'EC256', 'EC384', 'RSA2048', 'RSA4096', 'RSA8192'. Default to 'RSA4096'"`
-	OnHostRule    bool           `description:"Enable certificate generation on frontends Host rules."`
-	OnDemand      bool           `description:"Enable on demand certificate generation. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."` // Deprecated
-	DNSChallenge  *DNSChallenge  `description:"Activate DNS-01 Challenge"`
-	HTTPChallenge *HTTPChallenge `description:"Activate HTTP-01 Challenge"`
-	TLSChallenge  *TLSChallenge  `description:"Activate TLS-ALPN-01 Challenge"`
-	Domains       []types.Domain `description:"CN and SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='*.main.net'. No SANs for wildcards domain. Wildcard domains only accepted with DNSChallenge"`
+	Email                      string             `description:"Email address used for registration"`
+	ACMELogging                bool               `description:"Enable debug logging of ACME actions."`
+	CAServer                   string             `description:"CA server to use."`
+	CACertificates             []string           `description:"Files of PEM encoded CA certificates trusted for the HTTPS calls to the CA server, in addition to the system ones"`
+	CACertificatesSecretRef    *SecretRef         `description:"Kubernetes Secret holding PEM encoded CA certificates trusted for the HTTPS calls to the CA server, in addition to the system ones"`
+	TrustedRoots               []string           `description:"Files of PEM encoded root certificates the issued certificates must chain to, instead of the system ones"`
+	AllowUnverifiedChains      bool               `description:"Store and serve the issued certificates whose chain can not be verified, as the ones of private CAs"`
+	ValidateWithStaging        bool               `description:"Validate the challenges of the domains without certificate against the Let's Encrypt staging CA before placing the production order"`
+	Storage                    string             `description:"Storage to use."`
+	StorageEncryption          *StorageEncryption `description:"Encrypt the ACME storage at rest with AES-256-GCM"`
+	StorageSigning             *StorageSigning    `description:"Sign the ACME storage with an Ed25519 key, and refuse to load a storage with a missing or an invalid signature"`
+	StorageReadOnlyFallback    bool               `description:"Serve the certificates of a storage which can not be written in read-only mode, without saving the changes, instead of failing"`
+	ReadOnly                   bool               `description:"Serve the stored certificates without writing the storage: no account is registered and no certificate is obtained nor renewed"`
+	AuditLog                   string             `description:"File receiving the audit entries of the ACME storage mutations, in JSON. Default to the Traefik log"`
+	CertificateSecrets         *TLSSecrets        `description:"Keep the certificates in per-domain kubernetes.io/tls Secrets, the storage holding the account only"`
+	KubernetesEvents           *KubernetesEvents  `description:"Emit Kubernetes Events for the certificates issuance and renewal outcomes"`
+	StorageUnhealthyThreshold  parse.Duration     `description:"Fail the ping health check when the storage is unhealthy for longer than this duration. Disabled when empty"`
+	ExpiryAlerts               *ExpiryAlerts      `description:"Notify the stored certificates close to their expiry which are not renewed"`
+	DeployHooks                []DeployHook       `description:"Commands to run once a certificate is issued or renewed and persisted"`
+	StorageDrift               *StorageDrift      `description:"Periodically compare the storage with the data in memory, and reconcile their differences"`
+	StorageSaveQuietPeriod     parse.Duration     `description:"Coalesce the saves of the storage until none happens for this duration, storageMaxSaveDelay at most. Default to 500ms, only the pending saves are coalesced when negative"`
+	StorageMaxSaveDelay        parse.Duration     `description:"Write the coalesced saves of the storage at most this duration after the first one, up to 1m. Default to 5s"`
+	StoragePollInterval        parse.Duration     `description:"Reload the storage when it is changed by others, checking it at this interval with a jitter of 10%. Disabled when empty"`
+	StorageCacheTTL            parse.Duration     `description:"Cache the account and the certificates read from the storage for this duration. Disabled when empty"`
+	StorageRevisions           int                `description:"Keep this number of revisions of the storage, written when its account or its certificates change, to roll back to. Disabled when empty"`
+	StorageMissing             string             `description:"Behavior when the storage does not exist at start: createEmpty to start with an empty storage, waitFor to wait until it is created, or fail. Default to createEmpty"`
+	StorageLoadTimeout         parse.Duration     `description:"Retry the first load of the storage with an exponential backoff and a jitter for this duration at most. Disabled when empty"`
+	StorageCircuitBreaker      *StorageBreaker    `description:"Suspend the writes of the storage after consecutive failures, keeping the latest data in memory until a probe writes it"`
+	StorageLocalCache          string             `description:"Mirror the account and the certificates to this local file after each save, to serve them in read-only mode when the storage can not be loaded at start. Disabled when empty"`
+	StorageConsistencyInterval parse.Duration     `description:"Check at this interval that the storage holds the data in memory, writing it again when it does not. Disabled when empty"`
+	StorageFailMode            string             `description:"Behavior when an obtained certificate can not be persisted: serveStale to serve it anyway, or strict to serve it once persisted only, retrying its issuance once the storage recovers. Default to serveStale"`
+	StorageCanary              *StorageCanary     `description:"Check on start that the storage can be written, read back and decrypted with a canary entry, deleted once checked"`
+	EntryPoint                 string             `description:"EntryPoint to use."`
+	KeyType                    string             `description:"KeyType used for generating certificate private key. Allow value 'EC256', 'EC384', 'RSA2048', 'RSA4096', 'RSA8192'. Default to 'RSA4096'"`
+	AccountKeyType             string             `description:"KeyType used for generating the account private key. Allow value 'EC256', 'EC384', 'RSA2048', 'RSA4096'. Default to 'RSA4096'"`
+	DomainsKeyType             []DomainKeyType    `description:"KeyType overrides used for generating the certificate private key of specific domains"`
+	DomainsChallenge           []DomainChallenge  `description:"Challenge type overrides used for validating specific domains"`
+	RenewBefore                parse.Duration     `description:"Renew the certificates when less than this duration is left before their expiry. Default to 720h (30 days)"`
+	DomainsRenewBefore         []DomainRenewal    `description:"Renewal window overrides of the certificates of specific domains"`
+	StaggerRenewals            bool               `description:"Spread the renewals of the certificates over the first half of their renewal window, with a random offset stored with each certificate"`
+	DomainsMustStaple          []string           `description:"Domains (or wildcard domains) whose certificates are issued with the OCSP Must-Staple extension, and served with their OCSP response only"`
+	DomainsMetadata            []DomainMetadata   `description:"Metadata of the certificates of specific domains"`
+	OnHostRule                 bool               `description:"Enable certificate generation on frontends Host rules."`
+	OnDemand                   bool               `description:"Enable on demand certificate generation. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."` // Deprecated
+	OnDemandQueueTTL           parse.Duration     `description:"Duration after which a queued on demand domain which has not been requested again is dropped. Default to 24h"`
+	DNSChallenge               *DNSChallenge      `description:"Activate DNS-01 Challenge"`
+	HTTPChallenge              *HTTPChallenge     `description:"Activate HTTP-01 Challenge"`
+	TLSChallenge               *TLSChallenge      `description:"Activate TLS-ALPN-01 Challenge"`
+	EphemeralChallenges        bool               `description:"Keep the HTTP-01 and TLS-ALPN-01 challenges in memory only, without persisting them in the storage."`
+	EphemeralDefaultCert       bool               `description:"Generate the default certificate of the entry points on each start, without persisting it in the storage."`
+	RenewalInfoRefreshInterval parse.Duration     `description:"Interval between two refreshes of the ACME Renewal Information (ARI) of the certificates. Default to 6h"`
+	ChainRefreshInterval       parse.Duration     `description:"Interval between two refreshes of the intermediate chains of the certificates from the CA. Default to 24h"`
+	AccountKeySecretRef        *SecretRef         `description:"Kubernetes Secret holding the PEM encoded account private key to use instead of generating one"`
+	Domains                    []types.Domain     `description:"CN and SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='*.main.net'. No SANs for wildcards domain. Wildcard domains only accepted with DNSChallenge"`
 }
 
 // Provider holds configurations of the provider.
@@ -55,42 +103,123 @@ type Provider struct {
 	Store                  Store
 	certificates           []*Certificate
 	account                *Account
-	client                 *acme.Client
+	clients                map[string]*acme.Client
+	stagingClients         map[string]*acme.Client
 	certsChan              chan *Certificate
 	configurationChan      chan<- types.ConfigMessage
 	certificateStore       *traefiktls.CertificateStore
 	clientMutex            sync.Mutex
-	configFromListenerChan chan types.Configuration
+	configFromListenerChan chan types.ConfigMessage
 	pool                   *safe.Pool
 	resolvingDomains       map[string]struct{}
 	resolvingDomainsMutex  sync.RWMutex
+	certificateIndex       *certificateIndex
+	metricsRegistry        metrics.Registry
+	renewalInfoOnce        sync.Once
+	renewalInfoURL         string
+	passiveLogged          int32
+	events                 *eventRecorder
+	tracing                *issuanceTracer
+	timings                *issuanceTimings
+	expiry                 *expiryWatcher
+	storageReloads         chan chan error
+	certificateUpdates     chan *certificateUpdate
+	certificateInjections  chan *certificateInjection
+	dnsCredentials         *dnsCredentials
+	storageLoadResolved    int32
+	servingCache           bool
+	unpersistedIssuances   int32
+	renewals               renewalResults
+	reloads                storageReloadGroup
+	driftStatus            storageDriftRecorder
+	trustedRoots           *x509.CertPool
+	refreshingChains       int32
+	defaultCertificate     *tls.Certificate
+	defaultCertificateLock sync.Mutex
 }
 
 // Certificate is a struct which contains all data needed from an ACME certificate
 type Certificate struct {
-	Domain      types.Domain
-	Certificate []byte
-	Key         []byte
+	Domain        types.Domain
+	Certificate   []byte
+	Key           []byte
+	KeyType       acme.KeyType
+	ChallengeType string            `json:",omitempty"`
+	RenewalInfo   *RenewalInfo      `json:",omitempty"`
+	RenewBefore   time.Duration     `json:",omitempty"`
+	MustStaple    bool              `json:",omitempty"`
+	OCSPStaple    []byte            `json:",omitempty"`
+	SCTs          []SCT             `json:",omitempty"`
+	EncryptedKey  *encryptedField   `json:",omitempty"`
+	RenewalPaused bool              `json:",omitempty"`
+	Metadata      map[string]string `json:",omitempty"`
+	// External is set on the certificates obtained outside of ACME and injected with the API, which are never renewed
+	External bool `json:",omitempty"`
+	// CertURL is the URL of the certificate at the CA, its intermediate chain being downloaded again from it
+	CertURL string `json:",omitempty"`
+	// RenewalOffset delays the renewal after the start of the renewal window RenewalOffsetWindow it was drawn for
+	RenewalOffset       time.Duration `json:",omitempty"`
+	RenewalOffsetWindow time.Duration `json:",omitempty"`
+}
+
+// copyCertificate returns a deep copy of the certificate
+func copyCertificate(cert *Certificate) *Certificate {
+	if cert == nil {
+		return nil
+	}
+
+	certCopy := &Certificate{
+		Domain:        types.Domain{Main: cert.Domain.Main},
+		Certificate:   append([]byte(nil), cert.Certificate...),
+		Key:           append([]byte(nil), cert.Key...),
+		KeyType:       cert.KeyType,
+		ChallengeType: cert.ChallengeType,
+		RenewBefore:   cert.RenewBefore,
+		MustStaple:    cert.MustStaple,
+		OCSPStaple:    append([]byte(nil), cert.OCSPStaple...),
+		SCTs:          append([]SCT(nil), cert.SCTs...),
+		RenewalPaused: cert.RenewalPaused,
+		Metadata:      mergeMetadata(cert.Metadata, nil),
+		External:      cert.External,
+		CertURL:       cert.CertURL,
+		// The renewal offset is kept with the renewal window it was drawn for
+		RenewalOffset:       cert.RenewalOffset,
+		RenewalOffsetWindow: cert.RenewalOffsetWindow,
+	}
+
+	if cert.Domain.SANs != nil {
+		certCopy.Domain.SANs = append([]string(nil), cert.Domain.SANs...)
+	}
+
+	if cert.RenewalInfo != nil {
+		renewalInfo := *cert.RenewalInfo
+		certCopy.RenewalInfo = &renewalInfo
+	}
+
+	return certCopy
 }
 
 // DNSChallenge contains DNS challenge Configuration
 type DNSChallenge struct {
-	Provider         string         `description:"Use a DNS-01 based challenge provider rather than HTTPS."`
-	DelayBeforeCheck parse.Duration `description:"Assume DNS propagates after a delay in seconds rather than finding and querying nameservers."`
-	preCheckTimeout  time.Duration
-	preCheckInterval time.Duration
+	Provider             string                `description:"Use a DNS-01 based challenge provider rather than HTTPS."`
+	DelayBeforeCheck     parse.Duration        `description:"Assume DNS propagates after a delay in seconds rather than finding and querying nameservers."`
+	CredentialsSecretRef *CredentialsSecretRef `description:"Kubernetes Secret holding the credentials of the DNS provider, by environment variable name"`
+	preCheckTimeout      time.Duration
+	preCheckInterval     time.Duration
 }
 
 // HTTPChallenge contains HTTP challenge Configuration
 type HTTPChallenge struct {
-	EntryPoint string `description:"HTTP challenge EntryPoint"`
+	EntryPoint    string         `description:"HTTP challenge EntryPoint"`
+	TokenTTL      parse.Duration `description:"Duration after which a pending HTTP challenge token is removed. Default to 1h"`
+	TokenValidity parse.Duration `description:"Duration during which a pending HTTP challenge token is served. Default to the token TTL"`
 }
 
 // TLSChallenge contains TLS challenge Configuration
 type TLSChallenge struct{}
 
 // SetConfigListenerChan initializes the configFromListenerChan
-func (p *Provider) SetConfigListenerChan(configFromListenerChan chan types.Configuration) {
+func (p *Provider) SetConfigListenerChan(configFromListenerChan chan types.ConfigMessage) {
 	p.configFromListenerChan = configFromListenerChan
 }
 
@@ -101,12 +230,23 @@ func (p *Provider) SetCertificateStore(certificateStore *traefiktls.CertificateS
 
 // ListenConfiguration sets a new Configuration into the configFromListenerChan
 func (p *Provider) ListenConfiguration(config types.Configuration) {
-	p.configFromListenerChan <- config
+	p.configFromListenerChan <- types.ConfigMessage{Configuration: &config}
+}
+
+// ListenConfigMessage sets a new Configuration into the configFromListenerChan, with the name of its provider
+func (p *Provider) ListenConfigMessage(configMsg types.ConfigMessage) {
+	p.configFromListenerChan <- configMsg
 }
 
 // ListenRequest resolves new certificates for a domain from an incoming request and return a valid Certificate to serve (onDemand option)
+// The domain is queued in the store until its certificate is obtained, the issuance resumes after a restart.
 func (p *Provider) ListenRequest(domain string) (*tls.Certificate, error) {
+	p.queueOnDemandDomain(domain)
+
 	acmeCert, err := p.resolveCertificate(types.Domain{Main: domain}, false)
+	if err == nil {
+		p.dequeueOnDemandDomain(domain)
+	}
 	if acmeCert == nil || err != nil {
 		return nil, err
 	}
@@ -129,38 +269,165 @@ func (p *Provider) Init(_ types.Constraints) error {
 		return errors.New("no store found for the ACME provider")
 	}
 
-	var err error
-	p.account, err = p.Store.GetAccount()
+	if p.KubernetesEvents != nil {
+		events, err := newInClusterEventRecorder(p.KubernetesEvents)
+		if err != nil {
+			logger().Infof("Unable to create the Kubernetes Events recorder, the ACME Events are disabled: %v", err)
+		}
+		p.events = events
+	}
+
+	if err := checkSaveDelays(time.Duration(p.StorageSaveQuietPeriod), time.Duration(p.StorageMaxSaveDelay)); err != nil {
+		return err
+	}
+
+	if err := checkMissingStoragePolicy(p.StorageMissing); err != nil {
+		return err
+	}
+
+	if err := checkStorageFailMode(p.StorageFailMode); err != nil {
+		return err
+	}
+
+	if err := checkDomainsMetadata(p.DomainsMetadata); err != nil {
+		return err
+	}
+
+	if p.StorageCanary != nil {
+		if err := p.StorageCanary.checkOnFailure(); err != nil {
+			return err
+		}
+	}
+
+	if len(p.CACertificates) > 0 || p.CACertificatesSecretRef != nil {
+		if err := p.initCACertificates(getInClusterSecretData); err != nil {
+			return err
+		}
+	}
+
+	if err := p.initTrustedRoots(); err != nil {
+		return err
+	}
+
+	if p.DNSChallenge != nil && p.DNSChallenge.CredentialsSecretRef != nil {
+		if err := p.initDNSCredentials(getInClusterSecretData, dns.NewDNSChallengeProviderByName); err != nil {
+			return err
+		}
+	}
+
+	if p.StorageDrift != nil {
+		if err := p.StorageDrift.checkPolicy(); err != nil {
+			return err
+		}
+	}
+
+	if p.ExpiryAlerts != nil && p.ExpiryAlerts.Webhook != nil && p.expiry == nil {
+		notifier, err := newWebhookNotifier(p.ExpiryAlerts.Webhook)
+		if err != nil {
+			logger().Errorf("Unable to create the expiry notifications webhook, the expiry notifications are disabled: %v", err)
+		} else {
+			p.expiry = newExpiryWatcher(p.ExpiryAlerts, notifier)
+		}
+	}
+
+	if p.ReadOnly {
+		store, ok := unwrapStore(p.Store).(readOnlyStore)
+		if !ok {
+			return errors.New("the ACME store does not support the read-only mode")
+		}
+		store.SetReadOnly(true)
+	}
+
+	p.checkStoreCapabilities()
+
+	if store, ok := unwrapStore(p.Store).(permissionsStore); ok {
+		if err := store.CheckPermissions(); err != nil {
+			return err
+		}
+	}
+
+	// The account is read on the first load of the storage, retried while the storage can not be reached
+	err := p.retryStorageLoad(func() error {
+		return p.tracing.traceStore(p.Store, storeOperationLoad, "", func() error {
+			var err error
+			p.account, err = p.Store.GetAccount(p.getContext())
+			return err
+		})
+	})
+	if err != nil && p.loadStorageCache(err) {
+		p.account, err = p.Store.GetAccount(p.getContext())
+	}
 	if err != nil {
 		return fmt.Errorf("unable to get ACME account : %v", err)
 	}
 
 	// Reset Account if caServer changed, thus registration URI can be updated
 	if p.account != nil && p.account.Registration != nil && !isAccountMatchingCaServer(p.account.Registration.URI, p.CAServer) {
-		log.Info("Account URI does not match the current CAServer. The account will be reset")
+		logger().Info("Account URI does not match the current CAServer. The account will be reset")
 		p.account = nil
 	}
 
-	p.certificates, err = p.Store.GetCertificates()
+	if p.AccountKeySecretRef != nil {
+		if err = p.initAccountKeyFromSecret(getInClusterSecretData); err != nil {
+			return fmt.Errorf("unable to get ACME account private key : %v", err)
+		}
+	}
+
+	err = p.tracing.traceStore(p.Store, storeOperationLoad, "", func() error {
+		var err error
+		p.certificates, err = p.Store.GetCertificates(p.getContext())
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("unable to get ACME certificates : %v", err)
 	}
 
+	if err = p.checkStorageCanary(); err != nil {
+		return err
+	}
+
+	p.certificateIndex = newCertificateIndex(p.certificates)
+
 	// Init the currently resolved domain map
 	p.resolvingDomains = make(map[string]struct{})
 
 	return nil
 }
 
+// initAccountKeyFromSecret sets the private key of the account from the referenced Secret
+// The private key is never copied into the store, it is read from the Secret on each start
+func (p *Provider) initAccountKeyFromSecret(getSecretData secretDataGetter) error {
+	privateKey, privateKeyType, err := loadAccountKeyFromSecret(p.AccountKeySecretRef, getSecretData)
+	if err != nil {
+		return err
+	}
+
+	account := &Account{Email: p.Email, KeyType: GetKeyType(p.KeyType)}
+	if p.account != nil {
+		existingAccount := *p.account
+		account = &existingAccount
+	}
+
+	if len(account.Email) == 0 {
+		account.Email = p.Email
+	}
+	account.PrivateKey = privateKey
+	account.PrivateKeyType = privateKeyType
+
+	logger().Infof("Using the ACME account private key from the Secret %q.", p.AccountKeySecretRef)
+	p.account = account
+	return nil
+}
+
 func isAccountMatchingCaServer(accountURI string, serverURI string) bool {
 	aru, err := url.Parse(accountURI)
 	if err != nil {
-		log.Infof("Unable to parse account.Registration URL : %v", err)
+		logger().Infof("Unable to parse account.Registration URL : %v", err)
 		return false
 	}
 	cau, err := url.Parse(serverURI)
 	if err != nil {
-		log.Infof("Unable to parse CAServer URL : %v", err)
+		logger().Infof("Unable to parse CAServer URL : %v", err)
 		return false
 	}
 	return cau.Hostname() == aru.Hostname()
@@ -177,24 +444,38 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 	p.configurationChan = configurationChan
 	p.refreshCertificates()
 
+	p.reconcileDNSChallenges(dns.NewDNSChallengeProviderByName)
+
 	p.deleteUnnecessaryDomains()
-	for i := 0; i < len(p.Domains); i++ {
-		domain := p.Domains[i]
+	p.resolveDomains()
+	p.resumeOnDemandQueue()
+	p.resumeDesiredDomains()
+
+	// Update the account contact as soon as possible when the email changed
+	if !p.isPassive() && p.account != nil && p.account.Registration != nil && len(p.Email) > 0 && p.account.Email != p.Email {
 		safe.Go(func() {
-			if _, err := p.resolveCertificate(domain, true); err != nil {
-				log.Errorf("Unable to obtain ACME certificate for domains %q : %v", strings.Join(domain.ToStrArray(), ","), err)
+			if _, err := p.getClient(); err != nil {
+				logger().Errorf("Unable to get ACME client to update the account email: %v", err)
 			}
 		})
 	}
 
 	p.renewCertificates()
+	p.watchHTTPChallengeTokens()
 
-	ticker := time.NewTicker(24 * time.Hour)
+	renewInterval := 24 * time.Hour
+	if p.getRenewalInfoRefreshInterval() < renewInterval {
+		renewInterval = p.getRenewalInfoRefreshInterval()
+	}
+
+	ticker := time.NewTicker(renewInterval)
 	pool.Go(func(stop chan bool) {
 		for {
 			select {
 			case <-ticker.C:
 				p.renewCertificates()
+				p.removeExpiredOnDemandRequests()
+				p.resumeDesiredDomains()
 			case <-stop:
 				ticker.Stop()
 				return
@@ -202,29 +483,81 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 		}
 	})
 
+	p.watchExpiry()
+	p.watchChains()
+	p.reconcileStorageCache()
+	p.closeStoreOnStop()
+
 	return nil
 }
 
+// getContext returns the context of the calls of the store made by the routines of the provider, done at the shutdown
+func (p *Provider) getContext() context.Context {
+	if p.pool == nil {
+		return context.Background()
+	}
+	return p.pool.Ctx()
+}
+
+// closeStoreOnStop closes the store when the provider is stopped, once its pending saves are written
+func (p *Provider) closeStoreOnStop() {
+	p.pool.Go(func(stop chan bool) {
+		<-stop
+
+		ctx, cancel := context.WithTimeout(context.Background(), storeCloseTimeout)
+		defer cancel()
+
+		if err := p.Store.Close(ctx); err != nil {
+			logger().Errorf("Unable to close the ACME storage: %v", err)
+		}
+	})
+}
+
+// resolveDomains obtains the certificates of the domains of the configuration
+func (p *Provider) resolveDomains() {
+	for i := 0; i < len(p.Domains); i++ {
+		domain := p.Domains[i]
+		safe.Go(func() {
+			if _, err := p.resolveCertificate(domain, true); err != nil {
+				domainsLogger(domain.ToStrArray()).Errorf("Unable to obtain ACME certificate for domains %q : %v", strings.Join(domain.ToStrArray(), ","), err)
+			}
+		})
+	}
+}
+
 func (p *Provider) getClient() (*acme.Client, error) {
+	return p.getChallengeClient(p.getChallengeType())
+}
+
+// getChallengeClient returns the ACME client solving the challenges of the given type
+func (p *Provider) getChallengeClient(challengeType string) (*acme.Client, error) {
+	if p.isPassive() {
+		return nil, ErrReadOnly
+	}
+
 	p.clientMutex.Lock()
 	defer p.clientMutex.Unlock()
 
-	if p.client != nil {
-		return p.client, nil
+	if client, ok := p.clients[challengeType]; ok {
+		return client, nil
 	}
 
+	// The account is registered and saved by one store at a time
+	held, release, err := p.lock(lockKeyAccount)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	account, err := p.initAccount()
 	if err != nil {
 		return nil, err
 	}
 
-	log.Debug("Building ACME client...")
+	logger().Debug("Building ACME client...")
 
-	caServer := "https://acme-v02.api.letsencrypt.org/directory"
-	if len(p.CAServer) > 0 {
-		caServer = p.CAServer
-	}
-	log.Debug(caServer)
+	caServer := p.getCAServer()
+	logger().Debug(caServer)
 
 	client, err := acme.NewClient(caServer, account, account.KeyType)
 	if err != nil {
@@ -232,8 +565,19 @@ func (p *Provider) getClient() (*acme.Client, error) {
 	}
 
 	// New users will need to register; be sure to save it
+	if account.GetRegistration() == nil && p.AccountKeySecretRef != nil {
+		logger().Info("Looking for an existing account bound to the private key...")
+
+		reg, err := client.ResolveAccountByKey()
+		if err != nil {
+			logger().Infof("No existing account found for the private key: %v", err)
+		} else {
+			account.Registration = reg
+		}
+	}
+
 	if account.GetRegistration() == nil {
-		log.Info("Register...")
+		logger().Info("Register...")
 
 		reg, err := client.Register(true)
 		if err != nil {
@@ -241,34 +585,81 @@ func (p *Provider) getClient() (*acme.Client, error) {
 		}
 
 		account.Registration = reg
+		registeredAt := time.Now()
+		account.RegisteredAt = &registeredAt
+	} else if len(p.Email) > 0 && account.Email != p.Email {
+		logger().Infof("The ACME account email changed from %q to %q, updating the account contact...", account.Email, p.Email)
+
+		if err = updateAccountContact(caServer, account, p.Email); err != nil {
+			logger().Errorf("Unable to update the ACME account contact to %q, the CA server keeps sending notifications to %q: %v", p.Email, account.Email, err)
+		} else {
+			logger().Infof("The ACME account contact has been updated to %q.", p.Email)
+			account.Email = p.Email
+		}
 	}
 
 	// Save the account once before all the certificates generation/storing
 	// No certificate can be generated if account is not initialized
-	err = p.Store.SaveAccount(account)
+	accountToStore := account
+	if p.AccountKeySecretRef != nil {
+		// The private key stays in the referenced Secret
+		storedAccount := *account
+		storedAccount.PrivateKey = nil
+		accountToStore = &storedAccount
+	}
+
+	if err = checkLockHeld(held); err != nil {
+		return nil, err
+	}
+
+	err = p.tracing.traceStore(p.Store, storeOperationSave, "", func() error {
+		return p.Store.SaveAccount(p.getContext(), accountToStore)
+	})
 	if err != nil {
+		p.events.storageFailed(err)
 		return nil, err
 	}
 
-	if p.DNSChallenge != nil && len(p.DNSChallenge.Provider) > 0 {
-		log.Debugf("Using DNS Challenge provider: %s", p.DNSChallenge.Provider)
+	if err = p.setChallengeProvider(client, challengeType); err != nil {
+		return nil, err
+	}
+
+	if p.clients == nil {
+		p.clients = make(map[string]*acme.Client)
+	}
+	p.clients[challengeType] = client
+	return client, nil
+}
+
+// setChallengeProvider sets the provider solving the challenges of the given type on the client, the other challenges being excluded
+func (p *Provider) setChallengeProvider(client *acme.Client, challengeType string) error {
+	switch {
+	case challengeType == challengeTypeDNS01 && p.isChallengeConfigured(challengeTypeDNS01):
+		logger().WithField(logFieldChallengeType, challengeType).Debugf("Using DNS Challenge provider: %s", p.DNSChallenge.Provider)
 
-		err = dnsOverrideDelay(p.DNSChallenge.DelayBeforeCheck)
+		err := dnsOverrideDelay(p.DNSChallenge.DelayBeforeCheck)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		p.tracing.tracePreCheckDNS()
 
 		var provider acme.ChallengeProvider
 		provider, err = dns.NewDNSChallengeProviderByName(p.DNSChallenge.Provider)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		client.ExcludeChallenges([]acme.Challenge{acme.HTTP01, acme.TLSALPN01})
 
-		err = client.SetChallengeProvider(acme.DNS01, provider)
+		challenge := &challengeDNS{provider: provider, providerName: p.DNSChallenge.Provider, Store: p.Store, metricsRegistry: p.metricsRegistry, tracing: p.tracing, timings: p.timings}
+		if p.dnsCredentials != nil {
+			challenge.credentials = p.dnsCredentials
+			challenge.newProvider = dns.NewDNSChallengeProviderByName
+		}
+
+		err = client.SetChallengeProvider(acme.DNS01, challenge)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		// Same default values than LEGO
@@ -280,41 +671,61 @@ func (p *Provider) getClient() (*acme.Client, error) {
 			p.DNSChallenge.preCheckTimeout, p.DNSChallenge.preCheckInterval = challengeProviderTimeout.Timeout()
 		}
 
-	} else if p.HTTPChallenge != nil && len(p.HTTPChallenge.EntryPoint) > 0 {
-		log.Debug("Using HTTP Challenge provider.")
+	case challengeType == challengeTypeHTTP01 && p.isChallengeConfigured(challengeTypeHTTP01):
+		logger().WithField(logFieldChallengeType, challengeType).Debug("Using HTTP Challenge provider.")
 
 		client.ExcludeChallenges([]acme.Challenge{acme.DNS01, acme.TLSALPN01})
 
-		err = client.SetChallengeProvider(acme.HTTP01, &challengeHTTP{Store: p.Store})
+		err := client.SetChallengeProvider(acme.HTTP01, &challengeHTTP{Store: p.Store, metricsRegistry: p.metricsRegistry, tracing: p.tracing, timings: p.timings})
 		if err != nil {
-			return nil, err
+			return err
 		}
-	} else if p.TLSChallenge != nil {
-		log.Debug("Using TLS Challenge provider.")
+	case challengeType == challengeTypeTLSALPN01 && p.isChallengeConfigured(challengeTypeTLSALPN01):
+		logger().WithField(logFieldChallengeType, challengeType).Debug("Using TLS Challenge provider.")
 
 		client.ExcludeChallenges([]acme.Challenge{acme.HTTP01, acme.DNS01})
 
-		err = client.SetChallengeProvider(acme.TLSALPN01, &challengeTLSALPN{Store: p.Store})
+		err := client.SetChallengeProvider(acme.TLSALPN01, &challengeTLSALPN{Store: p.Store, metricsRegistry: p.metricsRegistry, tracing: p.tracing, timings: p.timings})
 		if err != nil {
-			return nil, err
+			return err
 		}
-	} else {
-		return nil, errors.New("ACME challenge not specified, please select TLS or HTTP or DNS Challenge")
+	default:
+		return errors.New("ACME challenge not specified, please select TLS or HTTP or DNS Challenge")
 	}
 
-	p.client = client
-	return p.client, nil
+	return nil
+}
+
+func (p *Provider) getCAServer() string {
+	if len(p.CAServer) > 0 {
+		return p.CAServer
+	}
+	return "https://acme-v02.api.letsencrypt.org/directory"
 }
 
 func (p *Provider) initAccount() (*Account, error) {
 	if p.account == nil || len(p.account.Email) == 0 {
 		var err error
-		p.account, err = NewAccount(p.Email, p.KeyType)
+		p.account, err = NewAccount(p.Email, p.KeyType, p.AccountKeyType)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// Set the PrivateKeyType if not already defined in the account, an unknown type being left empty
+	if len(p.account.PrivateKeyType) == 0 && len(p.account.PrivateKey) > 0 {
+		privateKeyType, err := inferPrivateKeyType(p.account.PrivateKey)
+		if err != nil {
+			logger().Warnf("The ACME account private key type is left empty: %v", err)
+		} else {
+			p.account.PrivateKeyType = privateKeyType
+		}
+	}
+
+	if len(p.AccountKeyType) > 0 && GetAccountKeyType(p.AccountKeyType) != p.account.PrivateKeyType {
+		logger().Warnf("The ACME account private key type is %s but %s is configured: the existing account key is kept.", p.account.PrivateKeyType, GetAccountKeyType(p.AccountKeyType))
+	}
+
 	// Set the KeyType if not already defined in the account
 	if len(p.account.KeyType) == 0 {
 		p.account.KeyType = GetKeyType(p.KeyType)
@@ -327,22 +738,23 @@ func (p *Provider) watchNewDomains() {
 	p.pool.Go(func(stop chan bool) {
 		for {
 			select {
-			case config := <-p.configFromListenerChan:
-				for _, frontend := range config.Frontends {
+			case configMsg := <-p.configFromListenerChan:
+				var desiredDomains []types.Domain
+				for _, frontend := range configMsg.Configuration.Frontends {
 					for _, route := range frontend.Routes {
 						domainRules := rules.Rules{}
 						domains, err := domainRules.ParseDomains(route.Rule)
 						if err != nil {
-							log.Errorf("Error parsing domains in provider ACME: %v", err)
+							logger().Errorf("Error parsing domains in provider ACME: %v", err)
 							continue
 						}
 
 						if len(domains) == 0 {
-							log.Debugf("No domain parsed in rule %q in provider ACME", route.Rule)
+							logger().Debugf("No domain parsed in rule %q in provider ACME", route.Rule)
 							continue
 						}
 
-						log.Debugf("Try to challenge certificate for domain %v founded in Host rule", domains)
+						domainsLogger(domains).Debugf("Try to challenge certificate for domain %v founded in Host rule", domains)
 
 						var domain types.Domain
 						if len(domains) > 0 {
@@ -350,15 +762,17 @@ func (p *Provider) watchNewDomains() {
 							if len(domains) > 1 {
 								domain.SANs = domains[1:]
 							}
+							desiredDomains = append(desiredDomains, domain)
 
 							safe.Go(func() {
 								if _, err := p.resolveCertificate(domain, false); err != nil {
-									log.Errorf("Unable to obtain ACME certificate for domains %q detected thanks to rule %q : %v", strings.Join(domains, ","), route.Rule, err)
+									domainsLogger(domains).Errorf("Unable to obtain ACME certificate for domains %q detected thanks to rule %q : %v", strings.Join(domains, ","), route.Rule, err)
 								}
 							})
 						}
 					}
 				}
+				p.updateDesiredDomains(configMsg.ProviderName, desiredDomains)
 			case <-stop:
 				return
 			}
@@ -366,7 +780,11 @@ func (p *Provider) watchNewDomains() {
 	})
 }
 
-func (p *Provider) resolveCertificate(domain types.Domain, domainFromConfigurationFile bool) (*acme.CertificateResource, error) {
+func (p *Provider) resolveCertificate(domain types.Domain, domainFromConfigurationFile bool) (_ *acme.CertificateResource, err error) {
+	if p.isPassive() {
+		return nil, nil
+	}
+
 	domains, err := p.getValidDomains(domain, domainFromConfigurationFile)
 	if err != nil {
 		return nil, err
@@ -381,22 +799,68 @@ func (p *Provider) resolveCertificate(domain types.Domain, domainFromConfigurati
 	p.addResolvingDomains(uncheckedDomains)
 	defer p.removeResolvingDomains(uncheckedDomains)
 
-	log.Debugf("Loading ACME certificates %+v...", uncheckedDomains)
+	// The certificate of the domains is obtained by one store at a time
+	held, release, err := p.lock(getCertificateLockKey(uncheckedDomains))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// The order is deferred when it would exceed a known limit of the CA, the next attempts are made by the next configurations
+	if err = p.checkOrderLimits(domains); err != nil {
+		return nil, err
+	}
+
+	challengeType := p.getDomainChallengeType(domain, nil)
+	logger := domainsLogger(uncheckedDomains).WithField(logFieldChallengeType, challengeType)
+	logger.Debugf("Loading ACME certificates %+v...", uncheckedDomains)
+
+	span := p.tracing.startIssuance(spanObtain, uncheckedDomains, challengeType)
+	defer func() { p.tracing.endIssuance(uncheckedDomains, span, err) }()
+
+	// The timing of a successful issuance ends once its certificate is persisted
+	timing := p.timings.start(uncheckedDomains, challengeType)
+	defer func() {
+		if err != nil {
+			p.timings.failed(uncheckedDomains, timing)
+		}
+	}()
 
-	client, err := p.getClient()
+	client, err := p.getChallengeClient(challengeType)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get ACME client %v", err)
 	}
 
+	keyType := p.getKeyType(domain)
+
+	// The challenges of new domains are proven against the staging CA first, not to consume the limits of the production CA
+	if err = p.validateWithStaging(domains, challengeType, keyType); err != nil {
+		p.events.certificateFailed(uncheckedDomains, false, err)
+		return nil, err
+	}
+
+	privateKey, err := generateCertificatePrivateKey(keyType)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate a private key for the domains %v: %v", uncheckedDomains, err)
+	}
+
 	var certificate *acme.CertificateResource
 	bundle := true
-	if p.useCertificateWithRetry(uncheckedDomains) {
-		certificate, err = obtainCertificateWithRetry(domains, client, p.DNSChallenge.preCheckTimeout, p.DNSChallenge.preCheckInterval, bundle)
+	orderSpan := p.tracing.startSpan(spanOrder, uncheckedDomains[0])
+	orderStart := time.Now()
+	mustStaple := p.isMustStaple(domain)
+	if challengeType == challengeTypeDNS01 && p.useCertificateWithRetry(uncheckedDomains) {
+		certificate, err = obtainCertificateWithRetry(domains, client, privateKey, p.DNSChallenge.preCheckTimeout, p.DNSChallenge.preCheckInterval, bundle, mustStaple)
 	} else {
-		certificate, err = client.ObtainCertificate(domains, bundle, nil, OSCPMustStaple)
+		certificate, err = client.ObtainCertificate(domains, bundle, privateKey, mustStaple)
 	}
+	p.timings.phase(timing, issuancePhaseOrder, orderStart)
+	orderSpan.finish(err)
+	p.recordOrder(domains, err)
 
 	if err != nil {
+		countChallenges(p.metricsRegistry, challengeType, challengeOutcomeFailed, len(uncheckedDomains))
+		p.events.certificateFailed(uncheckedDomains, false, err)
 		return nil, fmt.Errorf("unable to generate a certificate for the domains %v: %v", uncheckedDomains, err)
 	}
 	if certificate == nil {
@@ -406,14 +870,25 @@ func (p *Provider) resolveCertificate(domain types.Domain, domainFromConfigurati
 		return nil, fmt.Errorf("domains %v generate certificate with no value: %v", uncheckedDomains, certificate)
 	}
 
-	log.Debugf("Certificates obtained for domains %+v", uncheckedDomains)
+	logger.Debugf("Certificates obtained for domains %+v", uncheckedDomains)
+	countChallenges(p.metricsRegistry, challengeType, challengeOutcomeSolved, len(uncheckedDomains))
+
+	if err = p.verifyObtainedCertificate(domains, certificate.Certificate); err != nil {
+		p.events.certificateFailed(uncheckedDomains, false, err)
+		return nil, err
+	}
+
+	if err = checkLockHeld(held); err != nil {
+		return nil, fmt.Errorf("the certificate of the domains %v is not stored: %v", uncheckedDomains, err)
+	}
 
 	if len(uncheckedDomains) > 1 {
 		domain = types.Domain{Main: uncheckedDomains[0], SANs: uncheckedDomains[1:]}
 	} else {
 		domain = types.Domain{Main: uncheckedDomains[0]}
 	}
-	p.addCertificateForDomain(domain, certificate.Certificate, certificate.PrivateKey)
+	p.addCertificateForDomain(domain, certificate.Certificate, certificate.PrivateKey, keyType, challengeType, certificate.CertURL)
+	p.events.certificateObtained(uncheckedDomains, certificate.Certificate, false)
 
 	return certificate, nil
 }
@@ -462,17 +937,18 @@ func (p *Provider) useCertificateWithRetry(domains []string) bool {
 	return false
 }
 
-func obtainCertificateWithRetry(domains []string, client *acme.Client, timeout, interval time.Duration, bundle bool) (*acme.CertificateResource, error) {
+func obtainCertificateWithRetry(domains []string, client *acme.Client, privateKey crypto.PrivateKey, timeout, interval time.Duration, bundle, mustStaple bool) (*acme.CertificateResource, error) {
 	var certificate *acme.CertificateResource
 	var err error
 
 	operation := func() error {
-		certificate, err = client.ObtainCertificate(domains, bundle, nil, OSCPMustStaple)
+		certificate, err = client.ObtainCertificate(domains, bundle, privateKey, mustStaple)
 		return err
 	}
 
+	logger := domainsLogger(domains).WithField(logFieldChallengeType, challengeTypeDNS01)
 	notify := func(err error, time time.Duration) {
-		log.Errorf("Error obtaining certificate retrying in %s", time)
+		logger.Errorf("Error obtaining certificate retrying in %s", time)
 	}
 
 	// Define a retry backOff to let LEGO tries twice to obtain a certificate for both wildcard and root domain
@@ -483,7 +959,7 @@ func obtainCertificateWithRetry(domains []string, client *acme.Client, timeout,
 
 	err = backoff.RetryNotify(safe.OperationWithRecover(operation), rbo, notify)
 	if err != nil {
-		log.Errorf("Error obtaining certificate: %v", err)
+		logger.Errorf("Error obtaining certificate: %v", err)
 		return nil, err
 	}
 
@@ -496,7 +972,7 @@ func dnsOverrideDelay(delay parse.Duration) error {
 	}
 
 	if delay > 0 {
-		log.Debugf("Delaying %d rather than validating DNS propagation now.", delay)
+		logger().Debugf("Delaying %d rather than validating DNS propagation now.", delay)
 
 		acme.PreCheckDNS = func(_, _ string) (bool, error) {
 			time.Sleep(time.Duration(delay))
@@ -508,8 +984,17 @@ func dnsOverrideDelay(delay parse.Duration) error {
 	return nil
 }
 
-func (p *Provider) addCertificateForDomain(domain types.Domain, certificate []byte, key []byte) {
-	p.certsChan <- &Certificate{Certificate: certificate, Key: key, Domain: domain}
+func (p *Provider) addCertificateForDomain(domain types.Domain, certificate []byte, key []byte, keyType acme.KeyType, challengeType, certURL string) {
+	cert := &Certificate{Certificate: certificate, Key: key, KeyType: keyType, ChallengeType: challengeType, Domain: domain, CertURL: certURL}
+	if crt, err := getX509Certificate(cert); err == nil && crt != nil {
+		cert.RenewBefore = p.getCertificateRenewBefore(domain, crt)
+		p.recordSCTs(cert, crt)
+	}
+	setRenewalOffset(cert, p.StaggerRenewals)
+	cert.MustStaple = p.isMustStaple(domain)
+	cert.Metadata = p.getDomainMetadata(domain)
+	refreshOCSPStaple(cert, time.Now())
+	p.certsChan <- cert
 }
 
 // deleteUnnecessaryDomains deletes from the configuration :
@@ -528,7 +1013,7 @@ func (p *Provider) deleteUnnecessaryDomains() {
 
 			if reflect.DeepEqual(domain, domainToCheck) {
 				if idxDomainToCheck > idxDomain {
-					log.Warnf("The domain %v is duplicated in the configuration but will be process by ACME provider only once.", domainToCheck)
+					domainsLogger(domainToCheck.ToStrArray()).Warnf("The domain %v is duplicated in the configuration but will be process by ACME provider only once.", domainToCheck)
 					keepDomain = false
 				}
 				break
@@ -540,11 +1025,11 @@ func (p *Provider) deleteUnnecessaryDomains() {
 			for _, domainProcessed := range domainToCheck.ToStrArray() {
 				if idxDomain < idxDomainToCheck && isDomainAlreadyChecked(domainProcessed, domain.ToStrArray()) {
 					// The domain is duplicated in a CN
-					log.Warnf("Domain %q is duplicated in the configuration or validated by the domain %v. It will be processed once.", domainProcessed, domain)
+					domainsLogger([]string{domainProcessed}).Warnf("Domain %q is duplicated in the configuration or validated by the domain %v. It will be processed once.", domainProcessed, domain)
 					continue
 				} else if domain.Main != domainProcessed && strings.HasPrefix(domain.Main, "*") && isDomainAlreadyChecked(domainProcessed, []string{domain.Main}) {
 					// Check if a wildcard can validate the domain
-					log.Warnf("Domain %q will not be processed by ACME provider because it is validated by the wildcard %q", domainProcessed, domain.Main)
+					domainsLogger([]string{domainProcessed}).Warnf("Domain %q will not be processed by ACME provider because it is validated by the wildcard %q", domainProcessed, domain.Main)
 					continue
 				}
 				newDomainsToCheck = append(newDomainsToCheck, domainProcessed)
@@ -569,29 +1054,124 @@ func (p *Provider) deleteUnnecessaryDomains() {
 
 func (p *Provider) watchCertificate() {
 	p.certsChan = make(chan *Certificate)
+	p.storageReloads = make(chan chan error)
+	p.certificateUpdates = make(chan *certificateUpdate)
+	p.certificateInjections = make(chan *certificateInjection)
+
+	// The changes of the certificates of the store made by others are served in this routine
+	storeChanges, unsubscribe := p.Store.Subscribe(p.getContext())
+
+	// The drift of the storage is checked in this routine, which owns the certificates in memory
+	var driftTicker *time.Ticker
+	var driftChan <-chan time.Time
+	if p.StorageDrift != nil {
+		driftTicker = time.NewTicker(p.StorageDrift.getInterval())
+		driftChan = driftTicker.C
+	}
+
+	// The consistency of the storage with the data in memory is checked in this routine, as the drift
+	var consistencyTicker *time.Ticker
+	var consistencyChan <-chan time.Time
+	if p.StorageConsistencyInterval > 0 {
+		consistencyTicker = time.NewTicker(time.Duration(p.StorageConsistencyInterval))
+		consistencyChan = consistencyTicker.C
+	}
+
+	// The storage is polled with a jitter, the timer is reset with a new delay after each poll
+	var pollTimer *time.Timer
+	var pollChan <-chan time.Time
+	if p.needsStoragePoll() {
+		pollTimer = time.NewTimer(getPollDelay(time.Duration(p.StoragePollInterval)))
+		pollChan = pollTimer.C
+	}
+
 	p.pool.Go(func(stop chan bool) {
 		for {
 			select {
 			case cert := <-p.certsChan:
+				// The certificate replaced is kept, to be served again when the obtained one can not be persisted in the strict mode
+				var previous *Certificate
 				certUpdated := false
 				for _, domainsCertificate := range p.certificates {
 					if reflect.DeepEqual(cert.Domain, domainsCertificate.Domain) {
+						previous = copyCertificate(domainsCertificate)
+						p.certificateIndex.remove(domainsCertificate)
 						domainsCertificate.Certificate = cert.Certificate
 						domainsCertificate.Key = cert.Key
+						domainsCertificate.KeyType = cert.KeyType
+						domainsCertificate.ChallengeType = cert.ChallengeType
+						domainsCertificate.RenewalInfo = cert.RenewalInfo
+						domainsCertificate.RenewBefore = cert.RenewBefore
+						domainsCertificate.RenewalOffset = cert.RenewalOffset
+						domainsCertificate.RenewalOffsetWindow = cert.RenewalOffsetWindow
+						domainsCertificate.MustStaple = cert.MustStaple
+						domainsCertificate.OCSPStaple = cert.OCSPStaple
+						domainsCertificate.SCTs = cert.SCTs
+						domainsCertificate.External = cert.External
+						domainsCertificate.CertURL = cert.CertURL
+						// The metadata set with the API is carried through the renewals
+						domainsCertificate.Metadata = mergeMetadata(domainsCertificate.Metadata, cert.Metadata)
+						p.certificateIndex.add(domainsCertificate)
 						certUpdated = true
 						break
 					}
 				}
 				if !certUpdated {
 					p.certificates = append(p.certificates, cert)
+					p.certificateIndex.add(cert)
 				}
 
-				err := p.saveCertificates()
+				storageStart := time.Now()
+				err := p.saveObtainedCertificate(cert)
+				p.timings.persisted(cert.Domain.ToStrArray(), storageStart, err)
 				if err != nil {
-					log.Error(err)
+					domainsLogger(cert.Domain.ToStrArray()).WithField(logFieldOperation, storeOperationSave).Errorf("Unable to store the ACME certificate: %v", err)
+					p.events.storageFailed(err)
+					if p.getStorageFailMode() == StorageFailModeStrict {
+						p.withdrawUnpersistedCertificate(cert, previous)
+					}
+				} else {
+					p.runDeployHooks(cert)
+				}
+
+			case <-driftChan:
+				p.reconcileStorage()
+
+			case <-consistencyChan:
+				p.checkStorageConsistency()
+
+			case done := <-p.storageReloads:
+				done <- p.reloadFromStore()
+
+			case update := <-p.certificateUpdates:
+				update.done <- p.applyCertificateUpdate(update.domain, update.update)
+
+			case injection := <-p.certificateInjections:
+				injection.done <- p.applyCertificateInjection(injection.certificate, injection.force)
+
+			case <-pollChan:
+				p.pollStorage()
+				pollTimer.Reset(getPollDelay(time.Duration(p.StoragePollInterval)))
+
+			case change, ok := <-storeChanges:
+				if !ok {
+					// The store is closed
+					storeChanges = nil
+					continue
 				}
+				p.serveStoreChange(change)
 
 			case <-stop:
+				unsubscribe()
+				if driftTicker != nil {
+					driftTicker.Stop()
+				}
+				if consistencyTicker != nil {
+					consistencyTicker.Stop()
+				}
+				if pollTimer != nil {
+					pollTimer.Stop()
+				}
 				return
 			}
 		}
@@ -599,13 +1179,48 @@ func (p *Provider) watchCertificate() {
 }
 
 func (p *Provider) saveCertificates() error {
-	err := p.Store.SaveCertificates(p.certificates)
+	err := p.tracing.traceStore(p.Store, storeOperationSave, "", func() error {
+		return p.Store.SaveCertificates(p.getContext(), p.certificates)
+	})
 
 	p.refreshCertificates()
 
 	return err
 }
 
+// saveObtainedCertificate stores the certificates with the obtained one and removes the HTTP challenge tokens of its domains,
+// in a single update of the store
+func (p *Provider) saveObtainedCertificate(cert *Certificate) error {
+	// The entry of the obtained certificate is updated in place, it is replaced by a copy in the store
+	certificates := make([]*Certificate, 0, len(p.certificates))
+	for _, certificate := range p.certificates {
+		if reflect.DeepEqual(certificate.Domain, cert.Domain) {
+			certificate = copyCertificate(certificate)
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	err := p.tracing.traceStore(p.Store, storeOperationSave, "", func() error {
+		return p.Store.Update(p.getContext(), func(data *StoredData) error {
+			data.Certificates = certificates
+			for _, domain := range cert.Domain.ToStrArray() {
+				removeHTTPChallengesForDomain(data, domain)
+			}
+			return nil
+		})
+	})
+	if err == nil {
+		err = p.waitObtainedCertificatePersisted()
+	}
+
+	// In the strict mode, the certificate which is not persisted is not served
+	if err == nil || p.getStorageFailMode() != StorageFailModeStrict {
+		p.refreshCertificates()
+	}
+
+	return err
+}
+
 func (p *Provider) refreshCertificates() {
 	config := types.ConfigMessage{
 		ProviderName: "ACME",
@@ -616,47 +1231,186 @@ func (p *Provider) refreshCertificates() {
 		},
 	}
 
+	now := time.Now()
 	for _, cert := range p.certificates {
-		certificate := &traefiktls.Certificate{CertFile: traefiktls.FileOrContent(cert.Certificate), KeyFile: traefiktls.FileOrContent(cert.Key)}
+		// A Must-Staple certificate is rejected by the clients without its OCSP response
+		if cert.MustStaple && !isOCSPStapleValid(cert.OCSPStaple, now) {
+			domainsLogger(cert.Domain.ToStrArray()).Errorf("The Must-Staple certificate for domains %v is not served, it has no valid OCSP response.", cert.Domain.ToStrArray())
+			continue
+		}
+
+		certificate := &traefiktls.Certificate{CertFile: traefiktls.FileOrContent(cert.Certificate), KeyFile: traefiktls.FileOrContent(cert.Key), OCSPStaple: traefiktls.FileOrContent(cert.OCSPStaple)}
 		config.Configuration.TLS = append(config.Configuration.TLS, &traefiktls.Configuration{Certificate: certificate, EntryPoints: []string{p.EntryPoint}})
 	}
 	p.configurationChan <- config
 }
 
 func (p *Provider) renewCertificates() {
-	log.Info("Testing certificate renew...")
+	if p.isPassive() {
+		return
+	}
+
+	logger().Info("Testing certificate renew...")
+
+	renewalInfoChanged := p.refreshRenewalInfo(p.certificates)
+	renewBeforeChanged := p.refreshRenewBefore(p.certificates)
+	renewalOffsetsChanged := p.refreshRenewalOffsets(p.certificates)
+	metadataChanged := p.refreshMetadata(p.certificates)
+	staplesChanged := refreshOCSPStaples(p.certificates, time.Now())
+	if renewalInfoChanged || renewBeforeChanged || renewalOffsetsChanged || metadataChanged || staplesChanged {
+		err := p.tracing.traceStore(p.Store, storeOperationSave, "", func() error {
+			return p.Store.SaveCertificates(p.getContext(), p.certificates)
+		})
+		if err != nil {
+			logger().WithField(logFieldOperation, storeOperationSave).Errorf("Unable to store the renewal information of the ACME certificates: %v", err)
+			p.events.storageFailed(err)
+		}
+	}
+	if staplesChanged {
+		p.refreshCertificates()
+	}
+
 	for _, certificate := range p.certificates {
+		logger := domainsLogger(certificate.Domain.ToStrArray())
+
+		setCertificateExpiry(p.metricsRegistry, certificate, false)
+		if certificate.RenewalPaused {
+			logger.Infof("The renewal of the certificate for domains %v is paused, it is not renewed.", certificate.Domain.ToStrArray())
+			continue
+		}
+		if certificate.External {
+			logger.Debugf("The certificate for domains %v is external, it is not renewed.", certificate.Domain.ToStrArray())
+			continue
+		}
+
+		keyType := p.getKeyType(certificate.Domain)
+		keyTypeChanged := false
+		if certificateKeyType, err := getCertificateKeyType(certificate); err == nil && certificateKeyType != keyType {
+			logger.Infof("The key type of the certificate for domains %v changed from %s to %s, the certificate will be re-issued.", certificate.Domain.ToStrArray(), certificateKeyType, keyType)
+			keyTypeChanged = true
+		}
+
+		mustStaple := p.isMustStaple(certificate.Domain)
+		mustStapleChanged := certificate.MustStaple != mustStaple
+		if mustStapleChanged {
+			logger.Infof("The Must-Staple option of the certificate for domains %v changed to %t, the certificate will be re-issued.", certificate.Domain.ToStrArray(), mustStaple)
+		}
+
 		crt, err := getX509Certificate(certificate)
 		// If there's an error, we assume the cert is broken, and needs update
-		// <= 30 days left, renew certificate
-		if err != nil || crt == nil || crt.NotAfter.Before(time.Now().Add(24*30*time.Hour)) {
-			client, err := p.getClient()
-			if err != nil {
-				log.Infof("Error renewing certificate from LE : %+v, %v", certificate.Domain, err)
-				continue
-			}
+		if err != nil || crt == nil || isRenewalNeeded(certificate, crt, time.Now()) || keyTypeChanged || mustStapleChanged {
+			p.renewCertificate(certificate, keyType, keyTypeChanged, mustStaple)
+		}
+	}
+}
 
-			log.Infof("Renewing certificate from LE : %+v", certificate.Domain)
+// renewCertificate renews the certificate, or re-issues it when its key type changed, while holding the lock of its domains
+func (p *Provider) renewCertificate(certificate *Certificate, keyType acme.KeyType, keyTypeChanged, mustStaple bool) {
+	challengeType := p.getDomainChallengeType(certificate.Domain, certificate)
+	logger := domainsLogger(certificate.Domain.ToStrArray()).WithField(logFieldChallengeType, challengeType)
+	span := p.tracing.startIssuance(spanRenew, certificate.Domain.ToStrArray(), challengeType)
+	timing := p.timings.start(certificate.Domain.ToStrArray(), challengeType)
 
-			renewedCert, err := client.RenewCertificate(acme.CertificateResource{
-				Domain:      certificate.Domain.Main,
-				PrivateKey:  certificate.Key,
-				Certificate: certificate.Certificate,
-			}, true, OSCPMustStaple)
+	held, release, err := p.lock(getCertificateLockKey(certificate.Domain.ToStrArray()))
+	if err != nil {
+		logger.Errorf("Error renewing certificate from LE : %+v, %v", certificate.Domain, err)
+		p.tracing.endIssuance(certificate.Domain.ToStrArray(), span, err)
+		p.timings.failed(certificate.Domain.ToStrArray(), timing)
+		p.renewalFailed(certificate.Domain, err)
+		return
+	}
+	defer release()
+
+	// The renewal is deferred when it would exceed a known limit of the CA, it is retried by the next renewals
+	if err = p.checkOrderLimits(certificate.Domain.ToStrArray()); err != nil {
+		p.tracing.endIssuance(certificate.Domain.ToStrArray(), span, err)
+		p.timings.failed(certificate.Domain.ToStrArray(), timing)
+		p.renewalFailed(certificate.Domain, err)
+		return
+	}
 
-			if err != nil {
-				log.Errorf("Error renewing certificate from LE: %v, %v", certificate.Domain, err)
-				continue
-			}
+	client, err := p.getChallengeClient(challengeType)
+	if err != nil {
+		logger.Infof("Error renewing certificate from LE : %+v, %v", certificate.Domain, err)
+		p.tracing.endIssuance(certificate.Domain.ToStrArray(), span, err)
+		p.timings.failed(certificate.Domain.ToStrArray(), timing)
+		p.renewalFailed(certificate.Domain, err)
+		return
+	}
 
-			if len(renewedCert.Certificate) == 0 || len(renewedCert.PrivateKey) == 0 {
-				log.Errorf("domains %v renew certificate with no value: %v", certificate.Domain.ToStrArray(), certificate)
-				continue
-			}
+	logger.Infof("Renewing certificate from LE : %+v", certificate.Domain)
 
-			p.addCertificateForDomain(certificate.Domain, renewedCert.Certificate, renewedCert.PrivateKey)
+	var renewedCert *acme.CertificateResource
+	orderSpan := p.tracing.startSpan(spanOrder, certificate.Domain.Main)
+	orderStart := time.Now()
+	if keyTypeChanged {
+		var privateKey crypto.PrivateKey
+		privateKey, err = generateCertificatePrivateKey(keyType)
+		if err == nil {
+			renewedCert, err = client.ObtainCertificate(certificate.Domain.ToStrArray(), true, privateKey, mustStaple)
 		}
+	} else {
+		renewedCert, err = client.RenewCertificate(acme.CertificateResource{
+			Domain:      certificate.Domain.Main,
+			PrivateKey:  certificate.Key,
+			Certificate: certificate.Certificate,
+		}, true, mustStaple)
+	}
+	p.timings.phase(timing, issuancePhaseOrder, orderStart)
+	orderSpan.finish(err)
+	p.recordOrder(certificate.Domain.ToStrArray(), err)
+
+	if err != nil {
+		logger.Errorf("Error renewing certificate from LE: %v, %v", certificate.Domain, err)
+		p.tracing.endIssuance(certificate.Domain.ToStrArray(), span, err)
+		p.timings.failed(certificate.Domain.ToStrArray(), timing)
+		p.renewalFailed(certificate.Domain, err)
+		countChallenges(p.metricsRegistry, challengeType, challengeOutcomeFailed, len(certificate.Domain.ToStrArray()))
+		p.events.certificateFailed(certificate.Domain.ToStrArray(), true, err)
+		return
+	}
+
+	countChallenges(p.metricsRegistry, challengeType, challengeOutcomeSolved, len(certificate.Domain.ToStrArray()))
+
+	if len(renewedCert.Certificate) == 0 || len(renewedCert.PrivateKey) == 0 {
+		logger.Errorf("domains %v renew certificate with no value: %v", certificate.Domain.ToStrArray(), certificate)
+		err = fmt.Errorf("domains %v renew certificate with no value", certificate.Domain.ToStrArray())
+		p.tracing.endIssuance(certificate.Domain.ToStrArray(), span, err)
+		p.timings.failed(certificate.Domain.ToStrArray(), timing)
+		p.renewalFailed(certificate.Domain, err)
+		return
+	}
+	if err = p.verifyObtainedCertificate(certificate.Domain.ToStrArray(), renewedCert.Certificate); err != nil {
+		p.tracing.endIssuance(certificate.Domain.ToStrArray(), span, err)
+		p.timings.failed(certificate.Domain.ToStrArray(), timing)
+		p.renewalFailed(certificate.Domain, err)
+		p.events.certificateFailed(certificate.Domain.ToStrArray(), true, err)
+		return
+	}
+	// The certificate renewed after the loss of the lock may have been renewed by another store meanwhile, it is not stored
+	if err = checkLockHeld(held); err != nil {
+		logger.Errorf("Error renewing certificate from LE: %v, %v", certificate.Domain, err)
+		p.tracing.endIssuance(certificate.Domain.ToStrArray(), span, err)
+		p.timings.failed(certificate.Domain.ToStrArray(), timing)
+		p.renewalFailed(certificate.Domain, err)
+		return
+	}
+	p.tracing.endIssuance(certificate.Domain.ToStrArray(), span, nil)
+	p.renewed(certificate.Domain)
+
+	p.addCertificateForDomain(certificate.Domain, renewedCert.Certificate, renewedCert.PrivateKey, keyType, challengeType, renewedCert.CertURL)
+	p.events.certificateObtained(certificate.Domain.ToStrArray(), renewedCert.Certificate, true)
+}
+
+// isRenewalNeeded checks if the certificate is in its renewal window:
+// the suggested ACME Renewal Information window when available, its recorded renewal window otherwise (30 days by default)
+// delayed by its renewal offset when the renewals are staggered
+func isRenewalNeeded(certificate *Certificate, crt *x509.Certificate, now time.Time) bool {
+	if certificate.RenewalInfo != nil && !certificate.RenewalInfo.SuggestedWindowStart.IsZero() {
+		return certificate.RenewalInfo.isRenewalDue(now)
 	}
+
+	return crt.NotAfter.Before(now.Add(getScheduledRenewBefore(certificate)))
 }
 
 // Get provided certificate which check a domains list (Main and SANs)
@@ -665,7 +1419,7 @@ func (p *Provider) getUncheckedDomains(domainsToCheck []string, checkConfigurati
 	p.resolvingDomainsMutex.RLock()
 	defer p.resolvingDomainsMutex.RUnlock()
 
-	log.Debugf("Looking for provided certificate(s) to validate %q...", domainsToCheck)
+	domainsLogger(domainsToCheck).Debugf("Looking for provided certificate(s) to validate %q...", domainsToCheck)
 
 	allDomains := p.certificateStore.GetAllDomains()
 
@@ -698,9 +1452,9 @@ func searchUncheckedDomains(domainsToCheck []string, existentDomains []string) [
 	}
 
 	if len(uncheckedDomains) == 0 {
-		log.Debugf("No ACME certificate generation required for domains %q.", domainsToCheck)
+		domainsLogger(domainsToCheck).Debugf("No ACME certificate generation required for domains %q.", domainsToCheck)
 	} else {
-		log.Debugf("Domains %q need ACME certificates generation for domains %q.", domainsToCheck, strings.Join(uncheckedDomains, ","))
+		domainsLogger(domainsToCheck).Debugf("Domains %q need ACME certificates generation for domains %q.", domainsToCheck, strings.Join(uncheckedDomains, ","))
 	}
 	return uncheckedDomains
 }
@@ -708,7 +1462,7 @@ func searchUncheckedDomains(domainsToCheck []string, existentDomains []string) [
 func getX509Certificate(certificate *Certificate) (*x509.Certificate, error) {
 	tlsCert, err := tls.X509KeyPair(certificate.Certificate, certificate.Key)
 	if err != nil {
-		log.Errorf("Failed to load TLS keypair from ACME certificate for domain %q (SAN : %q), certificate will be renewed : %v", certificate.Domain.Main, strings.Join(certificate.Domain.SANs, ","), err)
+		domainsLogger(certificate.Domain.ToStrArray()).Errorf("Failed to load TLS keypair from ACME certificate for domain %q (SAN : %q), certificate will be renewed : %v", certificate.Domain.Main, strings.Join(certificate.Domain.SANs, ","), err)
 		return nil, err
 	}
 
@@ -716,7 +1470,7 @@ func getX509Certificate(certificate *Certificate) (*x509.Certificate, error) {
 	if crt == nil {
 		crt, err = x509.ParseCertificate(tlsCert.Certificate[0])
 		if err != nil {
-			log.Errorf("Failed to parse TLS keypair from ACME certificate for domain %q (SAN : %q), certificate will be renewed : %v", certificate.Domain.Main, strings.Join(certificate.Domain.SANs, ","), err)
+			domainsLogger(certificate.Domain.ToStrArray()).Errorf("Failed to parse TLS keypair from ACME certificate for domain %q (SAN : %q), certificate will be renewed : %v", certificate.Domain.Main, strings.Join(certificate.Domain.SANs, ","), err)
 		}
 	}
 
@@ -1,13 +1,18 @@
 package acme
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"testing"
 
 	"github.com/containous/traefik/safe"
 	traefiktls "github.com/containous/traefik/tls"
 	"github.com/containous/traefik/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/xenolf/lego/acme"
 )
 
@@ -682,3 +687,121 @@ func TestInitAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestGetKeyType(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		domain          types.Domain
+		keyType         string
+		account         *Account
+		domainsKeyType  []DomainKeyType
+		expectedKeyType acme.KeyType
+	}{
+		{
+			desc:            "no override uses the configured key type",
+			domain:          types.Domain{Main: "traefik.wtf"},
+			keyType:         "RSA2048",
+			expectedKeyType: acme.RSA2048,
+		},
+		{
+			desc:            "no override uses the account key type",
+			domain:          types.Domain{Main: "traefik.wtf"},
+			keyType:         "RSA2048",
+			account:         &Account{KeyType: acme.EC384},
+			expectedKeyType: acme.EC384,
+		},
+		{
+			desc:            "exact domain override",
+			domain:          types.Domain{Main: "Foo.Traefik.wtf"},
+			keyType:         "RSA2048",
+			domainsKeyType:  []DomainKeyType{{Domain: "foo.traefik.wtf", KeyType: "EC256"}},
+			expectedKeyType: acme.EC256,
+		},
+		{
+			desc:    "exact domain override takes precedence over wildcard override",
+			domain:  types.Domain{Main: "foo.traefik.wtf"},
+			keyType: "RSA2048",
+			domainsKeyType: []DomainKeyType{
+				{Domain: "*.traefik.wtf", KeyType: "EC384"},
+				{Domain: "foo.traefik.wtf", KeyType: "EC256"},
+			},
+			expectedKeyType: acme.EC256,
+		},
+		{
+			desc:            "wildcard domain override",
+			domain:          types.Domain{Main: "bar.traefik.wtf"},
+			keyType:         "RSA2048",
+			domainsKeyType:  []DomainKeyType{{Domain: "*.traefik.wtf", KeyType: "EC384"}},
+			expectedKeyType: acme.EC384,
+		},
+		{
+			desc:            "override of another domain",
+			domain:          types.Domain{Main: "traefik.wtf"},
+			keyType:         "RSA2048",
+			domainsKeyType:  []DomainKeyType{{Domain: "*.traefik.wtf", KeyType: "EC384"}},
+			expectedKeyType: acme.RSA2048,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			acmeProvider := Provider{
+				account:       test.account,
+				Configuration: &Configuration{KeyType: test.keyType, DomainsKeyType: test.domainsKeyType},
+			}
+
+			assert.Equal(t, test.expectedKeyType, acmeProvider.getKeyType(test.domain))
+		})
+	}
+}
+
+func TestGetCertificateKeyType(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		keyType acme.KeyType
+	}{
+		{
+			desc:    "EC256 private key",
+			keyType: acme.EC256,
+		},
+		{
+			desc:    "EC384 private key",
+			keyType: acme.EC384,
+		},
+		{
+			desc:    "RSA2048 private key",
+			keyType: acme.RSA2048,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			privateKey, err := generateCertificatePrivateKey(test.keyType)
+			require.NoError(t, err)
+
+			var block *pem.Block
+			switch key := privateKey.(type) {
+			case *ecdsa.PrivateKey:
+				der, errMarshal := x509.MarshalECPrivateKey(key)
+				require.NoError(t, errMarshal)
+				block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+			case *rsa.PrivateKey:
+				block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+			}
+
+			keyType, err := getCertificateKeyType(&Certificate{Key: pem.EncodeToMemory(block)})
+			require.NoError(t, err)
+			assert.Equal(t, test.keyType, keyType)
+
+			keyType, err = getCertificateKeyType(&Certificate{Key: pem.EncodeToMemory(block), KeyType: acme.RSA8192})
+			require.NoError(t, err)
+			assert.Equal(t, acme.RSA8192, keyType)
+		})
+	}
+}
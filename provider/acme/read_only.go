@@ -0,0 +1,79 @@
+package acme
+
+import (
+	"sync/atomic"
+
+	"github.com/containous/traefik/safe"
+)
+
+// Modes of the ACME provider
+const (
+	modeActive  = "active"
+	modePassive = "passive"
+)
+
+// readOnlyStore is implemented by the stores supporting the read-only mode
+type readOnlyStore interface {
+	SetReadOnly(readOnly bool)
+	IsReadOnly() bool
+}
+
+// SetReadOnly switches the store between the read-only and the read-write modes
+func (s *LocalStore) SetReadOnly(readOnly bool) {
+	var value int32
+	if readOnly {
+		value = 1
+	}
+	atomic.StoreInt32(&s.readOnly, value)
+}
+
+// IsReadOnly returns whether the mutations of the store are refused
+func (s *LocalStore) IsReadOnly() bool {
+	return atomic.LoadInt32(&s.readOnly) == 1
+}
+
+// GetMode returns the mode of the provider: passive when the storage is read-only, active otherwise
+func (p *Provider) GetMode() string {
+	if p.isReadOnly() {
+		return modePassive
+	}
+	return modeActive
+}
+
+// SetReadOnly switches the provider between the passive mode, serving the stored certificates only,
+// and the active mode, obtaining and renewing the certificates
+func (p *Provider) SetReadOnly(readOnly bool) {
+	store, ok := unwrapStore(p.Store).(readOnlyStore)
+	if !ok {
+		logger().Error("The ACME storage does not support the read-only mode.")
+		return
+	}
+
+	wasReadOnly := store.IsReadOnly()
+	store.SetReadOnly(readOnly)
+	atomic.StoreInt32(&p.passiveLogged, 0)
+
+	// Obtain and renew the certificates right away once the provider is started
+	if wasReadOnly && !readOnly && p.pool != nil {
+		logger().Info("The ACME storage is not read-only anymore: the certificates are obtained and renewed.")
+		p.resolveDomains()
+		safe.Go(p.renewCertificates)
+	}
+}
+
+func (p *Provider) isReadOnly() bool {
+	store, ok := unwrapStore(p.Store).(readOnlyStore)
+	return ok && store.IsReadOnly()
+}
+
+// isPassive returns whether the storage is read-only, and logs once that the certificates are not managed
+func (p *Provider) isPassive() bool {
+	if !p.isReadOnly() {
+		return false
+	}
+
+	if atomic.CompareAndSwapInt32(&p.passiveLogged, 0, 1) {
+		logger().Info("The ACME storage is read-only, the provider is passive: the stored certificates are served, but no account is registered and no certificate is obtained nor renewed.")
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package acme
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewLocalStore(filepath.Join(dir, "acme.json"))
+	p := &Provider{Configuration: &Configuration{ReadOnly: true}, Store: store}
+
+	require.NoError(t, p.Init(nil))
+	assert.True(t, store.IsReadOnly())
+	assert.Equal(t, modePassive, p.GetMode())
+
+	_, err = p.getClient()
+	assert.Equal(t, ErrReadOnly, err)
+
+	certificate, err := p.resolveCertificate(types.Domain{Main: "traefik.wtf"}, true)
+	require.NoError(t, err)
+	assert.Nil(t, certificate)
+
+	p.SetReadOnly(false)
+	assert.False(t, store.IsReadOnly())
+	assert.Equal(t, modeActive, p.GetMode())
+}
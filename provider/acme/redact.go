@@ -0,0 +1,129 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+const redacted = "[REDACTED]"
+
+// maxPayloadExcerptLength is the maximum length of a storage payload excerpt in the logs
+const maxPayloadExcerptLength = 512
+
+// redactedFields are the StoredData fields holding key material
+var redactedFields = map[string]bool{
+	"PrivateKey":          true,
+	"EncryptedPrivateKey": true,
+	"Key":                 true,
+	"EncryptedKey":        true,
+	"KeyAuth":             true,
+	"HTTPChallenges":      true,
+	"WrappedKey":          true,
+}
+
+var (
+	pemPrivateKeyRegexp = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(-----END [A-Z ]*PRIVATE KEY-----|$)`)
+	redactedFieldRegexp = regexp.MustCompile(`"(PrivateKey|EncryptedPrivateKey|Key|EncryptedKey|KeyAuth|WrappedKey)"\s*:\s*("[^"]*"?|\{[^}]*\}?)`)
+	// longStringRegexp matches the long strings of a payload which is not valid JSON: base64 encoded keys and key authorizations
+	longStringRegexp = regexp.MustCompile(`"[^"\s]{64,}"?`)
+)
+
+// String returns the account without its private key
+func (a *Account) String() string {
+	if a == nil {
+		return "<nil>"
+	}
+
+	registrationURI := ""
+	if a.Registration != nil {
+		registrationURI = a.Registration.URI
+	}
+
+	return fmt.Sprintf("{Email:%s Registration:%s PrivateKey:%s PrivateKeyType:%s KeyType:%s}",
+		a.Email, registrationURI, redactBytes(a.PrivateKey, a.EncryptedPrivateKey), a.PrivateKeyType, a.KeyType)
+}
+
+// GoString returns the account without its private key
+func (a *Account) GoString() string {
+	return a.String()
+}
+
+// String returns the certificate without its private key, the certificate is identified by its fingerprint
+func (c *Certificate) String() string {
+	if c == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("{Domain:%v Certificate:%s Key:%s KeyType:%s ChallengeType:%s}",
+		c.Domain.ToStrArray(), getCertificateFingerprint(c.Certificate), redactBytes(c.Key, c.EncryptedKey), c.KeyType, c.ChallengeType)
+}
+
+// GoString returns the certificate without its private key
+func (c *Certificate) GoString() string {
+	return c.String()
+}
+
+// String returns the DNS challenge state without its key authorization
+func (s *DNSChallengeState) String() string {
+	if s == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("{Provider:%s Domain:%s Token:%s KeyAuth:%s FQDN:%s CreatedAt:%s}",
+		s.Provider, s.Domain, s.Token, redactBytes([]byte(s.KeyAuth), nil), s.FQDN, s.CreatedAt)
+}
+
+// GoString returns the DNS challenge state without its key authorization
+func (s *DNSChallengeState) GoString() string {
+	return s.String()
+}
+
+func redactBytes(value []byte, encrypted *encryptedField) string {
+	if len(value) == 0 && encrypted == nil {
+		return ""
+	}
+	return redacted
+}
+
+// sanitizePayload returns an excerpt of a storage payload without key material, to be logged.
+// The payload may not be valid JSON: the text is then redacted field by field.
+func sanitizePayload(data []byte) string {
+	var sanitized string
+
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err == nil {
+		redactPayload(payload)
+		if content, err := json.Marshal(payload); err == nil {
+			sanitized = string(content)
+		}
+	}
+
+	if len(sanitized) == 0 {
+		sanitized = pemPrivateKeyRegexp.ReplaceAllString(string(data), redacted)
+		sanitized = redactedFieldRegexp.ReplaceAllString(sanitized, `"$1":"`+redacted+`"`)
+		sanitized = longStringRegexp.ReplaceAllString(sanitized, `"`+redacted+`"`)
+	}
+
+	if len(sanitized) > maxPayloadExcerptLength {
+		sanitized = sanitized[:maxPayloadExcerptLength] + "..."
+	}
+	return sanitized
+}
+
+func redactPayload(payload interface{}) {
+	switch value := payload.(type) {
+	case map[string]interface{}:
+		for field, content := range value {
+			if redactedFields[field] && content != nil {
+				value[field] = redacted
+				continue
+			}
+			redactPayload(content)
+		}
+	case []interface{}:
+		for _, content := range value {
+			redactPayload(content)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package acme
+
+import (
+	"crypto/x509"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/types"
+)
+
+// defaultRenewBefore is the duration left before the expiry of a certificate from which it is renewed
+const defaultRenewBefore = 30 * 24 * time.Hour
+
+// DomainRenewal holds the renewal window of the certificates of a domain
+type DomainRenewal struct {
+	Domain      string         `description:"Domain (or wildcard domain) using the renewal window"`
+	RenewBefore parse.Duration `description:"Renew the certificates of the domain when less than this duration is left before their expiry"`
+}
+
+// getRenewBefore returns the renewal window of the certificate of the given domain
+// An exact domain override takes precedence over a wildcard one
+func (p *Provider) getRenewBefore(domain types.Domain) time.Duration {
+	main := types.CanonicalDomain(domain.Main)
+
+	for _, override := range p.DomainsRenewBefore {
+		if types.CanonicalDomain(override.Domain) == main && override.RenewBefore > 0 {
+			return time.Duration(override.RenewBefore)
+		}
+	}
+
+	for _, override := range p.DomainsRenewBefore {
+		if types.MatchDomain(main, types.CanonicalDomain(override.Domain)) && override.RenewBefore > 0 {
+			return time.Duration(override.RenewBefore)
+		}
+	}
+
+	if p.RenewBefore > 0 {
+		return time.Duration(p.RenewBefore)
+	}
+	return defaultRenewBefore
+}
+
+// getCertificateRenewBefore returns the renewal window of the certificate, shorter than its lifetime:
+// a window longer than the lifetime would renew the certificate as soon as it is obtained,
+// it is then clamped to the last third of the lifetime
+func (p *Provider) getCertificateRenewBefore(domain types.Domain, crt *x509.Certificate) time.Duration {
+	renewBefore := p.getRenewBefore(domain)
+
+	lifetime := crt.NotAfter.Sub(crt.NotBefore)
+	if lifetime > 0 && renewBefore >= lifetime {
+		clamped := lifetime / 3
+		domainsLogger(domain.ToStrArray()).Warnf("The renewal window %s of the certificate for domains %v is not shorter than its lifetime %s, using %s.", renewBefore, domain.ToStrArray(), lifetime, clamped)
+		return clamped
+	}
+
+	return renewBefore
+}
+
+// refreshRenewBefore records the renewal window of the certificates, and returns whether one has changed
+func (p *Provider) refreshRenewBefore(certificates []*Certificate) bool {
+	changed := false
+	for _, certificate := range certificates {
+		crt, err := getX509Certificate(certificate)
+		if err != nil || crt == nil {
+			continue
+		}
+
+		renewBefore := p.getCertificateRenewBefore(certificate.Domain, crt)
+		if certificate.RenewBefore != renewBefore {
+			certificate.RenewBefore = renewBefore
+			changed = true
+		}
+	}
+	return changed
+}
+
+// getStoredRenewBefore returns the renewal window recorded on the certificate, the default one for the legacy entries
+func getStoredRenewBefore(certificate *Certificate) time.Duration {
+	if certificate.RenewBefore > 0 {
+		return certificate.RenewBefore
+	}
+	return defaultRenewBefore
+}
@@ -0,0 +1,100 @@
+package acme
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/tls/generate"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRenewBefore(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		renewBefore        parse.Duration
+		domainsRenewBefore []DomainRenewal
+		domain             types.Domain
+		expected           time.Duration
+	}{
+		{
+			desc:     "default",
+			domain:   types.Domain{Main: "traefik.wtf"},
+			expected: defaultRenewBefore,
+		},
+		{
+			desc:        "configured",
+			renewBefore: parse.Duration(10 * 24 * time.Hour),
+			domain:      types.Domain{Main: "traefik.wtf"},
+			expected:    10 * 24 * time.Hour,
+		},
+		{
+			desc:        "exact domain override",
+			renewBefore: parse.Duration(10 * 24 * time.Hour),
+			domainsRenewBefore: []DomainRenewal{
+				{Domain: "*.traefik.wtf", RenewBefore: parse.Duration(3 * 24 * time.Hour)},
+				{Domain: "internal.traefik.wtf", RenewBefore: parse.Duration(2 * 24 * time.Hour)},
+			},
+			domain:   types.Domain{Main: "internal.traefik.wtf"},
+			expected: 2 * 24 * time.Hour,
+		},
+		{
+			desc:        "wildcard domain override",
+			renewBefore: parse.Duration(10 * 24 * time.Hour),
+			domainsRenewBefore: []DomainRenewal{
+				{Domain: "*.traefik.wtf", RenewBefore: parse.Duration(3 * 24 * time.Hour)},
+			},
+			domain:   types.Domain{Main: "foo.traefik.wtf"},
+			expected: 3 * 24 * time.Hour,
+		},
+		{
+			desc:        "other domain override",
+			renewBefore: parse.Duration(10 * 24 * time.Hour),
+			domainsRenewBefore: []DomainRenewal{
+				{Domain: "other.wtf", RenewBefore: parse.Duration(3 * 24 * time.Hour)},
+			},
+			domain:   types.Domain{Main: "traefik.wtf"},
+			expected: 10 * 24 * time.Hour,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{Configuration: &Configuration{RenewBefore: test.renewBefore, DomainsRenewBefore: test.domainsRenewBefore}}
+			assert.Equal(t, test.expected, provider.getRenewBefore(test.domain))
+		})
+	}
+}
+
+func TestGetCertificateRenewBefore(t *testing.T) {
+	now := time.Now()
+	provider := &Provider{Configuration: &Configuration{}}
+
+	// A 7 days certificate is not renewed as soon as it is obtained
+	crt := &x509.Certificate{NotBefore: now, NotAfter: now.Add(7 * 24 * time.Hour)}
+	renewBefore := provider.getCertificateRenewBefore(types.Domain{Main: "traefik.wtf"}, crt)
+	assert.Equal(t, 56*time.Hour, renewBefore)
+
+	crt = &x509.Certificate{NotBefore: now, NotAfter: now.Add(90 * 24 * time.Hour)}
+	renewBefore = provider.getCertificateRenewBefore(types.Domain{Main: "traefik.wtf"}, crt)
+	assert.Equal(t, defaultRenewBefore, renewBefore)
+}
+
+func TestRefreshRenewBefore(t *testing.T) {
+	certPEM, keyPEM, err := generate.KeyPair("traefik.wtf", time.Now().Add(90*24*time.Hour))
+	require.NoError(t, err)
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: certPEM, Key: keyPEM}
+	provider := &Provider{Configuration: &Configuration{RenewBefore: parse.Duration(10 * 24 * time.Hour)}}
+
+	assert.True(t, provider.refreshRenewBefore([]*Certificate{certificate}))
+	assert.Equal(t, 10*24*time.Hour, certificate.RenewBefore)
+
+	assert.False(t, provider.refreshRenewBefore([]*Certificate{certificate}))
+}
@@ -0,0 +1,191 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xenolf/lego/acme"
+)
+
+// defaultRenewalInfoRefreshInterval is the default interval between two refreshes of the ACME Renewal Information
+const defaultRenewalInfoRefreshInterval = 6 * time.Hour
+
+// RenewalInfo holds the ACME Renewal Information (ARI) of a certificate
+type RenewalInfo struct {
+	SuggestedWindowStart time.Time
+	SuggestedWindowEnd   time.Time
+	RetryAfter           time.Time
+	ExplanationURL       string `json:",omitempty"`
+	FetchedAt            time.Time
+}
+
+type renewalInfoResponse struct {
+	SuggestedWindow struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	} `json:"suggestedWindow"`
+	ExplanationURL string `json:"explanationURL"`
+}
+
+// isRenewalDue returns true if the suggested renewal window has started
+func (r *RenewalInfo) isRenewalDue(now time.Time) bool {
+	return r != nil && !r.SuggestedWindowStart.IsZero() && !now.Before(r.SuggestedWindowStart)
+}
+
+// needsRefresh returns true if the renewal information has to be fetched again
+func (r *RenewalInfo) needsRefresh(now time.Time, refreshInterval time.Duration) bool {
+	if r == nil {
+		return true
+	}
+	if now.Before(r.RetryAfter) {
+		return false
+	}
+	return !now.Before(r.FetchedAt.Add(refreshInterval))
+}
+
+func (p *Provider) getRenewalInfoRefreshInterval() time.Duration {
+	if p.RenewalInfoRefreshInterval > 0 {
+		return time.Duration(p.RenewalInfoRefreshInterval)
+	}
+	return defaultRenewalInfoRefreshInterval
+}
+
+// getRenewalInfoURL returns the ARI endpoint of the CA server, or an empty string if the CA does not support ARI
+func (p *Provider) getRenewalInfoURL() string {
+	p.renewalInfoOnce.Do(func() {
+		resp, err := acme.HTTPClient.Get(p.getCAServer())
+		if err != nil {
+			logger().Warnf("Unable to get the ACME directory to look for the renewal information endpoint: %v", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		var directory struct {
+			RenewalInfo string `json:"renewalInfo"`
+		}
+		if err = json.NewDecoder(resp.Body).Decode(&directory); err != nil {
+			logger().Warnf("Unable to decode the ACME directory to look for the renewal information endpoint: %v", err)
+			return
+		}
+
+		if len(directory.RenewalInfo) == 0 {
+			logger().Debug("The CA server does not support ACME Renewal Information, the default renewal window will be used.")
+			return
+		}
+		p.renewalInfoURL = strings.TrimSuffix(directory.RenewalInfo, "/")
+	})
+
+	return p.renewalInfoURL
+}
+
+// refreshRenewalInfo fetches the ACME Renewal Information of the certificates when they are outdated
+// It returns true if at least one certificate has been updated
+func (p *Provider) refreshRenewalInfo(certificates []*Certificate) bool {
+	renewalInfoURL := p.getRenewalInfoURL()
+	if len(renewalInfoURL) == 0 {
+		return false
+	}
+
+	now := time.Now()
+	refreshInterval := p.getRenewalInfoRefreshInterval()
+
+	updated := false
+	for _, certificate := range certificates {
+		// The external certificates are not issued by the CA
+		if certificate.External || !certificate.RenewalInfo.needsRefresh(now, refreshInterval) {
+			continue
+		}
+
+		crt, err := getX509Certificate(certificate)
+		if err != nil || crt == nil {
+			continue
+		}
+
+		renewalInfo, err := fetchRenewalInfo(renewalInfoURL, crt, now)
+		if err != nil {
+			domainsLogger(certificate.Domain.ToStrArray()).Warnf("Unable to get the renewal information of the certificate for domains %v: %v", certificate.Domain.ToStrArray(), err)
+			continue
+		}
+
+		certificate.RenewalInfo = renewalInfo
+		updated = true
+	}
+
+	return updated
+}
+
+func fetchRenewalInfo(renewalInfoURL string, crt *x509.Certificate, now time.Time) (*RenewalInfo, error) {
+	certID, err := getRenewalInfoCertID(crt)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := acme.HTTPClient.Get(renewalInfoURL + "/" + certID)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var response renewalInfoResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	if response.SuggestedWindow.Start.IsZero() || response.SuggestedWindow.End.Before(response.SuggestedWindow.Start) {
+		return nil, fmt.Errorf("invalid suggested window [%s, %s]", response.SuggestedWindow.Start, response.SuggestedWindow.End)
+	}
+
+	return &RenewalInfo{
+		SuggestedWindowStart: response.SuggestedWindow.Start,
+		SuggestedWindowEnd:   response.SuggestedWindow.End,
+		RetryAfter:           parseRetryAfter(resp.Header.Get("Retry-After"), now),
+		ExplanationURL:       response.ExplanationURL,
+		FetchedAt:            now,
+	}, nil
+}
+
+// getRenewalInfoCertID builds the ARI certificate identifier:
+// the base64url encoded authority key identifier and serial number joined by a dot
+func getRenewalInfoCertID(crt *x509.Certificate) (string, error) {
+	if len(crt.AuthorityKeyId) == 0 {
+		return "", fmt.Errorf("the certificate has no authority key identifier")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(crt.AuthorityKeyId) + "." + base64.RawURLEncoding.EncodeToString(encodeSerialNumber(crt.SerialNumber)), nil
+}
+
+// encodeSerialNumber returns the content bytes of the DER encoded serial number
+func encodeSerialNumber(serial *big.Int) []byte {
+	serialBytes := serial.Bytes()
+	if len(serialBytes) == 0 || serialBytes[0]&0x80 != 0 {
+		serialBytes = append([]byte{0}, serialBytes...)
+	}
+	return serialBytes
+}
+
+func parseRetryAfter(value string, now time.Time) time.Time {
+	if len(value) == 0 {
+		return time.Time{}
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return date
+	}
+
+	return time.Time{}
+}
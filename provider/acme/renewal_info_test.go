@@ -0,0 +1,130 @@
+package acme
+
+import (
+	"crypto/x509"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRenewalInfoCertID(t *testing.T) {
+	crt := &x509.Certificate{
+		AuthorityKeyId: []byte{0x69, 0x88, 0x5b, 0x6b, 0x87, 0x46, 0x40, 0x41, 0xe1, 0xb3, 0x7b, 0x84, 0x7b, 0xa0, 0xae, 0x2c, 0xde, 0x01, 0xc8, 0xd4},
+		SerialNumber:   big.NewInt(0).SetBytes([]byte{0x87, 0x65, 0x43, 0x21}),
+	}
+
+	certID, err := getRenewalInfoCertID(crt)
+	require.NoError(t, err)
+	assert.Equal(t, "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE", certID)
+
+	_, err = getRenewalInfoCertID(&x509.Certificate{SerialNumber: big.NewInt(1)})
+	assert.Error(t, err)
+}
+
+func TestFetchRenewalInfo(t *testing.T) {
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/renewal-info/aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Retry-After", "21600")
+		_, _ = rw.Write([]byte(`{"suggestedWindow":{"start":"2026-01-10T00:00:00Z","end":"2026-01-12T00:00:00Z"}}`))
+	}))
+	defer server.Close()
+
+	crt := &x509.Certificate{
+		AuthorityKeyId: []byte{0x69, 0x88, 0x5b, 0x6b, 0x87, 0x46, 0x40, 0x41, 0xe1, 0xb3, 0x7b, 0x84, 0x7b, 0xa0, 0xae, 0x2c, 0xde, 0x01, 0xc8, 0xd4},
+		SerialNumber:   big.NewInt(0).SetBytes([]byte{0x87, 0x65, 0x43, 0x21}),
+	}
+
+	renewalInfo, err := fetchRenewalInfo(server.URL+"/renewal-info", crt, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2026, time.January, 10, 0, 0, 0, 0, time.UTC), renewalInfo.SuggestedWindowStart.UTC())
+	assert.Equal(t, time.Date(2026, time.January, 12, 0, 0, 0, 0, time.UTC), renewalInfo.SuggestedWindowEnd.UTC())
+	assert.Equal(t, now.Add(6*time.Hour), renewalInfo.RetryAfter)
+	assert.Equal(t, now, renewalInfo.FetchedAt)
+
+	_, err = fetchRenewalInfo(server.URL+"/unknown", crt, now)
+	assert.Error(t, err)
+}
+
+func TestIsRenewalNeeded(t *testing.T) {
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		desc        string
+		renewalInfo *RenewalInfo
+		renewBefore time.Duration
+		notAfter    time.Time
+		expected    bool
+	}{
+		{
+			desc:     "no renewal information and more than 30 days left",
+			notAfter: now.Add(60 * 24 * time.Hour),
+			expected: false,
+		},
+		{
+			desc:     "no renewal information and less than 30 days left",
+			notAfter: now.Add(10 * 24 * time.Hour),
+			expected: true,
+		},
+		{
+			desc:        "recorded renewal window not reached",
+			renewBefore: 2 * 24 * time.Hour,
+			notAfter:    now.Add(3 * 24 * time.Hour),
+			expected:    false,
+		},
+		{
+			desc:        "recorded renewal window reached",
+			renewBefore: 2 * 24 * time.Hour,
+			notAfter:    now.Add(24 * time.Hour),
+			expected:    true,
+		},
+		{
+			desc:        "suggested window started",
+			renewalInfo: &RenewalInfo{SuggestedWindowStart: now.Add(-time.Hour), SuggestedWindowEnd: now.Add(time.Hour)},
+			notAfter:    now.Add(60 * 24 * time.Hour),
+			expected:    true,
+		},
+		{
+			desc:        "suggested window not started despite less than 30 days left",
+			renewalInfo: &RenewalInfo{SuggestedWindowStart: now.Add(24 * time.Hour), SuggestedWindowEnd: now.Add(48 * time.Hour)},
+			notAfter:    now.Add(10 * 24 * time.Hour),
+			expected:    false,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			certificate := &Certificate{RenewalInfo: test.renewalInfo, RenewBefore: test.renewBefore}
+			assert.Equal(t, test.expected, isRenewalNeeded(certificate, &x509.Certificate{NotAfter: test.notAfter}, now))
+		})
+	}
+}
+
+func TestRenewalInfoNeedsRefresh(t *testing.T) {
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var renewalInfo *RenewalInfo
+	assert.True(t, renewalInfo.needsRefresh(now, time.Hour))
+
+	renewalInfo = &RenewalInfo{FetchedAt: now.Add(-2 * time.Hour)}
+	assert.True(t, renewalInfo.needsRefresh(now, time.Hour))
+
+	renewalInfo = &RenewalInfo{FetchedAt: now.Add(-2 * time.Hour), RetryAfter: now.Add(time.Hour)}
+	assert.False(t, renewalInfo.needsRefresh(now, time.Hour))
+
+	renewalInfo = &RenewalInfo{FetchedAt: now.Add(-30 * time.Minute)}
+	assert.False(t, renewalInfo.needsRefresh(now, time.Hour))
+}
@@ -0,0 +1,56 @@
+package acme
+
+import (
+	"math/rand"
+	"time"
+)
+
+// getRenewalOffset draws a random offset in the first half of the renewal window,
+// the second half being left to the retries of the failed renewals
+func getRenewalOffset(renewBefore time.Duration) time.Duration {
+	if renewBefore/2 <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(renewBefore / 2)))
+}
+
+// refreshRenewalOffsets draws the renewal offsets of the certificates without one, or whose renewal window changed since it was drawn,
+// and removes them when the renewals are not staggered. It returns whether an offset has changed.
+func (p *Provider) refreshRenewalOffsets(certificates []*Certificate) bool {
+	changed := false
+	for _, certificate := range certificates {
+		if setRenewalOffset(certificate, p.StaggerRenewals) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// setRenewalOffset draws the renewal offset of the certificate when it has none for its renewal window, and returns whether it has changed
+func setRenewalOffset(certificate *Certificate, staggered bool) bool {
+	if !staggered || certificate.External {
+		changed := certificate.RenewalOffset != 0 || certificate.RenewalOffsetWindow != 0
+		certificate.RenewalOffset = 0
+		certificate.RenewalOffsetWindow = 0
+		return changed
+	}
+
+	renewBefore := getStoredRenewBefore(certificate)
+	if certificate.RenewalOffsetWindow == renewBefore {
+		return false
+	}
+
+	certificate.RenewalOffset = getRenewalOffset(renewBefore)
+	certificate.RenewalOffsetWindow = renewBefore
+	return true
+}
+
+// getScheduledRenewBefore returns the duration left before the expiry of the certificate from which it is renewed:
+// its renewal window shortened by its renewal offset, as long as the offset was drawn for the current window
+func getScheduledRenewBefore(certificate *Certificate) time.Duration {
+	renewBefore := getStoredRenewBefore(certificate)
+	if certificate.RenewalOffsetWindow != renewBefore || certificate.RenewalOffset >= renewBefore {
+		return renewBefore
+	}
+	return renewBefore - certificate.RenewalOffset
+}
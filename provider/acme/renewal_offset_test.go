@@ -0,0 +1,84 @@
+package acme
+
+import (
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRenewalOffset(t *testing.T) {
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, RenewBefore: 30 * 24 * time.Hour}
+
+	// The offset is drawn in the first half of the renewal window
+	require.True(t, setRenewalOffset(certificate, true))
+	assert.Equal(t, 30*24*time.Hour, certificate.RenewalOffsetWindow)
+	assert.True(t, certificate.RenewalOffset >= 0 && certificate.RenewalOffset < 15*24*time.Hour)
+
+	// The offset is kept as long as the renewal window is unchanged
+	offset := certificate.RenewalOffset
+	assert.False(t, setRenewalOffset(certificate, true))
+	assert.Equal(t, offset, certificate.RenewalOffset)
+
+	certificate.RenewBefore = 48 * time.Hour
+	require.True(t, setRenewalOffset(certificate, true))
+	assert.Equal(t, 48*time.Hour, certificate.RenewalOffsetWindow)
+	assert.True(t, certificate.RenewalOffset < 24*time.Hour)
+
+	// The offset is removed when the renewals are not staggered
+	require.True(t, setRenewalOffset(certificate, false))
+	assert.Zero(t, certificate.RenewalOffset)
+	assert.Zero(t, certificate.RenewalOffsetWindow)
+	assert.False(t, setRenewalOffset(certificate, false))
+
+	external := &Certificate{Domain: types.Domain{Main: "external.wtf"}, External: true}
+	assert.False(t, setRenewalOffset(external, true))
+	assert.Zero(t, external.RenewalOffsetWindow)
+}
+
+func TestIsRenewalNeededWithOffset(t *testing.T) {
+	crt, err := parseCertificateLeaf(generateTestCertificate(t, time.Now().Add(20*24*time.Hour)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc     string
+		offset   time.Duration
+		window   time.Duration
+		expected bool
+	}{
+		{
+			desc:     "no offset",
+			expected: true,
+		},
+		{
+			desc:     "offset not elapsed",
+			offset:   12 * 24 * time.Hour,
+			window:   30 * 24 * time.Hour,
+			expected: false,
+		},
+		{
+			desc:     "offset elapsed",
+			offset:   5 * 24 * time.Hour,
+			window:   30 * 24 * time.Hour,
+			expected: true,
+		},
+		{
+			desc:     "offset of another renewal window",
+			offset:   12 * 24 * time.Hour,
+			window:   60 * 24 * time.Hour,
+			expected: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			certificate := &Certificate{RenewBefore: 30 * 24 * time.Hour, RenewalOffset: test.offset, RenewalOffsetWindow: test.window}
+			assert.Equal(t, test.expected, isRenewalNeeded(certificate, crt, time.Now()))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package acme
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	"github.com/containous/traefik/metrics"
+)
+
+// SetRenewalPaused pauses or resumes the renewal of the certificate of the main domain, and returns the certificate.
+// The certificate is still served while its renewal is paused. It returns nil when there is no certificate for the domain.
+func (p *Provider) SetRenewalPaused(ctx context.Context, domain string, paused bool) (*CertificateInfo, error) {
+	if p.isPassive() {
+		return nil, ErrReadOnly
+	}
+
+	result := p.updateCertificate(ctx, domain, func(certificate *Certificate) bool {
+		if certificate.RenewalPaused == paused {
+			return false
+		}
+		certificate.RenewalPaused = paused
+		return true
+	})
+	if result.err != nil || result.certificate == nil {
+		return nil, result.err
+	}
+
+	if result.updated {
+		logger := domainsLogger(result.certificate.Domain.ToStrArray())
+		if paused {
+			logger.Infof("The renewal of the certificate for domains %v is paused.", result.certificate.Domain.ToStrArray())
+		} else {
+			logger.Infof("The renewal of the certificate for domains %v is resumed.", result.certificate.Domain.ToStrArray())
+		}
+		setCertificateExpiry(p.metricsRegistry, result.certificate, true)
+	}
+
+	return p.getCertificateInfo(result.certificate), nil
+}
+
+// setCertificateExpiry sets the expiry gauge of the certificate, labeled with whether its renewal is paused.
+// When the pause flag changed, the series of the previous state is set to NaN first, for the alerts to ignore it.
+func setCertificateExpiry(registry metrics.Registry, certificate *Certificate, pauseChanged bool) {
+	if registry == nil || !registry.IsEnabled() {
+		return
+	}
+
+	notAfter, err := getCertificateNotAfter(certificate.Certificate)
+	if err != nil {
+		return
+	}
+
+	gauge := registry.ACMECertificateExpiryGauge()
+	domain := normalizeDomain(certificate.Domain.Main)
+	if pauseChanged {
+		gauge.With("domain", domain, "renewal_paused", strconv.FormatBool(!certificate.RenewalPaused)).Set(math.NaN())
+	}
+	gauge.With("domain", domain, "renewal_paused", strconv.FormatBool(certificate.RenewalPaused)).Set(float64(notAfter.Unix()))
+}
@@ -0,0 +1,117 @@
+package acme
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderSetRenewalPaused(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	notAfter := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, notAfter), Key: []byte("key")}
+	registry := newCollectingACMEMetrics()
+	configurationChan := make(chan types.ConfigMessage, 1)
+	p := &Provider{
+		Configuration:     &Configuration{},
+		Store:             store,
+		certificates:      []*Certificate{certificate},
+		certificateIndex:  newCertificateIndex([]*Certificate{certificate}),
+		configurationChan: configurationChan,
+		metricsRegistry:   registry,
+	}
+
+	info, err := p.SetRenewalPaused(context.Background(), "TRAEFIK.wtf", true)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, "traefik.wtf", info.Domain)
+	assert.True(t, info.RenewalPaused)
+	assert.True(t, certificate.RenewalPaused)
+
+	// The pause is stored, and the served certificates are not refreshed
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1 && storedData.Certificates[0].RenewalPaused
+	})
+	assert.Len(t, configurationChan, 0)
+
+	assert.Equal(t, float64(notAfter.Unix()), registry.expiry.GaugeValue)
+	assert.Equal(t, []string{"domain", "traefik.wtf", "renewal_paused", "true"}, registry.expiry.LastLabelValues)
+
+	certificates, err := p.GetCertificatesInfo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.True(t, certificates[0].RenewalPaused)
+
+	info, err = p.SetRenewalPaused(context.Background(), "traefik.wtf", false)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.False(t, info.RenewalPaused)
+	assert.False(t, certificate.RenewalPaused)
+	assert.Equal(t, []string{"domain", "traefik.wtf", "renewal_paused", "false"}, registry.expiry.LastLabelValues)
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1 && !storedData.Certificates[0].RenewalPaused
+	})
+
+	info, err = p.SetRenewalPaused(context.Background(), "unknown.wtf", true)
+	require.NoError(t, err)
+	assert.Nil(t, info)
+}
+
+func TestProviderSetRenewalPausedPassive(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	p := &Provider{Configuration: &Configuration{}, Store: store}
+	p.SetReadOnly(true)
+
+	_, err := p.SetRenewalPaused(context.Background(), "traefik.wtf", true)
+	assert.Equal(t, ErrReadOnly, err)
+}
+
+func TestProviderRenewCertificatesPaused(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	// The certificate is expired, its renewal is due
+	certificate := &Certificate{
+		Domain:        types.Domain{Main: "traefik.wtf"},
+		Certificate:   generateTestCertificate(t, time.Now().Add(-time.Hour)),
+		Key:           []byte("key"),
+		RenewalPaused: true,
+	}
+	p := &Provider{
+		Configuration: &Configuration{},
+		Store:         store,
+		certificates:  []*Certificate{certificate},
+	}
+	// No renewal information is fetched
+	p.renewalInfoOnce.Do(func() {})
+
+	p.renewCertificates()
+
+	assert.Nil(t, p.renewals.get(certificate.Domain))
+}
+
+func TestSetCertificateExpiryPauseChanged(t *testing.T) {
+	notAfter := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, notAfter)}
+
+	registry := newCollectingACMEMetrics()
+	setCertificateExpiry(registry, certificate, true)
+
+	// The series of the previous state is set to NaN before the one of the current state is set
+	assert.Equal(t, float64(notAfter.Unix()), registry.expiry.GaugeValue)
+	assert.Equal(t, []string{"domain", "traefik.wtf", "renewal_paused", "false"}, registry.expiry.LastLabelValues)
+
+	// The expiry of a certificate which can not be parsed is not reported
+
+	setCertificateExpiry(registry, &Certificate{Domain: types.Domain{Main: "broken.wtf"}}, false)
+	assert.Equal(t, float64(notAfter.Unix()), registry.expiry.GaugeValue)
+	assert.Equal(t, []string{"domain", "traefik.wtf", "renewal_paused", "false"}, registry.expiry.LastLabelValues)
+}
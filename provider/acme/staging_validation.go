@@ -0,0 +1,129 @@
+package acme
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xenolf/lego/acme"
+)
+
+// letsEncryptStagingCAServer is the directory of the Let's Encrypt staging CA, whose rate limits are much higher than the production ones
+const letsEncryptStagingCAServer = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+// validateWithStaging obtains a certificate of the domains from the staging CA, to prove that their challenges succeed before
+// the production order is placed. The staging certificate is discarded. The domains of which a certificate is already stored
+// skip the validation, as the CA server which is the staging one.
+func (p *Provider) validateWithStaging(domains []string, challengeType string, keyType acme.KeyType) error {
+	if !p.ValidateWithStaging || p.getCAServer() == letsEncryptStagingCAServer {
+		return nil
+	}
+
+	logger := domainsLogger(domains).WithField(logFieldChallengeType, challengeType)
+
+	existing, err := p.Store.GetCertificateByDomain(p.getContext(), domains[0])
+	if err != nil {
+		return fmt.Errorf("unable to get the certificate of the domain %s: %v", domains[0], err)
+	}
+	if existing != nil {
+		logger.Debugf("A certificate is already stored for the domain %s, the staging validation is skipped.", domains[0])
+		return nil
+	}
+
+	client, err := p.getStagingClient(challengeType)
+	if err != nil {
+		return fmt.Errorf("cannot get the staging ACME client: %v", err)
+	}
+
+	privateKey, err := generateCertificatePrivateKey(keyType)
+	if err != nil {
+		return fmt.Errorf("unable to generate a private key for the domains %v: %v", domains, err)
+	}
+
+	logger.Infof("Validating the challenges of the domains %v against the staging CA...", domains)
+
+	// The staging certificate is only a proof that the challenges succeed, it is never stored nor served
+	if challengeType == challengeTypeDNS01 && p.useCertificateWithRetry(domains) {
+		_, err = obtainCertificateWithRetry(domains, client, privateKey, p.DNSChallenge.preCheckTimeout, p.DNSChallenge.preCheckInterval, true, false)
+	} else {
+		_, err = client.ObtainCertificate(domains, true, privateKey, false)
+	}
+	if err != nil {
+		return fmt.Errorf("the validation of the domains %v against the staging CA failed, the production order is not placed: %v", domains, err)
+	}
+
+	logger.Infof("The challenges of the domains %v are validated against the staging CA, placing the production order.", domains)
+	return nil
+}
+
+// getStagingClient returns the ACME client of the staging CA solving the challenges of the given type,
+// with the staging account of the store, registered and saved when there is none
+func (p *Provider) getStagingClient(challengeType string) (*acme.Client, error) {
+	if p.isPassive() {
+		return nil, ErrReadOnly
+	}
+
+	p.clientMutex.Lock()
+	defer p.clientMutex.Unlock()
+
+	if client, ok := p.stagingClients[challengeType]; ok {
+		return client, nil
+	}
+
+	// The staging account is registered and saved by one store at a time
+	held, release, err := p.lock(lockKeyStagingAccount)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	account, err := p.Store.GetStagingAccount(p.getContext())
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.GetRegistration() == nil {
+		account, err = NewAccount(p.Email, p.KeyType, p.AccountKeyType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := acme.NewClient(letsEncryptStagingCAServer, account, account.KeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.GetRegistration() == nil {
+		logger().Info("Register the staging account...")
+
+		reg, err := client.Register(true)
+		if err != nil {
+			return nil, err
+		}
+
+		account.Registration = reg
+		registeredAt := time.Now()
+		account.RegisteredAt = &registeredAt
+
+		if err = checkLockHeld(held); err != nil {
+			return nil, err
+		}
+
+		err = p.Store.Update(p.getContext(), func(data *StoredData) error {
+			data.StagingAccount = account
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.setChallengeProvider(client, challengeType); err != nil {
+		return nil, err
+	}
+
+	if p.stagingClients == nil {
+		p.stagingClients = make(map[string]*acme.Client)
+	}
+	p.stagingClients[challengeType] = client
+	return client, nil
+}
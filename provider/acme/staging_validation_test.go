@@ -0,0 +1,67 @@
+package acme
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+func TestProviderValidateWithStagingSkipped(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, time.Now().Add(24*time.Hour)), Key: []byte("key")}
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{certificate}))
+
+	testCases := []struct {
+		desc          string
+		configuration *Configuration
+		domains       []string
+	}{
+		{
+			desc:          "disabled",
+			configuration: &Configuration{},
+			domains:       []string{"other.wtf"},
+		},
+		{
+			desc:          "staging CA server",
+			configuration: &Configuration{ValidateWithStaging: true, CAServer: letsEncryptStagingCAServer},
+			domains:       []string{"other.wtf"},
+		},
+		{
+			desc:          "certificate already stored",
+			configuration: &Configuration{ValidateWithStaging: true},
+			domains:       []string{"traefik.wtf", "www.traefik.wtf"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			p := &Provider{Configuration: test.configuration, Store: store}
+
+			// No staging client is built, no account is registered with the staging CA
+			require.NoError(t, p.validateWithStaging(test.domains, challengeTypeHTTP01, acme.EC256))
+			assert.Empty(t, p.stagingClients)
+		})
+	}
+
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1 && storedData.StagingAccount == nil
+	})
+}
+
+func TestProviderValidateWithStagingPassive(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	p := &Provider{Configuration: &Configuration{ValidateWithStaging: true}, Store: store}
+	p.SetReadOnly(true)
+
+	_, err := p.getStagingClient(challengeTypeHTTP01)
+	assert.Equal(t, ErrReadOnly, err)
+}
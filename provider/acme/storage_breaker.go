@@ -0,0 +1,134 @@
+package acme
+
+import (
+	"time"
+
+	"github.com/containous/flaeg/parse"
+)
+
+const (
+	defaultStorageBreakerFailures = 5
+	defaultStorageBreakerCooldown = 30 * time.Second
+)
+
+// StorageBreaker suspends the writes of the storage after consecutive failures
+type StorageBreaker struct {
+	Failures int            `description:"Number of consecutive failed writes after which the writes of the storage are suspended. Default to 5"`
+	Cooldown parse.Duration `description:"Duration of the suspension of the writes, after which the latest data is written again to probe the storage. Default to 30s"`
+}
+
+func (b *StorageBreaker) getFailures() int {
+	if b.Failures <= 0 {
+		return defaultStorageBreakerFailures
+	}
+	return b.Failures
+}
+
+func (b *StorageBreaker) getCooldown() time.Duration {
+	if b.Cooldown <= 0 {
+		return defaultStorageBreakerCooldown
+	}
+	return time.Duration(b.Cooldown)
+}
+
+// storeBreaker is the state of the circuit breaker of a store, only used by its save loop.
+// While it is open, the writes are suspended and the latest data is kept to be written by the next probe.
+type storeBreaker struct {
+	failures int
+	pending  *StoredData
+	probe    *time.Timer
+}
+
+// isOpen returns whether the writes are suspended
+func (b *storeBreaker) isOpen() bool {
+	return b.probe != nil
+}
+
+// probes returns the channel receiving the end of the cooldown, nil while the breaker is closed
+func (b *storeBreaker) probes() <-chan time.Time {
+	if b.probe == nil {
+		return nil
+	}
+	return b.probe.C
+}
+
+// suspendWrite keeps the data to write while the breaker is open, and returns whether the write is suspended
+func (s *LocalStore) suspendWrite(object *StoredData) bool {
+	if s.CircuitBreaker == nil || !s.breaker.isOpen() {
+		return false
+	}
+
+	s.breaker.pending = object
+	return true
+}
+
+// probeWrite writes the data kept while the breaker is open, once the cooldown has elapsed
+func (s *LocalStore) probeWrite() *StoredData {
+	object := s.breaker.pending
+	s.breaker.probe = nil
+	s.breaker.pending = nil
+	return object
+}
+
+// recordWrite counts the consecutive failed writes, and opens the breaker once they reach the threshold.
+// A failed probe opens the breaker again for a cooldown, a successful write closes it.
+func (s *LocalStore) recordWrite(object *StoredData, err error) {
+	if s.CircuitBreaker == nil {
+		return
+	}
+
+	if err == nil {
+		if s.breaker.failures >= s.CircuitBreaker.getFailures() {
+			s.logger(storeOperationSave).Infof("The ACME storage is written again, the writes are resumed after %d consecutive failures.", s.breaker.failures)
+			s.setDegraded(false)
+		}
+		s.breaker.failures = 0
+		return
+	}
+
+	s.breaker.failures++
+	if s.breaker.failures < s.CircuitBreaker.getFailures() {
+		return
+	}
+
+	if s.breaker.failures == s.CircuitBreaker.getFailures() {
+		s.logger(storeOperationSave).Errorf("The ACME storage failed to be written %d consecutive times, the writes are suspended and probed every %s, the latest data being kept in memory: %v", s.breaker.failures, s.CircuitBreaker.getCooldown(), err)
+		s.setDegraded(true)
+	}
+
+	s.breaker.pending = object
+	s.breaker.probe = time.NewTimer(s.CircuitBreaker.getCooldown())
+}
+
+// stopBreaker writes the data kept while the breaker is open, for the last time as the store is closed
+func (s *LocalStore) stopBreaker() {
+	if !s.breaker.isOpen() {
+		return
+	}
+
+	s.breaker.probe.Stop()
+	if object := s.probeWrite(); object != nil {
+		s.write(object)
+	}
+}
+
+// isDegraded returns whether the writes are suspended, it is only called by the save loop
+func (s *LocalStore) isDegraded() bool {
+	return s.CircuitBreaker != nil && s.breaker.failures >= s.CircuitBreaker.getFailures()
+}
+
+func (s *LocalStore) setDegraded(degraded bool) {
+	s.metricsLock.Lock()
+	registry := s.metricsRegistry
+	s.metricsLock.Unlock()
+
+	if registry != nil {
+		value := 0.0
+		if degraded {
+			value = 1
+		}
+		registry.ACMEStoreDegradedGauge().With("backend", getStoreBackend(s)).Set(value)
+	}
+
+	s.health.setDegraded(degraded)
+}
@@ -0,0 +1,81 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreCircuitBreaker(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+	store.CircuitBreaker = &StorageBreaker{Failures: 2, Cooldown: parse.Duration(300 * time.Millisecond)}
+	store.SetMetricsRegistry(registry)
+
+	failing := int32(1)
+	var writes int32
+	store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		atomic.AddInt32(&writes, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("backend unavailable")
+		}
+		return ioutil.WriteFile(filename, data, perm)
+	}
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "first@traefik.wtf"}))
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "second@traefik.wtf"}))
+	waitForStoreHealth(t, store, func(health *StoreHealth) bool { return health.Degraded })
+	assert.Equal(t, float64(1), registry.degraded.GaugeValue)
+	assert.Equal(t, []string{"backend", "file"}, registry.degraded.LastLabelValues)
+
+	// The saves are not written while the writes are suspended, the latest data is served from memory
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "latest@traefik.wtf"}))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&writes))
+
+	account, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "latest@traefik.wtf", account.Email)
+
+	// The probe writes the latest data once the storage is back
+	atomic.StoreInt32(&failing, 0)
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil && storedData.Account.Email == "latest@traefik.wtf"
+	})
+	waitForStoreHealth(t, store, func(health *StoreHealth) bool { return !health.Degraded })
+	assert.Equal(t, float64(0), registry.degraded.GaugeValue)
+}
+
+func TestLocalStoreCircuitBreakerClose(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+	store.CircuitBreaker = &StorageBreaker{Failures: 1, Cooldown: parse.Duration(time.Hour)}
+
+	failing := int32(1)
+	store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("backend unavailable")
+		}
+		return ioutil.WriteFile(filename, data, perm)
+	}
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoreHealth(t, store, func(health *StoreHealth) bool { return health.Degraded })
+
+	// The data kept while the writes are suspended is written when the store is closed
+	atomic.StoreInt32(&failing, 0)
+	require.NoError(t, store.Close(context.Background()))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil && storedData.Account.Email == "test@traefik.wtf"
+	})
+}
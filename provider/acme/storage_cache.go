@@ -0,0 +1,212 @@
+package acme
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// cacheStore is implemented by the stores able to serve their data from a local cache when the storage can not be loaded
+type cacheStore interface {
+	LoadCache() error
+}
+
+// storageCache is the content of the local cache of the storage, its checksum covers the data as encrypted
+type storageCache struct {
+	Checksum string `json:"checksum"`
+	Data     []byte `json:"data"`
+}
+
+// writeCache writes the account and the certificates to the cache file, encrypted as the storage.
+// The cache is written in a temporary file renamed over the previous cache, not to leave a partial cache.
+func (s *LocalStore) writeCache(object *StoredData) {
+	if len(s.LocalCacheFile) == 0 || object == nil {
+		return
+	}
+
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	if err := s.writeCacheFile(&StoredData{Account: object.Account, Certificates: object.Certificates}); err != nil {
+		s.logger(storeOperationSave).Warnf("Unable to write the ACME storage cache %s: %v", s.LocalCacheFile, err)
+	}
+}
+
+func (s *LocalStore) writeCacheFile(object *StoredData) error {
+	var key *storageKey
+	if s.Encryption != nil {
+		var err error
+		key, err = s.getStorageKey()
+		if err != nil {
+			return fmt.Errorf("unable to encrypt: %v", err)
+		}
+	}
+
+	if key != nil && s.Encryption.getMode() == storageEncryptionModeKeys {
+		sealedData, err := key.sealStoredDataKeys(object)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt the private keys: %v", err)
+		}
+		object = sealedData
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	if key != nil && s.Encryption.getMode() == storageEncryptionModeFull {
+		data, err = key.encrypt(data)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt: %v", err)
+		}
+	}
+
+	checksum := sha256.Sum256(data)
+	content, err := json.Marshal(&storageCache{Checksum: hex.EncodeToString(checksum[:]), Data: data})
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(s.LocalCacheFile), filepath.Base(s.LocalCacheFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(content); err == nil {
+		err = file.Chmod(0600)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), s.LocalCacheFile)
+}
+
+// readCacheFile reads the cache file, and returns its data once its checksum is verified
+func readCacheFile(filename string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &storageCache{}
+	if err = json.Unmarshal(content, cache); err != nil {
+		return nil, err
+	}
+
+	checksum := sha256.Sum256(cache.Data)
+	if hex.EncodeToString(checksum[:]) != cache.Checksum {
+		return nil, errors.New("invalid checksum")
+	}
+	return cache.Data, nil
+}
+
+// LoadCache loads the account and the certificates from the cache file, after the storage failed to be loaded.
+// The store is read-only and degraded until the storage is reloaded.
+func (s *LocalStore) LoadCache() error {
+	if len(s.LocalCacheFile) == 0 {
+		return errors.New("no cache of the ACME storage")
+	}
+
+	// The cache is written while the storage is loaded, it is read before taking the lock of the load
+	s.cacheLock.Lock()
+	data, err := readCacheFile(s.LocalCacheFile)
+	s.cacheLock.Unlock()
+	if err != nil {
+		return fmt.Errorf("unable to read the ACME storage cache %s: %v", s.LocalCacheFile, err)
+	}
+
+	storedData := &StoredData{
+		HTTPChallenges:          make(map[string]map[string][]byte),
+		HTTPChallengesCreatedAt: make(map[string]map[string]time.Time),
+		TLSChallenges:           make(map[string]*Certificate),
+		TLSChallengesCreatedAt:  make(map[string]time.Time),
+	}
+	if _, _, err := s.decodeStoredData(data, storedData); err != nil {
+		return fmt.Errorf("unable to decode the ACME storage cache %s: %v", s.LocalCacheFile, err)
+	}
+
+	s.loadLock.Lock()
+	s.storedData = storedData
+	s.loadLock.Unlock()
+
+	s.SetReadOnly(true)
+	atomic.StoreInt32(&s.servingCache, 1)
+	s.health.setDegraded(true)
+
+	s.logger(storeOperationLoad).Warnf("The ACME storage can not be loaded, the %d certificates of the cache %s are served in read-only mode until it is loaded again.", len(storedData.Certificates), s.LocalCacheFile)
+	return nil
+}
+
+// leaveCache records that the storage is loaded again, the data of the cache being replaced
+func (s *LocalStore) leaveCache() {
+	if atomic.CompareAndSwapInt32(&s.servingCache, 1, 0) {
+		s.health.setDegraded(false)
+	}
+}
+
+// loadStorageCache serves the store from its cache after the storage failed to be loaded, and returns whether it does
+func (p *Provider) loadStorageCache(loadErr error) bool {
+	store, ok := unwrapStore(p.Store).(cacheStore)
+	if !ok || len(p.StorageLocalCache) == 0 {
+		return false
+	}
+
+	if err := store.LoadCache(); err != nil {
+		logger().WithField(logFieldOperation, storeOperationLoad).Errorf("Unable to serve the ACME storage from its cache after the load failed (%v): %v", loadErr, err)
+		return false
+	}
+
+	p.servingCache = true
+	return true
+}
+
+// reconcileStorageCache loads the storage again until it succeeds, while the certificates of the cache are served,
+// then serves the account and the certificates of the storage and leaves the read-only mode
+func (p *Provider) reconcileStorageCache() {
+	if !p.servingCache {
+		return
+	}
+
+	store, ok := unwrapStore(p.Store).(reloadStore)
+	if !ok {
+		return
+	}
+
+	p.pool.Go(func(stop chan bool) {
+		for attempt := 1; ; attempt++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(getStorageLoadDelay(attempt)):
+			}
+
+			if err := store.Reload(); err != nil {
+				logger().WithField(logFieldOperation, storeOperationLoad).Debugf("Unable to load the ACME storage served from its cache (attempt %d): %v", attempt, err)
+				continue
+			}
+
+			if err := p.serveReloadedStorage(); err != nil {
+				logger().Errorf("Unable to serve the reloaded ACME storage: %v", err)
+			}
+			if !p.ReadOnly {
+				p.SetReadOnly(false)
+			}
+
+			logger().WithField(logFieldOperation, storeOperationLoad).Infof("The ACME storage is loaded after %d attempt(s), it is no longer served from its cache.", attempt)
+			return
+		}
+	})
+}
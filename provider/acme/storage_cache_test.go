@@ -0,0 +1,121 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreLocalCache(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		encryption string
+	}{
+		{
+			desc: "plaintext",
+		},
+		{
+			desc:       "full encryption",
+			encryption: storageEncryptionModeFull,
+		},
+		{
+			desc:       "keys encryption",
+			encryption: storageEncryptionModeKeys,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			cacheFile := filepath.Join(dir, "acme.cache.json")
+			store := NewLocalStore(filepath.Join(dir, "acme.json"))
+			store.LocalCacheFile = cacheFile
+			if len(test.encryption) > 0 {
+				store.Encryption = writeTestStorageKey(t, dir, "acme.key", bytes.Repeat([]byte{1}, 32))
+				store.Encryption.Mode = test.encryption
+			}
+
+			certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("secret-key")}
+			require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+			require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{certificate}))
+
+			// The cache is written after the storage
+			require.NoError(t, store.Close(context.Background()))
+			data, err := readCacheFile(cacheFile)
+			require.NoError(t, err)
+
+			if len(test.encryption) > 0 {
+				assert.NotContains(t, string(data), base64.StdEncoding.EncodeToString([]byte("secret-key")))
+			} else {
+				assert.Contains(t, string(data), base64.StdEncoding.EncodeToString([]byte("secret-key")))
+			}
+
+			// The storage can not be read, the certificates are served from the cache
+			require.NoError(t, os.Remove(store.filename))
+			require.NoError(t, os.Mkdir(store.filename, 0700))
+
+			cached := &LocalStore{filename: store.filename, LocalCacheFile: cacheFile, Encryption: store.Encryption}
+			_, err = cached.GetAccount(context.Background())
+			require.Error(t, err)
+
+			require.NoError(t, cached.LoadCache())
+			assert.True(t, cached.IsReadOnly())
+			assert.True(t, cached.GetHealth().Degraded)
+
+			account, err := cached.GetAccount(context.Background())
+			require.NoError(t, err)
+			require.NotNil(t, account)
+			assert.Equal(t, "test@traefik.wtf", account.Email)
+
+			certificates, err := cached.GetCertificates(context.Background())
+			require.NoError(t, err)
+			require.Len(t, certificates, 1)
+			assert.Equal(t, []byte("secret-key"), certificates[0].Key)
+
+			// The storage is served again once it can be loaded
+			require.NoError(t, os.Remove(store.filename))
+			require.NoError(t, cached.Reload())
+			assert.False(t, cached.GetHealth().Degraded)
+		})
+	}
+}
+
+func TestReadCacheFileChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cacheFile := filepath.Join(dir, "acme.cache.json")
+	store := &LocalStore{LocalCacheFile: cacheFile}
+	store.writeCache(&StoredData{Account: &Account{Email: "test@traefik.wtf"}})
+
+	data, err := readCacheFile(cacheFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "test@traefik.wtf")
+
+	cache := &storageCache{}
+	content, err := ioutil.ReadFile(cacheFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, cache))
+
+	cache.Data = bytes.Replace(cache.Data, []byte("test@"), []byte("evil@"), 1)
+	content, err = json.Marshal(cache)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(cacheFile, content, 0600))
+
+	_, err = readCacheFile(cacheFile)
+	assert.Error(t, err)
+	assert.Error(t, store.LoadCache())
+}
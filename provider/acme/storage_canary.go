@@ -0,0 +1,223 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+)
+
+// The behaviors when the canary check of the storage fails
+const (
+	// StorageCanaryWarn logs the failure, and starts anyway
+	StorageCanaryWarn = "warn"
+	// StorageCanaryRefuse refuses to start
+	StorageCanaryRefuse = "refuse"
+)
+
+const (
+	// storageCanaryTimeout bounds the canary check, its writes included
+	storageCanaryTimeout = 30 * time.Second
+	// storageCanaryValueSize is the size of the random value of the canary
+	storageCanaryValueSize = 32
+)
+
+// StorageCanary checks on start that the storage can be written, read back and decrypted
+type StorageCanary struct {
+	OnFailure string `description:"Behavior when the canary check fails: warn to log it and start anyway, or refuse to start. Default to warn"`
+}
+
+func (c *StorageCanary) getOnFailure() string {
+	if len(c.OnFailure) == 0 {
+		return StorageCanaryWarn
+	}
+	return c.OnFailure
+}
+
+func (c *StorageCanary) checkOnFailure() error {
+	switch c.OnFailure {
+	case "", StorageCanaryWarn, StorageCanaryRefuse:
+		return nil
+	default:
+		return fmt.Errorf("unknown behavior %q of the ACME storage canary, expected %s or %s", c.OnFailure, StorageCanaryWarn, StorageCanaryRefuse)
+	}
+}
+
+// StorageCanaryEntry is the canary written to the storage, and deleted once read back
+type StorageCanaryEntry struct {
+	Value          []byte          `json:",omitempty"`
+	EncryptedValue *encryptedField `json:",omitempty"`
+	WrittenAt      time.Time
+}
+
+// StorageCanaryResult is the result of the canary check of the storage
+type StorageCanaryResult struct {
+	Time   time.Time `json:"time"`
+	Passed bool      `json:"passed"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// canaryStore is implemented by the stores able to check their storage with a canary
+type canaryStore interface {
+	CheckCanary(ctx context.Context) error
+}
+
+// CheckCanary writes a canary to the storage file, reads it back, and deletes it, leaving the rest of the storage unchanged.
+// The canary is encrypted as the private keys, it must not be written in plaintext to an encrypted storage.
+func (s *LocalStore) CheckCanary(ctx context.Context) error {
+	err := s.checkCanary(ctx)
+
+	result := &StorageCanaryResult{Time: time.Now(), Passed: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	s.canaryLock.Lock()
+	s.canary = result
+	s.canaryLock.Unlock()
+
+	s.metricsLock.Lock()
+	reportStorageCanary(s.metricsRegistry, getStoreBackend(s), result)
+	s.metricsLock.Unlock()
+
+	return err
+}
+
+func (s *LocalStore) checkCanary(ctx context.Context) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	value := make([]byte, storageCanaryValueSize)
+	if _, err := io.ReadFull(rand.Reader, value); err != nil {
+		return fmt.Errorf("unable to generate the canary: %v", err)
+	}
+
+	err := s.Update(ctx, func(data *StoredData) error {
+		data.Canary = &StorageCanaryEntry{Value: value, WrittenAt: time.Now()}
+		return nil
+	})
+	if err == nil {
+		err = s.WaitPersisted(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write the canary: %v", err)
+	}
+
+	readErr := s.readCanary(value)
+
+	// The canary is deleted whether it was read back or not
+	err = s.Update(ctx, func(data *StoredData) error {
+		data.Canary = nil
+		return nil
+	})
+	if err == nil {
+		err = s.WaitPersisted(ctx)
+	}
+
+	switch {
+	case readErr != nil:
+		return readErr
+	case err != nil:
+		return fmt.Errorf("unable to delete the canary: %v", err)
+	}
+
+	storedData, err := s.ReadStorage()
+	if err != nil {
+		return fmt.Errorf("unable to read the storage once the canary is deleted: %v", err)
+	}
+	if storedData.Canary != nil {
+		return errors.New("the canary is still in the storage once deleted")
+	}
+	return nil
+}
+
+// readCanary reads the storage file, and checks that it holds the canary written, encrypted when the storage is
+func (s *LocalStore) readCanary(value []byte) error {
+	file, err := ioutil.ReadFile(s.filename)
+	if err != nil {
+		return fmt.Errorf("unable to read back the canary: %v", err)
+	}
+
+	if s.Encryption != nil && bytes.Contains(file, []byte(base64.StdEncoding.EncodeToString(value))) {
+		return errors.New("the canary is written in plaintext to the encrypted storage")
+	}
+
+	storedData := &StoredData{}
+	if _, _, err = s.decodeStoredData(file, storedData); err != nil {
+		return fmt.Errorf("unable to decode the canary read back: %v", err)
+	}
+
+	if storedData.Canary == nil {
+		return errors.New("the canary is missing from the storage read back")
+	}
+	if !bytes.Equal(storedData.Canary.Value, value) {
+		return errors.New("the canary read back differs from the one written")
+	}
+	return nil
+}
+
+// getCanary returns the result of the canary check, nil when the storage was not checked
+func (s *LocalStore) getCanary() *StorageCanaryResult {
+	s.canaryLock.RLock()
+	defer s.canaryLock.RUnlock()
+
+	if s.canary == nil {
+		return nil
+	}
+	result := *s.canary
+	return &result
+}
+
+func reportStorageCanary(registry metrics.Registry, backend string, result *StorageCanaryResult) {
+	if registry == nil || result == nil || registry.ACMEStoreCanaryGauge() == nil {
+		return
+	}
+
+	value := 0.0
+	if result.Passed {
+		value = 1
+	}
+	registry.ACMEStoreCanaryGauge().With("backend", backend).Set(value)
+}
+
+// checkStorageCanary checks the storage with a canary on start, the storage not being written in read-only mode
+func (p *Provider) checkStorageCanary() error {
+	if p.StorageCanary == nil {
+		return nil
+	}
+
+	if p.isReadOnly() {
+		logger().WithField(logFieldOperation, storeOperationCanary).Info("The ACME storage is read-only, its canary check is skipped.")
+		return nil
+	}
+
+	store, ok := unwrapStore(p.Store).(canaryStore)
+	if !ok {
+		logger().WithField(logFieldOperation, storeOperationCanary).Warn("The ACME store does not support the canary check, it is skipped.")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(p.getContext(), storageCanaryTimeout)
+	defer cancel()
+
+	err := store.CheckCanary(ctx)
+	if err == nil {
+		logger().WithField(logFieldOperation, storeOperationCanary).Info("The canary check of the ACME storage passed: it can be written, read back and decrypted.")
+		return nil
+	}
+
+	if p.StorageCanary.getOnFailure() == StorageCanaryRefuse {
+		return fmt.Errorf("the canary check of the ACME storage failed: %v", err)
+	}
+
+	logger().WithField(logFieldOperation, storeOperationCanary).Errorf("The canary check of the ACME storage failed, starting anyway: %v", err)
+	return nil
+}
@@ -0,0 +1,146 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreCheckCanary(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		encryptionMode string
+	}{
+		{
+			desc: "plaintext storage",
+		},
+		{
+			desc:           "fully encrypted storage",
+			encryptionMode: storageEncryptionModeFull,
+		},
+		{
+			desc:           "private keys encrypted",
+			encryptionMode: storageEncryptionModeKeys,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			filename := filepath.Join(dir, "acme.json")
+			store := NewLocalStore(filename)
+			if len(test.encryptionMode) > 0 {
+				store.Encryption = writeTestStorageKey(t, dir, "acme.key", bytes.Repeat([]byte{1}, 32))
+				store.Encryption.Mode = test.encryptionMode
+			}
+			registry := newCollectingACMEMetrics()
+			store.SetMetricsRegistry(registry)
+
+			certificates := []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}
+			require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+			require.NoError(t, store.WaitPersisted(context.Background()))
+
+			require.NoError(t, store.CheckCanary(context.Background()))
+
+			canary := store.GetStatus().Canary
+			require.NotNil(t, canary)
+			assert.True(t, canary.Passed)
+			assert.Empty(t, canary.Error)
+			assert.Equal(t, float64(1), registry.canary.GaugeValue)
+
+			// The canary is deleted, the rest of the storage is left unchanged
+			storedData, err := store.ReadStorage()
+			require.NoError(t, err)
+			assert.Nil(t, storedData.Canary)
+			require.Len(t, storedData.Certificates, 1)
+			assert.Equal(t, []byte("key"), storedData.Certificates[0].Key)
+		})
+	}
+}
+
+func TestLocalStoreCheckCanaryWriteFailure(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		return errors.New("permission denied")
+	}
+
+	registry := newCollectingACMEMetrics()
+	store.SetMetricsRegistry(registry)
+
+	err := store.CheckCanary(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to write the canary")
+
+	canary := store.GetStatus().Canary
+	require.NotNil(t, canary)
+	assert.False(t, canary.Passed)
+	assert.Equal(t, err.Error(), canary.Error)
+	assert.Equal(t, float64(0), registry.canary.GaugeValue)
+}
+
+func TestProviderCheckStorageCanary(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		onFailure     string
+		readOnly      bool
+		expectedError bool
+		expectedCheck bool
+	}{
+		{
+			desc:          "warn",
+			expectedCheck: true,
+		},
+		{
+			desc:          "refuse",
+			onFailure:     StorageCanaryRefuse,
+			expectedError: true,
+			expectedCheck: true,
+		},
+		{
+			desc:      "read-only storage",
+			onFailure: StorageCanaryRefuse,
+			readOnly:  true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			store, clean := newTestLocalStore(t)
+			defer clean()
+
+			store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+				return errors.New("permission denied")
+			}
+
+			p := &Provider{Configuration: &Configuration{StorageCanary: &StorageCanary{OnFailure: test.onFailure}}, Store: store}
+			store.SetReadOnly(test.readOnly)
+
+			err := p.checkStorageCanary()
+			if test.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedCheck, store.GetStatus().Canary != nil)
+		})
+	}
+}
+
+func TestStorageCanaryCheckOnFailure(t *testing.T) {
+	assert.NoError(t, (&StorageCanary{}).checkOnFailure())
+	assert.NoError(t, (&StorageCanary{OnFailure: StorageCanaryRefuse}).checkOnFailure())
+	assert.Error(t, (&StorageCanary{OnFailure: "ignore"}).checkOnFailure())
+}
@@ -0,0 +1,161 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	consistencySectionAccount        = "account"
+	consistencySectionCertificates   = "certificates"
+	consistencySectionDNSChallenges  = "dnsChallenges"
+	consistencySectionOnDemandQueue  = "onDemandQueue"
+	consistencySectionDesiredDomains = "desiredDomains"
+	consistencySectionOrderHistory   = "orderHistory"
+	consistencySectionStagingAccount = "stagingAccount"
+)
+
+// consistencyStore is implemented by the stores able to check that their storage holds the data in memory
+type consistencyStore interface {
+	CheckConsistency() ([]string, error)
+}
+
+// getSectionHashes hashes the sections of the data written by every save. The HTTP-01 and TLS-ALPN-01 challenges
+// are only written by the next save, they are not hashed.
+func getSectionHashes(storedData *StoredData, withCertificates bool) (map[string][sha256.Size]byte, error) {
+	sections := map[string]interface{}{
+		consistencySectionAccount:        storedData.Account,
+		consistencySectionDNSChallenges:  storedData.DNSChallenges,
+		consistencySectionOnDemandQueue:  storedData.OnDemandQueue,
+		consistencySectionDesiredDomains: storedData.DesiredDomains,
+		consistencySectionOrderHistory:   storedData.OrderHistory,
+		consistencySectionStagingAccount: storedData.StagingAccount,
+	}
+	if withCertificates {
+		sections[consistencySectionCertificates] = storedData.Certificates
+	}
+
+	hashes := make(map[string][sha256.Size]byte, len(sections))
+	for section, value := range sections {
+		content, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to hash the %s: %v", section, err)
+		}
+
+		// The empty sections are omitted from the storage
+		if bytes.Equal(content, []byte("{}")) || bytes.Equal(content, []byte("[]")) {
+			content = []byte("null")
+		}
+		hashes[section] = sha256.Sum256(content)
+	}
+	return hashes, nil
+}
+
+// CheckConsistency compares the storage file last written with the data in memory, and writes the data in memory again
+// when they differ, returning the differing sections. The data in memory is authoritative: a difference is a save which
+// was lost. The check is skipped while changes are not saved yet, and when the file was changed since it was last written
+// or loaded, which is a drift of the storage.
+func (s *LocalStore) CheckConsistency() ([]string, error) {
+	if s.IsReadOnly() || s.health.hasPendingChanges() {
+		return nil, nil
+	}
+
+	s.loadLock.Lock()
+	storedData := s.storedData
+	s.loadLock.Unlock()
+	if storedData == nil {
+		return nil, nil
+	}
+
+	file, err := ioutil.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		file, err = nil, nil
+	} else if err == nil && len(file) == 0 {
+		// The file is truncated by a write, it is checked again next time
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	withCertificates := s.CertificateSecrets == nil || atomic.LoadInt32(&s.certificatesInSecrets) == 0
+
+	s.lock.RLock()
+	localHashes, err := getSectionHashes(storedData, withCertificates)
+	s.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// The data in memory is hashed after the file is read: a save handed over in the meantime is still pending,
+	// or changed the file once written
+	if s.health.hasPendingChanges() || !s.hash.matches(file) {
+		s.logger(storeOperationLoad).Debug("Skip the consistency check of the ACME storage, it is being saved or was changed.")
+		return nil, nil
+	}
+
+	remote := &StoredData{}
+	if len(file) > 0 {
+		if _, _, err := s.decodeStoredData(file, remote); err != nil {
+			return nil, err
+		}
+	}
+
+	remoteHashes, err := getSectionHashes(remote, withCertificates)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []string
+	for section, hash := range localHashes {
+		if remoteHashes[section] != hash {
+			sections = append(sections, section)
+		}
+	}
+	if len(sections) == 0 {
+		return nil, nil
+	}
+	sort.Strings(sections)
+
+	s.logger(storeOperationSave).WithField("sections", strings.Join(sections, ",")).Warn("The ACME storage does not hold the data in memory, writing it again.")
+	s.countMismatches(sections)
+
+	s.payload.reset()
+	s.save(storedData)
+	s.flush()
+
+	return sections, nil
+}
+
+func (s *LocalStore) countMismatches(sections []string) {
+	s.metricsLock.Lock()
+	registry := s.metricsRegistry
+	s.metricsLock.Unlock()
+
+	if registry == nil {
+		return
+	}
+
+	for _, section := range sections {
+		registry.ACMEStoreMismatchesCounter().With("backend", getStoreBackend(s), "section", section).Add(1)
+	}
+}
+
+// checkStorageConsistency checks that the storage holds the data in memory, the store writes it again otherwise
+func (p *Provider) checkStorageConsistency() {
+	store, ok := unwrapStore(p.Store).(consistencyStore)
+	if !ok {
+		return
+	}
+
+	if _, err := store.CheckConsistency(); err != nil {
+		logger().WithField(logFieldOperation, storeOperationLoad).Errorf("Unable to check the consistency of the ACME storage: %v", err)
+	}
+}
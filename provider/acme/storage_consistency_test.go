@@ -0,0 +1,61 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreCheckConsistency(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+	store.SetMetricsRegistry(registry)
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool { return len(storedData.Certificates) == 1 })
+	waitForStoreHealth(t, store, func(*StoreHealth) bool { return !store.health.hasPendingChanges() })
+
+	sections, err := store.CheckConsistency()
+	require.NoError(t, err)
+	assert.Empty(t, sections)
+
+	// A change of the data in memory which is not saved is written again
+	store.storedData.Account.Email = "lost@traefik.wtf"
+	store.storedData.Certificates = nil
+
+	sections, err = store.CheckConsistency()
+	require.NoError(t, err)
+	assert.Equal(t, []string{consistencySectionAccount, consistencySectionCertificates}, sections)
+	assert.Equal(t, float64(2), registry.mismatches.CounterValue)
+
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil && storedData.Account.Email == "lost@traefik.wtf" && len(storedData.Certificates) == 0
+	})
+}
+
+func TestLocalStoreCheckConsistencyChangedStorage(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool { return storedData.Account != nil })
+	waitForStoreHealth(t, store, func(*StoreHealth) bool { return !store.health.hasPendingChanges() })
+
+	// The storage changed by others is a drift, it is not overwritten
+	require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{"Account":{"Email":"other@traefik.wtf"}}`), 0600))
+
+	sections, err := store.CheckConsistency()
+	require.NoError(t, err)
+	assert.Empty(t, sections)
+
+	content, err := ioutil.ReadFile(store.filename)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "other@traefik.wtf")
+}
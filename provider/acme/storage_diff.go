@@ -0,0 +1,230 @@
+package acme
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// storedDataSnapshot is what is compared of the stored data: the identity of the account, the fingerprints of the certificates
+// and the number of challenges. It holds no private key, the diffs built from it cannot leak one.
+type storedDataSnapshot struct {
+	account        *accountIdentity
+	certificates   map[string]*certificateSnapshot
+	httpChallenges int
+	tlsChallenges  int
+	dnsChallenges  int
+}
+
+// accountIdentity identifies an account, its private key may not be stored
+type accountIdentity struct {
+	email           string
+	registrationURI string
+}
+
+type certificateSnapshot struct {
+	fingerprint string
+	// certificate is the public PEM certificate, parsed only when the certificate changed
+	certificate []byte
+}
+
+func (c *certificateSnapshot) getNotAfter() *time.Time {
+	if c == nil {
+		return nil
+	}
+
+	leaf, err := parseCertificateLeaf(c.certificate)
+	if err != nil {
+		return nil
+	}
+	return &leaf.NotAfter
+}
+
+// newStoredDataSnapshot takes a snapshot of the stored data, the certificates being identified by their domains
+func newStoredDataSnapshot(storedData *StoredData) *storedDataSnapshot {
+	snapshot := &storedDataSnapshot{
+		certificates:  make(map[string]*certificateSnapshot),
+		tlsChallenges: len(storedData.TLSChallenges),
+		dnsChallenges: len(storedData.DNSChallenges),
+	}
+
+	if storedData.Account != nil {
+		snapshot.account = &accountIdentity{email: storedData.Account.Email, registrationURI: getRegistrationURI(storedData.Account)}
+	}
+
+	for _, certificate := range storedData.Certificates {
+		fingerprint := sha256.Sum256(certificate.Certificate)
+		snapshot.certificates[strings.Join(certificate.Domain.ToStrArray(), ",")] = &certificateSnapshot{
+			fingerprint: hex.EncodeToString(fingerprint[:]),
+			certificate: certificate.Certificate,
+		}
+	}
+
+	for _, domains := range storedData.HTTPChallenges {
+		snapshot.httpChallenges += len(domains)
+	}
+
+	return snapshot
+}
+
+// storedDataDiff is the difference between two snapshots of the stored data
+type storedDataDiff struct {
+	AccountChanged bool
+	// Added are the certificates of the next snapshot only
+	Added []string
+	// Removed are the certificates of the previous snapshot only
+	Removed []string
+	// Changed are the certificates with another fingerprint in the next snapshot
+	Changed []*certificateChange
+	// HTTPChallenges, TLSChallenges and DNSChallenges are the differences of the number of challenges
+	HTTPChallenges int
+	TLSChallenges  int
+	DNSChallenges  int
+}
+
+// certificateChange describes a certificate with another fingerprint, renewed most of the time
+type certificateChange struct {
+	Domains        string
+	OldFingerprint string
+	NewFingerprint string
+	OldNotAfter    *time.Time
+	NewNotAfter    *time.Time
+}
+
+func (c *certificateChange) String() string {
+	return fmt.Sprintf("%s: %s (%s) -> %s (%s)", c.Domains, shortFingerprint(c.OldFingerprint), formatNotAfter(c.OldNotAfter),
+		shortFingerprint(c.NewFingerprint), formatNotAfter(c.NewNotAfter))
+}
+
+// diffSnapshots compares the next snapshot of the stored data with the previous one
+func diffSnapshots(previous, next *storedDataSnapshot) *storedDataDiff {
+	diff := &storedDataDiff{
+		AccountChanged: isAccountIdentityChanged(previous.account, next.account),
+		HTTPChallenges: next.httpChallenges - previous.httpChallenges,
+		TLSChallenges:  next.tlsChallenges - previous.tlsChallenges,
+		DNSChallenges:  next.dnsChallenges - previous.dnsChallenges,
+	}
+
+	for domains, certificate := range next.certificates {
+		previousCertificate, ok := previous.certificates[domains]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, domains)
+		case previousCertificate.fingerprint != certificate.fingerprint:
+			diff.Changed = append(diff.Changed, &certificateChange{
+				Domains:        domains,
+				OldFingerprint: previousCertificate.fingerprint,
+				NewFingerprint: certificate.fingerprint,
+			})
+		}
+	}
+	for domains := range previous.certificates {
+		if _, ok := next.certificates[domains]; !ok {
+			diff.Removed = append(diff.Removed, domains)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Domains < diff.Changed[j].Domains })
+	return diff
+}
+
+func isAccountIdentityChanged(previous, next *accountIdentity) bool {
+	if previous == nil || next == nil {
+		return previous != next
+	}
+	return *previous != *next
+}
+
+// withNotAfter sets the expiry dates of the changed certificates, the certificates are only parsed for the log
+func (d *storedDataDiff) withNotAfter(previous, next *storedDataSnapshot) *storedDataDiff {
+	for _, change := range d.Changed {
+		change.OldNotAfter = previous.certificates[change.Domains].getNotAfter()
+		change.NewNotAfter = next.certificates[change.Domains].getNotAfter()
+	}
+	return d
+}
+
+func (d *storedDataDiff) changedDomains() []string {
+	var domains []string
+	for _, change := range d.Changed {
+		domains = append(domains, change.Domains)
+	}
+	return domains
+}
+
+func (d *storedDataDiff) isEmpty() bool {
+	return !d.AccountChanged && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 &&
+		d.HTTPChallenges == 0 && d.TLSChallenges == 0 && d.DNSChallenges == 0
+}
+
+func (d *storedDataDiff) fields() logrus.Fields {
+	var changed []string
+	for _, change := range d.Changed {
+		changed = append(changed, change.String())
+	}
+
+	return logrus.Fields{
+		"accountChanged": d.AccountChanged,
+		"added":          strings.Join(d.Added, ";"),
+		"removed":        strings.Join(d.Removed, ";"),
+		"changed":        strings.Join(changed, ";"),
+		"challenges":     fmt.Sprintf("http=%+d,tls=%+d,dns=%+d", d.HTTPChallenges, d.TLSChallenges, d.DNSChallenges),
+	}
+}
+
+func shortFingerprint(fingerprint string) string {
+	if len(fingerprint) > 16 {
+		return fingerprint[:16]
+	}
+	return fingerprint
+}
+
+func formatNotAfter(notAfter *time.Time) string {
+	if notAfter == nil {
+		return "unknown expiry"
+	}
+	return "notAfter " + notAfter.UTC().Format(time.RFC3339)
+}
+
+// writtenSnapshot is the snapshot of the data last written, the next write is logged as its difference with it
+type writtenSnapshot struct {
+	lock     sync.Mutex
+	snapshot *storedDataSnapshot
+}
+
+func (w *writtenSnapshot) get() *storedDataSnapshot {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.snapshot
+}
+
+func (w *writtenSnapshot) set(snapshot *storedDataSnapshot) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.snapshot = snapshot
+}
+
+// logWriteDiff logs what the write changes in the storage, at the debug level
+func (s *LocalStore) logWriteDiff(snapshot *storedDataSnapshot) {
+	previous := s.written.get()
+	if previous == nil {
+		previous = newStoredDataSnapshot(&StoredData{})
+	}
+
+	diff := diffSnapshots(previous, snapshot)
+	if diff.isEmpty() {
+		return
+	}
+
+	s.logger(storeOperationSave).WithFields(diff.withNotAfter(previous, snapshot).fields()).Debug("Write the changes of the ACME storage.")
+}
@@ -0,0 +1,77 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	notAfter := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	renewedNotAfter := notAfter.Add(90 * 24 * time.Hour)
+
+	previous := &StoredData{
+		Account: &Account{Email: "foo@foo.net", Registration: &acme.RegistrationResource{URI: "https://acme/acct/1"}},
+		Certificates: []*Certificate{
+			{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, notAfter), Key: []byte("private-key")},
+			{Domain: types.Domain{Main: "other.wtf"}, Certificate: []byte("other")},
+		},
+		HTTPChallenges: map[string]map[string][]byte{"token": {"traefik.wtf": []byte("keyAuth")}},
+	}
+	next := &StoredData{
+		Account: previous.Account,
+		Certificates: []*Certificate{
+			{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: generateTestCertificate(t, renewedNotAfter), Key: []byte("private-key")},
+			{Domain: types.Domain{Main: "new.wtf"}, Certificate: []byte("new")},
+		},
+		TLSChallenges: map[string]*Certificate{"new.wtf": {}},
+	}
+
+	previousSnapshot, nextSnapshot := newStoredDataSnapshot(previous), newStoredDataSnapshot(next)
+	diff := diffSnapshots(previousSnapshot, nextSnapshot).withNotAfter(previousSnapshot, nextSnapshot)
+
+	assert.False(t, diff.AccountChanged)
+	assert.Equal(t, []string{"new.wtf"}, diff.Added)
+	assert.Equal(t, []string{"other.wtf"}, diff.Removed)
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, "traefik.wtf", diff.Changed[0].Domains)
+	assert.NotEqual(t, diff.Changed[0].OldFingerprint, diff.Changed[0].NewFingerprint)
+	require.NotNil(t, diff.Changed[0].OldNotAfter)
+	assert.True(t, notAfter.Equal(*diff.Changed[0].OldNotAfter))
+	require.NotNil(t, diff.Changed[0].NewNotAfter)
+	assert.True(t, renewedNotAfter.Equal(*diff.Changed[0].NewNotAfter))
+	assert.Equal(t, -1, diff.HTTPChallenges)
+	assert.Equal(t, 1, diff.TLSChallenges)
+	assert.Equal(t, 0, diff.DNSChallenges)
+
+	fields := diff.fields()
+	assert.Equal(t, "http=-1,tls=+1,dns=+0", fields["challenges"])
+	assert.NotContains(t, fmt.Sprint(fields), "private-key")
+
+	assert.True(t, diffSnapshots(nextSnapshot, newStoredDataSnapshot(next)).isEmpty())
+}
+
+func TestLocalStoreLogWriteDiff(t *testing.T) {
+	hook, restore := collectLogs()
+	defer restore()
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate"), Key: []byte("private-key")}
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{certificate}))
+	require.NoError(t, store.Persist(context.Background()))
+
+	fields := hook.findEntry("Write the changes of the ACME storage.")
+	require.NotNil(t, fields)
+	assert.Equal(t, storeOperationSave, fields[logFieldOperation])
+	assert.Equal(t, "traefik.wtf", fields["added"])
+	assert.Equal(t, false, fields["accountChanged"])
+	assert.NotContains(t, fmt.Sprint(fields), "private-key")
+}
@@ -0,0 +1,288 @@
+package acme
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	storageDriftPolicyAlert    = "alert"
+	storageDriftPolicyAdopt    = "adopt"
+	storageDriftPolicyReassert = "reassert"
+
+	defaultStorageDriftInterval = 10 * time.Minute
+
+	driftKindAccount = "account"
+	driftKindAdded   = "added"
+	driftKindRemoved = "removed"
+	driftKindChanged = "changed"
+)
+
+// StorageDrift periodically compares the storage with the account and the certificates in memory
+type StorageDrift struct {
+	Interval parse.Duration `description:"Interval between two comparisons of the storage with the data in memory. Default to 10m"`
+	Policy   string         `description:"Reconciliation of the differences: alert only logs and counts them, adopt loads the storage, reassert saves the data in memory. Default to alert"`
+}
+
+func (d *StorageDrift) getPolicy() string {
+	if len(d.Policy) == 0 {
+		return storageDriftPolicyAlert
+	}
+	return d.Policy
+}
+
+func (d *StorageDrift) checkPolicy() error {
+	switch d.Policy {
+	case "", storageDriftPolicyAlert, storageDriftPolicyAdopt, storageDriftPolicyReassert:
+		return nil
+	default:
+		return fmt.Errorf("unsupported storage drift policy %q, please select alert, adopt or reassert", d.Policy)
+	}
+}
+
+func (d *StorageDrift) getInterval() time.Duration {
+	if d.Interval <= 0 {
+		return defaultStorageDriftInterval
+	}
+	return time.Duration(d.Interval)
+}
+
+// driftStore is implemented by the stores able to read their backend again, regardless of the data in memory
+type driftStore interface {
+	ReadStorage() (*StoredData, error)
+}
+
+// ReadStorage reads the storage file again, without changing the data in memory
+func (s *LocalStore) ReadStorage() (*StoredData, error) {
+	file, err := ioutil.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		file, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The data in memory is written again on the next save when the file was changed by others
+	if !s.hash.matches(file) {
+		s.payload.reset()
+	}
+
+	storedData := &StoredData{}
+	if len(file) == 0 {
+		return storedData, nil
+	}
+
+	if _, _, err := s.decodeStoredData(file, storedData); err != nil {
+		return nil, err
+	}
+	return storedData, nil
+}
+
+// storageDrift is the difference between the data in memory and the storage,
+// the certificates are identified by their domains and compared by fingerprint
+type storageDrift struct {
+	AccountChanged bool
+	// Added are the certificates of the storage only
+	Added []string
+	// Removed are the certificates in memory only
+	Removed []string
+	// Changed are the certificates with another fingerprint in the storage
+	Changed []string
+}
+
+// diffStoredData compares the account and the certificates of the storage with the local ones
+func diffStoredData(local, remote *StoredData) *storageDrift {
+	diff := diffSnapshots(newStoredDataSnapshot(local), newStoredDataSnapshot(remote))
+	return &storageDrift{
+		AccountChanged: diff.AccountChanged,
+		Added:          diff.Added,
+		Removed:        diff.Removed,
+		Changed:        diff.changedDomains(),
+	}
+}
+
+// isAccountDrifted compares the identity of the accounts, their private key may not be stored
+func isAccountDrifted(local, remote *Account) bool {
+	if local == nil || remote == nil {
+		return local != remote
+	}
+
+	return local.Email != remote.Email || getRegistrationURI(local) != getRegistrationURI(remote)
+}
+
+func getRegistrationURI(account *Account) string {
+	if account.Registration == nil {
+		return ""
+	}
+	return account.Registration.URI
+}
+
+func (d *storageDrift) isEmpty() bool {
+	return !d.AccountChanged && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d *storageDrift) fields() logrus.Fields {
+	return logrus.Fields{
+		"accountChanged": d.AccountChanged,
+		"added":          strings.Join(d.Added, ";"),
+		"removed":        strings.Join(d.Removed, ";"),
+		"changed":        strings.Join(d.Changed, ";"),
+	}
+}
+
+// StorageDriftStatus is the result of the last comparison of the storage with the data in memory
+type StorageDriftStatus struct {
+	Policy    string     `json:"policy"`
+	LastCheck *time.Time `json:"lastCheck,omitempty"`
+	Drifted   bool       `json:"drifted"`
+	// AccountChanged, Added, Removed and Changed are the differences reconciled by the last comparison
+	AccountChanged bool     `json:"accountChanged"`
+	Added          []string `json:"added,omitempty"`
+	Removed        []string `json:"removed,omitempty"`
+	Changed        []string `json:"changed,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// storageDriftRecorder keeps the result of the last comparison of the storage with the data in memory
+type storageDriftRecorder struct {
+	lock   sync.RWMutex
+	status *StorageDriftStatus
+}
+
+func (r *storageDriftRecorder) record(policy string, drift *storageDrift, err error) {
+	now := time.Now()
+	status := &StorageDriftStatus{Policy: policy, LastCheck: &now}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if drift != nil {
+		status.Drifted = !drift.isEmpty()
+		status.AccountChanged = drift.AccountChanged
+		status.Added = drift.Added
+		status.Removed = drift.Removed
+		status.Changed = drift.Changed
+	}
+
+	r.lock.Lock()
+	r.status = status
+	r.lock.Unlock()
+}
+
+func (r *storageDriftRecorder) get() *StorageDriftStatus {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.status == nil {
+		return nil
+	}
+	status := *r.status
+	return &status
+}
+
+func countStorageDrift(registry metrics.Registry, backend string, drift *storageDrift) {
+	if registry == nil {
+		return
+	}
+
+	if drift.AccountChanged {
+		registry.ACMEStorageDriftCounter().With("backend", backend, "kind", driftKindAccount).Add(1)
+	}
+	for kind, domains := range map[string][]string{driftKindAdded: drift.Added, driftKindRemoved: drift.Removed, driftKindChanged: drift.Changed} {
+		if len(domains) > 0 {
+			registry.ACMEStorageDriftCounter().With("backend", backend, "kind", kind).Add(float64(len(domains)))
+		}
+	}
+}
+
+// reconcileStorage compares the storage with the account and the certificates in memory, and reconciles them
+// following the policy. It runs in the routine watching the certificates, which owns the certificates in memory.
+func (p *Provider) reconcileStorage() {
+	store, ok := unwrapStore(p.Store).(driftStore)
+	if !ok {
+		return
+	}
+	backend := getStoreBackend(unwrapStore(p.Store))
+	logger := logger().WithField(logFieldStoreBackend, backend)
+
+	policy := p.StorageDrift.getPolicy()
+
+	remote, err := store.ReadStorage()
+	if err != nil {
+		logger.WithField(logFieldOperation, storeOperationLoad).Errorf("Unable to read the ACME storage to check its drift: %v", err)
+		p.driftStatus.record(policy, nil, err)
+		return
+	}
+
+	p.clientMutex.Lock()
+	local := &StoredData{Account: p.account, Certificates: p.certificates}
+	p.clientMutex.Unlock()
+
+	// The certificates kept in Secrets are not in the storage
+	if p.CertificateSecrets != nil {
+		local.Certificates = nil
+		remote.Certificates = nil
+	}
+
+	drift := diffStoredData(local, remote)
+	p.driftStatus.record(policy, drift, nil)
+	if drift.isEmpty() {
+		logger.Debug("The ACME storage matches the data in memory.")
+		return
+	}
+
+	logger.WithFields(drift.fields()).Warnf("The ACME storage drifted from the data in memory, reconciling with the %s policy.", policy)
+	countStorageDrift(p.metricsRegistry, backend, drift)
+
+	switch policy {
+	case storageDriftPolicyAdopt:
+		p.adoptStorage(remote, drift)
+	case storageDriftPolicyReassert:
+		p.reassertStorage(local, drift)
+	}
+}
+
+// adoptStorage replaces the account and the certificates in memory by the ones of the storage
+func (p *Provider) adoptStorage(remote *StoredData, drift *storageDrift) {
+	if drift.AccountChanged {
+		p.clientMutex.Lock()
+		p.account = remote.Account
+		// The clients are built again with the adopted account
+		p.clients = nil
+		p.clientMutex.Unlock()
+
+		if err := p.Store.SaveAccount(p.getContext(), remote.Account); err != nil {
+			logger().WithField(logFieldOperation, storeOperationSave).Errorf("Unable to adopt the account of the ACME storage: %v", err)
+		}
+	}
+
+	if p.CertificateSecrets == nil && (len(drift.Added) > 0 || len(drift.Removed) > 0 || len(drift.Changed) > 0) {
+		p.certificates = remote.Certificates
+		p.certificateIndex.reset(p.certificates)
+		if err := p.saveCertificates(); err != nil {
+			logger().WithField(logFieldOperation, storeOperationSave).Errorf("Unable to adopt the certificates of the ACME storage: %v", err)
+		}
+	}
+}
+
+// reassertStorage saves the account and the certificates in memory in the storage
+func (p *Provider) reassertStorage(local *StoredData, drift *storageDrift) {
+	if drift.AccountChanged && local.Account != nil {
+		if err := p.Store.SaveAccount(p.getContext(), local.Account); err != nil {
+			logger().WithField(logFieldOperation, storeOperationSave).Errorf("Unable to save the account in the ACME storage: %v", err)
+		}
+	}
+
+	if p.CertificateSecrets == nil && (len(drift.Added) > 0 || len(drift.Removed) > 0 || len(drift.Changed) > 0) {
+		if err := p.saveCertificates(); err != nil {
+			logger().WithField(logFieldOperation, storeOperationSave).Errorf("Unable to save the certificates in the ACME storage: %v", err)
+		}
+	}
+}
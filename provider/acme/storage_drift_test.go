@@ -0,0 +1,164 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenolf/lego/acme"
+)
+
+func TestDiffStoredData(t *testing.T) {
+	account := &Account{Email: "foo@foo.net", Registration: &acme.RegistrationResource{URI: "https://acme/acct/1"}}
+	first := &Certificate{Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}, Certificate: []byte("first")}
+	second := &Certificate{Domain: types.Domain{Main: "other.wtf"}, Certificate: []byte("second")}
+
+	testCases := []struct {
+		desc     string
+		local    *StoredData
+		remote   *StoredData
+		expected *storageDrift
+	}{
+		{
+			desc:     "no drift",
+			local:    &StoredData{Account: account, Certificates: []*Certificate{first, second}},
+			remote:   &StoredData{Account: &Account{Email: "foo@foo.net", Registration: &acme.RegistrationResource{URI: "https://acme/acct/1"}}, Certificates: []*Certificate{second, first}},
+			expected: &storageDrift{},
+		},
+		{
+			desc:     "account removed",
+			local:    &StoredData{Account: account},
+			remote:   &StoredData{},
+			expected: &storageDrift{AccountChanged: true},
+		},
+		{
+			desc:     "account registered again",
+			local:    &StoredData{Account: account},
+			remote:   &StoredData{Account: &Account{Email: "foo@foo.net", Registration: &acme.RegistrationResource{URI: "https://acme/acct/2"}}},
+			expected: &storageDrift{AccountChanged: true},
+		},
+		{
+			desc:   "certificates",
+			local:  &StoredData{Certificates: []*Certificate{first}},
+			remote: &StoredData{Certificates: []*Certificate{{Domain: first.Domain, Certificate: []byte("renewed")}, second}},
+			expected: &storageDrift{
+				Added:   []string{"other.wtf"},
+				Changed: []string{"traefik.wtf,www.traefik.wtf"},
+			},
+		},
+		{
+			desc:     "certificate removed",
+			local:    &StoredData{Certificates: []*Certificate{first, second}},
+			remote:   &StoredData{Certificates: []*Certificate{second}},
+			expected: &storageDrift{Removed: []string{"traefik.wtf,www.traefik.wtf"}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			drift := diffStoredData(test.local, test.remote)
+			assert.Equal(t, test.expected, drift)
+			assert.Equal(t, test.expected.isEmpty(), drift.isEmpty())
+		})
+	}
+}
+
+func TestLocalStoreReadStorage(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate")}
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{certificate}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1
+	})
+
+	// The file is edited behind the back of the store
+	require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{"Certificates":[{"Domain":{"Main":"other.wtf"},"Certificate":"b3RoZXI="}]}`), 0600))
+
+	remote, err := store.ReadStorage()
+	require.NoError(t, err)
+
+	drift := diffStoredData(store.storedData, remote)
+	assert.Equal(t, []string{"other.wtf"}, drift.Added)
+	assert.Equal(t, []string{"traefik.wtf"}, drift.Removed)
+
+	// The data in memory is unchanged
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*Certificate{certificate}, certificates)
+}
+
+func TestReconcileStorage(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		policy         string
+		expectedMemory string
+		expectedStored string
+	}{
+		{
+			desc:           "alert",
+			policy:         storageDriftPolicyAlert,
+			expectedMemory: "traefik.wtf",
+			expectedStored: "other.wtf",
+		},
+		{
+			desc:           "adopt",
+			policy:         storageDriftPolicyAdopt,
+			expectedMemory: "other.wtf",
+			expectedStored: "other.wtf",
+		},
+		{
+			desc:           "reassert",
+			policy:         storageDriftPolicyReassert,
+			expectedMemory: "traefik.wtf",
+			expectedStored: "traefik.wtf",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			store, clean := newTestLocalStore(t)
+			defer clean()
+
+			require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{"Certificates":[{"Domain":{"Main":"other.wtf"},"Certificate":"b3RoZXI="}]}`), 0600))
+
+			registry := newCollectingACMEMetrics()
+			configurationChan := make(chan types.ConfigMessage, 1)
+			certificates := []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate")}}
+			provider := &Provider{
+				Configuration:     &Configuration{StorageDrift: &StorageDrift{Policy: test.policy}},
+				Store:             store,
+				certificates:      certificates,
+				certificateIndex:  newCertificateIndex(certificates),
+				configurationChan: configurationChan,
+				metricsRegistry:   registry,
+			}
+
+			provider.reconcileStorage()
+			// One certificate added and one removed
+			assert.Equal(t, float64(2), registry.drifts.CounterValue)
+
+			drift := provider.driftStatus.get()
+			require.NotNil(t, drift)
+			assert.Equal(t, test.policy, drift.Policy)
+			assert.True(t, drift.Drifted)
+			assert.Equal(t, []string{"other.wtf"}, drift.Added)
+			assert.Equal(t, []string{"traefik.wtf"}, drift.Removed)
+
+			require.Len(t, provider.certificates, 1)
+			assert.Equal(t, test.expectedMemory, provider.certificates[0].Domain.Main)
+			assert.Equal(t, provider.certificates[0], provider.GetCertificateForDomain(test.expectedMemory))
+
+			waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+				return len(storedData.Certificates) == 1 && storedData.Certificates[0].Domain.Main == test.expectedStored
+			})
+		})
+	}
+}
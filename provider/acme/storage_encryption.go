@@ -0,0 +1,480 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// storageEncryptionAlgorithm identifies the encryption of the ACME storage
+const storageEncryptionAlgorithm = "AES-256-GCM"
+
+const (
+	// storageEncryptionModeFull encrypts the whole serialized StoredData
+	storageEncryptionModeFull = "full"
+	// storageEncryptionModeKeys only encrypts the private keys, the rest of the StoredData stays readable
+	storageEncryptionModeKeys = "keys"
+)
+
+// StorageEncryption holds the data-encryption key used to encrypt the ACME storage at rest
+type StorageEncryption struct {
+	KeyID        string                 `description:"Identifier of the key, stored with the encrypted data. Default to a fingerprint of the key"`
+	KeyFile      string                 `description:"File holding the 32 bytes key, raw or base64 encoded"`
+	KeyEnv       string                 `description:"Environment variable holding the base64 encoded 32 bytes key"`
+	KMS          *StorageKMS            `description:"Generate the key with a KMS, and store it wrapped by the KMS key with the encrypted data"`
+	Mode         string                 `description:"Encrypt the whole storage, or only the private keys. Allow value 'full', 'keys'. Default to 'full'"`
+	PreviousKeys []StorageEncryptionKey `description:"Previous keys, only used to decrypt a storage encrypted before a key rotation"`
+}
+
+// StorageEncryptionKey holds a key used to decrypt the ACME storage
+type StorageEncryptionKey struct {
+	KeyID   string      `description:"Identifier of the key, stored with the encrypted data. Default to a fingerprint of the key"`
+	KeyFile string      `description:"File holding the 32 bytes key, raw or base64 encoded"`
+	KeyEnv  string      `description:"Environment variable holding the base64 encoded 32 bytes key"`
+	KMS     *StorageKMS `description:"KMS key wrapping the data key"`
+}
+
+func (e *StorageEncryption) getMode() string {
+	if len(e.Mode) == 0 {
+		return storageEncryptionModeFull
+	}
+	return e.Mode
+}
+
+func (e *StorageEncryption) checkMode() error {
+	switch e.Mode {
+	case "", storageEncryptionModeFull, storageEncryptionModeKeys:
+		return nil
+	default:
+		return fmt.Errorf("unsupported storage encryption mode %q, please select full or keys", e.Mode)
+	}
+}
+
+// primaryKey returns the key used to encrypt the storage
+func (e *StorageEncryption) primaryKey() *StorageEncryptionKey {
+	return &StorageEncryptionKey{KeyID: e.KeyID, KeyFile: e.KeyFile, KeyEnv: e.KeyEnv, KMS: e.KMS}
+}
+
+// getKeys returns all the keys able to decrypt the storage, the primary key first
+func (e *StorageEncryption) getKeys() []*StorageEncryptionKey {
+	keys := []*StorageEncryptionKey{e.primaryKey()}
+	for i := range e.PreviousKeys {
+		keys = append(keys, &e.PreviousKeys[i])
+	}
+	return keys
+}
+
+// keysEncryption tags a StoredData with encrypted private keys
+type keysEncryption struct {
+	Encryption string
+	KeyID      string
+	WrappedKey []byte `json:",omitempty"`
+}
+
+// encryptedField holds an encrypted private key
+type encryptedField struct {
+	Nonce []byte
+	Data  []byte
+}
+
+// encryptedStoredData is the envelope of the encrypted ACME storage
+type encryptedStoredData struct {
+	Encryption string
+	KeyID      string
+	WrappedKey []byte `json:",omitempty"`
+	Nonce      []byte
+	Data       []byte
+}
+
+type storageKey struct {
+	id         string
+	wrappedKey []byte
+	aead       cipher.AEAD
+}
+
+// loadStorageKey reads the data-encryption key from the configured file or environment variable
+func loadStorageKey(encryption *StorageEncryptionKey) (*storageKey, error) {
+	var raw []byte
+
+	switch {
+	case len(encryption.KeyFile) > 0:
+		content, err := ioutil.ReadFile(encryption.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the storage encryption key: %v", err)
+		}
+		raw = content
+	case len(encryption.KeyEnv) > 0:
+		value, ok := os.LookupEnv(encryption.KeyEnv)
+		if !ok {
+			return nil, fmt.Errorf("the environment variable %s holding the storage encryption key is not set", encryption.KeyEnv)
+		}
+		raw = []byte(value)
+	default:
+		return nil, errors.New("no storage encryption key configured, please set a key file or a key environment variable")
+	}
+
+	key, err := decodeStorageKey(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	id := encryption.KeyID
+	if len(id) == 0 {
+		fingerprint := sha256.Sum256(key)
+		id = hex.EncodeToString(fingerprint[:8])
+	}
+
+	return newStorageKey(id, key, nil)
+}
+
+func newStorageKey(id string, key []byte, wrappedKey []byte) (*storageKey, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the storage encryption key must be 32 bytes long, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storageKey{id: id, wrappedKey: wrappedKey, aead: aead}, nil
+}
+
+func decodeStorageKey(raw []byte) ([]byte, error) {
+	if len(raw) == 32 {
+		return raw, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("the storage encryption key is neither 32 bytes nor base64 encoded: %v", err)
+	}
+
+	return key, nil
+}
+
+// encrypt seals the serialized StoredData with a random nonce
+func (k *storageKey) encrypt(data []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&encryptedStoredData{
+		Encryption: storageEncryptionAlgorithm,
+		KeyID:      k.id,
+		WrappedKey: k.wrappedKey,
+		Nonce:      nonce,
+		Data:       k.aead.Seal(nil, nonce, data, []byte(k.id)),
+	})
+}
+
+// parseEncryptedStoredData returns the envelope of an encrypted storage, or nil for a legacy plaintext storage
+func parseEncryptedStoredData(content []byte) *encryptedStoredData {
+	envelope := &encryptedStoredData{}
+	if err := json.Unmarshal(content, envelope); err != nil || len(envelope.Encryption) == 0 {
+		return nil
+	}
+	return envelope
+}
+
+// decrypt returns the serialized StoredData of the envelope
+func (k *storageKey) decrypt(envelope *encryptedStoredData) ([]byte, error) {
+	if envelope.Encryption != storageEncryptionAlgorithm {
+		return nil, fmt.Errorf("decryption failed: unsupported encryption %q", envelope.Encryption)
+	}
+
+	if k == nil {
+		return nil, fmt.Errorf("decryption failed: the storage is encrypted with the key %q, but no storage encryption key is configured", envelope.KeyID)
+	}
+
+	if envelope.KeyID != k.id {
+		return nil, fmt.Errorf("decryption failed: the storage is encrypted with the key %q, not with the configured key %q", envelope.KeyID, k.id)
+	}
+
+	if len(envelope.Nonce) != k.aead.NonceSize() {
+		return nil, errors.New("decryption failed: invalid nonce")
+	}
+
+	data, err := k.aead.Open(nil, envelope.Nonce, envelope.Data, []byte(envelope.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %v", err)
+	}
+
+	return data, nil
+}
+
+// seal encrypts a private key with a random nonce
+func (k *storageKey) seal(data []byte) (*encryptedField, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return &encryptedField{Nonce: nonce, Data: k.aead.Seal(nil, nonce, data, []byte(k.id))}, nil
+}
+
+// open decrypts a private key
+func (k *storageKey) open(field *encryptedField) ([]byte, error) {
+	if len(field.Nonce) != k.aead.NonceSize() {
+		return nil, errors.New("decryption failed: invalid nonce")
+	}
+
+	data, err := k.aead.Open(nil, field.Nonce, field.Data, []byte(k.id))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %v", err)
+	}
+
+	return data, nil
+}
+
+// sealStoredDataKeys returns a copy of the StoredData with encrypted private keys
+func (k *storageKey) sealStoredDataKeys(storedData *StoredData) (*StoredData, error) {
+	sealedData := *storedData
+	sealedData.KeysEncryption = &keysEncryption{Encryption: storageEncryptionAlgorithm, KeyID: k.id, WrappedKey: k.wrappedKey}
+
+	if storedData.Account != nil {
+		account, err := storedData.Account.sealPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		sealedData.Account = account
+	}
+
+	if storedData.StagingAccount != nil {
+		account, err := storedData.StagingAccount.sealPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		sealedData.StagingAccount = account
+	}
+
+	if storedData.Canary != nil {
+		canary, err := storedData.Canary.sealValue(k)
+		if err != nil {
+			return nil, err
+		}
+		sealedData.Canary = canary
+	}
+
+	if storedData.DefaultCertificate != nil {
+		defaultCertificate, err := storedData.DefaultCertificate.sealKey(k)
+		if err != nil {
+			return nil, err
+		}
+		sealedData.DefaultCertificate = defaultCertificate
+	}
+
+	sealedData.Certificates = make([]*Certificate, 0, len(storedData.Certificates))
+	for _, certificate := range storedData.Certificates {
+		sealedCertificate, err := certificate.sealKey(k)
+		if err != nil {
+			return nil, err
+		}
+		sealedData.Certificates = append(sealedData.Certificates, sealedCertificate)
+	}
+
+	if storedData.TLSChallenges != nil {
+		sealedData.TLSChallenges = make(map[string]*Certificate, len(storedData.TLSChallenges))
+		for domain, certificate := range storedData.TLSChallenges {
+			sealedCertificate, err := certificate.sealKey(k)
+			if err != nil {
+				return nil, err
+			}
+			sealedData.TLSChallenges[domain] = sealedCertificate
+		}
+	}
+
+	return &sealedData, nil
+}
+
+// openStoredDataKeys decrypts the private keys of the StoredData in place.
+// The boolean is true when some private keys were stored in plaintext.
+func (k *storageKey) openStoredDataKeys(storedData *StoredData) (bool, error) {
+	header := storedData.KeysEncryption
+
+	if header.Encryption != storageEncryptionAlgorithm {
+		return false, fmt.Errorf("decryption failed: unsupported encryption %q", header.Encryption)
+	}
+
+	if k == nil {
+		return false, fmt.Errorf("decryption failed: the private keys are encrypted with the key %q, but no storage encryption key is configured", header.KeyID)
+	}
+
+	if header.KeyID != k.id {
+		return false, fmt.Errorf("decryption failed: the private keys are encrypted with the key %q, not with the configured key %q", header.KeyID, k.id)
+	}
+
+	plaintext := false
+
+	if storedData.Account != nil {
+		plaintext = plaintext || (storedData.Account.EncryptedPrivateKey == nil && len(storedData.Account.PrivateKey) > 0)
+		if err := storedData.Account.openPrivateKey(k); err != nil {
+			return false, err
+		}
+	}
+
+	if storedData.StagingAccount != nil {
+		plaintext = plaintext || (storedData.StagingAccount.EncryptedPrivateKey == nil && len(storedData.StagingAccount.PrivateKey) > 0)
+		if err := storedData.StagingAccount.openPrivateKey(k); err != nil {
+			return false, err
+		}
+	}
+
+	// The canary is not a private key, a plaintext canary is reported by its check
+	if storedData.Canary != nil {
+		if err := storedData.Canary.openValue(k); err != nil {
+			return false, err
+		}
+	}
+
+	if storedData.DefaultCertificate != nil {
+		plaintext = plaintext || (storedData.DefaultCertificate.EncryptedKey == nil && len(storedData.DefaultCertificate.Key) > 0)
+		if err := storedData.DefaultCertificate.openKey(k); err != nil {
+			return false, err
+		}
+	}
+
+	for _, certificate := range storedData.Certificates {
+		plaintext = plaintext || (certificate.EncryptedKey == nil && len(certificate.Key) > 0)
+		if err := certificate.openKey(k); err != nil {
+			return false, err
+		}
+	}
+
+	for _, certificate := range storedData.TLSChallenges {
+		plaintext = plaintext || (certificate.EncryptedKey == nil && len(certificate.Key) > 0)
+		if err := certificate.openKey(k); err != nil {
+			return false, err
+		}
+	}
+
+	storedData.KeysEncryption = nil
+	return plaintext, nil
+}
+
+// sealPrivateKey returns a copy of the account with an encrypted private key
+func (a *Account) sealPrivateKey(k *storageKey) (*Account, error) {
+	account := *a
+	if len(a.PrivateKey) == 0 {
+		return &account, nil
+	}
+
+	field, err := k.seal(a.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	account.PrivateKey = nil
+	account.EncryptedPrivateKey = field
+	return &account, nil
+}
+
+// openPrivateKey decrypts the private key of the account, a plaintext private key is kept as is
+func (a *Account) openPrivateKey(k *storageKey) error {
+	if a.EncryptedPrivateKey == nil {
+		return nil
+	}
+
+	privateKey, err := k.open(a.EncryptedPrivateKey)
+	if err != nil {
+		return err
+	}
+
+	a.PrivateKey = privateKey
+	a.EncryptedPrivateKey = nil
+	return nil
+}
+
+// sealKey returns a copy of the certificate with an encrypted private key
+func (c *Certificate) sealKey(k *storageKey) (*Certificate, error) {
+	certificate := *c
+	if len(c.Key) == 0 {
+		return &certificate, nil
+	}
+
+	field, err := k.seal(c.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate.Key = nil
+	certificate.EncryptedKey = field
+	return &certificate, nil
+}
+
+// openKey decrypts the private key of the certificate, a plaintext private key is kept as is
+func (c *Certificate) openKey(k *storageKey) error {
+	if c.EncryptedKey == nil {
+		return nil
+	}
+
+	key, err := k.open(c.EncryptedKey)
+	if err != nil {
+		return err
+	}
+
+	c.Key = key
+	c.EncryptedKey = nil
+	return nil
+}
+
+// sealValue returns a copy of the canary with an encrypted value
+func (c *StorageCanaryEntry) sealValue(k *storageKey) (*StorageCanaryEntry, error) {
+	canary := *c
+	if len(c.Value) == 0 {
+		return &canary, nil
+	}
+
+	field, err := k.seal(c.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	canary.Value = nil
+	canary.EncryptedValue = field
+	return &canary, nil
+}
+
+// openValue decrypts the value of the canary, a plaintext value is kept as is
+func (c *StorageCanaryEntry) openValue(k *storageKey) error {
+	if c.EncryptedValue == nil {
+		return nil
+	}
+
+	value, err := k.open(c.EncryptedValue)
+	if err != nil {
+		return err
+	}
+
+	c.Value = value
+	c.EncryptedValue = nil
+	return nil
+}
+
+// reportStorageRewrap logs the items of the storage re-encrypted with the primary key
+func reportStorageRewrap(storedData *StoredData, previousKeyID string) {
+	if storedData.Account != nil {
+		logger().WithField(logFieldOperation, storeOperationLoad).Infof("Re-encrypt the ACME account %q, previously encrypted with the key %q.", storedData.Account.Email, previousKeyID)
+	}
+
+	for _, certificate := range storedData.Certificates {
+		domainsLogger(certificate.Domain.ToStrArray()).WithField(logFieldOperation, storeOperationLoad).Infof("Re-encrypt the ACME certificate for domains %q, previously encrypted with the key %q.", strings.Join(certificate.Domain.ToStrArray(), ","), previousKeyID)
+	}
+}
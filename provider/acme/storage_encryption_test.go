@@ -0,0 +1,535 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestStorageKey(t *testing.T, dir, name string, key []byte) *StorageEncryption {
+	keyFile := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0600))
+	return &StorageEncryption{KeyFile: keyFile}
+}
+
+// waitForStorage waits for the storage file to be written with the given content
+func waitForStorage(t *testing.T, filename string, contains string) []byte {
+	var data []byte
+	for i := 0; i < 500; i++ {
+		time.Sleep(10 * time.Millisecond)
+		content, err := ioutil.ReadFile(filename)
+		if err == nil && bytes.Contains(content, []byte(contains)) {
+			data = content
+			break
+		}
+	}
+	require.NotEmpty(t, data, "the storage has not been written")
+	return data
+}
+
+func TestLocalStoreEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	encryption := writeTestStorageKey(t, dir, "acme.key", bytes.Repeat([]byte{1}, 32))
+	filename := filepath.Join(dir, "acme.json")
+
+	store := NewLocalStore(filename)
+	store.Encryption = encryption
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("secret-key")}}))
+
+	data := waitForStorage(t, filename, storageEncryptionAlgorithm)
+	assert.NotContains(t, string(data), "traefik.wtf")
+	assert.NotContains(t, string(data), base64.StdEncoding.EncodeToString([]byte("secret-key")))
+
+	envelope := &encryptedStoredData{}
+	require.NoError(t, json.Unmarshal(data, envelope))
+	assert.NotEmpty(t, envelope.KeyID)
+	assert.Len(t, envelope.Nonce, 12)
+
+	reloaded := NewLocalStore(filename)
+	reloaded.Encryption = encryption
+	certificates, err := reloaded.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, "traefik.wtf", certificates[0].Domain.Main)
+	assert.Equal(t, []byte("secret-key"), certificates[0].Key)
+
+	wrongKey := NewLocalStore(filename)
+	wrongKey.Encryption = writeTestStorageKey(t, dir, "wrong.key", bytes.Repeat([]byte{2}, 32))
+	_, err = wrongKey.GetCertificates(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decryption failed")
+
+	_, err = wrongKey.GetCertificates(context.Background())
+	require.Error(t, err, "a failed decryption must never leave an empty store")
+
+	sameIDWrongKey := NewLocalStore(filename)
+	sameIDWrongKey.Encryption = writeTestStorageKey(t, dir, "wrong.key", bytes.Repeat([]byte{2}, 32))
+	sameIDWrongKey.Encryption.KeyID = envelope.KeyID
+	_, err = sameIDWrongKey.GetCertificates(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decryption failed")
+
+	noKey := NewLocalStore(filename)
+	_, err = noKey.GetCertificates(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decryption failed")
+}
+
+func TestLocalStoreEncryptionPlaintextStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	err = ioutil.WriteFile(filename, []byte(`{"Certificates":[{"Domain":{"Main":"traefik.wtf"},"Certificate":"Y2VydA==","Key":"a2V5"}]}`), 0600)
+	require.NoError(t, err)
+
+	store := NewLocalStore(filename)
+	store.Encryption = writeTestStorageKey(t, dir, "acme.key", bytes.Repeat([]byte{1}, 32))
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, "traefik.wtf", certificates[0].Domain.Main)
+
+	data := waitForStorage(t, filename, storageEncryptionAlgorithm)
+	assert.NotContains(t, string(data), "traefik.wtf", "the plaintext storage must be encrypted")
+}
+
+func TestLoadStorageKey(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rawKeyFile := filepath.Join(dir, "raw.key")
+	require.NoError(t, ioutil.WriteFile(rawKeyFile, key, 0600))
+
+	base64KeyFile := filepath.Join(dir, "base64.key")
+	require.NoError(t, ioutil.WriteFile(base64KeyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600))
+
+	shortKeyFile := filepath.Join(dir, "short.key")
+	require.NoError(t, ioutil.WriteFile(shortKeyFile, []byte(base64.StdEncoding.EncodeToString(key[:16])), 0600))
+
+	require.NoError(t, os.Setenv("TEST_ACME_STORAGE_KEY", base64.StdEncoding.EncodeToString(key)))
+	defer os.Unsetenv("TEST_ACME_STORAGE_KEY")
+
+	testCases := []struct {
+		desc        string
+		encryption  *StorageEncryptionKey
+		expectedID  string
+		expectedErr bool
+	}{
+		{
+			desc:       "raw key file",
+			encryption: &StorageEncryptionKey{KeyFile: rawKeyFile},
+		},
+		{
+			desc:       "base64 key file",
+			encryption: &StorageEncryptionKey{KeyFile: base64KeyFile},
+		},
+		{
+			desc:       "environment variable",
+			encryption: &StorageEncryptionKey{KeyEnv: "TEST_ACME_STORAGE_KEY"},
+		},
+		{
+			desc:       "key ID",
+			encryption: &StorageEncryptionKey{KeyEnv: "TEST_ACME_STORAGE_KEY", KeyID: "2019-01"},
+			expectedID: "2019-01",
+		},
+		{
+			desc:        "key too short",
+			encryption:  &StorageEncryptionKey{KeyFile: shortKeyFile},
+			expectedErr: true,
+		},
+		{
+			desc:        "missing key file",
+			encryption:  &StorageEncryptionKey{KeyFile: filepath.Join(dir, "missing.key")},
+			expectedErr: true,
+		},
+		{
+			desc:        "missing environment variable",
+			encryption:  &StorageEncryptionKey{KeyEnv: "TEST_ACME_STORAGE_KEY_MISSING"},
+			expectedErr: true,
+		},
+		{
+			desc:        "no key",
+			encryption:  &StorageEncryptionKey{},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			storageKey, err := loadStorageKey(test.encryption)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			if len(test.expectedID) > 0 {
+				assert.Equal(t, test.expectedID, storageKey.id)
+			} else {
+				assert.Equal(t, "72cd6e8422c407fb", storageKey.id)
+			}
+		})
+	}
+}
+
+type fakeKMSProvider struct {
+	lock      sync.Mutex
+	failures  int
+	generated int
+	decrypted int
+}
+
+func (f *fakeKMSProvider) fail() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.failures != 0 {
+		f.failures--
+		return errors.New("KMS unavailable")
+	}
+	return nil
+}
+
+// wrap is a reversible stand-in for the KMS encryption
+func wrap(key []byte) []byte {
+	wrapped := make([]byte, len(key))
+	for i, b := range key {
+		wrapped[i] = b ^ 0xff
+	}
+	return wrapped
+}
+
+func (f *fakeKMSProvider) GenerateDataKey(_ context.Context) ([]byte, []byte, error) {
+	if err := f.fail(); err != nil {
+		return nil, nil, err
+	}
+	f.generated++
+
+	key := bytes.Repeat([]byte{byte(f.generated)}, 32)
+	return key, wrap(key), nil
+}
+
+func (f *fakeKMSProvider) Decrypt(_ context.Context, wrapped []byte) ([]byte, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	f.decrypted++
+
+	return wrap(wrapped), nil
+}
+
+func TestLocalStoreKMSEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	encryption := &StorageEncryption{KMS: &StorageKMS{Provider: kmsProviderAWS, Key: "arn:aws:kms:eu-west-1:123456789012:key/traefik"}}
+
+	kms := &fakeKMSProvider{}
+	store := NewLocalStore(filename)
+	store.Encryption = encryption
+	store.kmsProviders = map[string]KMSProvider{kmsProviderAWS: kms}
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+
+	data := waitForStorage(t, filename, storageEncryptionAlgorithm)
+	envelope := parseEncryptedStoredData(data)
+	require.NotNil(t, envelope)
+	assert.Equal(t, encryption.KMS.Key, envelope.KeyID)
+	assert.Equal(t, wrap(bytes.Repeat([]byte{1}, 32)), envelope.WrappedKey)
+	assert.Equal(t, 1, kms.generated)
+
+	// The KMS is unavailable once, the load is retried
+	kms = &fakeKMSProvider{failures: 1}
+	reloaded := NewLocalStore(filename)
+	reloaded.Encryption = encryption
+	reloaded.kmsProviders = map[string]KMSProvider{kmsProviderAWS: kms}
+	certificates, err := reloaded.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, "traefik.wtf", certificates[0].Domain.Main)
+	assert.Equal(t, 1, kms.decrypted)
+	assert.Equal(t, 0, kms.generated, "the data key must be unwrapped, not generated")
+}
+
+func TestLocalStoreKMSOutage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	encryption := &StorageEncryption{KMS: &StorageKMS{Provider: kmsProviderGCP, Key: "projects/traefik/locations/global/keyRings/acme/cryptoKeys/storage"}}
+
+	store := NewLocalStore(filename)
+	store.Encryption = encryption
+	store.kmsProviders = map[string]KMSProvider{kmsProviderGCP: &fakeKMSProvider{}}
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+	waitForStorage(t, filename, storageEncryptionAlgorithm)
+
+	defer func(maxElapsedTime time.Duration) { kmsRetryMaxElapsedTime = maxElapsedTime }(kmsRetryMaxElapsedTime)
+	kmsRetryMaxElapsedTime = 100 * time.Millisecond
+
+	unavailable := NewLocalStore(filename)
+	unavailable.Encryption = encryption
+	unavailable.kmsProviders = map[string]KMSProvider{kmsProviderGCP: &fakeKMSProvider{failures: -1}}
+	_, err = unavailable.GetCertificates(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decryption failed")
+
+	_, err = unavailable.GetAccount(context.Background())
+	require.Error(t, err, "a KMS outage must never leave an empty store")
+}
+
+func TestNewKMSProvider(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config *StorageKMS
+	}{
+		{
+			desc:   "unsupported provider",
+			config: &StorageKMS{Provider: "vault", Key: "traefik"},
+		},
+		{
+			desc:   "missing key",
+			config: &StorageKMS{Provider: kmsProviderAWS},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newKMSProvider(test.config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLocalStoreKeysEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	encryption := writeTestStorageKey(t, dir, "acme.key", bytes.Repeat([]byte{1}, 32))
+	encryption.Mode = storageEncryptionModeKeys
+	filename := filepath.Join(dir, "acme.json")
+
+	store := NewLocalStore(filename)
+	store.Encryption = encryption
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf", PrivateKey: []byte("account-private-key"), PrivateKeyType: "RSA4096"}))
+	require.NoError(t, store.Update(context.Background(), func(data *StoredData) error {
+		data.StagingAccount = &Account{Email: "test@traefik.wtf", PrivateKey: []byte("staging-account-private-key"), PrivateKeyType: "RSA4096"}
+		data.DefaultCertificate = &DefaultCertificate{Certificate: []byte("default-cert"), Key: []byte("default-certificate-private-key")}
+		return nil
+	}))
+	require.NoError(t, store.AddTLSChallenge(context.Background(), "traefik.wtf", &Certificate{Domain: types.Domain{Main: "TEMP-traefik.wtf"}, Certificate: []byte("challenge-cert"), Key: []byte("challenge-private-key")}))
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("public-cert"), Key: []byte("certificate-private-key")}}))
+
+	data := waitForStorage(t, filename, base64.StdEncoding.EncodeToString([]byte("public-cert")))
+	assert.Contains(t, string(data), "traefik.wtf", "the metadata must stay readable")
+	assert.Contains(t, string(data), "test@traefik.wtf", "the metadata must stay readable")
+	for _, privateKey := range []string{"account-private-key", "staging-account-private-key", "challenge-private-key", "certificate-private-key", "default-certificate-private-key"} {
+		assert.NotContains(t, string(data), base64.StdEncoding.EncodeToString([]byte(privateKey)))
+	}
+	assert.Nil(t, parseEncryptedStoredData(data), "the storage must not be fully encrypted")
+
+	reloaded := NewLocalStore(filename)
+	reloaded.Encryption = encryption
+	account, err := reloaded.GetAccount(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("account-private-key"), account.PrivateKey)
+	assert.Nil(t, account.EncryptedPrivateKey)
+
+	stagingAccount, err := reloaded.GetStagingAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, stagingAccount)
+	assert.Equal(t, []byte("staging-account-private-key"), stagingAccount.PrivateKey)
+
+	defaultCertificate, err := reloaded.GetDefaultCertificate(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, defaultCertificate)
+	assert.Equal(t, []byte("default-certificate-private-key"), defaultCertificate.Key)
+	assert.Nil(t, defaultCertificate.EncryptedKey)
+
+	certificates, err := reloaded.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, []byte("certificate-private-key"), certificates[0].Key)
+	assert.Nil(t, certificates[0].EncryptedKey)
+
+	wrongKey := NewLocalStore(filename)
+	wrongKey.Encryption = writeTestStorageKey(t, dir, "wrong.key", bytes.Repeat([]byte{2}, 32))
+	wrongKey.Encryption.Mode = storageEncryptionModeKeys
+	_, err = wrongKey.GetCertificates(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decryption failed")
+
+	noKey := NewLocalStore(filename)
+	_, err = noKey.GetCertificates(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decryption failed")
+}
+
+func TestLocalStoreEncryptionModeSwitch(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		storage  string
+		fromMode string
+		toMode   string
+	}{
+		{
+			desc:    "plaintext to keys",
+			storage: `{"Account":{"Email":"test@traefik.wtf","PrivateKey":"a2V5","PrivateKeyType":"RSA4096"},"Certificates":[{"Domain":{"Main":"traefik.wtf"},"Certificate":"Y2VydA==","Key":"a2V5"}]}`,
+			toMode:  storageEncryptionModeKeys,
+		},
+		{
+			desc: "mixed keys",
+			storage: `{"KeysEncryption":{"Encryption":"AES-256-GCM","KeyID":"72cd6e8422c407fb"},` +
+				`"Account":{"Email":"test@traefik.wtf","PrivateKey":"a2V5","PrivateKeyType":"RSA4096"},"Certificates":[{"Domain":{"Main":"traefik.wtf"},"Certificate":"Y2VydA==","Key":"a2V5"}]}`,
+			toMode: storageEncryptionModeKeys,
+		},
+		{
+			desc:     "full to keys",
+			fromMode: storageEncryptionModeFull,
+			toMode:   storageEncryptionModeKeys,
+		},
+		{
+			desc:     "keys to full",
+			fromMode: storageEncryptionModeKeys,
+			toMode:   storageEncryptionModeFull,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			filename := filepath.Join(dir, "acme.json")
+			if len(test.fromMode) > 0 {
+				store := NewLocalStore(filename)
+				store.Encryption = writeTestStorageKey(t, dir, "acme.key", bytes.Repeat([]byte{1}, 32))
+				store.Encryption.Mode = test.fromMode
+				require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf", PrivateKey: []byte("key"), PrivateKeyType: "RSA4096"}))
+				require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+				if test.fromMode == storageEncryptionModeFull {
+					waitForStorage(t, filename, storageEncryptionAlgorithm)
+				} else {
+					waitForStorage(t, filename, "traefik.wtf")
+				}
+			} else {
+				require.NoError(t, ioutil.WriteFile(filename, []byte(test.storage), 0600))
+			}
+
+			store := NewLocalStore(filename)
+			store.Encryption = writeTestStorageKey(t, dir, "acme.key", bytes.Repeat([]byte{1}, 32))
+			store.Encryption.Mode = test.toMode
+
+			account, err := store.GetAccount(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, []byte("key"), account.PrivateKey)
+
+			certificates, err := store.GetCertificates(context.Background())
+			require.NoError(t, err)
+			require.Len(t, certificates, 1)
+			assert.Equal(t, []byte("key"), certificates[0].Key)
+
+			if test.toMode == storageEncryptionModeFull {
+				waitForStorage(t, filename, storageEncryptionAlgorithm)
+				return
+			}
+
+			data := waitForStorage(t, filename, "EncryptedKey")
+			assert.NotContains(t, string(data), `"a2V5"`)
+		})
+	}
+}
+
+func TestLocalStoreEncryptionKeyRotation(t *testing.T) {
+	testCases := []struct {
+		desc string
+		mode string
+	}{
+		{
+			desc: "full",
+			mode: storageEncryptionModeFull,
+		},
+		{
+			desc: "keys",
+			mode: storageEncryptionModeKeys,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			filename := filepath.Join(dir, "acme.json")
+			oldKey := writeTestStorageKey(t, dir, "old.key", bytes.Repeat([]byte{1}, 32))
+			oldKey.KeyID = "old"
+			oldKey.Mode = test.mode
+			newKey := writeTestStorageKey(t, dir, "new.key", bytes.Repeat([]byte{2}, 32))
+			newKey.KeyID = "new"
+			newKey.Mode = test.mode
+
+			store := NewLocalStore(filename)
+			store.Encryption = oldKey
+			require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+			waitForStorage(t, filename, `"old"`)
+
+			// The previous key is removed while the storage is still encrypted with it
+			removed := NewLocalStore(filename)
+			removed.Encryption = newKey
+			_, err = removed.GetCertificates(context.Background())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), `"old"`)
+
+			rotated := NewLocalStore(filename)
+			rotated.Encryption = &StorageEncryption{
+				KeyID:        newKey.KeyID,
+				KeyFile:      newKey.KeyFile,
+				Mode:         test.mode,
+				PreviousKeys: []StorageEncryptionKey{{KeyID: oldKey.KeyID, KeyFile: oldKey.KeyFile}},
+			}
+			certificates, err := rotated.GetCertificates(context.Background())
+			require.NoError(t, err)
+			require.Len(t, certificates, 1)
+			assert.Equal(t, []byte("key"), certificates[0].Key)
+
+			// The storage is re-encrypted with the primary key
+			waitForStorage(t, filename, `"new"`)
+
+			reloaded := NewLocalStore(filename)
+			reloaded.Encryption = newKey
+			certificates, err = reloaded.GetCertificates(context.Background())
+			require.NoError(t, err)
+			require.Len(t, certificates, 1)
+			assert.Equal(t, []byte("key"), certificates[0].Key)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// The behaviors when an obtained certificate can not be persisted
+const (
+	// StorageFailModeServeStale serves the obtained certificates, persisted or not
+	StorageFailModeServeStale = "serveStale"
+	// StorageFailModeStrict only serves the obtained certificates once persisted
+	StorageFailModeStrict = "strict"
+)
+
+// storagePersistTimeout bounds the wait for the write of the data saved, as an obtained certificate in the strict mode
+const storagePersistTimeout = 30 * time.Second
+
+// storageRecoveryInterval is the interval between two checks of the storage, until the issuances which could not be persisted are retried
+var storageRecoveryInterval = 30 * time.Second
+
+// errWriteSuspended is the result of the saves kept in memory while the writes are suspended by the circuit breaker
+var errWriteSuspended = errors.New("the writes of the storage are suspended")
+
+// persistStore is implemented by the stores able to wait for the write of the data saved
+type persistStore interface {
+	WaitPersisted(ctx context.Context) error
+	Persist(ctx context.Context) error
+}
+
+func checkStorageFailMode(mode string) error {
+	switch mode {
+	case "", StorageFailModeServeStale, StorageFailModeStrict:
+		return nil
+	default:
+		return fmt.Errorf("unknown fail mode %q of the ACME storage, expected %s or %s", mode, StorageFailModeServeStale, StorageFailModeStrict)
+	}
+}
+
+func (p *Provider) getStorageFailMode() string {
+	if len(p.StorageFailMode) == 0 {
+		return StorageFailModeServeStale
+	}
+	return p.StorageFailMode
+}
+
+// WaitPersisted waits until the saves handed over to the save loop are written, and returns the result of the last write.
+// The save loop answers once it is done with the saves handed over before.
+func (s *LocalStore) WaitPersisted(ctx context.Context) error {
+	result := make(chan error, 1)
+
+	select {
+	case s.persists <- result:
+	case <-s.closing:
+		return errors.New("the ACME storage is closed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Persist saves the data in memory, including the changes of the challenges only written by the next save, and waits for its write
+func (s *LocalStore) Persist(ctx context.Context) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.save(storedData)
+	s.flush()
+	return s.WaitPersisted(ctx)
+}
+
+// waitObtainedCertificatePersisted waits for the write of the obtained certificate in the strict mode
+func (p *Provider) waitObtainedCertificatePersisted() error {
+	if p.getStorageFailMode() != StorageFailModeStrict {
+		return nil
+	}
+
+	if err := p.waitStorePersisted(p.getContext()); err != nil {
+		return fmt.Errorf("the certificate is not persisted: %v", err)
+	}
+	return nil
+}
+
+// waitStorePersisted waits for the write of the data saved in the store, when the store can tell
+func (p *Provider) waitStorePersisted(ctx context.Context) error {
+	store, ok := unwrapStore(p.Store).(persistStore)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storagePersistTimeout)
+	defer cancel()
+
+	return store.WaitPersisted(ctx)
+}
+
+// persistStorage saves the data of the store and waits for its write, when the store can tell
+func (p *Provider) persistStorage(ctx context.Context) error {
+	store, ok := unwrapStore(p.Store).(persistStore)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storagePersistTimeout)
+	defer cancel()
+
+	return store.Persist(ctx)
+}
+
+// withdrawUnpersistedCertificate restores the certificate served before the issuance which could not be persisted,
+// none for a new certificate, and retries the issuance once the storage recovers.
+// It runs in the routine watching the certificates, which owns the certificates in memory.
+func (p *Provider) withdrawUnpersistedCertificate(cert, previous *Certificate) {
+	for i, certificate := range p.certificates {
+		if !reflect.DeepEqual(certificate.Domain, cert.Domain) {
+			continue
+		}
+
+		p.certificateIndex.remove(certificate)
+		if previous == nil {
+			p.certificates = append(p.certificates[:i], p.certificates[i+1:]...)
+		} else {
+			p.certificates[i] = previous
+			p.certificateIndex.add(previous)
+		}
+		break
+	}
+
+	domainsLogger(cert.Domain.ToStrArray()).WithField(logFieldOperation, storeOperationSave).Warnf("The certificate for domains %v is not served as it is not persisted, its issuance is retried once the ACME storage recovers.", cert.Domain.ToStrArray())
+
+	// The certificates served are saved, to be written once the storage recovers instead of the withdrawn one
+	if err := p.saveCertificates(); err != nil {
+		logger().WithField(logFieldOperation, storeOperationSave).Debugf("Unable to save the ACME certificates without the withdrawn one: %v", err)
+	}
+
+	p.retryUnpersistedIssuances()
+}
+
+// retryUnpersistedIssuances checks the storage until it recovers, then obtains the missing certificates
+// and renews the certificates due again
+func (p *Provider) retryUnpersistedIssuances() {
+	if !atomic.CompareAndSwapInt32(&p.unpersistedIssuances, 0, 1) {
+		return
+	}
+
+	p.pool.Go(func(stop chan bool) {
+		ticker := time.NewTicker(storageRecoveryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			health := p.GetStorageHealth(p.getContext())
+			if health != nil && (!health.Healthy || health.Degraded || health.OldestUnpersistedChange > 0) {
+				continue
+			}
+
+			atomic.StoreInt32(&p.unpersistedIssuances, 0)
+			logger().Info("The ACME storage recovered, retrying the issuances which could not be persisted.")
+
+			p.resolveDomains()
+			p.resumeDesiredDomains()
+			p.renewCertificates()
+			return
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStorageFailMode(t *testing.T) {
+	assert.NoError(t, checkStorageFailMode(""))
+	assert.NoError(t, checkStorageFailMode(StorageFailModeServeStale))
+	assert.NoError(t, checkStorageFailMode(StorageFailModeStrict))
+	assert.Error(t, checkStorageFailMode("lenient"))
+}
+
+func TestLocalStoreWaitPersisted(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	failing := int32(1)
+	store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("backend unavailable")
+		}
+		return ioutil.WriteFile(filename, data, perm)
+	}
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	assert.EqualError(t, store.WaitPersisted(context.Background()), "backend unavailable")
+
+	atomic.StoreInt32(&failing, 0)
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	assert.NoError(t, store.WaitPersisted(context.Background()))
+}
+
+func TestSaveObtainedCertificateFailMode(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		failMode string
+		served   bool
+	}{
+		{
+			desc:   "serve stale",
+			served: true,
+		},
+		{
+			desc:     "strict",
+			failMode: StorageFailModeStrict,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			store, clean := newTestLocalStore(t)
+			defer clean()
+
+			store.writeFile = func(string, []byte, os.FileMode) error {
+				return errors.New("backend unavailable")
+			}
+
+			cert := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate"), Key: []byte("key")}
+			configurationChan := make(chan types.ConfigMessage, 1)
+			provider := &Provider{
+				Configuration:     &Configuration{StorageFailMode: test.failMode},
+				Store:             store,
+				certificates:      []*Certificate{cert},
+				configurationChan: configurationChan,
+			}
+
+			err := provider.saveObtainedCertificate(cert)
+			if test.served {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+			assert.Equal(t, test.served, len(configurationChan) == 1)
+		})
+	}
+}
+
+func TestWithdrawUnpersistedCertificate(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	renewed := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("renewed"), Key: []byte("key")}
+	obtained := &Certificate{Domain: types.Domain{Main: "acme.wtf"}, Certificate: []byte("obtained"), Key: []byte("key")}
+	previous := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("previous"), Key: []byte("key")}
+
+	configurationChan := make(chan types.ConfigMessage, 2)
+	provider := &Provider{
+		Configuration:        &Configuration{StorageFailMode: StorageFailModeStrict},
+		Store:                store,
+		certificates:         []*Certificate{renewed, obtained},
+		certificateIndex:     newCertificateIndex([]*Certificate{renewed, obtained}),
+		configurationChan:    configurationChan,
+		unpersistedIssuances: 1,
+	}
+
+	provider.withdrawUnpersistedCertificate(renewed, previous)
+	provider.withdrawUnpersistedCertificate(obtained, nil)
+
+	require.Len(t, provider.certificates, 1)
+	assert.Equal(t, []byte("previous"), provider.certificates[0].Certificate)
+
+	<-configurationChan
+	config := <-configurationChan
+	require.Len(t, config.Configuration.TLS, 1)
+	assert.Equal(t, "previous", string(config.Configuration.TLS[0].Certificate.CertFile))
+}
@@ -0,0 +1,252 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// storeHealthTimeout bounds the health probes of the store
+const storeHealthTimeout = 5 * time.Second
+
+// healthStore is implemented by the stores reporting their health
+type healthStore interface {
+	GetHealth() *StoreHealth
+}
+
+// StoreHealth is the health of the ACME storage
+type StoreHealth struct {
+	Backend  string                `json:"backend"`
+	Healthy  bool                  `json:"healthy"`
+	Reason   string                `json:"reason,omitempty"`
+	LastLoad *StoreOperationResult `json:"lastLoad,omitempty"`
+	LastSave *StoreOperationResult `json:"lastSave,omitempty"`
+	// LastProbe is the result of the last check that the storage can be reached and written
+	LastProbe *StoreOperationResult `json:"lastProbe,omitempty"`
+	// OldestUnpersistedChange is the age in seconds of the oldest change not saved yet
+	OldestUnpersistedChange float64    `json:"oldestUnpersistedChange"`
+	UnhealthySince          *time.Time `json:"unhealthySince,omitempty"`
+	// Degraded is whether the writes are suspended by the circuit breaker, the data being kept in memory
+	Degraded bool `json:"degraded"`
+}
+
+// StoreOperationResult is the result of the last load or save of the ACME storage
+type StoreOperationResult struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// storeHealthTracker records the results of the loads and the saves of a store
+type storeHealthTracker struct {
+	lock           sync.RWMutex
+	lastLoad       *StoreOperationResult
+	lastSave       *StoreOperationResult
+	lastProbe      *StoreOperationResult
+	probeFailed    bool
+	pendingSince   time.Time
+	unhealthySince time.Time
+	reason         string
+	degraded       bool
+
+	lastSuccessfulLoad time.Time
+	lastSuccessfulSave time.Time
+	lastError          string
+	payloadSize        int
+}
+
+func (h *storeHealthTracker) loaded(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.lastLoad = newStoreOperationResult(err)
+	if err == nil {
+		h.lastSuccessfulLoad = h.lastLoad.Time
+	}
+	h.update("load", err)
+}
+
+// changed records a change to save, the oldest one is kept until the storage is saved
+func (h *storeHealthTracker) changed() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.pendingSince.IsZero() {
+		h.pendingSince = time.Now()
+	}
+}
+
+func (h *storeHealthTracker) saved(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.lastSave = newStoreOperationResult(err)
+	if err == nil {
+		h.pendingSince = time.Time{}
+		h.lastSuccessfulSave = h.lastSave.Time
+	}
+	h.update("save", err)
+}
+
+// probed records the result of a health probe. A failed probe does not hide a failed load or save,
+// and a successful probe only fixes a failed probe.
+func (h *storeHealthTracker) probed(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.lastProbe = newStoreOperationResult(err)
+
+	switch {
+	case err != nil && (h.unhealthySince.IsZero() || h.probeFailed):
+		h.update(storeOperationProbe, err)
+		h.probeFailed = true
+	case err == nil && h.probeFailed:
+		h.reason = ""
+		h.unhealthySince = time.Time{}
+		h.probeFailed = false
+	}
+}
+
+func (h *storeHealthTracker) update(operation string, err error) {
+	h.probeFailed = false
+
+	switch {
+	case err != nil:
+		h.reason = fmt.Sprintf("unable to %s: %v", operation, err)
+		h.lastError = h.reason
+		if h.unhealthySince.IsZero() {
+			h.unhealthySince = time.Now()
+		}
+	case h.isLoadFailed() && operation == "save":
+		// A save does not fix a failed load
+	default:
+		h.reason = ""
+		h.unhealthySince = time.Time{}
+	}
+}
+
+// setDegraded records whether the writes are suspended by the circuit breaker
+func (h *storeHealthTracker) setDegraded(degraded bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.degraded = degraded
+}
+
+// setPayloadSize records the size of the storage last loaded or saved
+func (h *storeHealthTracker) setPayloadSize(size int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.payloadSize = size
+}
+
+func (h *storeHealthTracker) isLoadFailed() bool {
+	return h.lastLoad != nil && len(h.lastLoad.Error) > 0
+}
+
+func (h *storeHealthTracker) getHealth(backend, name string) *StoreHealth {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	health := &StoreHealth{
+		Backend:   backend,
+		Healthy:   h.unhealthySince.IsZero(),
+		LastLoad:  h.lastLoad,
+		LastSave:  h.lastSave,
+		LastProbe: h.lastProbe,
+		Degraded:  h.degraded,
+	}
+	if !h.pendingSince.IsZero() {
+		health.OldestUnpersistedChange = time.Since(h.pendingSince).Seconds()
+	}
+	if !health.Healthy {
+		unhealthySince := h.unhealthySince
+		health.UnhealthySince = &unhealthySince
+		health.Reason = fmt.Sprintf("%s storage %s: %s", backend, name, h.reason)
+	}
+	return health
+}
+
+func newStoreOperationResult(err error) *StoreOperationResult {
+	result := &StoreOperationResult{Time: time.Now()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// GetHealth returns the health of the storage file
+func (s *LocalStore) GetHealth() *StoreHealth {
+	return s.health.getHealth(getStoreBackend(s), s.filename)
+}
+
+// Health checks that the storage can be written, with the namespace of the certificate Secrets,
+// before the context is done. A read-only storage only needs to be readable.
+func (s *LocalStore) Health(ctx context.Context) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- s.probe()
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("no answer: %v", ctx.Err())
+	}
+
+	s.health.probed(err)
+	return err
+}
+
+func (s *LocalStore) probe() error {
+	if s.IsReadOnly() {
+		if _, err := os.Stat(s.filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := checkDirectoryWritable(filepath.Dir(s.filename)); err != nil {
+		return err
+	}
+
+	if s.CertificateSecrets != nil {
+		return s.probeCertificateSecrets()
+	}
+	return nil
+}
+
+// GetStorageHealth probes the storage and returns its health, or nil when the store does not report it
+func (p *Provider) GetStorageHealth(ctx context.Context) *StoreHealth {
+	store, ok := unwrapStore(p.Store).(healthStore)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeHealthTimeout)
+	defer cancel()
+	if err := p.Store.Health(ctx); err != nil {
+		logger().WithField(logFieldOperation, storeOperationProbe).Debugf("The ACME storage health probe failed: %v", err)
+	}
+
+	return store.GetHealth()
+}
+
+// CheckStorageHealth returns an error when the storage is unhealthy for longer than StorageUnhealthyThreshold
+func (p *Provider) CheckStorageHealth() error {
+	if p.StorageUnhealthyThreshold <= 0 {
+		return nil
+	}
+
+	health := p.GetStorageHealth(context.Background())
+	if health == nil || health.Healthy {
+		return nil
+	}
+
+	if time.Since(*health.UnhealthySince) < time.Duration(p.StorageUnhealthyThreshold) {
+		return nil
+	}
+	return errors.New(health.Reason)
+}
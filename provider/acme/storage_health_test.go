@@ -0,0 +1,119 @@
+package acme
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreHealth(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	health := store.GetHealth()
+	assert.True(t, health.Healthy)
+	assert.Equal(t, "file", health.Backend)
+	require.NotNil(t, health.LastLoad)
+	assert.Empty(t, health.LastLoad.Error)
+	assert.Nil(t, health.LastSave)
+
+	// The storage can not be written once its directory is removed
+	dir := filepath.Dir(store.filename)
+	require.NoError(t, os.RemoveAll(dir))
+
+	provider := &Provider{Store: store, Configuration: &Configuration{StorageUnhealthyThreshold: parse.Duration(time.Hour)}}
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+
+	health = waitForStoreHealth(t, store, func(health *StoreHealth) bool { return !health.Healthy })
+	assert.Contains(t, health.Reason, "file storage "+store.filename+": unable to save: ")
+	assert.Contains(t, health.Reason, "no such file or directory")
+	require.NotNil(t, health.LastSave)
+	assert.NotEmpty(t, health.LastSave.Error)
+	assert.NotNil(t, health.UnhealthySince)
+	assert.True(t, health.OldestUnpersistedChange > 0)
+
+	// The store is unhealthy for less than the threshold
+	assert.NoError(t, provider.CheckStorageHealth())
+
+	provider.StorageUnhealthyThreshold = parse.Duration(time.Nanosecond)
+	err := provider.CheckStorageHealth()
+	require.Error(t, err)
+	assert.Equal(t, health.Reason, err.Error())
+
+	// The store is healthy again after a successful save
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+
+	health = waitForStoreHealth(t, store, func(health *StoreHealth) bool { return health.Healthy })
+	assert.Empty(t, health.Reason)
+	assert.Nil(t, health.UnhealthySince)
+	assert.Zero(t, health.OldestUnpersistedChange)
+	assert.NoError(t, provider.CheckStorageHealth())
+}
+
+func TestLocalStoreHealthProbe(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.Health(context.Background()))
+	health := store.GetHealth()
+	assert.True(t, health.Healthy)
+	require.NotNil(t, health.LastProbe)
+	assert.Empty(t, health.LastProbe.Error)
+
+	// The directory of the storage can not be written once removed
+	dir := filepath.Dir(store.filename)
+	require.NoError(t, os.RemoveAll(dir))
+
+	require.Error(t, store.Health(context.Background()))
+	health = store.GetHealth()
+	assert.False(t, health.Healthy)
+	assert.Contains(t, health.Reason, "file storage "+store.filename+": unable to probe: ")
+	assert.NotEmpty(t, health.LastProbe.Error)
+
+	// A read-only storage only needs to be readable
+	store.SetReadOnly(true)
+	require.NoError(t, store.Health(context.Background()))
+	assert.True(t, store.GetHealth().Healthy)
+
+	store.SetReadOnly(false)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	require.NoError(t, store.Health(context.Background()))
+	assert.True(t, store.GetHealth().Healthy)
+}
+
+func TestLocalStoreHealthDoesNotHideSaveFailure(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	dir := filepath.Dir(store.filename)
+	require.NoError(t, os.RemoveAll(dir))
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoreHealth(t, store, func(health *StoreHealth) bool { return !health.Healthy })
+
+	// The probe succeeds, but the last save still failed
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	require.NoError(t, store.Health(context.Background()))
+
+	health := store.GetHealth()
+	assert.False(t, health.Healthy)
+	assert.Contains(t, health.Reason, "unable to save: ")
+}
+
+// waitForStoreHealth waits for the health of the store to match the condition
+func waitForStoreHealth(t *testing.T, store *LocalStore, condition func(*StoreHealth) bool) *StoreHealth {
+	for i := 0; i < 500; i++ {
+		if health := store.GetHealth(); condition(health) {
+			return health
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.FailNow(t, "the health of the storage has not changed")
+	return nil
+}
@@ -0,0 +1,252 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"github.com/cenk/backoff"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	kmsProviderAWS = "aws"
+	kmsProviderGCP = "gcp"
+)
+
+// kmsRetryMaxElapsedTime is the maximum duration of the retries of a failing KMS call
+var kmsRetryMaxElapsedTime = 2 * time.Minute
+
+// StorageKMS holds the KMS key used to wrap the data-encryption key of the ACME storage
+type StorageKMS struct {
+	Provider string `description:"KMS provider. Allow value 'aws', 'gcp'"`
+	Key      string `description:"KMS key wrapping the data-encryption key: the key ARN (AWS) or the key resource name (GCP)"`
+}
+
+// KMSProvider wraps and unwraps data-encryption keys with a key stored in a KMS
+type KMSProvider interface {
+	// GenerateDataKey returns a new 32 bytes data key, in plaintext and wrapped by the KMS key
+	GenerateDataKey(ctx context.Context) (plaintext []byte, wrapped []byte, err error)
+	// Decrypt unwraps a data key wrapped by the KMS key
+	Decrypt(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+func newKMSProvider(config *StorageKMS) (KMSProvider, error) {
+	if len(config.Key) == 0 {
+		return nil, fmt.Errorf("no KMS key configured for the %s KMS provider", config.Provider)
+	}
+
+	switch config.Provider {
+	case kmsProviderAWS:
+		return newAWSKMS(config.Key)
+	case kmsProviderGCP:
+		return newGCPKMS(config.Key)
+	default:
+		return nil, fmt.Errorf("unsupported KMS provider %q, please select aws or gcp", config.Provider)
+	}
+}
+
+// retryKMS retries the KMS call with an exponential backoff, to ride out the KMS outages
+func retryKMS(operation func() error) error {
+	notify := func(err error, time time.Duration) {
+		logger().Errorf("KMS call failed, retrying in %s: %v", time, err)
+	}
+
+	ebo := backoff.NewExponentialBackOff()
+	ebo.MaxElapsedTime = kmsRetryMaxElapsedTime
+	return backoff.RetryNotify(operation, ebo, notify)
+}
+
+// newKMSStorageKey generates a new data key, or unwraps the given one, with the KMS
+func newKMSStorageKey(config *StorageKMS, provider KMSProvider, wrapped []byte) (*storageKey, error) {
+	var key []byte
+	var err error
+
+	if len(wrapped) > 0 {
+		err = retryKMS(func() error {
+			key, err = provider.Decrypt(context.Background(), wrapped)
+			return err
+		})
+	} else {
+		err = retryKMS(func() error {
+			key, wrapped, err = provider.GenerateDataKey(context.Background())
+			return err
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the data key from the %s KMS: %v", config.Provider, err)
+	}
+
+	return newStorageKey(config.Key, key, wrapped)
+}
+
+type awsKMS struct {
+	client *client.Client
+	keyID  string
+}
+
+type awsKMSGenerateDataKeyInput struct {
+	_       struct{} `type:"structure"`
+	KeyId   *string  `type:"string"`
+	KeySpec *string  `type:"string"`
+}
+
+type awsKMSGenerateDataKeyOutput struct {
+	_              struct{} `type:"structure"`
+	CiphertextBlob []byte   `type:"blob"`
+	Plaintext      []byte   `type:"blob"`
+}
+
+type awsKMSDecryptInput struct {
+	_              struct{} `type:"structure"`
+	CiphertextBlob []byte   `type:"blob"`
+}
+
+type awsKMSDecryptOutput struct {
+	_         struct{} `type:"structure"`
+	Plaintext []byte   `type:"blob"`
+}
+
+func newAWSKMS(keyID string) (*awsKMS, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &aws.Config{}
+	// arn:aws:kms:<region>:<account>:key/<id>
+	if parts := strings.Split(keyID, ":"); len(parts) > 3 && len(parts[3]) > 0 {
+		cfg.Region = aws.String(parts[3])
+	}
+
+	c := sess.ClientConfig("kms", cfg)
+	kmsClient := client.New(
+		*c.Config,
+		metadata.ClientInfo{
+			ServiceName:   "kms",
+			SigningName:   c.SigningName,
+			SigningRegion: c.SigningRegion,
+			Endpoint:      c.Endpoint,
+			APIVersion:    "2014-11-01",
+			JSONVersion:   "1.1",
+			TargetPrefix:  "TrentService",
+		},
+		c.Handlers,
+	)
+
+	kmsClient.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	kmsClient.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	kmsClient.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	kmsClient.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	kmsClient.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+
+	return &awsKMS{client: kmsClient, keyID: keyID}, nil
+}
+
+func (k *awsKMS) send(ctx context.Context, operation string, input, output interface{}) error {
+	req := k.client.NewRequest(&request.Operation{Name: operation, HTTPMethod: http.MethodPost, HTTPPath: "/"}, input, output)
+	req.SetContext(ctx)
+	return req.Send()
+}
+
+func (k *awsKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	output := &awsKMSGenerateDataKeyOutput{}
+	input := &awsKMSGenerateDataKeyInput{KeyId: aws.String(k.keyID), KeySpec: aws.String("AES_256")}
+	if err := k.send(ctx, "GenerateDataKey", input, output); err != nil {
+		return nil, nil, err
+	}
+	return output.Plaintext, output.CiphertextBlob, nil
+}
+
+func (k *awsKMS) Decrypt(ctx context.Context, wrapped []byte) ([]byte, error) {
+	output := &awsKMSDecryptOutput{}
+	if err := k.send(ctx, "Decrypt", &awsKMSDecryptInput{CiphertextBlob: wrapped}, output); err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
+
+// gcpKMS uses the Cloud KMS REST API, GCP KMS has no data key generation: the key is generated locally, then wrapped
+type gcpKMS struct {
+	client   *http.Client
+	endpoint string
+	keyName  string
+}
+
+func newGCPKMS(keyName string) (*gcpKMS, error) {
+	httpClient, err := google.DefaultClient(context.Background(), "https://www.googleapis.com/auth/cloudkms")
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcpKMS{client: httpClient, endpoint: "https://cloudkms.googleapis.com/v1/", keyName: keyName}, nil
+}
+
+func (k *gcpKMS) call(ctx context.Context, method string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, k.endpoint+k.keyName+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := k.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, resp.Status, strings.TrimSpace(string(content)))
+	}
+
+	return json.Unmarshal(content, output)
+}
+
+func (k *gcpKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, err
+	}
+
+	output := struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}{}
+	if err := k.call(ctx, "encrypt", map[string][]byte{"plaintext": key}, &output); err != nil {
+		return nil, nil, err
+	}
+	return key, output.Ciphertext, nil
+}
+
+func (k *gcpKMS) Decrypt(ctx context.Context, wrapped []byte) ([]byte, error) {
+	output := struct {
+		Plaintext []byte `json:"plaintext"`
+	}{}
+	if err := k.call(ctx, "decrypt", map[string][]byte{"ciphertext": wrapped}, &output); err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
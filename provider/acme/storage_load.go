@@ -0,0 +1,70 @@
+package acme
+
+import (
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// The delays between the attempts of the first load of the storage, doubled after each attempt
+var (
+	storageLoadInitialInterval = 500 * time.Millisecond
+	storageLoadMaxInterval     = 30 * time.Second
+)
+
+// errStorageNotLoaded is reported by the readiness check until the first load of the storage is resolved
+var errStorageNotLoaded = errors.New("the ACME storage is not loaded yet")
+
+// getStorageLoadDelay returns the delay before the next attempt of the first load, with a full jitter:
+// a random delay up to the exponential backoff, not to load the storage at the same time from every instance
+func getStorageLoadDelay(attempt int) time.Duration {
+	backoff := storageLoadMaxInterval
+	if attempt < 32 && storageLoadInitialInterval<<uint(attempt-1) < storageLoadMaxInterval {
+		backoff = storageLoadInitialInterval << uint(attempt-1)
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// retryStorageLoad calls the first load of the storage until it succeeds, with an exponential backoff and a full jitter,
+// for StorageLoadTimeout at most. The errors which are not retriable are returned right away.
+// The load is only called once when StorageLoadTimeout is empty.
+func (p *Provider) retryStorageLoad(load func() error) error {
+	defer atomic.StoreInt32(&p.storageLoadResolved, 1)
+
+	timeout := time.Duration(p.StorageLoadTimeout)
+	if timeout <= 0 {
+		return load()
+	}
+
+	logger := logger().WithField(logFieldOperation, storeOperationLoad)
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := load()
+		if err == nil {
+			logger.Infof("The ACME storage is loaded after %d attempt(s) in %s.", attempt, time.Since(start))
+			return nil
+		}
+
+		delay := getStorageLoadDelay(attempt)
+		if !IsRetriable(err) || time.Since(start)+delay > timeout {
+			logger.Infof("Unable to load the ACME storage after %d attempt(s) in %s: %v", attempt, time.Since(start), err)
+			return err
+		}
+
+		logger.Debugf("Unable to load the ACME storage (attempt %d), retrying in %s: %v", attempt, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+// CheckStorageLoaded returns an error until the first load of the storage is resolved, successfully or not
+func (p *Provider) CheckStorageLoaded() error {
+	if atomic.LoadInt32(&p.storageLoadResolved) == 0 {
+		return errStorageNotLoaded
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package acme
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStorageLoadDelay(t *testing.T) {
+	for attempt := 1; attempt < 100; attempt++ {
+		delay := getStorageLoadDelay(attempt)
+		assert.True(t, delay >= 0)
+		assert.True(t, delay <= storageLoadMaxInterval)
+		if attempt == 1 {
+			assert.True(t, delay <= storageLoadInitialInterval)
+		}
+	}
+}
+
+func TestRetryStorageLoad(t *testing.T) {
+	defer func(initial, max time.Duration) {
+		storageLoadInitialInterval = initial
+		storageLoadMaxInterval = max
+	}(storageLoadInitialInterval, storageLoadMaxInterval)
+	storageLoadInitialInterval = time.Millisecond
+	storageLoadMaxInterval = 5 * time.Millisecond
+
+	testCases := []struct {
+		desc             string
+		timeout          time.Duration
+		failures         int
+		err              error
+		expectedErr      bool
+		expectedAttempts int
+	}{
+		{
+			desc:             "loaded at once",
+			timeout:          time.Second,
+			expectedAttempts: 1,
+		},
+		{
+			desc:             "loaded after retries",
+			timeout:          time.Second,
+			failures:         3,
+			err:              errors.New("connection refused"),
+			expectedAttempts: 4,
+		},
+		{
+			desc:             "not retried without timeout",
+			failures:         3,
+			err:              errors.New("connection refused"),
+			expectedErr:      true,
+			expectedAttempts: 1,
+		},
+		{
+			desc:             "error not retriable",
+			timeout:          time.Second,
+			failures:         3,
+			err:              &storageMissingError{filename: "acme.json"},
+			expectedErr:      true,
+			expectedAttempts: 1,
+		},
+		{
+			desc:        "timeout",
+			timeout:     50 * time.Millisecond,
+			failures:    1000,
+			err:         errors.New("connection refused"),
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			provider := &Provider{Configuration: &Configuration{StorageLoadTimeout: parse.Duration(test.timeout)}}
+			require.Equal(t, errStorageNotLoaded, provider.CheckStorageLoaded())
+
+			attempts := 0
+			err := provider.retryStorageLoad(func() error {
+				attempts++
+				if attempts <= test.failures {
+					return test.err
+				}
+				return nil
+			})
+
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			if test.expectedAttempts > 0 {
+				assert.Equal(t, test.expectedAttempts, attempts)
+			}
+
+			// The readiness check passes once the load is resolved, even when it failed
+			assert.NoError(t, provider.CheckStorageLoaded())
+		})
+	}
+}
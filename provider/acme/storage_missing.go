@@ -0,0 +1,106 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// The behaviors when the storage does not exist at start
+const (
+	// MissingStorageCreateEmpty starts with an empty storage, created right away
+	MissingStorageCreateEmpty = "createEmpty"
+	// MissingStorageWaitFor waits for the storage to be created by others, as a process seeding the account
+	MissingStorageWaitFor = "waitFor"
+	// MissingStorageFail refuses to start
+	MissingStorageFail = "fail"
+)
+
+// storageWaitInterval is the interval between two checks of the storage file waited for
+var storageWaitInterval = time.Second
+
+// storageMissingError is returned when the storage does not exist and must not be created,
+// it is not temporary: loading the storage again does not create it
+type storageMissingError struct {
+	filename string
+}
+
+func (e *storageMissingError) Error() string {
+	return fmt.Sprintf("the ACME storage %s does not exist", e.filename)
+}
+
+// Temporary implements the temporary interface
+func (e *storageMissingError) Temporary() bool {
+	return false
+}
+
+func checkMissingStoragePolicy(policy string) error {
+	switch policy {
+	case "", MissingStorageCreateEmpty, MissingStorageWaitFor, MissingStorageFail:
+		return nil
+	default:
+		return fmt.Errorf("unknown behavior %q when the ACME storage is missing, expected %s, %s or %s", policy, MissingStorageCreateEmpty, MissingStorageWaitFor, MissingStorageFail)
+	}
+}
+
+// hasLoaded returns whether the storage has been loaded once
+func (h *storeHealthTracker) hasLoaded() bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return !h.lastSuccessfulLoad.IsZero()
+}
+
+// checkMissingStorage applies the MissingPolicy of the store to the storage file missing at start,
+// it returns whether the storage file exists once applied.
+// A storage file which can not be checked is never considered missing.
+func (s *LocalStore) checkMissingStorage() (bool, error) {
+	_, err := os.Stat(s.filename)
+	if err == nil || !os.IsNotExist(err) {
+		return true, nil
+	}
+
+	// The storage removed after the start is not waited for
+	if s.health.hasLoaded() {
+		return false, nil
+	}
+
+	switch s.MissingPolicy {
+	case MissingStorageFail:
+		return false, &storageMissingError{filename: s.filename}
+	case MissingStorageWaitFor:
+		if err := s.waitForStorageFile(); err != nil {
+			return false, err
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// waitForStorageFile waits for the storage file to be created by others, until the store is closed
+func (s *LocalStore) waitForStorageFile() error {
+	logger := s.logger(storeOperationLoad)
+	logger.Infof("The ACME storage %s does not exist, waiting for it to be created.", s.filename)
+
+	ticker := time.NewTicker(storageWaitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return errors.New("the ACME storage is closed")
+		case <-ticker.C:
+		}
+
+		_, err := os.Stat(s.filename)
+		if err == nil {
+			logger.Infof("The ACME storage %s is created, loading it.", s.filename)
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+}
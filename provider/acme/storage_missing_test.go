@@ -0,0 +1,114 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreMissingStorage(t *testing.T) {
+	defer func(interval time.Duration) { storageWaitInterval = interval }(storageWaitInterval)
+	storageWaitInterval = 10 * time.Millisecond
+
+	testCases := []struct {
+		desc            string
+		policy          string
+		created         bool
+		expectedErr     bool
+		expectedCreated bool
+	}{
+		{
+			desc:            "default",
+			expectedCreated: true,
+		},
+		{
+			desc:            "create empty",
+			policy:          MissingStorageCreateEmpty,
+			expectedCreated: true,
+		},
+		{
+			desc:        "fail",
+			policy:      MissingStorageFail,
+			expectedErr: true,
+		},
+		{
+			desc:            "wait for the storage",
+			policy:          MissingStorageWaitFor,
+			created:         true,
+			expectedCreated: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			filename := filepath.Join(dir, "acme.json")
+			store := &LocalStore{filename: filename, MissingPolicy: test.policy, closing: make(chan struct{})}
+
+			if test.created {
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					_ = ioutil.WriteFile(filename, []byte(`{"Account":{"Email":"test@traefik.wtf"}}`), 0600)
+				}()
+			}
+
+			_, err = store.get(context.Background())
+			if test.expectedErr {
+				require.Error(t, err)
+				// The failed load is not served as an empty storage
+				_, err = store.get(context.Background())
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			_, err = os.Stat(filename)
+			assert.Equal(t, test.expectedCreated, err == nil)
+
+			if test.created {
+				account, err := store.GetAccount(context.Background())
+				require.NoError(t, err)
+				require.NotNil(t, account)
+				assert.Equal(t, "test@traefik.wtf", account.Email)
+			}
+		})
+	}
+}
+
+func TestLocalStoreWaitForStorageClosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &LocalStore{filename: filepath.Join(dir, "acme.json"), MissingPolicy: MissingStorageWaitFor, closing: make(chan struct{})}
+	close(store.closing)
+
+	_, err = store.get(context.Background())
+	require.Error(t, err)
+}
+
+func TestLocalStoreUnreadableStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The storage is a directory, it exists but can not be read whatever the behavior when it is missing
+	filename := filepath.Join(dir, "acme.json")
+	require.NoError(t, os.Mkdir(filename, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(filename, "file"), []byte("content"), 0600))
+
+	store := &LocalStore{filename: filename, MissingPolicy: MissingStorageCreateEmpty}
+	for i := 0; i < 2; i++ {
+		_, err = store.GetAccount(context.Background())
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,116 @@
+package acme
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// permissionsStore is implemented by the stores checking their permissions on start
+type permissionsStore interface {
+	CheckPermissions() error
+}
+
+// storagePermission is the result of the check of a permission needed by the local store
+type storagePermission struct {
+	operation string
+	target    string
+	err       error
+}
+
+func (p storagePermission) String() string {
+	status := "granted"
+	if p.err != nil {
+		status = fmt.Sprintf("denied (%v)", p.err)
+	}
+	return fmt.Sprintf("%-7s %-40s %s", p.operation, p.target, status)
+}
+
+// CheckPermissions checks the permissions needed by the store on the storage file, and logs them.
+// A missing write permission enters the read-only mode when ReadOnlyFallback is set, and fails otherwise,
+// unless the store is already read-only.
+func (s *LocalStore) CheckPermissions() error {
+	permissions := s.getStoragePermissions()
+
+	logger := s.logger(storeOperationCheckPermissions)
+
+	var readErr, writeErr error
+	logger.Info("ACME storage permissions:")
+	for _, permission := range permissions {
+		logger.Infof("  %s", permission)
+
+		if permission.err == nil {
+			continue
+		}
+		if permission.operation == "read" {
+			readErr = permission.err
+		} else if writeErr == nil {
+			writeErr = permission.err
+		}
+	}
+
+	switch {
+	case readErr != nil:
+		return fmt.Errorf("unable to read the ACME storage %s: %v", s.filename, readErr)
+	case writeErr != nil && s.IsReadOnly():
+		// The storage is never written in read-only mode
+	case writeErr != nil && !s.ReadOnlyFallback:
+		return fmt.Errorf("unable to write the ACME storage %s: %v", s.filename, writeErr)
+	case writeErr != nil:
+		logger.Warnf("Unable to write the ACME storage %s, entering read-only mode: the certificates are served, but the changes are not saved: %v", s.filename, writeErr)
+		s.SetReadOnly(true)
+	}
+
+	return nil
+}
+
+// getStoragePermissions checks the storage file can be read and written,
+// and its directory can receive the signature and the rejected storage when the storage is signed
+func (s *LocalStore) getStoragePermissions() []storagePermission {
+	var permissions []storagePermission
+
+	_, err := os.Stat(s.filename)
+	switch {
+	case os.IsNotExist(err):
+		permissions = append(permissions, storagePermission{operation: "create", target: s.filename, err: checkDirectoryWritable(filepath.Dir(s.filename))})
+	case err != nil:
+		permissions = append(permissions, storagePermission{operation: "read", target: s.filename, err: unwrapPathError(err)})
+	default:
+		permissions = append(permissions,
+			storagePermission{operation: "read", target: s.filename, err: checkFileOpen(s.filename, os.O_RDONLY)},
+			storagePermission{operation: "write", target: s.filename, err: checkFileOpen(s.filename, os.O_WRONLY)},
+		)
+	}
+
+	if s.Signing != nil {
+		permissions = append(permissions, storagePermission{operation: "create", target: filepath.Dir(s.filename), err: checkDirectoryWritable(filepath.Dir(s.filename))})
+	}
+
+	return permissions
+}
+
+func checkFileOpen(filename string, flag int) error {
+	f, err := os.OpenFile(filename, flag, 0)
+	if err != nil {
+		return unwrapPathError(err)
+	}
+	return f.Close()
+}
+
+func checkDirectoryWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".acme-check")
+	if err != nil {
+		return unwrapPathError(err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// unwrapPathError drops the path from the error, already part of the permissions table
+func unwrapPathError(err error) error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		return pathErr.Err
+	}
+	return err
+}
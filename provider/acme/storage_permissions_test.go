@@ -0,0 +1,100 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreCheckPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "acme.json")
+	require.NoError(t, ioutil.WriteFile(existing, []byte("{}"), 0600))
+
+	// A directory can be opened for reading, but not for writing
+	notWritable := filepath.Join(dir, "directory")
+	require.NoError(t, os.Mkdir(notWritable, 0700))
+
+	testCases := []struct {
+		desc             string
+		filename         string
+		readOnlyFallback bool
+		expectedErr      bool
+		expectedReadOnly bool
+	}{
+		{
+			desc:     "existing storage",
+			filename: existing,
+		},
+		{
+			desc:     "missing storage",
+			filename: filepath.Join(dir, "missing.json"),
+		},
+		{
+			desc:        "storage not writable",
+			filename:    notWritable,
+			expectedErr: true,
+		},
+		{
+			desc:             "storage not writable with read-only fallback",
+			filename:         notWritable,
+			readOnlyFallback: true,
+			expectedReadOnly: true,
+		},
+		{
+			desc:             "storage not readable",
+			filename:         filepath.Join(existing, "acme.json"),
+			readOnlyFallback: true,
+			expectedErr:      true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			store := &LocalStore{filename: test.filename, ReadOnlyFallback: test.readOnlyFallback}
+
+			err := store.CheckPermissions()
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedReadOnly, store.IsReadOnly())
+		})
+	}
+}
+
+func TestLocalStoreReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	store := NewLocalStore(filename)
+	store.SetReadOnly(true)
+
+	certificates := []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}
+	assert.Equal(t, ErrReadOnly, store.SaveCertificates(context.Background(), certificates))
+	assert.Equal(t, ErrReadOnly, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	assert.Equal(t, ErrReadOnly, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+	assert.Equal(t, ErrReadOnly, store.AddTLSChallenge(context.Background(), "traefik.wtf", certificates[0]))
+	assert.Equal(t, ErrReadOnly, store.AddDNSChallenge(context.Background(), "token", &DNSChallengeState{Domain: "traefik.wtf"}))
+	_, err = store.RemoveExpiredHTTPChallengeTokens(context.Background(), time.Minute)
+	assert.Equal(t, ErrReadOnly, err)
+
+	// A read-only store never creates the storage
+	_, err = store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	_, err = os.Stat(filename)
+	assert.True(t, os.IsNotExist(err))
+}
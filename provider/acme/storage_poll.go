@@ -0,0 +1,101 @@
+package acme
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// pollStore is implemented by the stores able to detect the changes of their storage made by others
+type pollStore interface {
+	Poll() (bool, error)
+}
+
+// contentHash is the hash of the storage content last loaded or written
+type contentHash struct {
+	lock sync.Mutex
+	sum  [sha256.Size]byte
+}
+
+func (h *contentHash) set(content []byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.sum = sha256.Sum256(content)
+}
+
+func (h *contentHash) matches(content []byte) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.sum == sha256.Sum256(content)
+}
+
+// hasPendingChanges returns whether changes are not saved yet
+func (h *storeHealthTracker) hasPendingChanges() bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return !h.pendingSince.IsZero()
+}
+
+// Poll reloads the storage file when its content is not the one last loaded or written, and returns whether it is reloaded.
+// The file is not read while changes are not saved yet, not to read a partially written file nor to drop the changes,
+// and an empty file is not reloaded.
+func (s *LocalStore) Poll() (bool, error) {
+	if s.health.hasPendingChanges() {
+		s.logger(storeOperationLoad).Debug("Skip the poll of the ACME storage, changes are not saved yet.")
+		return false, nil
+	}
+
+	file, err := ioutil.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		file, err = nil, nil
+	} else if err == nil && len(file) == 0 {
+		// The file is truncated by a write of others, it is read again on the next poll
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if s.hash.matches(file) {
+		return false, nil
+	}
+
+	s.logger(storeOperationLoad).Infof("The ACME storage %s has changed, reloading it.", s.filename)
+	return true, s.Reload()
+}
+
+// getPollDelay returns the interval with a jitter of up to 10%, not to poll the storage at the same time from every instance
+func getPollDelay(interval time.Duration) time.Duration {
+	jitter := int64(interval / 10)
+	if jitter <= 0 {
+		return interval
+	}
+	return interval - time.Duration(jitter) + time.Duration(rand.Int63n(2*jitter))
+}
+
+// pollStorage reloads the storage and serves its certificates when it has changed.
+// It runs in the routine watching the certificates, which owns the certificates in memory.
+func (p *Provider) pollStorage() {
+	store, ok := unwrapStore(p.Store).(pollStore)
+	if !ok {
+		return
+	}
+
+	changed, err := store.Poll()
+	if err != nil {
+		logger().WithField(logFieldOperation, storeOperationLoad).Errorf("Unable to poll the ACME storage: %v", err)
+		return
+	}
+
+	if changed {
+		if err := p.reloadFromStore(); err != nil {
+			logger().WithField(logFieldOperation, storeOperationLoad).Errorf("Unable to serve the certificates of the polled ACME storage: %v", err)
+		}
+	}
+}
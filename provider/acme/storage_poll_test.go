@@ -0,0 +1,57 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorePoll(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate")}}))
+	waitForStoreHealth(t, store, func(health *StoreHealth) bool { return health.LastSave != nil })
+
+	// The storage written by the store is not reloaded
+	changed, err := store.Poll()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{"Certificates":[{"Domain":{"Main":"other.wtf"},"Certificate":"b3RoZXI=","Key":"a2V5"}]}`), 0600))
+
+	// The storage is not read while changes are not saved
+	store.health.changed()
+	changed, err = store.Poll()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	store.health.saved(nil)
+
+	changed, err = store.Poll()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, "other.wtf", certificates[0].Domain.Main)
+
+	// The reloaded storage is not reloaded again
+	changed, err = store.Poll()
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestGetPollDelay(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		delay := getPollDelay(time.Minute)
+		assert.True(t, delay >= 54*time.Second && delay < 66*time.Second, "unexpected delay %s", delay)
+	}
+
+	assert.Equal(t, time.Duration(5), getPollDelay(5))
+}
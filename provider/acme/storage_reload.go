@@ -0,0 +1,246 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// reloadStore is implemented by the stores able to load their storage again
+type reloadStore interface {
+	Reload() error
+}
+
+// Reload loads the storage file again and replaces the data in memory, keeping the challenges and the on demand queue in memory.
+// The data is kept when the storage can not be loaded, the certificate Secrets and resources are listed again from Kubernetes.
+func (s *LocalStore) Reload() error {
+	s.invalidateKubernetesCache()
+
+	// The storage is loaded apart, the challenges being served from the data in memory meanwhile
+	storedData, err := s.loadStorage()
+	if err != nil {
+		return err
+	}
+
+	s.loadLock.Lock()
+	defer s.loadLock.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if previous := s.storedData; previous != nil {
+		keepChallenges(storedData, previous)
+		s.subscriptions.notify(changedCertificateDomains(previous.Certificates, storedData.Certificates))
+
+		// The data is replaced in place, for the callers still holding it
+		*previous = *storedData
+	} else {
+		s.storedData = storedData
+	}
+
+	s.leaveCache()
+	s.logger(storeOperationLoad).Infof("The ACME storage %s is reloaded.", s.filename)
+	return nil
+}
+
+// keepChallenges adds the challenges of the previous data to the loaded one, the challenges in progress may only exist in memory
+func keepChallenges(storedData, previous *StoredData) {
+	for token, domains := range previous.HTTPChallenges {
+		if _, ok := storedData.HTTPChallenges[token]; !ok {
+			storedData.HTTPChallenges[token] = make(map[string][]byte)
+		}
+		for domain, keyAuth := range domains {
+			storedData.HTTPChallenges[token][domain] = keyAuth
+		}
+	}
+	for token, domains := range previous.HTTPChallengesCreatedAt {
+		for domain, createdAt := range domains {
+			setHTTPChallengeCreatedAt(storedData, token, domain, createdAt)
+		}
+	}
+
+	for domain, certificate := range previous.TLSChallenges {
+		storedData.TLSChallenges[domain] = certificate
+	}
+	for domain, createdAt := range previous.TLSChallengesCreatedAt {
+		storedData.TLSChallengesCreatedAt[domain] = createdAt
+	}
+
+	if len(previous.DNSChallenges) > 0 && storedData.DNSChallenges == nil {
+		storedData.DNSChallenges = make(map[string]*DNSChallengeState)
+	}
+	for token, state := range previous.DNSChallenges {
+		storedData.DNSChallenges[token] = state
+	}
+
+	if len(previous.OnDemandQueue) > 0 && storedData.OnDemandQueue == nil {
+		storedData.OnDemandQueue = make(map[string]*OnDemandRequest)
+	}
+	for domain, request := range previous.OnDemandQueue {
+		storedData.OnDemandQueue[domain] = request
+	}
+}
+
+// StorageReload is the summary of a reload of the storage, the certificates are identified by their domains
+type StorageReload struct {
+	AccountChanged bool                 `json:"accountChanged"`
+	Added          StorageReloadChanges `json:"added"`
+	Updated        StorageReloadChanges `json:"updated"`
+	Removed        StorageReloadChanges `json:"removed"`
+}
+
+// StorageReloadChanges are the certificates added, updated or removed by a reload of the storage
+type StorageReloadChanges struct {
+	Count   int      `json:"count"`
+	Domains []string `json:"domains"`
+}
+
+func newStorageReloadChanges(domains []string) StorageReloadChanges {
+	if domains == nil {
+		domains = []string{}
+	}
+	return StorageReloadChanges{Count: len(domains), Domains: domains}
+}
+
+// StorageLoadError is returned when the storage can not be loaded again, the data in memory being kept
+type StorageLoadError struct {
+	Err error
+}
+
+func (e *StorageLoadError) Error() string {
+	return fmt.Sprintf("unable to load the ACME storage: %v", e.Err)
+}
+
+// storageReloadGroup coalesces the concurrent reloads of the storage
+type storageReloadGroup struct {
+	lock sync.Mutex
+	call *storageReloadCall
+}
+
+// storageReloadCall is a reload in progress, its result is shared by the reloads requested meanwhile
+type storageReloadCall struct {
+	done   chan struct{}
+	result *StorageReload
+	err    error
+}
+
+func (g *storageReloadGroup) do(reload func() (*StorageReload, error)) (*StorageReload, error) {
+	g.lock.Lock()
+	if call := g.call; call != nil {
+		g.lock.Unlock()
+		<-call.done
+		return call.result, call.err
+	}
+
+	call := &storageReloadCall{done: make(chan struct{})}
+	g.call = call
+	g.lock.Unlock()
+
+	call.result, call.err = reload()
+
+	g.lock.Lock()
+	g.call = nil
+	g.lock.Unlock()
+	close(call.done)
+
+	return call.result, call.err
+}
+
+// ReloadStorage loads the storage again and serves its certificates, it returns the changes of the certificates,
+// none when the store can not be reloaded. The reloads requested while a reload is in progress get its result.
+func (p *Provider) ReloadStorage() (*StorageReload, error) {
+	store, ok := unwrapStore(p.Store).(reloadStore)
+	if !ok {
+		return nil, nil
+	}
+
+	return p.reloads.do(func() (*StorageReload, error) {
+		// The changes not written yet to an unhealthy storage would be replaced by its data
+		if health := p.GetStorageHealth(p.getContext()); health != nil && !health.Healthy {
+			return nil, &StorageLoadError{Err: errors.New(health.Reason)}
+		}
+
+		previous, err := p.getStoredAccountAndCertificates()
+		if err != nil {
+			return nil, &StorageLoadError{Err: err}
+		}
+
+		if err = store.Reload(); err != nil {
+			return nil, &StorageLoadError{Err: err}
+		}
+
+		reloaded, err := p.getStoredAccountAndCertificates()
+		if err != nil {
+			return nil, &StorageLoadError{Err: err}
+		}
+
+		if err = p.serveReloadedStorage(); err != nil {
+			return nil, err
+		}
+
+		drift := diffStoredData(previous, reloaded)
+		return &StorageReload{
+			AccountChanged: drift.AccountChanged,
+			Added:          newStorageReloadChanges(drift.Added),
+			Updated:        newStorageReloadChanges(drift.Changed),
+			Removed:        newStorageReloadChanges(drift.Removed),
+		}, nil
+	})
+}
+
+// getStoredAccountAndCertificates returns the account and the certificates of the store
+func (p *Provider) getStoredAccountAndCertificates() (*StoredData, error) {
+	account, err := p.Store.GetAccount(p.getContext())
+	if err != nil {
+		return nil, err
+	}
+
+	certificates, err := p.Store.GetCertificates(p.getContext())
+	if err != nil {
+		return nil, err
+	}
+
+	return &StoredData{Account: account, Certificates: certificates}, nil
+}
+
+// serveReloadedStorage serves the account and the certificates of the reloaded storage
+func (p *Provider) serveReloadedStorage() error {
+	// The certificates in memory are replaced in the routine watching the certificates, which owns them.
+	// Before the start of the provider, they are read from the store at the start.
+	if p.storageReloads == nil {
+		return nil
+	}
+
+	done := make(chan error)
+	p.storageReloads <- done
+	return <-done
+}
+
+// reloadFromStore replaces the account and the certificates in memory by the ones of the store
+func (p *Provider) reloadFromStore() error {
+	invalidateStoreCache(p.Store)
+
+	account, err := p.Store.GetAccount(p.getContext())
+	if err != nil {
+		return err
+	}
+
+	certificates, err := p.Store.GetCertificates(p.getContext())
+	if err != nil {
+		return err
+	}
+
+	p.clientMutex.Lock()
+	// The account is kept when it is the same, its private key may come from a Secret
+	if isAccountDrifted(p.account, account) {
+		p.account = account
+		// The clients are built again with the reloaded account
+		p.clients = nil
+	}
+	p.clientMutex.Unlock()
+
+	p.certificates = certificates
+	p.certificateIndex.reset(p.certificates)
+	p.refreshCertificates()
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreReload(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate")}}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1
+	})
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+	storedData, err := store.get(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{"Account":{"Email":"test@traefik.wtf"},"Certificates":[{"Domain":{"Main":"other.wtf"},"Certificate":"b3RoZXI=","Key":"a2V5"}]}`), 0600))
+	require.NoError(t, store.Reload())
+
+	account, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, account)
+	assert.Equal(t, "test@traefik.wtf", account.Email)
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, "other.wtf", certificates[0].Domain.Main)
+
+	// The challenges in memory are kept
+	keyAuth, err := store.GetHTTPChallengeToken(context.Background(), "token", "traefik.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("keyAuth"), keyAuth)
+
+	// The data held by the callers is reloaded too
+	assert.Equal(t, certificates, storedData.Certificates)
+}
+
+func TestLocalStoreReloadFailure(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil
+	})
+
+	require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{`), 0600))
+	assert.Error(t, store.Reload())
+
+	account, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, account)
+	assert.Equal(t, "test@traefik.wtf", account.Email)
+}
+
+func TestLocalStoreReloadLegacyAccount(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	account, err := NewAccount("test@traefik.wtf", "EC256", "EC256")
+	require.NoError(t, err)
+	require.NoError(t, store.SaveAccount(context.Background(), account))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil
+	})
+
+	// The account of a storage written before the private key type was recorded is saved again while it is reloaded
+	legacy := *account
+	legacy.PrivateKeyType = ""
+	file, err := json.Marshal(&StoredData{Account: &legacy})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(store.filename, file, 0600))
+
+	reloaded := make(chan error)
+	go func() { reloaded <- store.Reload() }()
+
+	select {
+	case err = <-reloaded:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The reload of the storage is blocked")
+	}
+
+	reloadedAccount, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, reloadedAccount)
+	assert.Equal(t, account.PrivateKeyType, reloadedAccount.PrivateKeyType)
+
+	// The reloaded storage file does not hold the data last written, it is written again
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil && storedData.Account.PrivateKeyType == account.PrivateKeyType
+	})
+}
+
+func TestProviderReloadStorage(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	configurationChan := make(chan types.ConfigMessage, 1)
+	provider := &Provider{
+		Configuration:     &Configuration{EntryPoint: "https"},
+		Store:             store,
+		account:           &Account{Email: "test@traefik.wtf"},
+		certificates:      []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}}},
+		certificateIndex:  newCertificateIndex(nil),
+		configurationChan: configurationChan,
+		storageReloads:    make(chan chan error),
+	}
+
+	// The routine watching the certificates
+	go func() {
+		done := <-provider.storageReloads
+		done <- provider.reloadFromStore()
+	}()
+
+	require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{"Account":{"Email":"other@traefik.wtf"},"Certificates":[{"Domain":{"Main":"other.wtf"},"Certificate":"b3RoZXI=","Key":"a2V5"}]}`), 0600))
+
+	reload, err := provider.ReloadStorage()
+	require.NoError(t, err)
+	assert.Equal(t, &StorageReload{
+		AccountChanged: true,
+		Added:          StorageReloadChanges{Count: 1, Domains: []string{"other.wtf"}},
+		Updated:        StorageReloadChanges{Domains: []string{}},
+		Removed:        StorageReloadChanges{Domains: []string{}},
+	}, reload)
+
+	assert.Equal(t, "other@traefik.wtf", provider.account.Email)
+	require.Len(t, provider.certificates, 1)
+	assert.Equal(t, "other.wtf", provider.certificates[0].Domain.Main)
+
+	config := <-configurationChan
+	assert.Len(t, config.Configuration.TLS, 1)
+}
+
+func TestProviderReloadUnhealthyStorage(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil
+	})
+
+	// The storage can not be written once its directory is removed
+	require.NoError(t, os.RemoveAll(filepath.Dir(store.filename)))
+
+	provider := &Provider{Configuration: &Configuration{}, Store: store}
+
+	reload, err := provider.ReloadStorage()
+	assert.Nil(t, reload)
+	require.IsType(t, &StorageLoadError{}, err)
+	assert.Contains(t, err.Error(), "unable to probe: ")
+
+	account, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, account)
+	assert.Equal(t, "test@traefik.wtf", account.Email)
+}
+
+func TestStorageReloadGroup(t *testing.T) {
+	group := &storageReloadGroup{}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls int32
+
+	results := make(chan *StorageReload, 2)
+	go func() {
+		reload, _ := group.do(func() (*StorageReload, error) {
+			atomic.AddInt32(&calls, 1)
+			close(started)
+			<-release
+			return &StorageReload{AccountChanged: true}, nil
+		})
+		results <- reload
+	}()
+
+	<-started
+	go func() {
+		reload, _ := group.do(func() (*StorageReload, error) {
+			atomic.AddInt32(&calls, 1)
+			return &StorageReload{}, nil
+		})
+		results <- reload
+	}()
+
+	// The second reload waits for the one in progress
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	first, second := <-results, <-results
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.True(t, first.AccountChanged)
+	assert.True(t, second.AccountChanged)
+
+	// A reload requested afterwards runs again
+	reload, err := group.do(func() (*StorageReload, error) {
+		atomic.AddInt32(&calls, 1)
+		return &StorageReload{}, nil
+	})
+	require.NoError(t, err)
+	assert.False(t, reload.AccountChanged)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,208 @@
+package acme
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// ErrUnknownRevision is returned when the requested revision of the storage is not one of the kept revisions
+var ErrUnknownRevision = errors.New("unknown revision of the ACME storage")
+
+// revisionStore is implemented by the stores keeping revisions of their storage
+type revisionStore interface {
+	GetRevisions() ([]*StorageRevision, error)
+	Rollback(revision int) error
+}
+
+// StorageRevision is a copy of the storage file, written when its account or its certificates changed
+type StorageRevision struct {
+	Revision  int       `json:"revision"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Digest is the hash of the account and the certificates of the revision, the changes of the challenges only are not revisions
+	Digest string `json:"digest"`
+}
+
+func getRevisionFilename(filename string, revision int) string {
+	return fmt.Sprintf("%s.rev-%d", filename, revision)
+}
+
+// getRevisionsFilename returns the file listing the revisions of the storage file, with their creation date
+func getRevisionsFilename(filename string) string {
+	return filename + ".revisions"
+}
+
+// ReadStorageRevisions returns the revisions kept of the storage file, the last one first
+func ReadStorageRevisions(filename string) ([]*StorageRevision, error) {
+	content, err := ioutil.ReadFile(getRevisionsFilename(filename))
+	if os.IsNotExist(err) {
+		return []*StorageRevision{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []*StorageRevision{}
+	if err := json.Unmarshal(content, &revisions); err != nil {
+		return nil, fmt.Errorf("unable to read the revisions of the ACME storage %s: %v", filename, err)
+	}
+	return revisions, nil
+}
+
+// RestoreStorageRevision writes the revision in the storage file, with its signature.
+// A running Traefik loads it on its next poll or reload of the storage.
+func RestoreStorageRevision(filename string, revision int) error {
+	revisions, err := ReadStorageRevisions(filename)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, kept := range revisions {
+		found = found || kept.Revision == revision
+	}
+	if !found {
+		return ErrUnknownRevision
+	}
+
+	revisionFilename := getRevisionFilename(filename, revision)
+	data, err := ioutil.ReadFile(revisionFilename)
+	if err != nil {
+		return fmt.Errorf("unable to read the revision %d of the ACME storage %s: %v", revision, filename, err)
+	}
+
+	signature, err := ioutil.ReadFile(getSignatureFilename(revisionFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read the signature of the revision %d of the ACME storage %s: %v", revision, filename, err)
+	}
+
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		return err
+	}
+	if signature != nil {
+		return ioutil.WriteFile(getSignatureFilename(filename), signature, 0600)
+	}
+	return nil
+}
+
+// getRevisionDigest returns the hash of the account and the certificates of the encoded data, their encoding being reused as is
+func getRevisionDigest(payload []byte) (string, error) {
+	var sections struct {
+		Account      json.RawMessage
+		Certificates json.RawMessage
+	}
+	if err := json.Unmarshal(payload, &sections); err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write(sections.Account)
+	hash.Write([]byte{0})
+	hash.Write(sections.Certificates)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeRevision writes a revision of the written storage file when its account or its certificates changed since the last revision,
+// and removes the revisions beyond the kept ones. The revisions are written after the storage file, they are not saves of the storage:
+// they are not coalesced, and never change its health.
+func (s *LocalStore) writeRevision(data, signature []byte, digest string) {
+	logger := s.logger(storeOperationSave)
+
+	revisions, err := ReadStorageRevisions(s.filename)
+	if err != nil {
+		logger.Errorf("Unable to read the revisions of the ACME storage, the revision is not written: %v", err)
+		return
+	}
+	if len(revisions) > 0 && revisions[0].Digest == digest {
+		return
+	}
+
+	revision := &StorageRevision{Revision: 1, CreatedAt: time.Now(), Digest: digest}
+	if len(revisions) > 0 {
+		revision.Revision = revisions[0].Revision + 1
+	}
+
+	revisionFilename := getRevisionFilename(s.filename, revision.Revision)
+	if err := ioutil.WriteFile(revisionFilename, data, 0600); err != nil {
+		logger.Errorf("Unable to write the revision %d of the ACME storage: %v", revision.Revision, err)
+		return
+	}
+	if signature != nil {
+		if err := ioutil.WriteFile(getSignatureFilename(revisionFilename), signature, 0600); err != nil {
+			logger.Errorf("Unable to write the signature of the revision %d of the ACME storage: %v", revision.Revision, err)
+			return
+		}
+	}
+
+	revisions = append([]*StorageRevision{revision}, revisions...)
+	for len(revisions) > s.Revisions {
+		pruned := getRevisionFilename(s.filename, revisions[len(revisions)-1].Revision)
+		for _, name := range []string{pruned, getSignatureFilename(pruned)} {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				logger.Warnf("Unable to remove the pruned revision %s of the ACME storage: %v", name, err)
+			}
+		}
+		revisions = revisions[:len(revisions)-1]
+	}
+
+	content, err := json.MarshalIndent(revisions, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(getRevisionsFilename(s.filename), content, 0600)
+	}
+	if err != nil {
+		logger.Errorf("Unable to write the revisions of the ACME storage: %v", err)
+		return
+	}
+
+	logger.Debugf("The revision %d of the ACME storage is written.", revision.Revision)
+}
+
+// GetRevisions returns the revisions kept of the storage file, the last one first
+func (s *LocalStore) GetRevisions() ([]*StorageRevision, error) {
+	return ReadStorageRevisions(s.filename)
+}
+
+// Rollback restores the revision in the storage file, and loads it.
+// The changes not saved yet would overwrite the restored revision, the rollback is refused until they are written.
+func (s *LocalStore) Rollback(revision int) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+	if s.health.hasPendingChanges() {
+		return errors.New("changes of the ACME storage are not saved yet")
+	}
+
+	if err := RestoreStorageRevision(s.filename, revision); err != nil {
+		return err
+	}
+
+	s.logger(storeOperationSave).Infof("The revision %d of the ACME storage %s is restored.", revision, s.filename)
+	return s.Reload()
+}
+
+// GetStorageRevisions returns the revisions kept of the storage, or nil when the store does not keep them
+func (p *Provider) GetStorageRevisions() ([]*StorageRevision, error) {
+	store, ok := unwrapStore(p.Store).(revisionStore)
+	if !ok {
+		return nil, nil
+	}
+	return store.GetRevisions()
+}
+
+// RollbackStorage restores a revision of the storage and serves its certificates, it returns false when the store does not keep revisions
+func (p *Provider) RollbackStorage(revision int) (bool, error) {
+	store, ok := unwrapStore(p.Store).(revisionStore)
+	if !ok {
+		return false, nil
+	}
+
+	if err := store.Rollback(revision); err != nil {
+		return true, err
+	}
+	return true, p.serveReloadedStorage()
+}
@@ -0,0 +1,63 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreRevisions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	store := &LocalStore{filename: filename, Revisions: 2}
+
+	account := &Account{Email: "test@traefik.wtf"}
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}
+	other := &Certificate{Domain: types.Domain{Main: "other.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}
+
+	store.write(&StoredData{Account: account})
+
+	// The changes of the challenges only are not revisions
+	store.write(&StoredData{Account: account, HTTPChallenges: map[string]map[string][]byte{"token": {"traefik.wtf": []byte("keyAuth")}}})
+
+	store.write(&StoredData{Account: account, Certificates: []*Certificate{certificate}})
+	store.write(&StoredData{Account: account, Certificates: []*Certificate{certificate, other}})
+
+	revisions, err := store.GetRevisions()
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 3, revisions[0].Revision)
+	assert.Equal(t, 2, revisions[1].Revision)
+	assert.False(t, revisions[0].CreatedAt.Before(revisions[1].CreatedAt))
+
+	// The revisions beyond the kept ones are removed
+	_, err = os.Stat(getRevisionFilename(filename, 1))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, ErrUnknownRevision, store.Rollback(1))
+
+	require.NoError(t, store.Rollback(2))
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, "traefik.wtf", certificates[0].Domain.Main)
+
+	file, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	revision, err := ioutil.ReadFile(getRevisionFilename(filename, 2))
+	require.NoError(t, err)
+	assert.Equal(t, revision, file)
+
+	// A read-only store is never rolled back
+	store.SetReadOnly(true)
+	assert.Equal(t, ErrReadOnly, store.Rollback(3))
+}
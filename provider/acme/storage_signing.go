@@ -0,0 +1,76 @@
+package acme
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// signingKeySeedSize is the size of the seed of an Ed25519 private key
+const signingKeySeedSize = 32
+
+// Reasons of the storage signature failures
+const (
+	storageSignatureMissing = "missing"
+	storageSignatureInvalid = "invalid"
+)
+
+// StorageSigning holds the Ed25519 key signing the ACME storage, to detect the payloads not written by Traefik
+type StorageSigning struct {
+	KeyFile               string `description:"File holding the Ed25519 signing key: the 32 bytes seed or the 64 bytes private key, raw or base64 encoded"`
+	AllowInvalidSignature bool   `description:"Load a storage with a missing or an invalid signature, and sign it again. Not recommended"`
+}
+
+// getSignatureFilename returns the file holding the signature of the storage file
+func getSignatureFilename(filename string) string {
+	return filename + ".sig"
+}
+
+func loadSigningKey(signing *StorageSigning) (ed25519.PrivateKey, error) {
+	if len(signing.KeyFile) == 0 {
+		return nil, errors.New("no storage signing key configured, please set a key file")
+	}
+
+	raw, err := ioutil.ReadFile(signing.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the storage signing key: %v", err)
+	}
+
+	if len(raw) != signingKeySeedSize && len(raw) != ed25519.PrivateKeySize {
+		raw, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(raw)))
+		if err != nil {
+			return nil, fmt.Errorf("the storage signing key is neither raw nor base64 encoded: %v", err)
+		}
+	}
+
+	switch len(raw) {
+	case signingKeySeedSize:
+		// The key is derived from the seed, read as the randomness of the key generation
+		_, key, err := ed25519.GenerateKey(bytes.NewReader(raw))
+		return key, err
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("the storage signing key must be %d or %d bytes long, got %d", signingKeySeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+// signStorage returns the base64 encoded signature of the storage content, as written to the storage file
+func signStorage(key ed25519.PrivateKey, data []byte) []byte {
+	signature := ed25519.Sign(key, data)
+	return []byte(base64.StdEncoding.EncodeToString(signature) + "\n")
+}
+
+// verifyStorage checks the signature of the storage content
+func verifyStorage(key ed25519.PrivateKey, data []byte, signature []byte) bool {
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil || len(decoded) != ed25519.SignatureSize {
+		return false
+	}
+
+	return ed25519.Verify(key.Public().(ed25519.PublicKey), data, decoded)
+}
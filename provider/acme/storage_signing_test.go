@@ -0,0 +1,158 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func writeTestSigningKey(t *testing.T, dir string) *StorageSigning {
+	keyFile := filepath.Join(dir, "acme-signing.key")
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, signingKeySeedSize))), 0600))
+	return &StorageSigning{KeyFile: keyFile}
+}
+
+// waitForSignedStorage waits for the storage file to be signed with the key
+func waitForSignedStorage(t *testing.T, filename string, signing *StorageSigning) {
+	key, err := loadSigningKey(signing)
+	require.NoError(t, err)
+
+	for i := 0; i < 500; i++ {
+		time.Sleep(10 * time.Millisecond)
+		data, errData := ioutil.ReadFile(filename)
+		signature, errSignature := ioutil.ReadFile(getSignatureFilename(filename))
+		if errData == nil && errSignature == nil && verifyStorage(key, data, signature) {
+			return
+		}
+	}
+	require.Fail(t, "the storage has not been signed")
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	seed := bytes.Repeat([]byte{1}, signingKeySeedSize)
+	_, privateKey, err := ed25519.GenerateKey(bytes.NewReader(seed))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc        string
+		content     []byte
+		expectedErr bool
+	}{
+		{
+			desc:    "raw seed",
+			content: seed,
+		},
+		{
+			desc:    "base64 seed",
+			content: []byte(base64.StdEncoding.EncodeToString(seed) + "\n"),
+		},
+		{
+			desc:    "base64 private key",
+			content: []byte(base64.StdEncoding.EncodeToString(privateKey)),
+		},
+		{
+			desc:        "invalid length",
+			content:     []byte(base64.StdEncoding.EncodeToString([]byte("short"))),
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			keyFile := filepath.Join(dir, test.desc)
+			require.NoError(t, ioutil.WriteFile(keyFile, test.content, 0600))
+
+			key, err := loadSigningKey(&StorageSigning{KeyFile: keyFile})
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, privateKey, key)
+		})
+	}
+}
+
+func TestLocalStoreSigning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	signing := writeTestSigningKey(t, dir)
+	filename := filepath.Join(dir, "acme.json")
+
+	store := NewLocalStore(filename)
+	store.Signing = signing
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+	waitForSignedStorage(t, filename, signing)
+
+	reloaded := NewLocalStore(filename)
+	reloaded.Signing = signing
+	certificates, err := reloaded.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	tampered := bytes.Replace(data, []byte("traefik.wtf"), []byte("evil.wtf"), 1)
+	require.NoError(t, ioutil.WriteFile(filename, tampered, 0600))
+
+	registry := newCollectingACMEMetrics()
+	rejected := NewLocalStore(filename)
+	rejected.Signing = signing
+	certificates, err = rejected.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, certificates)
+
+	rejected.SetMetricsRegistry(registry)
+	assert.Equal(t, float64(1), registry.signatures.CounterValue)
+	assert.Equal(t, []string{"reason", storageSignatureInvalid}, registry.signatures.LastLabelValues)
+
+	rejectedData, err := ioutil.ReadFile(filename + ".rejected")
+	require.NoError(t, err)
+	assert.Equal(t, tampered, rejectedData)
+
+	allowed := NewLocalStore(filename)
+	allowed.Signing = &StorageSigning{KeyFile: signing.KeyFile, AllowInvalidSignature: true}
+	certificates, err = allowed.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, "evil.wtf", certificates[0].Domain.Main)
+	waitForSignedStorage(t, filename, signing)
+}
+
+func TestLocalStoreSigningUnsignedStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "acme.json")
+	err = ioutil.WriteFile(filename, []byte(`{"Certificates":[{"Domain":{"Main":"traefik.wtf"},"Certificate":"Y2VydA==","Key":"a2V5"}]}`), 0600)
+	require.NoError(t, err)
+
+	registry := newCollectingACMEMetrics()
+	store := NewLocalStore(filename)
+	store.Signing = writeTestSigningKey(t, dir)
+	store.SetMetricsRegistry(registry)
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, certificates)
+	assert.Equal(t, float64(1), registry.signatures.CounterValue)
+	assert.Equal(t, []string{"reason", storageSignatureMissing}, registry.signatures.LastLabelValues)
+}
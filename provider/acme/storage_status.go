@@ -0,0 +1,179 @@
+package acme
+
+import (
+	"context"
+	"time"
+)
+
+// statusStore is implemented by the stores reporting their status
+type statusStore interface {
+	GetStatus() *StoreStatus
+}
+
+// StoreStatus is the status of the ACME storage
+type StoreStatus struct {
+	Backend string `json:"backend"`
+	// Target is the storage file of the file backend
+	Target             string     `json:"target"`
+	LastSuccessfulLoad *time.Time `json:"lastSuccessfulLoad,omitempty"`
+	LastSuccessfulSave *time.Time `json:"lastSuccessfulSave,omitempty"`
+	LastError          string     `json:"lastError,omitempty"`
+	// PayloadSize is the size in bytes of the storage last loaded or saved
+	PayloadSize       int `json:"payloadSize"`
+	Certificates      int `json:"certificates"`
+	PendingChallenges int `json:"pendingChallenges"`
+	// Writer is whether this instance writes the storage, a read-only storage is never written
+	Writer bool `json:"writer"`
+	// Healthy is the result of the health probe of the storage
+	Healthy bool `json:"healthy"`
+	// FailMode is the behavior when an obtained certificate can not be persisted
+	FailMode string `json:"failMode,omitempty"`
+	// Staleness is the age in seconds of the oldest change not persisted yet
+	Staleness float64 `json:"staleness"`
+	// Encryption is the encryption mode of the storage, empty when it is not encrypted
+	Encryption string `json:"encryption,omitempty"`
+	// Canary is the result of the canary check of the storage on start, if any
+	Canary *StorageCanaryResult `json:"canary,omitempty"`
+}
+
+func (h *storeHealthTracker) getStatus(backend, target string) *StoreStatus {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	status := &StoreStatus{
+		Backend:     backend,
+		Target:      target,
+		LastError:   h.lastError,
+		PayloadSize: h.payloadSize,
+	}
+	if !h.pendingSince.IsZero() {
+		status.Staleness = time.Since(h.pendingSince).Seconds()
+	}
+	if !h.lastSuccessfulLoad.IsZero() {
+		lastSuccessfulLoad := h.lastSuccessfulLoad
+		status.LastSuccessfulLoad = &lastSuccessfulLoad
+	}
+	if !h.lastSuccessfulSave.IsZero() {
+		lastSuccessfulSave := h.lastSuccessfulSave
+		status.LastSuccessfulSave = &lastSuccessfulSave
+	}
+	return status
+}
+
+// GetStatus returns the status of the storage file
+func (s *LocalStore) GetStatus() *StoreStatus {
+	status := s.health.getStatus(getStoreBackend(s), s.filename)
+	status.Writer = !s.IsReadOnly()
+	if s.Encryption != nil {
+		status.Encryption = s.Encryption.getMode()
+	}
+	status.Canary = s.getCanary()
+	return status
+}
+
+// GetStorageStatus returns the status of the storage, or nil when the store does not report it
+func (p *Provider) GetStorageStatus(ctx context.Context) (*StoreStatus, error) {
+	store, ok := unwrapStore(p.Store).(statusStore)
+	if !ok {
+		return nil, nil
+	}
+	status := store.GetStatus()
+	status.FailMode = p.getStorageFailMode()
+
+	healthCtx, cancel := context.WithTimeout(ctx, storeHealthTimeout)
+	defer cancel()
+	status.Healthy = p.Store.Health(healthCtx) == nil
+
+	// The status is read from the unwrapped store, not to count its reads as loads of the storage
+	certificates, err := unwrapStore(p.Store).GetCertificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.Certificates = len(certificates)
+
+	challenges, err := p.GetPendingChallenges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.PendingChallenges = len(challenges)
+
+	return status, nil
+}
+
+// StorageStatusReportVersion is the version of the storage status report, incremented when a field is removed or changes of meaning
+const StorageStatusReportVersion = 1
+
+// defaultResolverName is the name of the single ACME resolver in the storage status report
+const defaultResolverName = "acme"
+
+// StorageStatusReport is the status of the storage of each resolver
+type StorageStatusReport struct {
+	Version   int                               `json:"version"`
+	Resolvers map[string]*ResolverStorageStatus `json:"resolvers"`
+}
+
+// ResolverStorageStatus is the status of the storage of a resolver
+type ResolverStorageStatus struct {
+	Backend string `json:"backend"`
+	Target  string `json:"target"`
+	// Leader is whether the resolver obtains the certificates and writes the storage, it only serves them in the passive mode
+	Leader   bool                  `json:"leader"`
+	Mode     string                `json:"mode"`
+	LastLoad *StoreOperationResult `json:"lastLoad,omitempty"`
+	LastSave *StoreOperationResult `json:"lastSave,omitempty"`
+	// PayloadSize is the size in bytes of the storage last loaded or saved
+	PayloadSize       int                  `json:"payloadSize"`
+	Certificates      int                  `json:"certificates"`
+	PendingChallenges int                  `json:"pendingChallenges"`
+	Healthy           bool                 `json:"healthy"`
+	Reason            string               `json:"reason,omitempty"`
+	Degraded          bool                 `json:"degraded"`
+	Staleness         float64              `json:"staleness"`
+	ReadOnly          bool                 `json:"readOnly"`
+	Encrypted         bool                 `json:"encrypted"`
+	Encryption        string               `json:"encryption,omitempty"`
+	FailMode          string               `json:"failMode"`
+	Drift             *StorageDriftStatus  `json:"drift,omitempty"`
+	Canary            *StorageCanaryResult `json:"canary,omitempty"`
+}
+
+// GetStorageStatusReport returns the status of the storage of the resolver, or nil when the store does not report it
+func (p *Provider) GetStorageStatusReport(ctx context.Context) (*StorageStatusReport, error) {
+	status, err := p.GetStorageStatus(ctx)
+	if err != nil || status == nil {
+		return nil, err
+	}
+
+	resolver := &ResolverStorageStatus{
+		Backend:           status.Backend,
+		Target:            status.Target,
+		Leader:            p.GetMode() == modeActive,
+		Mode:              p.GetMode(),
+		PayloadSize:       status.PayloadSize,
+		Certificates:      status.Certificates,
+		PendingChallenges: status.PendingChallenges,
+		Healthy:           status.Healthy,
+		Staleness:         status.Staleness,
+		ReadOnly:          !status.Writer,
+		Encrypted:         len(status.Encryption) > 0,
+		Encryption:        status.Encryption,
+		FailMode:          status.FailMode,
+		Drift:             p.driftStatus.get(),
+		Canary:            status.Canary,
+	}
+
+	// The health is probed by the status, it also reports the failures of the last load or save
+	if store, ok := unwrapStore(p.Store).(healthStore); ok {
+		health := store.GetHealth()
+		resolver.Healthy = health.Healthy
+		resolver.LastLoad = health.LastLoad
+		resolver.LastSave = health.LastSave
+		resolver.Reason = health.Reason
+		resolver.Degraded = health.Degraded
+	}
+
+	return &StorageStatusReport{
+		Version:   StorageStatusReportVersion,
+		Resolvers: map[string]*ResolverStorageStatus{defaultResolverName: resolver},
+	}, nil
+}
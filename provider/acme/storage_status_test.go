@@ -0,0 +1,87 @@
+package acme
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderGetStorageStatus(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	provider := &Provider{Configuration: &Configuration{}, Store: store}
+
+	status, err := provider.GetStorageStatus(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "file", status.Backend)
+	assert.Equal(t, StorageFailModeServeStale, status.FailMode)
+	assert.Zero(t, status.Staleness)
+	assert.Equal(t, store.filename, status.Target)
+	assert.NotNil(t, status.LastSuccessfulLoad)
+	assert.Nil(t, status.LastSuccessfulSave)
+	assert.True(t, status.Writer)
+
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+
+	for i := 0; i < 500 && (status.LastSuccessfulSave == nil || status.PendingChallenges == 0); i++ {
+		time.Sleep(10 * time.Millisecond)
+		status, err = provider.GetStorageStatus(context.Background())
+		require.NoError(t, err)
+	}
+	require.NotNil(t, status.LastSuccessfulSave)
+	assert.Empty(t, status.LastError)
+	assert.True(t, status.PayloadSize > 0)
+	assert.Equal(t, 1, status.Certificates)
+	assert.Equal(t, 1, status.PendingChallenges)
+
+	store.SetReadOnly(true)
+	status, err = provider.GetStorageStatus(context.Background())
+	require.NoError(t, err)
+	assert.False(t, status.Writer)
+}
+
+func TestProviderGetStorageStatusReport(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	provider := &Provider{Configuration: &Configuration{StorageDrift: &StorageDrift{}}, Store: store}
+	provider.reconcileStorage()
+
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool { return len(storedData.Certificates) == 1 })
+
+	report, err := provider.GetStorageStatusReport(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, StorageStatusReportVersion, report.Version)
+	require.Len(t, report.Resolvers, 1)
+
+	status := report.Resolvers[defaultResolverName]
+	require.NotNil(t, status)
+	assert.Equal(t, "file", status.Backend)
+	assert.Equal(t, store.filename, status.Target)
+	assert.True(t, status.Leader)
+	assert.Equal(t, modeActive, status.Mode)
+	assert.NotNil(t, status.LastLoad)
+	assert.Equal(t, 1, status.Certificates)
+	assert.True(t, status.Healthy)
+	assert.False(t, status.ReadOnly)
+	assert.False(t, status.Encrypted)
+	assert.Equal(t, StorageFailModeServeStale, status.FailMode)
+	require.NotNil(t, status.Drift)
+	assert.Equal(t, storageDriftPolicyAlert, status.Drift.Policy)
+	assert.False(t, status.Drift.Drifted)
+
+	store.SetReadOnly(true)
+	report, err = provider.GetStorageStatusReport(context.Background())
+	require.NoError(t, err)
+	status = report.Resolvers[defaultResolverName]
+	assert.False(t, status.Leader)
+	assert.Equal(t, modePassive, status.Mode)
+	assert.True(t, status.ReadOnly)
+}
@@ -0,0 +1,57 @@
+package acme
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+// payloadHash is the hash of the payload last written, before its encryption
+type payloadHash struct {
+	lock    sync.Mutex
+	sum     [sha256.Size]byte
+	written bool
+}
+
+func (h *payloadHash) set(sum [sha256.Size]byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.sum = sum
+	h.written = true
+}
+
+func (h *payloadHash) matches(sum [sha256.Size]byte) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.written && h.sum == sum
+}
+
+// reset forgets the payload last written, the storage file holding other data since
+func (h *payloadHash) reset() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.written = false
+}
+
+// isPayloadWritten returns whether the payload is the one last written. The payload is hashed before its encryption,
+// the encryption is not deterministic. The maps are encoded in the order of their keys, the same data is always encoded the same way.
+// The payload is forgotten once the storage file is found changed by others, the file is then written again.
+func (s *LocalStore) isPayloadWritten(sum [sha256.Size]byte) bool {
+	return s.payload.matches(sum)
+}
+
+// skipWrite records the save of a payload already written, which is not written again
+func (s *LocalStore) skipWrite() {
+	s.logger(storeOperationSave).Debug("The ACME storage already holds the data saved, it is not written again.")
+	s.health.saved(nil)
+
+	s.metricsLock.Lock()
+	registry := s.metricsRegistry
+	s.metricsLock.Unlock()
+
+	if registry != nil {
+		registry.ACMEStoreSkippedWritesCounter().With("backend", getStoreBackend(s)).Add(1)
+	}
+}
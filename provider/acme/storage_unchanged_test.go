@@ -0,0 +1,104 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreSkipsUnchangedWrites(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		encryption string
+	}{
+		{
+			desc: "plaintext",
+		},
+		{
+			desc:       "full encryption",
+			encryption: storageEncryptionModeFull,
+		},
+		{
+			desc:       "keys encryption",
+			encryption: storageEncryptionModeKeys,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			store, clean := newTestLocalStore(t)
+			defer clean()
+
+			registry := newCollectingACMEMetrics()
+			store.SetMetricsRegistry(registry)
+			if len(test.encryption) > 0 {
+				store.Encryption = writeTestStorageKey(t, filepath.Dir(store.filename), "acme.key", bytes.Repeat([]byte{1}, 32))
+				store.Encryption.Mode = test.encryption
+			}
+
+			var writes int32
+			store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+				atomic.AddInt32(&writes, 1)
+				return ioutil.WriteFile(filename, data, perm)
+			}
+
+			certificates := []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}
+			require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+			require.NoError(t, store.WaitPersisted(context.Background()))
+			assert.Equal(t, int32(1), atomic.LoadInt32(&writes))
+
+			// The same data is not written again
+			require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+			require.NoError(t, store.WaitPersisted(context.Background()))
+			assert.Equal(t, int32(1), atomic.LoadInt32(&writes))
+			assert.Equal(t, float64(1), registry.skipped.CounterValue)
+			assert.False(t, store.health.hasPendingChanges())
+
+			// A storage file found changed by others is written again
+			require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{}`), 0600))
+			_, err := store.ReadStorage()
+			require.NoError(t, err)
+			require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+			require.NoError(t, store.WaitPersisted(context.Background()))
+			assert.Equal(t, int32(2), atomic.LoadInt32(&writes))
+
+			// Changed data is written
+			certificates = append(certificates, &Certificate{Domain: types.Domain{Main: "other.wtf"}, Certificate: []byte("cert"), Key: []byte("key")})
+			require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+			require.NoError(t, store.WaitPersisted(context.Background()))
+			assert.Equal(t, int32(3), atomic.LoadInt32(&writes))
+			assert.Equal(t, float64(1), registry.skipped.CounterValue)
+		})
+	}
+}
+
+func TestGetRevisionDigest(t *testing.T) {
+	certificates := []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}
+
+	first, err := json.Marshal(&StoredData{Certificates: certificates, HTTPChallenges: map[string]map[string][]byte{"a": {"traefik.wtf": []byte("1")}}})
+	require.NoError(t, err)
+	firstDigest, err := getRevisionDigest(first)
+	require.NoError(t, err)
+
+	// The changes of the challenges only are not revisions
+	second, err := json.Marshal(&StoredData{Certificates: certificates})
+	require.NoError(t, err)
+	secondDigest, err := getRevisionDigest(second)
+	require.NoError(t, err)
+	assert.Equal(t, firstDigest, secondDigest)
+
+	third, err := json.Marshal(&StoredData{Certificates: append(certificates, &Certificate{Domain: types.Domain{Main: "other.wtf"}})})
+	require.NoError(t, err)
+	thirdDigest, err := getRevisionDigest(third)
+	require.NoError(t, err)
+	assert.NotEqual(t, firstDigest, thirdDigest)
+}
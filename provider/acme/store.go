@@ -1,25 +1,83 @@
 package acme
 
+import (
+	"context"
+	"time"
+)
+
 // StoredData represents the data managed by the Store
 type StoredData struct {
-	Account        *Account
-	Certificates   []*Certificate
-	HTTPChallenges map[string]map[string][]byte
-	TLSChallenges  map[string]*Certificate
+	Account                 *Account
+	StagingAccount          *Account `json:",omitempty"`
+	Certificates            []*Certificate
+	HTTPChallenges          map[string]map[string][]byte
+	HTTPChallengesCreatedAt map[string]map[string]time.Time `json:",omitempty"`
+	TLSChallenges           map[string]*Certificate
+	TLSChallengesCreatedAt  map[string]time.Time          `json:",omitempty"`
+	DNSChallenges           map[string]*DNSChallengeState `json:",omitempty"`
+	OnDemandQueue           map[string]*OnDemandRequest   `json:",omitempty"`
+	DesiredDomains          map[string]*DesiredDomain     `json:",omitempty"`
+	OrderHistory            *OrderHistory                 `json:",omitempty"`
+	Canary                  *StorageCanaryEntry           `json:",omitempty"`
+	MirrorNamespaces        []string                      `json:",omitempty"`
+	DefaultCertificate      *DefaultCertificate           `json:",omitempty"`
+	KeysEncryption          *keysEncryption               `json:",omitempty"`
+}
+
+// PendingHTTPChallenge represents an HTTP-01 challenge token stored for a domain
+type PendingHTTPChallenge struct {
+	Token     string
+	Domain    string
+	KeyAuth   []byte
+	CreatedAt time.Time
 }
 
-// Store is a generic interface to represents a storage
+// Store is a generic interface to represents a storage, its errors are described in store_errors.go.
+// The context bounds the operations of the backend which may block, a call waiting for them returns the error of the done context.
 type Store interface {
-	GetAccount() (*Account, error)
-	SaveAccount(*Account) error
-	GetCertificates() ([]*Certificate, error)
-	SaveCertificates([]*Certificate) error
-
-	GetHTTPChallengeToken(token, domain string) ([]byte, error)
-	SetHTTPChallengeToken(token, domain string, keyAuth []byte) error
-	RemoveHTTPChallengeToken(token, domain string) error
-
-	AddTLSChallenge(domain string, cert *Certificate) error
-	GetTLSChallenge(domain string) (*Certificate, error)
-	RemoveTLSChallenge(domain string) error
+	GetAccount(ctx context.Context) (*Account, error)
+	SaveAccount(ctx context.Context, account *Account) error
+	GetCertificates(ctx context.Context) ([]*Certificate, error)
+	SaveCertificates(ctx context.Context, certificates []*Certificate) error
+	GetCertificateByDomain(ctx context.Context, domain string) (*Certificate, error)
+
+	GetHTTPChallengeToken(ctx context.Context, token, domain string) ([]byte, error)
+	GetHTTPChallenges(ctx context.Context) ([]*PendingHTTPChallenge, error)
+	SetHTTPChallengeToken(ctx context.Context, token, domain string, keyAuth []byte) error
+	RemoveHTTPChallengeToken(ctx context.Context, token, domain string) error
+	RemoveExpiredHTTPChallengeTokens(ctx context.Context, ttl time.Duration) (int, error)
+	RemoveHTTPChallengeTokensForDomain(ctx context.Context, domain string) (int, error)
+
+	AddTLSChallenge(ctx context.Context, domain string, cert *Certificate) error
+	GetTLSChallenge(ctx context.Context, domain string) (*Certificate, error)
+	GetTLSChallenges(ctx context.Context) (map[string]*Certificate, error)
+	GetTLSChallengesCreatedAt(ctx context.Context) (map[string]time.Time, error)
+	RemoveTLSChallenge(ctx context.Context, domain string) error
+
+	AddDNSChallenge(ctx context.Context, token string, state *DNSChallengeState) error
+	GetDNSChallenges(ctx context.Context) (map[string]*DNSChallengeState, error)
+	RemoveDNSChallenge(ctx context.Context, token string) error
+
+	AddOnDemandRequest(ctx context.Context, domain string, requestedAt time.Time) error
+	GetOnDemandQueue(ctx context.Context) (map[string]*OnDemandRequest, error)
+	RemoveOnDemandRequest(ctx context.Context, domain string) error
+	RemoveExpiredOnDemandRequests(ctx context.Context, ttl time.Duration) (int, error)
+
+	GetStagingAccount(ctx context.Context) (*Account, error)
+
+	// Update applies a mutation to the data, and saves the resulting data at once
+	Update(ctx context.Context, update func(data *StoredData) error) error
+
+	// Subscribe returns a channel notified of the changes of the certificates, and the function removing the subscription.
+	// The subscription is also removed when the context is done.
+	Subscribe(ctx context.Context) (<-chan StoreChange, func())
+
+	// Capabilities returns the abilities of the backend
+	Capabilities() StoreCapabilities
+
+	// Health checks that the storage can be reached and written before the context is done
+	Health(ctx context.Context) error
+
+	// Close stops the store once its pending saves are written
+	Close(ctx context.Context) error
 }
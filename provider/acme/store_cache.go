@@ -0,0 +1,149 @@
+package acme
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/safe"
+)
+
+var _ Store = (*cachingStore)(nil)
+
+// cachingStore caches the account and the certificates read from the wrapped Store for a duration.
+// The cache is dropped by the saves of the account and the certificates, and by the changes notified by the wrapped store.
+type cachingStore struct {
+	Store
+	ttl time.Duration
+
+	// generation counts the invalidations, not to cache a read started before the last one
+	lock               sync.Mutex
+	generation         uint64
+	account            *Account
+	accountExpiry      time.Time
+	certificates       []*Certificate
+	certificatesExpiry time.Time
+}
+
+func newCachingStore(store Store, ttl time.Duration) *cachingStore {
+	return &cachingStore{Store: store, ttl: ttl}
+}
+
+func (s *cachingStore) unwrap() Store {
+	return s.Store
+}
+
+// invalidate drops the cached account and certificates
+func (s *cachingStore) invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.generation++
+	s.account = nil
+	s.accountExpiry = time.Time{}
+	s.certificates = nil
+	s.certificatesExpiry = time.Time{}
+}
+
+// invalidateStoreCache drops the cache of the store, when it is wrapped with a cache
+func invalidateStoreCache(store Store) {
+	for {
+		if s, ok := store.(*cachingStore); ok {
+			s.invalidate()
+		}
+
+		layer, ok := store.(storeLayer)
+		if !ok {
+			return
+		}
+		store = layer.unwrap()
+	}
+}
+
+// GetAccount returns a copy of the cached account, or the account of the wrapped store
+func (s *cachingStore) GetAccount(ctx context.Context) (*Account, error) {
+	s.lock.Lock()
+	if time.Now().Before(s.accountExpiry) {
+		account := copyAccount(s.account)
+		s.lock.Unlock()
+		return account, nil
+	}
+	generation := s.generation
+	s.lock.Unlock()
+
+	account, err := s.Store.GetAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	if generation == s.generation {
+		s.account = copyAccount(account)
+		s.accountExpiry = time.Now().Add(s.ttl)
+	}
+	s.lock.Unlock()
+
+	return account, nil
+}
+
+// SaveAccount saves the account in the wrapped store, and drops the cache
+func (s *cachingStore) SaveAccount(ctx context.Context, account *Account) error {
+	defer s.invalidate()
+	return s.Store.SaveAccount(ctx, account)
+}
+
+// GetCertificates returns copies of the cached certificates, or the certificates of the wrapped store
+func (s *cachingStore) GetCertificates(ctx context.Context) ([]*Certificate, error) {
+	s.lock.Lock()
+	if time.Now().Before(s.certificatesExpiry) {
+		certificates := copyCertificates(s.certificates)
+		s.lock.Unlock()
+		return certificates, nil
+	}
+	generation := s.generation
+	s.lock.Unlock()
+
+	certificates, err := s.Store.GetCertificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	if generation == s.generation {
+		s.certificates = copyCertificates(certificates)
+		s.certificatesExpiry = time.Now().Add(s.ttl)
+	}
+	s.lock.Unlock()
+
+	return certificates, nil
+}
+
+// SaveCertificates saves the certificates in the wrapped store, and drops the cache
+func (s *cachingStore) SaveCertificates(ctx context.Context, certificates []*Certificate) error {
+	defer s.invalidate()
+	return s.Store.SaveCertificates(ctx, certificates)
+}
+
+// Update applies the mutation in the wrapped store, and drops the cache
+func (s *cachingStore) Update(ctx context.Context, update func(data *StoredData) error) error {
+	defer s.invalidate()
+	return s.Store.Update(ctx, update)
+}
+
+// Subscribe subscribes to the changes of the wrapped store, the cache is dropped before each change is notified
+func (s *cachingStore) Subscribe(ctx context.Context) (<-chan StoreChange, func()) {
+	changes, unsubscribe := s.Store.Subscribe(ctx)
+
+	subscriber := &storeSubscriber{changes: make(chan StoreChange, 1)}
+	safe.Go(func() {
+		defer close(subscriber.changes)
+
+		// The changes are only sent by this routine
+		for change := range changes {
+			s.invalidate()
+			subscriber.notify(change)
+		}
+	})
+
+	return subscriber.changes, unsubscribe
+}
@@ -0,0 +1,59 @@
+package acme
+
+import "fmt"
+
+// StoreCapabilities are the abilities of a Store backend, which the provider adapts to
+type StoreCapabilities struct {
+	// SupportsWatch is true when the changes made by every writer, including the ones of other hosts, are notified to the subscribers
+	SupportsWatch bool
+	// SupportsLocking is true when the backend locks its data against the writers of other instances
+	SupportsLocking bool
+	// SupportsTransactions is true when Update applies its mutation atomically
+	SupportsTransactions bool
+	// SafeForMultipleWriters is true when several instances can write the storage at the same time without losing changes
+	SafeForMultipleWriters bool
+}
+
+// Capabilities returns the abilities of the file storage. The storage is watched, but the writes of other hosts
+// sharing the file through a network file system are not seen; Update is atomic within this instance only.
+func (s *LocalStore) Capabilities() StoreCapabilities {
+	return StoreCapabilities{
+		SupportsTransactions: true,
+	}
+}
+
+// getStoreCapabilityWarnings returns the warnings about the configuration of the provider the store is not able to support
+func (p *Provider) getStoreCapabilityWarnings() []string {
+	capabilities := p.Store.Capabilities()
+	backend := getStoreBackend(p.Store)
+
+	var warnings []string
+
+	// The storage is shared when the changes of the other instances are reloaded or checked
+	shared := p.StoragePollInterval > 0 || p.StorageDrift != nil
+	if shared && !p.ReadOnly && !capabilities.SafeForMultipleWriters {
+		warnings = append(warnings, fmt.Sprintf("multiple Traefik replicas detected but storage backend '%s' is not safe for concurrent writers: only one instance should write the storage, the others should be read-only", backend))
+	}
+
+	return warnings
+}
+
+// checkStoreCapabilities logs the warnings about the configuration of the provider the store is not able to support
+func (p *Provider) checkStoreCapabilities() {
+	for _, warning := range p.getStoreCapabilityWarnings() {
+		logger().Warnf("The ACME storage may lose changes: %s.", warning)
+	}
+}
+
+// needsStoragePoll returns whether the storage must be polled for the changes of others, which a watch of the store does not notify
+func (p *Provider) needsStoragePoll() bool {
+	if p.StoragePollInterval <= 0 {
+		return false
+	}
+
+	if p.Store.Capabilities().SupportsWatch {
+		logger().Infof("The ACME storage backend '%s' notifies the changes of every writer, the storage is not polled.", getStoreBackend(p.Store))
+		return false
+	}
+	return true
+}
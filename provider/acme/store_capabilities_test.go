@@ -0,0 +1,100 @@
+package acme
+
+import (
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+type watchedStore struct {
+	*LocalStore
+}
+
+func (s *watchedStore) Capabilities() StoreCapabilities {
+	return StoreCapabilities{SupportsWatch: true, SupportsLocking: true, SupportsTransactions: true, SafeForMultipleWriters: true}
+}
+
+func TestProviderGetStoreCapabilityWarnings(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		configuration *Configuration
+		store         Store
+		expected      []string
+	}{
+		{
+			desc:          "single instance",
+			configuration: &Configuration{},
+			store:         &LocalStore{},
+		},
+		{
+			desc:          "polled storage",
+			configuration: &Configuration{StoragePollInterval: parse.Duration(time.Minute)},
+			store:         &LocalStore{},
+			expected:      []string{"multiple Traefik replicas detected but storage backend 'file' is not safe for concurrent writers: only one instance should write the storage, the others should be read-only"},
+		},
+		{
+			desc:          "drift check",
+			configuration: &Configuration{StorageDrift: &StorageDrift{}},
+			store:         WrapStore(&LocalStore{}, StoreWrapOptions{}),
+			expected:      []string{"multiple Traefik replicas detected but storage backend 'file' is not safe for concurrent writers: only one instance should write the storage, the others should be read-only"},
+		},
+		{
+			desc:          "read-only replica",
+			configuration: &Configuration{StoragePollInterval: parse.Duration(time.Minute), ReadOnly: true},
+			store:         &LocalStore{},
+		},
+		{
+			desc:          "backend safe for concurrent writers",
+			configuration: &Configuration{StoragePollInterval: parse.Duration(time.Minute)},
+			store:         &watchedStore{LocalStore: &LocalStore{}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{Configuration: test.configuration, Store: test.store}
+			assert.Equal(t, test.expected, provider.getStoreCapabilityWarnings())
+		})
+	}
+}
+
+func TestProviderNeedsStoragePoll(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		configuration *Configuration
+		store         Store
+		expected      bool
+	}{
+		{
+			desc:          "no poll",
+			configuration: &Configuration{},
+			store:         &LocalStore{},
+		},
+		{
+			desc:          "file storage",
+			configuration: &Configuration{StoragePollInterval: parse.Duration(time.Minute)},
+			store:         &LocalStore{},
+			expected:      true,
+		},
+		{
+			desc:          "watched storage",
+			configuration: &Configuration{StoragePollInterval: parse.Duration(time.Minute)},
+			store:         &watchedStore{LocalStore: &LocalStore{}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{Configuration: test.configuration, Store: test.store}
+			assert.Equal(t, test.expected, provider.needsStoragePoll())
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package acme
+
+import (
+	"context"
+	"errors"
+)
+
+// The errors of the Store implementations follow the rules below, the callers rely on them:
+//  - GetCertificateByDomain, GetHTTPChallengeToken and GetTLSChallenge return ErrNotFound when nothing matches,
+//    they never return a nil value without error
+//  - GetAccount returns a nil account without error when no account has been saved yet
+//  - the getters of collections return an empty collection without error when there is no data
+//  - the mutations of a read-only store return ErrReadOnly, without attempting the mutation
+//  - the other errors are failures of the backend, retriable unless IsRetriable reports otherwise
+
+// ErrNotFound is returned by the Store when no certificate matches the requested domain,
+// or when the requested challenge is no longer valid
+var ErrNotFound = errors.New("not found")
+
+// ErrReadOnly is returned by the mutating methods of a read-only Store
+var ErrReadOnly = errors.New("the ACME storage is read-only")
+
+// temporary is implemented by the errors telling whether they are temporary, as the net errors
+type temporary interface {
+	Temporary() bool
+}
+
+// IsRetriable returns whether the error of a Store may go away by calling it again.
+// ErrNotFound, ErrReadOnly and the errors of a done context are final, as the errors reporting they are not temporary.
+func IsRetriable(err error) bool {
+	switch err {
+	case nil, ErrNotFound, ErrReadOnly, context.Canceled, context.DeadlineExceeded:
+		return false
+	}
+
+	if tempErr, ok := err.(temporary); ok {
+		return tempErr.Temporary()
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetriable(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		err      error
+		expected bool
+	}{
+		{
+			desc: "no error",
+		},
+		{
+			desc: "not found",
+			err:  ErrNotFound,
+		},
+		{
+			desc: "read-only",
+			err:  ErrReadOnly,
+		},
+		{
+			desc: "done context",
+			err:  context.DeadlineExceeded,
+		},
+		{
+			desc:     "failure of the backend",
+			err:      errors.New("storage unavailable"),
+			expected: true,
+		},
+		{
+			desc:     "temporary error",
+			err:      &net.DNSError{IsTemporary: true},
+			expected: true,
+		},
+		{
+			desc: "permanent error",
+			err:  &net.DNSError{IsTemporary: false},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, IsRetriable(test.err))
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+	"github.com/containous/traefik/safe"
+)
+
+const (
+	storeOperationLoad             = "load"
+	storeOperationSave             = "save"
+	storeOperationMigrate          = "migrate"
+	storeOperationCheckPermissions = "checkPermissions"
+	storeOperationProbe            = "probe"
+	storeOperationCanary           = "canary"
+
+	// storePayloadStageSerialized is the stage of the storage payload as written by the backend,
+	// the size of the storage file for the file backend
+	storePayloadStageSerialized = "serialized"
+
+	// storeLastSaveRefreshInterval is the interval between two updates of the time elapsed since the last successful save
+	storeLastSaveRefreshInterval = 10 * time.Second
+)
+
+var _ Store = (*instrumentedStore)(nil)
+
+// instrumentedStore reports the loads and the saves of the wrapped Store
+type instrumentedStore struct {
+	Store
+	backend  string
+	registry metrics.Registry
+
+	lastSaveLock sync.RWMutex
+	lastSave     time.Time
+}
+
+// newInstrumentedStore wraps the store to report its operations,
+// the time elapsed since the last successful save is counted from now until the first one
+func newInstrumentedStore(store Store, registry metrics.Registry) *instrumentedStore {
+	s := &instrumentedStore{
+		Store:    store,
+		backend:  getStoreBackend(store),
+		registry: registry,
+		lastSave: time.Now(),
+	}
+
+	safe.Go(func() {
+		ticker := time.NewTicker(storeLastSaveRefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.updateLastSave()
+		}
+	})
+
+	return s
+}
+
+func (s *instrumentedStore) unwrap() Store {
+	return s.Store
+}
+
+func getStoreBackend(store Store) string {
+	switch store := unwrapStore(store).(type) {
+	case *LocalStore:
+		return "file"
+	default:
+		return fmt.Sprintf("%T", store)
+	}
+}
+
+func (s *instrumentedStore) observeLoad(err error) {
+	s.count(storeOperationLoad, err)
+}
+
+func (s *instrumentedStore) observeSave(start time.Time, err error) {
+	// The saves refused by a read-only store are not attempted
+	if err == ErrReadOnly {
+		return
+	}
+
+	s.count(storeOperationSave, err)
+	s.registry.ACMEStoreSaveDurationHistogram().With("backend", s.backend).Observe(time.Since(start).Seconds())
+
+	if err == nil {
+		s.lastSaveLock.Lock()
+		s.lastSave = time.Now()
+		s.lastSaveLock.Unlock()
+		s.updateLastSave()
+	}
+}
+
+func (s *instrumentedStore) count(operation string, err error) {
+	s.registry.ACMEStoreOperationsCounter().With("backend", s.backend, "operation", operation).Add(1)
+	if err != nil {
+		s.registry.ACMEStoreFailuresCounter().With("backend", s.backend, "operation", operation).Add(1)
+	}
+}
+
+func (s *instrumentedStore) updateLastSave() {
+	s.lastSaveLock.RLock()
+	elapsed := time.Since(s.lastSave)
+	s.lastSaveLock.RUnlock()
+
+	s.registry.ACMEStoreLastSaveGauge().With("backend", s.backend).Set(elapsed.Seconds())
+}
+
+// GetAccount returns the account of the wrapped store
+func (s *instrumentedStore) GetAccount(ctx context.Context) (*Account, error) {
+	account, err := s.Store.GetAccount(ctx)
+	s.observeLoad(err)
+	return account, err
+}
+
+// SaveAccount saves the account in the wrapped store
+func (s *instrumentedStore) SaveAccount(ctx context.Context, account *Account) error {
+	start := time.Now()
+	err := s.Store.SaveAccount(ctx, account)
+	s.observeSave(start, err)
+	return err
+}
+
+// GetCertificates returns the certificates of the wrapped store
+func (s *instrumentedStore) GetCertificates(ctx context.Context) ([]*Certificate, error) {
+	certificates, err := s.Store.GetCertificates(ctx)
+	s.observeLoad(err)
+	return certificates, err
+}
+
+// SaveCertificates saves the certificates in the wrapped store
+func (s *instrumentedStore) SaveCertificates(ctx context.Context, certificates []*Certificate) error {
+	start := time.Now()
+	err := s.Store.SaveCertificates(ctx, certificates)
+	s.observeSave(start, err)
+	return err
+}
+
+// SetHTTPChallengeToken saves the HTTP challenge token in the wrapped store
+func (s *instrumentedStore) SetHTTPChallengeToken(ctx context.Context, token, domain string, keyAuth []byte) error {
+	start := time.Now()
+	err := s.Store.SetHTTPChallengeToken(ctx, token, domain, keyAuth)
+	s.observeSave(start, err)
+	return err
+}
+
+// RemoveHTTPChallengeToken removes the HTTP challenge token from the wrapped store
+func (s *instrumentedStore) RemoveHTTPChallengeToken(ctx context.Context, token, domain string) error {
+	start := time.Now()
+	err := s.Store.RemoveHTTPChallengeToken(ctx, token, domain)
+	s.observeSave(start, err)
+	return err
+}
+
+// RemoveExpiredHTTPChallengeTokens removes the expired HTTP challenge tokens from the wrapped store
+func (s *instrumentedStore) RemoveExpiredHTTPChallengeTokens(ctx context.Context, ttl time.Duration) (int, error) {
+	start := time.Now()
+	removed, err := s.Store.RemoveExpiredHTTPChallengeTokens(ctx, ttl)
+	if removed > 0 || err != nil {
+		s.observeSave(start, err)
+	}
+	return removed, err
+}
+
+// RemoveHTTPChallengeTokensForDomain removes the HTTP challenge tokens of the domain from the wrapped store
+func (s *instrumentedStore) RemoveHTTPChallengeTokensForDomain(ctx context.Context, domain string) (int, error) {
+	start := time.Now()
+	removed, err := s.Store.RemoveHTTPChallengeTokensForDomain(ctx, domain)
+	if removed > 0 || err != nil {
+		s.observeSave(start, err)
+	}
+	return removed, err
+}
+
+// AddTLSChallenge saves the TLS challenge in the wrapped store
+func (s *instrumentedStore) AddTLSChallenge(ctx context.Context, domain string, cert *Certificate) error {
+	start := time.Now()
+	err := s.Store.AddTLSChallenge(ctx, domain, cert)
+	s.observeSave(start, err)
+	return err
+}
+
+// RemoveTLSChallenge removes the TLS challenge from the wrapped store
+func (s *instrumentedStore) RemoveTLSChallenge(ctx context.Context, domain string) error {
+	start := time.Now()
+	err := s.Store.RemoveTLSChallenge(ctx, domain)
+	s.observeSave(start, err)
+	return err
+}
+
+// AddDNSChallenge saves the DNS challenge in the wrapped store
+func (s *instrumentedStore) AddDNSChallenge(ctx context.Context, token string, state *DNSChallengeState) error {
+	start := time.Now()
+	err := s.Store.AddDNSChallenge(ctx, token, state)
+	s.observeSave(start, err)
+	return err
+}
+
+// RemoveDNSChallenge removes the DNS challenge from the wrapped store
+func (s *instrumentedStore) RemoveDNSChallenge(ctx context.Context, token string) error {
+	start := time.Now()
+	err := s.Store.RemoveDNSChallenge(ctx, token)
+	s.observeSave(start, err)
+	return err
+}
+
+// AddOnDemandRequest saves the on demand request in the wrapped store
+func (s *instrumentedStore) AddOnDemandRequest(ctx context.Context, domain string, requestedAt time.Time) error {
+	start := time.Now()
+	err := s.Store.AddOnDemandRequest(ctx, domain, requestedAt)
+	s.observeSave(start, err)
+	return err
+}
+
+// RemoveOnDemandRequest removes the on demand request from the wrapped store
+func (s *instrumentedStore) RemoveOnDemandRequest(ctx context.Context, domain string) error {
+	start := time.Now()
+	err := s.Store.RemoveOnDemandRequest(ctx, domain)
+	s.observeSave(start, err)
+	return err
+}
+
+// RemoveExpiredOnDemandRequests removes the expired on demand requests from the wrapped store
+func (s *instrumentedStore) RemoveExpiredOnDemandRequests(ctx context.Context, ttl time.Duration) (int, error) {
+	start := time.Now()
+	removed, err := s.Store.RemoveExpiredOnDemandRequests(ctx, ttl)
+	if removed > 0 || err != nil {
+		s.observeSave(start, err)
+	}
+	return removed, err
+}
+
+// Update applies the mutation in the wrapped store
+func (s *instrumentedStore) Update(ctx context.Context, update func(data *StoredData) error) error {
+	start := time.Now()
+	err := s.Store.Update(ctx, update)
+	s.observeSave(start, err)
+	return err
+}
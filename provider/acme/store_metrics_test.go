@@ -0,0 +1,99 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct {
+	*LocalStore
+}
+
+func (s *failingStore) SaveAccount(ctx context.Context, account *Account) error {
+	return errors.New("unable to save the account")
+}
+
+func TestInstrumentedStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	registry := newCollectingACMEMetrics()
+	localStore := NewLocalStore(filepath.Join(dir, "acme.json"))
+	store := newInstrumentedStore(&failingStore{LocalStore: localStore}, registry)
+
+	_, err = store.GetAccount(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), registry.operations.CounterValue)
+	assert.Equal(t, []string{"backend", "*acme.failingStore", "operation", storeOperationLoad}, registry.operations.LastLabelValues)
+
+	certificates := []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")}}
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+	assert.Equal(t, float64(2), registry.operations.CounterValue)
+	assert.Equal(t, []string{"backend", "*acme.failingStore", "operation", storeOperationSave}, registry.operations.LastLabelValues)
+	assert.Equal(t, float64(0), registry.failures.CounterValue)
+	assert.Equal(t, 1, registry.durations.ObservationsCount)
+	assert.InDelta(t, 0, registry.lastSave.GaugeValue, 1)
+
+	assert.Error(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	assert.Equal(t, float64(3), registry.operations.CounterValue)
+	assert.Equal(t, float64(1), registry.failures.CounterValue)
+	assert.Equal(t, []string{"backend", "*acme.failingStore", "operation", storeOperationSave}, registry.failures.LastLabelValues)
+	assert.Equal(t, 2, registry.durations.ObservationsCount)
+
+	// The saves refused by a read-only store are not counted
+	localStore.SetReadOnly(true)
+	assert.Equal(t, ErrReadOnly, store.SaveCertificates(context.Background(), certificates))
+	assert.Equal(t, float64(3), registry.operations.CounterValue)
+	assert.Equal(t, float64(1), registry.failures.CounterValue)
+}
+
+func TestProviderSetMetricsRegistry(t *testing.T) {
+	store := &LocalStore{storedData: &StoredData{}}
+	p := &Provider{Configuration: &Configuration{}, Store: store}
+
+	p.SetMetricsRegistry(newCollectingACMEMetrics())
+	require.IsType(t, &instrumentedStore{}, p.Store)
+	assert.Equal(t, "file", p.Store.(*instrumentedStore).backend)
+
+	// The store is wrapped once, and its optional interfaces are still used
+	p.SetMetricsRegistry(newCollectingACMEMetrics())
+	require.IsType(t, &instrumentedStore{}, p.Store)
+	assert.Equal(t, store, unwrapStore(p.Store))
+
+	p.SetReadOnly(true)
+	assert.True(t, store.IsReadOnly())
+	assert.Equal(t, modePassive, p.GetMode())
+}
+
+func TestLocalStorePayloadSize(t *testing.T) {
+	registry := newCollectingACMEMetrics()
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+	store.SetMetricsRegistry(registry)
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoreHealth(t, store, func(health *StoreHealth) bool { return health.LastSave != nil })
+
+	file, err := ioutil.ReadFile(store.filename)
+	require.NoError(t, err)
+	assert.Equal(t, float64(len(file)), registry.payload.GaugeValue)
+	assert.Equal(t, []string{"backend", "file", "stage", storePayloadStageSerialized}, registry.payload.LastLabelValues)
+	assert.Equal(t, float64(0), registry.rejected.CounterValue)
+}
+
+func TestIsFileTooLarge(t *testing.T) {
+	assert.True(t, isFileTooLarge(&os.PathError{Op: "write", Path: "acme.json", Err: syscall.EFBIG}))
+	assert.False(t, isFileTooLarge(&os.PathError{Op: "open", Path: "acme.json", Err: syscall.EACCES}))
+	assert.False(t, isFileTooLarge(errors.New("unable to write")))
+}
@@ -0,0 +1,280 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	"gopkg.in/fsnotify.v1"
+)
+
+// storageWatchDelay is the delay between the last change of the storage file and its reload,
+// not to read the file while it is written
+var storageWatchDelay = 100 * time.Millisecond
+
+// StoreChange notifies the change of the certificates of the store
+type StoreChange struct {
+	// Domains are the main domains of the certificates added, changed or removed
+	Domains []string
+}
+
+// storeSubscriber receives the changes of the store. Its channel holds a single change,
+// the changes not received yet are merged so that a slow subscriber never blocks the store.
+type storeSubscriber struct {
+	changes chan StoreChange
+}
+
+func (s *storeSubscriber) notify(change StoreChange) {
+	select {
+	case s.changes <- change:
+		return
+	default:
+	}
+
+	select {
+	case previous := <-s.changes:
+		change = mergeStoreChanges(previous, change)
+	default:
+	}
+
+	// The changes are sent by a single sender at a time, the channel is empty
+	s.changes <- change
+}
+
+// storeSubscriptions are the subscribers to the changes of a store, the zero value has no subscriber
+type storeSubscriptions struct {
+	lock        sync.Mutex
+	subscribers map[*storeSubscriber]struct{}
+	closed      bool
+}
+
+// add returns a new subscriber and the number of subscribers, a closed subscriptions only returns closed channels
+func (s *storeSubscriptions) add() (*storeSubscriber, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	subscriber := &storeSubscriber{changes: make(chan StoreChange, 1)}
+	if s.closed {
+		close(subscriber.changes)
+		return subscriber, len(s.subscribers)
+	}
+
+	if s.subscribers == nil {
+		s.subscribers = make(map[*storeSubscriber]struct{})
+	}
+	s.subscribers[subscriber] = struct{}{}
+	return subscriber, len(s.subscribers)
+}
+
+// remove removes the subscriber, closing its channel, and returns the number of the remaining subscribers
+func (s *storeSubscriptions) remove(subscriber *storeSubscriber) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.subscribers[subscriber]; ok {
+		delete(s.subscribers, subscriber)
+		close(subscriber.changes)
+	}
+	return len(s.subscribers)
+}
+
+func (s *storeSubscriptions) notify(domains []string) {
+	if len(domains) == 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for subscriber := range s.subscribers {
+		subscriber.notify(StoreChange{Domains: domains})
+	}
+}
+
+// close closes the channels of the subscribers
+func (s *storeSubscriptions) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for subscriber := range s.subscribers {
+		close(subscriber.changes)
+	}
+	s.subscribers = nil
+	s.closed = true
+}
+
+// mergeStoreChanges returns a change with the domains of both changes
+func mergeStoreChanges(a, b StoreChange) StoreChange {
+	domains := make(map[string]struct{})
+	for _, domain := range append(a.Domains, b.Domains...) {
+		domains[domain] = struct{}{}
+	}
+
+	merged := StoreChange{}
+	for domain := range domains {
+		merged.Domains = append(merged.Domains, domain)
+	}
+	sort.Strings(merged.Domains)
+	return merged
+}
+
+// changedCertificateDomains returns the main domains of the certificates added, changed or removed from before to after
+func changedCertificateDomains(before, after []*Certificate) []string {
+	previous := make(map[string]*Certificate)
+	for _, certificate := range before {
+		previous[normalizeDomain(certificate.Domain.Main)] = certificate
+	}
+
+	var domains []string
+	for _, certificate := range after {
+		domain := normalizeDomain(certificate.Domain.Main)
+		if previousCertificate, ok := previous[domain]; !ok || !sameCertificate(previousCertificate, certificate) {
+			domains = append(domains, domain)
+		}
+		delete(previous, domain)
+	}
+	for domain := range previous {
+		domains = append(domains, domain)
+	}
+
+	sort.Strings(domains)
+	return domains
+}
+
+func sameCertificate(a, b *Certificate) bool {
+	return bytes.Equal(a.Certificate, b.Certificate) && bytes.Equal(a.Key, b.Key) &&
+		strings.Join(a.Domain.SANs, ",") == strings.Join(b.Domain.SANs, ",")
+}
+
+// Subscribe returns a channel notified of the changes of the certificates of the store, by this instance or by others,
+// and the function removing the subscription, also removed when the context is done.
+// The storage file, and the ACMECertificate resources when enabled, are watched while there are subscribers.
+func (s *LocalStore) Subscribe(ctx context.Context) (<-chan StoreChange, func()) {
+	s.watchLock.Lock()
+	defer s.watchLock.Unlock()
+
+	subscriber, count := s.subscriptions.add()
+	if count == 1 && s.watchStop == nil {
+		s.watchStop = make(chan struct{})
+		s.watchStorage(s.watchStop)
+		if s.CertificateSecrets != nil && s.CertificateSecrets.Resources {
+			s.watchCertificateResources(s.watchStop)
+		}
+	}
+
+	unsubscribed := make(chan struct{})
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			close(unsubscribed)
+
+			s.watchLock.Lock()
+			defer s.watchLock.Unlock()
+
+			if s.subscriptions.remove(subscriber) == 0 {
+				s.stopWatch()
+			}
+		})
+	}
+
+	if ctx.Done() != nil {
+		safe.Go(func() {
+			select {
+			case <-ctx.Done():
+				unsubscribe()
+			case <-unsubscribed:
+			}
+		})
+	}
+
+	return subscriber.changes, unsubscribe
+}
+
+// closeSubscriptions stops the watch of the storage file and closes the channels of the subscribers
+func (s *LocalStore) closeSubscriptions() {
+	s.watchLock.Lock()
+	defer s.watchLock.Unlock()
+
+	s.stopWatch()
+	s.subscriptions.close()
+}
+
+func (s *LocalStore) stopWatch() {
+	if s.watchStop != nil {
+		close(s.watchStop)
+		s.watchStop = nil
+	}
+}
+
+// watchStorage reloads the storage file when it is written by others, until stop is closed.
+// The changes of the file are found as with a poll of the storage, the writes of this instance are not reloaded.
+func (s *LocalStore) watchStorage(stop chan struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		s.logger(storeOperationLoad).Warnf("Unable to watch the ACME storage %s: %v", s.filename, err)
+		return
+	}
+
+	// The directory is watched, for the storage file created or replaced by others
+	if err := watcher.Add(filepath.Dir(s.filename)); err != nil {
+		watcher.Close()
+		s.logger(storeOperationLoad).Warnf("Unable to watch the ACME storage %s: %v", s.filename, err)
+		return
+	}
+
+	delay := storageWatchDelay
+	safe.Go(func() {
+		defer watcher.Close()
+
+		// The reload is delayed again by each event of the file, until it is written
+		reload := time.NewTimer(delay)
+		reload.Stop()
+		defer reload.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+
+			case event := <-watcher.Events:
+				if filepath.Clean(event.Name) == filepath.Clean(s.filename) {
+					reload.Stop()
+					reload.Reset(delay)
+				}
+
+			case err := <-watcher.Errors:
+				s.logger(storeOperationLoad).Warnf("Error while watching the ACME storage %s: %v", s.filename, err)
+
+			case <-reload.C:
+				if _, err := s.Poll(); err != nil {
+					s.logger(storeOperationLoad).Errorf("Unable to reload the changed ACME storage %s: %v", s.filename, err)
+				}
+			}
+		}
+	})
+}
+
+// serveStoreChange reloads the certificates in memory when the ones of the store are not the same.
+// It runs in the routine watching the certificates, which owns the certificates in memory.
+func (p *Provider) serveStoreChange(change StoreChange) {
+	certificates, err := p.Store.GetCertificates(p.getContext())
+	if err != nil {
+		logger().WithField(logFieldOperation, storeOperationLoad).Errorf("Unable to get the changed ACME certificates: %v", err)
+		return
+	}
+
+	// The changes of the certificates made by the provider are already served
+	if len(changedCertificateDomains(p.certificates, certificates)) == 0 {
+		return
+	}
+
+	domainsLogger(change.Domains).Infof("The ACME certificates for domains %q have changed in the storage, serving them.", strings.Join(change.Domains, ","))
+	if err := p.reloadFromStore(); err != nil {
+		logger().WithField(logFieldOperation, storeOperationLoad).Errorf("Unable to serve the changed ACME certificates: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package acme
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedCertificateDomains(t *testing.T) {
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate"), Key: []byte("key")}
+
+	testCases := []struct {
+		desc     string
+		before   []*Certificate
+		after    []*Certificate
+		expected []string
+	}{
+		{
+			desc:   "same certificates",
+			before: []*Certificate{certificate},
+			after:  []*Certificate{copyCertificate(certificate)},
+		},
+		{
+			desc:     "added certificate",
+			after:    []*Certificate{certificate},
+			expected: []string{"traefik.wtf"},
+		},
+		{
+			desc:     "removed certificate",
+			before:   []*Certificate{certificate},
+			expected: []string{"traefik.wtf"},
+		},
+		{
+			desc:     "renewed certificate",
+			before:   []*Certificate{certificate, {Domain: types.Domain{Main: "acme.wtf"}}},
+			after:    []*Certificate{{Domain: types.Domain{Main: "Traefik.wtf"}, Certificate: []byte("renewed"), Key: []byte("key")}, {Domain: types.Domain{Main: "acme.wtf"}}},
+			expected: []string{"traefik.wtf"},
+		},
+		{
+			desc:     "changed SANs",
+			before:   []*Certificate{certificate},
+			after:    []*Certificate{{Domain: types.Domain{Main: "traefik.wtf", SANs: []string{"www.traefik.wtf"}}, Certificate: []byte("certificate"), Key: []byte("key")}},
+			expected: []string{"traefik.wtf"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, changedCertificateDomains(test.before, test.after))
+		})
+	}
+}
+
+func TestStoreSubscriptionsSlowSubscriber(t *testing.T) {
+	subscriptions := &storeSubscriptions{}
+	subscriber, count := subscriptions.add()
+	assert.Equal(t, 1, count)
+
+	// The changes not received are merged, without blocking
+	subscriptions.notify([]string{"traefik.wtf"})
+	subscriptions.notify([]string{"acme.wtf"})
+	subscriptions.notify([]string{"traefik.wtf"})
+	subscriptions.notify(nil)
+
+	assert.Equal(t, StoreChange{Domains: []string{"acme.wtf", "traefik.wtf"}}, <-subscriber.changes)
+	select {
+	case change := <-subscriber.changes:
+		assert.Failf(t, "unexpected change", "%v", change)
+	default:
+	}
+
+	assert.Equal(t, 0, subscriptions.remove(subscriber))
+	_, ok := <-subscriber.changes
+	assert.False(t, ok)
+}
+
+func TestLocalStoreSubscribe(t *testing.T) {
+	defer func(delay time.Duration) { storageWatchDelay = delay }(storageWatchDelay)
+	storageWatchDelay = 10 * time.Millisecond
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	changes, unsubscribe := store.Subscribe(context.Background())
+	defer unsubscribe()
+
+	// The writes of this instance notify the subscribers
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate")}}))
+	assert.Equal(t, StoreChange{Domains: []string{"traefik.wtf"}}, waitForStoreChange(t, changes))
+
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1
+	})
+
+	// The writes of others are reloaded
+	require.NoError(t, ioutil.WriteFile(store.filename, []byte(`{"Certificates":[{"Domain":{"Main":"other.wtf"},"Certificate":"b3RoZXI=","Key":"a2V5"}]}`), 0600))
+	assert.Equal(t, StoreChange{Domains: []string{"other.wtf", "traefik.wtf"}}, waitForStoreChange(t, changes))
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, "other.wtf", certificates[0].Domain.Main)
+
+	// The subscriptions are closed with the store
+	require.NoError(t, store.Close(context.Background()))
+	_, ok := <-changes
+	assert.False(t, ok)
+}
+
+func TestLocalStoreSubscribeContext(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	// The subscription is removed when its context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, unsubscribe := store.Subscribe(ctx)
+	defer unsubscribe()
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the subscription has not been removed")
+	}
+
+	store.watchLock.Lock()
+	defer store.watchLock.Unlock()
+	assert.Nil(t, store.watchStop)
+}
+
+func TestProviderServeStoreChange(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	certificate := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate"), Key: []byte("key")}
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{copyCertificate(certificate)}))
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1
+	})
+
+	configurationChan := make(chan types.ConfigMessage, 1)
+	provider := &Provider{
+		Configuration:     &Configuration{EntryPoint: "https"},
+		Store:             store,
+		certificates:      []*Certificate{certificate},
+		certificateIndex:  newCertificateIndex([]*Certificate{certificate}),
+		configurationChan: configurationChan,
+	}
+
+	// The certificates in memory are already the ones of the store
+	provider.serveStoreChange(StoreChange{Domains: []string{"traefik.wtf"}})
+	assert.Len(t, configurationChan, 0)
+
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{certificate, {Domain: types.Domain{Main: "other.wtf"}, Certificate: []byte("other"), Key: []byte("key")}}))
+	provider.serveStoreChange(StoreChange{Domains: []string{"other.wtf"}})
+
+	require.Len(t, provider.certificates, 2)
+	config := <-configurationChan
+	assert.Len(t, config.Configuration.TLS, 2)
+}
+
+// waitForStoreChange waits for a change of the store
+func waitForStoreChange(t *testing.T, changes <-chan StoreChange) StoreChange {
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the store has not changed")
+		return StoreChange{}
+	}
+}
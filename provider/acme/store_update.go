@@ -0,0 +1,201 @@
+package acme
+
+import (
+	"context"
+	"time"
+)
+
+// Update applies a mutation to the data of the store, and saves the resulting data at once, without waiting for the next saves.
+// The mutation works on a copy of the collections of the data, which is left unchanged when the mutation fails:
+// the entries of the collections must be replaced rather than modified in place.
+func (s *LocalStore) Update(ctx context.Context, update func(data *StoredData) error) error {
+	if s.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	storedData, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	applied, err := s.applyUpdate(storedData, update)
+	if applied {
+		s.save(storedData)
+		s.flush()
+	}
+	return err
+}
+
+// applyUpdate applies the mutation to a copy of the data, which replaces the data once the mutation succeeds, and returns whether it is replaced.
+// The changed certificates are then written to the certificate Secrets, from a snapshot taken without holding the lock of the data during the Kubernetes calls:
+// the error of the Secrets is returned once the data is replaced.
+func (s *LocalStore) applyUpdate(storedData *StoredData, update func(data *StoredData) error) (bool, error) {
+	var certificatesChanged bool
+	var changedDomains []string
+	var certificates []*Certificate
+
+	err := func() error {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		updated := copyStoredData(storedData)
+		if err := update(updated); err != nil {
+			return err
+		}
+
+		certificatesChanged = !sameCertificates(storedData.Certificates, updated.Certificates)
+		changedDomains = changedCertificateDomains(storedData.Certificates, updated.Certificates)
+		s.auditUpdate(storedData, updated, certificatesChanged)
+		if certificatesChanged && s.CertificateSecrets != nil {
+			certificates = copyCertificates(updated.Certificates)
+		}
+
+		// The data is replaced in place, for the callers still holding it
+		*storedData = *updated
+		return nil
+	}()
+	if err != nil {
+		return false, err
+	}
+
+	if !certificatesChanged {
+		return true, nil
+	}
+
+	s.subscriptions.notify(changedDomains)
+	if s.CertificateSecrets != nil {
+		return true, s.saveCertificateSecrets(certificates)
+	}
+	return true, nil
+}
+
+// copyStoredData returns a copy of the data with copies of its collections, the certificates and the accounts being shared:
+// the entries of the DNS-01 challenges, of the on demand queue and of the desired domains are copied.
+func copyStoredData(storedData *StoredData) *StoredData {
+	dataCopy := *storedData
+
+	dataCopy.Certificates = append([]*Certificate(nil), storedData.Certificates...)
+
+	dataCopy.HTTPChallenges = make(map[string]map[string][]byte, len(storedData.HTTPChallenges))
+	for token, domains := range storedData.HTTPChallenges {
+		dataCopy.HTTPChallenges[token] = make(map[string][]byte, len(domains))
+		for domain, keyAuth := range domains {
+			dataCopy.HTTPChallenges[token][domain] = keyAuth
+		}
+	}
+
+	dataCopy.HTTPChallengesCreatedAt = make(map[string]map[string]time.Time, len(storedData.HTTPChallengesCreatedAt))
+	for token, domains := range storedData.HTTPChallengesCreatedAt {
+		dataCopy.HTTPChallengesCreatedAt[token] = make(map[string]time.Time, len(domains))
+		for domain, createdAt := range domains {
+			dataCopy.HTTPChallengesCreatedAt[token][domain] = createdAt
+		}
+	}
+
+	dataCopy.TLSChallenges = make(map[string]*Certificate, len(storedData.TLSChallenges))
+	for domain, certificate := range storedData.TLSChallenges {
+		dataCopy.TLSChallenges[domain] = certificate
+	}
+
+	dataCopy.TLSChallengesCreatedAt = make(map[string]time.Time, len(storedData.TLSChallengesCreatedAt))
+	for domain, createdAt := range storedData.TLSChallengesCreatedAt {
+		dataCopy.TLSChallengesCreatedAt[domain] = createdAt
+	}
+
+	if storedData.DNSChallenges != nil {
+		dataCopy.DNSChallenges = make(map[string]*DNSChallengeState, len(storedData.DNSChallenges))
+		for token, state := range storedData.DNSChallenges {
+			stateCopy := *state
+			dataCopy.DNSChallenges[token] = &stateCopy
+		}
+	}
+
+	if storedData.OnDemandQueue != nil {
+		dataCopy.OnDemandQueue = make(map[string]*OnDemandRequest, len(storedData.OnDemandQueue))
+		for domain, request := range storedData.OnDemandQueue {
+			requestCopy := *request
+			dataCopy.OnDemandQueue[domain] = &requestCopy
+		}
+	}
+
+	if storedData.DesiredDomains != nil {
+		dataCopy.DesiredDomains = make(map[string]*DesiredDomain, len(storedData.DesiredDomains))
+		for key, desired := range storedData.DesiredDomains {
+			dataCopy.DesiredDomains[key] = copyDesiredDomain(desired)
+		}
+	}
+
+	return &dataCopy
+}
+
+// sameCertificates returns whether both lists hold the same certificates, in the same order
+func sameCertificates(a, b []*Certificate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// auditUpdate records the audit entries of the changes of an update, as the individual operations of the store do
+func (s *LocalStore) auditUpdate(before, after *StoredData, certificatesChanged bool) {
+	audit := s.getAudit()
+
+	if before.Account != after.Account {
+		audit.saveAccount(after.Account, "")
+	}
+	if certificatesChanged {
+		audit.saveCertificates(after.Certificates, "")
+	}
+
+	for token, domains := range before.HTTPChallenges {
+		for domain := range domains {
+			if _, ok := after.HTTPChallenges[token][domain]; !ok {
+				audit.challenge(auditActionChallengeRemoved, challengeTypeHTTP01, domain, token)
+			}
+		}
+	}
+	for token, domains := range after.HTTPChallenges {
+		for domain := range domains {
+			if _, ok := before.HTTPChallenges[token][domain]; !ok {
+				audit.challenge(auditActionChallengeAdded, challengeTypeHTTP01, domain, token)
+			}
+		}
+	}
+
+	for domain := range before.TLSChallenges {
+		if _, ok := after.TLSChallenges[domain]; !ok {
+			audit.challenge(auditActionChallengeRemoved, challengeTypeTLSALPN01, domain, "")
+		}
+	}
+	for domain := range after.TLSChallenges {
+		if _, ok := before.TLSChallenges[domain]; !ok {
+			audit.challenge(auditActionChallengeAdded, challengeTypeTLSALPN01, domain, "")
+		}
+	}
+
+	for token, state := range before.DNSChallenges {
+		if _, ok := after.DNSChallenges[token]; !ok {
+			audit.challenge(auditActionChallengeRemoved, challengeTypeDNS01, state.Domain, token)
+		}
+	}
+	for token, state := range after.DNSChallenges {
+		if _, ok := before.DNSChallenges[token]; !ok {
+			audit.challenge(auditActionChallengeAdded, challengeTypeDNS01, state.Domain, token)
+		}
+	}
+}
+
+// removeHTTPChallengesForDomain removes all the HTTP challenge tokens of the domain from the data
+func removeHTTPChallengesForDomain(storedData *StoredData, domain string) {
+	domain = normalizeDomain(domain)
+	for token, domains := range storedData.HTTPChallenges {
+		if _, ok := domains[domain]; ok {
+			removeHTTPChallenge(storedData, token, domain)
+		}
+	}
+}
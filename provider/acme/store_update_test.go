@@ -0,0 +1,141 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStoreUpdate(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+	require.NoError(t, store.WaitPersisted(context.Background()))
+
+	var writes int32
+	store.writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		atomic.AddInt32(&writes, 1)
+		return ioutil.WriteFile(filename, data, perm)
+	}
+
+	// A failed mutation leaves the data unchanged
+	err := store.Update(context.Background(), func(data *StoredData) error {
+		data.Certificates = append(data.Certificates, &Certificate{Domain: types.Domain{Main: "traefik.wtf"}})
+		removeHTTPChallengesForDomain(data, "traefik.wtf")
+		return errors.New("mutation failed")
+	})
+	require.Error(t, err)
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, certificates)
+	_, err = store.GetHTTPChallengeToken(context.Background(), "token", "traefik.wtf")
+	require.NoError(t, err)
+
+	// The certificate and the removal of the challenge token are saved together
+	err = store.Update(context.Background(), func(data *StoredData) error {
+		data.Certificates = append(data.Certificates, &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate")})
+		removeHTTPChallengesForDomain(data, "Traefik.wtf")
+		return nil
+	})
+	require.NoError(t, err)
+
+	waitForStoredData(t, store.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1 && len(storedData.HTTPChallenges) == 0
+	})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&writes))
+
+	_, err = store.GetHTTPChallengeToken(context.Background(), "token", "traefik.wtf")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestLocalStoreUpdateCertificateSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client := newFakeSecretsClient()
+	store := newTestTLSSecretsStore(filepath.Join(dir, "acme.json"), client)
+	defer store.Close(context.Background())
+
+	// The data is served while the certificate Secrets are written
+	var served int32
+	client.reactor = func(verb, name string) {
+		if _, err := store.GetCertificates(context.Background()); err == nil {
+			atomic.AddInt32(&served, 1)
+		}
+	}
+
+	updated := make(chan error)
+	go func() {
+		updated <- store.Update(context.Background(), func(data *StoredData) error {
+			data.Certificates = append(data.Certificates, &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("cert"), Key: []byte("key")})
+			return nil
+		})
+	}()
+
+	select {
+	case err = <-updated:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The data is locked while the certificate Secrets are written")
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&served))
+	assert.Contains(t, client.secrets, "traefik/acme-traefik.wtf")
+}
+
+func TestCopyStoredData(t *testing.T) {
+	storedData := &StoredData{
+		DNSChallenges:  map[string]*DNSChallengeState{"token": {Domain: "traefik.wtf"}},
+		OnDemandQueue:  map[string]*OnDemandRequest{"traefik.wtf": {Domain: "traefik.wtf", Requests: 1}},
+		DesiredDomains: map[string]*DesiredDomain{"traefik.wtf": {Domain: types.Domain{Main: "traefik.wtf"}, Providers: []string{"file"}}},
+	}
+
+	dataCopy := copyStoredData(storedData)
+	storedData.DNSChallenges["token"].Value = "value"
+	storedData.OnDemandQueue["traefik.wtf"].Requests++
+	storedData.DesiredDomains["traefik.wtf"].Providers[0] = "docker"
+
+	assert.Empty(t, dataCopy.DNSChallenges["token"].Value)
+	assert.Equal(t, 1, dataCopy.OnDemandQueue["traefik.wtf"].Requests)
+	assert.Equal(t, []string{"file"}, dataCopy.DesiredDomains["traefik.wtf"].Providers)
+}
+
+func TestSaveObtainedCertificate(t *testing.T) {
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "acme.wtf", []byte("keyAuth")))
+
+	cert := &Certificate{Domain: types.Domain{Main: "traefik.wtf"}, Certificate: []byte("certificate")}
+	provider := &Provider{
+		Configuration:     &Configuration{},
+		Store:             store,
+		certificates:      []*Certificate{cert},
+		configurationChan: make(chan types.ConfigMessage, 1),
+	}
+
+	require.NoError(t, provider.saveObtainedCertificate(cert))
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+	assert.Equal(t, cert.Certificate, certificates[0].Certificate)
+
+	_, err = store.GetHTTPChallengeToken(context.Background(), "token", "traefik.wtf")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.GetHTTPChallengeToken(context.Background(), "token", "acme.wtf")
+	assert.NoError(t, err)
+}
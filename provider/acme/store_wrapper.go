@@ -0,0 +1,371 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// StoreWrapOptions are the layers added to a Store by WrapStore
+type StoreWrapOptions struct {
+	// MetricsRegistry reports the operations of the store, when it is enabled
+	MetricsRegistry metrics.Registry
+	// CacheTTL is the duration the account and the certificates read from the store are cached, disabled when zero
+	CacheTTL time.Duration
+}
+
+// WrapStore wraps the store to recover its panics as errors and log its mutations,
+// then to report its operations and to cache its reads, according to the options
+func WrapStore(inner Store, opts StoreWrapOptions) Store {
+	store := Store(newGuardedStore(inner, opts.MetricsRegistry))
+
+	if opts.MetricsRegistry != nil && opts.MetricsRegistry.IsEnabled() {
+		store = newInstrumentedStore(store, opts.MetricsRegistry)
+	}
+
+	if opts.CacheTTL > 0 {
+		store = newCachingStore(store, opts.CacheTTL)
+	}
+
+	return store
+}
+
+// storeLayer is implemented by the layers of WrapStore
+type storeLayer interface {
+	unwrap() Store
+}
+
+// unwrapStore returns the store wrapped by the layers of WrapStore, to check the optional interfaces it implements
+func unwrapStore(store Store) Store {
+	for {
+		layer, ok := store.(storeLayer)
+		if !ok {
+			return store
+		}
+		store = layer.unwrap()
+	}
+}
+
+// UnwrapStore returns the store wrapped by the layers of WrapStore, for the suites checking its optional interfaces
+func UnwrapStore(store Store) Store {
+	return unwrapStore(store)
+}
+
+// getStoreWrapOptions returns the options of the layers of the store of the provider
+func (p *Provider) getStoreWrapOptions(registry metrics.Registry) StoreWrapOptions {
+	return StoreWrapOptions{
+		MetricsRegistry: registry,
+		CacheTTL:        time.Duration(p.StorageCacheTTL),
+	}
+}
+
+var _ Store = (*guardedStore)(nil)
+
+// guardedStore recovers the panics of the wrapped Store as errors, and logs its mutations
+type guardedStore struct {
+	Store
+	backend  string
+	registry metrics.Registry
+}
+
+func newGuardedStore(store Store, registry metrics.Registry) *guardedStore {
+	return &guardedStore{
+		Store:    store,
+		backend:  getStoreBackend(store),
+		registry: registry,
+	}
+}
+
+func (s *guardedStore) unwrap() Store {
+	return s.Store
+}
+
+func (s *guardedStore) logger(method string) *logrus.Entry {
+	return logger().WithFields(logrus.Fields{
+		logFieldStoreBackend: s.backend,
+		logFieldStoreMethod:  method,
+	})
+}
+
+func (s *guardedStore) read(method string, read func() error) error {
+	return s.guard(method, false, read)
+}
+
+func (s *guardedStore) mutate(method string, mutation func() error) error {
+	return s.guard(method, true, mutation)
+}
+
+func (s *guardedStore) guard(method string, mutation bool, call func() error) (err error) {
+	start := time.Now()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = s.recovered(method, recovered)
+		}
+		if mutation {
+			s.logMutation(method, start, err)
+		}
+	}()
+
+	return call()
+}
+
+// recovered reports the panic of a call of the wrapped store, and returns it as an error
+func (s *guardedStore) recovered(method string, recovered interface{}) error {
+	s.logger(method).Errorf("Panic in the ACME storage: %v\n%s", recovered, debug.Stack())
+
+	if s.registry != nil {
+		s.registry.ACMEStorePanicsCounter().With("backend", s.backend).Add(1)
+	}
+
+	return fmt.Errorf("panic in the %s storage: %v", s.backend, recovered)
+}
+
+func (s *guardedStore) logMutation(method string, start time.Time, err error) {
+	// The mutations refused by a read-only store are not attempted
+	if err == ErrReadOnly {
+		return
+	}
+
+	entry := s.logger(method).WithField(logFieldOperation, storeOperationSave)
+	if err != nil {
+		entry.Debugf("The mutation of the ACME storage failed after %s: %v", time.Since(start), err)
+		return
+	}
+	entry.Debugf("The ACME storage is mutated in %s.", time.Since(start))
+}
+
+// GetAccount returns the account of the wrapped store
+func (s *guardedStore) GetAccount(ctx context.Context) (account *Account, err error) {
+	err = s.read("GetAccount", func() error {
+		account, err = s.Store.GetAccount(ctx)
+		return err
+	})
+	return account, err
+}
+
+// SaveAccount saves the account in the wrapped store
+func (s *guardedStore) SaveAccount(ctx context.Context, account *Account) error {
+	return s.mutate("SaveAccount", func() error {
+		return s.Store.SaveAccount(ctx, account)
+	})
+}
+
+// GetCertificates returns the certificates of the wrapped store
+func (s *guardedStore) GetCertificates(ctx context.Context) (certificates []*Certificate, err error) {
+	err = s.read("GetCertificates", func() error {
+		certificates, err = s.Store.GetCertificates(ctx)
+		return err
+	})
+	return certificates, err
+}
+
+// SaveCertificates saves the certificates in the wrapped store
+func (s *guardedStore) SaveCertificates(ctx context.Context, certificates []*Certificate) error {
+	return s.mutate("SaveCertificates", func() error {
+		return s.Store.SaveCertificates(ctx, certificates)
+	})
+}
+
+// GetCertificateByDomain returns the certificate of the wrapped store serving the domain
+func (s *guardedStore) GetCertificateByDomain(ctx context.Context, domain string) (certificate *Certificate, err error) {
+	err = s.read("GetCertificateByDomain", func() error {
+		certificate, err = s.Store.GetCertificateByDomain(ctx, domain)
+		return err
+	})
+	return certificate, err
+}
+
+// GetHTTPChallengeToken returns the HTTP challenge token of the wrapped store
+func (s *guardedStore) GetHTTPChallengeToken(ctx context.Context, token, domain string) (keyAuth []byte, err error) {
+	err = s.read("GetHTTPChallengeToken", func() error {
+		keyAuth, err = s.Store.GetHTTPChallengeToken(ctx, token, domain)
+		return err
+	})
+	return keyAuth, err
+}
+
+// GetHTTPChallenges returns the HTTP challenges of the wrapped store
+func (s *guardedStore) GetHTTPChallenges(ctx context.Context) (challenges []*PendingHTTPChallenge, err error) {
+	err = s.read("GetHTTPChallenges", func() error {
+		challenges, err = s.Store.GetHTTPChallenges(ctx)
+		return err
+	})
+	return challenges, err
+}
+
+// SetHTTPChallengeToken saves the HTTP challenge token in the wrapped store
+func (s *guardedStore) SetHTTPChallengeToken(ctx context.Context, token, domain string, keyAuth []byte) error {
+	return s.mutate("SetHTTPChallengeToken", func() error {
+		return s.Store.SetHTTPChallengeToken(ctx, token, domain, keyAuth)
+	})
+}
+
+// RemoveHTTPChallengeToken removes the HTTP challenge token from the wrapped store
+func (s *guardedStore) RemoveHTTPChallengeToken(ctx context.Context, token, domain string) error {
+	return s.mutate("RemoveHTTPChallengeToken", func() error {
+		return s.Store.RemoveHTTPChallengeToken(ctx, token, domain)
+	})
+}
+
+// RemoveExpiredHTTPChallengeTokens removes the expired HTTP challenge tokens from the wrapped store
+func (s *guardedStore) RemoveExpiredHTTPChallengeTokens(ctx context.Context, ttl time.Duration) (removed int, err error) {
+	err = s.mutate("RemoveExpiredHTTPChallengeTokens", func() error {
+		removed, err = s.Store.RemoveExpiredHTTPChallengeTokens(ctx, ttl)
+		return err
+	})
+	return removed, err
+}
+
+// RemoveHTTPChallengeTokensForDomain removes the HTTP challenge tokens of the domain from the wrapped store
+func (s *guardedStore) RemoveHTTPChallengeTokensForDomain(ctx context.Context, domain string) (removed int, err error) {
+	err = s.mutate("RemoveHTTPChallengeTokensForDomain", func() error {
+		removed, err = s.Store.RemoveHTTPChallengeTokensForDomain(ctx, domain)
+		return err
+	})
+	return removed, err
+}
+
+// AddTLSChallenge saves the TLS challenge in the wrapped store
+func (s *guardedStore) AddTLSChallenge(ctx context.Context, domain string, cert *Certificate) error {
+	return s.mutate("AddTLSChallenge", func() error {
+		return s.Store.AddTLSChallenge(ctx, domain, cert)
+	})
+}
+
+// GetTLSChallenge returns the TLS challenge of the wrapped store
+func (s *guardedStore) GetTLSChallenge(ctx context.Context, domain string) (certificate *Certificate, err error) {
+	err = s.read("GetTLSChallenge", func() error {
+		certificate, err = s.Store.GetTLSChallenge(ctx, domain)
+		return err
+	})
+	return certificate, err
+}
+
+// GetTLSChallenges returns the TLS challenges of the wrapped store
+func (s *guardedStore) GetTLSChallenges(ctx context.Context) (challenges map[string]*Certificate, err error) {
+	err = s.read("GetTLSChallenges", func() error {
+		challenges, err = s.Store.GetTLSChallenges(ctx)
+		return err
+	})
+	return challenges, err
+}
+
+// GetTLSChallengesCreatedAt returns the creation times of the TLS challenges of the wrapped store
+func (s *guardedStore) GetTLSChallengesCreatedAt(ctx context.Context) (createdAt map[string]time.Time, err error) {
+	err = s.read("GetTLSChallengesCreatedAt", func() error {
+		createdAt, err = s.Store.GetTLSChallengesCreatedAt(ctx)
+		return err
+	})
+	return createdAt, err
+}
+
+// RemoveTLSChallenge removes the TLS challenge from the wrapped store
+func (s *guardedStore) RemoveTLSChallenge(ctx context.Context, domain string) error {
+	return s.mutate("RemoveTLSChallenge", func() error {
+		return s.Store.RemoveTLSChallenge(ctx, domain)
+	})
+}
+
+// AddDNSChallenge saves the DNS challenge in the wrapped store
+func (s *guardedStore) AddDNSChallenge(ctx context.Context, token string, state *DNSChallengeState) error {
+	return s.mutate("AddDNSChallenge", func() error {
+		return s.Store.AddDNSChallenge(ctx, token, state)
+	})
+}
+
+// GetDNSChallenges returns the DNS challenges of the wrapped store
+func (s *guardedStore) GetDNSChallenges(ctx context.Context) (challenges map[string]*DNSChallengeState, err error) {
+	err = s.read("GetDNSChallenges", func() error {
+		challenges, err = s.Store.GetDNSChallenges(ctx)
+		return err
+	})
+	return challenges, err
+}
+
+// RemoveDNSChallenge removes the DNS challenge from the wrapped store
+func (s *guardedStore) RemoveDNSChallenge(ctx context.Context, token string) error {
+	return s.mutate("RemoveDNSChallenge", func() error {
+		return s.Store.RemoveDNSChallenge(ctx, token)
+	})
+}
+
+// AddOnDemandRequest saves the on demand request in the wrapped store
+func (s *guardedStore) AddOnDemandRequest(ctx context.Context, domain string, requestedAt time.Time) error {
+	return s.mutate("AddOnDemandRequest", func() error {
+		return s.Store.AddOnDemandRequest(ctx, domain, requestedAt)
+	})
+}
+
+// GetOnDemandQueue returns the on demand queue of the wrapped store
+func (s *guardedStore) GetOnDemandQueue(ctx context.Context) (queue map[string]*OnDemandRequest, err error) {
+	err = s.read("GetOnDemandQueue", func() error {
+		queue, err = s.Store.GetOnDemandQueue(ctx)
+		return err
+	})
+	return queue, err
+}
+
+// RemoveOnDemandRequest removes the on demand request from the wrapped store
+func (s *guardedStore) RemoveOnDemandRequest(ctx context.Context, domain string) error {
+	return s.mutate("RemoveOnDemandRequest", func() error {
+		return s.Store.RemoveOnDemandRequest(ctx, domain)
+	})
+}
+
+// RemoveExpiredOnDemandRequests removes the expired on demand requests from the wrapped store
+func (s *guardedStore) RemoveExpiredOnDemandRequests(ctx context.Context, ttl time.Duration) (removed int, err error) {
+	err = s.mutate("RemoveExpiredOnDemandRequests", func() error {
+		removed, err = s.Store.RemoveExpiredOnDemandRequests(ctx, ttl)
+		return err
+	})
+	return removed, err
+}
+
+// GetStagingAccount returns the staging account of the wrapped store
+func (s *guardedStore) GetStagingAccount(ctx context.Context) (account *Account, err error) {
+	err = s.read("GetStagingAccount", func() error {
+		account, err = s.Store.GetStagingAccount(ctx)
+		return err
+	})
+	return account, err
+}
+
+// Update applies the mutation in the wrapped store, a panic of the mutation leaves the data unchanged
+func (s *guardedStore) Update(ctx context.Context, update func(data *StoredData) error) error {
+	return s.mutate("Update", func() error {
+		return s.Store.Update(ctx, update)
+	})
+}
+
+// Subscribe subscribes to the changes of the wrapped store
+func (s *guardedStore) Subscribe(ctx context.Context) (changes <-chan StoreChange, unsubscribe func()) {
+	err := s.read("Subscribe", func() error {
+		changes, unsubscribe = s.Store.Subscribe(ctx)
+		return nil
+	})
+	if err != nil {
+		// The subscription is closed at once
+		closed := make(chan StoreChange)
+		close(closed)
+		return closed, func() {}
+	}
+	return changes, unsubscribe
+}
+
+// Health checks the health of the wrapped store
+func (s *guardedStore) Health(ctx context.Context) error {
+	return s.read("Health", func() error {
+		return s.Store.Health(ctx)
+	})
+}
+
+// Close closes the wrapped store
+func (s *guardedStore) Close(ctx context.Context) error {
+	return s.read("Close", func() error {
+		return s.Store.Close(ctx)
+	})
+}
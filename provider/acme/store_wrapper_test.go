@@ -0,0 +1,212 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type panickingStore struct {
+	*LocalStore
+}
+
+func (s *panickingStore) GetCertificates(ctx context.Context) ([]*Certificate, error) {
+	panic("BOOM")
+}
+
+func (s *panickingStore) SaveAccount(ctx context.Context, account *Account) error {
+	panic("BOOM")
+}
+
+type countingStore struct {
+	*LocalStore
+	accountReads     int32
+	certificateReads int32
+	readErr          error
+}
+
+func (s *countingStore) GetAccount(ctx context.Context) (*Account, error) {
+	atomic.AddInt32(&s.accountReads, 1)
+	if s.readErr != nil {
+		return nil, s.readErr
+	}
+	return s.LocalStore.GetAccount(ctx)
+}
+
+func (s *countingStore) GetCertificates(ctx context.Context) ([]*Certificate, error) {
+	atomic.AddInt32(&s.certificateReads, 1)
+	return s.LocalStore.GetCertificates(ctx)
+}
+
+func TestWrapStore(t *testing.T) {
+	inner := &LocalStore{storedData: &StoredData{}}
+
+	testCases := []struct {
+		desc     string
+		opts     StoreWrapOptions
+		expected []Store
+	}{
+		{
+			desc:     "default layers",
+			expected: []Store{&guardedStore{}},
+		},
+		{
+			desc:     "disabled metrics",
+			opts:     StoreWrapOptions{MetricsRegistry: &disabledACMEMetrics{newCollectingACMEMetrics()}},
+			expected: []Store{&guardedStore{}},
+		},
+		{
+			desc:     "metrics",
+			opts:     StoreWrapOptions{MetricsRegistry: newCollectingACMEMetrics()},
+			expected: []Store{&instrumentedStore{}, &guardedStore{}},
+		},
+		{
+			desc:     "metrics and cache",
+			opts:     StoreWrapOptions{MetricsRegistry: newCollectingACMEMetrics(), CacheTTL: time.Minute},
+			expected: []Store{&cachingStore{}, &instrumentedStore{}, &guardedStore{}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			store := WrapStore(inner, test.opts)
+			assert.Equal(t, inner, unwrapStore(store))
+			assert.Equal(t, "file", getStoreBackend(store))
+
+			for _, layer := range test.expected {
+				require.IsType(t, layer, store)
+				store = store.(storeLayer).unwrap()
+			}
+			assert.Equal(t, inner, store)
+		})
+	}
+}
+
+type disabledACMEMetrics struct {
+	*collectingACMEMetrics
+}
+
+func (m *disabledACMEMetrics) IsEnabled() bool {
+	return false
+}
+
+func TestGuardedStorePanic(t *testing.T) {
+	localStore, clean := newTestLocalStore(t)
+	defer clean()
+
+	registry := newCollectingACMEMetrics()
+	store := WrapStore(&panickingStore{LocalStore: localStore}, StoreWrapOptions{MetricsRegistry: registry})
+
+	// The panics are returned as errors, and counted as failures
+	_, err := store.GetCertificates(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "panic in the *acme.panickingStore storage: BOOM", err.Error())
+	assert.Equal(t, float64(1), registry.failures.CounterValue)
+
+	err = store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"})
+	require.Error(t, err)
+	assert.Equal(t, float64(2), registry.panics.CounterValue)
+	assert.Equal(t, []string{"backend", "*acme.panickingStore"}, registry.panics.LastLabelValues)
+
+	// The store is still usable after a panic of a mutation
+	err = store.Update(context.Background(), func(data *StoredData) error {
+		panic("BOOM")
+	})
+	require.Error(t, err)
+	assert.Equal(t, float64(3), registry.panics.CounterValue)
+
+	require.NoError(t, store.Update(context.Background(), func(data *StoredData) error {
+		data.Certificates = []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}}}
+		return nil
+	}))
+	certificates, err := localStore.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, certificates, 1)
+}
+
+func TestCachingStore(t *testing.T) {
+	localStore, clean := newTestLocalStore(t)
+	defer clean()
+
+	inner := &countingStore{LocalStore: localStore}
+	store := WrapStore(inner, StoreWrapOptions{CacheTTL: time.Hour})
+
+	require.NoError(t, store.SaveAccount(context.Background(), &Account{Email: "test@traefik.wtf"}))
+	waitForStoredData(t, localStore.filename, func(storedData *StoredData) bool {
+		return storedData.Account != nil
+	})
+	for i := 0; i < 3; i++ {
+		account, err := store.GetAccount(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "test@traefik.wtf", account.Email)
+		_, err = store.GetCertificates(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.accountReads))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.certificateReads))
+
+	// The saves drop the cache
+	require.NoError(t, store.SaveCertificates(context.Background(), []*Certificate{{Domain: types.Domain{Main: "traefik.wtf"}}}))
+	waitForStoredData(t, localStore.filename, func(storedData *StoredData) bool {
+		return len(storedData.Certificates) == 1
+	})
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, certificates, 1)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&inner.certificateReads))
+
+	// The changes notified by the inner store drop the cache before they are received
+	changes, unsubscribe := store.Subscribe(context.Background())
+	defer unsubscribe()
+
+	require.NoError(t, localStore.SaveCertificates(context.Background(), nil))
+	assert.Equal(t, StoreChange{Domains: []string{"traefik.wtf"}}, waitForStoreChange(t, changes))
+	certificates, err = store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, certificates)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&inner.certificateReads))
+
+	// The failed reads are not cached
+	inner.readErr = errors.New("unable to read the account")
+	invalidateStoreCache(store)
+	_, err = store.GetAccount(context.Background())
+	assert.Error(t, err)
+	_, err = store.GetAccount(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&inner.accountReads))
+}
+
+func TestCachingStoreTTL(t *testing.T) {
+	inner := &countingStore{LocalStore: &LocalStore{storedData: &StoredData{}}}
+	store := newCachingStore(inner, time.Millisecond)
+
+	_, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = store.GetCertificates(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&inner.certificateReads))
+}
+
+func TestGuardedStoreErrors(t *testing.T) {
+	localStore, clean := newTestLocalStore(t)
+	defer clean()
+
+	store := WrapStore(&failingStore{LocalStore: localStore}, StoreWrapOptions{})
+
+	// The errors of the inner store are returned unchanged
+	assert.Equal(t, errors.New("unable to save the account"), store.SaveAccount(context.Background(), &Account{}))
+
+	localStore.SetReadOnly(true)
+	assert.Equal(t, ErrReadOnly, store.SaveCertificates(context.Background(), nil))
+}
@@ -0,0 +1,165 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/provider/acme"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readOnlyStore is implemented by the stores with a read-only mode
+type readOnlyStore interface {
+	SetReadOnly(readOnly bool)
+}
+
+// desiredDomainsStore is implemented by the stores keeping the desired domains
+type desiredDomainsStore interface {
+	GetDesiredDomains(ctx context.Context) (map[string]*acme.DesiredDomain, error)
+}
+
+// orderHistoryStore is implemented by the stores keeping the history of the orders
+type orderHistoryStore interface {
+	GetOrderHistory(ctx context.Context) (*acme.OrderHistory, error)
+}
+
+// defaultCertificateStore is implemented by the stores keeping the default certificate of the entry points
+type defaultCertificateStore interface {
+	GetDefaultCertificate(ctx context.Context) (*acme.DefaultCertificate, error)
+}
+
+// testContract checks that the stores of the factory follow the rules of the errors of the Store, described in store_errors.go
+func testContract(t *testing.T, factory Factory) {
+	t.Run("missing values", func(t *testing.T) {
+		store, clean := openStore(t, factory)
+		defer clean()
+
+		account, err := store.GetAccount(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, account)
+
+		certificate, err := store.GetCertificateByDomain(context.Background(), "traefik.wtf")
+		assert.Equal(t, acme.ErrNotFound, err)
+		assert.Nil(t, certificate)
+
+		keyAuth, err := store.GetHTTPChallengeToken(context.Background(), "token", "traefik.wtf")
+		assert.Equal(t, acme.ErrNotFound, err)
+		assert.Nil(t, keyAuth)
+
+		certificate, err = store.GetTLSChallenge(context.Background(), "traefik.wtf")
+		assert.Equal(t, acme.ErrNotFound, err)
+		assert.Nil(t, certificate)
+	})
+
+	t.Run("empty collections", func(t *testing.T) {
+		store, clean := openStore(t, factory)
+		defer clean()
+
+		certificates, err := store.GetCertificates(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, certificates)
+
+		httpChallenges, err := store.GetHTTPChallenges(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, httpChallenges)
+
+		tlsChallenges, err := store.GetTLSChallenges(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, tlsChallenges)
+
+		createdAt, err := store.GetTLSChallengesCreatedAt(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, createdAt)
+
+		dnsChallenges, err := store.GetDNSChallenges(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, dnsChallenges)
+
+		queue, err := store.GetOnDemandQueue(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, queue)
+
+		if store, ok := acme.UnwrapStore(store).(desiredDomainsStore); ok {
+			desired, err := store.GetDesiredDomains(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, desired)
+		}
+
+		if store, ok := acme.UnwrapStore(store).(orderHistoryStore); ok {
+			history, err := store.GetOrderHistory(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, &acme.OrderHistory{}, history)
+		}
+
+		stagingAccount, err := store.GetStagingAccount(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, stagingAccount)
+
+		if store, ok := acme.UnwrapStore(store).(defaultCertificateStore); ok {
+			defaultCertificate, err := store.GetDefaultCertificate(context.Background())
+			require.NoError(t, err)
+			assert.Nil(t, defaultCertificate)
+		}
+	})
+
+	t.Run("removal of missing values", func(t *testing.T) {
+		store, clean := openStore(t, factory)
+		defer clean()
+
+		assert.NoError(t, store.RemoveHTTPChallengeToken(context.Background(), "token", "traefik.wtf"))
+		assert.NoError(t, store.RemoveTLSChallenge(context.Background(), "traefik.wtf"))
+		assert.NoError(t, store.RemoveDNSChallenge(context.Background(), "token"))
+		assert.NoError(t, store.RemoveOnDemandRequest(context.Background(), "traefik.wtf"))
+
+		removed, err := store.RemoveHTTPChallengeTokensForDomain(context.Background(), "traefik.wtf")
+		require.NoError(t, err)
+		assert.Equal(t, 0, removed)
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		store, clean := openStore(t, factory)
+		defer clean()
+
+		if !store.Capabilities().SupportsTransactions {
+			t.Skip("the store has no transactions")
+		}
+
+		// A failed mutation leaves the data unchanged
+		err := store.Update(context.Background(), func(data *acme.StoredData) error {
+			data.Certificates = []*acme.Certificate{{Domain: types.Domain{Main: "traefik.wtf"}}}
+			return errors.New("mutation failed")
+		})
+		require.Error(t, err)
+
+		certificates, err := store.GetCertificates(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, certificates)
+	})
+
+	t.Run("read-only store", func(t *testing.T) {
+		store, clean := openStore(t, factory)
+		defer clean()
+
+		readOnly, ok := acme.UnwrapStore(store).(readOnlyStore)
+		if !ok {
+			t.Skip("the store has no read-only mode")
+		}
+		readOnly.SetReadOnly(true)
+
+		assert.Equal(t, acme.ErrReadOnly, store.SaveAccount(context.Background(), &acme.Account{Email: "test@traefik.wtf"}))
+		assert.Equal(t, acme.ErrReadOnly, store.SaveCertificates(context.Background(), nil))
+		assert.Equal(t, acme.ErrReadOnly, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+		assert.Equal(t, acme.ErrReadOnly, store.AddTLSChallenge(context.Background(), "traefik.wtf", &acme.Certificate{}))
+		assert.Equal(t, acme.ErrReadOnly, store.AddDNSChallenge(context.Background(), "token", &acme.DNSChallengeState{Domain: "traefik.wtf"}))
+		assert.Equal(t, acme.ErrReadOnly, store.AddOnDemandRequest(context.Background(), "traefik.wtf", time.Now()))
+		assert.Equal(t, acme.ErrReadOnly, store.Update(context.Background(), func(data *acme.StoredData) error { return nil }))
+
+		account, err := store.GetAccount(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, account)
+	})
+}
@@ -0,0 +1,293 @@
+// Package storetest is the conformance suite of the implementations of the ACME Store.
+// The backends maintained out of the tree run it to check that they keep the semantics relied on by the provider.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/provider/acme"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrentUpdates is the number of updates run concurrently by the suite
+const concurrentUpdates = 20
+
+// Factory returns the function opening a store on a new empty storage, and the function removing the storage.
+// The stores opened by the function share the storage, a store being opened again once the previous one is closed.
+type Factory func(t *testing.T) (open func() acme.Store, clean func())
+
+// RunStoreTests runs the conformance suite against the stores of the factory: the contract of the errors of the Store,
+// then the semantics of its operations. Run it with -race, the concurrent access being checked by the race detector.
+func RunStoreTests(t *testing.T, factory Factory) {
+	testContract(t, factory)
+	t.Run("account round-trip", func(t *testing.T) { testAccount(t, factory) })
+	t.Run("certificates", func(t *testing.T) { testCertificates(t, factory) })
+	t.Run("HTTP-01 challenge tokens", func(t *testing.T) { testHTTPChallenges(t, factory) })
+	t.Run("TLS-ALPN-01 challenges", func(t *testing.T) { testTLSChallenges(t, factory) })
+	t.Run("DNS-01 challenges", func(t *testing.T) { testDNSChallenges(t, factory) })
+	t.Run("concurrent access", func(t *testing.T) { testConcurrentAccess(t, factory) })
+	t.Run("persistence", func(t *testing.T) { testPersistence(t, factory) })
+}
+
+// openStore opens a store on a new storage, and returns the function closing it and removing the storage
+func openStore(t *testing.T, factory Factory) (acme.Store, func()) {
+	open, clean := factory(t)
+	store := open()
+	return store, func() {
+		assert.NoError(t, store.Close(context.Background()))
+		clean()
+	}
+}
+
+func newCertificate(domain string, sans ...string) *acme.Certificate {
+	return &acme.Certificate{
+		Domain:      types.Domain{Main: domain, SANs: sans},
+		Certificate: []byte("certificate of " + domain),
+		Key:         []byte("key of " + domain),
+		KeyType:     "EC256",
+	}
+}
+
+func getDomains(certificates []*acme.Certificate) []string {
+	var domains []string
+	for _, certificate := range certificates {
+		domains = append(domains, certificate.Domain.Main)
+	}
+	return domains
+}
+
+func testAccount(t *testing.T, factory Factory) {
+	store, clean := openStore(t, factory)
+	defer clean()
+
+	account := &acme.Account{Email: "test@traefik.wtf", PrivateKey: []byte("private key"), PrivateKeyType: "EC256", KeyType: "EC256"}
+	require.NoError(t, store.SaveAccount(context.Background(), account))
+
+	saved, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, account.Email, saved.Email)
+	assert.Equal(t, account.PrivateKey, saved.PrivateKey)
+	assert.Equal(t, account.KeyType, saved.KeyType)
+
+	// The account is replaced
+	require.NoError(t, store.SaveAccount(context.Background(), &acme.Account{Email: "other@traefik.wtf", PrivateKey: []byte("other key"), KeyType: "RSA4096"}))
+	saved, err = store.GetAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, "other@traefik.wtf", saved.Email)
+	assert.Equal(t, []byte("other key"), saved.PrivateKey)
+}
+
+func testCertificates(t *testing.T, factory Factory) {
+	store, clean := openStore(t, factory)
+	defer clean()
+
+	certificates := []*acme.Certificate{newCertificate("traefik.wtf", "www.traefik.wtf"), newCertificate("other.wtf")}
+	require.NoError(t, store.SaveCertificates(context.Background(), certificates))
+
+	saved, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"traefik.wtf", "other.wtf"}, getDomains(saved))
+
+	certificate, err := store.GetCertificateByDomain(context.Background(), "traefik.wtf")
+	require.NoError(t, err)
+	require.NotNil(t, certificate)
+	assert.Equal(t, []string{"www.traefik.wtf"}, certificate.Domain.SANs)
+	assert.Equal(t, []byte("certificate of traefik.wtf"), certificate.Certificate)
+	assert.Equal(t, []byte("key of traefik.wtf"), certificate.Key)
+
+	// The saved list replaces the certificates: one is renewed, the other one removed
+	renewed := newCertificate("traefik.wtf", "www.traefik.wtf")
+	renewed.Certificate = []byte("renewed certificate")
+	require.NoError(t, store.SaveCertificates(context.Background(), []*acme.Certificate{renewed}))
+
+	certificate, err = store.GetCertificateByDomain(context.Background(), "traefik.wtf")
+	require.NoError(t, err)
+	require.NotNil(t, certificate)
+	assert.Equal(t, []byte("renewed certificate"), certificate.Certificate)
+
+	_, err = store.GetCertificateByDomain(context.Background(), "other.wtf")
+	assert.Equal(t, acme.ErrNotFound, err)
+
+	// The certificates are updated at once with the current ones
+	err = store.Update(context.Background(), func(data *acme.StoredData) error {
+		data.Certificates = append(data.Certificates, newCertificate("updated.wtf"))
+		return nil
+	})
+	require.NoError(t, err)
+
+	saved, err = store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"traefik.wtf", "updated.wtf"}, getDomains(saved))
+
+	require.NoError(t, store.SaveCertificates(context.Background(), nil))
+	saved, err = store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, saved)
+}
+
+func testHTTPChallenges(t *testing.T, factory Factory) {
+	store, clean := openStore(t, factory)
+	defer clean()
+
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "traefik.wtf", []byte("keyAuth")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "token", "www.traefik.wtf", []byte("www keyAuth")))
+	require.NoError(t, store.SetHTTPChallengeToken(context.Background(), "other", "other.wtf", []byte("other keyAuth")))
+
+	keyAuth, err := store.GetHTTPChallengeToken(context.Background(), "token", "traefik.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("keyAuth"), keyAuth)
+
+	// A token is only served for the domain it was set for
+	_, err = store.GetHTTPChallengeToken(context.Background(), "token", "other.wtf")
+	assert.Equal(t, acme.ErrNotFound, err)
+
+	challenges, err := store.GetHTTPChallenges(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, challenges, 3)
+
+	require.NoError(t, store.RemoveHTTPChallengeToken(context.Background(), "token", "traefik.wtf"))
+	_, err = store.GetHTTPChallengeToken(context.Background(), "token", "traefik.wtf")
+	assert.Equal(t, acme.ErrNotFound, err)
+
+	// The other domains of the token are kept
+	keyAuth, err = store.GetHTTPChallengeToken(context.Background(), "token", "www.traefik.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("www keyAuth"), keyAuth)
+
+	removed, err := store.RemoveHTTPChallengeTokensForDomain(context.Background(), "other.wtf")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, err = store.GetHTTPChallengeToken(context.Background(), "other", "other.wtf")
+	assert.Equal(t, acme.ErrNotFound, err)
+
+	// The tokens set before the TTL are kept
+	removed, err = store.RemoveExpiredHTTPChallengeTokens(context.Background(), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	challenges, err = store.GetHTTPChallenges(context.Background())
+	require.NoError(t, err)
+	require.Len(t, challenges, 1)
+	assert.Equal(t, "token", challenges[0].Token)
+	assert.Equal(t, "www.traefik.wtf", challenges[0].Domain)
+}
+
+func testTLSChallenges(t *testing.T, factory Factory) {
+	store, clean := openStore(t, factory)
+	defer clean()
+
+	require.NoError(t, store.AddTLSChallenge(context.Background(), "traefik.wtf", newCertificate("traefik.wtf")))
+
+	certificate, err := store.GetTLSChallenge(context.Background(), "traefik.wtf")
+	require.NoError(t, err)
+	require.NotNil(t, certificate)
+	assert.Equal(t, []byte("certificate of traefik.wtf"), certificate.Certificate)
+
+	challenges, err := store.GetTLSChallenges(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, challenges, 1)
+
+	createdAt, err := store.GetTLSChallengesCreatedAt(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, createdAt, "traefik.wtf")
+
+	require.NoError(t, store.RemoveTLSChallenge(context.Background(), "traefik.wtf"))
+	_, err = store.GetTLSChallenge(context.Background(), "traefik.wtf")
+	assert.Equal(t, acme.ErrNotFound, err)
+
+	challenges, err = store.GetTLSChallenges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, challenges)
+}
+
+func testDNSChallenges(t *testing.T, factory Factory) {
+	store, clean := openStore(t, factory)
+	defer clean()
+
+	state := &acme.DNSChallengeState{Provider: "manual", Domain: "traefik.wtf", Token: "token", FQDN: "_acme-challenge.traefik.wtf.", Value: "value", CreatedAt: time.Now()}
+	require.NoError(t, store.AddDNSChallenge(context.Background(), "token", state))
+
+	challenges, err := store.GetDNSChallenges(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, challenges, "token")
+	assert.Equal(t, "_acme-challenge.traefik.wtf.", challenges["token"].FQDN)
+	assert.Equal(t, "value", challenges["token"].Value)
+
+	require.NoError(t, store.RemoveDNSChallenge(context.Background(), "token"))
+	challenges, err = store.GetDNSChallenges(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, challenges)
+}
+
+// testConcurrentAccess checks that the concurrent updates are all applied, while the certificates are read
+func testConcurrentAccess(t *testing.T, factory Factory) {
+	store, clean := openStore(t, factory)
+	defer clean()
+
+	var expected []string
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*concurrentUpdates)
+	for i := 0; i < concurrentUpdates; i++ {
+		domain := fmt.Sprintf("domain%d.traefik.wtf", i)
+		expected = append(expected, domain)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- store.Update(context.Background(), func(data *acme.StoredData) error {
+				data.Certificates = append(data.Certificates, newCertificate(domain))
+				return nil
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := store.GetCertificates(context.Background())
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	certificates, err := store.GetCertificates(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, expected, getDomains(certificates))
+}
+
+// testPersistence checks that the account and the certificates are read back by a store opened on the same storage once closed
+func testPersistence(t *testing.T, factory Factory) {
+	open, clean := factory(t)
+	defer clean()
+
+	store := open()
+	require.NoError(t, store.SaveAccount(context.Background(), &acme.Account{Email: "test@traefik.wtf", PrivateKey: []byte("private key"), PrivateKeyType: "EC256", KeyType: "EC256"}))
+	require.NoError(t, store.SaveCertificates(context.Background(), []*acme.Certificate{newCertificate("traefik.wtf", "www.traefik.wtf")}))
+	require.NoError(t, store.Close(context.Background()))
+
+	store = open()
+	defer store.Close(context.Background())
+
+	account, err := store.GetAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, account)
+	assert.Equal(t, "test@traefik.wtf", account.Email)
+	assert.Equal(t, []byte("private key"), account.PrivateKey)
+
+	certificate, err := store.GetCertificateByDomain(context.Background(), "www.traefik.wtf")
+	require.NoError(t, err)
+	require.NotNil(t, certificate)
+	assert.Equal(t, "traefik.wtf", certificate.Domain.Main)
+	assert.Equal(t, []byte("certificate of traefik.wtf"), certificate.Certificate)
+	assert.Equal(t, []byte("key of traefik.wtf"), certificate.Key)
+}
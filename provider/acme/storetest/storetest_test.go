@@ -0,0 +1,57 @@
+package storetest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/metrics"
+	"github.com/containous/traefik/provider/acme"
+	"github.com/stretchr/testify/require"
+)
+
+// newLocalStoreFactory returns the factory of the local stores on a new storage file, configured by the function with the directory of the file
+func newLocalStoreFactory(configure func(store *acme.LocalStore, dir string) acme.Store) Factory {
+	return func(t *testing.T) (func() acme.Store, func()) {
+		dir, err := ioutil.TempDir("", "acme")
+		require.NoError(t, err)
+
+		open := func() acme.Store {
+			return configure(acme.NewLocalStore(filepath.Join(dir, "acme.json")), dir)
+		}
+		return open, func() { os.RemoveAll(dir) }
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	RunStoreTests(t, newLocalStoreFactory(func(store *acme.LocalStore, _ string) acme.Store {
+		return store
+	}))
+}
+
+func TestLocalStoreCoalescedSaves(t *testing.T) {
+	RunStoreTests(t, newLocalStoreFactory(func(store *acme.LocalStore, _ string) acme.Store {
+		store.SaveQuietPeriod = acme.DefaultSaveQuietPeriod
+		return store
+	}))
+}
+
+func TestEncryptedLocalStore(t *testing.T) {
+	RunStoreTests(t, newLocalStoreFactory(func(store *acme.LocalStore, dir string) acme.Store {
+		keyFile := filepath.Join(dir, "acme.key")
+		if _, err := os.Stat(keyFile); os.IsNotExist(err) {
+			require.NoError(t, ioutil.WriteFile(keyFile, bytes.Repeat([]byte{1}, 32), 0600))
+		}
+		store.Encryption = &acme.StorageEncryption{KeyFile: keyFile}
+		return store
+	}))
+}
+
+func TestWrappedLocalStore(t *testing.T) {
+	RunStoreTests(t, newLocalStoreFactory(func(store *acme.LocalStore, _ string) acme.Store {
+		return acme.WrapStore(store, acme.StoreWrapOptions{MetricsRegistry: metrics.NewVoidRegistry(), CacheTTL: time.Minute})
+	}))
+}
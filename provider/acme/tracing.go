@@ -0,0 +1,190 @@
+package acme
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/xenolf/lego/acme"
+)
+
+const (
+	spanObtain      = "acme.obtain"
+	spanRenew       = "acme.renew"
+	spanOrder       = "acme.order"
+	spanStore       = "acme.store."
+	spanChallenge   = "acme.challenge."
+	spanPropagation = "acme.dns.propagation"
+
+	spanTagDomain        = "acme.domain"
+	spanTagChallengeType = "acme.challenge_type"
+	spanTagBackend       = "acme.backend"
+	spanTagResult        = "acme.result"
+
+	spanResultSuccess = "success"
+	spanResultFailure = "failure"
+
+	challengeOperationPresent = "present"
+	challengeOperationCleanUp = "cleanup"
+	challengeOperationGet     = "get"
+)
+
+// preCheckDNSOnce wraps the lego DNS propagation check once, it is shared by all the clients
+var preCheckDNSOnce sync.Once
+
+// Tracer starts the spans of the ACME operations, it is implemented by the Traefik tracing
+type Tracer interface {
+	StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span
+}
+
+// issuanceTracer creates the spans of the ACME operations, parented under the root span of the issuance of their domain.
+// A nil issuanceTracer creates no span.
+type issuanceTracer struct {
+	tracer Tracer
+
+	lock      sync.RWMutex
+	issuances map[string]opentracing.Span
+}
+
+// traceSpan is a span of an ACME operation, a nil traceSpan records nothing
+type traceSpan struct {
+	span opentracing.Span
+}
+
+// SetTracer creates the spans of the ACME operations with the tracer
+func (p *Provider) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		p.tracing = nil
+		return
+	}
+	p.tracing = &issuanceTracer{tracer: tracer, issuances: make(map[string]opentracing.Span)}
+}
+
+// startIssuance starts the root span of the issuance or the renewal of the certificate of the domains
+func (t *issuanceTracer) startIssuance(operationName string, domains []string, challengeType string) *traceSpan {
+	if t == nil {
+		return nil
+	}
+
+	span := t.tracer.StartSpan(operationName)
+	span.SetTag(spanTagDomain, strings.Join(domains, ","))
+	span.SetTag(spanTagChallengeType, challengeType)
+
+	t.lock.Lock()
+	for _, domain := range domains {
+		t.issuances[normalizeDomain(domain)] = span
+	}
+	t.lock.Unlock()
+
+	return &traceSpan{span: span}
+}
+
+// endIssuance finishes the root span of the issuance of the certificate of the domains
+func (t *issuanceTracer) endIssuance(domains []string, span *traceSpan, err error) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	for _, domain := range domains {
+		if t.issuances[normalizeDomain(domain)] == span.span {
+			delete(t.issuances, normalizeDomain(domain))
+		}
+	}
+	t.lock.Unlock()
+
+	span.finish(err)
+}
+
+// startSpan starts the span of an operation, as a child of the issuance of the domain if any
+func (t *issuanceTracer) startSpan(operationName, domain string) *traceSpan {
+	if t == nil {
+		return nil
+	}
+
+	var opts []opentracing.StartSpanOption
+	if len(domain) > 0 {
+		t.lock.RLock()
+		if issuance, ok := t.issuances[normalizeDomain(domain)]; ok {
+			opts = append(opts, opentracing.ChildOf(issuance.Context()))
+		}
+		t.lock.RUnlock()
+	}
+
+	span := t.tracer.StartSpan(operationName, opts...)
+	if len(domain) > 0 {
+		span.SetTag(spanTagDomain, domain)
+	}
+	return &traceSpan{span: span}
+}
+
+// traceStore traces an operation on the store, for the domain if any
+func (t *issuanceTracer) traceStore(store Store, operation, domain string, fn func() error) error {
+	return t.trace(spanStore+operation, domain, "", store, fn)
+}
+
+// traceChallenge traces the setting, the getting or the removal of a challenge in the store
+func (t *issuanceTracer) traceChallenge(store Store, challengeType, operation, domain string, fn func() error) error {
+	return t.trace(spanChallenge+operation, domain, challengeType, store, fn)
+}
+
+func (t *issuanceTracer) trace(operationName, domain, challengeType string, store Store, fn func() error) error {
+	if t == nil {
+		return fn()
+	}
+
+	span := t.startSpan(operationName, domain)
+	span.setTag(spanTagBackend, getStoreBackend(unwrapStore(store)))
+	if len(challengeType) > 0 {
+		span.setTag(spanTagChallengeType, challengeType)
+	}
+
+	err := fn()
+	span.finish(err)
+	return err
+}
+
+// tracePreCheckDNS traces the checks of the propagation of the DNS challenge records
+func (t *issuanceTracer) tracePreCheckDNS() {
+	if t == nil {
+		return
+	}
+
+	preCheckDNSOnce.Do(func() {
+		preCheckDNS := acme.PreCheckDNS
+		acme.PreCheckDNS = func(fqdn, value string) (bool, error) {
+			domain := strings.TrimSuffix(strings.TrimPrefix(fqdn, "_acme-challenge."), ".")
+
+			span := t.startSpan(spanPropagation, domain)
+			propagated, err := preCheckDNS(fqdn, value)
+			span.setTag("acme.propagated", propagated)
+			span.finish(err)
+
+			return propagated, err
+		}
+	})
+}
+
+func (s *traceSpan) setTag(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.span.SetTag(key, value)
+}
+
+// finish sets the result of the operation and finishes the span
+func (s *traceSpan) finish(err error) {
+	if s == nil {
+		return
+	}
+
+	if err != nil {
+		ext.Error.Set(s.span, true)
+		s.span.SetTag(spanTagResult, spanResultFailure)
+		s.span.LogKV("error", err.Error())
+	} else {
+		s.span.SetTag(spanTagResult, spanResultSuccess)
+	}
+	s.span.Finish()
+}
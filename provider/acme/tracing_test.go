@@ -0,0 +1,132 @@
+package acme
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSpan struct {
+	opentracing.Span
+	name     string
+	parent   *recordingSpan
+	tags     map[string]interface{}
+	finished bool
+}
+
+type recordingSpanContext struct {
+	opentracing.SpanContext
+	span *recordingSpan
+}
+
+func (s *recordingSpan) Context() opentracing.SpanContext {
+	return recordingSpanContext{SpanContext: s.Span.Context(), span: s}
+}
+
+func (s *recordingSpan) SetTag(key string, value interface{}) opentracing.Span {
+	s.tags[key] = value
+	return s
+}
+
+func (s *recordingSpan) Finish() {
+	s.finished = true
+}
+
+type recordingTracer struct {
+	lock  sync.Mutex
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	options := opentracing.StartSpanOptions{}
+	for _, opt := range opts {
+		opt.Apply(&options)
+	}
+
+	span := &recordingSpan{
+		Span: opentracing.NoopTracer{}.StartSpan(operationName),
+		name: operationName,
+		tags: make(map[string]interface{}),
+	}
+	for _, reference := range options.References {
+		if ctx, ok := reference.ReferencedContext.(recordingSpanContext); ok {
+			span.parent = ctx.span
+		}
+	}
+
+	t.lock.Lock()
+	t.spans = append(t.spans, span)
+	t.lock.Unlock()
+	return span
+}
+
+func (t *recordingTracer) findSpan(name string) *recordingSpan {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, span := range t.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestIssuanceTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	provider := &Provider{}
+	provider.SetTracer(tracer)
+
+	store, clean := newTestLocalStore(t)
+	defer clean()
+
+	challenge := &challengeHTTP{Store: store, tracing: provider.tracing}
+
+	domains := []string{"traefik.wtf", "www.traefik.wtf"}
+	span := provider.tracing.startIssuance(spanObtain, domains, challengeTypeHTTP01)
+	require.NoError(t, challenge.Present("www.traefik.wtf", "token", "keyAuth"))
+	provider.tracing.endIssuance(domains, span, errors.New("order failed"))
+
+	root := tracer.findSpan(spanObtain)
+	require.NotNil(t, root)
+	assert.True(t, root.finished)
+	assert.Equal(t, "traefik.wtf,www.traefik.wtf", root.tags[spanTagDomain])
+	assert.Equal(t, spanResultFailure, root.tags[spanTagResult])
+	assert.Equal(t, true, root.tags["error"])
+
+	present := tracer.findSpan(spanChallenge + challengeOperationPresent)
+	require.NotNil(t, present)
+	assert.True(t, present.finished)
+	assert.Equal(t, root, present.parent)
+	assert.Equal(t, "www.traefik.wtf", present.tags[spanTagDomain])
+	assert.Equal(t, challengeTypeHTTP01, present.tags[spanTagChallengeType])
+	assert.Equal(t, "file", present.tags[spanTagBackend])
+	assert.Equal(t, spanResultSuccess, present.tags[spanTagResult])
+
+	// The operations after the issuance are not parented
+	require.NoError(t, challenge.CleanUp("www.traefik.wtf", "token", "keyAuth"))
+	cleanUp := tracer.findSpan(spanChallenge + challengeOperationCleanUp)
+	require.NotNil(t, cleanUp)
+	assert.Nil(t, cleanUp.parent)
+}
+
+func TestIssuanceTracerDisabled(t *testing.T) {
+	var tracing *issuanceTracer
+
+	called := false
+	err := tracing.traceStore(nil, storeOperationSave, "traefik.wtf", func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+
+	span := tracing.startIssuance(spanObtain, []string{"traefik.wtf"}, challengeTypeHTTP01)
+	assert.Nil(t, span)
+	tracing.endIssuance([]string{"traefik.wtf"}, span, nil)
+	tracing.startSpan(spanOrder, "traefik.wtf").finish(nil)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/cenk/backoff"
 	"github.com/containous/traefik/log"
@@ -143,6 +144,48 @@ func GoWithRecover(goroutine func(), customRecover func(err interface{})) {
 	}()
 }
 
+// GoSupervised starts a recoverable goroutine, restarted with an exponential backoff after each panic until it returns.
+// onPanic, if any, is called with each recovered panic and its stack.
+func GoSupervised(goroutine func(), onPanic func(err interface{}, stack []byte)) {
+	go func() {
+		restartBackOff := backoff.NewExponentialBackOff()
+		restartBackOff.MaxElapsedTime = 0
+
+		for {
+			start := time.Now()
+			if !runSupervised(goroutine, onPanic) {
+				return
+			}
+
+			// A goroutine which ran for a while before panicking is restarted quickly
+			if time.Since(start) > restartBackOff.MaxInterval {
+				restartBackOff.Reset()
+			}
+			time.Sleep(restartBackOff.NextBackOff())
+		}
+	}()
+}
+
+// runSupervised runs the goroutine, and returns whether it panicked
+func runSupervised(goroutine func(), onPanic func(err interface{}, stack []byte)) (panicked bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			panicked = true
+
+			stack := debug.Stack()
+			log.Errorf("Error in Go routine: %s, restarting it", err)
+			if onPanic != nil {
+				onPanic(err, stack)
+			} else {
+				log.Debugf("%s", stack)
+			}
+		}
+	}()
+
+	goroutine()
+	return false
+}
+
 func defaultRecoverGoroutine(err interface{}) {
 	log.Errorf("Error in Go routine: %s", err)
 	debug.PrintStack()
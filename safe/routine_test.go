@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -200,3 +201,34 @@ func TestOperationWithRecoverError(t *testing.T) {
 		t.Fatalf("Error in OperationWithRecover: %s", err)
 	}
 }
+
+func TestGoSupervised(t *testing.T) {
+	var runs int32
+	panics := make(chan interface{}, 2)
+	done := make(chan struct{})
+
+	GoSupervised(func() {
+		if atomic.AddInt32(&runs, 1) <= 2 {
+			panic("BOOM")
+		}
+		close(done)
+	}, func(err interface{}, stack []byte) {
+		if len(stack) == 0 {
+			t.Error("the stack of the panic is missing")
+		}
+		panics <- err
+	})
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the goroutine was not restarted after the panics")
+	}
+
+	if len(panics) != 2 {
+		t.Fatalf("expected 2 reported panics, got %d", len(panics))
+	}
+	if err := <-panics; err != "BOOM" {
+		t.Fatalf("unexpected panic %v", err)
+	}
+}
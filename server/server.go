@@ -109,6 +109,7 @@ type Server struct {
 	metricsRegistry               metrics.Registry
 	provider                      provider.Provider
 	configurationListeners        []func(types.Configuration)
+	messageListeners              []func(types.ConfigMessage)
 	entryPoints                   map[string]EntryPoint
 	bufferPool                    httputil.BufferPool
 }
@@ -120,6 +121,8 @@ type EntryPoint struct {
 	OnDemandListener func(string) (*tls.Certificate, error)
 	TLSALPNGetter    func(string) (*tls.Certificate, error)
 	CertificateStore *traefiktls.CertificateStore
+	// DefaultCertificateGetter returns the default certificate when none is configured, a random one is generated when nil
+	DefaultCertificateGetter func() (*tls.Certificate, error)
 }
 
 type serverEntryPoints map[string]*serverEntryPoint
@@ -211,7 +214,8 @@ func NewServer(globalConfiguration configuration.GlobalConfiguration, provider p
 	server.defaultForwardingRoundTripper = transport
 
 	server.tracingMiddleware = globalConfiguration.Tracing
-	if server.tracingMiddleware != nil && server.tracingMiddleware.Backend != "" {
+	// The tracing may already be set up to trace the start of the ACME provider
+	if server.tracingMiddleware != nil && server.tracingMiddleware.Backend != "" && !server.tracingMiddleware.IsEnabled() {
 		server.tracingMiddleware.Setup()
 	}
 
@@ -360,6 +364,16 @@ func (s *Server) AddListener(listener func(types.Configuration)) {
 	s.configurationListeners = append(s.configurationListeners, listener)
 }
 
+// AddMessageListener adds a new listener function used when new configuration is provided, with the name of its provider
+func (s *Server) AddMessageListener(listener func(types.ConfigMessage)) {
+	s.messageListeners = append(s.messageListeners, listener)
+}
+
+// GetMetricsRegistry returns the metrics registry used by the server
+func (s *Server) GetMetricsRegistry() metrics.Registry {
+	return s.metricsRegistry
+}
+
 // getCertificate allows to customize tlsConfig.GetCertificate behavior to get the certificates inserted dynamically
 func (s *serverEntryPoint) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	domainToCheck := types.CanonicalDomain(clientHello.ServerName)
@@ -71,6 +71,9 @@ func (s *Server) loadConfiguration(configMsg types.ConfigMessage) {
 	for _, listener := range s.configurationListeners {
 		listener(*configMsg.Configuration)
 	}
+	for _, listener := range s.messageListeners {
+		listener(configMsg)
+	}
 
 	s.postLoadConfiguration()
 }
@@ -569,7 +572,11 @@ func (s *Server) buildServerEntryPoints() map[string]*serverEntryPoint {
 				}
 				serverEntryPoints[entryPointName].certs.DefaultCertificate = &cert
 			} else {
-				cert, err := generate.DefaultCertificate()
+				getDefaultCertificate := entryPoint.DefaultCertificateGetter
+				if getDefaultCertificate == nil {
+					getDefaultCertificate = generate.DefaultCertificate
+				}
+				cert, err := getDefaultCertificate()
 				if err != nil {
 					log.Error(err)
 					continue
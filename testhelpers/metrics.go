@@ -41,6 +41,25 @@ func (g *CollectingGauge) Add(delta float64) {
 	g.GaugeValue = delta
 }
 
+// CollectingHistogram is a metrics.Histogram implementation that enables access to the ObservationsCount, LastValue and LastLabelValues.
+type CollectingHistogram struct {
+	ObservationsCount int
+	LastValue         float64
+	LastLabelValues   []string
+}
+
+// With is there to satisfy the metrics.Histogram interface.
+func (h *CollectingHistogram) With(labelValues ...string) metrics.Histogram {
+	h.LastLabelValues = labelValues
+	return h
+}
+
+// Observe is there to satisfy the metrics.Histogram interface.
+func (h *CollectingHistogram) Observe(value float64) {
+	h.ObservationsCount++
+	h.LastValue = value
+}
+
 // CollectingHealthCheckMetrics can be used for testing the Metrics instrumentation of the HealthCheck package.
 type CollectingHealthCheckMetrics struct {
 	Gauge *CollectingGauge
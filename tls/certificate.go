@@ -51,9 +51,11 @@ var (
 
 // Certificate holds a SSL cert/key pair
 // Certs and Key could be either a file path, or the file content itself
+// OCSPStaple is the content of the DER encoded OCSP response stapled to the certificate
 type Certificate struct {
-	CertFile FileOrContent
-	KeyFile  FileOrContent
+	CertFile   FileOrContent
+	KeyFile    FileOrContent
+	OCSPStaple FileOrContent `json:",omitempty"`
 }
 
 // Certificates defines traefik certificates type
@@ -150,6 +152,11 @@ func (c *Certificate) AppendCertificates(certs map[string]map[string]*tls.Certif
 		return fmt.Errorf("unable to generate TLS certificate : %v", err)
 	}
 
+	// The DER encoded OCSP response is stapled to the certificate, when provided
+	if len(c.OCSPStaple) > 0 {
+		tlsCert.OCSPStaple = []byte(c.OCSPStaple)
+	}
+
 	parsedCert, _ := x509.ParseCertificate(tlsCert.Certificate[0])
 
 	var SANs []string
@@ -19,26 +19,31 @@ const DefaultDomain = "TRAEFIK DEFAULT CERT"
 
 // DefaultCertificate generates random TLS certificates
 func DefaultCertificate() (*tls.Certificate, error) {
-	randomBytes := make([]byte, 100)
-	_, err := rand.Read(randomBytes)
+	certPEM, keyPEM, err := DefaultKeyPair()
 	if err != nil {
 		return nil, err
 	}
-	zBytes := sha256.Sum256(randomBytes)
-	z := hex.EncodeToString(zBytes[:sha256.Size])
-	domain := fmt.Sprintf("%s.%s.traefik.default", z[:32], z[32:])
 
-	certPEM, keyPEM, err := KeyPair(domain, time.Time{})
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
 	if err != nil {
 		return nil, err
 	}
 
-	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	return &certificate, nil
+}
+
+// DefaultKeyPair generates the PEM encoded cert and key of a random default certificate
+func DefaultKeyPair() ([]byte, []byte, error) {
+	randomBytes := make([]byte, 100)
+	_, err := rand.Read(randomBytes)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	zBytes := sha256.Sum256(randomBytes)
+	z := hex.EncodeToString(zBytes[:sha256.Size])
+	domain := fmt.Sprintf("%s.%s.traefik.default", z[:32], z[32:])
 
-	return &certificate, nil
+	return KeyPair(domain, time.Time{})
 }
 
 // KeyPair generates cert and key files
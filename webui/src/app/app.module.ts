@@ -7,6 +7,7 @@ import { RouterModule } from '@angular/router';
 import { AppComponent } from './app.component';
 import { BarChartComponent } from './charts/bar-chart/bar-chart.component';
 import { LineChartComponent } from './charts/line-chart/line-chart.component';
+import { CertificatesComponent } from './components/certificates/certificates.component';
 import { HeaderComponent } from './components/header/header.component';
 import { HealthComponent } from './components/health/health.component';
 import { ProvidersComponent } from './components/providers/providers.component';
@@ -23,6 +24,7 @@ import { WindowService } from './services/window.service';
     HeaderComponent,
     ProvidersComponent,
     HealthComponent,
+    CertificatesComponent,
     LineChartComponent,
     BarChartComponent,
     KeysPipe,
@@ -37,7 +39,8 @@ import { WindowService } from './services/window.service';
     FormsModule,
     RouterModule.forRoot([
       {path: '', component: ProvidersComponent, pathMatch: 'full'},
-      {path: 'status', component: HealthComponent}
+      {path: 'status', component: HealthComponent},
+      {path: 'certificates', component: CertificatesComponent}
     ])
   ],
   providers: [
@@ -0,0 +1,74 @@
+import { Component, OnDestroy, OnInit } from '@angular/core';
+import { differenceInDays, distanceInWordsStrict } from 'date-fns';
+import * as _ from 'lodash';
+import 'rxjs/add/observable/forkJoin';
+import 'rxjs/add/observable/timer';
+import 'rxjs/add/operator/mergeMap';
+import 'rxjs/add/operator/timeInterval';
+import { Observable } from 'rxjs/Observable';
+import { Subscription } from 'rxjs/Subscription';
+import { ApiService } from '../../services/api.service';
+
+@Component({
+  selector: 'app-certificates',
+  templateUrl: 'certificates.component.html'
+})
+export class CertificatesComponent implements OnInit, OnDestroy {
+  sub: Subscription;
+  available: boolean;
+  certificates: any[];
+  previousData: any;
+
+  constructor(private apiService: ApiService) { }
+
+  ngOnInit() {
+    this.sub = Observable.timer(0, 5000)
+      .timeInterval()
+      .mergeMap(() => Observable.forkJoin(this.apiService.fetchACMECertificates(), this.apiService.fetchACMEChallenges()))
+      .subscribe(([certificates, challenges]) => {
+        this.available = certificates !== null;
+
+        const data = {certificates: certificates || [], challenges: challenges || []};
+        if (!_.isEqual(this.previousData, data)) {
+          this.previousData = _.cloneDeep(data);
+          this.certificates = this.parseCertificates(data.certificates, data.challenges);
+        }
+      });
+  }
+
+  parseCertificates(certificates: any[], challenges: any[]): any[] {
+    const now = new Date();
+
+    return certificates.map(certificate => {
+      const domains = [certificate.domain].concat(certificate.sans || []);
+
+      certificate.challenges = challenges.filter(challenge => domains.includes(challenge.domain));
+      if (certificate.notAfter) {
+        const notAfter = new Date(certificate.notAfter);
+        certificate.expiry = distanceInWordsStrict(now, notAfter, {addSuffix: true});
+        certificate.expiryClass = this.getExpiryClass(differenceInDays(notAfter, now));
+      }
+      return certificate;
+    });
+  }
+
+  getExpiryClass(days: number): string {
+    if (days < 7) {
+      return 'is-danger';
+    }
+    if (days < 30) {
+      return 'is-warning';
+    }
+    return 'is-success';
+  }
+
+  trackCertificate(index, item): string {
+    return item.domain;
+  }
+
+  ngOnDestroy() {
+    if (this.sub) {
+      this.sub.unsubscribe();
+    }
+  }
+}
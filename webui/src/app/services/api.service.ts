@@ -52,6 +52,27 @@ export class ApiService {
       .map((data: any): ProviderType => this.parseProviders(data));
   }
 
+  fetchACMECertificates(): Observable<any> {
+    return this.http.get('../api/acme/certificates', {headers: this.headers})
+      .catch((err: HttpErrorResponse) => {
+        // The ACME routes are not served when no resolver is configured
+        if (err.status !== 404) {
+          console.error(`[acme certificates] returned code ${err.status}, body was: ${err.error}`);
+        }
+        return Observable.of<any>(null);
+      });
+  }
+
+  fetchACMEChallenges(): Observable<any> {
+    return this.http.get('../api/acme/challenges', {headers: this.headers})
+      .catch((err: HttpErrorResponse) => {
+        if (err.status !== 404) {
+          console.error(`[acme challenges] returned code ${err.status}, body was: ${err.error}`);
+        }
+        return Observable.of<any>([]);
+      });
+  }
+
   parseProviders(data: any): ProviderType {
     return Object.keys(data)
       .filter(value => value !== 'acme' && value !== 'ACME')